@@ -0,0 +1,8 @@
+// Package openapi embeds the OpenAPI description of the REST API, served at
+// /api/openapi.json and used to keep the client package in step.
+package openapi
+
+import _ "embed"
+
+//go:embed openapi.json
+var Spec []byte
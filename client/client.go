@@ -0,0 +1,269 @@
+// Package client calls the ZFSRabbit REST API described by
+// api/openapi/openapi.json. Each method is named after the operationId of
+// the endpoint it calls.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client talks to one ZFSRabbit instance
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	username   string
+	password   string
+	token      string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBasicAuth authenticates as a configured user
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username, c.password = username, password
+	}
+}
+
+// WithToken authenticates with an API token from /api/tokens
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient, for
+// example to trust the instance's certificate
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// New returns a client for the instance at baseURL, such as
+// https://backup.example.com:8443
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimRight(baseURL, "/"), httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is a response with an unexpected status
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("zfsrabbit: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// do sends a request with in as the JSON body, if not nil, and decodes a
+// 2xx response into out, if not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("zfsrabbit: decoding %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// jobQuery selects a jobs entry, or the zfs section if job is ""
+func jobQuery(job string) url.Values {
+	if job == "" {
+		return nil
+	}
+	return url.Values{"job": {job}}
+}
+
+// GetHealth calls GET /health
+func (c *Client) GetHealth(ctx context.Context) (*Health, error) {
+	var out Health
+	return &out, c.do(ctx, http.MethodGet, "/health", nil, nil, &out)
+}
+
+// GetVersion calls GET /api/version
+func (c *Client) GetVersion(ctx context.Context) (*Version, error) {
+	var out Version
+	return &out, c.do(ctx, http.MethodGet, "/api/version", nil, nil, &out)
+}
+
+// GetStatus calls GET /api/status
+func (c *Client) GetStatus(ctx context.Context) (*Status, error) {
+	var out Status
+	return &out, c.do(ctx, http.MethodGet, "/api/status", nil, nil, &out)
+}
+
+// ListSnapshots calls GET /api/snapshots
+func (c *Client) ListSnapshots(ctx context.Context) ([]Snapshot, error) {
+	var out []Snapshot
+	err := c.do(ctx, http.MethodGet, "/api/snapshots", nil, nil, &out)
+	return out, err
+}
+
+// TriggerSnapshot calls POST /api/trigger/snapshot
+func (c *Client) TriggerSnapshot(ctx context.Context, job string) (*Result, error) {
+	var out Result
+	return &out, c.do(ctx, http.MethodPost, "/api/trigger/snapshot", jobQuery(job), nil, &out)
+}
+
+// TriggerScrub calls POST /api/trigger/scrub
+func (c *Client) TriggerScrub(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/trigger/scrub", nil, nil, nil)
+}
+
+// RetryPendingSends calls POST /api/trigger/retry
+func (c *Client) RetryPendingSends(ctx context.Context) (*Result, error) {
+	var out Result
+	return &out, c.do(ctx, http.MethodPost, "/api/trigger/retry", nil, nil, &out)
+}
+
+// ListBackfillGaps calls GET /api/backfill
+func (c *Client) ListBackfillGaps(ctx context.Context, job string) ([]BackfillGap, error) {
+	var out []BackfillGap
+	err := c.do(ctx, http.MethodGet, "/api/backfill", jobQuery(job), nil, &out)
+	return out, err
+}
+
+// TriggerBackfill calls POST /api/backfill
+func (c *Client) TriggerBackfill(ctx context.Context, job string) (*Result, error) {
+	var out Result
+	return &out, c.do(ctx, http.MethodPost, "/api/backfill", jobQuery(job), nil, &out)
+}
+
+// StartRestore calls POST /api/restore and returns the restore job's ID
+func (c *Client) StartRestore(ctx context.Context, req RestoreRequest) (string, error) {
+	var out struct {
+		JobID string `json:"job_id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/restore", nil, req, &out)
+	return out.JobID, err
+}
+
+// EstimateRestore calls GET /api/restore/estimate; dataset may be "" for the
+// default remote dataset
+func (c *Client) EstimateRestore(ctx context.Context, dataset, snapshot string) (*RestoreEstimate, error) {
+	query := url.Values{"snapshot": {snapshot}}
+	if dataset != "" {
+		query.Set("dataset", dataset)
+	}
+	var out RestoreEstimate
+	return &out, c.do(ctx, http.MethodGet, "/api/restore/estimate", query, nil, &out)
+}
+
+// ListRestoreJobs calls GET /api/restore/jobs
+func (c *Client) ListRestoreJobs(ctx context.Context) ([]RestoreJob, error) {
+	var out []RestoreJob
+	err := c.do(ctx, http.MethodGet, "/api/restore/jobs", nil, nil, &out)
+	return out, err
+}
+
+// CancelRestoreJob calls DELETE /api/restore/jobs/{id}
+func (c *Client) CancelRestoreJob(ctx context.Context, id string) (*Result, error) {
+	var out Result
+	return &out, c.do(ctx, http.MethodDelete, "/api/restore/jobs/"+url.PathEscape(id), nil, nil, &out)
+}
+
+// ConfirmRestore calls POST /api/restore/confirm/{id}
+func (c *Client) ConfirmRestore(ctx context.Context, id string) (*Result, error) {
+	var out Result
+	return &out, c.do(ctx, http.MethodPost, "/api/restore/confirm/"+url.PathEscape(id), nil, nil, &out)
+}
+
+// ListRemoteDatasets calls GET /api/remote/datasets
+func (c *Client) ListRemoteDatasets(ctx context.Context) (*RemoteDatasets, error) {
+	var out RemoteDatasets
+	return &out, c.do(ctx, http.MethodGet, "/api/remote/datasets", nil, nil, &out)
+}
+
+// GetRemoteDataset calls GET /api/remote/dataset/{dataset}
+func (c *Client) GetRemoteDataset(ctx context.Context, dataset string) (*RemoteDatasetInfo, error) {
+	var out RemoteDatasetInfo
+	return &out, c.do(ctx, http.MethodGet, "/api/remote/dataset/"+strings.ReplaceAll(dataset, "/", "%2F"), nil, nil, &out)
+}
+
+// StartMigration calls POST /api/migration/start
+func (c *Client) StartMigration(ctx context.Context, req MigrationRequest) (*MigrationSession, error) {
+	var out MigrationSession
+	return &out, c.do(ctx, http.MethodPost, "/api/migration/start", nil, req, &out)
+}
+
+// GetMigrationStatus calls GET /api/migration/status
+func (c *Client) GetMigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	var out MigrationStatus
+	return &out, c.do(ctx, http.MethodGet, "/api/migration/status", nil, nil, &out)
+}
+
+// ExecuteMigrationStep calls POST /api/migration/step
+func (c *Client) ExecuteMigrationStep(ctx context.Context) (*MigrationStepResult, error) {
+	var out MigrationStepResult
+	return &out, c.do(ctx, http.MethodPost, "/api/migration/step", nil, nil, &out)
+}
+
+// CancelMigration calls POST /api/migration/cancel
+func (c *Client) CancelMigration(ctx context.Context) (*Result, error) {
+	var out Result
+	return &out, c.do(ctx, http.MethodPost, "/api/migration/cancel", nil, nil, &out)
+}
+
+// PrepareMigrationTarget calls POST /api/migration/target/prepare
+func (c *Client) PrepareMigrationTarget(ctx context.Context, req MigrationTargetRequest) (*MigrationTargetResult, error) {
+	var out MigrationTargetResult
+	return &out, c.do(ctx, http.MethodPost, "/api/migration/target/prepare", nil, req, &out)
+}
+
+// FinalMigrationRestore calls POST /api/migration/target/restore
+func (c *Client) FinalMigrationRestore(ctx context.Context, req MigrationTargetRequest) (*MigrationTargetResult, error) {
+	var out MigrationTargetResult
+	return &out, c.do(ctx, http.MethodPost, "/api/migration/target/restore", nil, req, &out)
+}
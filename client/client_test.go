@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zfsrabbit/api/openapi"
+)
+
+// TestClientCoversSpec keeps the client in step with the spec: every
+// operationId needs a method of the same name
+func TestClientCoversSpec(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+
+	client := reflect.TypeOf(&Client{})
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			if operation.OperationID == "" {
+				t.Errorf("%s %s has no operationId", method, path)
+				continue
+			}
+			name := strings.ToUpper(operation.OperationID[:1]) + operation.OperationID[1:]
+			if _, ok := client.MethodByName(name); !ok {
+				t.Errorf("No client method %s for %s %s", name, method, path)
+			}
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer zfsr_test" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/backfill":
+			if r.URL.Query().Get("job") != "media" {
+				http.Error(w, "unknown job", http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`[{"job":"media","dataset":"tank/media","target":"primary","from":"a","missing":["b","c"]}]`))
+		case "/api/restore":
+			var req RestoreRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Snapshot != "autosnap_1" || req.Dataset != "tank/restored" {
+				http.Error(w, "Snapshot and dataset are required", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"job_id":"restore_1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL+"/", WithToken("zfsr_test"))
+	ctx := context.Background()
+
+	gaps, err := c.ListBackfillGaps(ctx, "media")
+	if err != nil {
+		t.Fatalf("ListBackfillGaps: %v", err)
+	}
+	if len(gaps) != 1 || len(gaps[0].Missing) != 2 || gaps[0].Target != "primary" {
+		t.Errorf("Unexpected gaps %+v", gaps)
+	}
+
+	id, err := c.StartRestore(ctx, RestoreRequest{Snapshot: "autosnap_1", Dataset: "tank/restored"})
+	if err != nil || id != "restore_1" {
+		t.Errorf("StartRestore = %q, %v", id, err)
+	}
+
+	_, err = c.StartRestore(ctx, RestoreRequest{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Snapshot and dataset are required" {
+		t.Errorf("Expected a 400 error, got %v", err)
+	}
+
+	_, err = New(server.URL, WithBasicAuth("admin", "wrong")).GetStatus(ctx)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 error, got %v", err)
+	}
+}
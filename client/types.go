@@ -0,0 +1,236 @@
+package client
+
+import "time"
+
+// The types below mirror the schemas in api/openapi/openapi.json
+
+// Health is the liveness check response
+type Health struct {
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+	Service   string `json:"service"`
+}
+
+// Version is the build of the running binary
+type Version struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// Result is the outcome of an action
+type Result struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status is the instance's pools, disks, checks and replication state.
+// Sections without a typed field are left as raw maps.
+type Status struct {
+	Healthy      bool                   `json:"healthy"`
+	Pools        map[string]interface{} `json:"pools"`
+	Disks        map[string]interface{} `json:"disks"`
+	Checks       map[string]interface{} `json:"checks"`
+	PendingSends []string               `json:"pendingSends"`
+	Targets      []TargetStatus         `json:"targets"`
+	Jobs         []JobStatus            `json:"jobs"`
+	Health       []PairHealth           `json:"health"`
+	DryRun       bool                   `json:"dry_run"`
+}
+
+// JobStatus is a replication job and the state of its targets
+type JobStatus struct {
+	Name     string         `json:"name"`
+	Dataset  string         `json:"dataset"`
+	Schedule string         `json:"schedule"`
+	Targets  []TargetStatus `json:"targets"`
+
+	DeferredSince *time.Time `json:"deferred_since,omitempty"` // Sends are waiting for the pool to go quiet
+}
+
+// TargetStatus is the replication state of one backup server
+type TargetStatus struct {
+	Name             string     `json:"name"`
+	Host             string     `json:"host"`
+	Dataset          string     `json:"dataset"`
+	Pending          []string   `json:"pending"`
+	LastSuccess      *time.Time `json:"last_success,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+	Sending          string     `json:"sending,omitempty"`
+	SendStarted      *time.Time `json:"send_started,omitempty"`
+	EstimatedBytes   int64      `json:"estimated_bytes,omitempty"`
+	EstimatedSeconds int64      `json:"estimated_seconds,omitempty"`
+	SentBytes        int64      `json:"sent_bytes,omitempty"`
+}
+
+// PairHealth scores the replication of one dataset to one target from 0 to 100
+type PairHealth struct {
+	Job         string   `json:"job"`
+	Dataset     string   `json:"dataset"`
+	Target      string   `json:"target"`
+	Score       int      `json:"score"`
+	Emoji       string   `json:"emoji"`
+	SuccessRate float64  `json:"success_rate"`
+	LagSeconds  int64    `json:"lag_seconds"`
+	RPOSeconds  int64    `json:"rpo_seconds"`
+	Unverified  int      `json:"unverified"`
+	Pending     int      `json:"pending"`
+	Issues      []string `json:"issues,omitempty"`
+}
+
+// Snapshot is a local snapshot. Created is in the instance's date format.
+type Snapshot struct {
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Used    string `json:"used"`
+	Refer   string `json:"refer"`
+}
+
+// BackfillGap is the run of snapshots one target is missing
+type BackfillGap struct {
+	Job     string   `json:"job"`
+	Dataset string   `json:"dataset"`
+	Target  string   `json:"target"`
+	From    string   `json:"from,omitempty"`
+	Missing []string `json:"missing"`
+	Blocker string   `json:"blocker,omitempty"`
+}
+
+// RestoreRequest restores Snapshot from the backup server into Dataset
+type RestoreRequest struct {
+	Snapshot      string `json:"snapshot"`
+	Dataset       string `json:"dataset"`
+	SourceDataset string `json:"source_dataset,omitempty"` // The default remote dataset if empty
+	Mountpoint    string `json:"mountpoint,omitempty"`
+	Mount         bool   `json:"mount,omitempty"`
+	ShareNFS      string `json:"sharenfs,omitempty"`
+	ShareSMB      string `json:"sharesmb,omitempty"`
+}
+
+// RestoreEstimate is the size of a restore and, once there is transfer
+// history, how long it should take
+type RestoreEstimate struct {
+	Bytes    int64  `json:"bytes"`
+	Size     string `json:"size"`
+	Tier     string `json:"tier"`
+	Seconds  int64  `json:"seconds,omitempty"`
+	Estimate string `json:"estimate,omitempty"`
+}
+
+// RestoreJob is a restore and its progress
+type RestoreJob struct {
+	ID               string  `json:"id"`
+	Snapshot         string  `json:"snapshot"`
+	Dataset          string  `json:"dataset"`
+	Status           string  `json:"status"`
+	Progress         int     `json:"progress"`
+	StartTime        string  `json:"start_time"`
+	EndTime          string  `json:"end_time,omitempty"`
+	Error            string  `json:"error,omitempty"`
+	BytesTransferred int64   `json:"bytes_transferred,omitempty"`
+	TransferRate     float64 `json:"transfer_rate,omitempty"`
+	TotalBytes       int64   `json:"total_bytes,omitempty"`
+	ETA              string  `json:"eta,omitempty"`
+	RestoredDataset  string  `json:"restored_dataset,omitempty"`
+	Tier             string  `json:"tier,omitempty"`
+	MountedAt        string  `json:"mounted_at,omitempty"`
+	RequiresConfirm  bool    `json:"requires_confirm,omitempty"`
+	SafetyWarning    string  `json:"safety_warning,omitempty"`
+}
+
+// RemoteDatasets lists the datasets on the backup server
+type RemoteDatasets struct {
+	LocalDataset          string              `json:"local_dataset"`
+	RemoteDatasets        map[string][]string `json:"remote_datasets"`
+	AvailableForRestore   []RemoteDataset     `json:"available_for_restore"`
+	ManagedByThisInstance RemoteDataset       `json:"managed_by_this_instance"`
+}
+
+// RemoteDataset is a dataset on the backup server and its snapshots
+type RemoteDataset struct {
+	Dataset        string   `json:"dataset"`
+	Snapshots      []string `json:"snapshots"`
+	LatestSnapshot string   `json:"latest_snapshot,omitempty"`
+}
+
+// RemoteDatasetInfo is the detail of one dataset on the backup server
+type RemoteDatasetInfo struct {
+	Name       string   `json:"name"`
+	Used       string   `json:"used"`
+	Available  string   `json:"available"`
+	Referenced string   `json:"referenced"`
+	Mountpoint string   `json:"mountpoint"`
+	Snapshots  []string `json:"snapshots"`
+}
+
+// MigrationRequest starts a migration of SourceDataset to another node
+type MigrationRequest struct {
+	SourceDataset string `json:"sourceDataset"`
+	TargetHost    string `json:"targetHost"`
+	TargetDataset string `json:"targetDataset"`
+	ManageShares  bool   `json:"manageShares"`
+}
+
+// MigrationSession is a migration in progress or finished
+type MigrationSession struct {
+	ID              string     `json:"id"`
+	SourceDataset   string     `json:"sourceDataset"`
+	TargetHost      string     `json:"targetHost"`
+	TargetDataset   string     `json:"targetDataset"`
+	CurrentStep     int        `json:"currentStep"`
+	Status          string     `json:"status"`
+	StartTime       time.Time  `json:"startTime"`
+	InitialSnapshot string     `json:"initialSnapshot,omitempty"`
+	InitialSyncTime *time.Time `json:"initialSyncTime,omitempty"`
+	FinalSnapshot   string     `json:"finalSnapshot,omitempty"`
+	CompletionTime  *time.Time `json:"completionTime,omitempty"`
+	WorkloadStopped bool       `json:"workloadStopped"`
+	WorkloadStarted bool       `json:"workloadStarted"`
+	TargetPrepared  bool       `json:"targetPrepared"`
+	ManageShares    bool       `json:"manageShares"`
+	SharesDisabled  bool       `json:"sharesDisabled,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// MigrationStep is one step of the migration wizard
+type MigrationStep struct {
+	Title        string `json:"Title"`
+	Description  string `json:"Description"`
+	Action       string `json:"Action"`
+	TargetAction string `json:"TargetAction"`
+}
+
+// MigrationStatus is the active migration, if any, and the wizard's steps
+type MigrationStatus struct {
+	Active          bool              `json:"active"`
+	Session         *MigrationSession `json:"session,omitempty"`
+	Steps           []MigrationStep   `json:"steps"`
+	CurrentStepInfo *MigrationStep    `json:"currentStepInfo,omitempty"`
+}
+
+// MigrationStepResult is the outcome of running a migration step
+type MigrationStepResult struct {
+	Success bool              `json:"success"`
+	Error   string            `json:"error,omitempty"`
+	Session *MigrationSession `json:"session,omitempty"`
+}
+
+// MigrationTargetRequest restores a migration snapshot on the target node
+type MigrationTargetRequest struct {
+	SourceDataset string `json:"sourceDataset"`
+	TargetDataset string `json:"targetDataset"`
+	SnapshotName  string `json:"snapshotName"`
+	ShareNFS      string `json:"sharenfs,omitempty"`
+	ShareSMB      string `json:"sharesmb,omitempty"`
+}
+
+// MigrationTargetResult reports the restore job started on the target node
+type MigrationTargetResult struct {
+	Success      bool   `json:"success"`
+	Message      string `json:"message,omitempty"`
+	Error        string `json:"error,omitempty"`
+	RestoreJobID string `json:"restoreJobId,omitempty"`
+}
@@ -0,0 +1,121 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// sendTimeout bounds a single delivery attempt over any channel
+	sendTimeout = 30 * time.Second
+
+	// A channel's breaker opens after breakerThreshold consecutive failures,
+	// first for breakerBaseDelay, doubling each time a trial delivery fails,
+	// up to breakerMaxDelay
+	breakerThreshold = 3
+	breakerBaseDelay = 30 * time.Second
+	breakerMaxDelay  = 30 * time.Minute
+)
+
+// ErrCircuitOpen is returned without attempting delivery while a channel's
+// breaker is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Breaker stops deliveries to a failing channel so a hung SMTP server or
+// Slack outage costs one timeout rather than one per alert. Once the open
+// period has passed, a single trial delivery decides whether to close it
+// again or back off for twice as long.
+type Breaker struct {
+	channel   string
+	timeout   time.Duration
+	mutex     sync.Mutex
+	failures  int // Consecutive failures
+	trips     int // Consecutive times opened, for the backoff
+	openUntil time.Time
+	trial     bool // A trial delivery is in progress
+}
+
+func NewBreaker(channel string, timeout time.Duration) *Breaker {
+	return &Breaker{
+		channel: channel,
+		timeout: timeout,
+	}
+}
+
+// Call runs send unless the breaker is open, cancelling its context once the
+// breaker's timeout passes. send must give up when the context is done.
+func (b *Breaker) Call(send func(ctx context.Context) error) error {
+	b.mutex.Lock()
+	now := time.Now()
+	if now.Before(b.openUntil) || b.trial {
+		retry := b.openUntil
+		b.mutex.Unlock()
+		return fmt.Errorf("%s: %w, retrying after %s", b.channel, ErrCircuitOpen, retry.Format(time.RFC3339))
+	}
+	if b.trips > 0 {
+		b.trial = true
+	}
+	b.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	err := send(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", b.timeout, err)
+	}
+	cancel()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+
+	if err == nil {
+		if b.trips > 0 {
+			logger.Info("Alert channel recovered", "channel", b.channel)
+		}
+		b.failures = 0
+		b.trips = 0
+		b.openUntil = time.Time{}
+		return nil
+	}
+
+	b.failures++
+	if b.trips > 0 || b.failures >= breakerThreshold {
+		delay := breakerBaseDelay
+		for i := 0; i < b.trips && delay < breakerMaxDelay; i++ {
+			delay *= 2
+		}
+		if delay > breakerMaxDelay {
+			delay = breakerMaxDelay
+		}
+		b.trips++
+		b.openUntil = time.Now().Add(delay)
+		logger.Warn("Alert channel keeps failing, pausing deliveries", "channel", b.channel, "failures", b.failures, "pause", delay, "err", err)
+	}
+	return err
+}
+
+// Wrap returns send guarded by the breaker
+func (b *Breaker) Wrap(send func(ctx context.Context, subject, body string) error) SendFunc {
+	return func(subject, body string) error {
+		return b.Call(func(ctx context.Context) error { return send(ctx, subject, body) })
+	}
+}
+
+// State returns "closed", "open" or "half-open" (open period over, next
+// delivery is a trial)
+func (b *Breaker) State() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch {
+	case b.trips == 0:
+		return "closed"
+	case time.Now().Before(b.openUntil) || b.trial:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
@@ -0,0 +1,140 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestBreakerOpensAndBacksOff(t *testing.T) {
+	b := NewBreaker("test", time.Second)
+	calls := 0
+	failing := func(context.Context) error {
+		calls++
+		return fmt.Errorf("down")
+	}
+
+	for i := 0; i < breakerThreshold; i++ {
+		b.Call(failing)
+	}
+	if state := b.State(); state != "open" {
+		t.Fatalf("Expected the breaker to open after %d failures, got %s", breakerThreshold, state)
+	}
+
+	if err := b.Call(failing); !errors.Is(err, ErrCircuitOpen) || calls != breakerThreshold {
+		t.Errorf("Expected an open breaker to skip delivery, got %v after %d calls", err, calls)
+	}
+
+	// A failed trial reopens the breaker for twice as long
+	b.openUntil = time.Now()
+	if state := b.State(); state != "half-open" {
+		t.Errorf("Expected half-open once the open period is over, got %s", state)
+	}
+	b.Call(failing)
+	if wait := time.Until(b.openUntil); wait < breakerBaseDelay || wait > 2*breakerBaseDelay {
+		t.Errorf("Expected to back off for %s, got %s", 2*breakerBaseDelay, wait)
+	}
+
+	// A successful trial closes it
+	b.openUntil = time.Now()
+	if err := b.Call(func(context.Context) error { return nil }); err != nil || b.State() != "closed" || b.trips != 0 {
+		t.Errorf("Expected a successful trial to close the breaker, got %v (%s)", err, b.State())
+	}
+}
+
+func TestBreakerTimeout(t *testing.T) {
+	b := NewBreaker("test", 50*time.Millisecond)
+
+	// The send is cancelled rather than left running, so it can't go out
+	// after being counted as failed
+	start := time.Now()
+	err := b.Call(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the send to be cancelled after the timeout, took %s", elapsed)
+	}
+}
+
+func TestMultiAlerterDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan string, 1)
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		received <- r.URL.Path
+	}))
+	defer slack.Close()
+
+	cfg := &config.Config{Slack: config.SlackConfig{Enabled: true, WebhookURL: slack.URL + "/hook"}}
+	m := NewMultiAlerter(cfg, "")
+
+	start := time.Now()
+	if err := m.SendAlert("Pool Degraded", "tank is DEGRADED"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected SendAlert to return at once while Slack hangs, took %s", elapsed)
+	}
+
+	close(release)
+	m.Stop()
+	select {
+	case path := <-received:
+		if path != "/hook" {
+			t.Errorf("Unexpected webhook path %s", path)
+		}
+	default:
+		t.Error("Expected Stop to wait for the queued alert")
+	}
+
+	if err := m.SendAlert("Late", "after stop"); err == nil {
+		t.Error("Expected alerts after Stop to be refused")
+	}
+}
+
+func TestMultiAlerterSlowChannelDoesNotDelayOthers(t *testing.T) {
+	release := make(chan struct{})
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slack.Close()
+
+	received := make(chan string, 2)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+	}))
+	defer hook.Close()
+
+	cfg := &config.Config{
+		Slack:    config.SlackConfig{Enabled: true, WebhookURL: slack.URL},
+		Webhooks: []config.WebhookConfig{{Name: "ops", URL: hook.URL + "/ops"}},
+	}
+	m := NewMultiAlerter(cfg, "")
+	defer m.Stop()
+	defer close(release)
+
+	// Slack hangs on the first alert, holding up only its own queue
+	m.SendAlert("[WARNING] Pool Degraded", "tank is DEGRADED")
+	m.SendAlert("[WARNING] Pool Degraded", "tank is still DEGRADED")
+	for i := 0; i < 2; i++ {
+		select {
+		case path := <-received:
+			if path != "/ops" {
+				t.Errorf("Unexpected webhook path %s", path)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the webhook to receive both alerts while Slack hangs")
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/sla"
+	"zfsrabbit/internal/update"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/version"
+)
+
+// Digest collects alerts below CRITICAL and sync notifications for email and
+// sends them as one summary on a schedule. What it holds is persisted, so a
+// restart doesn't lose a day's worth of alerts.
+type Digest struct {
+	schedule string
+	send     SendFunc
+	path     string
+	mutex    sync.Mutex
+	pending  digestState
+	cron     *cron.Cron
+	now      func() time.Time
+
+	compliance  ComplianceReporter // nil unless SetCompliance is called
+	slaDatasets []string
+	updates     UpdateReporter // nil unless SetUpdates is called
+}
+
+// ComplianceReporter reports a dataset's SLA compliance for a month, like
+// sla.Tracker
+type ComplianceReporter interface {
+	Compliance(dataset string, month time.Time) sla.Compliance
+}
+
+type digestState struct {
+	Since  time.Time     `json:"since"` // When the first held item arrived
+	Alerts []digestAlert `json:"alerts"`
+	Syncs  []digestSync  `json:"syncs"`
+}
+
+// digestAlert is every alert with the same subject
+type digestAlert struct {
+	Subject  string    `json:"subject"`
+	Count    int       `json:"count"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	LastBody string    `json:"last_body"`
+}
+
+// digestSync is every successful sync of a dataset
+type digestSync struct {
+	Dataset      string        `json:"dataset"`
+	Count        int           `json:"count"`
+	Duration     time.Duration `json:"duration"` // Total time spent sending
+	LastSnapshot string        `json:"last_snapshot"`
+	Last         time.Time     `json:"last"`
+}
+
+// NewDigest creates a digest sent through send on the cron schedule
+func NewDigest(schedule string, send SendFunc) *Digest {
+	return &Digest{
+		schedule: schedule,
+		send:     send,
+		cron:     cron.New(),
+		now:      time.Now,
+	}
+}
+
+// SetStore persists the held alerts at path, loading any held before a restart
+func (d *Digest) SetStore(path string) {
+	if path == "" {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.path = path
+	if err := utils.ReadJSONFile(path, &d.pending); err != nil {
+		logger.Error("Failed to load email digest", "path", path, "err", err)
+	}
+}
+
+// AddAlert holds an alert for the next digest
+func (d *Digest) AddAlert(subject, body string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now()
+	d.startLocked(now)
+	for i := range d.pending.Alerts {
+		if a := &d.pending.Alerts[i]; a.Subject == subject {
+			a.Count++
+			a.Last = now
+			a.LastBody = body
+			d.saveLocked()
+			return
+		}
+	}
+	d.pending.Alerts = append(d.pending.Alerts, digestAlert{Subject: subject, Count: 1, First: now, Last: now, LastBody: body})
+	d.saveLocked()
+}
+
+// AddSync counts a successful sync in the next digest
+func (d *Digest) AddSync(snapshot, dataset string, duration time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now()
+	d.startLocked(now)
+	i := 0
+	for i < len(d.pending.Syncs) && d.pending.Syncs[i].Dataset != dataset {
+		i++
+	}
+	if i == len(d.pending.Syncs) {
+		d.pending.Syncs = append(d.pending.Syncs, digestSync{Dataset: dataset})
+	}
+	s := &d.pending.Syncs[i]
+	s.Count++
+	s.Duration += duration
+	s.LastSnapshot = snapshot
+	s.Last = now
+	d.saveLocked()
+}
+
+// UpdateReporter reports whether a newer zfsrabbit release is out, like
+// update.Checker
+type UpdateReporter interface {
+	Status() update.Status
+}
+
+// SetUpdates notes in the digest when a newer release is available, with a
+// link to its release notes
+func (d *Digest) SetUpdates(updates UpdateReporter) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.updates = updates
+}
+
+// SetCompliance adds this month's and last month's SLA compliance for each
+// of datasets to the digest
+func (d *Digest) SetCompliance(report ComplianceReporter, datasets []string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.compliance = report
+	d.slaDatasets = datasets
+}
+
+// Pending returns how many alerts and syncs are waiting for the next digest
+func (d *Digest) Pending() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	total := 0
+	for _, a := range d.pending.Alerts {
+		total += a.Count
+	}
+	for _, s := range d.pending.Syncs {
+		total += s.Count
+	}
+	return total
+}
+
+// Flush sends the digest if anything is held. Held items are kept if it fails.
+func (d *Digest) Flush() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(d.pending.Alerts) == 0 && len(d.pending.Syncs) == 0 {
+		return nil
+	}
+
+	subject, body := d.messageLocked()
+	if err := d.send(subject, body); err != nil {
+		return err
+	}
+	d.pending = digestState{}
+	d.saveLocked()
+	return nil
+}
+
+// Start sends digests on the schedule until Stop is called
+func (d *Digest) Start() error {
+	_, err := d.cron.AddFunc(d.schedule, func() {
+		if err := d.Flush(); err != nil {
+			logger.Error("Failed to send email digest", "err", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	d.cron.Start()
+	return nil
+}
+
+func (d *Digest) Stop() {
+	d.cron.Stop()
+}
+
+func (d *Digest) startLocked(now time.Time) {
+	if d.pending.Since.IsZero() {
+		d.pending.Since = now
+	}
+}
+
+func (d *Digest) messageLocked() (string, string) {
+	alerts := 0
+	for _, a := range d.pending.Alerts {
+		alerts += a.Count
+	}
+	syncs := 0
+	for _, s := range d.pending.Syncs {
+		syncs += s.Count
+	}
+
+	subject := fmt.Sprintf("Digest: %d alerts, %d successful syncs", alerts, syncs)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary of alerts below CRITICAL and sync notifications since %s.\n", display.Time(d.pending.Since))
+	fmt.Fprintf(&b, "Running zfsrabbit %s.\n", version.Get().Short())
+	if d.updates != nil {
+		if status := d.updates.Status(); status.UpdateAvailable {
+			fmt.Fprintf(&b, "zfsrabbit %s is available, release notes: %s\n", status.Latest, status.ReleaseURL)
+		}
+	}
+
+	if len(d.pending.Alerts) > 0 {
+		held := append([]digestAlert(nil), d.pending.Alerts...)
+		sort.SliceStable(held, func(i, j int) bool { return held[i].First.Before(held[j].First) })
+
+		fmt.Fprintf(&b, "\nAlerts (%d):\n", alerts)
+		for _, a := range held {
+			fmt.Fprintf(&b, "%dx %s (first %s, last %s)\n", a.Count, a.Subject,
+				display.ShortTime(a.First), display.ShortTime(a.Last))
+		}
+	}
+
+	if len(d.pending.Syncs) > 0 {
+		fmt.Fprintf(&b, "\nSuccessful syncs (%d):\n", syncs)
+		for _, s := range d.pending.Syncs {
+			fmt.Fprintf(&b, "%s: %d, last %s at %s, %s sending in total\n", s.Dataset, s.Count,
+				s.LastSnapshot, display.ShortTime(s.Last), s.Duration.Round(time.Second))
+		}
+	}
+
+	if d.compliance != nil && len(d.slaDatasets) > 0 {
+		now := d.now()
+		lastMonth := now.AddDate(0, 0, -now.Day())
+		b.WriteString("\nSLA compliance:\n")
+		for _, dataset := range d.slaDatasets {
+			fmt.Fprintf(&b, "%s: %s, %s\n", dataset,
+				complianceText(d.compliance.Compliance(dataset, now)),
+				complianceText(d.compliance.Compliance(dataset, lastMonth)))
+		}
+	}
+
+	if len(d.pending.Alerts) > 0 {
+		b.WriteString("\nMost recent message for each alert:\n")
+		for _, a := range d.pending.Alerts {
+			fmt.Fprintf(&b, "\n--- %s ---\n%s\n", a.Subject, a.LastBody)
+		}
+	}
+
+	return subject, b.String()
+}
+
+// complianceText describes a month's compliance, e.g. "96.7% in 2024-07
+// (29 of 30 days)"
+func complianceText(c sla.Compliance) string {
+	if c.Days == 0 {
+		return fmt.Sprintf("no completed days in %s", c.Month)
+	}
+	return fmt.Sprintf("%.1f%% in %s (%d of %d days)", c.Percent, c.Month, c.Met, c.Days)
+}
+
+func (d *Digest) saveLocked() {
+	if d.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(d.path, d.pending, 0600); err != nil {
+		logger.Error("Failed to save email digest", "path", d.path, "err", err)
+	}
+}
@@ -0,0 +1,221 @@
+package alert
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/sla"
+	"zfsrabbit/internal/update"
+	"zfsrabbit/internal/version"
+)
+
+func TestDigestBatchesAlertsAndSyncs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "email_digest.json")
+
+	type email struct{ subject, body string }
+	var sent []email
+	fail := false
+	send := func(subject, body string) error {
+		if fail {
+			return errors.New("smtp down")
+		}
+		sent = append(sent, email{subject, body})
+		return nil
+	}
+
+	d := NewDigest("0 * * * *", send)
+	d.SetStore(path)
+	if err := d.Flush(); err != nil || len(sent) != 0 {
+		t.Fatalf("Expected nothing sent for an empty digest, got %v, %v", sent, err)
+	}
+
+	d.AddAlert("[WARNING] ZFS Pool Capacity Alert: tank", "Capacity: 81%")
+	d.AddAlert("[WARNING] ZFS Pool Capacity Alert: tank", "Capacity: 82%")
+	d.AddSync("autosnap_1", "tank/vms", time.Minute)
+	d.AddSync("autosnap_2", "tank/vms", time.Minute)
+	if d.Pending() != 4 {
+		t.Errorf("Expected 4 pending, got %d", d.Pending())
+	}
+
+	// Reloaded from disk, as after a restart
+	d = NewDigest("0 * * * *", send)
+	d.SetStore(path)
+	if d.Pending() != 4 {
+		t.Fatalf("Expected the digest to survive a restart, got %d pending", d.Pending())
+	}
+
+	fail = true
+	if err := d.Flush(); err == nil || d.Pending() != 4 {
+		t.Fatalf("Expected a failed digest to be kept, got %v with %d pending", err, d.Pending())
+	}
+
+	fail = false
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || d.Pending() != 0 {
+		t.Fatalf("Expected one digest and nothing pending, got %d sent and %d pending", len(sent), d.Pending())
+	}
+	if sent[0].subject != "Digest: 2 alerts, 2 successful syncs" {
+		t.Errorf("Unexpected subject %q", sent[0].subject)
+	}
+	for _, want := range []string{"2x [WARNING] ZFS Pool Capacity Alert: tank", "tank/vms: 2, last autosnap_2", "Capacity: 82%"} {
+		if !strings.Contains(sent[0].body, want) {
+			t.Errorf("Expected digest to contain %q:\n%s", want, sent[0].body)
+		}
+	}
+	if strings.Contains(sent[0].body, "Capacity: 81%") {
+		t.Errorf("Expected only the most recent message of each alert:\n%s", sent[0].body)
+	}
+}
+
+func TestMultiAlerterDigestHoldsNonCritical(t *testing.T) {
+	cfg := &config.Config{
+		Email: config.EmailConfig{
+			SMTPHost: "localhost",
+			SMTPPort: 1,
+			ToEmails: []string{"admin@example.com"},
+			Digest:   config.EmailDigestConfig{Enabled: true, Cron: "0 * * * *"},
+		},
+	}
+
+	m := NewMultiAlerter(cfg, "")
+	defer m.Stop()
+	history := NewHistory("")
+	m.SetHistory(history)
+
+	if err := m.SendAlert("[WARNING] ZFS Pool Capacity Alert: tank", "Capacity: 81%"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SendSyncSuccess("autosnap_1", "tank/vms", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SendAlert("[CRITICAL] HDD Health Alert: sda", "Device: /dev/sda\n"); err != nil {
+		t.Fatal(err)
+	}
+	waitForQueue(t, m)
+
+	if m.HeldAlerts() != 2 {
+		t.Errorf("Expected the warning and sync success held for the digest, got %d", m.HeldAlerts())
+	}
+	entries := history.List(HistoryFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 history entries, got %+v", entries)
+	}
+	if entries[1].Channels[ChannelEmail] != ResultHeld {
+		t.Errorf("Expected the warning held, got %v", entries[1].Channels)
+	}
+	if entries[0].Channels[ChannelEmail] == ResultHeld {
+		t.Errorf("Expected the critical alert emailed immediately, got %v", entries[0].Channels)
+	}
+}
+
+func TestMultiAlerterDigestSkipsPoolsAndSyncFailures(t *testing.T) {
+	cfg := &config.Config{
+		Email: config.EmailConfig{
+			SMTPHost: "localhost",
+			SMTPPort: 1,
+			ToEmails: []string{"admin@example.com"},
+			Digest:   config.EmailDigestConfig{Enabled: true, Cron: "0 * * * *"},
+		},
+	}
+
+	m := NewMultiAlerter(cfg, "")
+	defer m.Stop()
+	history := NewHistory("")
+	m.SetHistory(history)
+
+	// As the monitor raises a DEGRADED pool
+	if err := m.SendAlert("[CRITICAL] ZFS Pool Alert: tank", "Pool: tank\nState: DEGRADED\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SendSyncFailure("autosnap_1", "tank/vms", errors.New("connection refused")); err != nil {
+		t.Fatal(err)
+	}
+	waitForQueue(t, m)
+
+	if m.HeldAlerts() != 0 {
+		t.Errorf("Expected nothing held for the digest, got %d", m.HeldAlerts())
+	}
+	entries := history.List(HistoryFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 history entries, got %+v", entries)
+	}
+	for _, entry := range entries {
+		if entry.Channels[ChannelEmail] == ResultHeld {
+			t.Errorf("Expected %q emailed immediately, got %v", entry.Subject, entry.Channels)
+		}
+	}
+}
+
+type fakeCompliance map[string]sla.Compliance
+
+func (f fakeCompliance) Compliance(dataset string, month time.Time) sla.Compliance {
+	key := dataset + " " + month.Format("2006-01")
+	if c, ok := f[key]; ok {
+		return c
+	}
+	return sla.Compliance{Month: month.Format("2006-01")}
+}
+
+func TestDigestReportsSLACompliance(t *testing.T) {
+	var body string
+	d := NewDigest("0 * * * *", func(subject, b string) error {
+		body = b
+		return nil
+	})
+	d.now = func() time.Time { return time.Date(2024, 7, 16, 9, 0, 0, 0, time.UTC) }
+	d.SetCompliance(fakeCompliance{
+		"tank/data 2024-07": {Month: "2024-07", Days: 15, Met: 14, Percent: 14 * 100.0 / 15},
+		"tank/data 2024-06": {Month: "2024-06", Days: 30, Met: 30, Percent: 100},
+	}, []string{"tank/data", "tank/vms"})
+
+	d.AddSync("autosnap_1", "tank/data", time.Minute)
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"SLA compliance:\n",
+		"tank/data: 93.3% in 2024-07 (14 of 15 days), 100.0% in 2024-06 (30 of 30 days)\n",
+		"tank/vms: no completed days in 2024-07, no completed days in 2024-06\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the digest to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+type fakeUpdates update.Status
+
+func (f fakeUpdates) Status() update.Status {
+	return update.Status(f)
+}
+
+func TestDigestReportsVersion(t *testing.T) {
+	var body string
+	d := NewDigest("0 * * * *", func(subject, b string) error {
+		body = b
+		return nil
+	})
+
+	d.AddSync("autosnap_1", "tank/data", time.Minute)
+	d.Flush()
+	if want := "Running zfsrabbit " + version.Get().Short() + ".\n"; !strings.Contains(body, want) {
+		t.Errorf("Expected the digest to contain %q, got:\n%s", want, body)
+	}
+	if strings.Contains(body, "is available") {
+		t.Errorf("Expected no update notice without an update checker, got:\n%s", body)
+	}
+
+	d.SetUpdates(fakeUpdates{Current: "1.2.0", Latest: "1.3.0", UpdateAvailable: true, ReleaseURL: "https://example.com/releases/1.3.0"})
+	d.AddSync("autosnap_2", "tank/data", time.Minute)
+	d.Flush()
+	if want := "zfsrabbit 1.3.0 is available, release notes: https://example.com/releases/1.3.0\n"; !strings.Contains(body, want) {
+		t.Errorf("Expected the digest to contain %q, got:\n%s", want, body)
+	}
+}
@@ -1,26 +1,50 @@
 package alert
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
+	"html/template"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 	"time"
 
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/emailtemplate"
+	"zfsrabbit/internal/version"
 )
 
 type EmailAlerter struct {
-	config *config.EmailConfig
+	config   *config.EmailConfig
+	template *template.Template // nil unless email.html is on
 }
 
 func NewEmailAlerter(cfg *config.EmailConfig) *EmailAlerter {
-	return &EmailAlerter{
+	e := &EmailAlerter{
 		config: cfg,
 	}
+	if cfg.HTML {
+		tmpl, err := emailtemplate.Load(cfg.TemplateDir)
+		if err != nil {
+			logger.Warn("Failed to load email templates, sending plain text", "err", err)
+		} else {
+			e.template = tmpl
+		}
+	}
+	return e
+}
+
+// Enabled reports whether email delivery is configured
+func (e *EmailAlerter) Enabled() bool {
+	return e.config.SMTPHost != "" && len(e.config.ToEmails) > 0
 }
 
-func (e *EmailAlerter) SendAlert(subject, body string) error {
+func (e *EmailAlerter) SendAlert(ctx context.Context, subject, body string) error {
 	if e.config.SMTPHost == "" || len(e.config.ToEmails) == 0 {
 		return fmt.Errorf("email configuration incomplete")
 	}
@@ -31,23 +55,31 @@ func (e *EmailAlerter) SendAlert(subject, body string) error {
 
 	addr := fmt.Sprintf("%s:%d", e.config.SMTPHost, e.config.SMTPPort)
 
-	if e.config.UseTLS {
-		return e.sendTLS(addr, auth, msg)
-	}
-
-	return smtp.SendMail(addr, auth, e.config.FromEmail, e.config.ToEmails, []byte(msg))
+	return e.send(ctx, addr, auth, msg)
 }
 
-func (e *EmailAlerter) sendTLS(addr string, auth smtp.Auth, msg string) error {
+// send delivers msg over an implicit TLS connection if use_tls is on, or
+// with STARTTLS if the server offers it, like smtp.SendMail. The connection
+// is closed if ctx ends first.
+func (e *EmailAlerter) send(ctx context.Context, addr string, auth smtp.Auth, msg string) error {
 	tlsConfig := &tls.Config{
 		ServerName: e.config.SMTPHost,
 	}
 
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	if e.config.UseTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
 
 	client, err := smtp.NewClient(conn, e.config.SMTPHost)
 	if err != nil {
@@ -55,8 +87,18 @@ func (e *EmailAlerter) sendTLS(addr string, auth smtp.Auth, msg string) error {
 	}
 	defer client.Close()
 
-	if err = client.Auth(auth); err != nil {
-		return err
+	if !e.config.UseTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ok, _ := client.Extension("AUTH"); ok || e.config.UseTLS {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
 	}
 
 	if err = client.Mail(e.config.FromEmail); err != nil {
@@ -95,6 +137,16 @@ func (e *EmailAlerter) buildMessage(subject, body string) string {
 	headers["Date"] = time.Now().Format(time.RFC822)
 	headers["MIME-Version"] = "1.0"
 	headers["Content-Type"] = "text/plain; charset=utf-8"
+	headers["X-Mailer"] = version.UserAgent()
+
+	if e.template != nil {
+		if contentType, multipartBody, err := e.buildHTML(subject, body); err != nil {
+			logger.Warn("Failed to render HTML email, sending plain text", "err", err)
+		} else {
+			headers["Content-Type"] = contentType
+			body = multipartBody
+		}
+	}
 
 	message := ""
 	for k, v := range headers {
@@ -105,6 +157,41 @@ func (e *EmailAlerter) buildMessage(subject, body string) string {
 	return message
 }
 
+// buildHTML renders an alert as a multipart/alternative body with the plain
+// text first and the HTML last, returning its content type and the body
+func (e *EmailAlerter) buildHTML(subject, body string) (string, string, error) {
+	severity, _ := splitSeverity(subject)
+	html, err := emailtemplate.Render(e.template, subject, severity, body)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	text, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return "", "", err
+	}
+	text.Write([]byte(body))
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(html))
+	qp.Close()
+
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
+	return "multipart/alternative; boundary=" + writer.Boundary(), buf.String(), nil
+}
+
 func (e *EmailAlerter) TestConnection() error {
-	return e.SendAlert("Test Alert", "This is a test email from ZFSRabbit to verify email configuration.")
+	return e.SendAlert(context.Background(), "Test Alert", "This is a test email from ZFSRabbit to verify email configuration.")
 }
@@ -1,6 +1,10 @@
 package alert
 
 import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
 	"strings"
 	"testing"
 
@@ -71,6 +75,60 @@ func TestEmailAlerterBuildMessage(t *testing.T) {
 	}
 }
 
+func TestEmailAlerterBuildHTMLMessage(t *testing.T) {
+	cfg := &config.EmailConfig{
+		FromEmail: "alerts@test.com",
+		ToEmails:  []string{"admin@test.com"},
+		HTML:      true,
+	}
+
+	alerter := NewEmailAlerter(cfg)
+	if alerter.template == nil {
+		t.Fatal("Expected the built-in template to load")
+	}
+
+	body := "Device: /dev/sda\nTemperature: 75°C\n"
+	message := alerter.buildMessage("[CRITICAL] HDD Health Alert: sda", body)
+
+	headers, content, _ := strings.Cut(message, "\r\n\r\n")
+	_, params, err := mime.ParseMediaType(strings.TrimPrefix(headerLine(headers, "Content-Type"), "Content-Type: "))
+	if err != nil || !strings.Contains(headers, "Content-Type: multipart/alternative;") {
+		t.Fatalf("Expected a multipart/alternative message, got headers:\n%s", headers)
+	}
+
+	reader := multipart.NewReader(strings.NewReader(content), params["boundary"])
+	var types []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(part) // Quoted-printable is decoded by NextPart
+		types = append(types, part.Header.Get("Content-Type"))
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") && string(data) != body {
+			t.Errorf("Expected the plain text body, got %q", data)
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") && !strings.Contains(string(data), "75°C") {
+			t.Errorf("Expected the HTML body to contain the alert, got %s", data)
+		}
+	}
+	if len(types) != 2 || types[0] != "text/plain; charset=utf-8" || types[1] != "text/html; charset=utf-8" {
+		t.Errorf("Expected plain text then HTML parts, got %v", types)
+	}
+}
+
+func headerLine(headers, name string) string {
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, name+": ") {
+			return line
+		}
+	}
+	return ""
+}
+
 func TestEmailAlerterSendAlert(t *testing.T) {
 	cfg := &config.EmailConfig{
 		SMTPHost:  "", // Empty host will cause validation error
@@ -82,7 +140,7 @@ func TestEmailAlerterSendAlert(t *testing.T) {
 
 	alerter := NewEmailAlerter(cfg)
 
-	err := alerter.SendAlert("Test", "Test message")
+	err := alerter.SendAlert(context.Background(), "Test", "Test message")
 
 	// Should fail due to incomplete configuration
 	if err == nil {
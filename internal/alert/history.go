@@ -0,0 +1,246 @@
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+)
+
+// historyMaxEntries caps the alert history between compactions
+const historyMaxEntries = 5000
+
+// Outcomes of delivering an alert over one channel
+const (
+	ResultSent   = "sent"
+	ResultQueued = "queued" // Failed or behind a backlog, retried from the outbox
+	ResultHeld   = "held"   // Held for an email digest or a threaded Slack batch
+	ResultFailed = "failed"
+)
+
+// HistoryEntry is an alert that was sent, and what became of it on each
+// channel it was routed to
+type HistoryEntry struct {
+	ID        string            `json:"id"`
+	Raised    time.Time         `json:"raised"`    // When SendAlert was called
+	Delivered time.Time         `json:"delivered"` // When every channel had been tried
+	Subject   string            `json:"subject"`
+	Severity  string            `json:"severity"`
+	Type      string            `json:"type"`     // As used by alert routes
+	Channels  map[string]string `json:"channels"` // Outcome per channel
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// HistoryFilter picks entries from the alert history. Empty fields match
+// everything.
+type HistoryFilter struct {
+	MinSeverity string
+	Type        string
+	Channel     string
+	Query       string // Case-insensitive substring of the subject
+	Since       time.Time
+	Limit       int // Newest entries returned, 0 for all
+}
+
+// History persists the alerts sent, newest last
+type History struct {
+	path    string
+	mutex   sync.Mutex
+	entries []HistoryEntry
+}
+
+// NewHistory loads the history at path; an empty path keeps it in memory only
+func NewHistory(path string) *History {
+	h := &History{path: path}
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &h.entries); err != nil {
+			logger.Error("Failed to load alert history", "path", path, "err", err)
+		}
+	}
+	return h
+}
+
+// Add records an alert, dropping the oldest past historyMaxEntries
+func (h *History) Add(entry HistoryEntry) {
+	if entry.ID == "" {
+		buf := make([]byte, 6)
+		rand.Read(buf)
+		entry.ID = "alr_" + hex.EncodeToString(buf)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > historyMaxEntries {
+		h.entries = h.entries[len(h.entries)-historyMaxEntries:]
+	}
+	h.saveLocked()
+}
+
+// List returns the entries matching filter, newest first
+func (h *History) List(filter HistoryFilter) []HistoryEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var types []string
+	if filter.Type != "" {
+		types = []string{filter.Type}
+	}
+	match := config.AlertRoute{MinSeverity: filter.MinSeverity, Types: types}
+	query := strings.ToLower(filter.Query)
+
+	entries := []HistoryEntry{}
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		entry := h.entries[i]
+		if entry.Raised.Before(filter.Since) || !match.Matches(entry.Type, entry.Severity) {
+			continue
+		}
+		if _, ok := entry.Channels[filter.Channel]; filter.Channel != "" && !ok {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(entry.Subject), query) {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries
+}
+
+// Compact drops alerts raised before before, then the oldest past maxRows
+func (h *History) Compact(before time.Time, maxRows int) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var kept []HistoryEntry
+	for _, entry := range h.entries {
+		if before.IsZero() || !entry.Raised.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	if maxRows > 0 && len(kept) > maxRows {
+		kept = kept[len(kept)-maxRows:]
+	}
+
+	removed := len(h.entries) - len(kept)
+	if removed > 0 {
+		h.entries = kept
+		h.saveLocked()
+	}
+	return removed
+}
+
+func (h *History) saveLocked() {
+	if h.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(h.path, h.entries, 0600); err != nil {
+		logger.Error("Failed to save alert history", "path", h.path, "err", err)
+	}
+}
+
+// results collects the outcome on each channel of delivering one alert,
+// which the channels' workers note as they finish. A nil results records
+// nothing.
+type results struct {
+	mutex    sync.Mutex
+	channels map[string]string
+	errors   map[string]string
+	pending  int    // Deliveries started but not finished
+	done     func() // Set by whenDone, called once pending reaches zero
+}
+
+func newResults() *results {
+	return &results{channels: make(map[string]string), errors: make(map[string]string)}
+}
+
+// note records result for channel, or a failure if err is set
+func (r *results) note(channel, result string, err error) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err != nil {
+		r.channels[channel] = ResultFailed
+		r.errors[channel] = err.Error()
+		return
+	}
+	r.channels[channel] = result
+}
+
+// start notes a delivery handed to a channel's worker, which calls finish
+func (r *results) start() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	r.pending++
+	r.mutex.Unlock()
+}
+
+func (r *results) finish() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	r.pending--
+	done := r.done
+	if r.pending > 0 {
+		done = nil
+	}
+	r.mutex.Unlock()
+
+	if done != nil {
+		done()
+	}
+}
+
+// whenDone calls done once every delivery started has finished, straight
+// away if none are still running
+func (r *results) whenDone(done func()) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	r.done = done
+	pending := r.pending
+	r.mutex.Unlock()
+
+	if pending == 0 {
+		done()
+	}
+}
+
+// SetHistory records every alert and sync failure sent in history
+func (m *MultiAlerter) SetHistory(history *History) {
+	m.history = history
+}
+
+// recordHistory adds an alert raised at raised, delivered with res
+func (m *MultiAlerter) recordHistory(raised time.Time, subject string, res *results) {
+	if m.history == nil {
+		return
+	}
+	kind, severity := classify(subject)
+	entry := HistoryEntry{
+		Raised:    raised,
+		Delivered: time.Now(),
+		Subject:   subject,
+		Severity:  severity,
+		Type:      kind,
+		Channels:  res.channels,
+	}
+	if len(res.errors) > 0 {
+		entry.Errors = res.errors
+	}
+	m.history.Add(entry)
+}
@@ -0,0 +1,110 @@
+package alert
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestMultiAlerterRecordsHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Slack: config.SlackConfig{Enabled: true, AlertOnSync: true, WebhookURL: server.URL + "/slack"},
+		Webhooks: []config.WebhookConfig{
+			{Name: "down", URL: server.URL + "/down"},
+		},
+	}
+	m := NewMultiAlerter(cfg, "")
+	defer m.Stop()
+	history := NewHistory("")
+	m.SetHistory(history)
+
+	if err := m.SendAlert("[CRITICAL] HDD Health Alert: sda", "Device: /dev/sda\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SendSyncSuccess("autosnap_1", "tank/vms", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SendSyncFailure("autosnap_2", "tank/vms", errors.New("connection refused")); err != nil {
+		t.Fatal(err)
+	}
+	waitForQueue(t, m)
+
+	entries := history.List(HistoryFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("Expected the alert and sync failure in the history, got %+v", entries)
+	}
+
+	sync, disk := entries[0], entries[1]
+	if sync.Subject != "[WARNING] ZFS Sync Failed" || sync.Type != "sync" || sync.Severity != "WARNING" {
+		t.Errorf("Unexpected sync failure entry: %+v", sync)
+	}
+	if disk.Type != "disk" || disk.Severity != "CRITICAL" || disk.ID == "" || disk.Delivered.Before(disk.Raised) {
+		t.Errorf("Unexpected disk entry: %+v", disk)
+	}
+	if disk.Channels[ChannelSlack] != ResultSent || disk.Channels["webhook:down"] != ResultQueued {
+		t.Errorf("Expected Slack sent and the failing webhook queued, got %v", disk.Channels)
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alert_history.json")
+	now := time.Now()
+
+	h := NewHistory(path)
+	h.Add(HistoryEntry{Raised: now.Add(-48 * time.Hour), Subject: "ZFS Pool Alert: tank", Severity: "EMERGENCY", Type: "pool", Channels: map[string]string{"sms": ResultSent}})
+	h.Add(HistoryEntry{Raised: now.Add(-time.Hour), Subject: "[WARNING] ZFS Pool Capacity Alert: tank", Severity: "WARNING", Type: "capacity", Channels: map[string]string{"email": ResultHeld}})
+	h.Add(HistoryEntry{Raised: now, Subject: "[CRITICAL] HDD Health Alert: sda", Severity: "CRITICAL", Type: "disk", Channels: map[string]string{"email": ResultSent}})
+
+	// Reloaded from disk, as after a restart
+	h = NewHistory(path)
+
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   []string
+	}{
+		{"all, newest first", HistoryFilter{}, []string{"disk", "capacity", "pool"}},
+		{"min severity", HistoryFilter{MinSeverity: "critical"}, []string{"disk", "pool"}},
+		{"type", HistoryFilter{Type: "capacity"}, []string{"capacity"}},
+		{"channel", HistoryFilter{Channel: "email"}, []string{"disk", "capacity"}},
+		{"subject", HistoryFilter{Query: "TANK"}, []string{"capacity", "pool"}},
+		{"since", HistoryFilter{Since: now.Add(-24 * time.Hour)}, []string{"disk", "capacity"}},
+		{"limit", HistoryFilter{Limit: 1}, []string{"disk"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range h.List(tt.filter) {
+				got = append(got, entry.Type)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	if removed := h.Compact(now.Add(-24*time.Hour), 1); removed != 2 {
+		t.Errorf("Expected compaction to remove 2 entries, removed %d", removed)
+	}
+	if entries := NewHistory(path).List(HistoryFilter{}); len(entries) != 1 || entries[0].Type != "disk" {
+		t.Errorf("Expected only the newest entry after compaction, got %+v", entries)
+	}
+}
@@ -1,35 +1,311 @@
 package alert
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"zfsrabbit/internal/audit"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/logging"
 )
 
+var logger = logging.For("alert")
+
+const (
+	ChannelEmail    = "email"
+	ChannelSlack    = "slack"
+	ChannelSMS      = "sms"
+	ChannelSNMP     = "snmp"
+	ChannelSyslog   = "syslog"
+	ChannelTelegram = "telegram"
+	ChannelTeams    = "teams"
+	ChannelPush     = "push"
+)
+
+const (
+	// dispatchQueueSize bounds the alerts waiting to be dispatched, and the
+	// deliveries waiting on each channel; callers never wait for a slot, an
+	// alert is dropped instead
+	dispatchQueueSize = 1000
+	// drainTimeout bounds how long Stop waits for queued alerts to go out
+	drainTimeout = 10 * time.Second
+)
+
+// dispatchJob is an alert waiting in the dispatch queue, or a delivery
+// waiting on a channel's worker
+type dispatchJob struct {
+	name    string
+	deliver func() error
+	res     *results // Told when a channel's delivery finishes
+}
+
 type MultiAlerter struct {
-	email *EmailAlerter
-	slack *SlackAlerter
+	email        *EmailAlerter
+	slack        *SlackAlerter
+	snmp         *SNMPAlerter
+	syslog       *SyslogAlerter
+	sms          *SMSAlerter
+	telegram     *TelegramAlerter
+	teams        *TeamsAlerter
+	push         *PushAlerter
+	webhooks     []*WebhookAlerter
+	threads      *SlackThreads // nil unless slack.thread_incidents is on
+	outbox       *Outbox
+	emailLimiter *RateLimiter
+	digest       *Digest // nil unless email.digest is on
+	breakers     map[string]*Breaker
+	owners       []*owner // Per-dataset recipients from the owners section
+	routes       router
+	history      *History // nil unless SetHistory is called
+	slaDatasets  []string // Datasets with an SLA, for the email digest
+
+	queue       chan dispatchJob
+	queueMutex  sync.Mutex
+	stopped     bool
+	dispatching chan struct{}               // Closed once the dispatch queue and every channel's are drained
+	workers     map[string]chan dispatchJob // Each channel's deliveries, only touched by the dispatch goroutine
+	working     sync.WaitGroup
+	queued      atomic.Int64 // Deliveries waiting on the workers
+}
+
+// NewMultiAlerter creates an alerter fanning out to email, Slack, Telegram,
+// Teams, push notifications, SNMP, syslog and webhooks, escalating EMERGENCY
+// alerts to SMS.
+// Email, Slack, Telegram, Teams, push, SMS and webhook alerts that fail to deliver are queued in an outbox
+// persisted at outboxPath (in memory only if empty) and retried until the
+// channel recovers. SNMP traps and syslog messages are sent directly.
+// Alerts are delivered in the background so a slow or failing channel never
+// holds up the caller. Each channel has its own worker sending its alerts in
+// order, so it never holds up the others either, and is guarded by a Breaker
+// that cancels a send taking too long and stops trying the channel for a
+// while once it keeps failing.
+// Alerts about a dataset listed under owners go to its owners' email and
+// Slack, and to the global email and Slack only if CRITICAL or worse.
+// alert_routes then narrow down which alerts each global channel receives.
+func NewMultiAlerter(cfg *config.Config, outboxPath string) *MultiAlerter {
+	emailCfg := &cfg.Email
+
+	m := &MultiAlerter{
+		email:       NewEmailAlerter(emailCfg),
+		slack:       NewSlackAlerter(&cfg.Slack),
+		snmp:        NewSNMPAlerter(&cfg.SNMP),
+		syslog:      NewSyslogAlerter(&cfg.Syslog),
+		sms:         NewSMSAlerter(&cfg.SMS),
+		telegram:    NewTelegramAlerter(&cfg.Telegram),
+		teams:       NewTeamsAlerter(&cfg.Teams),
+		push:        NewPushAlerter(&cfg.Push),
+		outbox:      NewOutbox(outboxPath),
+		breakers:    make(map[string]*Breaker),
+		routes:      newRouter(cfg.Routes),
+		queue:       make(chan dispatchJob, dispatchQueueSize),
+		dispatching: make(chan struct{}),
+		workers:     make(map[string]chan dispatchJob),
+	}
+	for _, channel := range []string{ChannelEmail, ChannelSlack, ChannelSMS, ChannelSNMP, ChannelSyslog, ChannelTelegram, ChannelTeams, ChannelPush} {
+		m.breakers[channel] = NewBreaker(channel, sendTimeout)
+	}
+
+	m.outbox.Register(ChannelEmail, m.breakers[ChannelEmail].Wrap(m.email.SendAlert))
+	m.outbox.Register(ChannelSlack, m.breakers[ChannelSlack].Wrap(m.slack.SendAlert))
+	// Alerts queued before each recipient had their own queue page everyone on duty
+	m.outbox.Register(ChannelSMS, m.breakers[ChannelSMS].Wrap(m.sms.SendAlert))
+	for _, recipient := range cfg.SMS.Recipients {
+		m.outbox.Register(smsChannel(recipient), m.breakers[ChannelSMS].Wrap(func(ctx context.Context, subject, body string) error {
+			return m.sms.SendTo(ctx, recipient, subject, body)
+		}))
+	}
+	m.outbox.Register(ChannelTelegram, m.breakers[ChannelTelegram].Wrap(m.telegram.SendAlert))
+	m.outbox.Register(ChannelTeams, m.breakers[ChannelTeams].Wrap(m.teams.SendAlert))
+	m.outbox.Register(ChannelPush, m.breakers[ChannelPush].Wrap(m.push.SendAlert))
+
+	for _, webhookCfg := range cfg.Webhooks {
+		webhook, err := NewWebhookAlerter(webhookCfg)
+		if err != nil {
+			logger.Warn("Skipping webhook", "err", err)
+			continue
+		}
+		m.webhooks = append(m.webhooks, webhook)
+		m.breakers[webhook.Channel()] = NewBreaker(webhook.Channel(), sendTimeout)
+		m.outbox.Register(webhook.Channel(), m.breakers[webhook.Channel()].Wrap(webhook.SendAlert))
+	}
+
+	if m.slack.Enabled() && cfg.Slack.ThreadIncidents {
+		m.threads = NewSlackThreads(&cfg.Slack, m.slack)
+	}
+
+	m.emailLimiter = NewRateLimiter(emailCfg.MaxPerHour, emailCfg.MaxPerSubjectPerHour, func(subject, body string) error {
+		return m.outbox.Deliver(ChannelEmail, subject, body)
+	})
+	if m.email.Enabled() && emailCfg.Digest.Enabled {
+		m.digest = NewDigest(emailCfg.Digest.Cron, func(subject, body string) error {
+			return m.outbox.Deliver(ChannelEmail, subject, body)
+		})
+	}
+	m.addOwners(cfg)
+	for _, slaCfg := range cfg.SLAs {
+		m.slaDatasets = append(m.slaDatasets, slaCfg.DatasetOr(cfg.ZFS.Dataset))
+	}
+
+	go m.dispatch()
+	return m
 }
 
-func NewMultiAlerter(emailCfg *config.EmailConfig, slackCfg *config.SlackConfig) *MultiAlerter {
-	return &MultiAlerter{
-		email: NewEmailAlerter(emailCfg),
-		slack: NewSlackAlerter(slackCfg),
+// enqueue hands a delivery to the dispatch goroutine without waiting for it
+func (m *MultiAlerter) enqueue(name string, deliver func() error) error {
+	m.queueMutex.Lock()
+	defer m.queueMutex.Unlock()
+
+	if m.stopped {
+		return fmt.Errorf("alerter stopped, dropping %s", name)
+	}
+	select {
+	case m.queue <- dispatchJob{name: name, deliver: deliver}:
+		return nil
+	default:
+		return fmt.Errorf("alert queue full, dropping %s", name)
 	}
 }
 
+// dispatch works out where each queued alert goes, in order, and hands its
+// delivery on each channel to that channel's worker
+func (m *MultiAlerter) dispatch() {
+	defer close(m.dispatching)
+	for job := range m.queue {
+		if err := job.deliver(); err != nil {
+			logger.Error("Failed to deliver alert", "alert", job.name, "err", err)
+		}
+		// Threaded Slack alerts held for a batch go out once the queue empties
+		if m.threads != nil && len(m.queue) == 0 {
+			if err := m.toChannel(nil, ChannelSlack, "threaded Slack alerts", m.flushThreads); err != nil {
+				logger.Error("Failed to deliver threaded Slack alerts", "err", err)
+			}
+		}
+	}
+
+	for _, queue := range m.workers {
+		close(queue)
+	}
+	m.working.Wait()
+}
+
+// toChannel queues deliver on channel's worker, starting it the first time
+// the channel is used, and tells res when it has run. Only the dispatch
+// goroutine calls it.
+func (m *MultiAlerter) toChannel(res *results, channel, name string, deliver func() error) error {
+	queue, ok := m.workers[channel]
+	if !ok {
+		queue = make(chan dispatchJob, dispatchQueueSize)
+		m.workers[channel] = queue
+		m.working.Add(1)
+		go m.work(channel, queue)
+	}
+
+	res.start()
+	m.queued.Add(1)
+	select {
+	case queue <- dispatchJob{name: name, deliver: deliver, res: res}:
+		return nil
+	default:
+		m.queued.Add(-1)
+		res.finish()
+		return fmt.Errorf("%s queue full, dropping %s", channel, name)
+	}
+}
+
+// work delivers a channel's alerts one at a time until its queue is closed
+func (m *MultiAlerter) work(channel string, queue <-chan dispatchJob) {
+	defer m.working.Done()
+	for job := range queue {
+		if err := job.deliver(); err != nil {
+			logger.Error("Failed to deliver alert", "alert", job.name, "channel", channel, "err", err)
+		}
+		m.queued.Add(-1)
+		job.res.finish()
+	}
+}
+
+// SetIncidentStore keeps the open Slack incident in store so its thread is
+// carried on after a restart
+func (m *MultiAlerter) SetIncidentStore(store IncidentStore) {
+	if m.threads != nil {
+		m.threads.SetStore(store)
+	}
+}
+
+// SendAlert queues an alert for every enabled channel. Delivery errors are
+// logged, not returned.
 func (m *MultiAlerter) SendAlert(subject, body string) error {
-	var errs []error
+	raised := time.Now()
+	return m.enqueue(fmt.Sprintf("alert %q", subject), func() error {
+		return m.sendAlert(raised, subject, body)
+	})
+}
+
+func (m *MultiAlerter) sendAlert(raised time.Time, subject, body string) error {
+	res := newResults()
+	defer res.whenDone(func() { m.recordHistory(raised, subject, res) })
+
+	owners := m.ownersOf(bodyField(body, "Dataset:"))
+	global := len(owners) == 0 || isCritical(subject)
+	errs := m.sendToOwners(res, owners, subject, body)
+
+	if global && m.email.Enabled() && m.routed(ChannelEmail, subject) {
+		if err := m.deliverEmail(res, subject, body); err != nil {
+			errs = append(errs, fmt.Errorf("email alert failed: %w", err))
+		}
+	}
+
+	if global && m.slack.Enabled() && m.routed(ChannelSlack, subject) {
+		if err := m.deliverSlack(res, subject, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("slack alert failed: %w", err))
+		}
+	}
+
+	if global && m.telegram.Enabled() && m.routed(ChannelTelegram, subject) {
+		if err := m.deliver(res, ChannelTelegram, subject, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("telegram alert failed: %w", err))
+		}
+	}
+
+	if global && m.teams.Enabled() && m.routed(ChannelTeams, subject) {
+		if err := m.deliver(res, ChannelTeams, subject, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("teams alert failed: %w", err))
+		}
+	}
 
-	if err := m.email.SendAlert(subject, body); err != nil {
-		errs = append(errs, fmt.Errorf("email alert failed: %w", err))
+	if global && m.push.Enabled() && m.routed(ChannelPush, subject) {
+		if err := m.deliver(res, ChannelPush, subject, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("push notification failed: %w", err))
+		}
 	}
 
-	if err := m.slack.SendAlert(subject, body); err != nil {
-		errs = append(errs, fmt.Errorf("slack alert failed: %w", err))
+	if m.snmp.Enabled() && m.routed(ChannelSNMP, subject) {
+		if err := m.call(res, ChannelSNMP, subject, func(context.Context) error { return m.snmp.SendAlert(subject, body) }); err != nil {
+			errs = append(errs, fmt.Errorf("snmp trap failed: %w", err))
+		}
 	}
 
+	if m.syslog.Enabled() && m.routed(ChannelSyslog, subject) {
+		if err := m.call(res, ChannelSyslog, subject, func(ctx context.Context) error { return m.syslog.SendAlert(ctx, subject, body) }); err != nil {
+			errs = append(errs, fmt.Errorf("syslog alert failed: %w", err))
+		}
+	}
+
+	// SMS is for emergencies unless routes say otherwise
+	escalate := isEmergency(subject)
+	if m.routes.has(ChannelSMS) {
+		escalate = m.routed(ChannelSMS, subject)
+	}
+	if m.sms.Enabled() && escalate {
+		errs = append(errs, m.deliverSMS(res, subject, body)...)
+	}
+
+	errs = append(errs, m.deliverWebhooks(res, subject, body)...)
+
 	if len(errs) > 0 {
 		return fmt.Errorf("alert failures: %v", errs)
 	}
@@ -38,10 +314,56 @@ func (m *MultiAlerter) SendAlert(subject, body string) error {
 }
 
 func (m *MultiAlerter) SendSyncSuccess(snapshot, dataset string, duration time.Duration) error {
-	var errs []error
+	return m.enqueue("sync success of "+snapshot, func() error {
+		return m.sendSyncSuccess(snapshot, dataset, duration)
+	})
+}
+
+func (m *MultiAlerter) sendSyncSuccess(snapshot, dataset string, duration time.Duration) error {
+	owners := m.ownersOf(dataset)
+	what := "sync success of " + snapshot
+	errs := m.sendSyncToOwners(nil, owners, what, "", func(ctx context.Context, slack *SlackAlerter) error {
+		return slack.SendSyncSuccess(ctx, snapshot, dataset, duration)
+	})
+
+	if len(owners) == 0 && m.syncRouted(ChannelSlack) {
+		err := m.call(nil, ChannelSlack, what, func(ctx context.Context) error { return m.slack.SendSyncSuccess(ctx, snapshot, dataset, duration) })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("slack sync success alert failed: %w", err))
+		}
+	}
+
+	if len(owners) == 0 && m.telegram.Enabled() && m.syncRouted(ChannelTelegram) {
+		err := m.call(nil, ChannelTelegram, what, func(ctx context.Context) error { return m.telegram.SendSyncSuccess(ctx, snapshot, dataset, duration) })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("telegram sync success alert failed: %w", err))
+		}
+	}
 
-	if err := m.slack.SendSyncSuccess(snapshot, dataset, duration); err != nil {
-		errs = append(errs, fmt.Errorf("slack sync success alert failed: %w", err))
+	if len(owners) == 0 && m.teams.Enabled() && m.syncRouted(ChannelTeams) {
+		err := m.call(nil, ChannelTeams, what, func(ctx context.Context) error { return m.teams.SendSyncSuccess(ctx, snapshot, dataset, duration) })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("teams sync success alert failed: %w", err))
+		}
+	}
+
+	if len(owners) == 0 && m.push.Enabled() && m.syncRouted(ChannelPush) {
+		err := m.call(nil, ChannelPush, what, func(ctx context.Context) error { return m.push.SendSyncSuccess(ctx, snapshot, dataset, duration) })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("push sync success notification failed: %w", err))
+		}
+	}
+
+	if m.syslog.Enabled() && m.syncRouted(ChannelSyslog) {
+		err := m.call(nil, ChannelSyslog, what, func(ctx context.Context) error { return m.syslog.SendSyncSuccess(ctx, snapshot, dataset, duration) })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("syslog sync success failed: %w", err))
+		}
+	}
+
+	// Sync successes are only emailed as part of a digest
+	if len(owners) == 0 && m.digest != nil && m.syncRouted(ChannelEmail) {
+		m.digest.AddSync(snapshot, dataset, duration)
 	}
 
 	if len(errs) > 0 {
@@ -51,20 +373,111 @@ func (m *MultiAlerter) SendSyncSuccess(snapshot, dataset string, duration time.D
 	return nil
 }
 
+// SendSyncStart announces a replication over Slack, to the dataset's owners
+// if it has any
+func (m *MultiAlerter) SendSyncStart(snapshot, dataset string, estimatedBytes int64, eta time.Duration) error {
+	return m.enqueue("sync start of "+snapshot, func() error {
+		what := "sync start of " + snapshot
+		if owners := m.ownersOf(dataset); len(owners) > 0 {
+			errs := m.sendSyncToOwners(nil, owners, what, "", func(ctx context.Context, slack *SlackAlerter) error {
+				return slack.SendSyncStart(ctx, snapshot, dataset, estimatedBytes, eta)
+			})
+			if len(errs) > 0 {
+				return fmt.Errorf("sync start alert failures: %v", errs)
+			}
+			return nil
+		}
+		if !m.syncRouted(ChannelSlack) {
+			return nil
+		}
+		return m.call(nil, ChannelSlack, what, func(ctx context.Context) error {
+			return m.slack.SendSyncStart(ctx, snapshot, dataset, estimatedBytes, eta)
+		})
+	})
+}
+
 func (m *MultiAlerter) SendSyncFailure(snapshot, dataset string, err error) error {
-	var errs []error
+	raised := time.Now()
+	return m.enqueue("sync failure of "+snapshot, func() error {
+		return m.sendSyncFailure(raised, snapshot, dataset, err)
+	})
+}
+
+func (m *MultiAlerter) sendSyncFailure(raised time.Time, snapshot, dataset string, err error) error {
+	subject := "[WARNING] ZFS Sync Failed"
+	body := i18n.T("alert.sync.body", snapshot, dataset, err.Error())
+	if runbook := i18n.Runbook("alert.sync.runbook"); runbook != "" {
+		body += "\n" + runbook
+	}
+	res := newResults()
+	defer res.whenDone(func() { m.recordHistory(raised, subject, res) })
+
+	// Sync failures are retried, so they stay with the owners like warnings
+	owners := m.ownersOf(dataset)
+	global := len(owners) == 0
+	errs := m.sendSyncToOwners(res, owners, subject, body, func(ctx context.Context, slack *SlackAlerter) error {
+		return slack.SendSyncFailure(ctx, snapshot, dataset, err)
+	})
+
+	if global && m.slack.Enabled() && m.slack.config.AlertOnSync && m.routed(ChannelSlack, subject) {
+		slackErr := m.deliverSlack(res, subject, body, func() error {
+			return m.breakers[ChannelSlack].Call(func(ctx context.Context) error { return m.slack.SendSyncFailure(ctx, snapshot, dataset, err) })
+		})
+		if slackErr != nil {
+			errs = append(errs, fmt.Errorf("slack sync failure alert failed: %w", slackErr))
+		}
+	}
+
+	if global && m.telegram.Enabled() && m.telegram.config.AlertOnSync && m.routed(ChannelTelegram, subject) {
+		telegramErr := m.deliver(res, ChannelTelegram, subject, body, func() error {
+			return m.breakers[ChannelTelegram].Call(func(ctx context.Context) error { return m.telegram.SendSyncFailure(ctx, snapshot, dataset, err) })
+		})
+		if telegramErr != nil {
+			errs = append(errs, fmt.Errorf("telegram sync failure alert failed: %w", telegramErr))
+		}
+	}
+
+	if global && m.teams.Enabled() && m.teams.config.AlertOnSync && m.routed(ChannelTeams, subject) {
+		teamsErr := m.deliver(res, ChannelTeams, subject, body, func() error {
+			return m.breakers[ChannelTeams].Call(func(ctx context.Context) error { return m.teams.SendSyncFailure(ctx, snapshot, dataset, err) })
+		})
+		if teamsErr != nil {
+			errs = append(errs, fmt.Errorf("teams sync failure alert failed: %w", teamsErr))
+		}
+	}
 
-	if slackErr := m.slack.SendSyncFailure(snapshot, dataset, err); slackErr != nil {
-		errs = append(errs, fmt.Errorf("slack sync failure alert failed: %w", slackErr))
+	if global && m.push.Enabled() && m.push.config.AlertOnSync && m.routed(ChannelPush, subject) {
+		pushErr := m.deliver(res, ChannelPush, subject, body, func() error {
+			return m.breakers[ChannelPush].Call(func(ctx context.Context) error { return m.push.SendSyncFailure(ctx, snapshot, dataset, err) })
+		})
+		if pushErr != nil {
+			errs = append(errs, fmt.Errorf("push sync failure notification failed: %w", pushErr))
+		}
 	}
 
 	// Also send email for failures
-	subject := "ZFS Sync Failed"
-	body := fmt.Sprintf("Failed to replicate snapshot %s from dataset %s\nError: %s", snapshot, dataset, err.Error())
-	if emailErr := m.email.SendAlert(subject, body); emailErr != nil {
-		errs = append(errs, fmt.Errorf("email sync failure alert failed: %w", emailErr))
+	if global && m.email.Enabled() && m.routed(ChannelEmail, subject) {
+		if emailErr := m.deliverEmail(res, subject, body); emailErr != nil {
+			errs = append(errs, fmt.Errorf("email sync failure alert failed: %w", emailErr))
+		}
+	}
+
+	if m.snmp.Enabled() && m.routed(ChannelSNMP, subject) {
+		snmpErr := m.call(res, ChannelSNMP, subject, func(context.Context) error { return m.snmp.SendSyncFailure(snapshot, dataset, err) })
+		if snmpErr != nil {
+			errs = append(errs, fmt.Errorf("snmp sync failure trap failed: %w", snmpErr))
+		}
 	}
 
+	if m.syslog.Enabled() && m.routed(ChannelSyslog, subject) {
+		syslogErr := m.call(res, ChannelSyslog, subject, func(ctx context.Context) error { return m.syslog.SendSyncFailure(ctx, snapshot, dataset, err) })
+		if syslogErr != nil {
+			errs = append(errs, fmt.Errorf("syslog sync failure failed: %w", syslogErr))
+		}
+	}
+
+	errs = append(errs, m.deliverWebhooks(res, subject, body)...)
+
 	if len(errs) > 0 {
 		return fmt.Errorf("sync failure alert failures: %v", errs)
 	}
@@ -72,8 +485,233 @@ func (m *MultiAlerter) SendSyncFailure(snapshot, dataset string, err error) erro
 	return nil
 }
 
+// deliver queues an alert for channel's worker to send through the outbox,
+// with send if it isn't nil, noting the outcome in res. It only fails if the
+// channel's queue is full; failed sends are logged by the worker.
+func (m *MultiAlerter) deliver(res *results, channel, subject, body string, send func() error) error {
+	return m.toChannel(res, channel, fmt.Sprintf("alert %q", subject), func() error {
+		result, err := m.outbox.deliver(channel, subject, body, send)
+		res.note(channel, result, err)
+		return err
+	})
+}
+
+// call queues what for channel's worker to send directly through the
+// channel's breaker, noting the outcome in res
+func (m *MultiAlerter) call(res *results, channel, what string, send func(ctx context.Context) error) error {
+	return m.toChannel(res, channel, what, func() error {
+		err := m.breakers[channel].Call(send)
+		res.note(channel, ResultSent, err)
+		return err
+	})
+}
+
+// deliverWebhooks posts an alert to every webhook routed it, queueing it for
+// those that fail
+func (m *MultiAlerter) deliverWebhooks(res *results, subject, body string) []error {
+	var errs []error
+	for _, webhook := range m.webhooks {
+		if !m.routed(webhook.Channel(), subject) {
+			continue
+		}
+		if err := m.deliver(res, webhook.Channel(), subject, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("%s alert failed: %w", webhook.Channel(), err))
+		}
+	}
+	return errs
+}
+
+// deliverSMS pages every recipient on duty, each through their own outbox
+// queue so a failed message is retried without paging the others again
+func (m *MultiAlerter) deliverSMS(res *results, subject, body string) []error {
+	recipients := m.sms.OnDuty()
+	if len(recipients) == 0 {
+		logger.Warn("No SMS recipients on duty for emergency alert", "subject", subject)
+	}
+
+	var errs []error
+	for _, recipient := range recipients {
+		if err := m.deliver(res, smsChannel(recipient), subject, body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("sms escalation to %s failed: %w", recipientName(recipient), err))
+		}
+	}
+	return errs
+}
+
+// deliverSlack posts an alert to the global Slack channel with send, or as
+// subject and body if send is nil. With thread_incidents on it is threaded
+// instead, and held while more alerts wait in the dispatch queue so a storm
+// of them is posted as a few batched replies rather than one message each.
+func (m *MultiAlerter) deliverSlack(res *results, subject, body string, send func() error) error {
+	if m.threads == nil {
+		return m.deliver(res, ChannelSlack, subject, body, send)
+	}
+
+	if pending := m.threads.add(subject, body); len(m.queue) > 0 && pending < maxThreadBatch {
+		res.note(ChannelSlack, ResultHeld, nil)
+		return nil
+	}
+	return m.toChannel(res, ChannelSlack, fmt.Sprintf("alert %q", subject), func() error {
+		err := m.flushThreads()
+		res.note(ChannelSlack, ResultSent, err)
+		return err
+	})
+}
+
+// flushThreads posts the held threaded alerts. If the Slack API fails they
+// go through the webhook and outbox instead, unthreaded.
+func (m *MultiAlerter) flushThreads() error {
+	batch := m.threads.take()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := m.breakers[ChannelSlack].Call(func(ctx context.Context) error {
+		var err error
+		batch, err = m.threads.send(ctx, batch)
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+
+	logger.Warn("Failed to thread Slack alerts, falling back to the webhook", "alerts", len(batch), "err", err)
+	var errs []error
+	for _, alert := range batch {
+		if err := m.outbox.Deliver(ChannelSlack, alert.subject, alert.body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// deliverEmail sends an email unless the email digest or the rate limit
+// holds it. Only alerts below CRITICAL other than sync failures go into the
+// email digest.
+func (m *MultiAlerter) deliverEmail(res *results, subject, body string) error {
+	if m.digest != nil && !urgentEmail(subject) {
+		m.digest.AddAlert(subject, body)
+		res.note(ChannelEmail, ResultHeld, nil)
+		return nil
+	}
+	if !m.emailLimiter.Allow(subject, body) {
+		res.note(ChannelEmail, ResultHeld, nil)
+		return nil
+	}
+	return m.deliver(res, ChannelEmail, subject, body, nil)
+}
+
+// urgentEmail reports whether an alert is emailed straight away even with
+// the digest on: anything CRITICAL or worse, which includes every pool that
+// isn't ONLINE, and failed syncs, which would otherwise wait up to a day
+func urgentEmail(subject string) bool {
+	kind, _ := classify(subject)
+	return isCritical(subject) || kind == "sync"
+}
+
 func (m *MultiAlerter) SendSystemStatus(status map[string]interface{}) error {
-	return m.slack.SendSystemStatus(status)
+	return m.enqueue("system status", func() error {
+		return m.call(nil, ChannelSlack, "system status", func(ctx context.Context) error { return m.slack.SendSystemStatus(ctx, status) })
+	})
+}
+
+// PendingAlerts returns the number of undelivered alerts queued per channel
+func (m *MultiAlerter) PendingAlerts() map[string]int {
+	return m.outbox.Pending()
+}
+
+// ChannelStates returns the breaker state of each channel: closed, open or half-open
+func (m *MultiAlerter) ChannelStates() map[string]string {
+	states := make(map[string]string)
+	for channel, breaker := range m.breakers {
+		states[channel] = breaker.State()
+	}
+	return states
+}
+
+// RecordAudit is an audit.Sink forwarding audit events to syslog
+func (m *MultiAlerter) RecordAudit(event audit.Event) {
+	err := m.enqueue("audit event", func() error {
+		return m.toChannel(nil, ChannelSyslog, "audit event", func() error {
+			m.syslog.RecordAudit(event)
+			return nil
+		})
+	})
+	if err != nil {
+		logger.Error("Failed to forward audit event to syslog", "err", err)
+	}
+}
+
+// HeldAlerts returns the number of emails held back for the next rate limit
+// or email digest
+func (m *MultiAlerter) HeldAlerts() int {
+	held := m.emailLimiter.Held()
+	if m.digest != nil {
+		held += m.digest.Pending()
+	}
+	return held
+}
+
+// SetUpdateChecker notes newer releases in the email digest
+func (m *MultiAlerter) SetUpdateChecker(updates UpdateReporter) {
+	if m.digest != nil {
+		m.digest.SetUpdates(updates)
+	}
+}
+
+// SetCompliance reports each SLA's monthly compliance in the email digest
+func (m *MultiAlerter) SetCompliance(report ComplianceReporter) {
+	if m.digest != nil {
+		m.digest.SetCompliance(report, m.slaDatasets)
+	}
+}
+
+// SetDigestStore persists the alerts held for the email digest at path
+func (m *MultiAlerter) SetDigestStore(path string) {
+	if m.digest != nil {
+		m.digest.SetStore(path)
+	}
+}
+
+// Start retries queued alerts and sends rate limit and email digests in the
+// background until Stop is called
+func (m *MultiAlerter) Start() {
+	go m.emailLimiter.Start()
+	if m.digest != nil {
+		if err := m.digest.Start(); err != nil {
+			logger.Error("Failed to schedule email digest", "err", err)
+		}
+	}
+	m.startOwners()
+	m.outbox.Start()
+}
+
+// Stop ends background delivery, giving queued alerts up to drainTimeout to
+// go out
+func (m *MultiAlerter) Stop() {
+	m.queueMutex.Lock()
+	if !m.stopped {
+		m.stopped = true
+		close(m.queue)
+	}
+	m.queueMutex.Unlock()
+
+	select {
+	case <-m.dispatching:
+	case <-time.After(drainTimeout):
+		logger.Warn("Gave up waiting for queued alerts", "alerts", len(m.queue)+int(m.queued.Load()), "timeout", drainTimeout)
+	}
+
+	m.emailLimiter.Stop()
+	if m.digest != nil {
+		m.digest.Stop()
+	}
+	m.stopOwners()
+	m.outbox.Stop()
+	m.syslog.Close()
 }
 
 func (m *MultiAlerter) TestConnection() error {
@@ -87,6 +725,42 @@ func (m *MultiAlerter) TestConnection() error {
 		errs = append(errs, fmt.Errorf("slack test failed: %w", err))
 	}
 
+	if m.snmp.Enabled() {
+		if err := m.snmp.TestConnection(); err != nil {
+			errs = append(errs, fmt.Errorf("snmp test failed: %w", err))
+		}
+	}
+
+	if m.telegram.Enabled() {
+		if err := m.telegram.TestConnection(); err != nil {
+			errs = append(errs, fmt.Errorf("telegram test failed: %w", err))
+		}
+	}
+
+	if m.teams.Enabled() {
+		if err := m.teams.TestConnection(); err != nil {
+			errs = append(errs, fmt.Errorf("teams test failed: %w", err))
+		}
+	}
+
+	if m.push.Enabled() {
+		if err := m.push.TestConnection(); err != nil {
+			errs = append(errs, fmt.Errorf("push test failed: %w", err))
+		}
+	}
+
+	if m.sms.Enabled() {
+		if err := m.sms.TestConnection(); err != nil {
+			errs = append(errs, fmt.Errorf("sms test failed: %w", err))
+		}
+	}
+
+	for _, webhook := range m.webhooks {
+		if err := webhook.TestConnection(); err != nil {
+			errs = append(errs, fmt.Errorf("%s test failed: %w", webhook.Channel(), err))
+		}
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("connection test failures: %v", errs)
 	}
@@ -0,0 +1,291 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/utils"
+)
+
+const (
+	outboxMaxMessages = 1000
+	outboxMaxAge      = 7 * 24 * time.Hour
+	outboxFlushPeriod = 1 * time.Minute
+)
+
+// SendFunc delivers a single alert over one channel
+type SendFunc func(subject, body string) error
+
+// OutboxMessage is an alert that could not be delivered yet
+type OutboxMessage struct {
+	Channel   string    `json:"channel"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+
+	seq uint64 // Matches a flush's results back to the queue
+}
+
+// Outbox queues alerts per channel while the channel is failing and delivers
+// them in order once it recovers. The queue is persisted so alerts raised
+// during an outage survive a daemon restart.
+type Outbox struct {
+	path     string
+	mutex    sync.Mutex
+	messages []OutboxMessage
+	senders  map[string]SendFunc
+	nextSeq  uint64
+	flushing bool // A flush is sending; others leave the queue to it
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewOutbox creates an outbox persisted at path; an empty path keeps it in memory only
+func NewOutbox(path string) *Outbox {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	o := &Outbox{
+		path:    path,
+		senders: make(map[string]SendFunc),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &o.messages); err != nil {
+			logger.Error("Failed to load alert outbox", "path", path, "err", err)
+		} else if len(o.messages) > 0 {
+			logger.Info("Loaded undelivered alerts", "alerts", len(o.messages), "path", path)
+		}
+		for i := range o.messages {
+			o.nextSeq++
+			o.messages[i].seq = o.nextSeq
+		}
+	}
+
+	return o
+}
+
+// Register sets the delivery function for a channel
+func (o *Outbox) Register(channel string, send SendFunc) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.senders[channel] = send
+}
+
+// Deliver sends an alert over a channel, or queues it if the channel is down
+// or already has a backlog (so alerts are never delivered out of order).
+// Queued alerts are not an error: they will be delivered on recovery.
+func (o *Outbox) Deliver(channel, subject, body string) error {
+	return o.DeliverFunc(channel, subject, body, nil)
+}
+
+// DeliverFunc is like Deliver but makes the first attempt with send, letting
+// callers use a richer format than the channel's plain subject/body sender.
+// Retries always go through the registered sender.
+func (o *Outbox) DeliverFunc(channel, subject, body string, send func() error) error {
+	_, err := o.deliver(channel, subject, body, send)
+	return err
+}
+
+// deliver is DeliverFunc, also returning whether the alert was sent or queued
+func (o *Outbox) deliver(channel, subject, body string, send func() error) (string, error) {
+	o.mutex.Lock()
+	registered, ok := o.senders[channel]
+	backlog := o.pendingLocked(channel)
+	o.mutex.Unlock()
+
+	if !ok {
+		return ResultFailed, fmt.Errorf("unknown alert channel %s", channel)
+	}
+
+	if backlog > 0 {
+		o.enqueue(OutboxMessage{Channel: channel, Subject: subject, Body: body, CreatedAt: time.Now()})
+		o.Flush()
+		return ResultQueued, nil
+	}
+
+	if send == nil {
+		send = func() error { return registered(subject, body) }
+	}
+
+	if err := send(); err != nil {
+		logger.Warn("Alert delivery failed, queueing for retry", "channel", channel, "err", err)
+		o.enqueue(OutboxMessage{Channel: channel, Subject: subject, Body: body, CreatedAt: time.Now(), Attempts: 1, LastError: err.Error()})
+		return ResultQueued, nil
+	}
+	return ResultSent, nil
+}
+
+func (o *Outbox) enqueue(msg OutboxMessage) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.nextSeq++
+	msg.seq = o.nextSeq
+	o.messages = append(o.messages, msg)
+	if len(o.messages) > outboxMaxMessages {
+		dropped := len(o.messages) - outboxMaxMessages
+		logger.Warn("Alert outbox full, dropping the oldest alerts", "dropped", dropped)
+		o.messages = o.messages[dropped:]
+	}
+	o.saveLocked()
+}
+
+// Flush attempts delivery of queued alerts in order. A channel stops at its
+// first failure so later alerts never overtake earlier ones. Alerts are sent
+// without holding the lock, so a slow channel doesn't hold up queueing new
+// ones, and only one flush sends at a time; alerts queued meanwhile are left
+// to the flush already running.
+func (o *Outbox) Flush() {
+	o.mutex.Lock()
+	if o.flushing {
+		o.mutex.Unlock()
+		return
+	}
+	o.flushing = true
+	o.mutex.Unlock()
+
+	defer func() {
+		o.mutex.Lock()
+		o.flushing = false
+		o.mutex.Unlock()
+	}()
+
+	for o.flushOnce() {
+	}
+}
+
+// flushOnce sends the queued alerts, then records the results against the
+// queue as it is by then. It reports whether alerts were queued during the
+// send for a channel that is still working, which are sent next.
+func (o *Outbox) flushOnce() bool {
+	o.mutex.Lock()
+	queued := slices.Clone(o.messages)
+	senders := maps.Clone(o.senders)
+	o.mutex.Unlock()
+
+	if len(queued) == 0 {
+		return false
+	}
+
+	delayed := make(map[string]int)
+	for _, msg := range queued {
+		delayed[msg.Channel]++
+	}
+
+	failed := make(map[string]bool)
+	done := make(map[uint64]bool) // Delivered or discarded
+	retried := make(map[uint64]OutboxMessage)
+	sent := 0
+
+	for _, msg := range queued {
+		if time.Since(msg.CreatedAt) > outboxMaxAge {
+			logger.Warn("Discarding undeliverable alert", "subject", msg.Subject, "channel", msg.Channel, "max_age", outboxMaxAge)
+			done[msg.seq] = true
+			continue
+		}
+
+		send, ok := senders[msg.Channel]
+		if !ok || failed[msg.Channel] {
+			continue
+		}
+
+		subject := fmt.Sprintf("[DELAYED] %s", msg.Subject)
+		body := fmt.Sprintf("%d alerts delayed by a %s delivery outage. This alert was raised at %s.\n\n%s",
+			delayed[msg.Channel], msg.Channel, display.Time(msg.CreatedAt), msg.Body)
+
+		if err := send(subject, body); err != nil {
+			msg.Attempts++
+			msg.LastError = err.Error()
+			failed[msg.Channel] = true
+			retried[msg.seq] = msg
+			continue
+		}
+		done[msg.seq] = true
+		sent++
+	}
+
+	if sent > 0 {
+		logger.Info("Delivered delayed alerts from outbox", "alerts", sent)
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	last := queued[len(queued)-1].seq
+	more := false
+	remaining := make([]OutboxMessage, 0, len(o.messages))
+	for _, msg := range o.messages {
+		if done[msg.seq] {
+			continue
+		}
+		if updated, ok := retried[msg.seq]; ok {
+			msg = updated
+		}
+		if _, ok := senders[msg.Channel]; ok && msg.seq > last && !failed[msg.Channel] {
+			more = true
+		}
+		remaining = append(remaining, msg)
+	}
+	o.messages = remaining
+	o.saveLocked()
+	return more
+}
+
+// Pending returns the number of queued alerts per channel
+func (o *Outbox) Pending() map[string]int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	pending := make(map[string]int)
+	for _, msg := range o.messages {
+		pending[msg.Channel]++
+	}
+	return pending
+}
+
+func (o *Outbox) pendingLocked(channel string) int {
+	count := 0
+	for _, msg := range o.messages {
+		if msg.Channel == channel {
+			count++
+		}
+	}
+	return count
+}
+
+func (o *Outbox) saveLocked() {
+	if o.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(o.path, o.messages, 0600); err != nil {
+		logger.Error("Failed to save alert outbox", "path", o.path, "err", err)
+	}
+}
+
+// Start periodically retries queued alerts until Stop is called
+func (o *Outbox) Start() {
+	ticker := time.NewTicker(outboxFlushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-ticker.C:
+			o.Flush()
+		}
+	}
+}
+
+func (o *Outbox) Stop() {
+	o.cancel()
+}
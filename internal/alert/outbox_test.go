@@ -0,0 +1,159 @@
+package alert
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeChannel struct {
+	down     bool
+	subjects []string
+	bodies   []string
+}
+
+func (f *fakeChannel) send(subject, body string) error {
+	if f.down {
+		return fmt.Errorf("channel down")
+	}
+	f.subjects = append(f.subjects, subject)
+	f.bodies = append(f.bodies, body)
+	return nil
+}
+
+func TestOutboxDeliversImmediately(t *testing.T) {
+	channel := &fakeChannel{}
+	outbox := NewOutbox("")
+	outbox.Register("test", channel.send)
+
+	if err := outbox.Deliver("test", "Subject", "Body"); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+
+	if len(channel.subjects) != 1 || channel.subjects[0] != "Subject" {
+		t.Errorf("Expected immediate delivery, got %v", channel.subjects)
+	}
+	if pending := outbox.Pending()["test"]; pending != 0 {
+		t.Errorf("Expected no pending alerts, got %d", pending)
+	}
+}
+
+func TestOutboxQueuesAndFlushesInOrder(t *testing.T) {
+	channel := &fakeChannel{down: true}
+	outbox := NewOutbox("")
+	outbox.Register("test", channel.send)
+
+	for i := 1; i <= 3; i++ {
+		if err := outbox.Deliver("test", fmt.Sprintf("Alert %d", i), "Body"); err != nil {
+			t.Fatalf("Deliver should queue rather than fail: %v", err)
+		}
+	}
+
+	if pending := outbox.Pending()["test"]; pending != 3 {
+		t.Fatalf("Expected 3 pending alerts, got %d", pending)
+	}
+
+	outbox.Flush()
+	if pending := outbox.Pending()["test"]; pending != 3 {
+		t.Fatalf("Expected alerts to stay queued while channel is down, got %d", pending)
+	}
+
+	channel.down = false
+	outbox.Flush()
+
+	if pending := outbox.Pending()["test"]; pending != 0 {
+		t.Errorf("Expected outbox to drain, got %d pending", pending)
+	}
+
+	expected := []string{"[DELAYED] Alert 1", "[DELAYED] Alert 2", "[DELAYED] Alert 3"}
+	if strings.Join(channel.subjects, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, channel.subjects)
+	}
+	if !strings.HasPrefix(channel.bodies[0], "3 alerts delayed") {
+		t.Errorf("Expected delayed prefix, got %q", channel.bodies[0])
+	}
+}
+
+func TestOutboxPreservesOrderBehindBacklog(t *testing.T) {
+	channel := &fakeChannel{down: true}
+	outbox := NewOutbox("")
+	outbox.Register("test", channel.send)
+
+	outbox.Deliver("test", "First", "Body")
+
+	// The channel recovers, but a new alert must not overtake the queued one
+	channel.down = false
+	outbox.Deliver("test", "Second", "Body")
+
+	expected := []string{"[DELAYED] First", "[DELAYED] Second"}
+	if strings.Join(channel.subjects, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, channel.subjects)
+	}
+}
+
+func TestOutboxQueuesDuringFlush(t *testing.T) {
+	channel := &fakeChannel{down: true}
+	outbox := NewOutbox("")
+	outbox.Register("test", func(subject, body string) error {
+		// Alerts raised while a flush is sending queue behind it rather than
+		// waiting for the lock
+		if subject == "[DELAYED] Alert 1" {
+			if err := outbox.Deliver("test", "Alert 2", "Body"); err != nil {
+				t.Errorf("Deliver during a flush failed: %v", err)
+			}
+		}
+		return channel.send(subject, body)
+	})
+
+	outbox.Deliver("test", "Alert 1", "Body")
+	channel.down = false
+
+	done := make(chan struct{})
+	go func() {
+		outbox.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush blocked an alert raised while sending")
+	}
+
+	if len(channel.subjects) != 2 || !strings.HasSuffix(channel.subjects[0], "Alert 1") || !strings.HasSuffix(channel.subjects[1], "Alert 2") {
+		t.Errorf("Expected both alerts in order, got %v", channel.subjects)
+	}
+	if pending := outbox.Pending()["test"]; pending != 0 {
+		t.Errorf("Expected the queue to drain, got %d", pending)
+	}
+}
+
+func TestOutboxPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alert_outbox.json")
+
+	down := &fakeChannel{down: true}
+	outbox := NewOutbox(path)
+	outbox.Register("test", down.send)
+	outbox.Deliver("test", "Pool degraded", "Body")
+
+	channel := &fakeChannel{}
+	reloaded := NewOutbox(path)
+	reloaded.Register("test", channel.send)
+
+	if pending := reloaded.Pending()["test"]; pending != 1 {
+		t.Fatalf("Expected queued alert to survive reload, got %d pending", pending)
+	}
+
+	reloaded.Flush()
+	if len(channel.subjects) != 1 || channel.subjects[0] != "[DELAYED] Pool degraded" {
+		t.Errorf("Expected reloaded alert to be delivered, got %v", channel.subjects)
+	}
+}
+
+func TestOutboxUnknownChannel(t *testing.T) {
+	outbox := NewOutbox("")
+	if err := outbox.Deliver("missing", "Subject", "Body"); err == nil {
+		t.Error("Expected error for unregistered channel")
+	}
+}
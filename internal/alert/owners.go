@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+
+	"zfsrabbit/internal/config"
+)
+
+// owner delivers alerts about a team's datasets to the team's own email
+// recipients and Slack channel
+type owner struct {
+	config       config.OwnerConfig
+	email        *EmailAlerter
+	slack        *SlackAlerter
+	emailLimiter *RateLimiter
+	emailChannel string // Outbox and breaker names, e.g. "email:vm-team"
+	slackChannel string
+}
+
+// addOwners sets up delivery for each configured owner. Owners share the
+// global SMTP server, rate limits and Slack formatting, with their own
+// recipients, webhook and channel.
+func (m *MultiAlerter) addOwners(cfg *config.Config) {
+	for _, ownerCfg := range cfg.Owners {
+		o := &owner{
+			config:       ownerCfg,
+			emailChannel: ChannelEmail + ":" + ownerCfg.Name,
+			slackChannel: ChannelSlack + ":" + ownerCfg.Name,
+		}
+
+		if len(ownerCfg.Emails) > 0 {
+			emailCfg := cfg.Email
+			emailCfg.ToEmails = ownerCfg.Emails
+			o.email = NewEmailAlerter(&emailCfg)
+			m.breakers[o.emailChannel] = NewBreaker(o.emailChannel, sendTimeout)
+			m.outbox.Register(o.emailChannel, m.breakers[o.emailChannel].Wrap(o.email.SendAlert))
+			o.emailLimiter = NewRateLimiter(emailCfg.MaxPerHour, emailCfg.MaxPerSubjectPerHour, func(subject, body string) error {
+				return m.outbox.Deliver(o.emailChannel, subject, body)
+			})
+		}
+
+		if ownerCfg.SlackWebhookURL != "" || ownerCfg.SlackChannel != "" {
+			slackCfg := cfg.Slack
+			slackCfg.Enabled = true
+			if ownerCfg.SlackWebhookURL != "" {
+				slackCfg.WebhookURL = ownerCfg.SlackWebhookURL
+			}
+			if ownerCfg.SlackChannel != "" {
+				slackCfg.Channel = ownerCfg.SlackChannel
+			}
+			o.slack = NewSlackAlerter(&slackCfg)
+			m.breakers[o.slackChannel] = NewBreaker(o.slackChannel, sendTimeout)
+			m.outbox.Register(o.slackChannel, m.breakers[o.slackChannel].Wrap(o.slack.SendAlert))
+		}
+
+		m.owners = append(m.owners, o)
+	}
+}
+
+// ownersOf returns the owners of dataset; none for an empty dataset
+func (m *MultiAlerter) ownersOf(dataset string) []*owner {
+	if dataset == "" {
+		return nil
+	}
+	var owners []*owner
+	for _, o := range m.owners {
+		if o.config.Owns(dataset) {
+			owners = append(owners, o)
+		}
+	}
+	return owners
+}
+
+// isCritical reports whether an alert is CRITICAL or worse, so it still goes
+// to the global channels when a dataset's owner receives it
+func isCritical(subject string) bool {
+	severity, _ := splitSeverity(subject)
+	return severity == "CRITICAL" || severity == "EMERGENCY"
+}
+
+// sendToOwners delivers an alert to every owner's email and Slack
+func (m *MultiAlerter) sendToOwners(res *results, owners []*owner, subject, body string) []error {
+	var errs []error
+	for _, o := range owners {
+		if o.email != nil {
+			if !o.emailLimiter.Allow(subject, body) {
+				res.note(o.emailChannel, ResultHeld, nil)
+			} else if err := m.deliver(res, o.emailChannel, subject, body, nil); err != nil {
+				errs = append(errs, fmt.Errorf("%s alert failed: %w", o.emailChannel, err))
+			}
+		}
+		if o.slack != nil {
+			if err := m.deliver(res, o.slackChannel, subject, body, nil); err != nil {
+				errs = append(errs, fmt.Errorf("%s alert failed: %w", o.slackChannel, err))
+			}
+		}
+	}
+	return errs
+}
+
+// sendSyncToOwners posts a sync notification to every owner's Slack with
+// send, queueing subject and body for retry if it fails. Failures, which
+// have a body, are also emailed, and their outcomes noted in res. Other
+// notifications only have a subject, naming them in the log.
+func (m *MultiAlerter) sendSyncToOwners(res *results, owners []*owner, subject, body string, send func(context.Context, *SlackAlerter) error) []error {
+	var errs []error
+	for _, o := range owners {
+		if o.slack != nil && o.slack.config.AlertOnSync {
+			var err error
+			if body != "" {
+				err = m.deliver(res, o.slackChannel, subject, body, func() error {
+					return m.breakers[o.slackChannel].Call(func(ctx context.Context) error { return send(ctx, o.slack) })
+				})
+			} else {
+				err = m.call(nil, o.slackChannel, subject, func(ctx context.Context) error { return send(ctx, o.slack) })
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s sync alert failed: %w", o.slackChannel, err))
+			}
+		}
+		if o.email != nil && body != "" {
+			if !o.emailLimiter.Allow(subject, body) {
+				res.note(o.emailChannel, ResultHeld, nil)
+			} else if err := m.deliver(res, o.emailChannel, subject, body, nil); err != nil {
+				errs = append(errs, fmt.Errorf("%s sync alert failed: %w", o.emailChannel, err))
+			}
+		}
+	}
+	return errs
+}
+
+// startOwners and stopOwners run the owners' rate limit digests alongside
+// the global one
+func (m *MultiAlerter) startOwners() {
+	for _, o := range m.owners {
+		if o.emailLimiter != nil {
+			go o.emailLimiter.Start()
+		}
+	}
+}
+
+func (m *MultiAlerter) stopOwners() {
+	for _, o := range m.owners {
+		if o.emailLimiter != nil {
+			o.emailLimiter.Stop()
+		}
+	}
+}
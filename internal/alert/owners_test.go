@@ -0,0 +1,112 @@
+package alert
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestOwnersReceiveTheirDatasetAlerts(t *testing.T) {
+	var mutex sync.Mutex
+	received := make(map[string]int)
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		received[r.URL.Path]++
+		mutex.Unlock()
+	}))
+	defer slack.Close()
+
+	cfg := &config.Config{
+		Slack: config.SlackConfig{Enabled: true, AlertOnSync: true, WebhookURL: slack.URL + "/global"},
+		Owners: []config.OwnerConfig{
+			{Name: "vm-team", Datasets: []string{"tank/vms"}, SlackWebhookURL: slack.URL + "/vm"},
+			{Name: "db-team", Datasets: []string{"tank/db"}, SlackWebhookURL: slack.URL + "/db"},
+		},
+	}
+	m := NewMultiAlerter(cfg, "")
+
+	tests := []struct {
+		name string
+		send func() error
+		want []string
+	}{
+		{"child dataset warning", func() error {
+			return m.SendAlert("[WARNING] ZFS Property Drift: tank/vms/web", "ZFS Property Drift\n\nDataset: tank/vms/web\n")
+		}, []string{"/vm"}},
+		{"critical", func() error {
+			return m.SendAlert("[CRITICAL] ZFS Property Drift: tank/db", "Dataset: tank/db\n")
+		}, []string{"/db", "/global"}},
+		{"pool alert", func() error {
+			return m.SendAlert("[WARNING] ZFS Pool Alert: tank", "Pool: tank\nState: DEGRADED\n")
+		}, []string{"/global"}},
+		{"unowned dataset", func() error {
+			return m.SendAlert("[WARNING] ZFS Property Drift: tank/vmstore", "Dataset: tank/vmstore\n")
+		}, []string{"/global"}},
+		{"sync success", func() error {
+			return m.SendSyncSuccess("autosnap_1", "tank/vms", time.Minute)
+		}, []string{"/vm"}},
+		{"sync failure", func() error {
+			return m.SendSyncFailure("autosnap_1", "tank/db", errors.New("connection refused"))
+		}, []string{"/db"}},
+	}
+
+	for _, tt := range tests {
+		mutex.Lock()
+		clear(received)
+		mutex.Unlock()
+
+		if err := tt.send(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		waitForQueue(t, m)
+
+		mutex.Lock()
+		var got []string
+		for path := range received {
+			got = append(got, path)
+		}
+		mutex.Unlock()
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected delivery to %v, got %v", tt.name, tt.want, got)
+		}
+	}
+	m.Stop()
+
+	if _, ok := m.ChannelStates()["slack:vm-team"]; !ok {
+		t.Error("Expected a breaker for the owner's Slack channel")
+	}
+}
+
+// waitForQueue waits until every queued alert has been dispatched and each
+// channel's worker has finished delivering it
+func waitForQueue(t *testing.T, m *MultiAlerter) {
+	t.Helper()
+	done := make(chan struct{})
+	err := m.enqueue("wait", func() error {
+		var workers sync.WaitGroup
+		for channel := range m.workers {
+			workers.Add(1)
+			m.toChannel(nil, channel, "wait", func() error { workers.Done(); return nil })
+		}
+		go func() {
+			workers.Wait()
+			close(done)
+		}()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for queued alerts")
+	}
+}
@@ -0,0 +1,169 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/version"
+)
+
+// pushMaxLength keeps messages well within ntfy's 4096 byte limit, above
+// which the message would become an attachment
+const pushMaxLength = 4000
+
+// PushAlerter sends alerts as phone push notifications through an ntfy
+// topic or a Gotify application, with the notification priority following
+// the alert's severity
+type PushAlerter struct {
+	config *config.PushConfig
+	client *http.Client
+}
+
+func NewPushAlerter(cfg *config.PushConfig) *PushAlerter {
+	return &PushAlerter{
+		config: cfg,
+		client: &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Enabled reports whether push notifications are configured
+func (p *PushAlerter) Enabled() bool {
+	return p.config.Enabled && p.config.Server != ""
+}
+
+func (p *PushAlerter) SendAlert(ctx context.Context, subject, body string) error {
+	if !p.Enabled() {
+		return nil
+	}
+	severity, _ := splitSeverity(subject)
+	return p.push(ctx, subject, body, severity, pushTags(severity))
+}
+
+func (p *PushAlerter) SendSyncSuccess(ctx context.Context, snapshot, dataset string, duration time.Duration) error {
+	if !p.Enabled() || !p.config.AlertOnSync {
+		return nil
+	}
+	return p.push(ctx, i18n.T("slack.sync_success_title"),
+		i18n.T("slack.sync_success", snapshot, dataset, duration.String()), "INFO", []string{"white_check_mark"})
+}
+
+func (p *PushAlerter) SendSyncFailure(ctx context.Context, snapshot, dataset string, err error) error {
+	if !p.Enabled() || !p.config.AlertOnSync {
+		return nil
+	}
+	return p.push(ctx, i18n.T("slack.sync_failure_title"),
+		i18n.T("slack.sync_failure", snapshot, dataset, err.Error()), "WARNING", []string{"x"})
+}
+
+func (p *PushAlerter) TestConnection() error {
+	return p.SendAlert(context.Background(), "[INFO] Test Alert", "This is a test notification from ZFSRabbit to verify push notifications.")
+}
+
+func (p *PushAlerter) push(ctx context.Context, title, message, severity string, tags []string) error {
+	message = strings.ReplaceAll(message, "`", "")
+	if len(message) > pushMaxLength {
+		message = truncate(message, pushMaxLength) + "…"
+	}
+
+	server := strings.TrimSuffix(p.config.Server, "/")
+	var endpoint string
+	var payload map[string]interface{}
+	if p.config.Provider == "gotify" {
+		endpoint = server + "/message"
+		payload = map[string]interface{}{
+			"title":    title,
+			"message":  message,
+			"priority": gotifyPriority(severity),
+		}
+	} else {
+		// ntfy takes JSON published to the server root, which unlike its
+		// header API allows UTF-8 titles
+		endpoint = server
+		payload = map[string]interface{}{
+			"topic":    p.config.Topic,
+			"title":    title,
+			"message":  message,
+			"priority": ntfyPriority(severity),
+			"tags":     tags,
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	if p.config.Token != "" {
+		if p.config.Provider == "gotify" {
+			req.Header.Set("X-Gotify-Key", p.config.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+p.config.Token)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s notification: %w", p.config.Provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", p.config.Provider, resp.StatusCode)
+	}
+	return nil
+}
+
+// ntfyPriority maps a severity to ntfy's 1 (min) to 5 (urgent) scale
+func ntfyPriority(severity string) int {
+	switch severity {
+	case "EMERGENCY":
+		return 5
+	case "CRITICAL":
+		return 4
+	case "INFO":
+		return 2
+	default:
+		return 3
+	}
+}
+
+// gotifyPriority maps a severity to Gotify's 0 to 10 scale; the Android app
+// notifies silently below 4
+func gotifyPriority(severity string) int {
+	switch severity {
+	case "EMERGENCY":
+		return 10
+	case "CRITICAL":
+		return 8
+	case "INFO":
+		return 2
+	default:
+		return 5
+	}
+}
+
+// pushTags are the ntfy tags for a severity, shown as an emoji before the title
+func pushTags(severity string) []string {
+	switch severity {
+	case "EMERGENCY":
+		return []string{"rotating_light"}
+	case "CRITICAL":
+		return []string{"red_circle"}
+	case "INFO":
+		return []string{"information_source"}
+	default:
+		return []string{"warning"}
+	}
+}
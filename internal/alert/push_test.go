@@ -0,0 +1,91 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestPushAlerterNtfy(t *testing.T) {
+	var path, auth string
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	alerter := NewPushAlerter(&config.PushConfig{
+		Enabled:  true,
+		Provider: "ntfy",
+		Server:   server.URL + "/",
+		Topic:    "zfsrabbit-alerts",
+		Token:    "tk_secret",
+	})
+	if err := alerter.SendAlert(context.Background(), "[EMERGENCY] ZFS Pool Alert: tank", "Pool: tank\nState: FAULTED\n"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+
+	if path != "/" || auth != "Bearer tk_secret" {
+		t.Errorf("Expected a publish to the server root with the token, got %q %q", path, auth)
+	}
+	if got["topic"] != "zfsrabbit-alerts" || got["title"] != "[EMERGENCY] ZFS Pool Alert: tank" {
+		t.Errorf("Unexpected message: %v", got)
+	}
+	if got["priority"] != float64(5) {
+		t.Errorf("Expected urgent priority, got %v", got["priority"])
+	}
+}
+
+func TestPushAlerterGotify(t *testing.T) {
+	var path, key string
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.Header.Get("X-Gotify-Key")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	alerter := NewPushAlerter(&config.PushConfig{
+		Enabled:  true,
+		Provider: "gotify",
+		Server:   server.URL,
+		Token:    "app-token",
+	})
+	if err := alerter.SendAlert(context.Background(), "[CRITICAL] HDD Health Alert: sda", "Device: /dev/sda `x`"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+
+	if path != "/message" || key != "app-token" {
+		t.Errorf("Expected a message posted with the app token, got %q %q", path, key)
+	}
+	if got["priority"] != float64(8) || got["message"] != "Device: /dev/sda x" {
+		t.Errorf("Unexpected message: %v", got)
+	}
+}
+
+func TestPushPriorities(t *testing.T) {
+	tests := []struct {
+		severity string
+		ntfy     int
+		gotify   int
+	}{
+		{"INFO", 2, 2},
+		{"WARNING", 3, 5},
+		{"", 3, 5},
+		{"CRITICAL", 4, 8},
+		{"EMERGENCY", 5, 10},
+	}
+	for _, tt := range tests {
+		if got := ntfyPriority(tt.severity); got != tt.ntfy {
+			t.Errorf("ntfyPriority(%q) = %d, want %d", tt.severity, got, tt.ntfy)
+		}
+		if got := gotifyPriority(tt.severity); got != tt.gotify {
+			t.Errorf("gotifyPriority(%q) = %d, want %d", tt.severity, got, tt.gotify)
+		}
+	}
+}
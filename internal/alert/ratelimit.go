@@ -0,0 +1,233 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/display"
+)
+
+const (
+	rateLimitWindow      = 1 * time.Hour
+	rateLimitCheckPeriod = 1 * time.Minute
+)
+
+// RateLimiter caps how many alerts a channel sends per hour, both overall and
+// per subject, so a flapping disk can't flood an inbox. Alerts over the limit
+// are held back and summarized in a single digest an hour after the first one
+// was held. CRITICAL and EMERGENCY alerts are never held.
+type RateLimiter struct {
+	maxTotal      int // 0 means unlimited
+	maxPerSubject int // 0 means unlimited
+	sendDigest    SendFunc
+	mutex         sync.Mutex
+	sent          []sentAlert
+	held          map[string]*heldAlert
+	firstHeld     time.Time
+	now           func() time.Time
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+type sentAlert struct {
+	subject string
+	at      time.Time
+}
+
+type heldAlert struct {
+	subject  string
+	count    int
+	first    time.Time
+	last     time.Time
+	lastBody string
+}
+
+// NewRateLimiter creates a limiter that delivers digests through sendDigest
+func NewRateLimiter(maxTotal, maxPerSubject int, sendDigest SendFunc) *RateLimiter {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &RateLimiter{
+		maxTotal:      maxTotal,
+		maxPerSubject: maxPerSubject,
+		sendDigest:    sendDigest,
+		held:          make(map[string]*heldAlert),
+		now:           time.Now,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Allow reports whether an alert may be sent now. Alerts that aren't allowed
+// are held for the next digest. CRITICAL and EMERGENCY alerts are always
+// allowed, though they still count towards the limits.
+func (r *RateLimiter) Allow(subject, body string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	r.pruneLocked(now)
+
+	if isCritical(subject) {
+		r.sent = append(r.sent, sentAlert{subject: subject, at: now})
+		return true
+	}
+
+	subjectCount := 0
+	for _, s := range r.sent {
+		if s.subject == subject {
+			subjectCount++
+		}
+	}
+
+	overTotal := r.maxTotal > 0 && len(r.sent) >= r.maxTotal
+	overSubject := r.maxPerSubject > 0 && subjectCount >= r.maxPerSubject
+	if !overTotal && !overSubject {
+		r.sent = append(r.sent, sentAlert{subject: subject, at: now})
+		return true
+	}
+
+	h, ok := r.held[subject]
+	if !ok {
+		h = &heldAlert{subject: subject, first: now}
+		r.held[subject] = h
+	}
+	h.count++
+	h.last = now
+	h.lastBody = body
+
+	if r.firstHeld.IsZero() {
+		r.firstHeld = now
+		logger.Warn("Alert rate limit reached, holding this and later alerts for a digest", "subject", subject)
+	}
+	return false
+}
+
+// Held returns how many alerts are waiting for the next digest
+func (r *RateLimiter) Held() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	total := 0
+	for _, h := range r.held {
+		total += h.count
+	}
+	return total
+}
+
+// FlushDigest sends the digest if one is due. Held alerts are kept if it fails.
+func (r *RateLimiter) FlushDigest() error {
+	return r.flush(false)
+}
+
+// flush sends the digest if one is due, or whenever alerts are held if force
+// is set. The held alerts are taken out under the lock and sent without it,
+// so alerts raised during a slow SMTP send aren't blocked behind it.
+func (r *RateLimiter) flush(force bool) error {
+	r.mutex.Lock()
+	now := r.now()
+	if len(r.held) == 0 || (!force && now.Sub(r.firstHeld) < rateLimitWindow) {
+		r.mutex.Unlock()
+		return nil
+	}
+
+	subject, body := r.digestLocked()
+	held, firstHeld := r.held, r.firstHeld
+	r.held = make(map[string]*heldAlert)
+	r.firstHeld = time.Time{}
+	r.mutex.Unlock()
+
+	err := r.sendDigest(subject, body)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err != nil {
+		r.restoreLocked(held, firstHeld)
+		return err
+	}
+	r.sent = append(r.sent, sentAlert{subject: subject, at: now})
+	return nil
+}
+
+// restoreLocked puts back alerts taken for a digest that failed, merging them
+// with any held while it was being sent
+func (r *RateLimiter) restoreLocked(held map[string]*heldAlert, firstHeld time.Time) {
+	for subject, h := range held {
+		newer, ok := r.held[subject]
+		if !ok {
+			r.held[subject] = h
+			continue
+		}
+		newer.count += h.count
+		newer.first = h.first
+	}
+	if r.firstHeld.IsZero() || firstHeld.Before(r.firstHeld) {
+		r.firstHeld = firstHeld
+	}
+}
+
+func (r *RateLimiter) digestLocked() (string, string) {
+	held := make([]*heldAlert, 0, len(r.held))
+	total := 0
+	for _, h := range r.held {
+		held = append(held, h)
+		total += h.count
+	}
+	sort.Slice(held, func(i, j int) bool {
+		return held[i].first.Before(held[j].first)
+	})
+
+	subject := fmt.Sprintf("Alert digest: %d alerts held back by rate limiting", total)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d alerts exceeded the email rate limit since %s and were not sent individually.\n\n",
+		total, display.Time(r.firstHeld))
+	for _, h := range held {
+		fmt.Fprintf(&b, "%dx %s (first %s, last %s)\n", h.count, h.subject,
+			display.ShortTime(h.first), display.ShortTime(h.last))
+	}
+	b.WriteString("\nMost recent message for each:\n")
+	for _, h := range held {
+		fmt.Fprintf(&b, "\n--- %s ---\n%s\n", h.subject, h.lastBody)
+	}
+
+	return subject, b.String()
+}
+
+func (r *RateLimiter) pruneLocked(now time.Time) {
+	cutoff := now.Add(-rateLimitWindow)
+	i := 0
+	for i < len(r.sent) && r.sent[i].at.Before(cutoff) {
+		i++
+	}
+	r.sent = r.sent[i:]
+}
+
+// Start sends due digests in the background until Stop is called
+func (r *RateLimiter) Start() {
+	ticker := time.NewTicker(rateLimitCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.FlushDigest(); err != nil {
+				logger.Error("Failed to send alert digest", "err", err)
+			}
+		}
+	}
+}
+
+// Stop ends the background digests and sends any held alerts straight away
+// in a final digest rather than dropping them
+func (r *RateLimiter) Stop() {
+	r.cancel()
+	if err := r.flush(true); err != nil {
+		logger.Error("Failed to send alert digest on shutdown", "err", err)
+	}
+}
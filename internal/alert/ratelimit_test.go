@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPerSubject(t *testing.T) {
+	var digests []string
+	r := NewRateLimiter(0, 2, func(subject, body string) error {
+		digests = append(digests, subject+"\n"+body)
+		return nil
+	})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		allowed := r.Allow("Disk Alert: sda", "temperature high")
+		if want := i < 2; allowed != want {
+			t.Errorf("Alert %d: expected allowed=%v, got %v", i, want, allowed)
+		}
+	}
+	if !r.Allow("Pool Alert: tank", "degraded") {
+		t.Error("Expected a different subject to be allowed")
+	}
+	if r.Held() != 3 {
+		t.Errorf("Expected 3 held alerts, got %d", r.Held())
+	}
+
+	// Not due until an hour after the first alert was held
+	now = now.Add(30 * time.Minute)
+	if err := r.FlushDigest(); err != nil || len(digests) != 0 {
+		t.Fatalf("Expected no digest yet, got %d (err %v)", len(digests), err)
+	}
+
+	now = now.Add(31 * time.Minute)
+	if err := r.FlushDigest(); err != nil {
+		t.Fatalf("FlushDigest failed: %v", err)
+	}
+	if len(digests) != 1 {
+		t.Fatalf("Expected 1 digest, got %d", len(digests))
+	}
+	if !strings.Contains(digests[0], "3 alerts held back") || !strings.Contains(digests[0], "3x Disk Alert: sda") {
+		t.Errorf("Unexpected digest:\n%s", digests[0])
+	}
+	if r.Held() != 0 {
+		t.Errorf("Expected held alerts to be cleared, got %d", r.Held())
+	}
+
+	// The window has rolled over so the subject is allowed again
+	if !r.Allow("Disk Alert: sda", "temperature high") {
+		t.Error("Expected alert to be allowed after the window passed")
+	}
+}
+
+func TestRateLimiterGlobal(t *testing.T) {
+	r := NewRateLimiter(2, 0, func(subject, body string) error { return nil })
+
+	if !r.Allow("a", "") || !r.Allow("b", "") {
+		t.Fatal("Expected first two alerts to be allowed")
+	}
+	if r.Allow("c", "") {
+		t.Error("Expected third alert to exceed the global limit")
+	}
+}
+
+func TestRateLimiterNeverHoldsCritical(t *testing.T) {
+	r := NewRateLimiter(1, 1, func(subject, body string) error { return nil })
+
+	if !r.Allow("[WARNING] Disk Alert: sda", "") {
+		t.Fatal("Expected the first alert to be allowed")
+	}
+	for _, subject := range []string{"[CRITICAL] Disk Alert: sda", "[EMERGENCY] ZFS Pool Alert: tank", "[CRITICAL] Disk Alert: sda"} {
+		if !r.Allow(subject, "") {
+			t.Errorf("Expected %q to bypass the rate limit", subject)
+		}
+	}
+	if r.Allow("[WARNING] Disk Alert: sdb", "") {
+		t.Error("Expected a WARNING over the limit to be held")
+	}
+}
+
+func TestRateLimiterFlushKeepsAlertsHeldDuringSend(t *testing.T) {
+	var r *RateLimiter
+	fail := true
+	r = NewRateLimiter(1, 0, func(subject, body string) error {
+		// The limiter isn't locked while the digest is sent
+		r.Allow("Disk Alert: sda", "during send")
+		if fail {
+			return errors.New("smtp down")
+		}
+		return nil
+	})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	r.Allow("Disk Alert: sda", "first")
+	r.Allow("Disk Alert: sda", "held")
+	now = now.Add(61 * time.Minute)
+	r.Allow("Pool Alert: tank", "uses up the new window")
+
+	if err := r.FlushDigest(); err == nil {
+		t.Fatal("Expected the failed digest to return an error")
+	}
+	if r.Held() != 2 {
+		t.Fatalf("Expected the failed digest's alert and the one held during it, got %d", r.Held())
+	}
+
+	fail = false
+	if err := r.FlushDigest(); err != nil {
+		t.Fatalf("FlushDigest failed: %v", err)
+	}
+	if r.Held() != 1 {
+		t.Errorf("Expected only the alert held during the send to remain, got %d", r.Held())
+	}
+}
+
+func TestRateLimiterStopSendsHeldAlerts(t *testing.T) {
+	var digests []string
+	r := NewRateLimiter(0, 1, func(subject, body string) error {
+		digests = append(digests, subject)
+		return nil
+	})
+
+	r.Allow("Disk Alert: sda", "")
+	r.Allow("Disk Alert: sda", "")
+	r.Stop()
+
+	if len(digests) != 1 || !strings.Contains(digests[0], "1 alerts held back") {
+		t.Errorf("Expected Stop to send the held alert in a digest, got %v", digests)
+	}
+	if r.Held() != 0 {
+		t.Errorf("Expected no held alerts after Stop, got %d", r.Held())
+	}
+}
@@ -0,0 +1,91 @@
+package alert
+
+import (
+	"strings"
+
+	"zfsrabbit/internal/config"
+)
+
+// alertKinds maps the start of an alert's title, without its severity, to
+// its type for alert routes. Disk health alerts are matched separately as
+// their titles begin with the device type.
+var alertKinds = []struct {
+	prefix string
+	kind   string
+}{
+	{"ZFS Pool Alert: ", "pool"},
+	{"ZFS Pool Capacity Alert: ", "capacity"},
+	{"Disk Path Alert: ", "disk"},
+	{"ZFS Event: ", "event"},
+	{"ZFS Sync Failed", "sync"},
+	{"Replication Lag: ", "replication"},
+	{"Backup SLA Alert: ", "replication"},
+	{"Dataset Renamed: ", "replication"},
+	{"Snapshot Hook Failed: ", "replication"},
+	{"Standby Not Ready: ", "standby"},
+	{"ZFS Property Drift: ", "drift"},
+	{"Check Failed: ", "check"},
+	{"Restore Request", "restore"},
+}
+
+// classify returns an alert's type and severity for routing. Alerts without
+// a severity count as warnings.
+func classify(subject string) (string, string) {
+	severity, title := splitSeverity(subject)
+	if severity == "" {
+		severity = "WARNING"
+	}
+
+	for _, k := range alertKinds {
+		if strings.HasPrefix(title, k.prefix) {
+			return k.kind, severity
+		}
+	}
+	if strings.Contains(title, "Health Alert: ") {
+		return "disk", severity
+	}
+	return "other", severity
+}
+
+// router decides which channels an alert goes to from alert_routes.
+// Channels without routes get every alert they would otherwise.
+type router map[string][]config.AlertRoute
+
+func newRouter(routes []config.AlertRoute) router {
+	r := make(router)
+	for _, route := range routes {
+		r[route.Channel] = append(r[route.Channel], route)
+	}
+	return r
+}
+
+// has reports whether channel has routes of its own
+func (r router) has(channel string) bool {
+	return len(r[channel]) > 0
+}
+
+// allows reports whether an alert of kind and severity may go to channel
+func (r router) allows(channel, kind, severity string) bool {
+	routes := r[channel]
+	if len(routes) == 0 {
+		return true
+	}
+	for _, route := range routes {
+		if route.Matches(kind, severity) {
+			return true
+		}
+	}
+	return false
+}
+
+// routed reports whether an alert may go to channel
+func (m *MultiAlerter) routed(channel, subject string) bool {
+	kind, severity := classify(subject)
+	return m.routes.allows(channel, kind, severity)
+}
+
+// syncRouted reports whether sync successes and starts, which are INFO,
+// may go to channel
+func (m *MultiAlerter) syncRouted(channel string) bool {
+	return m.routes.allows(channel, "sync", "INFO")
+}
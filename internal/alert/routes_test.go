@@ -0,0 +1,108 @@
+package alert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		subject  string
+		kind     string
+		severity string
+	}{
+		{"[WARNING] ZFS Pool Alert: tank", "pool", "WARNING"},
+		{"[EMERGENCY] ZFS Pool Alert: tank", "pool", "EMERGENCY"},
+		{"[CRITICAL] ZFS Pool Capacity Alert: tank", "capacity", "CRITICAL"},
+		{"[WARNING] NVMe SSD Health Alert: nvme0", "disk", "WARNING"},
+		{"[CRITICAL] Disk Path Alert: wwn-1", "disk", "CRITICAL"},
+		{"[WARNING] ZFS Sync Failed", "sync", "WARNING"},
+		{"[WARNING] Backup SLA Alert: tank/db", "replication", "WARNING"},
+		{"[WARNING] Standby Not Ready: dr1", "standby", "WARNING"},
+		{"Restore Request Approved: r1", "restore", "WARNING"},
+		{"[INFO] Test Alert", "other", "INFO"},
+	}
+
+	for _, tt := range tests {
+		kind, severity := classify(tt.subject)
+		if kind != tt.kind || severity != tt.severity {
+			t.Errorf("classify(%q) = %s %s, want %s %s", tt.subject, kind, severity, tt.kind, tt.severity)
+		}
+		if !slices.Contains(config.AlertTypes, kind) {
+			t.Errorf("classify(%q) returned %q, which is missing from config.AlertTypes", tt.subject, kind)
+		}
+	}
+}
+
+func TestAlertRoutes(t *testing.T) {
+	var mutex sync.Mutex
+	received := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		received[r.URL.Path]++
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Slack: config.SlackConfig{Enabled: true, AlertOnSync: true, WebhookURL: server.URL + "/slack"},
+		Webhooks: []config.WebhookConfig{
+			{Name: "pagerduty", URL: server.URL + "/pagerduty"},
+			{Name: "ops", URL: server.URL + "/ops"},
+		},
+		Routes: []config.AlertRoute{
+			{Channel: "webhook:pagerduty", MinSeverity: "critical", Types: []string{"pool"}},
+			{Channel: "webhook:ops", MinSeverity: "critical"},
+			{Channel: "webhook:ops", Types: []string{"sync"}},
+		},
+	}
+	m := NewMultiAlerter(cfg, "")
+	defer m.Stop()
+
+	tests := []struct {
+		name string
+		send func() error
+		want []string
+	}{
+		{"degraded pool", func() error {
+			return m.SendAlert("[WARNING] ZFS Pool Alert: tank", "Pool: tank\nState: DEGRADED\n")
+		}, []string{"/slack"}},
+		{"faulted pool", func() error {
+			return m.SendAlert("[EMERGENCY] ZFS Pool Alert: tank", "Pool: tank\nState: FAULTED\n")
+		}, []string{"/ops", "/pagerduty", "/slack"}},
+		{"critical disk", func() error {
+			return m.SendAlert("[CRITICAL] HDD Health Alert: sda", "Device: /dev/sda\n")
+		}, []string{"/ops", "/slack"}},
+		{"sync success", func() error {
+			return m.SendSyncSuccess("autosnap_1", "tank/vms", time.Minute)
+		}, []string{"/slack"}},
+	}
+
+	for _, tt := range tests {
+		mutex.Lock()
+		clear(received)
+		mutex.Unlock()
+
+		if err := tt.send(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		waitForQueue(t, m)
+
+		mutex.Lock()
+		var got []string
+		for path := range received {
+			got = append(got, path)
+		}
+		mutex.Unlock()
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected delivery to %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
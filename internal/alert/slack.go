@@ -2,16 +2,21 @@ package alert
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
 
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/version"
 )
 
 type SlackAlerter struct {
 	config *config.SlackConfig
+	client *http.Client
 }
 
 type SlackMessage struct {
@@ -48,42 +53,65 @@ type SlackAccessory struct {
 func NewSlackAlerter(cfg *config.SlackConfig) *SlackAlerter {
 	return &SlackAlerter{
 		config: cfg,
+		client: &http.Client{Timeout: sendTimeout},
 	}
 }
 
-func (s *SlackAlerter) SendAlert(subject, body string) error {
+// Enabled reports whether Slack delivery is configured
+func (s *SlackAlerter) Enabled() bool {
+	return s.config.Enabled && s.config.WebhookURL != ""
+}
+
+func (s *SlackAlerter) SendAlert(ctx context.Context, subject, body string) error {
 	if !s.config.Enabled || s.config.WebhookURL == "" {
 		return nil
 	}
 
-	return s.sendMessage(s.formatAlert(subject, body, "warning"))
+	return s.sendMessage(ctx, s.formatAlert(subject, body, "warning"))
 }
 
-func (s *SlackAlerter) SendSyncSuccess(snapshot, dataset string, duration time.Duration) error {
+func (s *SlackAlerter) SendSyncSuccess(ctx context.Context, snapshot, dataset string, duration time.Duration) error {
 	if !s.config.Enabled || !s.config.AlertOnSync || s.config.WebhookURL == "" {
 		return nil
 	}
 
-	title := "✅ ZFS Sync Completed"
-	message := fmt.Sprintf("Successfully replicated snapshot `%s` from dataset `%s`\nDuration: %s",
-		snapshot, dataset, duration.String())
+	title := i18n.T("slack.sync_success_title")
+	message := i18n.T("slack.sync_success", snapshot, dataset, duration.String())
 
-	return s.sendMessage(s.formatAlert(title, message, "good"))
+	return s.sendMessage(ctx, s.formatAlert(title, message, "good"))
 }
 
-func (s *SlackAlerter) SendSyncFailure(snapshot, dataset string, err error) error {
+// SendSyncStart announces a replication with its estimated size and, if
+// known, duration
+func (s *SlackAlerter) SendSyncStart(ctx context.Context, snapshot, dataset string, estimatedBytes int64, eta time.Duration) error {
 	if !s.config.Enabled || !s.config.AlertOnSync || s.config.WebhookURL == "" {
 		return nil
 	}
 
-	title := "❌ ZFS Sync Failed"
-	message := fmt.Sprintf("Failed to replicate snapshot `%s` from dataset `%s`\nError: %s",
-		snapshot, dataset, err.Error())
+	title := i18n.T("slack.sync_start_title")
+	message := i18n.T("slack.sync_start", snapshot, dataset, display.Bytes(estimatedBytes))
+	if eta > 0 {
+		message += "\n" + i18n.T("slack.sync_start_eta", eta.String())
+	}
 
-	return s.sendMessage(s.formatAlert(title, message, "danger"))
+	return s.sendMessage(ctx, s.formatAlert(title, message, "#439FE0"))
 }
 
-func (s *SlackAlerter) SendSystemStatus(status map[string]interface{}) error {
+func (s *SlackAlerter) SendSyncFailure(ctx context.Context, snapshot, dataset string, err error) error {
+	if !s.config.Enabled || !s.config.AlertOnSync || s.config.WebhookURL == "" {
+		return nil
+	}
+
+	title := i18n.T("slack.sync_failure_title")
+	message := i18n.T("slack.sync_failure", snapshot, dataset, err.Error())
+	if runbook := i18n.Runbook("alert.sync.runbook"); runbook != "" {
+		message += "\n" + runbook
+	}
+
+	return s.sendMessage(ctx, s.formatAlert(title, message, "danger"))
+}
+
+func (s *SlackAlerter) SendSystemStatus(ctx context.Context, status map[string]interface{}) error {
 	if !s.config.Enabled || s.config.WebhookURL == "" {
 		return nil
 	}
@@ -138,8 +166,8 @@ func (s *SlackAlerter) SendSystemStatus(status map[string]interface{}) error {
 				}
 				fields = append(fields, SlackField{
 					Type: "mrkdwn",
-					Text: fmt.Sprintf("*%s %s:*\n%s %d°C", emoji, disk,
-						map[bool]string{true: "Healthy", false: "Issues"}[healthy], temp),
+					Text: fmt.Sprintf("*%s %s:*\n%s %s", emoji, disk,
+						map[bool]string{true: "Healthy", false: "Issues"}[healthy], display.Temperature(temp)),
 				})
 			}
 		}
@@ -157,7 +185,7 @@ func (s *SlackAlerter) SendSystemStatus(status map[string]interface{}) error {
 		Fields: []SlackField{
 			{
 				Type: "mrkdwn",
-				Text: fmt.Sprintf("Updated: %s", time.Now().Format("2006-01-02 15:04:05")),
+				Text: i18n.T("slack.updated", display.Time(time.Now())),
 			},
 		},
 	})
@@ -169,7 +197,7 @@ func (s *SlackAlerter) SendSystemStatus(status map[string]interface{}) error {
 		Blocks:    blocks,
 	}
 
-	return s.sendMessage(msg)
+	return s.sendMessage(ctx, msg)
 }
 
 func (s *SlackAlerter) formatAlert(title, body, color string) SlackMessage {
@@ -204,7 +232,11 @@ func (s *SlackAlerter) formatAlert(title, body, color string) SlackMessage {
 			Fields: []SlackField{
 				{
 					Type: "mrkdwn",
-					Text: fmt.Sprintf("Time: %s", time.Now().Format("2006-01-02 15:04:05")),
+					Text: i18n.T("slack.time", display.Time(time.Now())),
+				},
+				{
+					Type: "mrkdwn",
+					Text: i18n.T("slack.version", version.Get().Short()),
 				},
 			},
 		},
@@ -218,13 +250,20 @@ func (s *SlackAlerter) formatAlert(title, body, color string) SlackMessage {
 	}
 }
 
-func (s *SlackAlerter) sendMessage(msg SlackMessage) error {
+func (s *SlackAlerter) sendMessage(ctx context.Context, msg SlackMessage) error {
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Slack message: %w", err)
 	}
 
-	resp, err := http.Post(s.config.WebhookURL, "application/json", bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.WebhookURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send Slack message: %w", err)
 	}
@@ -239,5 +278,5 @@ func (s *SlackAlerter) sendMessage(msg SlackMessage) error {
 
 // Implement the Alerter interface
 func (s *SlackAlerter) TestConnection() error {
-	return s.SendAlert("Test Alert", "This is a test message from ZFSRabbit to verify Slack integration.")
+	return s.SendAlert(context.Background(), "Test Alert", "This is a test message from ZFSRabbit to verify Slack integration.")
 }
@@ -1,6 +1,7 @@
 package alert
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -52,7 +53,7 @@ func TestSlackAlerterSendAlert(t *testing.T) {
 
 	alerter := NewSlackAlerter(cfg)
 
-	err := alerter.SendAlert("Test Alert", "This is a test alert message")
+	err := alerter.SendAlert(context.Background(), "Test Alert", "This is a test alert message")
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -110,7 +111,7 @@ func TestSlackAlerterSendSyncSuccess(t *testing.T) {
 	alerter := NewSlackAlerter(cfg)
 
 	duration := 5 * time.Minute
-	err := alerter.SendSyncSuccess("test-snapshot", "tank/test", duration)
+	err := alerter.SendSyncSuccess(context.Background(), "test-snapshot", "tank/test", duration)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -151,7 +152,7 @@ func TestSlackAlerterSendSyncFailure(t *testing.T) {
 	alerter := NewSlackAlerter(cfg)
 
 	testError := errors.New("sync failed")
-	err := alerter.SendSyncFailure("test-snapshot", "tank/test", testError)
+	err := alerter.SendSyncFailure(context.Background(), "test-snapshot", "tank/test", testError)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -211,7 +212,7 @@ func TestSlackAlerterSendSystemStatus(t *testing.T) {
 		},
 	}
 
-	err := alerter.SendSystemStatus(status)
+	err := alerter.SendSystemStatus(context.Background(), status)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -262,17 +263,17 @@ func TestSlackAlerterDisabled(t *testing.T) {
 	alerter := NewSlackAlerter(cfg)
 
 	// All methods should return early without error when disabled
-	err := alerter.SendAlert("Test", "Test message")
+	err := alerter.SendAlert(context.Background(), "Test", "Test message")
 	if err != nil {
 		t.Errorf("Expected no error when disabled, got: %v", err)
 	}
 
-	err = alerter.SendSyncSuccess("test", "dataset", time.Minute)
+	err = alerter.SendSyncSuccess(context.Background(), "test", "dataset", time.Minute)
 	if err != nil {
 		t.Errorf("Expected no error when disabled, got: %v", err)
 	}
 
-	err = alerter.SendSyncFailure("test", "dataset", errors.New("test"))
+	err = alerter.SendSyncFailure(context.Background(), "test", "dataset", errors.New("test"))
 	if err != nil {
 		t.Errorf("Expected no error when disabled, got: %v", err)
 	}
@@ -287,7 +288,7 @@ func TestSlackAlerterEmptyWebhookURL(t *testing.T) {
 	alerter := NewSlackAlerter(cfg)
 
 	// Should return early without error when no webhook URL
-	err := alerter.SendAlert("Test", "Test message")
+	err := alerter.SendAlert(context.Background(), "Test", "Test message")
 	if err != nil {
 		t.Errorf("Expected no error with empty webhook URL, got: %v", err)
 	}
@@ -307,7 +308,7 @@ func TestSlackAlerterHTTPError(t *testing.T) {
 
 	alerter := NewSlackAlerter(cfg)
 
-	err := alerter.SendAlert("Test", "Test message")
+	err := alerter.SendAlert(context.Background(), "Test", "Test message")
 	if err == nil {
 		t.Error("Expected error from HTTP 500 response")
 	}
@@ -0,0 +1,297 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/version"
+)
+
+// slackAPIBase is the Slack Web API. Threads need it because an incoming
+// webhook can't say which message it posted.
+const slackAPIBase = "https://slack.com/api"
+
+// maxThreadBatch is the most queued alerts combined into one thread reply
+const maxThreadBatch = 10
+
+// maxSectionText keeps each alert within Slack's 3000 character section limit
+const maxSectionText = 2900
+
+// SlackIncident is the Slack thread alerts are posted into while they keep
+// arriving within the incident window of each other
+type SlackIncident struct {
+	Channel  string    `json:"channel"`   // Channel ID returned by Slack, needed to edit the parent
+	ThreadTS string    `json:"thread_ts"` // Timestamp of the parent message
+	Subject  string    `json:"subject"`   // Alert that opened the incident and is the parent
+	Body     string    `json:"body"`
+	Started  time.Time `json:"started"`
+	Last     time.Time `json:"last"` // When the latest alert in the thread was raised
+	Alerts   int       `json:"alerts"`
+	Severity string    `json:"severity,omitempty"` // Worst severity so far
+}
+
+// IncidentStore keeps the open incident across restarts, so alerts after a
+// restart still join its thread
+type IncidentStore interface {
+	SlackIncident() *SlackIncident
+	SetSlackIncident(incident *SlackIncident)
+}
+
+// threadedAlert is an alert waiting to be posted
+type threadedAlert struct {
+	subject string
+	body    string
+	raised  time.Time
+}
+
+// SlackThreads posts alerts in one Slack thread per incident instead of as
+// separate messages. The first alert of an incident is posted as usual and
+// becomes the parent; later ones are replies beneath it, and the parent is
+// edited to show how many there have been. Alerts queued during a storm are
+// combined into a single reply.
+type SlackThreads struct {
+	config  *config.SlackConfig
+	alerter *SlackAlerter // Formats messages the same way as the webhook
+	client  *http.Client
+	apiBase string
+	now     func() time.Time
+
+	mutex    sync.Mutex
+	incident *SlackIncident
+	store    IncidentStore
+	pending  []threadedAlert
+}
+
+func NewSlackThreads(cfg *config.SlackConfig, alerter *SlackAlerter) *SlackThreads {
+	return &SlackThreads{
+		config:  cfg,
+		alerter: alerter,
+		client:  &http.Client{Timeout: sendTimeout},
+		apiBase: slackAPIBase,
+		now:     time.Now,
+	}
+}
+
+// SetStore persists the open incident in store, picking up the one saved there
+func (t *SlackThreads) SetStore(store IncidentStore) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.store = store
+	t.incident = store.SlackIncident()
+}
+
+// Incident returns a copy of the open incident, or nil
+func (t *SlackThreads) Incident() *SlackIncident {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.incident == nil {
+		return nil
+	}
+	incident := *t.incident
+	return &incident
+}
+
+// add queues an alert for the next send and returns how many are queued
+func (t *SlackThreads) add(subject, body string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pending = append(t.pending, threadedAlert{subject: subject, body: body, raised: t.now()})
+	return len(t.pending)
+}
+
+// take removes and returns the queued alerts
+func (t *SlackThreads) take() []threadedAlert {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	batch := t.pending
+	t.pending = nil
+	return batch
+}
+
+// send posts a batch of alerts: into the open incident's thread as a single
+// reply, or as the parent of a new incident if the window since the last
+// alert has passed. Alerts that couldn't be posted are returned with the
+// error so the caller can fall back to the webhook.
+func (t *SlackThreads) send(ctx context.Context, batch []threadedAlert) ([]threadedAlert, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	incident := t.incident
+	if incident == nil || batch[0].raised.Sub(incident.Last) > t.config.IncidentWindow {
+		first := batch[0]
+		channel, ts, err := t.post(ctx, t.alerter.formatAlert(first.subject, first.body, "warning"), "")
+		if err != nil {
+			return batch, err
+		}
+		severity, _ := splitSeverity(first.subject)
+		incident = &SlackIncident{
+			Channel:  channel,
+			ThreadTS: ts,
+			Subject:  first.subject,
+			Body:     first.body,
+			Started:  first.raised,
+			Last:     first.raised,
+			Alerts:   1,
+			Severity: severity,
+		}
+		t.saveLocked(incident)
+
+		batch = batch[1:]
+		if len(batch) == 0 {
+			return nil, nil
+		}
+	}
+
+	if _, _, err := t.post(ctx, t.reply(batch), incident.ThreadTS); err != nil {
+		return batch, err
+	}
+	for _, alert := range batch {
+		severity, _ := splitSeverity(alert.subject)
+		// Syslog severities are lower for worse alerts
+		if alertSyslogSeverity(severity) < alertSyslogSeverity(incident.Severity) {
+			incident.Severity = severity
+		}
+	}
+	incident.Alerts += len(batch)
+	incident.Last = batch[len(batch)-1].raised
+	t.saveLocked(incident)
+
+	if err := t.update(ctx, incident); err != nil {
+		logger.Error("Failed to update Slack incident summary", "err", err)
+	}
+	return nil, nil
+}
+
+func (t *SlackThreads) saveLocked(incident *SlackIncident) {
+	t.incident = incident
+	if t.store != nil {
+		saved := *incident
+		t.store.SetSlackIncident(&saved)
+	}
+}
+
+// reply is a thread reply for one or more alerts
+func (t *SlackThreads) reply(batch []threadedAlert) SlackMessage {
+	if len(batch) == 1 {
+		return t.alerter.formatAlert(batch[0].subject, batch[0].body, "warning")
+	}
+
+	blocks := []SlackBlock{{
+		Type: "header",
+		Text: &SlackText{Type: "plain_text", Text: fmt.Sprintf("⚠️ %d more alerts", len(batch))},
+	}}
+	for _, alert := range batch {
+		text := fmt.Sprintf("*%s* (%s)\n%s", alert.subject, display.Time(alert.raised), alert.body)
+		if len(text) > maxSectionText {
+			text = text[:maxSectionText] + "…"
+		}
+		blocks = append(blocks, SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text}})
+	}
+	return SlackMessage{Blocks: blocks}
+}
+
+// update edits the parent message to summarise the incident so far
+func (t *SlackThreads) update(ctx context.Context, incident *SlackIncident) error {
+	msg := t.alerter.formatAlert(incident.Subject, incident.Body, "warning")
+	summary := fmt.Sprintf("🧵 *%d alerts* in this incident since %s, latest at %s", incident.Alerts,
+		display.Time(incident.Started), display.Time(incident.Last))
+	if incident.Severity != "" {
+		summary += fmt.Sprintf(", worst %s", incident.Severity)
+	}
+	msg.Blocks = append(msg.Blocks, SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: summary}})
+
+	return t.call(ctx, "chat.update", map[string]interface{}{
+		"channel": incident.Channel,
+		"ts":      incident.ThreadTS,
+		"text":    incident.Subject,
+		"blocks":  msg.Blocks,
+	}, nil)
+}
+
+// post sends a message, as a reply if threadTS is set, and returns the
+// channel ID and timestamp Slack gave it
+func (t *SlackThreads) post(ctx context.Context, msg SlackMessage, threadTS string) (string, string, error) {
+	body := map[string]interface{}{
+		"channel": t.config.Channel,
+		"text":    messageText(msg),
+		"blocks":  msg.Blocks,
+	}
+	if threadTS != "" {
+		body["thread_ts"] = threadTS
+	}
+	if t.config.Username != "" {
+		body["username"] = t.config.Username
+	}
+	if t.config.IconEmoji != "" {
+		body["icon_emoji"] = t.config.IconEmoji
+	}
+
+	var result struct {
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}
+	if err := t.call(ctx, "chat.postMessage", body, &result); err != nil {
+		return "", "", err
+	}
+	return result.Channel, result.TS, nil
+}
+
+// call invokes a Slack Web API method, decoding the response into result
+func (t *SlackThreads) call(ctx context.Context, method string, body, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiBase+"/"+method, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+t.config.BotToken)
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack %s failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("slack %s returned status %d", method, resp.StatusCode)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil || !status.OK {
+		return fmt.Errorf("slack %s failed: %s", method, status.Error)
+	}
+	if result != nil {
+		return json.Unmarshal(raw, result)
+	}
+	return nil
+}
+
+// messageText is the notification text for a message made of blocks
+func messageText(msg SlackMessage) string {
+	for _, block := range msg.Blocks {
+		if block.Type == "header" && block.Text != nil {
+			return block.Text.Text
+		}
+	}
+	return strings.TrimSpace(msg.Text)
+}
@@ -0,0 +1,129 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+// memoryIncidents is an IncidentStore kept in memory
+type memoryIncidents struct {
+	incident *SlackIncident
+}
+
+func (s *memoryIncidents) SlackIncident() *SlackIncident            { return s.incident }
+func (s *memoryIncidents) SetSlackIncident(incident *SlackIncident) { s.incident = incident }
+
+func TestSlackThreadsBatchIncident(t *testing.T) {
+	type call struct {
+		method   string
+		threadTS string
+		blocks   int
+	}
+	var calls []call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			fmt.Fprint(w, `{"ok":false,"error":"invalid_auth"}`)
+			return
+		}
+		var body struct {
+			ThreadTS string            `json:"thread_ts"`
+			Blocks   []json.RawMessage `json:"blocks"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, call{method: r.URL.Path[1:], threadTS: body.ThreadTS, blocks: len(body.Blocks)})
+		fmt.Fprintf(w, `{"ok":true,"channel":"C123","ts":"1700000000.%06d"}`, len(calls))
+	}))
+	defer server.Close()
+
+	cfg := &config.SlackConfig{Enabled: true, WebhookURL: "https://hooks.slack.com/services/x", BotToken: "xoxb-test", Channel: "#storage", IncidentWindow: 30 * time.Minute}
+	threads := NewSlackThreads(cfg, NewSlackAlerter(cfg))
+	threads.apiBase = server.URL
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	threads.now = func() time.Time { return now }
+	store := &memoryIncidents{}
+	threads.SetStore(store)
+
+	// The pool fault opens the incident
+	threads.add("[CRITICAL] Pool tank DEGRADED", "Pool: tank")
+	if failed, err := threads.send(context.Background(), threads.take()); err != nil || len(failed) > 0 {
+		t.Fatalf("send failed: %v", err)
+	}
+	if len(calls) != 1 || calls[0].method != "chat.postMessage" || calls[0].threadTS != "" {
+		t.Fatalf("Expected a parent message, got %+v", calls)
+	}
+
+	// Disk alerts queued behind it go out as one reply, then the parent is updated
+	now = now.Add(time.Minute)
+	for _, disk := range []string{"sda", "sdb", "sdc"} {
+		threads.add("[WARNING] SMART Warning: "+disk, "Device: "+disk)
+	}
+	if failed, err := threads.send(context.Background(), threads.take()); err != nil || len(failed) > 0 {
+		t.Fatalf("send failed: %v", err)
+	}
+	if len(calls) != 3 {
+		t.Fatalf("Expected a reply and an update, got %+v", calls)
+	}
+	if calls[1].method != "chat.postMessage" || calls[1].threadTS != "1700000000.000001" || calls[1].blocks != 4 {
+		t.Errorf("Expected one reply with a header and three alerts in the thread, got %+v", calls[1])
+	}
+	if calls[2].method != "chat.update" {
+		t.Errorf("Expected the parent to be updated, got %+v", calls[2])
+	}
+
+	incident := store.SlackIncident()
+	if incident == nil || incident.Channel != "C123" || incident.ThreadTS != "1700000000.000001" || incident.Alerts != 4 || incident.Severity != "CRITICAL" || !incident.Last.Equal(now) {
+		t.Fatalf("Unexpected stored incident %+v", incident)
+	}
+
+	// A restart carries on the same thread
+	restarted := NewSlackThreads(cfg, NewSlackAlerter(cfg))
+	restarted.apiBase = server.URL
+	restarted.now = func() time.Time { return now }
+	restarted.SetStore(store)
+	now = now.Add(10 * time.Minute)
+	restarted.add("[WARNING] SMART Warning: sdd", "Device: sdd")
+	restarted.send(context.Background(), restarted.take())
+	if calls[3].threadTS != "1700000000.000001" {
+		t.Errorf("Expected the reply to join the stored thread, got %+v", calls[3])
+	}
+
+	// Once the window passes a new incident starts
+	now = now.Add(31 * time.Minute)
+	restarted.add("[INFO] Scrub finished", "Pool: tank")
+	restarted.send(context.Background(), restarted.take())
+	last := calls[len(calls)-1]
+	if last.method != "chat.postMessage" || last.threadTS != "" {
+		t.Errorf("Expected a new parent message after the window, got %+v", last)
+	}
+	if incident := restarted.Incident(); incident.Alerts != 1 || incident.Severity != "INFO" {
+		t.Errorf("Expected a fresh incident, got %+v", incident)
+	}
+}
+
+func TestSlackThreadsReturnsUnpostedAlerts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":false,"error":"channel_not_found"}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.SlackConfig{Enabled: true, BotToken: "xoxb-test", Channel: "#missing", IncidentWindow: 30 * time.Minute}
+	threads := NewSlackThreads(cfg, NewSlackAlerter(cfg))
+	threads.apiBase = server.URL
+
+	threads.add("[WARNING] A", "a")
+	threads.add("[WARNING] B", "b")
+	failed, err := threads.send(context.Background(), threads.take())
+	if err == nil || len(failed) != 2 {
+		t.Errorf("Expected both alerts back with an error, got %d, %v", len(failed), err)
+	}
+	if threads.Incident() != nil {
+		t.Error("Expected no incident to be opened")
+	}
+}
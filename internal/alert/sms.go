@@ -0,0 +1,220 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/version"
+)
+
+const (
+	twilioAPIBase = "https://api.twilio.com"
+	smsMaxLength  = 320 // Two concatenated SMS segments
+)
+
+// SMSAlerter escalates EMERGENCY alerts by SMS, and optionally a voice call,
+// to whichever recipients are on duty. It is a last resort for when chat and
+// email are being ignored, so nothing else is routed to it.
+type SMSAlerter struct {
+	config  *config.SMSConfig
+	client  *http.Client
+	apiBase string
+	now     func() time.Time
+}
+
+func NewSMSAlerter(cfg *config.SMSConfig) *SMSAlerter {
+	return &SMSAlerter{
+		config:  cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		apiBase: twilioAPIBase,
+		now:     time.Now,
+	}
+}
+
+// Enabled reports whether SMS escalation is configured
+func (s *SMSAlerter) Enabled() bool {
+	return s.config.Enabled && len(s.config.Recipients) > 0
+}
+
+// isEmergency reports whether an alert warrants paging someone: anything
+// raised at EMERGENCY severity, which includes pools that have FAULTED or
+// worse. The severity is read from the subject rather than the body, which
+// is in the configured locale.
+func isEmergency(subject string) bool {
+	severity, _ := splitSeverity(subject)
+	return severity == "EMERGENCY"
+}
+
+// SendAlert messages every recipient currently on duty
+func (s *SMSAlerter) SendAlert(ctx context.Context, subject, body string) error {
+	recipients := s.OnDuty()
+	if len(recipients) == 0 {
+		logger.Warn("No SMS recipients on duty for emergency alert", "subject", subject)
+	}
+
+	var errs []string
+	for _, recipient := range recipients {
+		if err := s.SendTo(ctx, recipient, subject, body); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", recipientName(recipient), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("sms escalation failed for %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// OnDuty returns the recipients whose schedule covers now
+func (s *SMSAlerter) OnDuty() []config.SMSRecipient {
+	now := s.now()
+	var recipients []config.SMSRecipient
+	for _, recipient := range s.config.Recipients {
+		if onDuty(recipient, now) {
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients
+}
+
+// SendTo messages one recipient, and calls them too when voice is on
+func (s *SMSAlerter) SendTo(ctx context.Context, recipient config.SMSRecipient, subject, body string) error {
+	message := smsText(subject, body)
+	if err := s.sendSMS(ctx, recipient.Phone, message); err != nil {
+		return err
+	}
+	if s.config.Voice && s.config.Provider == "twilio" {
+		if err := s.placeCall(ctx, recipient.Phone, message); err != nil {
+			return fmt.Errorf("voice: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SMSAlerter) TestConnection() error {
+	return s.SendAlert(context.Background(), "Test Alert", "This is a test message from ZFSRabbit to verify SMS escalation.")
+}
+
+func (s *SMSAlerter) sendSMS(ctx context.Context, to, message string) error {
+	if s.config.Provider == "gateway" {
+		payload, err := json.Marshal(map[string]string{"to": to, "message": message})
+		if err != nil {
+			return err
+		}
+		return s.post(ctx, s.config.GatewayURL, "application/json", bytes.NewReader(payload), false)
+	}
+
+	form := url.Values{"To": {to}, "From": {s.config.From}, "Body": {message}}
+	return s.post(ctx, s.twilioURL("Messages.json"), "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), true)
+}
+
+// placeCall reads the message out with Twilio's text-to-speech
+func (s *SMSAlerter) placeCall(ctx context.Context, to, message string) error {
+	var twiml strings.Builder
+	twiml.WriteString("<Response><Say>")
+	if err := xml.EscapeText(&twiml, []byte(message)); err != nil {
+		return err
+	}
+	twiml.WriteString("</Say></Response>")
+
+	form := url.Values{"To": {to}, "From": {s.config.From}, "Twiml": {twiml.String()}}
+	return s.post(ctx, s.twilioURL("Calls.json"), "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), true)
+}
+
+func (s *SMSAlerter) twilioURL(resource string) string {
+	return fmt.Sprintf("%s/2010-04-01/Accounts/%s/%s", s.apiBase, url.PathEscape(s.config.AccountSID), resource)
+}
+
+func (s *SMSAlerter) post(ctx context.Context, endpoint, contentType string, body io.Reader, twilioAuth bool) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", version.UserAgent())
+	if twilioAuth {
+		req.SetBasicAuth(s.config.AccountSID, s.config.AuthToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+// onDuty reports whether a recipient's schedule covers now. Recipients
+// without days or hours are always on duty, and hours that start and end at
+// the same time, such as 00:00-00:00, cover the whole day.
+func onDuty(r config.SMSRecipient, now time.Time) bool {
+	if r.Timezone != "" {
+		if loc, err := time.LoadLocation(r.Timezone); err == nil {
+			now = now.In(loc)
+		}
+	}
+
+	if len(r.Days) > 0 {
+		today := strings.ToLower(now.Weekday().String()[:3])
+		found := false
+		for _, day := range r.Days {
+			if strings.ToLower(day) == today {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if r.Hours == "" {
+		return true
+	}
+	start, end, err := config.ParseHoursRange(r.Hours)
+	if err != nil {
+		return true // Validated at load; fail open rather than page nobody
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start == end {
+		return true
+	}
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end // Wraps past midnight
+}
+
+func smsText(subject, body string) string {
+	text := "[ZFSRabbit] " + subject
+	if first := strings.TrimSpace(strings.SplitN(strings.TrimSpace(body), "\n\n", 2)[0]); first != "" {
+		text += "\n" + first
+	}
+	return truncate(text, smsMaxLength)
+}
+
+// smsChannel names a recipient's outbox queue, so a retry only pages the
+// recipients whose message failed
+func smsChannel(r config.SMSRecipient) string {
+	return ChannelSMS + ":" + recipientName(r)
+}
+
+func recipientName(r config.SMSRecipient) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Phone
+}
@@ -0,0 +1,191 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestOnDuty(t *testing.T) {
+	// Wednesday 2024-01-17
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 1, 17, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		recipient config.SMSRecipient
+		now       time.Time
+		want      bool
+	}{
+		{"always", config.SMSRecipient{}, at(3, 0), true},
+		{"inside hours", config.SMSRecipient{Hours: "09:00-17:00"}, at(12, 0), true},
+		{"outside hours", config.SMSRecipient{Hours: "09:00-17:00"}, at(17, 0), false},
+		{"wrap late", config.SMSRecipient{Hours: "22:00-06:00"}, at(23, 30), true},
+		{"wrap early", config.SMSRecipient{Hours: "22:00-06:00"}, at(5, 59), true},
+		{"wrap outside", config.SMSRecipient{Hours: "22:00-06:00"}, at(12, 0), false},
+		{"all day", config.SMSRecipient{Hours: "00:00-00:00"}, at(3, 0), true},
+		{"all day from a shift change", config.SMSRecipient{Hours: "08:00-08:00"}, at(7, 59), true},
+		{"day matches", config.SMSRecipient{Days: []string{"mon", "Wed"}}, at(12, 0), true},
+		{"day excluded", config.SMSRecipient{Days: []string{"sat", "sun"}}, at(12, 0), false},
+		// 12:00 UTC is 21:00 in Tokyo
+		{"timezone", config.SMSRecipient{Hours: "20:00-22:00", Timezone: "Asia/Tokyo"}, at(12, 0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onDuty(tt.recipient, tt.now); got != tt.want {
+				t.Errorf("onDuty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsEmergency(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    bool
+	}{
+		{"[EMERGENCY] NVMe nvme0 read-only", true},
+		{"[CRITICAL] Disk sda failing", false},
+		{"[EMERGENCY] ZFS Pool Alert: tank", true},
+		{"[WARNING] ZFS Pool Alert: tank", false},
+		{"Sync failed", false},
+	}
+
+	for _, tt := range tests {
+		if got := isEmergency(tt.subject); got != tt.want {
+			t.Errorf("isEmergency(%q) = %v, want %v", tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestSMSAlerterTwilio(t *testing.T) {
+	var mu sync.Mutex
+	var paths, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "AC123" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, r.Form.Get("Body")+r.Form.Get("Twiml"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	alerter := NewSMSAlerter(&config.SMSConfig{
+		Enabled:    true,
+		Provider:   "twilio",
+		AccountSID: "AC123",
+		AuthToken:  "secret",
+		From:       "+15550000000",
+		Voice:      true,
+		Recipients: []config.SMSRecipient{
+			{Name: "day", Phone: "+15551111111", Hours: "09:00-17:00"},
+			{Name: "night", Phone: "+15552222222", Hours: "17:00-09:00"},
+		},
+	})
+	alerter.apiBase = server.URL
+	alerter.now = func() time.Time { return time.Date(2024, 1, 17, 3, 0, 0, 0, time.Local) }
+
+	if err := alerter.SendAlert(context.Background(), "[EMERGENCY] Pool tank <faulted>", "State: FAULTED"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("Expected one SMS and one call for the night recipient, got %v", paths)
+	}
+	if paths[0] != "/2010-04-01/Accounts/AC123/Messages.json" || paths[1] != "/2010-04-01/Accounts/AC123/Calls.json" {
+		t.Errorf("Unexpected request paths: %v", paths)
+	}
+	if !strings.Contains(bodies[1], "&lt;faulted&gt;") {
+		t.Errorf("Expected escaped TwiML, got %q", bodies[1])
+	}
+}
+
+func TestSMSAlerterGateway(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		got = string(data)
+	}))
+	defer server.Close()
+
+	alerter := NewSMSAlerter(&config.SMSConfig{
+		Enabled:    true,
+		Provider:   "gateway",
+		GatewayURL: server.URL,
+		Recipients: []config.SMSRecipient{{Phone: "+15551111111"}},
+	})
+
+	if err := alerter.SendAlert(context.Background(), "[EMERGENCY] test", "body"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if !strings.Contains(got, `"to":"+15551111111"`) {
+		t.Errorf("Unexpected gateway payload: %s", got)
+	}
+}
+
+func TestSMSQueuesPerRecipient(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	bobDown := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct{ To string }
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		if payload.To == "+15552222222" && bobDown {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		received[payload.To]++
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{SMS: config.SMSConfig{
+		Enabled:    true,
+		Provider:   "gateway",
+		GatewayURL: server.URL,
+		Recipients: []config.SMSRecipient{
+			{Name: "alice", Phone: "+15551111111"},
+			{Name: "bob", Phone: "+15552222222"},
+		},
+	}}
+	m := NewMultiAlerter(cfg, "")
+	defer m.Stop()
+
+	if err := m.SendAlert("[EMERGENCY] ZFS Pool Alert: tank", "State: FAULTED"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	waitForQueue(t, m)
+
+	pending := m.PendingAlerts()
+	if pending["sms:bob"] != 1 || pending["sms:alice"] != 0 || pending[ChannelSMS] != 0 {
+		t.Fatalf("Expected only bob's message queued, got %v", pending)
+	}
+
+	mu.Lock()
+	bobDown = false
+	mu.Unlock()
+	m.outbox.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if received["+15551111111"] != 1 || received["+15552222222"] != 1 {
+		t.Errorf("Expected the retry to page only bob, got %v", received)
+	}
+}
@@ -0,0 +1,298 @@
+package alert
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/i18n"
+)
+
+// Trap and object identifiers, relative to the configured enterprise OID
+const (
+	trapPoolState     = "1.1"
+	trapDiskFailure   = "1.2"
+	trapBackupFailure = "1.3"
+	trapAlert         = "1.4"
+
+	objSubject  = "2.1"
+	objDetail   = "2.2"
+	objPool     = "2.3"
+	objState    = "2.4"
+	objDisk     = "2.5"
+	objDataset  = "2.6"
+	objSnapshot = "2.7"
+	objSeverity = "2.8"
+)
+
+const (
+	oidSysUpTime    = "1.3.6.1.2.1.1.3.0"
+	oidSnmpTrapOID  = "1.3.6.1.6.3.1.1.4.1.0"
+	snmpMaxDetail   = 1024
+	snmpDefaultPort = "162"
+)
+
+// SNMPAlerter emits SNMPv2c traps for a network management system
+type SNMPAlerter struct {
+	config    *config.SNMPConfig
+	started   time.Time
+	requestID int32
+}
+
+func NewSNMPAlerter(cfg *config.SNMPConfig) *SNMPAlerter {
+	return &SNMPAlerter{
+		config:  cfg,
+		started: time.Now(),
+	}
+}
+
+// Enabled reports whether SNMP traps are configured
+func (s *SNMPAlerter) Enabled() bool {
+	return s.config.Enabled && len(s.config.Targets) > 0
+}
+
+// SendAlert sends a trap typed by the kind of alert: pool state, disk failure,
+// or a generic alert carrying the subject and body
+func (s *SNMPAlerter) SendAlert(subject, body string) error {
+	severity, title := splitSeverity(subject)
+
+	switch {
+	case strings.HasPrefix(title, "ZFS Pool Alert: "):
+		pool := strings.TrimPrefix(title, "ZFS Pool Alert: ")
+		return s.sendTrap(trapPoolState, []varBind{
+			{objPool, pool},
+			{objState, bodyField(body, i18n.T("alert.pool.state_field"))},
+			{objDetail, truncate(body, snmpMaxDetail)},
+		})
+	case strings.Contains(title, "Health Alert: ") || strings.HasPrefix(title, "Disk Path Alert: "):
+		disk := title[strings.LastIndex(title, ": ")+2:]
+		return s.sendTrap(trapDiskFailure, []varBind{
+			{objDisk, disk},
+			{objSeverity, severity},
+			{objSubject, subject},
+			{objDetail, truncate(body, snmpMaxDetail)},
+		})
+	default:
+		return s.sendTrap(trapAlert, []varBind{
+			{objSeverity, severity},
+			{objSubject, subject},
+			{objDetail, truncate(body, snmpMaxDetail)},
+		})
+	}
+}
+
+func (s *SNMPAlerter) SendSyncFailure(snapshot, dataset string, err error) error {
+	return s.sendTrap(trapBackupFailure, []varBind{
+		{objDataset, dataset},
+		{objSnapshot, snapshot},
+		{objDetail, truncate(err.Error(), snmpMaxDetail)},
+	})
+}
+
+func (s *SNMPAlerter) TestConnection() error {
+	return s.SendAlert("Test Alert", "This is a test trap from ZFSRabbit to verify SNMP integration.")
+}
+
+// varBind is an object suffix and its string value
+type varBind struct {
+	object string
+	value  string
+}
+
+func (s *SNMPAlerter) sendTrap(trap string, binds []varBind) error {
+	if !s.Enabled() {
+		return fmt.Errorf("snmp configuration incomplete")
+	}
+
+	packet, err := s.buildTrap(trap, binds)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, target := range s.config.Targets {
+		if err := sendUDP(target, packet); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", target, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send trap to %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func sendUDP(target string, packet []byte) error {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, snmpDefaultPort)
+	}
+
+	conn, err := net.DialTimeout("udp", target, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(packet)
+	return err
+}
+
+// buildTrap encodes an SNMPv2-Trap-PDU message (RFC 3416) in BER
+func (s *SNMPAlerter) buildTrap(trap string, binds []varBind) ([]byte, error) {
+	base := strings.TrimPrefix(s.config.EnterpriseOID, ".")
+
+	uptime := uint32(time.Since(s.started) / (10 * time.Millisecond))
+
+	sysUpTime, err := berOID(oidSysUpTime)
+	if err != nil {
+		return nil, err
+	}
+	trapOIDName, err := berOID(oidSnmpTrapOID)
+	if err != nil {
+		return nil, err
+	}
+	trapOID, err := berOID(base + "." + trap)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enterprise_oid: %w", err)
+	}
+
+	list := [][]byte{
+		berTLV(0x30, sysUpTime, berTLV(0x43, berUint(uint64(uptime)))),
+		berTLV(0x30, trapOIDName, trapOID),
+	}
+	for _, bind := range binds {
+		name, err := berOID(base + "." + bind.object)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, berTLV(0x30, name, berTLV(0x04, []byte(bind.value))))
+	}
+
+	requestID := atomic.AddInt32(&s.requestID, 1)
+	pdu := berTLV(0xa7,
+		berInt(int64(requestID)),
+		berInt(0), // error-status
+		berInt(0), // error-index
+		berTLV(0x30, list...),
+	)
+
+	return berTLV(0x30,
+		berInt(1), // SNMPv2c
+		berTLV(0x04, []byte(s.config.Community)),
+		pdu,
+	), nil
+}
+
+// berTLV joins already encoded contents under a tag
+func berTLV(tag byte, contents ...[]byte) []byte {
+	var body []byte
+	for _, c := range contents {
+		body = append(body, c...)
+	}
+
+	out := []byte{tag}
+	n := len(body)
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, body...)
+}
+
+func berInt(v int64) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		if (v >= -0x80 && v < 0x80) || len(b) == 8 {
+			break
+		}
+		v >>= 8
+	}
+	return berTLV(0x02, b)
+}
+
+// berUint encodes the contents of an unsigned application type such as TimeTicks
+func berUint(v uint64) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		if v == 0 {
+			break
+		}
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+// berOID encodes a dotted object identifier
+func berOID(oid string) ([]byte, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("object identifier %q is too short", oid)
+	}
+
+	arcs := make([]uint64, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("object identifier %q: %w", oid, err)
+		}
+		arcs[i] = arc
+	}
+	if arcs[0] > 2 || (arcs[0] < 2 && arcs[1] >= 40) {
+		return nil, fmt.Errorf("object identifier %q is invalid", oid)
+	}
+
+	body := encodeArc(arcs[0]*40 + arcs[1])
+	for _, arc := range arcs[2:] {
+		body = append(body, encodeArc(arc)...)
+	}
+	return berTLV(0x06, body), nil
+}
+
+// encodeArc encodes one OID arc in base 128 with continuation bits
+func encodeArc(arc uint64) []byte {
+	b := []byte{byte(arc & 0x7f)}
+	for arc >>= 7; arc > 0; arc >>= 7 {
+		b = append([]byte{byte(arc&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// splitSeverity separates a "[SEVERITY] " subject prefix from the rest
+func splitSeverity(subject string) (string, string) {
+	if strings.HasPrefix(subject, "[") {
+		if end := strings.Index(subject, "] "); end > 0 {
+			return subject[1:end], subject[end+2:]
+		}
+	}
+	return "", subject
+}
+
+// bodyField returns the value of a "Key: value" line in an alert body
+func bodyField(body, key string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, key) {
+			return strings.TrimSpace(strings.TrimPrefix(line, key))
+		}
+	}
+	return ""
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -0,0 +1,100 @@
+package alert
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestBerOID(t *testing.T) {
+	got, err := berOID("1.3.6.1.2.1.1.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected % x, got % x", want, got)
+	}
+
+	// Arcs above 127 use base 128 with continuation bits
+	if got := encodeArc(8072); !bytes.Equal(got, []byte{0xbf, 0x08}) {
+		t.Errorf("Expected bf 08, got % x", got)
+	}
+
+	if _, err := berOID("1.3.x"); err == nil {
+		t.Error("Expected error for non-numeric OID")
+	}
+}
+
+func TestBerInt(t *testing.T) {
+	cases := map[int64][]byte{
+		0:   {0x02, 0x01, 0x00},
+		1:   {0x02, 0x01, 0x01},
+		200: {0x02, 0x02, 0x00, 0xc8},
+		-1:  {0x02, 0x01, 0xff},
+	}
+	for v, want := range cases {
+		if got := berInt(v); !bytes.Equal(got, want) {
+			t.Errorf("berInt(%d): expected % x, got % x", v, want, got)
+		}
+	}
+}
+
+func TestSNMPTrapDelivery(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	defer conn.Close()
+
+	cfg := &config.SNMPConfig{
+		Enabled:       true,
+		Targets:       []string{conn.LocalAddr().String()},
+		Community:     "noc",
+		EnterpriseOID: "1.3.6.1.4.1.8072.9999.9999.1",
+	}
+	alerter := NewSNMPAlerter(cfg)
+
+	if err := alerter.SendAlert("ZFS Pool Alert: tank", "Pool: tank\nState: DEGRADED\n"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("No trap received: %v", err)
+	}
+	packet := buf[:n]
+
+	if packet[0] != 0x30 {
+		t.Errorf("Expected SEQUENCE, got %#x", packet[0])
+	}
+	if !bytes.Contains(packet, []byte{0x04, 0x03, 'n', 'o', 'c'}) {
+		t.Error("Expected community string in trap")
+	}
+	if !bytes.Contains(packet, []byte{0xa7}) {
+		t.Error("Expected SNMPv2-Trap PDU")
+	}
+
+	trapOID, _ := berOID(cfg.EnterpriseOID + "." + trapPoolState)
+	if !bytes.Contains(packet, trapOID) {
+		t.Error("Expected pool state trap OID")
+	}
+	if !bytes.Contains(packet, []byte("DEGRADED")) {
+		t.Error("Expected pool state value in trap")
+	}
+}
+
+func TestSNMPDisabled(t *testing.T) {
+	alerter := NewSNMPAlerter(&config.SNMPConfig{})
+	if alerter.Enabled() {
+		t.Error("Expected SNMP to be disabled without targets")
+	}
+	if err := alerter.SendAlert("subject", "body"); err == nil {
+		t.Error("Expected error sending with incomplete configuration")
+	}
+}
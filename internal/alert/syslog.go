@@ -0,0 +1,244 @@
+package alert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/config"
+)
+
+// Syslog severities (RFC 5424 section 6.2.1)
+const (
+	syslogAlert   = 1
+	syslogCrit    = 2
+	syslogErr     = 3
+	syslogWarning = 4
+	syslogNotice  = 5
+	syslogInfo    = 6
+)
+
+// syslogWriteTimeout bounds writing one message, so a collector that stops
+// reading can't hold up alerts and audit events indefinitely
+const syslogWriteTimeout = 10 * time.Second
+
+// syslogSDID names the structured data element; 32473 is the example
+// enterprise number reserved for documentation (RFC 5612)
+const syslogSDID = "zfsrabbit@32473"
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// sdParam is one structured data parameter; a slice keeps them in order
+type sdParam struct {
+	name  string
+	value string
+}
+
+// SyslogAlerter writes alerts and audit events as RFC 5424 messages to the
+// local syslog socket or a remote collector over UDP, TCP or TLS
+type SyslogAlerter struct {
+	config       *config.SyslogConfig
+	hostname     string
+	writeTimeout time.Duration
+	mutex        sync.Mutex
+	conn         net.Conn
+}
+
+func NewSyslogAlerter(cfg *config.SyslogConfig) *SyslogAlerter {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogAlerter{
+		config:       cfg,
+		hostname:     hostname,
+		writeTimeout: syslogWriteTimeout,
+	}
+}
+
+// Enabled reports whether alerts go to syslog
+func (s *SyslogAlerter) Enabled() bool {
+	return s.config.Enabled && s.config.Alerts
+}
+
+func (s *SyslogAlerter) SendAlert(ctx context.Context, subject, body string) error {
+	severity, _ := splitSeverity(subject)
+
+	return s.write(ctx, alertSyslogSeverity(severity), "alert", []sdParam{
+		{"subject", subject},
+		{"severity", severity},
+	}, subject+": "+body)
+}
+
+func (s *SyslogAlerter) SendSyncSuccess(ctx context.Context, snapshot, dataset string, duration time.Duration) error {
+	return s.write(ctx, syslogInfo, "sync_success", []sdParam{
+		{"dataset", dataset},
+		{"snapshot", snapshot},
+		{"duration", duration.String()},
+	}, fmt.Sprintf("Replicated snapshot %s of %s", snapshot, dataset))
+}
+
+func (s *SyslogAlerter) SendSyncFailure(ctx context.Context, snapshot, dataset string, err error) error {
+	return s.write(ctx, syslogErr, "sync_failure", []sdParam{
+		{"dataset", dataset},
+		{"snapshot", snapshot},
+		{"error", err.Error()},
+	}, fmt.Sprintf("Failed to replicate snapshot %s of %s: %v", snapshot, dataset, err))
+}
+
+// RecordAudit is an audit.Sink writing audit events to syslog
+func (s *SyslogAlerter) RecordAudit(event audit.Event) {
+	err := s.write(context.Background(), syslogNotice, "audit", []sdParam{
+		{"actor", event.Actor},
+		{"action", event.Action},
+		{"remote", event.Remote},
+		{"outcome", event.Outcome},
+		{"status", strconv.Itoa(event.Status)},
+	}, fmt.Sprintf("%s %s by %s: %s", event.Action, event.Outcome, event.Actor, event.Remote))
+	if err != nil {
+		logger.Error("Failed to write audit event to syslog", "err", err)
+	}
+}
+
+// write sends one message, giving up after the write timeout or once ctx
+// ends. A failed write drops the connection, which is redialled once.
+func (s *SyslogAlerter) write(ctx context.Context, severity int, msgID string, params []sdParam, msg string) error {
+	facility, ok := syslogFacilities[s.config.Facility]
+	if !ok {
+		facility = syslogFacilities["daemon"]
+	}
+
+	line := formatRFC5424(facility*8+severity, time.Now(), s.hostname, msgID, params, msg)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// One reconnect per message covers collectors that dropped an idle
+	// connection, or stalled and timed out a write, which leaves a partial
+	// frame on the stream
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(ctx); err != nil {
+				return fmt.Errorf("failed to connect to syslog: %w", err)
+			}
+		}
+		deadline := time.Now().Add(s.writeTimeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		s.conn.SetWriteDeadline(deadline)
+		if _, err = s.conn.Write(s.frame(line)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("failed to write to syslog: %w", err)
+}
+
+func (s *SyslogAlerter) dial(ctx context.Context) (net.Conn, error) {
+	switch s.config.Network {
+	case "", "local":
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			if conn, err := net.Dial("unixgram", path); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, fmt.Errorf("no local syslog socket found")
+	case "udp", "tcp":
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		return dialer.DialContext(ctx, s.config.Network, s.config.Address)
+	case "tls":
+		tlsConfig := &tls.Config{}
+		if s.config.CAFile != "" {
+			pem, err := os.ReadFile(s.config.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", s.config.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: 10 * time.Second}, Config: tlsConfig}
+		return dialer.DialContext(ctx, "tcp", s.config.Address)
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", s.config.Network)
+	}
+}
+
+// frame applies octet-counting framing (RFC 6587) on stream transports;
+// datagrams carry one message each
+func (s *SyslogAlerter) frame(line string) []byte {
+	if s.config.Network == "tcp" || s.config.Network == "tls" {
+		return []byte(strconv.Itoa(len(line)) + " " + line)
+	}
+	return []byte(line)
+}
+
+func (s *SyslogAlerter) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// formatRFC5424 renders <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func formatRFC5424(pri int, ts time.Time, hostname, msgID string, params []sdParam, msg string) string {
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		fmt.Fprintf(&sd, " %s=\"%s\"", p.name, escapeSDValue(p.value))
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s zfsrabbit %d %s %s %s",
+		pri, ts.UTC().Format("2006-01-02T15:04:05.000000Z"), hostname, os.Getpid(), msgID, sd.String(), msg)
+}
+
+// escapeSDValue escapes the characters RFC 5424 reserves in parameter values
+func escapeSDValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
+
+// alertSyslogSeverity maps alert subject severities to syslog severities
+func alertSyslogSeverity(severity string) int {
+	switch severity {
+	case "EMERGENCY":
+		return syslogAlert
+	case "CRITICAL":
+		return syslogCrit
+	case "WARNING":
+		return syslogWarning
+	case "INFO":
+		return syslogInfo
+	default:
+		return syslogErr
+	}
+}
@@ -0,0 +1,156 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/config"
+)
+
+func TestFormatRFC5424(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 2, 0, 0, 0, time.UTC)
+	line := formatRFC5424(3*8+syslogErr, ts, "nas1", "alert", []sdParam{
+		{"subject", `Pool "tank" [x]`},
+		{"severity", ""},
+	}, "body")
+
+	if !strings.HasPrefix(line, "<27>1 2024-01-15T02:00:00.000000Z nas1 zfsrabbit ") {
+		t.Errorf("Unexpected header: %s", line)
+	}
+	if !strings.Contains(line, ` alert [zfsrabbit@32473 subject="Pool \"tank\" [x\]"] body`) {
+		t.Errorf("Expected escaped structured data without empty params, got: %s", line)
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(length))
+		msg := make([]byte, n)
+		if _, err := reader.Read(msg); err == nil {
+			received <- string(msg)
+		}
+	}()
+
+	s := NewSyslogAlerter(&config.SyslogConfig{
+		Enabled:  true,
+		Network:  "tcp",
+		Address:  listener.Addr().String(),
+		Facility: "local0",
+		Alerts:   true,
+		Audit:    true,
+	})
+	defer s.Close()
+
+	s.RecordAudit(audit.Event{Actor: "admin", Action: "POST /api/restore", Remote: "10.0.0.5:4000", Outcome: "success", Status: 200})
+
+	select {
+	case msg := <-received:
+		// local0 (16) * 8 + notice (5)
+		if !strings.HasPrefix(msg, "<133>1 ") {
+			t.Errorf("Unexpected priority: %s", msg)
+		}
+		if !strings.Contains(msg, `action="POST /api/restore"`) || !strings.Contains(msg, " audit [") {
+			t.Errorf("Expected audit structured data, got: %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No syslog message received")
+	}
+}
+
+func TestSyslogStalledCollector(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+	defer listener.Close()
+
+	// The collector accepts connections but never reads from them
+	var mutex sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			conns = append(conns, conn)
+			mutex.Unlock()
+		}
+	}()
+	accepted := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(conns)
+	}
+	defer func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	s := NewSyslogAlerter(&config.SyslogConfig{
+		Enabled: true,
+		Network: "tcp",
+		Address: listener.Addr().String(),
+		Alerts:  true,
+	})
+	s.writeTimeout = 100 * time.Millisecond
+	defer s.Close()
+
+	// Fill the socket buffers until a write stalls, times out and the
+	// message goes over a new connection instead
+	body := strings.Repeat("x", 1<<20)
+	deadline := time.Now().Add(10 * time.Second)
+	for accepted() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a stalled write to time out and reconnect")
+		}
+		start := time.Now()
+		if err := s.SendAlert(context.Background(), "[WARNING] Test", body); err != nil {
+			t.Fatalf("Expected the message to go over a new connection, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("Expected the write to give up after its timeout, took %s", elapsed)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAlertSyslogSeverity(t *testing.T) {
+	severity, _ := splitSeverity("[CRITICAL] Disk Health Alert: sda")
+	if alertSyslogSeverity(severity) != syslogCrit {
+		t.Errorf("Expected crit for CRITICAL alerts")
+	}
+	severity, _ = splitSeverity("ZFS Pool Alert: tank")
+	if alertSyslogSeverity(severity) != syslogErr {
+		t.Errorf("Expected err for alerts without a severity")
+	}
+}
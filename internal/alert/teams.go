@@ -0,0 +1,224 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/version"
+)
+
+// Adaptive Card container styles, used to colour the title of a card
+const (
+	teamsGood      = "good"
+	teamsWarning   = "warning"
+	teamsAttention = "attention"
+	teamsAccent    = "accent"
+)
+
+// maxFactKey is the longest "Key: value" key shown as a fact rather than text
+const maxFactKey = 30
+
+// TeamsAlerter posts alerts and sync notifications to a Microsoft Teams
+// channel as Adaptive Cards, through an incoming webhook or a Workflows
+// webhook trigger
+type TeamsAlerter struct {
+	config *config.TeamsConfig
+	client *http.Client
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string            `json:"$schema"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Body    []teamsElement    `json:"body"`
+	MSTeams map[string]string `json:"msteams,omitempty"`
+}
+
+type teamsElement struct {
+	Type     string         `json:"type"`
+	Text     string         `json:"text,omitempty"`
+	Weight   string         `json:"weight,omitempty"`
+	Size     string         `json:"size,omitempty"`
+	Style    string         `json:"style,omitempty"`
+	Wrap     bool           `json:"wrap,omitempty"`
+	IsSubtle bool           `json:"isSubtle,omitempty"`
+	Bleed    bool           `json:"bleed,omitempty"`
+	Items    []teamsElement `json:"items,omitempty"`
+	Facts    []teamsFact    `json:"facts,omitempty"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+func NewTeamsAlerter(cfg *config.TeamsConfig) *TeamsAlerter {
+	return &TeamsAlerter{
+		config: cfg,
+		client: &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Enabled reports whether Teams alerts are configured
+func (t *TeamsAlerter) Enabled() bool {
+	return t.config.Enabled && t.config.WebhookURL != ""
+}
+
+// SendAlert posts a card coloured by the kind of alert: pool state, disk
+// health severity, or the severity in the subject
+func (t *TeamsAlerter) SendAlert(ctx context.Context, subject, body string) error {
+	if !t.Enabled() {
+		return nil
+	}
+
+	severity, title := splitSeverity(subject)
+	style := severityStyle(severity)
+	if strings.HasPrefix(title, "ZFS Pool Alert: ") {
+		switch bodyField(body, i18n.T("alert.pool.state_field")) {
+		case "ONLINE":
+			style = teamsGood
+		case "DEGRADED":
+			style = teamsWarning
+		default:
+			style = teamsAttention
+		}
+	}
+
+	return t.sendMessage(ctx, teamsCardMessage(subject, body, style))
+}
+
+func (t *TeamsAlerter) SendSyncSuccess(ctx context.Context, snapshot, dataset string, duration time.Duration) error {
+	if !t.Enabled() || !t.config.AlertOnSync {
+		return nil
+	}
+	return t.sendMessage(ctx, teamsCardMessage(i18n.T("slack.sync_success_title"),
+		i18n.T("slack.sync_success", snapshot, dataset, duration.String()), teamsGood))
+}
+
+func (t *TeamsAlerter) SendSyncFailure(ctx context.Context, snapshot, dataset string, err error) error {
+	if !t.Enabled() || !t.config.AlertOnSync {
+		return nil
+	}
+	message := i18n.T("slack.sync_failure", snapshot, dataset, err.Error())
+	if runbook := i18n.Runbook("alert.sync.runbook"); runbook != "" {
+		message += "\n" + runbook
+	}
+	return t.sendMessage(ctx, teamsCardMessage(i18n.T("slack.sync_failure_title"), message, teamsAttention))
+}
+
+func (t *TeamsAlerter) TestConnection() error {
+	return t.SendAlert(context.Background(), "Test Alert", "This is a test message from ZFSRabbit to verify Microsoft Teams integration.")
+}
+
+func (t *TeamsAlerter) sendMessage(ctx context.Context, msg teamsMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Teams message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build Teams request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Teams message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Incoming webhooks answer 200, Workflows triggers 202
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("teams webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// severityStyle is the card style for an alert severity
+func severityStyle(severity string) string {
+	switch severity {
+	case "CRITICAL", "EMERGENCY":
+		return teamsAttention
+	case "INFO":
+		return teamsAccent
+	default:
+		return teamsWarning
+	}
+}
+
+// teamsCardMessage is an alert as an Adaptive Card: the title on a band in
+// style's colour, the body's "Key: value" lines as facts, the rest of the
+// body as text, and when it was sent
+func teamsCardMessage(title, body, style string) teamsMessage {
+	facts, text := teamsFacts(body)
+
+	elements := []teamsElement{{
+		Type:  "Container",
+		Style: style,
+		Bleed: true,
+		Items: []teamsElement{{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Medium", Wrap: true}},
+	}}
+	if len(facts) > 0 {
+		elements = append(elements, teamsElement{Type: "FactSet", Facts: facts})
+	}
+	if text != "" {
+		elements = append(elements, teamsElement{Type: "TextBlock", Text: text, Wrap: true})
+	}
+	elements = append(elements, teamsElement{
+		Type:     "TextBlock",
+		Text:     i18n.T("slack.time", display.Time(time.Now())) + " · " + i18n.T("slack.version", version.Get().Short()),
+		Size:     "Small",
+		IsSubtle: true,
+		Wrap:     true,
+	})
+
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: teamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    elements,
+				MSTeams: map[string]string{"width": "Full"},
+			},
+		}},
+	}
+}
+
+// teamsFacts splits an alert body into its unindented "Key: value" lines and
+// everything else. Backticks are dropped, cards don't render them as code.
+func teamsFacts(body string) ([]teamsFact, string) {
+	var facts []teamsFact
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(body, "`", ""), "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if ok && key != "" && len(key) <= maxFactKey && strings.TrimSpace(key) == key && strings.TrimSpace(value) != "" {
+			facts = append(facts, teamsFact{Title: key, Value: strings.TrimSpace(value)})
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return facts, strings.TrimSpace(strings.Join(lines, "\n"))
+}
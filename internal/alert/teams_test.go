@@ -0,0 +1,87 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestTeamsAlerterPoolCard(t *testing.T) {
+	var got teamsMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	alerter := NewTeamsAlerter(&config.TeamsConfig{Enabled: true, WebhookURL: server.URL})
+	body := "ZFS Pool Health Alert\n\nPool: tank\nState: FAULTED\nDegraded: true\n\nDevice Status:\n  sda: FAULTED (R:3 W:0 C:0)\n"
+	if err := alerter.SendAlert(context.Background(), "ZFS Pool Alert: tank", body); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+
+	if len(got.Attachments) != 1 || got.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("Expected one Adaptive Card, got %+v", got)
+	}
+	card := got.Attachments[0].Content
+	if card.Type != "AdaptiveCard" || len(card.Body) != 4 {
+		t.Fatalf("Expected title, facts, text and footer, got %+v", card.Body)
+	}
+	if card.Body[0].Style != teamsAttention || card.Body[0].Items[0].Text != "ZFS Pool Alert: tank" {
+		t.Errorf("Expected a faulted pool in the attention style, got %+v", card.Body[0])
+	}
+	facts := card.Body[1].Facts
+	if len(facts) != 3 || facts[0] != (teamsFact{"Pool", "tank"}) || facts[1] != (teamsFact{"State", "FAULTED"}) {
+		t.Errorf("Unexpected facts: %+v", facts)
+	}
+	if !strings.Contains(card.Body[2].Text, "sda: FAULTED") {
+		t.Errorf("Expected device lines kept as text, got %q", card.Body[2].Text)
+	}
+}
+
+func TestTeamsAlerterStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	alerter := NewTeamsAlerter(&config.TeamsConfig{Enabled: true, WebhookURL: server.URL})
+	if err := alerter.SendAlert(context.Background(), "[CRITICAL] Disk sda failing", "Device: /dev/sda"); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("Expected the status to be reported, got %v", err)
+	}
+}
+
+func TestTeamsCardStyles(t *testing.T) {
+	tests := []struct {
+		subject string
+		body    string
+		want    string
+	}{
+		{"[CRITICAL] HDD Health Alert: sda", "Severity: CRITICAL", teamsAttention},
+		{"[WARNING] Disk Path Alert: wwn-1", "", teamsWarning},
+		{"ZFS Pool Alert: tank", "State: DEGRADED", teamsWarning},
+		{"ZFS Pool Alert: tank", "State: ONLINE", teamsGood},
+		{"Replication Lag", "", teamsWarning},
+	}
+
+	for _, tt := range tests {
+		var got teamsMessage
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+		}))
+		alerter := NewTeamsAlerter(&config.TeamsConfig{Enabled: true, WebhookURL: server.URL})
+		if err := alerter.SendAlert(context.Background(), tt.subject, tt.body); err != nil {
+			t.Fatalf("SendAlert(%q) failed: %v", tt.subject, err)
+		}
+		server.Close()
+
+		if style := got.Attachments[0].Content.Body[0].Style; style != tt.want {
+			t.Errorf("SendAlert(%q, %q) style = %q, want %q", tt.subject, tt.body, style, tt.want)
+		}
+	}
+}
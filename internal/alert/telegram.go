@@ -0,0 +1,169 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/version"
+)
+
+const (
+	telegramAPIBase = "https://api.telegram.org"
+	// telegramMaxLength is Telegram's limit on the text of one message
+	telegramMaxLength = 4096
+)
+
+// TelegramAlerter sends alerts and sync notifications through a Telegram bot
+// to every configured chat
+type TelegramAlerter struct {
+	config  *config.TelegramConfig
+	client  *http.Client
+	apiBase string
+}
+
+func NewTelegramAlerter(cfg *config.TelegramConfig) *TelegramAlerter {
+	return &TelegramAlerter{
+		config:  cfg,
+		client:  &http.Client{Timeout: sendTimeout},
+		apiBase: telegramAPIBase,
+	}
+}
+
+// Enabled reports whether Telegram alerts are configured
+func (t *TelegramAlerter) Enabled() bool {
+	return t.config.Enabled && t.config.BotToken != "" && len(t.config.ChatIDs) > 0
+}
+
+func (t *TelegramAlerter) SendAlert(ctx context.Context, subject, body string) error {
+	if !t.Enabled() {
+		return nil
+	}
+	return t.sendMessage(ctx, telegramText("⚠️ "+subject, body))
+}
+
+func (t *TelegramAlerter) SendSyncSuccess(ctx context.Context, snapshot, dataset string, duration time.Duration) error {
+	if !t.Enabled() || !t.config.AlertOnSync {
+		return nil
+	}
+	return t.sendMessage(ctx, telegramText(i18n.T("slack.sync_success_title"),
+		i18n.T("slack.sync_success", snapshot, dataset, duration.String())))
+}
+
+func (t *TelegramAlerter) SendSyncFailure(ctx context.Context, snapshot, dataset string, err error) error {
+	if !t.Enabled() || !t.config.AlertOnSync {
+		return nil
+	}
+	message := i18n.T("slack.sync_failure", snapshot, dataset, err.Error())
+	if runbook := i18n.Runbook("alert.sync.runbook"); runbook != "" {
+		message += "\n" + runbook
+	}
+	return t.sendMessage(ctx, telegramText(i18n.T("slack.sync_failure_title"), message))
+}
+
+func (t *TelegramAlerter) TestConnection() error {
+	return t.SendAlert(context.Background(), "Test Alert", "This is a test message from ZFSRabbit to verify Telegram integration.")
+}
+
+// sendMessage posts text to each chat, trying them all before reporting
+// the ones that failed
+func (t *TelegramAlerter) sendMessage(ctx context.Context, text string) error {
+	var errs []string
+	for _, chatID := range t.config.ChatIDs {
+		if err := SendTelegram(ctx, t.client, t.apiBase, t.config.BotToken, chatID, text); err != nil {
+			errs = append(errs, fmt.Sprintf("chat %d: %v", chatID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("telegram delivery failed for %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SendTelegram posts an HTML formatted message to a chat with the Bot API
+func SendTelegram(ctx context.Context, client *http.Client, apiBase, token string, chatID int64, text string) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiBase+"/bot"+token+"/sendMessage", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL holds the token, keep it out of logs
+		return fmt.Errorf("telegram sendMessage failed: %w", stripToken(err, token))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || !result.OK {
+		if result.Description != "" {
+			return fmt.Errorf("telegram sendMessage failed: %s", result.Description)
+		}
+		return fmt.Errorf("telegram sendMessage returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func stripToken(err error, token string) error {
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<token>"))
+}
+
+// telegramText is an alert as a Telegram HTML message: the title in bold,
+// the body, and when it was sent, cut to fit in one message
+func telegramText(title, body string) string {
+	footer := "\n\n<i>" + html.EscapeString(i18n.T("slack.time", display.Time(time.Now()))) + "</i>"
+	head := "<b>" + html.EscapeString(title) + "</b>\n"
+
+	text := TelegramHTML(body)
+	if room := telegramMaxLength - len(head) - len(footer); len(text) > room {
+		// Cut the plain body so no tag or entity is split
+		plain := []rune(body)
+		for len(plain) > 0 && len(TelegramHTML(string(plain)))+len("…") > room {
+			plain = plain[:len(plain)*9/10]
+		}
+		text = TelegramHTML(string(plain)) + "…"
+	}
+	return head + text + footer
+}
+
+// TelegramHTML escapes text for Telegram's HTML parse mode, turning
+// `quoted` spans into code as Slack's mrkdwn would
+func TelegramHTML(text string) string {
+	parts := strings.Split(text, "`")
+	var b strings.Builder
+	for i, part := range parts {
+		// An unmatched final backtick is kept as is
+		if i%2 == 1 && i < len(parts)-1 {
+			b.WriteString("<code>" + html.EscapeString(part) + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			b.WriteString("`")
+		}
+		b.WriteString(html.EscapeString(part))
+	}
+	return b.String()
+}
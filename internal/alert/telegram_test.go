@@ -0,0 +1,82 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestTelegramAlerter(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	var messages []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		messages = append(messages, msg)
+		mu.Unlock()
+
+		if msg["chat_id"] == float64(-100) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer server.Close()
+
+	alerter := NewTelegramAlerter(&config.TelegramConfig{
+		Enabled:  true,
+		BotToken: "123:secret",
+		ChatIDs:  []int64{42, -100},
+	})
+	alerter.apiBase = server.URL
+
+	err := alerter.SendAlert(context.Background(), "[WARNING] Pool <tank>", "Dataset: `tank/data` & more")
+	if err == nil || !strings.Contains(err.Error(), "chat -100: telegram sendMessage failed: Bad Request: chat not found") {
+		t.Fatalf("Expected the failing chat to be reported, got %v", err)
+	}
+
+	if len(paths) != 2 || paths[0] != "/bot123:secret/sendMessage" {
+		t.Fatalf("Expected one message per chat, got %v", paths)
+	}
+	text, _ := messages[0]["text"].(string)
+	if !strings.Contains(text, "<b>⚠️ [WARNING] Pool &lt;tank&gt;</b>") || !strings.Contains(text, "Dataset: <code>tank/data</code> &amp; more") {
+		t.Errorf("Unexpected message text: %q", text)
+	}
+	if messages[0]["parse_mode"] != "HTML" {
+		t.Errorf("Expected HTML parse mode, got %v", messages[0]["parse_mode"])
+	}
+}
+
+func TestTelegramTextFitsOneMessage(t *testing.T) {
+	text := telegramText("Big alert", strings.Repeat("<&> ", 3000))
+	if len(text) > telegramMaxLength {
+		t.Errorf("Expected at most %d bytes, got %d", telegramMaxLength, len(text))
+	}
+	if !strings.Contains(text, "…") || strings.Contains(text, "&l…") {
+		t.Errorf("Expected the body cut between entities")
+	}
+}
+
+func TestTelegramHTML(t *testing.T) {
+	tests := map[string]string{
+		"plain":            "plain",
+		"a `b` c":          "a <code>b</code> c",
+		"<x> & `y<z>`":     "&lt;x&gt; &amp; <code>y&lt;z&gt;</code>",
+		"unmatched ` tick": "unmatched ` tick",
+	}
+	for in, want := range tests {
+		if got := TelegramHTML(in); got != want {
+			t.Errorf("TelegramHTML(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/version"
+)
+
+// ChannelWebhook prefixes the outbox and breaker channel of each webhook,
+// e.g. "webhook:alertmanager"
+const ChannelWebhook = "webhook"
+
+// WebhookAlerter posts alerts as JSON to an HTTP receiver such as
+// Alertmanager, Grafana OnCall or a custom service
+type WebhookAlerter struct {
+	config   config.WebhookConfig
+	template *template.Template // nil for the Alertmanager format
+	client   *http.Client
+	hostname string
+	now      func() time.Time
+}
+
+// WebhookAlert is what a webhook template is executed with
+type WebhookAlert struct {
+	Subject  string    // As sent by email, e.g. "[WARNING] Replication Lag: tank/data"
+	Title    string    // Subject without its severity
+	Name     string    // Title up to the first colon, e.g. "Replication Lag"
+	Severity string    // Lower case, e.g. warning; info if the subject has none
+	Body     string    // Plain text details
+	Dataset  string    // From the body's Dataset: line, if any
+	Host     string    // Host zfsrabbit runs on
+	Time     time.Time // When the alert was raised
+}
+
+// alertmanagerAlert is one alert in Alertmanager's POST /api/v2/alerts body
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+}
+
+// NewWebhookAlerter creates an alerter for a validated webhook config
+func NewWebhookAlerter(cfg config.WebhookConfig) (*WebhookAlerter, error) {
+	tmpl, err := cfg.ParseTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("webhook %s template: %w", cfg.Name, err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+
+	return &WebhookAlerter{
+		config:   cfg,
+		template: tmpl,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		hostname: hostname,
+		now:      time.Now,
+	}, nil
+}
+
+// Channel is the name the webhook's outbox queue and breaker go by
+func (w *WebhookAlerter) Channel() string {
+	return ChannelWebhook + ":" + w.config.Name
+}
+
+func (w *WebhookAlerter) SendAlert(ctx context.Context, subject, body string) error {
+	payload, err := w.payload(w.alert(subject, body))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	for name, value := range w.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook %s returned status %d: %s", w.config.Name, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+func (w *WebhookAlerter) TestConnection() error {
+	return w.SendAlert(context.Background(), "[INFO] Test Alert", "This is a test message from ZFSRabbit to verify webhook delivery.")
+}
+
+func (w *WebhookAlerter) alert(subject, body string) WebhookAlert {
+	// Retries from the outbox are marked delayed in front of the severity
+	severity, title := splitSeverity(strings.TrimPrefix(subject, "[DELAYED] "))
+	if severity == "" {
+		severity = "info"
+	}
+	name, _, _ := strings.Cut(title, ":")
+
+	return WebhookAlert{
+		Subject:  subject,
+		Title:    title,
+		Name:     strings.TrimSpace(name),
+		Severity: strings.ToLower(severity),
+		Body:     body,
+		Dataset:  bodyField(body, "Dataset:"),
+		Host:     w.hostname,
+		Time:     w.now(),
+	}
+}
+
+// payload renders the request body: the template's output, which must be
+// valid JSON, or a single Alertmanager alert
+func (w *WebhookAlerter) payload(alert WebhookAlert) ([]byte, error) {
+	if w.template == nil {
+		labels := map[string]string{
+			"alertname": alert.Name,
+			"severity":  alert.Severity,
+			"instance":  alert.Host,
+			"service":   "zfsrabbit",
+		}
+		if alert.Dataset != "" {
+			labels["dataset"] = alert.Dataset
+		}
+		return json.Marshal([]alertmanagerAlert{{
+			Labels:      labels,
+			Annotations: map[string]string{"summary": alert.Title, "description": alert.Body},
+			StartsAt:    alert.Time.UTC(),
+		}})
+	}
+
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, alert); err != nil {
+		return nil, fmt.Errorf("webhook %s template: %w", w.config.Name, err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook %s template did not produce valid JSON", w.config.Name)
+	}
+	return buf.Bytes(), nil
+}
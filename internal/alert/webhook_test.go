@@ -0,0 +1,102 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestWebhookAlertmanagerFormat(t *testing.T) {
+	var got []alertmanagerAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhookAlerter(config.WebhookConfig{Name: "am", URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raised := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	webhook.now = func() time.Time { return raised }
+
+	if err := webhook.SendAlert(context.Background(), "[WARNING] Replication Lag: tank/data", "Replication Lag\n\nDataset: tank/data\n"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Expected one alert, got %+v", got)
+	}
+	labels := got[0].Labels
+	if labels["alertname"] != "Replication Lag" || labels["severity"] != "warning" || labels["dataset"] != "tank/data" || labels["service"] != "zfsrabbit" {
+		t.Errorf("Unexpected labels %v", labels)
+	}
+	if got[0].Annotations["summary"] != "Replication Lag: tank/data" || !strings.Contains(got[0].Annotations["description"], "Dataset: tank/data") {
+		t.Errorf("Unexpected annotations %v", got[0].Annotations)
+	}
+	if !got[0].StartsAt.Equal(raised) {
+		t.Errorf("Expected startsAt %s, got %s", raised, got[0].StartsAt)
+	}
+
+	// An outbox retry keeps the original severity
+	if err := webhook.SendAlert(context.Background(), "[DELAYED] [CRITICAL] Disk sda failing", "2 alerts delayed"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if got[0].Labels["severity"] != "critical" {
+		t.Errorf("Expected a delayed critical alert to stay critical, got %v", got[0].Labels)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var body string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(status)
+		w.Write([]byte("rejected"))
+	}))
+	defer server.Close()
+
+	template := `{"title": {{json .Title}}, "state": {{if eq .Severity "info"}}"ok"{{else}}"alerting"{{end}}, "message": {{json .Body}}}`
+	webhook, err := NewWebhookAlerter(config.WebhookConfig{Name: "oncall", URL: server.URL, Template: template})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := webhook.SendAlert(context.Background(), "[CRITICAL] Pool \"tank\" degraded", "line one\nline two"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("Template output is not JSON: %v\n%s", err, body)
+	}
+	if got["title"] != `Pool "tank" degraded` || got["state"] != "alerting" || got["message"] != "line one\nline two" {
+		t.Errorf("Unexpected body %v", got)
+	}
+
+	status = http.StatusBadRequest
+	if err := webhook.SendAlert(context.Background(), "Test", "body"); err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Expected the receiver's error to be returned, got %v", err)
+	}
+
+	broken, err := NewWebhookAlerter(config.WebhookConfig{Name: "broken", URL: server.URL, Template: `{"title": {{.Title}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broken.SendAlert(context.Background(), "Test", "body"); err == nil || !strings.Contains(err.Error(), "valid JSON") {
+		t.Errorf("Expected invalid JSON to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event is one security-relevant action: an API call that changes state, or
+// a failed login
+type Event struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`  // Authenticated user, or the attempted one for failures
+	Action  string    `json:"action"` // e.g. "POST /api/restore" or "login_failed"
+	Remote  string    `json:"remote"` // Client address
+	Outcome string    `json:"outcome"`
+	Status  int       `json:"status,omitempty"` // HTTP status code, if any
+}
+
+// Sink receives every recorded event, e.g. to forward it to syslog
+type Sink func(Event)
+
+var (
+	mutex sync.RWMutex
+	sinks []Sink
+)
+
+// AddSink registers a sink for all later events
+func AddSink(sink Sink) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	sinks = append(sinks, sink)
+}
+
+// Record logs an event and passes it to every sink
+func Record(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	log.Printf("AUDIT actor=%s action=%q remote=%s outcome=%s status=%d",
+		event.Actor, event.Action, event.Remote, event.Outcome, event.Status)
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+	for _, sink := range sinks {
+		sink(event)
+	}
+}
@@ -0,0 +1,349 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/utils"
+)
+
+const maxEntries = 10000
+
+// Entry records a snapshot that was destroyed, and the bookmark left in its place if any
+type Entry struct {
+	Dataset   string    `json:"dataset"`
+	Snapshot  string    `json:"snapshot"`
+	Bookmark  string    `json:"bookmark,omitempty"` // dataset#name, usable as an incremental source
+	Created   time.Time `json:"created"`
+	Destroyed time.Time `json:"destroyed"`
+	Reason    string    `json:"reason"`
+}
+
+// Suspect is a replicated dataset or snapshot that may hold corrupted data
+// because a scrub found permanent errors in it locally
+type Suspect struct {
+	Pool     string    `json:"pool"`
+	Dataset  string    `json:"dataset"`
+	Snapshot string    `json:"snapshot,omitempty"` // Empty means every replicated snapshot of the dataset
+	Paths    []string  `json:"paths"`
+	Detected time.Time `json:"detected"`
+	Reason   string    `json:"reason"`
+}
+
+// Target names the flagged snapshot, or the dataset if no single snapshot is implicated
+func (s Suspect) Target() string {
+	if s.Snapshot == "" {
+		return s.Dataset
+	}
+	return s.Dataset + "@" + s.Snapshot
+}
+
+// TierArchive is the archive tier that tiering moves old snapshots to
+const TierArchive = "archive"
+
+// Archived records a recovery point that was moved off the backup pool and
+// where it can now be restored from
+type Archived struct {
+	Dataset  string    `json:"dataset"` // Remote dataset the snapshot was taken from
+	Snapshot string    `json:"snapshot"`
+	Tier     string    `json:"tier"`
+	Location string    `json:"location"`       // Path of the stream within the tier
+	Base     string    `json:"base,omitempty"` // Archived snapshot the stream is incremental from; empty for a full stream
+	Size     int64     `json:"size"`
+	Created  time.Time `json:"created"`
+	Archived time.Time `json:"archived"`
+}
+
+// catalogFile is the on-disk layout
+type catalogFile struct {
+	Destroyed         []Entry    `json:"destroyed"`
+	NeedsVerification []Suspect  `json:"needs_verification"`
+	Archived          []Archived `json:"archived,omitempty"`
+}
+
+// Catalog is a persistent trail of snapshot deletions, of replicas that
+// need verification and of where archived recovery points live
+type Catalog struct {
+	path     string
+	mutex    sync.RWMutex
+	entries  []Entry
+	suspects []Suspect
+	archived []Archived
+}
+
+// Open loads the catalog at path; an empty path keeps it in memory only
+func Open(path string) *Catalog {
+	c := &Catalog{path: path}
+
+	if path != "" {
+		if err := c.load(); err != nil {
+			log.Printf("Failed to load snapshot catalog from %s: %v", path, err)
+		}
+	}
+
+	return c
+}
+
+func (c *Catalog) load() error {
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Catalogs written before verification flags were added are a bare list of entries
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &c.entries)
+	}
+
+	var file catalogFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	c.entries = file.Destroyed
+	c.suspects = file.NeedsVerification
+	c.archived = file.Archived
+	return nil
+}
+
+// saveLocked persists the catalog; callers hold the write lock
+func (c *Catalog) saveLocked() {
+	if c.path == "" {
+		return
+	}
+	file := catalogFile{Destroyed: c.entries, NeedsVerification: c.suspects, Archived: c.archived}
+	if err := utils.WriteJSONAtomic(c.path, file, 0600); err != nil {
+		log.Printf("Failed to save snapshot catalog to %s: %v", c.path, err)
+	}
+}
+
+// RecordDestroyed adds an entry and persists the catalog
+func (c *Catalog) RecordDestroyed(entry Entry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if entry.Destroyed.IsZero() {
+		entry.Destroyed = time.Now()
+	}
+
+	c.entries = append(c.entries, entry)
+	if len(c.entries) > maxEntries {
+		c.entries = c.entries[len(c.entries)-maxEntries:]
+	}
+
+	c.saveLocked()
+}
+
+// Destroyed returns the recorded deletions, oldest first
+func (c *Catalog) Destroyed() []Entry {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entries := make([]Entry, len(c.entries))
+	copy(entries, c.entries)
+	return entries
+}
+
+// RenameDataset moves every record of from, and of its children, to to
+// after the dataset was renamed
+func (c *Catalog) RenameDataset(from, to string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i := range c.entries {
+		entry := &c.entries[i]
+		entry.Dataset = renamed(entry.Dataset, from, to)
+		if dataset, name, ok := strings.Cut(entry.Bookmark, "#"); ok {
+			entry.Bookmark = renamed(dataset, from, to) + "#" + name
+		}
+	}
+	for i := range c.suspects {
+		c.suspects[i].Dataset = renamed(c.suspects[i].Dataset, from, to)
+	}
+	c.saveLocked()
+}
+
+// renamed returns dataset with the from prefix replaced by to
+func renamed(dataset, from, to string) string {
+	if dataset == from {
+		return to
+	}
+	if child, ok := strings.CutPrefix(dataset, from+"/"); ok {
+		return to + "/" + child
+	}
+	return dataset
+}
+
+// Compact drops deletion records older than before and then the oldest past
+// maxRows (0 keeps every row), returning how many were removed. Verification
+// flags and archived recovery points are still in use and are never dropped.
+func (c *Catalog) Compact(before time.Time, maxRows int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var kept []Entry
+	for _, entry := range c.entries {
+		if before.IsZero() || !entry.Destroyed.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	if maxRows > 0 && len(kept) > maxRows {
+		kept = kept[len(kept)-maxRows:]
+	}
+
+	removed := len(c.entries) - len(kept)
+	if removed > 0 {
+		c.entries = kept
+		c.saveLocked()
+	}
+	return removed
+}
+
+// FlagForVerification records that a dataset or snapshot needs verification.
+// Flags for the same dataset and snapshot are merged so repeated scrubs of an
+// unrepaired pool don't grow the catalog. It reports whether anything new was added.
+func (c *Catalog) FlagForVerification(suspect Suspect) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if suspect.Detected.IsZero() {
+		suspect.Detected = time.Now()
+	}
+
+	for i := range c.suspects {
+		existing := &c.suspects[i]
+		if existing.Dataset != suspect.Dataset || existing.Snapshot != suspect.Snapshot {
+			continue
+		}
+
+		added := false
+		for _, path := range suspect.Paths {
+			if !containsString(existing.Paths, path) {
+				existing.Paths = append(existing.Paths, path)
+				added = true
+			}
+		}
+		if added {
+			c.saveLocked()
+		}
+		return added
+	}
+
+	c.suspects = append(c.suspects, suspect)
+	c.saveLocked()
+	return true
+}
+
+// ClearVerification removes the flag for a dataset and snapshot once the
+// replica has been verified or replaced. It reports whether a flag was removed.
+func (c *Catalog) ClearVerification(dataset, snapshot string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, suspect := range c.suspects {
+		if suspect.Dataset == dataset && suspect.Snapshot == snapshot {
+			c.suspects = append(c.suspects[:i], c.suspects[i+1:]...)
+			c.saveLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsVerification returns the flagged datasets and snapshots, oldest first
+func (c *Catalog) NeedsVerification() []Suspect {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	suspects := make([]Suspect, len(c.suspects))
+	copy(suspects, c.suspects)
+	return suspects
+}
+
+// RecordArchived remembers that a snapshot now lives in another tier,
+// replacing any earlier record for the same snapshot
+func (c *Catalog) RecordArchived(archived Archived) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if archived.Archived.IsZero() {
+		archived.Archived = time.Now()
+	}
+
+	for i, existing := range c.archived {
+		if existing.Dataset == archived.Dataset && existing.Snapshot == archived.Snapshot {
+			c.archived = append(c.archived[:i], c.archived[i+1:]...)
+			break
+		}
+	}
+	c.archived = append(c.archived, archived)
+	c.saveLocked()
+}
+
+// LookupArchived returns where an archived snapshot of dataset lives
+func (c *Catalog) LookupArchived(dataset, snapshot string) (Archived, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, archived := range c.archived {
+		if archived.Dataset == dataset && archived.Snapshot == snapshot {
+			return archived, true
+		}
+	}
+	return Archived{}, false
+}
+
+// ArchiveChain returns the streams to receive, in order, to restore an
+// archived snapshot: the full stream its chain starts from, then each
+// incremental one up to the snapshot itself
+func (c *Catalog) ArchiveChain(dataset, snapshot string) ([]Archived, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	byName := make(map[string]Archived)
+	for _, archived := range c.archived {
+		if archived.Dataset == dataset {
+			byName[archived.Snapshot] = archived
+		}
+	}
+
+	var chain []Archived
+	for name := snapshot; len(chain) <= len(byName); {
+		archived, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%s@%s is not archived", dataset, name)
+		}
+		chain = append([]Archived{archived}, chain...)
+		if archived.Base == "" {
+			return chain, nil
+		}
+		name = archived.Base
+	}
+	return nil, fmt.Errorf("the archive chain of %s@%s loops", dataset, snapshot)
+}
+
+// ArchivedSnapshots returns the archived recovery points, oldest archive first
+func (c *Catalog) ArchivedSnapshots() []Archived {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	archived := make([]Archived, len(c.archived))
+	copy(archived, c.archived)
+	return archived
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
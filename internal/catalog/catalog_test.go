@@ -0,0 +1,157 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlagForVerificationMerges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	c := Open(path)
+
+	if !c.FlagForVerification(Suspect{Dataset: "tank/data", Paths: []string{"/a"}}) {
+		t.Error("Expected first flag to be added")
+	}
+	if c.FlagForVerification(Suspect{Dataset: "tank/data", Paths: []string{"/a"}}) {
+		t.Error("Expected repeated flag to be a no-op")
+	}
+	if !c.FlagForVerification(Suspect{Dataset: "tank/data", Paths: []string{"/b"}}) {
+		t.Error("Expected new path to be merged")
+	}
+	c.FlagForVerification(Suspect{Dataset: "tank/data", Snapshot: "snap1", Paths: []string{"/a"}})
+
+	reopened := Open(path)
+	suspects := reopened.NeedsVerification()
+	if len(suspects) != 2 {
+		t.Fatalf("Expected 2 suspects after reload, got %d", len(suspects))
+	}
+	if len(suspects[0].Paths) != 2 {
+		t.Errorf("Expected merged paths, got %v", suspects[0].Paths)
+	}
+	if suspects[1].Target() != "tank/data@snap1" {
+		t.Errorf("Expected tank/data@snap1, got %s", suspects[1].Target())
+	}
+
+	if !reopened.ClearVerification("tank/data", "snap1") || len(reopened.NeedsVerification()) != 1 {
+		t.Error("Expected flag to be cleared")
+	}
+}
+
+func TestOpenLegacyCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	legacy := `[{"dataset":"tank/data","snapshot":"old","created":"2024-01-01T00:00:00Z","destroyed":"2024-02-01T00:00:00Z","reason":"retention"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Open(path)
+	if destroyed := c.Destroyed(); len(destroyed) != 1 || destroyed[0].Snapshot != "old" {
+		t.Errorf("Expected legacy entry to load, got %+v", destroyed)
+	}
+}
+
+func TestRecordArchived(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	c := Open(path)
+
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "snap1", Tier: TierArchive, Location: "/cold/backup/data/snap1.zfs", Size: 10})
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "snap2", Tier: TierArchive, Location: "/cold/backup/data/snap2.zfs", Size: 20})
+	// Archiving the same snapshot again replaces the earlier record
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "snap1", Tier: TierArchive, Location: "/colder/backup/data/snap1.zfs", Size: 10})
+
+	reopened := Open(path)
+	if archived := reopened.ArchivedSnapshots(); len(archived) != 2 {
+		t.Fatalf("Expected 2 archived snapshots after reload, got %+v", archived)
+	}
+
+	archived, ok := reopened.LookupArchived("backup/data", "snap1")
+	if !ok || archived.Location != "/colder/backup/data/snap1.zfs" || archived.Archived.IsZero() {
+		t.Errorf("Unexpected archived snapshot %+v (found %v)", archived, ok)
+	}
+	if _, ok := reopened.LookupArchived("backup/other", "snap1"); ok {
+		t.Error("Expected lookup to be scoped to the dataset")
+	}
+}
+
+func TestArchiveChain(t *testing.T) {
+	c := Open("")
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "snap1", Tier: TierArchive, Location: "/cold/snap1.zfs"})
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "snap2", Tier: TierArchive, Location: "/cold/snap2.zfs", Base: "snap1"})
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "snap3", Tier: TierArchive, Location: "/cold/snap3.zfs", Base: "snap2"})
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "snap5", Tier: TierArchive, Location: "/cold/snap5.zfs", Base: "snap4"})
+
+	chain, err := c.ArchiveChain("backup/data", "snap3")
+	if err != nil {
+		t.Fatalf("ArchiveChain failed: %v", err)
+	}
+	var names []string
+	for _, archived := range chain {
+		names = append(names, archived.Snapshot)
+	}
+	if strings.Join(names, ",") != "snap1,snap2,snap3" {
+		t.Errorf("Expected the chain from the full stream up, got %v", names)
+	}
+
+	if _, err := c.ArchiveChain("backup/data", "snap5"); err == nil {
+		t.Error("Expected a chain with a missing link to fail")
+	}
+	if _, err := c.ArchiveChain("backup/other", "snap1"); err == nil {
+		t.Error("Expected the chain to be scoped to the dataset")
+	}
+}
+
+func TestCompactKeepsLiveRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	c := Open(path)
+
+	now := time.Now()
+	for _, age := range []int{400, 100, 10, 1} {
+		c.RecordDestroyed(Entry{Dataset: "tank/data", Snapshot: "snap", Destroyed: now.AddDate(0, 0, -age)})
+	}
+	c.FlagForVerification(Suspect{Dataset: "tank/data", Detected: now.AddDate(-2, 0, 0)})
+	c.RecordArchived(Archived{Dataset: "backup/data", Snapshot: "old", Archived: now.AddDate(-2, 0, 0)})
+
+	if removed := c.Compact(now.AddDate(0, 0, -365), 2); removed != 2 {
+		t.Errorf("Expected the expired entry and one past the row limit removed, got %d", removed)
+	}
+
+	reopened := Open(path)
+	destroyed := reopened.Destroyed()
+	if len(destroyed) != 2 || destroyed[0].Destroyed.Before(now.AddDate(0, 0, -11)) {
+		t.Errorf("Expected the two newest entries to remain, got %+v", destroyed)
+	}
+	if len(reopened.NeedsVerification()) != 1 || len(reopened.ArchivedSnapshots()) != 1 {
+		t.Error("Expected verification flags and archived records to survive compaction")
+	}
+
+	if removed := reopened.Compact(time.Time{}, 0); removed != 0 {
+		t.Errorf("Expected no limits to remove nothing, got %d", removed)
+	}
+}
+
+func TestRenameDataset(t *testing.T) {
+	c := Open("")
+	c.RecordDestroyed(Entry{Dataset: "tank/old", Snapshot: "snap1", Bookmark: "tank/old#snap1"})
+	c.RecordDestroyed(Entry{Dataset: "tank/old/db", Snapshot: "snap1"})
+	c.RecordDestroyed(Entry{Dataset: "tank/older", Snapshot: "snap1"})
+	c.FlagForVerification(Suspect{Pool: "tank", Dataset: "tank/old"})
+
+	c.RenameDataset("tank/old", "tank/new")
+
+	entries := c.Destroyed()
+	if entries[0].Dataset != "tank/new" || entries[0].Bookmark != "tank/new#snap1" {
+		t.Errorf("Expected the dataset and bookmark to be renamed, got %+v", entries[0])
+	}
+	if entries[1].Dataset != "tank/new/db" {
+		t.Errorf("Expected the child to be renamed, got %s", entries[1].Dataset)
+	}
+	if entries[2].Dataset != "tank/older" {
+		t.Errorf("Expected a dataset sharing the prefix to be left alone, got %s", entries[2].Dataset)
+	}
+	if suspects := c.NeedsVerification(); suspects[0].Dataset != "tank/new" {
+		t.Errorf("Expected the verification flag to move, got %+v", suspects)
+	}
+}
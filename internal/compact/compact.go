@@ -0,0 +1,130 @@
+// Package compact keeps the state directory from growing without bound. On
+// a schedule it trims old history from the persistent stores, which rewrites
+// their files, and deletes old quarantined state files.
+package compact
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/state"
+)
+
+// Store is a persistent store with history that can be trimmed. Compact
+// drops rows older than before (zero keeps them) and then the oldest past
+// maxRows (0 means no limit), returning how many rows it removed.
+type Store interface {
+	Compact(before time.Time, maxRows int) int
+}
+
+// Status reports the size of the state directory and the last compaction
+type Status struct {
+	Bytes       int64          `json:"bytes"`
+	Files       []state.File   `json:"files"`
+	LastRun     *time.Time     `json:"last_run,omitempty"`
+	LastRemoved map[string]int `json:"last_removed,omitempty"` // Rows removed per store, and quarantined files
+}
+
+type namedStore struct {
+	name  string
+	store Store
+}
+
+// Compactor runs compaction for the registered stores
+type Compactor struct {
+	config  *config.StoreConfig
+	dir     *state.Dir // nil when no state directory is configured
+	cron    *cron.Cron
+	mutex   sync.Mutex
+	stores  []namedStore
+	lastRun *time.Time
+	removed map[string]int
+}
+
+// New returns a compactor for the stores in dir, which may be nil
+func New(cfg *config.StoreConfig, dir *state.Dir) *Compactor {
+	return &Compactor{
+		config: cfg,
+		dir:    dir,
+		cron:   cron.New(),
+	}
+}
+
+// Register adds a store to be compacted under name
+func (c *Compactor) Register(name string, store Store) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stores = append(c.stores, namedStore{name: name, store: store})
+}
+
+// Start schedules compaction on store.compact_cron; an empty schedule leaves
+// compaction to Run
+func (c *Compactor) Start() error {
+	if c.config.CompactCron == "" {
+		return nil
+	}
+	if _, err := c.cron.AddFunc(c.config.CompactCron, func() { c.Run() }); err != nil {
+		return err
+	}
+	c.cron.Start()
+	return nil
+}
+
+func (c *Compactor) Stop() {
+	c.cron.Stop()
+}
+
+// Run compacts every store now and returns how many rows each one dropped
+func (c *Compactor) Run() map[string]int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var before time.Time
+	if c.config.HistoryDays > 0 {
+		before = time.Now().AddDate(0, 0, -c.config.HistoryDays)
+	}
+
+	removed := make(map[string]int)
+	total := 0
+	for _, s := range c.stores {
+		removed[s.name] = s.store.Compact(before, c.config.MaxHistory)
+		total += removed[s.name]
+	}
+	if c.dir != nil && !before.IsZero() {
+		removed["quarantined"] = c.dir.RemoveQuarantined(before)
+		total += removed["quarantined"]
+	}
+
+	now := time.Now()
+	c.lastRun = &now
+	c.removed = removed
+	if total > 0 {
+		log.Printf("Compacted state stores, removed %v", removed)
+	}
+	return removed
+}
+
+// Status returns the current size of the state directory
+func (c *Compactor) Status() Status {
+	c.mutex.Lock()
+	status := Status{LastRun: c.lastRun, LastRemoved: c.removed}
+	c.mutex.Unlock()
+
+	if c.dir == nil {
+		return status
+	}
+	files, err := c.dir.Files()
+	if err != nil {
+		log.Printf("Failed to list state directory: %v", err)
+		return status
+	}
+	status.Files = files
+	for _, file := range files {
+		status.Bytes += file.Bytes
+	}
+	return status
+}
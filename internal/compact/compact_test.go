@@ -0,0 +1,74 @@
+package compact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/state"
+)
+
+type recordingStore struct {
+	before  time.Time
+	maxRows int
+}
+
+func (s *recordingStore) Compact(before time.Time, maxRows int) int {
+	s.before, s.maxRows = before, maxRows
+	return 3
+}
+
+func TestRunCompactsStoresAndQuarantine(t *testing.T) {
+	path := t.TempDir()
+	dir, err := state.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dir.Close()
+
+	os.WriteFile(filepath.Join(path, state.CatalogFile), []byte(`{"destroyed":[]}`), 0600)
+	os.WriteFile(filepath.Join(path, state.OutboxFile+".corrupt-20200101-000000"), []byte("{"), 0600)
+	recent := state.OutboxFile + ".corrupt-" + time.Now().Format("20060102-150405")
+	os.WriteFile(filepath.Join(path, recent), []byte("{"), 0600)
+
+	store := &recordingStore{}
+	c := New(&config.StoreConfig{HistoryDays: 30, MaxHistory: 100}, dir)
+	c.Register("catalog", store)
+
+	removed := c.Run()
+	if removed["catalog"] != 3 || removed["quarantined"] != 1 {
+		t.Errorf("Unexpected removal counts: %v", removed)
+	}
+	if store.maxRows != 100 || time.Since(store.before) < 29*24*time.Hour {
+		t.Errorf("Expected the store limits to be passed through, got %v / %d", store.before, store.maxRows)
+	}
+	if _, err := os.Stat(filepath.Join(path, recent)); err != nil {
+		t.Errorf("Expected the recently quarantined file to be kept: %v", err)
+	}
+
+	status := c.Status()
+	if status.LastRun == nil || status.Bytes == 0 {
+		t.Errorf("Expected a last run and a non-empty state directory, got %+v", status)
+	}
+	for _, file := range status.Files {
+		if file.Name == state.CatalogFile && file.Bytes != int64(len(`{"destroyed":[]}`)) {
+			t.Errorf("Unexpected catalog size %d", file.Bytes)
+		}
+	}
+}
+
+func TestRunWithoutLimits(t *testing.T) {
+	store := &recordingStore{}
+	c := New(&config.StoreConfig{}, nil)
+	c.Register("catalog", store)
+	c.Run()
+
+	if !store.before.IsZero() || store.maxRows != 0 {
+		t.Errorf("Expected no age or row limit, got %v / %d", store.before, store.maxRows)
+	}
+	if status := c.Status(); status.Bytes != 0 || status.Files != nil {
+		t.Errorf("Expected no files without a state directory, got %+v", status)
+	}
+}
@@ -1,35 +1,184 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/robfig/cron/v3"
-	"gopkg.in/yaml.v3"
+	"golang.org/x/crypto/bcrypt"
+	"zfsrabbit/internal/emailtemplate"
+	"zfsrabbit/internal/features"
+	"zfsrabbit/internal/i18n"
 	"zfsrabbit/internal/validation"
 )
 
+// phonePattern matches an E.164 phone number
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// telegramTokenPattern matches a Telegram bot token: the bot ID and a secret
+var telegramTokenPattern = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]{30,}$`)
+
+// oidPattern matches a dotted numeric object identifier such as 1.3.6.1.4.1
+var oidPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)+$`)
+
+// propertyPattern matches a native or user ZFS property name
+var propertyPattern = regexp.MustCompile(`^[a-z][a-z0-9_:.-]*$`)
+
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	ZFS      ZFSConfig      `yaml:"zfs"`
-	SSH      SSHConfig      `yaml:"ssh"`
-	Email    EmailConfig    `yaml:"email"`
-	Slack    SlackConfig    `yaml:"slack"`
-	Schedule ScheduleConfig `yaml:"schedule"`
+	Version    int              `yaml:"version"`
+	DryRun     bool             `yaml:"dry_run"` // Log mutating zfs, zpool and SSH operations instead of running them
+	Server     ServerConfig     `yaml:"server"`
+	ZFS        ZFSConfig        `yaml:"zfs"`
+	SSH        SSHConfig        `yaml:"ssh"`
+	Remotes    []RemoteConfig   `yaml:"remotes"`
+	Email      EmailConfig      `yaml:"email"`
+	Slack      SlackConfig      `yaml:"slack"`
+	SNMP       SNMPConfig       `yaml:"snmp"`
+	Syslog     SyslogConfig     `yaml:"syslog"`
+	SMS        SMSConfig        `yaml:"sms"`
+	Telegram   TelegramConfig   `yaml:"telegram"`
+	Teams      TeamsConfig      `yaml:"teams"`
+	Push       PushConfig       `yaml:"push"`
+	Webhooks   []WebhookConfig  `yaml:"webhooks"`
+	Routes     []AlertRoute     `yaml:"alert_routes"`
+	Owners     []OwnerConfig    `yaml:"owners"` // Teams receiving alerts about their datasets
+	Schedule   ScheduleConfig   `yaml:"schedule"`
+	Monitor    MonitorConfig    `yaml:"monitor"`
+	Export     ExportConfig     `yaml:"status_export"`
+	SelfBackup SelfBackupConfig `yaml:"self_backup"`
+	Drill      DrillConfig      `yaml:"dr_drill"`
+	Restore    RestoreConfig    `yaml:"restore"`
+	Update     UpdateConfig     `yaml:"update_check"`
+	Features   map[string]bool  `yaml:"features"` // Overrides for features.Known defaults
+	SLAs       []SLAConfig      `yaml:"sla"`
+	Tiering    TieringConfig    `yaml:"tiering"`
+	S3         S3Config         `yaml:"s3"`
+	Standby    StandbyConfig    `yaml:"standby"`
+	Jobs       []JobConfig      `yaml:"jobs"`
+	Store      StoreConfig      `yaml:"store"`
+	Display    DisplayConfig    `yaml:"display"`
+
+	// Path is the file the config was loaded from
+	Path string `yaml:"-"`
 }
 
 type ServerConfig struct {
-	Port         int    `yaml:"port"`
-	AdminPassEnv string `yaml:"admin_pass_env"`
-	LogLevel     string `yaml:"log_level"`
+	Port         int               `yaml:"port"`
+	GRPCPort     int               `yaml:"grpc_port"` // Also serve the gRPC API on this port; 0 leaves it off
+	AdminPassEnv string            `yaml:"admin_pass_env"`
+	LogLevel     string            `yaml:"log_level"`
+	LogFormat    string            `yaml:"log_format"`
+	StateDir     string            `yaml:"state_dir"`
+	Requesters   []RequesterConfig `yaml:"requesters"`
+	Users        []UserConfig      `yaml:"users"`
+	SessionTTL   time.Duration     `yaml:"session_ttl"` // How long a web login lasts
+	TLS          TLSConfig         `yaml:"tls"`
+	// Limits on reading a request, writing a response and keeping an idle
+	// connection open. Event streams and downloads are exempt from write_timeout.
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// How long shutdown waits for sends and restores in flight to finish
+	// before cutting them off; 0 stops right away
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+}
+
+// User roles for the web interface and Slack, from least to most access
+const (
+	RoleRequester = "requester" // request restores only
+	RoleViewer    = "viewer"    // read-only status
+	RoleOperator  = "operator"  // also trigger snapshots, scrubs and retries
+	RoleAdmin     = "admin"     // everything, including restores and migrations
+)
+
+var roleRanks = map[string]int{
+	RoleRequester: 1,
+	RoleViewer:    2,
+	RoleOperator:  3,
+	RoleAdmin:     4,
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// RoleAllows reports whether role grants at least the access of required
+func RoleAllows(role, required string) bool {
+	return ValidRole(role) && roleRanks[role] >= roleRanks[required]
+}
+
+// UserConfig is an individual web account
+type UserConfig struct {
+	User         string `yaml:"user"`
+	PasswordHash string `yaml:"password_hash" secret:"true"` // bcrypt hash from `zfsrabbit hash-password`
+	Role         string `yaml:"role"`                        // requester, viewer, operator or admin
+}
+
+// TLSConfig serves the web interface and Slack endpoints over HTTPS
+type TLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CertFile   string `yaml:"cert_file"`   // PEM certificate chain
+	KeyFile    string `yaml:"key_file"`    // PEM private key
+	SelfSigned bool   `yaml:"self_signed"` // Generate a certificate in the state directory when none is configured
+}
+
+// RequesterConfig is a non-admin web user who may only request restores
+// for an admin to approve
+type RequesterConfig struct {
+	User    string `yaml:"user"`
+	PassEnv string `yaml:"pass_env"` // Environment variable holding the password
 }
 
 type ZFSConfig struct {
-	Dataset         string `yaml:"dataset"`
-	SendCompression string `yaml:"send_compression"`
-	Recursive       bool   `yaml:"recursive"`
+	Dataset           string `yaml:"dataset"`
+	SendCompression   string `yaml:"send_compression"`
+	Recursive         bool   `yaml:"recursive"`
+	KeepSnapshots     int    `yaml:"keep_snapshots"` // Newest snapshots always kept
+	KeepHourly        int    `yaml:"keep_hourly"`    // Plus the newest snapshot of each of the last N hours
+	KeepDaily         int    `yaml:"keep_daily"`
+	KeepWeekly        int    `yaml:"keep_weekly"`
+	KeepMonthly       int    `yaml:"keep_monthly"`
+	KeepYearly        int    `yaml:"keep_yearly"`
+	PruneRemote       bool   `yaml:"prune_remote"`        // Apply the same retention to each backup server
+	BookmarkOnDestroy bool   `yaml:"bookmark_on_destroy"` // Keep a bookmark of each snapshot pruned by retention
+	BookmarkOnSend    bool   `yaml:"bookmark_on_send"`    // Bookmark each snapshot once every target has it
+	KeepBookmarks     int    `yaml:"keep_bookmarks"`      // Newest zfsrabbit bookmarks kept, 0 keeps all
+	RawSend           bool   `yaml:"raw_send"`            // zfs send -w: encrypted data replicates without its keys
+	FollowRenames     bool   `yaml:"follow_renames"`      // Switch to the dataset's new name when it is renamed
+	// Alert when a target's newest snapshot is older than this, e.g. 26h;
+	// 0 disables the alert
+	MaxLag time.Duration `yaml:"max_lag"`
+	// Expected ZFS properties such as compression, atime and recordsize;
+	// changes made out of band are alerted on and can be reapplied
+	Properties map[string]string `yaml:"properties"`
+	// Run before each snapshot, e.g. to flush and lock a database
+	PreSnapshot []SnapshotHook `yaml:"pre_snapshot"`
+	// Run once the snapshot is taken or has failed, e.g. to unlock it again
+	PostSnapshot []SnapshotHook `yaml:"post_snapshot"`
+}
+
+// What a failed pre-snapshot hook does to the snapshot
+const (
+	HookAbort    = "abort"    // Skip the snapshot and alert
+	HookContinue = "continue" // Take the snapshot anyway and alert
+)
+
+// SnapshotHook runs around a job's snapshots with ZFSRABBIT_* environment
+// variables naming the dataset and snapshot, so application-consistent
+// snapshots are possible
+type SnapshotHook struct {
+	DrillHook `yaml:",inline"`
+	OnFailure string `yaml:"on_failure"` // abort (default) or continue; pre_snapshot hooks only
 }
 
 type SSHConfig struct {
@@ -38,27 +187,359 @@ type SSHConfig struct {
 	PrivateKey    string `yaml:"private_key"`
 	RemoteDataset string `yaml:"remote_dataset"`
 	MbufferSize   string `yaml:"mbuffer_size"`
+	MaxSendRate   string `yaml:"max_send_rate"` // Bytes per second with K/M/G suffix, e.g. 50M; unlimited if empty
+}
+
+// ParseRate parses a transfer rate such as "50M" into bytes per second.
+// Suffixes K, M and G are powers of 1024; "" and "0" mean unlimited.
+func ParseRate(rate string) (int64, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" {
+		return 0, nil
+	}
+
+	number := rate
+	multiplier := int64(1)
+	switch strings.ToUpper(rate[len(rate)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		number = rate[:len(rate)-1]
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected e.g. 500K, 50M or 1G", rate)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// RemoteConfig is an additional replication target. Every snapshot is sent
+// to ssh and to each remote; restores, browsing and self-backup use ssh only.
+type RemoteConfig struct {
+	Name      string           `yaml:"name"`
+	SSHConfig `yaml:",inline"` // private_key, mbuffer_size and max_send_rate default to the ssh section's
+}
+
+// JobConfig replicates another dataset alongside the zfs section, with its
+// own schedule, retention and target. Connection settings left empty in
+// target are taken from the ssh section.
+type JobConfig struct {
+	Name         string `yaml:"name"`
+	ZFSConfig    `yaml:",inline"`
+	SnapshotCron string    `yaml:"snapshot_cron"` // Defaults to schedule.snapshot_cron
+	Target       SSHConfig `yaml:"target"`
 }
 
 type EmailConfig struct {
 	SMTPHost     string   `yaml:"smtp_host"`
 	SMTPPort     int      `yaml:"smtp_port"`
 	SMTPUser     string   `yaml:"smtp_user"`
-	SMTPPassword string   `yaml:"smtp_password"`
+	SMTPPassword string   `yaml:"smtp_password" secret:"true"`
 	FromEmail    string   `yaml:"from_email"`
 	ToEmails     []string `yaml:"to_emails"`
 	UseTLS       bool     `yaml:"use_tls"`
+	// Rate limits per rolling hour; alerts over either limit are summarized
+	// in an hourly digest. 0 disables the limit.
+	MaxPerHour           int `yaml:"max_per_hour"`
+	MaxPerSubjectPerHour int `yaml:"max_per_subject_per_hour"`
+
+	Digest EmailDigestConfig `yaml:"digest"`
+
+	// HTML sends an HTML part alongside the plain text, rendered from the
+	// built-in templates overlaid with any *.html in TemplateDir
+	HTML        bool   `yaml:"html"`
+	TemplateDir string `yaml:"template_dir"`
+}
+
+// EmailDigestConfig batches alerts below CRITICAL and sync notifications
+// into one summary email per schedule instead of an email each
+type EmailDigestConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Cron    string `yaml:"cron"` // When the digest is sent, hourly by default
 }
 
 type SlackConfig struct {
-	WebhookURL    string `yaml:"webhook_url"`
+	WebhookURL    string `yaml:"webhook_url" secret:"true"`
 	Channel       string `yaml:"channel"`
 	Username      string `yaml:"username"`
 	IconEmoji     string `yaml:"icon_emoji"`
 	Enabled       bool   `yaml:"enabled"`
 	AlertOnSync   bool   `yaml:"alert_on_sync"`
 	AlertOnErrors bool   `yaml:"alert_on_errors"`
-	SlashToken    string `yaml:"slash_token"`
+	SlashToken    string `yaml:"slash_token" secret:"true"` // Verification token; used only without signing_secret
+	// Signing secret of the Slack app, which commands, dialogs and options
+	// requests are verified with. Slack requests are refused if neither this
+	// nor slash_token is set.
+	SigningSecret string `yaml:"signing_secret" secret:"true"`
+	// Bot token (xoxb-...) used to open the restore dialog; typed restore
+	// arguments are the only option without it
+	BotToken string `yaml:"bot_token" secret:"true"`
+	// Slack user names or IDs allowed to restore and approve restore
+	// requests. Everyone may restore if this and roles are both empty.
+	AdminUsers []string `yaml:"admin_users"`
+	// Slack user names or IDs mapped to requester, viewer, operator or admin
+	Roles map[string]string `yaml:"roles"`
+	// Role for users not in admin_users or roles; operator by default
+	DefaultRole string `yaml:"default_role"`
+	// Post alerts raised within incident_window of the previous one as
+	// replies in a single thread per incident. Needs bot_token with the
+	// chat:write scope, and channel.
+	ThreadIncidents bool          `yaml:"thread_incidents"`
+	IncidentWindow  time.Duration `yaml:"incident_window"`
+}
+
+// SlackRole returns the role of a Slack user, matched by name or ID
+func (s *SlackConfig) SlackRole(userName, userID string) string {
+	if len(s.AdminUsers) == 0 && len(s.Roles) == 0 {
+		return RoleAdmin
+	}
+	for _, user := range s.AdminUsers {
+		if user == userName || user == userID {
+			return RoleAdmin
+		}
+	}
+	if role, ok := s.Roles[userID]; ok {
+		return role
+	}
+	if role, ok := s.Roles[userName]; ok {
+		return role
+	}
+	if s.DefaultRole != "" {
+		return s.DefaultRole
+	}
+	return RoleOperator
+}
+
+// TelegramConfig sends alerts through a Telegram bot and can take status,
+// snapshots and snapshot commands from the same chats
+type TelegramConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	BotToken    string  `yaml:"bot_token" secret:"true"` // From @BotFather, e.g. 123456:ABC-DEF...
+	ChatIDs     []int64 `yaml:"chat_ids"`                // Users, groups or channels; groups are negative
+	AlertOnSync bool    `yaml:"alert_on_sync"`
+	// Long-poll the bot for /status, /snapshots and /snapshot sent from
+	// chat_ids. Messages from any other chat are ignored.
+	Commands bool `yaml:"commands"`
+}
+
+// TeamsConfig posts alerts to a Microsoft Teams channel as Adaptive Cards
+type TeamsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Incoming webhook, or the URL of a Workflows "When a Teams webhook
+	// request is received" trigger
+	WebhookURL  string `yaml:"webhook_url" secret:"true"`
+	AlertOnSync bool   `yaml:"alert_on_sync"`
+}
+
+// PushConfig sends alerts as phone push notifications through ntfy or Gotify
+type PushConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Provider    string `yaml:"provider"`            // ntfy or gotify
+	Server      string `yaml:"server"`              // e.g. https://ntfy.sh or the Gotify server URL
+	Topic       string `yaml:"topic"`               // ntfy only
+	Token       string `yaml:"token" secret:"true"` // ntfy access token, or Gotify application token
+	AlertOnSync bool   `yaml:"alert_on_sync"`
+}
+
+// SNMPConfig controls SNMPv2c traps sent to a network management system
+type SNMPConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	Targets       []string `yaml:"targets"` // host or host:port, port 162 by default
+	Community     string   `yaml:"community" secret:"true"`
+	EnterpriseOID string   `yaml:"enterprise_oid"` // Base OID for zfsrabbit traps and objects
+}
+
+// SyslogConfig controls RFC 5424 syslog output for alerts and audit events
+type SyslogConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Network  string `yaml:"network"`  // local, udp, tcp or tls
+	Address  string `yaml:"address"`  // host:port of a remote collector
+	Facility string `yaml:"facility"` // e.g. daemon, auth, local0
+	CAFile   string `yaml:"ca_file"`  // CA bundle for tls, system roots if empty
+	Alerts   bool   `yaml:"alerts"`
+	Audit    bool   `yaml:"audit"`
+}
+
+// SMSConfig controls last-resort SMS and voice escalation of EMERGENCY alerts
+type SMSConfig struct {
+	Enabled    bool           `yaml:"enabled"`
+	Provider   string         `yaml:"provider"` // twilio or gateway
+	AccountSID string         `yaml:"account_sid"`
+	AuthToken  string         `yaml:"auth_token" secret:"true"`
+	From       string         `yaml:"from"`
+	Voice      bool           `yaml:"voice"`                     // Also place a call (twilio only)
+	GatewayURL string         `yaml:"gateway_url" secret:"true"` // gateway: receives POST {"to", "message"}
+	Recipients []SMSRecipient `yaml:"recipients"`
+}
+
+// SMSRecipient is a phone number paged during its schedule
+type SMSRecipient struct {
+	Name     string   `yaml:"name"`
+	Phone    string   `yaml:"phone"`    // E.164, e.g. +15551234567
+	Days     []string `yaml:"days"`     // mon..sun, every day if empty
+	Hours    string   `yaml:"hours"`    // HH:MM-HH:MM, may wrap midnight; all day if empty
+	Timezone string   `yaml:"timezone"` // IANA zone, local time if empty
+}
+
+// WebhookConfig posts every alert as JSON to an HTTP receiver. Without a
+// template the body is an Alertmanager v2 alert list, so url can point at
+// Alertmanager's /api/v2/alerts.
+type WebhookConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url" secret:"true"`
+	Headers map[string]string `yaml:"headers" secret:"true"` // e.g. Authorization: "Bearer ..."
+	// Go text/template producing the JSON body, for receivers that expect
+	// their own format; see README for the fields and functions available
+	Template string `yaml:"template"`
+}
+
+// webhookTemplateFuncs are the functions webhook templates can call
+var webhookTemplateFuncs = template.FuncMap{
+	// json quotes a value for use in the JSON body
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// ParseTemplate parses the webhook's template, returning nil if it has none
+func (w *WebhookConfig) ParseTemplate() (*template.Template, error) {
+	if w.Template == "" {
+		return nil, nil
+	}
+	return template.New(w.Name).Option("missingkey=error").Funcs(webhookTemplateFuncs).Parse(w.Template)
+}
+
+func (w *WebhookConfig) validate() error {
+	if !strings.HasPrefix(w.URL, "https://") && !strings.HasPrefix(w.URL, "http://") {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	for name := range w.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return fmt.Errorf("headers: invalid header name %q", name)
+		}
+	}
+	if _, err := w.ParseTemplate(); err != nil {
+		return fmt.Errorf("template: %w", err)
+	}
+	return nil
+}
+
+// OwnerConfig sends alerts about some datasets to the team that owns them.
+// The email and slack sections then only receive those alerts when they are
+// CRITICAL or worse.
+type OwnerConfig struct {
+	Name            string   `yaml:"name"`
+	Datasets        []string `yaml:"datasets"`                        // Alerts about these datasets or their children
+	Emails          []string `yaml:"emails"`                          // Sent through the email section's SMTP server
+	SlackWebhookURL string   `yaml:"slack_webhook_url" secret:"true"` // The slack section's webhook if empty
+	SlackChannel    string   `yaml:"slack_channel"`
+}
+
+// Owns reports whether dataset is one of the owner's datasets or a child of one
+func (o OwnerConfig) Owns(dataset string) bool {
+	for _, owned := range o.Datasets {
+		if dataset == owned || strings.HasPrefix(dataset, owned+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertRoute sends a channel the alerts of at least a severity and,
+// optionally, of some types. A channel with routes only receives alerts
+// matching one of them.
+type AlertRoute struct {
+	Channel     string   `yaml:"channel"`      // email, slack, telegram, teams, push, sms, snmp, syslog or webhook:<name>
+	MinSeverity string   `yaml:"min_severity"` // info, warning, critical or emergency; info if empty
+	Types       []string `yaml:"types"`        // Any of AlertTypes; every type if empty
+}
+
+// AlertChannels are the channels alert routes can name, besides webhook:<name>
+var AlertChannels = []string{"email", "slack", "telegram", "teams", "push", "sms", "snmp", "syslog"}
+
+// AlertTypes are the kinds of alert routes can select, told apart by subject
+var AlertTypes = []string{"pool", "capacity", "disk", "event", "sync", "replication", "standby", "drift", "check", "restore", "other"}
+
+// severityRanks orders alert severities for min_severity
+var severityRanks = map[string]int{
+	"info":      1,
+	"warning":   2,
+	"critical":  3,
+	"emergency": 4,
+}
+
+// Matches reports whether an alert of kind and severity (e.g. "CRITICAL")
+// is sent by this route
+func (r AlertRoute) Matches(kind, severity string) bool {
+	if severityRanks[strings.ToLower(severity)] < severityRanks[strings.ToLower(r.MinSeverity)] {
+		return false
+	}
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, t := range r.Types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (r AlertRoute) validate(c *Config) error {
+	known := false
+	for _, channel := range AlertChannels {
+		known = known || r.Channel == channel
+	}
+	if name, ok := strings.CutPrefix(r.Channel, "webhook:"); ok {
+		for _, webhook := range c.Webhooks {
+			known = known || webhook.Name == name
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown channel %q", r.Channel)
+	}
+
+	if r.MinSeverity != "" && severityRanks[strings.ToLower(r.MinSeverity)] == 0 {
+		return fmt.Errorf("min_severity must be info, warning, critical or emergency")
+	}
+	for _, t := range r.Types {
+		valid := false
+		for _, known := range AlertTypes {
+			valid = valid || t == known
+		}
+		if !valid {
+			return fmt.Errorf("unknown type %q", t)
+		}
+	}
+	return nil
+}
+
+// ParseHoursRange parses "HH:MM-HH:MM" into minutes after midnight
+func ParseHoursRange(hours string) (int, int, error) {
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("hours %q must be HH:MM-HH:MM", hours)
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("hours %q: %w", hours, err)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("hours %q: %w", hours, err)
+	}
+
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
 }
 
 type ScheduleConfig struct {
@@ -66,58 +547,497 @@ type ScheduleConfig struct {
 	ScrubCron       string        `yaml:"scrub_cron"`
 	RetryCron       string        `yaml:"retry_cron"`
 	MonitorInterval time.Duration `yaml:"monitor_interval"`
+
+	MaxConcurrentJobs int `yaml:"max_concurrent_jobs"` // Scheduled snapshot jobs allowed to run at once
+
+	IdleDeferral IdleDeferralConfig `yaml:"idle_deferral"`
+}
+
+// IdleDeferralConfig holds scheduled sends back while the pool is busy, so
+// replication doesn't compete with batch jobs for the same disks. Snapshots
+// are still taken on time; their sends wait in the retry queue until a
+// retry finds the pool quiet.
+type IdleDeferralConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	MaxBandwidth string        `yaml:"max_bandwidth"` // Read plus write throughput above which the pool is busy, e.g. 200M
+	MaxLatency   time.Duration `yaml:"max_latency"`   // Average IO wait above which the pool is busy
+	Sample       time.Duration `yaml:"sample"`        // How long zpool iostat measures for
+	MaxDelay     time.Duration `yaml:"max_delay"`     // Send anyway once sends have waited this long; 0 waits indefinitely
+}
+
+// MonitorConfig controls the independent health check loops. A zero interval
+// falls back to schedule.monitor_interval.
+type MonitorConfig struct {
+	PoolInterval            time.Duration      `yaml:"pool_interval"`
+	DiskInterval            time.Duration      `yaml:"disk_interval"`
+	CapacityInterval        time.Duration      `yaml:"capacity_interval"`
+	PropertyInterval        time.Duration      `yaml:"property_interval"` // How often expected ZFS properties are compared
+	CheckTimeout            time.Duration      `yaml:"check_timeout"`
+	CapacityWarningPercent  int                `yaml:"capacity_warning_percent"`
+	CapacityCriticalPercent int                `yaml:"capacity_critical_percent"`
+	ScriptChecks            []ScriptCheck      `yaml:"script_checks"`
+	ScanThrottle            ScanThrottleConfig `yaml:"scan_throttle"`
+	Events                  EventsConfig       `yaml:"events"`
+
+	// SMART polling: how many disks are read at once, per-disk check
+	// intervals keyed by disk ID, serial, by-id path or device, and whether
+	// to leave spun down disks alone (smartctl -n standby)
+	SMARTConcurrency int                      `yaml:"smart_concurrency"`
+	DiskIntervals    map[string]time.Duration `yaml:"disk_intervals"`
+	SkipStandby      bool                     `yaml:"skip_standby"`
+
+	Thresholds ThresholdsConfig `yaml:"thresholds"`
+}
+
+// ThresholdsConfig sets the SMART levels disks are alerted at, per device
+// class and per disk. Disks entries are keyed like disk_intervals and only
+// override the fields they set.
+type ThresholdsConfig struct {
+	HDD   SMARTThresholds            `yaml:"hdd"` // HDDs and SATA/SAS SSDs
+	NVMe  SMARTThresholds            `yaml:"nvme"`
+	Disks map[string]SMARTThresholds `yaml:"disks"`
+}
+
+// SMARTThresholds are the warning, critical and emergency levels for disk
+// temperature (°C), NVMe wear (percentage used) and NVMe available spare
+// (percent, alerting as it falls). Zero keeps the built-in level.
+type SMARTThresholds struct {
+	TempWarning    int `yaml:"temp_warning"`
+	TempCritical   int `yaml:"temp_critical"`
+	TempEmergency  int `yaml:"temp_emergency"`
+	WearWarning    int `yaml:"wear_warning"`
+	WearCritical   int `yaml:"wear_critical"`
+	WearEmergency  int `yaml:"wear_emergency"`
+	SpareWarning   int `yaml:"spare_warning"`
+	SpareCritical  int `yaml:"spare_critical"`
+	SpareEmergency int `yaml:"spare_emergency"`
+}
+
+// Merge returns t with the fields set in override replaced
+func (t SMARTThresholds) Merge(override SMARTThresholds) SMARTThresholds {
+	pick := func(base, value int) int {
+		if value != 0 {
+			return value
+		}
+		return base
+	}
+	return SMARTThresholds{
+		TempWarning:    pick(t.TempWarning, override.TempWarning),
+		TempCritical:   pick(t.TempCritical, override.TempCritical),
+		TempEmergency:  pick(t.TempEmergency, override.TempEmergency),
+		WearWarning:    pick(t.WearWarning, override.WearWarning),
+		WearCritical:   pick(t.WearCritical, override.WearCritical),
+		WearEmergency:  pick(t.WearEmergency, override.WearEmergency),
+		SpareWarning:   pick(t.SpareWarning, override.SpareWarning),
+		SpareCritical:  pick(t.SpareCritical, override.SpareCritical),
+		SpareEmergency: pick(t.SpareEmergency, override.SpareEmergency),
+	}
+}
+
+// validate checks that the levels set are in range and escalate in order
+func (t SMARTThresholds) validate(name string) error {
+	levels := []struct {
+		field  string
+		values []int
+		max    int
+		rising bool
+	}{
+		{"temp", []int{t.TempWarning, t.TempCritical, t.TempEmergency}, 200, true},
+		{"wear", []int{t.WearWarning, t.WearCritical, t.WearEmergency}, 255, true},
+		{"spare", []int{t.SpareWarning, t.SpareCritical, t.SpareEmergency}, 100, false},
+	}
+	for _, level := range levels {
+		previous := 0
+		for _, value := range level.values {
+			if value < 0 || value > level.max {
+				return fmt.Errorf("%s: %s thresholds must be between 0 and %d", name, level.field, level.max)
+			}
+			if value == 0 {
+				continue
+			}
+			if previous != 0 && ((level.rising && value < previous) || (!level.rising && value > previous)) {
+				return fmt.Errorf("%s: %s thresholds must escalate from warning to critical to emergency", name, level.field)
+			}
+			previous = value
+		}
+	}
+	return nil
+}
+
+// ScanThrottleConfig slows disk checks while a pool is resilvering or
+// scrubbing, so monitoring doesn't add IO to pools that are already busy
+type ScanThrottleConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	DiskInterval time.Duration `yaml:"disk_interval"` // Disk check interval while a scan runs
+	SkipSMART    bool          `yaml:"skip_smart"`    // Only check disk paths, not SMART data, while a scan runs
+}
+
+// EventsConfig follows `zpool events` so checksum errors, removed devices and
+// resilvers are alerted on as they happen rather than at the next pool check
+type EventsConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Cooldown time.Duration `yaml:"cooldown"` // Minimum time between alerts for the same event on the same device
+}
+
+// ScriptCheck is a site-specific check command whose failures are alerted on
+// like any built-in check
+type ScriptCheck struct {
+	Name             string        `yaml:"name"`
+	Command          string        `yaml:"command"`
+	Args             []string      `yaml:"args"`
+	Interval         time.Duration `yaml:"interval"`
+	Timeout          time.Duration `yaml:"timeout"`
+	ExpectedExitCode int           `yaml:"expected_exit_code"`
+	Severity         string        `yaml:"severity"` // warning (default), critical or emergency
+}
+
+// ExportConfig controls periodic writing of the status JSON to a file for
+// collectors that can't reach the HTTP API
+type ExportConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SelfBackupConfig controls copying zfsrabbit's own config and state
+// directory to the backup server after each successful sync
+type SelfBackupConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	RemoteDir string `yaml:"remote_dir"`
+}
+
+// UpdateConfig controls the optional check for newer releases. Nothing
+// is fetched unless it is enabled.
+type UpdateConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	URL      string        `yaml:"url"` // GitHub latest-release API, or any endpoint returning {"version", "url"}
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SLAConfig is a backup service level for one replicated dataset. At least
+// one of FinishBy and MaxAge must be set.
+type SLAConfig struct {
+	Dataset  string        `yaml:"dataset"`   // Defaults to zfs.dataset
+	FinishBy string        `yaml:"finish_by"` // HH:MM each day's replication must complete by
+	MaxAge   time.Duration `yaml:"max_age"`   // Longest allowed time since the last successful replication
+	Timezone string        `yaml:"timezone"`  // IANA zone for finish_by, server local time if empty
+}
+
+// DatasetOr returns the SLA's dataset, or fallback when none is set
+func (s SLAConfig) DatasetOr(fallback string) string {
+	if s.Dataset == "" {
+		return fallback
+	}
+	return s.Dataset
+}
+
+// ParseClock parses "HH:MM" into minutes after midnight
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("time %q must be HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// DrillConfig controls DR drills: restores into an isolated namespace
+// followed by verification hooks and a timed report
+type DrillConfig struct {
+	Namespace string      `yaml:"namespace"` // Drills restore into <namespace>/<date>
+	Cleanup   bool        `yaml:"cleanup"`   // Destroy drill datasets once the report is written
+	Hooks     []DrillHook `yaml:"hooks"`
+}
+
+// DrillHook verifies a restored dataset. It runs once per dataset with
+// ZFSRABBIT_DRILL_* environment variables describing it; exit 0 passes.
+type DrillHook struct {
+	Name    string        `yaml:"name"`
+	Command string        `yaml:"command"`
+	Args    []string      `yaml:"args"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RestoreConfig controls what happens after a restore is received
+type RestoreConfig struct {
+	MountHooks      []RestoreHook     `yaml:"mount_hooks"`      // Run once a restore is mounted, e.g. to share it over NFS or SMB
+	PostHooks       []PostRestoreHook `yaml:"post_hooks"`       // Run after restores into matching datasets, e.g. to fix ownership
+	BrowseNamespace string            `yaml:"browse_namespace"` // Remote snapshots are received under <namespace>/<session> for file restores
+	BrowseTTL       time.Duration     `yaml:"browse_ttl"`       // File restore sessions are cleaned up after this long
+	// Datasets of a snapshot's tree received at once, each with its own
+	// send; 1 receives the tree as a single zfs send -R stream
+	Parallelism int `yaml:"parallelism"`
+}
+
+// RestoreHook takes the same fields as a drill hook and runs with
+// ZFSRABBIT_RESTORE_* environment variables describing the restored dataset
+type RestoreHook = DrillHook
+
+// PostRestoreHook runs after every restore into a dataset matching Dataset,
+// a path.Match pattern such as "tank/home/*"; an empty pattern matches all
+type PostRestoreHook struct {
+	Dataset     string `yaml:"dataset"`
+	RestoreHook `yaml:",inline"`
+}
+
+// Matches reports whether the hook applies to a restored dataset
+func (h PostRestoreHook) Matches(dataset string) bool {
+	if h.Dataset == "" {
+		return true
+	}
+	matched, err := path.Match(h.Dataset, dataset)
+	return err == nil && matched
+}
+
+// TieringConfig moves old snapshots off the primary backup pool into a
+// cheaper archive tier
+type TieringConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	AfterDays  int    `yaml:"after_days"`  // Snapshots older than this are archived
+	ArchiveDir string `yaml:"archive_dir"` // Directory on the backup server that holds archived streams
+}
+
+// S3Config uploads every snapshot to S3-compatible object storage as
+// chunked, compressed and optionally encrypted objects, alongside the SSH
+// targets
+type S3Config struct {
+	Enabled           bool   `yaml:"enabled"`
+	Endpoint          string `yaml:"endpoint"` // e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Region            string `yaml:"region"`
+	Bucket            string `yaml:"bucket"`
+	Prefix            string `yaml:"prefix"`                   // Streams are stored under <prefix>/<dataset>/<snapshot>/
+	AccessKey         string `yaml:"access_key" secret:"true"` // AWS_ACCESS_KEY_ID if empty
+	SecretKey         string `yaml:"secret_key" secret:"true"` // AWS_SECRET_ACCESS_KEY if empty
+	PathStyle         bool   `yaml:"path_style"`               // Bucket in the URL path, as MinIO expects
+	ChunkSize         string `yaml:"chunk_size"`               // Size of each object before compression, e.g. 64M
+	Compression       string `yaml:"compression"`
+	EncryptionKeyFile string `yaml:"encryption_key_file"` // 64 hex characters; chunks are sent unencrypted if empty
+}
+
+// Standby mount modes for the received datasets
+const (
+	StandbyReadOnly  = "readonly"
+	StandbyUnmounted = "unmounted"
+)
+
+// StandbyConfig runs the primary backup target as a warm standby: the
+// received datasets are kept read-only or unmounted, and the target is
+// checked periodically for whether it could take over
+type StandbyConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Mount      string        `yaml:"mount"`      // readonly or unmounted
+	Interval   time.Duration `yaml:"interval"`   // Time between readiness checks
+	Properties []string      `yaml:"properties"` // Must have the same value on the source and the standby
+	Probes     []DrillHook   `yaml:"probes"`     // App-specific checks, run with ZFSRABBIT_STANDBY_* variables
+}
+
+// StoreConfig bounds the history kept in the state directory's stores
+type StoreConfig struct {
+	HistoryDays int    `yaml:"history_days"` // Drop history older than this; 0 keeps it forever
+	MaxHistory  int    `yaml:"max_history"`  // Rows of history kept per store; 0 means no limit
+	CompactCron string `yaml:"compact_cron"` // When to trim and rewrite the stores; empty disables it
+}
+
+// DisplayConfig controls how temperatures, times and text are shown to
+// people in the web UI, Slack and alerts. Machine-readable output is unaffected.
+type DisplayConfig struct {
+	TemperatureUnit string `yaml:"temperature_unit"` // celsius (default) or fahrenheit
+	DateFormat      string `yaml:"date_format"`      // iso (default), us or eu
+	Timezone        string `yaml:"timezone"`         // IANA zone, server local time if empty
+	Locale          string `yaml:"locale"`           // Language of the web UI and alert text, e.g. de; en if empty
+	MessagesDir     string `yaml:"messages_dir"`     // Holds <locale>.json catalogs that add or override messages
 }
 
 func Load(path string) (*Config, error) {
 	cfg := &Config{
+		Version: CurrentVersion,
 		Server: ServerConfig{
 			Port:         8080,
 			AdminPassEnv: "ZFSRABBIT_ADMIN_PASSWORD",
 			LogLevel:     "info",
+			LogFormat:    "text",
+			StateDir:     "/var/lib/zfsrabbit",
+			SessionTTL:   12 * time.Hour,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 2 * time.Minute,
+			IdleTimeout:  2 * time.Minute,
+			DrainTimeout: 5 * time.Minute,
 		},
 		ZFS: ZFSConfig{
 			SendCompression: "lz4",
 			Recursive:       true,
+			KeepSnapshots:   30,
 		},
 		SSH: SSHConfig{
 			MbufferSize: "1G",
 		},
 		Email: EmailConfig{
-			SMTPPort: 587,
-			UseTLS:   true,
+			SMTPPort:             587,
+			UseTLS:               true,
+			MaxPerHour:           20,
+			MaxPerSubjectPerHour: 3,
+			Digest: EmailDigestConfig{
+				Cron: "0 * * * *",
+			},
 		},
 		Slack: SlackConfig{
-			Username:      "ZFSRabbit",
-			IconEmoji:     ":rabbit:",
-			Enabled:       false,
-			AlertOnSync:   true,
-			AlertOnErrors: true,
+			Username:       "ZFSRabbit",
+			IconEmoji:      ":rabbit:",
+			Enabled:        false,
+			AlertOnSync:    true,
+			AlertOnErrors:  true,
+			IncidentWindow: 30 * time.Minute,
+		},
+		SNMP: SNMPConfig{
+			Community:     "public",
+			EnterpriseOID: "1.3.6.1.4.1.8072.9999.9999.1",
+		},
+		Syslog: SyslogConfig{
+			Network:  "local",
+			Facility: "daemon",
+			Alerts:   true,
+			Audit:    true,
+		},
+		SMS: SMSConfig{
+			Provider: "twilio",
+		},
+		Telegram: TelegramConfig{
+			AlertOnSync: true,
+		},
+		Teams: TeamsConfig{
+			AlertOnSync: true,
+		},
+		Push: PushConfig{
+			Provider: "ntfy",
+			Server:   "https://ntfy.sh",
 		},
 		Schedule: ScheduleConfig{
 			SnapshotCron:    "0 2 * * *",  // Daily at 2 AM
 			ScrubCron:       "0 3 * * 0",  // Weekly on Sunday at 3 AM
 			RetryCron:       "*/15 * * * *", // Every 15 minutes
 			MonitorInterval: 5 * time.Minute,
+
+			MaxConcurrentJobs: 2,
+
+			IdleDeferral: IdleDeferralConfig{
+				Sample:   10 * time.Second,
+				MaxDelay: 6 * time.Hour,
+			},
+		},
+		Monitor: MonitorConfig{
+			CheckTimeout:            2 * time.Minute,
+			CapacityWarningPercent:  80,
+			CapacityCriticalPercent: 90,
+			ScanThrottle: ScanThrottleConfig{
+				Enabled:      true,
+				DiskInterval: time.Hour,
+			},
+			Events: EventsConfig{
+				Enabled:  true,
+				Cooldown: 10 * time.Minute,
+			},
+			SMARTConcurrency: 4,
+			SkipStandby:      true,
+		},
+		Export: ExportConfig{
+			Interval: 1 * time.Minute,
+		},
+		SelfBackup: SelfBackupConfig{
+			RemoteDir: "/var/backups/zfsrabbit",
+		},
+		Update: UpdateConfig{
+			URL:      "https://api.github.com/repos/helixml/zfsrabbit/releases/latest",
+			Interval: 24 * time.Hour,
+		},
+		Restore: RestoreConfig{
+			BrowseTTL:   time.Hour,
+			Parallelism: 1,
+		},
+		S3: S3Config{
+			Region:      "us-east-1",
+			Prefix:      "zfsrabbit",
+			ChunkSize:   "64M",
+			Compression: "gzip",
+		},
+		Standby: StandbyConfig{
+			Mount:      StandbyReadOnly,
+			Interval:   15 * time.Minute,
+			Properties: []string{"compression", "recordsize", "encryption"},
 		},
+		Store: StoreConfig{
+			HistoryDays: 365,
+			MaxHistory:  5000,
+			CompactCron: "30 4 * * *",
+		},
+		Path: path,
 	}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return cfg, nil
+	// Without a file the config comes from the defaults and the environment
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := decodeVersioned(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
 	}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
+	if err := applyEnv(cfg); err != nil {
 		return nil, err
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	for i := range cfg.Remotes {
+		remote := &cfg.Remotes[i]
+		if remote.PrivateKey == "" {
+			remote.PrivateKey = cfg.SSH.PrivateKey
+		}
+		if remote.MbufferSize == "" {
+			remote.MbufferSize = cfg.SSH.MbufferSize
+		}
+		if remote.MaxSendRate == "" {
+			remote.MaxSendRate = cfg.SSH.MaxSendRate
+		}
+	}
+
+	for i := range cfg.Jobs {
+		job := &cfg.Jobs[i]
+		if job.SendCompression == "" {
+			job.SendCompression = cfg.ZFS.SendCompression
+		}
+		if job.KeepSnapshots == 0 {
+			job.KeepSnapshots = cfg.ZFS.KeepSnapshots
+		}
+		if job.SnapshotCron == "" {
+			job.SnapshotCron = cfg.Schedule.SnapshotCron
+		}
+		if job.Properties == nil {
+			job.Properties = cfg.ZFS.Properties
+		}
+		if job.MaxLag == 0 {
+			job.MaxLag = cfg.ZFS.MaxLag
+		}
+		target := &job.Target
+		if target.RemoteHost == "" {
+			target.RemoteHost = cfg.SSH.RemoteHost
+		}
+		if target.RemoteUser == "" {
+			target.RemoteUser = cfg.SSH.RemoteUser
+		}
+		if target.PrivateKey == "" {
+			target.PrivateKey = cfg.SSH.PrivateKey
+		}
+		if target.MbufferSize == "" {
+			target.MbufferSize = cfg.SSH.MbufferSize
+		}
+		if target.MaxSendRate == "" {
+			target.MaxSendRate = cfg.SSH.MaxSendRate
+		}
 	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	cfg.warnOverlaps()
 
 	return cfg, nil
 }
@@ -128,11 +1048,91 @@ func (c *Config) Validate() error {
 	if err := validation.ValidatePort(c.Server.Port); err != nil {
 		return fmt.Errorf("server port: %w", err)
 	}
+	if c.Server.GRPCPort != 0 {
+		if err := validation.ValidatePort(c.Server.GRPCPort); err != nil {
+			return fmt.Errorf("server.grpc_port: %w", err)
+		}
+		if c.Server.GRPCPort == c.Server.Port {
+			return fmt.Errorf("server.grpc_port must differ from server.port")
+		}
+	}
 
 	if c.Server.AdminPassEnv == "" {
 		return fmt.Errorf("server.admin_pass_env cannot be empty")
 	}
 
+	switch strings.ToLower(c.Server.LogLevel) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		return fmt.Errorf("server.log_level must be debug, info, warn or error, got %q", c.Server.LogLevel)
+	}
+	switch strings.ToLower(c.Server.LogFormat) {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("server.log_format must be text or json, got %q", c.Server.LogFormat)
+	}
+
+	requesters := make(map[string]bool)
+	for i, requester := range c.Server.Requesters {
+		if requester.User == "" || requester.User == "admin" {
+			return fmt.Errorf("server.requesters[%d].user must be set and not \"admin\"", i)
+		}
+		if requesters[requester.User] {
+			return fmt.Errorf("server.requesters: duplicate user %q", requester.User)
+		}
+		requesters[requester.User] = true
+		if requester.PassEnv == "" {
+			return fmt.Errorf("server.requesters[%s].pass_env cannot be empty", requester.User)
+		}
+	}
+
+	for i, user := range c.Server.Users {
+		if user.User == "" || user.User == "admin" {
+			return fmt.Errorf("server.users[%d].user must be set and not \"admin\"", i)
+		}
+		if requesters[user.User] {
+			return fmt.Errorf("server.users: duplicate user %q", user.User)
+		}
+		requesters[user.User] = true
+		if !ValidRole(user.Role) {
+			return fmt.Errorf("server.users[%s].role must be requester, viewer, operator or admin", user.User)
+		}
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return fmt.Errorf("server.users[%s].password_hash is not a bcrypt hash: %w", user.User, err)
+		}
+	}
+
+	if c.Server.SessionTTL < 0 {
+		return fmt.Errorf("server.session_ttl cannot be negative")
+	}
+	for name, timeout := range map[string]time.Duration{
+		"read_timeout":  c.Server.ReadTimeout,
+		"write_timeout": c.Server.WriteTimeout,
+		"idle_timeout":  c.Server.IdleTimeout,
+		"drain_timeout": c.Server.DrainTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("server.%s cannot be negative", name)
+		}
+	}
+
+	if c.Server.StateDir != "" && !filepath.IsAbs(c.Server.StateDir) {
+		return fmt.Errorf("server.state_dir must be an absolute path")
+	}
+
+	if c.Server.TLS.Enabled {
+		tls := c.Server.TLS
+		if (tls.CertFile == "") != (tls.KeyFile == "") {
+			return fmt.Errorf("server.tls: cert_file and key_file must be set together")
+		}
+		if tls.CertFile == "" && !tls.SelfSigned {
+			return fmt.Errorf("server.tls: cert_file and key_file are required unless self_signed is enabled")
+		}
+		if tls.CertFile == "" && c.Server.StateDir == "" {
+			return fmt.Errorf("server.tls.self_signed requires server.state_dir")
+		}
+	}
+
 	// ZFS validation
 	if c.ZFS.Dataset == "" {
 		return fmt.Errorf("zfs.dataset cannot be empty")
@@ -142,6 +1142,19 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("zfs.dataset: %w", err)
 	}
 
+	if err := validateRetention("zfs", &c.ZFS); err != nil {
+		return err
+	}
+	if err := validateSnapshotHooks("zfs", &c.ZFS); err != nil {
+		return err
+	}
+	if c.ZFS.MaxLag < 0 {
+		return fmt.Errorf("zfs.max_lag cannot be negative")
+	}
+	if err := validateProperties("zfs", c.ZFS.Properties); err != nil {
+		return err
+	}
+
 	// SSH validation
 	if c.SSH.RemoteHost == "" {
 		return fmt.Errorf("ssh.remote_host cannot be empty")
@@ -163,8 +1176,106 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("ssh.remote_dataset: %w", err)
 	}
 
-	// Email validation
-	if c.Email.SMTPHost != "" { // Email is optional
+	if _, err := ParseRate(c.SSH.MaxSendRate); err != nil {
+		return fmt.Errorf("ssh.max_send_rate: %w", err)
+	}
+
+	remoteNames := map[string]bool{"primary": true}
+	for i, remote := range c.Remotes {
+		if remote.Name == "" {
+			return fmt.Errorf("remotes[%d].name cannot be empty", i)
+		}
+		if remoteNames[remote.Name] {
+			return fmt.Errorf("remotes[%d].name %q is already in use", i, remote.Name)
+		}
+		remoteNames[remote.Name] = true
+
+		if remote.RemoteHost == "" || remote.RemoteUser == "" || remote.PrivateKey == "" {
+			return fmt.Errorf("remotes[%d]: remote_host, remote_user and private_key are required", i)
+		}
+		if err := validation.ValidateDatasetName(remote.RemoteDataset); err != nil {
+			return fmt.Errorf("remotes[%d].remote_dataset: %w", i, err)
+		}
+		if _, err := ParseRate(remote.MaxSendRate); err != nil {
+			return fmt.Errorf("remotes[%d].max_send_rate: %w", i, err)
+		}
+	}
+
+	if c.Schedule.MaxConcurrentJobs < 1 {
+		return fmt.Errorf("schedule.max_concurrent_jobs must be at least 1")
+	}
+
+	if deferral := c.Schedule.IdleDeferral; deferral.Enabled {
+		maxBandwidth, err := ParseRate(deferral.MaxBandwidth)
+		if err != nil {
+			return fmt.Errorf("schedule.idle_deferral.max_bandwidth: %w", err)
+		}
+		if maxBandwidth == 0 && deferral.MaxLatency <= 0 {
+			return fmt.Errorf("schedule.idle_deferral needs max_bandwidth or max_latency")
+		}
+		if deferral.Sample < time.Second || deferral.Sample > 5*time.Minute {
+			return fmt.Errorf("schedule.idle_deferral.sample must be between 1s and 5m")
+		}
+		if deferral.MaxDelay < 0 {
+			return fmt.Errorf("schedule.idle_deferral.max_delay cannot be negative")
+		}
+	}
+
+	jobNames := map[string]bool{"default": true}
+	datasets := map[string]bool{c.ZFS.Dataset: true}
+	destinations := map[string]bool{c.SSH.RemoteHost + ":" + c.SSH.RemoteDataset: true}
+	for i, job := range c.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("jobs[%d].name cannot be empty", i)
+		}
+		if jobNames[job.Name] {
+			return fmt.Errorf("jobs[%d].name %q is already in use", i, job.Name)
+		}
+		jobNames[job.Name] = true
+
+		section := fmt.Sprintf("jobs[%d]", i)
+		if err := validation.ValidateDatasetName(job.Dataset); err != nil {
+			return fmt.Errorf("%s.dataset: %w", section, err)
+		}
+		if datasets[job.Dataset] {
+			return fmt.Errorf("%s.dataset %q is already replicated", section, job.Dataset)
+		}
+		datasets[job.Dataset] = true
+
+		if err := validateRetention(section, &job.ZFSConfig); err != nil {
+			return err
+		}
+		if err := validateSnapshotHooks(section, &job.ZFSConfig); err != nil {
+			return err
+		}
+		if job.MaxLag < 0 {
+			return fmt.Errorf("%s.max_lag cannot be negative", section)
+		}
+		if err := validateProperties(section, job.Properties); err != nil {
+			return err
+		}
+		if err := validateCronExpression(job.SnapshotCron); err != nil {
+			return fmt.Errorf("%s: invalid snapshot_cron expression '%s': %w", section, job.SnapshotCron, err)
+		}
+
+		target := job.Target
+		if target.RemoteHost == "" || target.RemoteUser == "" || target.PrivateKey == "" {
+			return fmt.Errorf("%s.target: remote_host, remote_user and private_key are required", section)
+		}
+		if err := validation.ValidateDatasetName(target.RemoteDataset); err != nil {
+			return fmt.Errorf("%s.target.remote_dataset: %w", section, err)
+		}
+		if destinations[target.RemoteHost+":"+target.RemoteDataset] {
+			return fmt.Errorf("%s.target: %s:%s is already a replication target", section, target.RemoteHost, target.RemoteDataset)
+		}
+		destinations[target.RemoteHost+":"+target.RemoteDataset] = true
+		if _, err := ParseRate(target.MaxSendRate); err != nil {
+			return fmt.Errorf("%s.target.max_send_rate: %w", section, err)
+		}
+	}
+
+	// Email validation
+	if c.Email.SMTPHost != "" { // Email is optional
 		if err := validation.ValidatePort(c.Email.SMTPPort); err != nil {
 			return fmt.Errorf("email.smtp_port: %w", err)
 		}
@@ -180,6 +1291,22 @@ func (c *Config) Validate() error {
 				return fmt.Errorf("email.from_email: %w", err)
 			}
 		}
+
+		if c.Email.MaxPerHour < 0 || c.Email.MaxPerSubjectPerHour < 0 {
+			return fmt.Errorf("email rate limits must be 0 (unlimited) or positive")
+		}
+
+		if c.Email.Digest.Enabled {
+			if err := validateCronExpression(c.Email.Digest.Cron); err != nil {
+				return fmt.Errorf("invalid email.digest.cron expression '%s': %w", c.Email.Digest.Cron, err)
+			}
+		}
+
+		if c.Email.HTML {
+			if _, err := emailtemplate.Load(c.Email.TemplateDir); err != nil {
+				return fmt.Errorf("email.template_dir: %w", err)
+			}
+		}
 	}
 
 	// Slack validation
@@ -188,12 +1315,356 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("slack.webhook_url must be a valid Slack webhook URL")
 		}
 	}
+	for user, role := range c.Slack.Roles {
+		if !ValidRole(role) {
+			return fmt.Errorf("slack.roles[%s] must be requester, viewer, operator or admin", user)
+		}
+	}
+	if c.Slack.DefaultRole != "" && !ValidRole(c.Slack.DefaultRole) {
+		return fmt.Errorf("slack.default_role must be requester, viewer, operator or admin")
+	}
+	if c.Slack.ThreadIncidents {
+		if c.Slack.BotToken == "" || c.Slack.Channel == "" {
+			return fmt.Errorf("slack.thread_incidents requires slack.bot_token and slack.channel")
+		}
+		if c.Slack.IncidentWindow < time.Minute {
+			return fmt.Errorf("slack.incident_window must be at least 1m")
+		}
+	}
+
+	owners := make(map[string]bool)
+	for i, owner := range c.Owners {
+		if owner.Name == "" {
+			return fmt.Errorf("owners[%d].name cannot be empty", i)
+		}
+		if owners[owner.Name] {
+			return fmt.Errorf("owners: duplicate name %q", owner.Name)
+		}
+		owners[owner.Name] = true
+		if err := owner.validate(c); err != nil {
+			return fmt.Errorf("owners[%s]: %w", owner.Name, err)
+		}
+	}
+
+	if c.SNMP.Enabled {
+		if len(c.SNMP.Targets) == 0 {
+			return fmt.Errorf("snmp.targets must list at least one trap receiver")
+		}
+		if !oidPattern.MatchString(strings.TrimPrefix(c.SNMP.EnterpriseOID, ".")) {
+			return fmt.Errorf("snmp.enterprise_oid must be a dotted numeric OID")
+		}
+	}
+
+	if c.Syslog.Enabled {
+		switch c.Syslog.Network {
+		case "local":
+		case "udp", "tcp", "tls":
+			if _, _, err := net.SplitHostPort(c.Syslog.Address); err != nil {
+				return fmt.Errorf("syslog.address must be host:port for network %s", c.Syslog.Network)
+			}
+		default:
+			return fmt.Errorf("syslog.network must be local, udp, tcp or tls")
+		}
+		switch c.Syslog.Facility {
+		case "kern", "user", "daemon", "auth", "syslog",
+			"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7":
+		default:
+			return fmt.Errorf("syslog.facility %q is not supported", c.Syslog.Facility)
+		}
+	}
+
+	if c.Update.Enabled {
+		if !strings.HasPrefix(c.Update.URL, "https://") && !strings.HasPrefix(c.Update.URL, "http://") {
+			return fmt.Errorf("update_check.url must be an http(s) URL")
+		}
+		if c.Update.Interval < time.Hour {
+			return fmt.Errorf("update_check.interval must be at least 1h")
+		}
+	}
+
+	slaDatasets := make(map[string]bool)
+	for i, sla := range c.SLAs {
+		// An SLA on a dataset that isn't replicated could never be met
+		dataset := sla.DatasetOr(c.ZFS.Dataset)
+		if !datasets[dataset] {
+			return fmt.Errorf("sla[%d].dataset %q is not replicated by this instance", i, dataset)
+		}
+		if slaDatasets[dataset] {
+			return fmt.Errorf("sla[%d]: dataset %q already has an SLA", i, dataset)
+		}
+		slaDatasets[dataset] = true
+
+		if sla.FinishBy == "" && sla.MaxAge == 0 {
+			return fmt.Errorf("sla[%d]: finish_by or max_age is required", i)
+		}
+		if sla.FinishBy != "" {
+			if _, err := ParseClock(sla.FinishBy); err != nil {
+				return fmt.Errorf("sla[%d].finish_by: %w", i, err)
+			}
+		}
+		if sla.MaxAge < 0 {
+			return fmt.Errorf("sla[%d].max_age cannot be negative", i)
+		}
+		if sla.Timezone != "" {
+			if _, err := time.LoadLocation(sla.Timezone); err != nil {
+				return fmt.Errorf("sla[%d].timezone: %w", i, err)
+			}
+		}
+	}
+
+	switch c.Display.TemperatureUnit {
+	case "", "celsius", "fahrenheit":
+	default:
+		return fmt.Errorf("display.temperature_unit must be celsius or fahrenheit, got %q", c.Display.TemperatureUnit)
+	}
+	switch c.Display.DateFormat {
+	case "", "iso", "us", "eu":
+	default:
+		return fmt.Errorf("display.date_format must be iso, us or eu, got %q", c.Display.DateFormat)
+	}
+	if c.Display.Timezone != "" {
+		if _, err := time.LoadLocation(c.Display.Timezone); err != nil {
+			return fmt.Errorf("display.timezone: %w", err)
+		}
+	}
+	if _, err := i18n.Load(c.Display.Locale, c.Display.MessagesDir); err != nil {
+		return fmt.Errorf("display.locale: %w", err)
+	}
+
+	for name := range c.Features {
+		if _, ok := features.Lookup(name); !ok {
+			return fmt.Errorf("features: unknown flag %q", name)
+		}
+	}
+
+	if c.SMS.Enabled {
+		if err := c.SMS.validate(); err != nil {
+			return fmt.Errorf("sms: %w", err)
+		}
+	}
+
+	if c.Telegram.Enabled {
+		if err := c.Telegram.validate(); err != nil {
+			return fmt.Errorf("telegram: %w", err)
+		}
+	}
+
+	if c.Teams.Enabled && !strings.HasPrefix(c.Teams.WebhookURL, "https://") {
+		return fmt.Errorf("teams.webhook_url must be an https URL")
+	}
+
+	if c.Push.Enabled {
+		if err := c.Push.validate(); err != nil {
+			return fmt.Errorf("push: %w", err)
+		}
+	}
+
+	webhooks := make(map[string]bool)
+	for i, webhook := range c.Webhooks {
+		if webhook.Name == "" {
+			return fmt.Errorf("webhooks[%d].name cannot be empty", i)
+		}
+		if webhooks[webhook.Name] {
+			return fmt.Errorf("webhooks[%d].name %q is already in use", i, webhook.Name)
+		}
+		webhooks[webhook.Name] = true
+		if err := webhook.validate(); err != nil {
+			return fmt.Errorf("webhooks[%s]: %w", webhook.Name, err)
+		}
+	}
+
+	for i, route := range c.Routes {
+		if err := route.validate(c); err != nil {
+			return fmt.Errorf("alert_routes[%d]: %w", i, err)
+		}
+	}
 
 	// Schedule validation - validate cron expressions
 	if c.Schedule.MonitorInterval < time.Minute {
 		return fmt.Errorf("schedule.monitor_interval must be at least 1 minute")
 	}
 
+	// Monitor validation
+	for name, interval := range map[string]time.Duration{
+		"pool_interval":     c.Monitor.PoolInterval,
+		"disk_interval":     c.Monitor.DiskInterval,
+		"capacity_interval": c.Monitor.CapacityInterval,
+		"property_interval": c.Monitor.PropertyInterval,
+	} {
+		if interval != 0 && interval < time.Minute {
+			return fmt.Errorf("monitor.%s must be at least 1 minute", name)
+		}
+	}
+
+	if c.Monitor.CheckTimeout <= 0 {
+		return fmt.Errorf("monitor.check_timeout must be positive")
+	}
+
+	if c.Monitor.CapacityWarningPercent < 1 || c.Monitor.CapacityWarningPercent > 100 ||
+		c.Monitor.CapacityCriticalPercent < 1 || c.Monitor.CapacityCriticalPercent > 100 {
+		return fmt.Errorf("monitor capacity thresholds must be between 1 and 100")
+	}
+
+	if c.Monitor.CapacityWarningPercent > c.Monitor.CapacityCriticalPercent {
+		return fmt.Errorf("monitor.capacity_warning_percent cannot exceed capacity_critical_percent")
+	}
+
+	if c.Monitor.SMARTConcurrency < 1 {
+		return fmt.Errorf("monitor.smart_concurrency must be at least 1")
+	}
+
+	for disk, interval := range c.Monitor.DiskIntervals {
+		if interval < time.Minute {
+			return fmt.Errorf("monitor.disk_intervals[%s] must be at least 1 minute", disk)
+		}
+	}
+
+	if err := c.Monitor.Thresholds.HDD.validate("monitor.thresholds.hdd"); err != nil {
+		return err
+	}
+	if err := c.Monitor.Thresholds.NVMe.validate("monitor.thresholds.nvme"); err != nil {
+		return err
+	}
+	for disk, thresholds := range c.Monitor.Thresholds.Disks {
+		if err := thresholds.validate(fmt.Sprintf("monitor.thresholds.disks[%s]", disk)); err != nil {
+			return err
+		}
+	}
+
+	if c.Monitor.ScanThrottle.Enabled && c.Monitor.ScanThrottle.DiskInterval < time.Minute {
+		return fmt.Errorf("monitor.scan_throttle.disk_interval must be at least 1 minute")
+	}
+
+	if c.Monitor.Events.Cooldown < 0 {
+		return fmt.Errorf("monitor.events.cooldown cannot be negative")
+	}
+
+	scriptNames := make(map[string]bool)
+	for i, check := range c.Monitor.ScriptChecks {
+		if check.Name == "" {
+			return fmt.Errorf("monitor.script_checks[%d].name cannot be empty", i)
+		}
+		if scriptNames[check.Name] {
+			return fmt.Errorf("monitor.script_checks: duplicate name %q", check.Name)
+		}
+		scriptNames[check.Name] = true
+
+		if !filepath.IsAbs(check.Command) {
+			return fmt.Errorf("monitor.script_checks[%s].command must be an absolute path", check.Name)
+		}
+		if check.Interval != 0 && check.Interval < time.Minute {
+			return fmt.Errorf("monitor.script_checks[%s].interval must be at least 1 minute", check.Name)
+		}
+		if check.Timeout < 0 {
+			return fmt.Errorf("monitor.script_checks[%s].timeout cannot be negative", check.Name)
+		}
+		switch check.Severity {
+		case "", "warning", "critical", "emergency":
+		default:
+			return fmt.Errorf("monitor.script_checks[%s].severity must be warning, critical or emergency", check.Name)
+		}
+	}
+
+	// Status export validation
+	if c.Export.Path != "" {
+		if !filepath.IsAbs(c.Export.Path) {
+			return fmt.Errorf("status_export.path must be an absolute path")
+		}
+		if c.Export.Interval < 10*time.Second {
+			return fmt.Errorf("status_export.interval must be at least 10 seconds")
+		}
+	}
+
+	if c.SelfBackup.Enabled && !filepath.IsAbs(c.SelfBackup.RemoteDir) {
+		return fmt.Errorf("self_backup.remote_dir must be an absolute path")
+	}
+
+	if c.Drill.Namespace != "" {
+		if err := validation.ValidateDatasetName(c.Drill.Namespace); err != nil {
+			return fmt.Errorf("dr_drill.namespace: %w", err)
+		}
+	}
+
+	if err := validateHooks("dr_drill.hooks", c.Drill.Hooks); err != nil {
+		return err
+	}
+	if err := validateHooks("restore.mount_hooks", c.Restore.MountHooks); err != nil {
+		return err
+	}
+	for _, hook := range c.Restore.PostHooks {
+		if err := validateHooks("restore.post_hooks", []DrillHook{hook.RestoreHook}); err != nil {
+			return err
+		}
+		if _, err := path.Match(hook.Dataset, ""); err != nil {
+			return fmt.Errorf("restore.post_hooks[%s].dataset: %w", hook.Name, err)
+		}
+	}
+	if c.Restore.BrowseNamespace != "" {
+		if err := validation.ValidateDatasetName(c.Restore.BrowseNamespace); err != nil {
+			return fmt.Errorf("restore.browse_namespace: %w", err)
+		}
+	}
+	if c.Restore.BrowseTTL < time.Minute {
+		return fmt.Errorf("restore.browse_ttl must be at least 1 minute")
+	}
+	if c.Restore.Parallelism < 1 || c.Restore.Parallelism > 32 {
+		return fmt.Errorf("restore.parallelism must be between 1 and 32")
+	}
+
+	if c.Tiering.Enabled {
+		if c.Tiering.AfterDays < 1 {
+			return fmt.Errorf("tiering.after_days must be at least 1")
+		}
+		if err := validation.ValidateArchivePath(c.Tiering.ArchiveDir); err != nil {
+			return fmt.Errorf("tiering.archive_dir: %w", err)
+		}
+	}
+
+	if c.S3.Enabled {
+		if !strings.HasPrefix(c.S3.Endpoint, "https://") && !strings.HasPrefix(c.S3.Endpoint, "http://") {
+			return fmt.Errorf("s3.endpoint must be an http or https URL")
+		}
+		if c.S3.Bucket == "" {
+			return fmt.Errorf("s3.bucket cannot be empty")
+		}
+		chunkSize, err := ParseRate(c.S3.ChunkSize)
+		if err != nil {
+			return fmt.Errorf("s3.chunk_size: %w", err)
+		}
+		if chunkSize < 1<<20 || chunkSize > 5<<30 {
+			return fmt.Errorf("s3.chunk_size must be between 1M and 5G")
+		}
+		if c.S3.Compression != "gzip" && c.S3.Compression != "none" {
+			return fmt.Errorf("s3.compression must be gzip or none")
+		}
+	}
+
+	if c.Standby.Enabled {
+		if c.Standby.Mount != StandbyReadOnly && c.Standby.Mount != StandbyUnmounted {
+			return fmt.Errorf("standby.mount must be readonly or unmounted")
+		}
+		if c.Standby.Interval < time.Minute {
+			return fmt.Errorf("standby.interval must be at least 1 minute")
+		}
+		for _, name := range c.Standby.Properties {
+			if !propertyPattern.MatchString(name) {
+				return fmt.Errorf("standby.properties: %q is not a valid ZFS property name", name)
+			}
+		}
+		if err := validateHooks("standby.probes", c.Standby.Probes); err != nil {
+			return err
+		}
+	}
+
+	if c.Store.HistoryDays < 0 || c.Store.MaxHistory < 0 {
+		return fmt.Errorf("store.history_days and store.max_history must be 0 (unlimited) or positive")
+	}
+	if c.Store.CompactCron != "" {
+		if err := validateCronExpression(c.Store.CompactCron); err != nil {
+			return fmt.Errorf("invalid store.compact_cron expression '%s': %w", c.Store.CompactCron, err)
+		}
+	}
+
 	if err := validateCronExpression(c.Schedule.SnapshotCron); err != nil {
 		return fmt.Errorf("invalid snapshot_cron expression '%s': %w", c.Schedule.SnapshotCron, err)
 	}
@@ -218,3 +1689,239 @@ func validateCronExpression(expr string) error {
 func (c *Config) GetAdminPassword() string {
 	return os.Getenv(c.Server.AdminPassEnv)
 }
+
+// GetUser returns the configured web account named user, or nil
+func (c *Config) GetUser(user string) *UserConfig {
+	for i := range c.Server.Users {
+		if c.Server.Users[i].User == user {
+			return &c.Server.Users[i]
+		}
+	}
+	return nil
+}
+
+// GetRequesterPassword returns a requester's password, or "" if user isn't one
+func (c *Config) GetRequesterPassword(user string) string {
+	for _, requester := range c.Server.Requesters {
+		if requester.User == user {
+			return os.Getenv(requester.PassEnv)
+		}
+	}
+	return ""
+}
+
+func validateRetention(section string, z *ZFSConfig) error {
+	if z.KeepSnapshots < 1 {
+		return fmt.Errorf("%s.keep_snapshots must be at least 1", section)
+	}
+	for key, count := range map[string]int{
+		"keep_hourly":  z.KeepHourly,
+		"keep_daily":   z.KeepDaily,
+		"keep_weekly":  z.KeepWeekly,
+		"keep_monthly": z.KeepMonthly,
+		"keep_yearly":  z.KeepYearly,
+	} {
+		if count < 0 {
+			return fmt.Errorf("%s.%s cannot be negative", section, key)
+		}
+	}
+	if z.KeepBookmarks < 0 {
+		return fmt.Errorf("%s.keep_bookmarks cannot be negative", section)
+	}
+	if z.BookmarkOnSend && z.Recursive {
+		return fmt.Errorf("%s.bookmark_on_send can't be used with recursive, zfs send -R can't start from a bookmark", section)
+	}
+	return nil
+}
+
+func validateSnapshotHooks(section string, z *ZFSConfig) error {
+	for _, hook := range z.PreSnapshot {
+		if err := validateHooks(section+".pre_snapshot", []DrillHook{hook.DrillHook}); err != nil {
+			return err
+		}
+		switch hook.OnFailure {
+		case "", HookAbort, HookContinue:
+		default:
+			return fmt.Errorf("%s.pre_snapshot[%s].on_failure must be abort or continue", section, hook.Name)
+		}
+	}
+	for _, hook := range z.PostSnapshot {
+		if err := validateHooks(section+".post_snapshot", []DrillHook{hook.DrillHook}); err != nil {
+			return err
+		}
+		if hook.OnFailure != "" {
+			return fmt.Errorf("%s.post_snapshot[%s].on_failure only applies to pre_snapshot hooks", section, hook.Name)
+		}
+	}
+	return nil
+}
+
+func validateProperties(section string, props map[string]string) error {
+	for name, value := range props {
+		if !propertyPattern.MatchString(name) {
+			return fmt.Errorf("%s.properties: %q is not a valid ZFS property name", section, name)
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s.properties.%s cannot be empty", section, name)
+		}
+	}
+	return nil
+}
+
+// ExpectedProperties returns the ZFS properties each managed dataset should
+// have, keyed by dataset
+func (c *Config) ExpectedProperties() map[string]map[string]string {
+	expected := make(map[string]map[string]string)
+	add := func(dataset string, props map[string]string) {
+		if dataset == "" || len(props) == 0 {
+			return
+		}
+		if expected[dataset] == nil {
+			expected[dataset] = make(map[string]string)
+		}
+		for name, value := range props {
+			expected[dataset][name] = value
+		}
+	}
+	add(c.ZFS.Dataset, c.ZFS.Properties)
+	for _, job := range c.Jobs {
+		add(job.Dataset, job.Properties)
+	}
+	return expected
+}
+
+func validateHooks(section string, hooks []DrillHook) error {
+	for i, hook := range hooks {
+		if hook.Name == "" {
+			return fmt.Errorf("%s[%d].name cannot be empty", section, i)
+		}
+		if !filepath.IsAbs(hook.Command) {
+			return fmt.Errorf("%s[%s].command must be an absolute path", section, hook.Name)
+		}
+		if hook.Timeout < 0 {
+			return fmt.Errorf("%s[%s].timeout cannot be negative", section, hook.Name)
+		}
+	}
+	return nil
+}
+
+func (o *OwnerConfig) validate(c *Config) error {
+	if len(o.Datasets) == 0 {
+		return fmt.Errorf("datasets must list at least one dataset")
+	}
+	for _, dataset := range o.Datasets {
+		if err := validation.ValidateDatasetName(dataset); err != nil {
+			return fmt.Errorf("datasets: %w", err)
+		}
+	}
+
+	if len(o.Emails) == 0 && o.SlackWebhookURL == "" && o.SlackChannel == "" {
+		return fmt.Errorf("set emails, slack_webhook_url or slack_channel")
+	}
+	if len(o.Emails) > 0 && c.Email.SMTPHost == "" {
+		return fmt.Errorf("emails need email.smtp_host")
+	}
+	for _, email := range o.Emails {
+		if err := validation.ValidateEmailAddress(email); err != nil {
+			return fmt.Errorf("emails: %w", err)
+		}
+	}
+
+	if o.SlackWebhookURL != "" && !strings.HasPrefix(o.SlackWebhookURL, "https://hooks.slack.com/") {
+		return fmt.Errorf("slack_webhook_url must be a valid Slack webhook URL")
+	}
+	if o.SlackChannel != "" && o.SlackWebhookURL == "" && c.Slack.WebhookURL == "" {
+		return fmt.Errorf("slack_channel needs slack_webhook_url or slack.webhook_url")
+	}
+	return nil
+}
+
+func (t *TelegramConfig) validate() error {
+	if !telegramTokenPattern.MatchString(t.BotToken) {
+		return fmt.Errorf("bot_token must be a bot token from @BotFather, like 123456:ABC-DEF...")
+	}
+	if len(t.ChatIDs) == 0 {
+		return fmt.Errorf("at least one chat_id is required")
+	}
+	for i, id := range t.ChatIDs {
+		if id == 0 {
+			return fmt.Errorf("chat_ids[%d] cannot be 0", i)
+		}
+	}
+	return nil
+}
+
+func (p *PushConfig) validate() error {
+	if !strings.HasPrefix(p.Server, "https://") && !strings.HasPrefix(p.Server, "http://") {
+		return fmt.Errorf("server must be an http(s) URL")
+	}
+	switch p.Provider {
+	case "ntfy":
+		if p.Topic == "" || strings.Contains(p.Topic, "/") {
+			return fmt.Errorf("topic is required for ntfy and cannot contain /")
+		}
+	case "gotify":
+		if p.Token == "" {
+			return fmt.Errorf("token is required for gotify")
+		}
+	default:
+		return fmt.Errorf("provider must be ntfy or gotify")
+	}
+	return nil
+}
+
+func (s *SMSConfig) validate() error {
+	switch s.Provider {
+	case "twilio":
+		if s.AccountSID == "" || s.AuthToken == "" || s.From == "" {
+			return fmt.Errorf("account_sid, auth_token and from are required for twilio")
+		}
+	case "gateway":
+		if !strings.HasPrefix(s.GatewayURL, "https://") && !strings.HasPrefix(s.GatewayURL, "http://") {
+			return fmt.Errorf("gateway_url must be an http(s) URL")
+		}
+		if s.Voice {
+			return fmt.Errorf("voice calls are only supported with twilio")
+		}
+	default:
+		return fmt.Errorf("provider must be twilio or gateway")
+	}
+
+	if len(s.Recipients) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	days := map[string]bool{"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true}
+	names := make(map[string]bool)
+	for i, r := range s.Recipients {
+		if !phonePattern.MatchString(r.Phone) {
+			return fmt.Errorf("recipients[%d].phone %q must be in E.164 format", i, r.Phone)
+		}
+		// Each recipient's undelivered alerts are queued under their name
+		name := r.Name
+		if name == "" {
+			name = r.Phone
+		}
+		if names[name] {
+			return fmt.Errorf("recipients[%d]: %s is listed more than once", i, name)
+		}
+		names[name] = true
+		for _, day := range r.Days {
+			if !days[strings.ToLower(day)] {
+				return fmt.Errorf("recipients[%d].days: unknown day %q", i, day)
+			}
+		}
+		if r.Hours != "" {
+			if _, _, err := ParseHoursRange(r.Hours); err != nil {
+				return fmt.Errorf("recipients[%d]: %w", i, err)
+			}
+		}
+		if r.Timezone != "" {
+			if _, err := time.LoadLocation(r.Timezone); err != nil {
+				return fmt.Errorf("recipients[%d].timezone: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
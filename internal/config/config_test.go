@@ -0,0 +1,97 @@
+package config
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate    string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"500K", 500 << 10, false},
+		{"50M", 50 << 20, false},
+		{"1.5g", 3 << 29, false},
+		{"1048576", 1 << 20, false},
+		{"fast", 0, true},
+		{"-5M", 0, true},
+		{"M", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.rate)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, %v; want %d, error %v", tt.rate, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSlackRole(t *testing.T) {
+	open := SlackConfig{}
+	if got := open.SlackRole("anyone", "U0"); got != RoleAdmin {
+		t.Errorf("Expected everyone to be admin without a mapping, got %q", got)
+	}
+
+	cfg := SlackConfig{
+		AdminUsers: []string{"boss"},
+		Roles:      map[string]string{"U1": RoleViewer, "rita": RoleRequester},
+	}
+	tests := []struct {
+		name, id, want string
+	}{
+		{"boss", "U9", RoleAdmin},
+		{"vera", "U1", RoleViewer},
+		{"rita", "U2", RoleRequester},
+		{"sam", "U3", RoleOperator},
+	}
+	for _, tt := range tests {
+		if got := cfg.SlackRole(tt.name, tt.id); got != tt.want {
+			t.Errorf("SlackRole(%q, %q) = %q, want %q", tt.name, tt.id, got, tt.want)
+		}
+	}
+
+	if !RoleAllows(RoleAdmin, RoleOperator) || RoleAllows(RoleViewer, RoleOperator) || RoleAllows("", RoleRequester) {
+		t.Error("Unexpected role ordering")
+	}
+}
+
+func TestExpectedProperties(t *testing.T) {
+	cfg := Config{
+		ZFS: ZFSConfig{Dataset: "tank/data", Properties: map[string]string{"compression": "lz4"}},
+		Jobs: []JobConfig{
+			{Name: "vms", ZFSConfig: ZFSConfig{Dataset: "tank/vms", Properties: map[string]string{"recordsize": "64K"}}},
+			{Name: "plain", ZFSConfig: ZFSConfig{Dataset: "tank/plain"}},
+		},
+	}
+
+	expected := cfg.ExpectedProperties()
+	if len(expected) != 2 || expected["tank/data"]["compression"] != "lz4" || expected["tank/vms"]["recordsize"] != "64K" {
+		t.Errorf("Unexpected expected properties: %v", expected)
+	}
+
+	if err := validateProperties("zfs", map[string]string{"Compression": "lz4"}); err == nil {
+		t.Error("Expected an invalid property name to be rejected")
+	}
+	if err := validateProperties("zfs", map[string]string{"com.example:owner": ""}); err == nil {
+		t.Error("Expected an empty value to be rejected")
+	}
+	if err := validateProperties("zfs", map[string]string{"com.example:owner": "ops", "atime": "off"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestOwnerConfigOwns(t *testing.T) {
+	o := OwnerConfig{Datasets: []string{"tank/vms", "fast/db"}}
+	for dataset, want := range map[string]bool{
+		"tank/vms":       true,
+		"tank/vms/web01": true,
+		"fast/db":        true,
+		"tank/vmstore":   false,
+		"tank":           false,
+	} {
+		if got := o.Owns(dataset); got != want {
+			t.Errorf("Owns(%q) = %v, want %v", dataset, got, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override config keys
+const EnvPrefix = "ZFSRABBIT_"
+
+var (
+	configType   = reflect.TypeOf(Config{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// applyEnv overrides config keys from ZFSRABBIT_* environment variables, so
+// secrets need not be written to the config file. A key's variable is its
+// path upper-cased and joined with underscores: ssh.remote_host is
+// ZFSRABBIT_SSH_REMOTE_HOST. Entries of a list are numbered from 0, as in
+// ZFSRABBIT_REMOTES_0_PRIVATE_KEY, and only entries in the file can be
+// overridden that way; the list itself takes YAML, as in
+// ZFSRABBIT_REMOTES='[{...}]'.
+// Lists of strings or numbers are comma-separated, and maps take YAML.
+// Variables that name no config key are left alone.
+func applyEnv(cfg *Config) error {
+	var applied []string
+	if err := applyEnvFields(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(EnvPrefix, "_"), &applied); err != nil {
+		return fmt.Errorf("invalid environment override: %w", err)
+	}
+	if len(applied) > 0 {
+		log.Printf("Config overridden from the environment: %s", strings.Join(applied, ", "))
+	}
+	return nil
+}
+
+func applyEnvFields(v reflect.Value, prefix string, applied *[]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if tag[0] == "-" || field.Name == "Version" && t == configType {
+			// The schema version is set by migrations, and ZFSRABBIT_VERSION
+			// is more likely to name a package version
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "inline" {
+			if err := applyEnvFields(v.Field(i), prefix, applied); err != nil {
+				return err
+			}
+			continue
+		}
+		if tag[0] == "" {
+			continue
+		}
+		if err := applyEnvValue(v.Field(i), prefix+"_"+strings.ToUpper(tag[0]), applied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyEnvValue(v reflect.Value, name string, applied *[]string) error {
+	// Sections are overridden a key at a time, never as a whole
+	if value, ok := os.LookupEnv(name); ok && v.Kind() != reflect.Struct {
+		if err := setFromEnv(v, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*applied = append(*applied, name)
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct:
+		return applyEnvFields(v, name, applied)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		for i := 0; i < v.Len(); i++ {
+			if err := applyEnvFields(v.Index(i), name+"_"+strconv.Itoa(i), applied); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setFromEnv parses an environment variable into a config value
+func setFromEnv(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if kind := v.Type().Elem().Kind(); kind != reflect.Struct && kind != reflect.Map && kind != reflect.Slice {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			list := reflect.MakeSlice(v.Type(), len(items), len(items))
+			for i, item := range items {
+				if err := setFromEnv(list.Index(i), item); err != nil {
+					return err
+				}
+			}
+			v.Set(list)
+			return nil
+		}
+		fallthrough
+	default:
+		// Lists of sections and maps, as YAML
+		fresh := reflect.New(v.Type())
+		if err := yaml.Unmarshal([]byte(value), fresh.Interface()); err != nil {
+			return err
+		}
+		v.Set(fresh.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadEnvOverrides(t *testing.T) {
+	t.Setenv("ZFSRABBIT_SSH_REMOTE_HOST", "standby.example.com")
+	t.Setenv("ZFSRABBIT_EMAIL_SMTP_HOST", "smtp.example.com")
+	t.Setenv("ZFSRABBIT_EMAIL_SMTP_PASSWORD", "from-env")
+	t.Setenv("ZFSRABBIT_EMAIL_SMTP_PORT", "2525")
+	t.Setenv("ZFSRABBIT_EMAIL_TO_EMAILS", "a@example.com, b@example.com")
+	t.Setenv("ZFSRABBIT_SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T/B/X")
+	t.Setenv("ZFSRABBIT_SLACK_CHANNEL", "#storage")
+	t.Setenv("ZFSRABBIT_SLACK_ENABLED", "true")
+	t.Setenv("ZFSRABBIT_TELEGRAM_CHAT_IDS", "42,-1001")
+	t.Setenv("ZFSRABBIT_SCHEDULE_MONITOR_INTERVAL", "2m")
+	t.Setenv("ZFSRABBIT_REMOTES_0_PRIVATE_KEY", "/run/secrets/offsite_key")
+	t.Setenv("ZFSRABBIT_FEATURES", "{}")
+	t.Setenv("ZFSRABBIT_VERSION", "1.2.3")
+
+	cfg, err := Load(writeConfig(t, baseConfig+"remotes:\n  - name: offsite\n    remote_host: offsite.example.com\n    remote_user: root\n    remote_dataset: backup/data\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.SSH.RemoteHost != "standby.example.com" || cfg.SSH.RemoteUser != "root" {
+		t.Errorf("Expected only ssh.remote_host overridden, got %+v", cfg.SSH)
+	}
+	if cfg.Email.SMTPPassword != "from-env" || cfg.Email.SMTPPort != 2525 || len(cfg.Email.ToEmails) != 2 || cfg.Email.ToEmails[1] != "b@example.com" {
+		t.Errorf("Unexpected email config %+v", cfg.Email)
+	}
+	if !cfg.Slack.Enabled || cfg.Slack.Channel != "#storage" || cfg.Slack.WebhookURL != "https://hooks.slack.com/services/T/B/X" {
+		t.Errorf("Unexpected slack config %+v", cfg.Slack)
+	}
+	if len(cfg.Telegram.ChatIDs) != 2 || cfg.Telegram.ChatIDs[1] != -1001 {
+		t.Errorf("Unexpected telegram chat IDs %v", cfg.Telegram.ChatIDs)
+	}
+	if cfg.Schedule.MonitorInterval != 2*time.Minute {
+		t.Errorf("Expected monitor interval 2m, got %s", cfg.Schedule.MonitorInterval)
+	}
+	if remote := cfg.Remotes[0]; remote.PrivateKey != "/run/secrets/offsite_key" || remote.MbufferSize != "1G" {
+		t.Errorf("Expected the remote's key overridden and the rest defaulted, got %+v", remote)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Expected ZFSRABBIT_VERSION to be ignored, got version %d", cfg.Version)
+	}
+}
+
+func TestLoadEnvOverridesInvalid(t *testing.T) {
+	tests := []struct {
+		name, value, wantErr string
+	}{
+		{"ZFSRABBIT_EMAIL_SMTP_PORT", "smtp", "ZFSRABBIT_EMAIL_SMTP_PORT"},
+		{"ZFSRABBIT_SLACK_ENABLED", "maybe", "ZFSRABBIT_SLACK_ENABLED"},
+		{"ZFSRABBIT_SCHEDULE_MONITOR_INTERVAL", "often", "ZFSRABBIT_SCHEDULE_MONITOR_INTERVAL"},
+		{"ZFSRABBIT_ALERT_ROUTES", "[not: [closed", "ZFSRABBIT_ALERT_ROUTES"},
+		// Checked like the file
+		{"ZFSRABBIT_SERVER_PORT", "70000", "server port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			_, err := Load(writeConfig(t, baseConfig))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadEnvWithoutConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("ZFSRABBIT_ZFS_DATASET", "tank/data")
+	t.Setenv("ZFSRABBIT_SSH_REMOTE_HOST", "backup.example.com")
+	t.Setenv("ZFSRABBIT_SSH_REMOTE_USER", "root")
+	t.Setenv("ZFSRABBIT_SSH_PRIVATE_KEY", "/run/secrets/ssh_key")
+	t.Setenv("ZFSRABBIT_SSH_REMOTE_DATASET", "backup/data")
+	t.Setenv("ZFSRABBIT_REMOTES", "[{name: offsite, remote_host: offsite.example.com, remote_user: root, remote_dataset: backup/data}]")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ZFS.Dataset != "tank/data" || cfg.Server.Port != 8080 {
+		t.Errorf("Expected the environment on top of the defaults, got %+v / %+v", cfg.ZFS, cfg.Server)
+	}
+	if cfg.Remotes[0].PrivateKey != "/run/secrets/ssh_key" {
+		t.Errorf("Expected the remote to default to the ssh section's key, got %+v", cfg.Remotes[0])
+	}
+
+	// Checked like a file
+	t.Setenv("ZFSRABBIT_SERVER_PORT", "70000")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "server port") {
+		t.Errorf("Expected an invalid port to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version written by this release.
+// Configs without a version field are treated as version 1.
+const CurrentVersion = 2
+
+// migrations upgrade a parsed config document from version N to N+1
+var migrations = map[int]func(root *yaml.Node) error{
+	// Version 2 introduced the version field itself and the unknown key
+	// check; every key of the version 1 layout kept its name and place
+	1: func(root *yaml.Node) error { return nil },
+}
+
+// decodeVersioned migrates an older config document to CurrentVersion,
+// rejects keys that don't exist in the schema and decodes into cfg
+func decodeVersioned(data []byte, cfg *Config) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: config must be a mapping", root.Line)
+	}
+
+	version := 1
+	if node := mappingValue(root, "version"); node != nil {
+		v, err := strconv.Atoi(node.Value)
+		if err != nil || v < 1 {
+			return fmt.Errorf("line %d: version must be a positive integer", node.Line)
+		}
+		version = v
+	}
+
+	if version > CurrentVersion {
+		return fmt.Errorf("config schema version %d is newer than supported version %d; upgrade zfsrabbit", version, CurrentVersion)
+	}
+
+	if version < CurrentVersion {
+		for v := version; v < CurrentVersion; v++ {
+			if err := migrations[v](root); err != nil {
+				return fmt.Errorf("failed to migrate config from version %d: %w", v, err)
+			}
+		}
+		setMappingValue(root, "version", strconv.Itoa(CurrentVersion))
+		log.Printf("Config uses schema version %d, migrated to version %d; set \"version: %d\" after updating the file", version, CurrentVersion, CurrentVersion)
+	}
+
+	if err := checkUnknownKeys(root, reflect.TypeOf(*cfg), ""); err != nil {
+		return err
+	}
+
+	return root.Decode(cfg)
+}
+
+// checkUnknownKeys walks a mapping node against the yaml tags of t so that
+// misspelled or misplaced settings fail loudly instead of being ignored
+func checkUnknownKeys(node *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil // Type mismatches are reported by Decode
+		}
+		fields := make(map[string]reflect.Type)
+		collectFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				return fmt.Errorf("line %d: unknown config key %q", key.Line, joinPath(path, key.Value))
+			}
+			if err := checkUnknownKeys(value, fieldType, joinPath(path, key.Value)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			if err := checkUnknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectFields maps yaml keys to field types, flattening ",inline" structs
+func collectFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if len(tag) > 1 && tag[1] == "inline" && field.Type.Kind() == reflect.Struct {
+			collectFields(field.Type, fields)
+			continue
+		}
+		if tag[0] == "" || tag[0] == "-" {
+			continue
+		}
+		fields[tag[0]] = field.Type
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// mappingValue returns the value node for key in a mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, key, value string) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind = yaml.ScalarNode
+		existing.Tag = "!!int"
+		existing.Value = value
+		return
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value},
+	)
+}
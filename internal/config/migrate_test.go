@@ -0,0 +1,857 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const baseConfig = `
+zfs:
+  dataset: "tank/data"
+ssh:
+  remote_host: "backup.example.com"
+  remote_user: "root"
+  private_key: "/root/.ssh/id_rsa"
+  remote_dataset: "backup/data"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadUnversionedConfigMigrates(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Expected version %d after migration, got %d", CurrentVersion, cfg.Version)
+	}
+	if cfg.ZFS.Dataset != "tank/data" {
+		t.Errorf("Expected dataset tank/data, got %s", cfg.ZFS.Dataset)
+	}
+}
+
+func TestLoadMigratesVersion1Config(t *testing.T) {
+	migrated, err := Load(filepath.Join("testdata", "v1.yaml"))
+	if err != nil {
+		t.Fatalf("Load of the version 1 config failed: %v", err)
+	}
+	want, err := Load(filepath.Join("testdata", "v2.yaml"))
+	if err != nil {
+		t.Fatalf("Load of the version 2 config failed: %v", err)
+	}
+
+	migrated.Path, want.Path = "", ""
+	if !reflect.DeepEqual(migrated, want) {
+		t.Errorf("Migrated config differs from testdata/v2.yaml:\n%+v\nwant\n%+v", migrated, want)
+	}
+
+	// Spot checks, in case both files go wrong the same way
+	if migrated.Version != CurrentVersion || migrated.ZFS.Dataset != "tank/data" || migrated.SSH.PrivateKey != "/root/.ssh/id_rsa" ||
+		migrated.Slack.SlashToken != "your-slack-slash-command-token" || len(migrated.Email.ToEmails) != 2 {
+		t.Errorf("Expected the version 1 settings to be kept, got %+v", migrated)
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	_, err := Load(writeConfig(t, "version: 99\n"+baseConfig))
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("Expected newer version error, got %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		extra   string
+		wantKey string
+	}{
+		{"top level", "montior:\n  pool_interval: 5m\n", `"montior"`},
+		{"nested", "server:\n  prot: 9090\n", `"server.prot"`},
+		{"in list", "monitor:\n  script_checks:\n    - name: x\n      comand: /bin/true\n", `"monitor.script_checks[0].comand"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, "version: 2\n"+baseConfig+tt.extra))
+			if err == nil {
+				t.Fatal("Expected unknown key error")
+			}
+			if !strings.Contains(err.Error(), tt.wantKey) || !strings.Contains(err.Error(), "line ") {
+				t.Errorf("Expected error naming %s with a line number, got %v", tt.wantKey, err)
+			}
+		})
+	}
+}
+
+func TestLoadRemotesInheritSSHDefaults(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig+`
+remotes:
+  - name: offsite
+    remote_host: "vault.example.com"
+    remote_user: "backup"
+    remote_dataset: "vault/data"
+`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Remotes) != 1 {
+		t.Fatalf("Expected one remote, got %d", len(cfg.Remotes))
+	}
+	remote := cfg.Remotes[0]
+	if remote.RemoteHost != "vault.example.com" || remote.PrivateKey != "/root/.ssh/id_rsa" || remote.MbufferSize != "1G" {
+		t.Errorf("Expected remote to inherit ssh defaults, got %+v", remote)
+	}
+
+	_, err = Load(writeConfig(t, baseConfig+`
+remotes:
+  - name: primary
+    remote_host: "vault.example.com"
+    remote_user: "backup"
+    remote_dataset: "vault/data"
+`))
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected reserved name to be rejected, got %v", err)
+	}
+
+	_, err = Load(writeConfig(t, baseConfig+"remotes:\n  - name: offsite\n    remote_hots: x\n"))
+	if err == nil || !strings.Contains(err.Error(), `"remotes[0].remote_hots"`) {
+		t.Errorf("Expected unknown key in inline struct to be reported, got %v", err)
+	}
+}
+
+func TestLoadValidatesFeatureFlags(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig+"features:\n  resumable_sends: false\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if enabled, ok := cfg.Features["resumable_sends"]; !ok || enabled {
+		t.Errorf("Expected resumable_sends override to be false, got %v", cfg.Features)
+	}
+
+	_, err = Load(writeConfig(t, baseConfig+"features:\n  resumable_snds: true\n"))
+	if err == nil || !strings.Contains(err.Error(), `unknown flag "resumable_snds"`) {
+		t.Errorf("Expected unknown flag error, got %v", err)
+	}
+}
+
+func TestLoadValidatesRetention(t *testing.T) {
+	cfg, err := Load(writeConfig(t, strings.Replace(baseConfig, "  dataset: \"tank/data\"\n", "  dataset: \"tank/data\"\n  keep_daily: 7\n  keep_monthly: 12\n  prune_remote: true\n", 1)))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ZFS.KeepSnapshots != 30 || cfg.ZFS.KeepDaily != 7 || cfg.ZFS.KeepMonthly != 12 || !cfg.ZFS.PruneRemote {
+		t.Errorf("Unexpected retention: %+v", cfg.ZFS)
+	}
+
+	_, err = Load(writeConfig(t, strings.Replace(baseConfig, "  dataset: \"tank/data\"\n", "  dataset: \"tank/data\"\n  keep_weekly: -1\n", 1)))
+	if err == nil || !strings.Contains(err.Error(), "zfs.keep_weekly cannot be negative") {
+		t.Errorf("Expected negative keep_weekly to be rejected, got %v", err)
+	}
+
+	_, err = Load(writeConfig(t, strings.Replace(baseConfig, "  dataset: \"tank/data\"\n", "  dataset: \"tank/data\"\n  recursive: false\n  keep_snapshots: 1\n  bookmark_on_send: true\n  keep_bookmarks: 48\n", 1)))
+	if err != nil {
+		t.Errorf("Expected bookmark_on_send with one snapshot kept to load, got %v", err)
+	}
+
+	_, err = Load(writeConfig(t, strings.Replace(baseConfig, "  dataset: \"tank/data\"\n", "  dataset: \"tank/data\"\n  recursive: true\n  bookmark_on_send: true\n", 1)))
+	if err == nil || !strings.Contains(err.Error(), "zfs.bookmark_on_send") {
+		t.Errorf("Expected bookmark_on_send to be rejected for recursive sends, got %v", err)
+	}
+}
+
+func TestLoadMaxLag(t *testing.T) {
+	cfg, err := Load(writeConfig(t, strings.Replace(baseConfig, "  dataset: \"tank/data\"\n", "  dataset: \"tank/data\"\n  max_lag: 26h\n", 1)+
+		"jobs:\n  - name: db\n    dataset: tank/db\n    target:\n      remote_dataset: backup/db\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.ZFS.MaxLag != 26*time.Hour || cfg.Jobs[0].MaxLag != 26*time.Hour {
+		t.Errorf("Expected max_lag 26h for the zfs section and its jobs, got %s and %s", cfg.ZFS.MaxLag, cfg.Jobs[0].MaxLag)
+	}
+
+	_, err = Load(writeConfig(t, strings.Replace(baseConfig, "  dataset: \"tank/data\"\n", "  dataset: \"tank/data\"\n  max_lag: -1h\n", 1)))
+	if err == nil || !strings.Contains(err.Error(), "zfs.max_lag") {
+		t.Errorf("Expected negative max_lag to be rejected, got %v", err)
+	}
+}
+
+func TestLoadValidatesTiering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tiering string
+		wantErr string
+	}{
+		{"no age", "tiering:\n  enabled: true\n  archive_dir: /cold\n", "tiering.after_days"},
+		{"relative dir", "tiering:\n  enabled: true\n  after_days: 90\n  archive_dir: cold\n", "tiering.archive_dir"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.tiering))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := Load(writeConfig(t, baseConfig+"tiering:\n  enabled: true\n  after_days: 90\n  archive_dir: /cold/zfsrabbit\n")); err != nil {
+		t.Errorf("Expected valid tiering config, got %v", err)
+	}
+}
+
+func TestLoadJobsInheritDefaults(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig+"jobs:\n  - name: media\n    dataset: tank/media\n    target:\n      remote_dataset: backup/media\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	job := cfg.Jobs[0]
+	if job.SnapshotCron != cfg.Schedule.SnapshotCron || job.KeepSnapshots != cfg.ZFS.KeepSnapshots || job.SendCompression != cfg.ZFS.SendCompression {
+		t.Errorf("Expected the job to inherit schedule and retention, got %+v", job)
+	}
+	if job.Target.RemoteHost != "backup.example.com" || job.Target.PrivateKey != "/root/.ssh/id_rsa" {
+		t.Errorf("Expected the job to inherit the ssh connection, got %+v", job.Target)
+	}
+	if cfg.Schedule.MaxConcurrentJobs != 2 {
+		t.Errorf("Expected max_concurrent_jobs to default to 2, got %d", cfg.Schedule.MaxConcurrentJobs)
+	}
+}
+
+func TestLoadValidatesJobs(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobs    string
+		wantErr string
+	}{
+		{"no name", "  - dataset: tank/media\n    target:\n      remote_dataset: backup/media\n", "jobs[0].name"},
+		{"reserved name", "  - name: default\n    dataset: tank/media\n    target:\n      remote_dataset: backup/media\n", "already in use"},
+		{"duplicate dataset", "  - name: data\n    dataset: tank/data\n    target:\n      remote_dataset: backup/other\n", "already replicated"},
+		{"duplicate destination", "  - name: media\n    dataset: tank/media\n    target:\n      remote_dataset: backup/data\n", "already a replication target"},
+		{"bad cron", "  - name: media\n    dataset: tank/media\n    snapshot_cron: \"bad\"\n    target:\n      remote_dataset: backup/media\n", "snapshot_cron"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+"jobs:\n"+tt.jobs))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesSnapshotHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   string
+		wantErr string
+	}{
+		{"valid", "  pre_snapshot:\n    - name: flush\n      command: /usr/local/bin/flush-db\n      timeout: 30s\n      on_failure: continue\n  post_snapshot:\n    - name: unlock\n      command: /usr/local/bin/unlock-db\n", ""},
+		{"relative command", "  pre_snapshot:\n    - name: flush\n      command: flush-db\n", "zfs.pre_snapshot[flush].command"},
+		{"unknown policy", "  pre_snapshot:\n    - name: flush\n      command: /usr/local/bin/flush-db\n      on_failure: retry\n", "zfs.pre_snapshot[flush].on_failure"},
+		{"policy on post hook", "  post_snapshot:\n    - name: unlock\n      command: /usr/local/bin/unlock-db\n      on_failure: abort\n", "zfs.post_snapshot[unlock].on_failure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, strings.Replace(baseConfig, "  dataset: \"tank/data\"\n", "  dataset: \"tank/data\"\n"+tt.hooks, 1)))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	const job = "  - name: %s\n    dataset: %s\n    recursive: %t\n    target:\n      remote_dataset: backup/%s\n"
+	jobs := "jobs:\n" + fmt.Sprintf(job, "db", "tank/data/db", true, "db") +
+		fmt.Sprintf(job, "logs", "tank/data/db/logs", false, "logs") +
+		fmt.Sprintf(job, "media", "tank/media", false, "media")
+	cfg, err := Load(writeConfig(t, baseConfig+jobs))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// logs is left out of the default job along with db, so only db is listed for it
+	want := []Overlap{
+		{Parent: "default", ParentDataset: "tank/data", Child: "db", ChildDataset: "tank/data/db"},
+		{Parent: "db", ParentDataset: "tank/data/db", Child: "logs", ChildDataset: "tank/data/db/logs"},
+	}
+	if got := cfg.Overlaps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected overlaps %+v, got %+v", want, got)
+	}
+	if got := cfg.ManagedChildren("tank/media"); got != nil {
+		t.Errorf("Expected no managed children of a non-recursive job, got %v", got)
+	}
+}
+
+func TestLoadValidatesStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   string
+		wantErr string
+	}{
+		{"negative age", "store:\n  history_days: -1\n", "store.history_days"},
+		{"bad cron", "store:\n  compact_cron: \"bad\"\n", "store.compact_cron"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.store))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	cfg, err := Load(writeConfig(t, baseConfig+"store:\n  compact_cron: \"\"\n"))
+	if err != nil {
+		t.Fatalf("Expected an empty compact_cron to disable compaction, got %v", err)
+	}
+	if cfg.Store.HistoryDays != 365 || cfg.Store.MaxHistory != 5000 {
+		t.Errorf("Expected default limits, got %+v", cfg.Store)
+	}
+}
+
+func TestLoadValidatesScanThrottle(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.Monitor.ScanThrottle.Enabled || cfg.Monitor.ScanThrottle.DiskInterval != time.Hour {
+		t.Errorf("Expected scan throttling on by default, got %+v", cfg.Monitor.ScanThrottle)
+	}
+
+	_, err = Load(writeConfig(t, baseConfig+"monitor:\n  scan_throttle:\n    disk_interval: 10s\n"))
+	if err == nil || !strings.Contains(err.Error(), "monitor.scan_throttle.disk_interval") {
+		t.Errorf("Expected a short throttled interval to be rejected, got %v", err)
+	}
+
+	if _, err := Load(writeConfig(t, baseConfig+"monitor:\n  scan_throttle:\n    enabled: false\n    disk_interval: 0s\n")); err != nil {
+		t.Errorf("Expected disabled throttling to skip validation, got %v", err)
+	}
+}
+
+func TestLoadValidatesSMARTPolling(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig+"monitor:\n  disk_intervals:\n    ZA1234: 6h\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Monitor.SMARTConcurrency != 4 || !cfg.Monitor.SkipStandby || cfg.Monitor.DiskIntervals["ZA1234"] != 6*time.Hour {
+		t.Errorf("Unexpected SMART polling settings: %+v", cfg.Monitor)
+	}
+
+	tests := []struct {
+		name    string
+		monitor string
+		wantErr string
+	}{
+		{"no concurrency", "monitor:\n  smart_concurrency: 0\n", "monitor.smart_concurrency"},
+		{"short interval", "monitor:\n  disk_intervals:\n    ZA1234: 10s\n", "monitor.disk_intervals[ZA1234]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.monitor))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesThresholds(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig+"monitor:\n  thresholds:\n    hdd:\n      temp_warning: 55\n    disks:\n      ZA1234:\n        temp_critical: 65\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Monitor.Thresholds.HDD.TempWarning != 55 || cfg.Monitor.Thresholds.Disks["ZA1234"].TempCritical != 65 {
+		t.Errorf("Unexpected thresholds: %+v", cfg.Monitor.Thresholds)
+	}
+
+	tests := []struct {
+		name       string
+		thresholds string
+		wantErr    string
+	}{
+		{"temperatures out of order", "    hdd:\n      temp_warning: 70\n      temp_critical: 60\n", "monitor.thresholds.hdd: temp"},
+		{"spare rising", "    nvme:\n      spare_warning: 5\n      spare_critical: 10\n", "monitor.thresholds.nvme: spare"},
+		{"spare over 100", "    nvme:\n      spare_warning: 120\n", "between 0 and 100"},
+		{"bad disk override", "    disks:\n      ZA1234:\n        wear_warning: -1\n", "monitor.thresholds.disks[ZA1234]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+"monitor:\n  thresholds:\n"+tt.thresholds))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesDisplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		display string
+		wantErr string
+	}{
+		{"fahrenheit", "display:\n  temperature_unit: fahrenheit\n  date_format: us\n  timezone: America/Chicago\n", ""},
+		{"kelvin", "display:\n  temperature_unit: kelvin\n", "display.temperature_unit"},
+		{"unknown format", "display:\n  date_format: jp\n", "display.date_format"},
+		{"unknown timezone", "display:\n  timezone: Mars/Olympus\n", "display.timezone"},
+		{"german", "display:\n  locale: de\n", ""},
+		{"unknown locale", "display:\n  locale: xx\n", "display.locale"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.display))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesRequesters(t *testing.T) {
+	tests := []struct {
+		name       string
+		requesters string
+		wantErr    string
+	}{
+		{"admin", "    - user: admin\n      pass_env: PASS\n", "not \"admin\""},
+		{"duplicate", "    - user: alice\n      pass_env: A\n    - user: alice\n      pass_env: B\n", "duplicate user"},
+		{"no pass_env", "    - user: alice\n", "pass_env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+"server:\n  requesters:\n"+tt.requesters))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesLogging(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		wantErr string
+	}{
+		{"defaults", "", ""},
+		{"json debug", "  log_level: debug\n  log_format: json\n", ""},
+		{"unknown level", "  log_level: verbose\n", "server.log_level"},
+		{"unknown format", "  log_format: xml\n", "server.log_format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, baseConfig+"server:\n  port: 8080\n"+tt.server))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load failed: %v", err)
+				}
+				if tt.server == "" && (cfg.Server.LogLevel != "info" || cfg.Server.LogFormat != "text") {
+					t.Errorf("Expected info text logging by default, got %s %s", cfg.Server.LogLevel, cfg.Server.LogFormat)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesOwners(t *testing.T) {
+	const smtp = "email:\n  smtp_host: smtp.example.com\n  smtp_port: 587\n"
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"email owner", smtp + "owners:\n  - name: vm-team\n    datasets: [tank/vms]\n    emails: [vm@example.com]\n", ""},
+		{"slack owner", "owners:\n  - name: db-team\n    datasets: [tank/db]\n    slack_webhook_url: https://hooks.slack.com/services/T/B/X\n", ""},
+		{"no name", "owners:\n  - datasets: [tank/vms]\n    slack_webhook_url: https://hooks.slack.com/services/T/B/X\n", "owners[0].name"},
+		{"duplicate", "owners:\n  - name: a\n    datasets: [tank/a]\n    slack_webhook_url: https://hooks.slack.com/a\n  - name: a\n    datasets: [tank/b]\n    slack_webhook_url: https://hooks.slack.com/b\n", "duplicate name"},
+		{"no datasets", "owners:\n  - name: a\n    slack_webhook_url: https://hooks.slack.com/a\n", "datasets"},
+		{"no recipients", "owners:\n  - name: a\n    datasets: [tank/a]\n", "set emails"},
+		{"emails without smtp", "owners:\n  - name: a\n    datasets: [tank/a]\n    emails: [a@example.com]\n", "email.smtp_host"},
+		{"channel without webhook", "owners:\n  - name: a\n    datasets: [tank/a]\n    slack_channel: \"#a\"\n", "slack_channel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesS3(t *testing.T) {
+	const s3 = "s3:\n  enabled: true\n  bucket: backups\n"
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"minio", s3 + "  endpoint: http://minio:9000\n  path_style: true\n", ""},
+		{"disabled", "s3:\n  endpoint: minio:9000\n", ""},
+		{"no scheme", s3 + "  endpoint: minio:9000\n", "s3.endpoint"},
+		{"no bucket", "s3:\n  enabled: true\n  endpoint: https://s3.amazonaws.com\n", "s3.bucket"},
+		{"small chunks", s3 + "  endpoint: https://s3.amazonaws.com\n  chunk_size: 64K\n", "s3.chunk_size"},
+		{"compression", s3 + "  endpoint: https://s3.amazonaws.com\n  compression: zstd\n", "s3.compression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesStandby(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig+"standby:\n  enabled: true\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Standby.Mount != StandbyReadOnly || cfg.Standby.Interval != 15*time.Minute || len(cfg.Standby.Properties) == 0 {
+		t.Errorf("Expected standby defaults, got %+v", cfg.Standby)
+	}
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"unmounted", "  mount: unmounted\n  probes:\n    - name: pg\n      command: /usr/local/bin/check-pg\n", ""},
+		{"mount", "  mount: rw\n", "standby.mount"},
+		{"interval", "  interval: 10s\n", "standby.interval"},
+		{"property", "  properties: [\"Bad Name\"]\n", "standby.properties"},
+		{"probe", "  probes:\n    - name: pg\n      command: check-pg\n", "standby.probes[pg].command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+"standby:\n  enabled: true\n"+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesSLAs(t *testing.T) {
+	cfg, err := Load(writeConfig(t, baseConfig+"sla:\n  - finish_by: \"06:00\"\n    max_age: 24h\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := cfg.SLAs[0].DatasetOr(cfg.ZFS.Dataset); got != "tank/data" {
+		t.Errorf("Expected SLA dataset to default to zfs.dataset, got %q", got)
+	}
+
+	tests := []struct {
+		name    string
+		sla     string
+		wantErr string
+	}{
+		{"no objective", "sla:\n  - dataset: tank/data\n", "finish_by or max_age is required"},
+		{"bad clock", "sla:\n  - finish_by: \"6am\"\n", "must be HH:MM"},
+		{"other dataset", "sla:\n  - dataset: tank/other\n    max_age: 24h\n", "not replicated"},
+		{"duplicate", "sla:\n  - max_age: 24h\n  - dataset: tank/data\n    finish_by: \"06:00\"\n", "already has an SLA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.sla))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesWebhooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"alertmanager", "webhooks:\n  - name: am\n    url: http://alertmanager:9093/api/v2/alerts\n", ""},
+		{"template and headers", "webhooks:\n  - name: oncall\n    url: https://oncall.example.com/integrations/v1/webhook/abc/\n    headers:\n      Authorization: Bearer x\n    template: '{\"title\": {{json .Title}}}'\n", ""},
+		{"no name", "webhooks:\n  - url: http://am/\n", "webhooks[0].name"},
+		{"duplicate", "webhooks:\n  - name: a\n    url: http://a/\n  - name: a\n    url: http://b/\n", "already in use"},
+		{"bad url", "webhooks:\n  - name: a\n    url: am:9093\n", "http(s) URL"},
+		{"bad header", "webhooks:\n  - name: a\n    url: http://a/\n    headers:\n      \"X Bad\": y\n", "header name"},
+		{"bad template", "webhooks:\n  - name: a\n    url: http://a/\n    template: '{{.Title'\n", "webhooks[a]: template"},
+		{"unknown function", "webhooks:\n  - name: a\n    url: http://a/\n    template: '{{yaml .Title}}'\n", "webhooks[a]: template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesSlackThreads(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"threaded", "slack:\n  bot_token: xoxb-1\n  channel: \"#storage\"\n  thread_incidents: true\n", ""},
+		{"no bot token", "slack:\n  channel: \"#storage\"\n  thread_incidents: true\n", "requires slack.bot_token"},
+		{"no channel", "slack:\n  bot_token: xoxb-1\n  thread_incidents: true\n", "requires slack.bot_token"},
+		{"short window", "slack:\n  bot_token: xoxb-1\n  channel: \"#storage\"\n  thread_incidents: true\n  incident_window: 30s\n", "incident_window"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesTelegram(t *testing.T) {
+	token := "123456:ABCdefGHIjklMNOpqrSTUvwxYZ0123456789"
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"valid", "telegram:\n  enabled: true\n  bot_token: " + token + "\n  chat_ids: [12345, -100987]\n  commands: true\n", ""},
+		{"disabled", "telegram:\n  bot_token: nonsense\n", ""},
+		{"bad token", "telegram:\n  enabled: true\n  bot_token: nonsense\n  chat_ids: [12345]\n", "bot_token"},
+		{"no chats", "telegram:\n  enabled: true\n  bot_token: " + token + "\n", "chat_id"},
+		{"zero chat", "telegram:\n  enabled: true\n  bot_token: " + token + "\n  chat_ids: [0]\n", "chat_ids[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesTeams(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"valid", "teams:\n  enabled: true\n  webhook_url: https://example.webhook.office.com/webhookb2/abc\n", ""},
+		{"disabled", "teams:\n  webhook_url: nonsense\n", ""},
+		{"no url", "teams:\n  enabled: true\n", "teams.webhook_url"},
+		{"http", "teams:\n  enabled: true\n  webhook_url: http://example.com/hook\n", "teams.webhook_url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesPush(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"ntfy", "push:\n  enabled: true\n  topic: zfsrabbit-alerts\n", ""},
+		{"gotify", "push:\n  enabled: true\n  provider: gotify\n  server: https://gotify.example.com\n  token: abc\n", ""},
+		{"no topic", "push:\n  enabled: true\n", "topic is required"},
+		{"gotify without token", "push:\n  enabled: true\n  provider: gotify\n  server: https://gotify.example.com\n", "token is required"},
+		{"bad server", "push:\n  enabled: true\n  server: ntfy.sh\n  topic: alerts\n", "server must be"},
+		{"bad provider", "push:\n  enabled: true\n  provider: pushover\n  topic: alerts\n", "provider must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesAlertRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"valid", "alert_routes:\n  - channel: email\n    min_severity: critical\n  - channel: sms\n    types: [pool, disk]\n", ""},
+		{"webhook", "webhooks:\n  - name: pagerduty\n    url: https://events.example.com\nalert_routes:\n  - channel: webhook:pagerduty\n    types: [pool]\n", ""},
+		{"unknown webhook", "alert_routes:\n  - channel: webhook:pagerduty\n", "unknown channel"},
+		{"unknown channel", "alert_routes:\n  - channel: pager\n", "unknown channel"},
+		{"bad severity", "alert_routes:\n  - channel: email\n    min_severity: high\n", "min_severity"},
+		{"bad type", "alert_routes:\n  - channel: email\n    types: [pools]\n", "unknown type \"pools\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesEmailDigest(t *testing.T) {
+	const smtp = "email:\n  smtp_host: smtp.example.com\n  smtp_port: 587\n"
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"hourly by default", smtp + "  digest:\n    enabled: true\n", ""},
+		{"daily", smtp + "  digest:\n    enabled: true\n    cron: \"0 8 * * *\"\n", ""},
+		{"bad cron", smtp + "  digest:\n    enabled: true\n    cron: \"every day\"\n", "email.digest.cron"},
+		{"disabled", smtp + "  digest:\n    cron: \"every day\"\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesEmailTemplates(t *testing.T) {
+	const smtp = "email:\n  smtp_host: smtp.example.com\n  smtp_port: 587\n"
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"built-in", smtp + "  html: true\n", ""},
+		{"template dir", smtp + "  html: true\n  template_dir: " + t.TempDir() + "\n", ""},
+		{"missing template dir", smtp + "  html: true\n  template_dir: /nonexistent/templates\n", "email.template_dir"},
+		{"plain text", smtp + "  template_dir: /nonexistent/templates\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, baseConfig+tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Overlap is a dataset replicated by its own job that also lies below the
+// dataset of another, recursive job
+type Overlap struct {
+	Parent        string // Name of the recursive job, "default" for the zfs section
+	ParentDataset string
+	Child         string
+	ChildDataset  string
+}
+
+func (o Overlap) String() string {
+	return fmt.Sprintf("%s (job %s) is inside %s, which job %s replicates recursively; job %s leaves it out of its snapshots and sends",
+		o.ChildDataset, o.Child, o.ParentDataset, o.Parent, o.Parent)
+}
+
+// managedDataset is a dataset with its own replication job
+type managedDataset struct {
+	job       string
+	dataset   string
+	recursive bool
+}
+
+func (c *Config) managedDatasets() []managedDataset {
+	managed := []managedDataset{{job: "default", dataset: c.ZFS.Dataset, recursive: c.ZFS.Recursive}}
+	for _, job := range c.Jobs {
+		managed = append(managed, managedDataset{job: job.Name, dataset: job.Dataset, recursive: job.Recursive})
+	}
+	return managed
+}
+
+// Overlaps returns each recursive job's children that another job
+// replicates. Only the topmost are listed: leaving one out also leaves out
+// everything below it.
+func (c *Config) Overlaps() []Overlap {
+	managed := c.managedDatasets()
+	var overlaps []Overlap
+	for _, parent := range managed {
+		if !parent.recursive {
+			continue
+		}
+		for _, child := range managed {
+			if !isBelow(child.dataset, parent.dataset) {
+				continue
+			}
+			topmost := true
+			for _, other := range managed {
+				if isBelow(other.dataset, parent.dataset) && isBelow(child.dataset, other.dataset) {
+					topmost = false
+				}
+			}
+			if topmost {
+				overlaps = append(overlaps, Overlap{
+					Parent:        parent.job,
+					ParentDataset: parent.dataset,
+					Child:         child.job,
+					ChildDataset:  child.dataset,
+				})
+			}
+		}
+	}
+	sort.Slice(overlaps, func(i, j int) bool {
+		if overlaps[i].ParentDataset != overlaps[j].ParentDataset {
+			return overlaps[i].ParentDataset < overlaps[j].ParentDataset
+		}
+		return overlaps[i].ChildDataset < overlaps[j].ChildDataset
+	})
+	return overlaps
+}
+
+// ManagedChildren returns the children of dataset that other jobs
+// replicate, for a recursive job to leave out
+func (c *Config) ManagedChildren(dataset string) []string {
+	var children []string
+	for _, overlap := range c.Overlaps() {
+		if overlap.ParentDataset == dataset {
+			children = append(children, overlap.ChildDataset)
+		}
+	}
+	return children
+}
+
+func (c *Config) warnOverlaps() {
+	for _, overlap := range c.Overlaps() {
+		log.Printf("Warning: %s", overlap)
+	}
+}
+
+// isBelow reports whether dataset is a descendant of parent
+func isBelow(dataset, parent string) bool {
+	return strings.HasPrefix(dataset, parent+"/")
+}
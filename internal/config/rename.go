@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"zfsrabbit/internal/utils"
+)
+
+// RenameDataset rewrites every dataset setting in the config file at path
+// that names from, or one of its children, to use to instead. Comments and
+// the rest of the file are kept; the previous file is saved as path.bak.
+func RenameDataset(path, from, to string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if renameDatasetValues(&doc, from, to) == 0 {
+		return fmt.Errorf("%s doesn't mention dataset %s", path, from)
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	if err := utils.WriteFileAtomic(path+".bak", data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, out.Bytes(), info.Mode().Perm())
+}
+
+// renameDatasetValues renames the values of "dataset" keys below node and
+// returns how many it changed
+func renameDatasetValues(node *yaml.Node, from, to string) int {
+	changed := 0
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value != "dataset" || value.Kind != yaml.ScalarNode {
+				continue
+			}
+			if value.Value == from {
+				value.Value = to
+				changed++
+			} else if child, ok := strings.CutPrefix(value.Value, from+"/"); ok {
+				value.Value = to + "/" + child
+				changed++
+			}
+		}
+	}
+	for _, child := range node.Content {
+		changed += renameDatasetValues(child, from, to)
+	}
+	return changed
+}
@@ -0,0 +1,115 @@
+package display
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+// layouts are the full and short timestamp layouts of each date format
+var layouts = map[string][2]string{
+	"iso": {"2006-01-02 15:04:05", "Jan 02 15:04"},
+	"us":  {"01/02/2006 03:04:05 PM", "01/02 03:04 PM"},
+	"eu":  {"02/01/2006 15:04:05", "02/01 15:04"},
+}
+
+var (
+	mutex    sync.RWMutex
+	settings config.DisplayConfig
+	location = time.Local
+)
+
+// Configure sets the preferences used by every later call; the config is
+// expected to have been validated
+func Configure(cfg config.DisplayConfig) error {
+	loc := time.Local
+	if cfg.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("display.timezone: %w", err)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	settings = cfg
+	location = loc
+	return nil
+}
+
+// Settings returns the current preferences, with defaults filled in, so
+// the web UI can format values the same way
+func Settings() config.DisplayConfig {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	cfg := settings
+	if cfg.TemperatureUnit == "" {
+		cfg.TemperatureUnit = "celsius"
+	}
+	if cfg.DateFormat == "" {
+		cfg.DateFormat = "iso"
+	}
+	return cfg
+}
+
+// Temperature formats a temperature given in Celsius, e.g. "45°C" or "113°F"
+func Temperature(celsius int) string {
+	if Settings().TemperatureUnit == "fahrenheit" {
+		return fmt.Sprintf("%d°F", int(math.Round(float64(celsius)*9/5+32)))
+	}
+	return fmt.Sprintf("%d°C", celsius)
+}
+
+// Bytes formats a size with binary units, e.g. "1.5 GiB"
+func Bytes(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// Duration formats a rough duration for people, e.g. "2h 15m", "45m" or
+// "under a minute"
+func Duration(d time.Duration) string {
+	minutes := int64(d.Round(time.Minute) / time.Minute)
+	switch {
+	case minutes < 1:
+		return "under a minute"
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+	}
+}
+
+// Time formats a timestamp in the display timezone
+func Time(t time.Time) string {
+	return format(t, 0)
+}
+
+// ShortTime formats a timestamp compactly, without seconds or the year,
+// for lists such as Slack snapshot listings
+func ShortTime(t time.Time) string {
+	return format(t, 1)
+}
+
+func format(t time.Time, short int) string {
+	mutex.RLock()
+	dateFormat, loc := settings.DateFormat, location
+	mutex.RUnlock()
+
+	if dateFormat == "" {
+		dateFormat = "iso"
+	}
+	return t.In(loc).Format(layouts[dateFormat][short])
+}
@@ -0,0 +1,70 @@
+package display
+
+import (
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func configure(t *testing.T, cfg config.DisplayConfig) {
+	if err := Configure(cfg); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	t.Cleanup(func() { Configure(config.DisplayConfig{}) })
+}
+
+func TestTemperature(t *testing.T) {
+	if got := Temperature(45); got != "45°C" {
+		t.Errorf("Expected Celsius by default, got %s", got)
+	}
+
+	configure(t, config.DisplayConfig{TemperatureUnit: "fahrenheit"})
+	for celsius, want := range map[int]string{45: "113°F", 46: "115°F", 0: "32°F"} {
+		if got := Temperature(celsius); got != want {
+			t.Errorf("Temperature(%d) = %s, expected %s", celsius, got, want)
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	at := time.Date(2024, 6, 1, 14, 5, 9, 0, time.UTC)
+
+	tests := []struct {
+		cfg   config.DisplayConfig
+		full  string
+		short string
+	}{
+		{config.DisplayConfig{Timezone: "UTC"}, "2024-06-01 14:05:09", "Jun 01 14:05"},
+		{config.DisplayConfig{DateFormat: "us", Timezone: "America/New_York"}, "06/01/2024 10:05:09 AM", "06/01 10:05 AM"},
+		{config.DisplayConfig{DateFormat: "eu", Timezone: "Europe/Berlin"}, "01/06/2024 16:05:09", "01/06 16:05"},
+	}
+
+	for _, tt := range tests {
+		configure(t, tt.cfg)
+		if got := Time(at); got != tt.full {
+			t.Errorf("Time with %+v = %q, expected %q", tt.cfg, got, tt.full)
+		}
+		if got := ShortTime(at); got != tt.short {
+			t.Errorf("ShortTime with %+v = %q, expected %q", tt.cfg, got, tt.short)
+		}
+	}
+
+	if err := Configure(config.DisplayConfig{Timezone: "Mars/Olympus"}); err == nil {
+		t.Error("Expected an unknown timezone to be rejected")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		20 * time.Second: "under a minute",
+		45 * time.Minute: "45m",
+		2 * time.Hour:    "2h",
+		2*time.Hour + 14*time.Minute + 40*time.Second: "2h 15m",
+	}
+	for d, want := range tests {
+		if got := Duration(d); got != want {
+			t.Errorf("Duration(%s) = %s, expected %s", d, got, want)
+		}
+	}
+}
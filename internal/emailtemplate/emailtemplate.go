@@ -0,0 +1,211 @@
+// Package emailtemplate renders alerts as HTML email with html/template. The
+// built-in templates can be replaced, in whole or one block at a time, by
+// templates in a directory of the operator's choosing.
+package emailtemplate
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Name is the template executed for every alert
+const Name = "alert.html"
+
+//go:embed templates/*.html
+var builtin embed.FS
+
+// Colors of each severity and of device states, used by the built-in template
+var (
+	severityColors = map[string]string{
+		"EMERGENCY": "#7b1fa2",
+		"CRITICAL":  "#c62828",
+		"WARNING":   "#ef6c00",
+		"INFO":      "#1565c0",
+	}
+	stateColors = map[string]string{
+		"ONLINE":   "#2e7d32",
+		"DEGRADED": "#ef6c00",
+		"OFFLINE":  "#616161",
+	}
+)
+
+const (
+	neutralColor = "#455a64" // Alerts without a severity
+	failedColor  = "#c62828" // FAULTED, UNAVAIL, REMOVED and other states
+)
+
+var (
+	// "  sda: ONLINE (R:0 W:0 C:0)" in pool alerts
+	deviceRegex = regexp.MustCompile(`^\s+(\S+): (\S+) \(R:(\d+) W:(\d+) C:(\d+)\)$`)
+	// "Temperature: 45°C", but not sentences that happen to contain a colon
+	fieldRegex = regexp.MustCompile(`^([\p{Lu}][\p{L}\d /.-]{0,30}): (.+)$`)
+)
+
+// Alert is what the template is executed with
+type Alert struct {
+	Subject  string // As sent, e.g. "[CRITICAL] HDD Health Alert: sda"
+	Title    string // The subject without its severity
+	Severity string // EMERGENCY, CRITICAL, WARNING, INFO, or empty if unknown
+	Body     string // The plain text alert
+	Sections []Section
+}
+
+// Section is a run of body lines of the same kind: a heading, key/value
+// fields, pool device status or plain text
+type Section struct {
+	Heading string
+	Fields  []Field
+	Devices []Device
+	Lines   []string
+}
+
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Device is a line of the device status in a pool alert
+type Device struct {
+	Name   string
+	State  string
+	Read   string
+	Write  string
+	Cksum  string
+	Errors bool // Any read, write or checksum errors
+}
+
+// Load parses the built-in templates, then every *.html in dir, if set, on
+// top of them. A file in dir named alert.html replaces the whole email; other
+// files can redefine single blocks such as "style" or "footer".
+func Load(dir string) (*template.Template, error) {
+	tmpl, err := template.New(Name).Funcs(template.FuncMap{
+		"severityColor": SeverityColor,
+		"stateColor":    StateColor,
+	}).ParseFS(builtin, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		return tmpl, nil
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return tmpl, nil
+	}
+	return tmpl.ParseFiles(files...)
+}
+
+// Render executes tmpl for an alert
+func Render(tmpl *template.Template, subject, severity, body string) (string, error) {
+	title := subject
+	if severity != "" {
+		title = strings.TrimSpace(strings.TrimPrefix(subject, "["+severity+"]"))
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.ExecuteTemplate(&buf, Name, Alert{
+		Subject:  subject,
+		Title:    title,
+		Severity: severity,
+		Body:     body,
+		Sections: Parse(body),
+	})
+	return buf.String(), err
+}
+
+// Parse splits a plain text alert into sections for the template
+func Parse(body string) []Section {
+	var sections []Section
+	last := func() *Section {
+		if len(sections) == 0 {
+			return nil
+		}
+		return &sections[len(sections)-1]
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			// A blank line ends a section
+			if s := last(); s != nil && !s.empty() {
+				sections = append(sections, Section{})
+			}
+			continue
+		}
+
+		if m := deviceRegex.FindStringSubmatch(line); m != nil {
+			device := Device{Name: m[1], State: m[2], Read: m[3], Write: m[4], Cksum: m[5]}
+			device.Errors = device.Read != "0" || device.Write != "0" || device.Cksum != "0"
+			if s := last(); s != nil && s.Heading == "" && len(s.Fields) == 0 && len(s.Lines) == 0 {
+				s.Devices = append(s.Devices, device)
+			} else {
+				sections = append(sections, Section{Devices: []Device{device}})
+			}
+			continue
+		}
+
+		if strings.HasSuffix(trimmed, ":") && line == trimmed {
+			sections = append(sections, Section{Heading: strings.TrimSuffix(trimmed, ":")})
+			continue
+		}
+
+		if m := fieldRegex.FindStringSubmatch(line); m != nil {
+			if s := last(); s != nil && s.Heading == "" && len(s.Devices) == 0 && len(s.Lines) == 0 {
+				s.Fields = append(s.Fields, Field{Key: m[1], Value: m[2]})
+			} else {
+				sections = append(sections, Section{Fields: []Field{{Key: m[1], Value: m[2]}}})
+			}
+			continue
+		}
+
+		if s := last(); s != nil && s.Heading == "" && len(s.Fields) == 0 && len(s.Devices) == 0 {
+			s.Lines = append(s.Lines, trimmed)
+		} else {
+			sections = append(sections, Section{Lines: []string{trimmed}})
+		}
+	}
+
+	// Drop the empty sections blank lines leave
+	kept := sections[:0]
+	for _, s := range sections {
+		if !s.empty() {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func (s Section) empty() bool {
+	return s.Heading == "" && len(s.Fields) == 0 && len(s.Devices) == 0 && len(s.Lines) == 0
+}
+
+// SeverityColor returns the color of a severity
+func SeverityColor(severity string) string {
+	if color, ok := severityColors[severity]; ok {
+		return color
+	}
+	return neutralColor
+}
+
+// StateColor returns the color of a pool device state
+func StateColor(state string) string {
+	if color, ok := stateColors[state]; ok {
+		return color
+	}
+	return failedColor
+}
@@ -0,0 +1,107 @@
+package emailtemplate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const poolBody = "ZFS Pool Health Alert\n\nPool: tank\nState: DEGRADED\nDegraded: true\n\nDevice Status:\n" +
+	"  mirror-0: DEGRADED (R:0 W:0 C:0)\n  sda: ONLINE (R:0 W:0 C:0)\n  sdb: FAULTED (R:3 W:0 C:12)\n\n" +
+	"Run `zpool status -v tank` to see which devices are affected.\n"
+
+func TestParse(t *testing.T) {
+	sections := Parse(poolBody)
+	if len(sections) != 5 {
+		t.Fatalf("Expected 5 sections, got %+v", sections)
+	}
+
+	if len(sections[0].Lines) != 1 || sections[0].Lines[0] != "ZFS Pool Health Alert" {
+		t.Errorf("Expected the title line first, got %+v", sections[0])
+	}
+	if fields := sections[1].Fields; len(fields) != 3 || fields[1] != (Field{"State", "DEGRADED"}) {
+		t.Errorf("Unexpected fields %+v", fields)
+	}
+	if sections[2].Heading != "Device Status" {
+		t.Errorf("Expected the device status heading, got %+v", sections[2])
+	}
+	devices := sections[3].Devices
+	if len(devices) != 3 || devices[2].Name != "sdb" || devices[2].State != "FAULTED" || !devices[2].Errors || devices[1].Errors {
+		t.Errorf("Unexpected devices %+v", devices)
+	}
+	if len(sections[4].Lines) != 1 || len(sections[4].Fields) != 0 {
+		t.Errorf("Expected the runbook as text, got %+v", sections[4])
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := Render(tmpl, "[CRITICAL] HDD Health Alert: sda", "CRITICAL", "Device: /dev/sda\nModel: <script>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{">CRITICAL</span>", ">HDD Health Alert: sda</span>", "background:#c62828", "&lt;script&gt;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %q in:\n%s", want, html)
+		}
+	}
+
+	html, err = Render(tmpl, "ZFS Pool Alert: tank", "", poolBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"background:" + neutralColor, "color:#2e7d32;\">ONLINE", "color:#c62828;\">FAULTED", ">Checksum</th>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %q in:\n%s", want, html)
+		}
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	footer := `{{define "footer"}}<tr><td>Storage team, ext. 4321</td></tr>{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "footer.html"), []byte(footer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := Render(tmpl, "[WARNING] ZFS Pool Capacity Alert: tank", "WARNING", "Capacity: 85%\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, "Storage team, ext. 4321") || strings.Contains(html, "Sent by ZFSRabbit") {
+		t.Errorf("Expected the footer replaced:\n%s", html)
+	}
+	if !strings.Contains(html, "Capacity") {
+		t.Errorf("Expected the rest of the built-in template:\n%s", html)
+	}
+
+	// A whole replacement
+	if err := os.WriteFile(filepath.Join(dir, "alert.html"), []byte(`<p>{{.Title}}</p>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, err = Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if html, _ = Render(tmpl, "[WARNING] Check Failed: backup", "WARNING", ""); html != "<p>Check Failed: backup</p>" {
+		t.Errorf("Expected the replaced template, got %q", html)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.html"), []byte(`{{if}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("Expected an error for a broken template")
+	}
+	if _, err := Load(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types pushed to dashboard clients
+const (
+	TypePool    = "pool"    // Data is a PoolChange
+	TypeSend    = "send"    // Data is a SendProgress
+	TypeRestore = "restore" // Data is a RestoreChange
+)
+
+// subscriberBuffer is how many events a slow subscriber can fall behind by
+// before it starts missing them
+const subscriberBuffer = 64
+
+// Event is one change pushed to /api/events clients
+type Event struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// PoolChange reports a pool's health changing
+type PoolChange struct {
+	Pool      string `json:"pool"`
+	State     string `json:"state"`
+	Degraded  bool   `json:"degraded"`
+	Errors    int    `json:"errors"`
+	Scrubbing bool   `json:"scrubbing"`
+}
+
+// Send phases
+const (
+	PhaseStarted   = "started"
+	PhaseProgress  = "progress"
+	PhaseCompleted = "completed"
+	PhaseFailed    = "failed"
+)
+
+// SendProgress reports a snapshot send to one target starting, moving on or
+// finishing
+type SendProgress struct {
+	Job            string `json:"job"`
+	Target         string `json:"target"`
+	Snapshot       string `json:"snapshot"`
+	Phase          string `json:"phase"`
+	SentBytes      int64  `json:"sent_bytes"`
+	EstimatedBytes int64  `json:"estimated_bytes,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// RestoreChange reports a restore job's status or progress changing
+type RestoreChange struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Progress int    `json:"progress"`
+}
+
+// Bus fans events out to subscribers. Publishing never blocks, so a client
+// that falls behind misses events rather than stalling a send or restore.
+// A nil Bus drops everything, so components work without one.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish sends an event to every subscriber with room for it
+func (b *Bus) Publish(eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+	event := Event{Type: eventType, Time: time.Now(), Data: data}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of events and a function that ends the
+// subscription and closes the channel
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package events
+
+import "testing"
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	updates, unsubscribe := bus.Subscribe()
+
+	bus.Publish(TypeRestore, RestoreChange{ID: "restore_1", Status: "restoring", Progress: 40})
+	event := <-updates
+	if event.Type != TypeRestore || event.Data.(RestoreChange).Progress != 40 {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// A subscriber that stops reading misses events instead of blocking
+	for i := 0; i < subscriberBuffer*2; i++ {
+		bus.Publish(TypePool, PoolChange{Pool: "tank"})
+	}
+	if len(updates) != subscriberBuffer {
+		t.Errorf("Expected a full buffer of %d events, got %d", subscriberBuffer, len(updates))
+	}
+
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(TypePool, PoolChange{Pool: "tank"})
+
+	var nilBus *Bus
+	nilBus.Publish(TypePool, PoolChange{Pool: "tank"})
+}
@@ -0,0 +1,71 @@
+package export
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+)
+
+// StatusSource produces the status document to export
+type StatusSource func() map[string]interface{}
+
+// Exporter periodically writes the status document to a JSON file so
+// air-gapped collectors can read it without HTTP access
+type Exporter struct {
+	config *config.ExportConfig
+	source StatusSource
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func New(cfg *config.ExportConfig, source StatusSource) *Exporter {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Exporter{
+		config: cfg,
+		source: source,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (e *Exporter) Start() {
+	log.Printf("Exporting status to %s every %s", e.config.Path, e.config.Interval)
+
+	if err := e.WriteOnce(); err != nil {
+		log.Printf("Failed to export status: %v", err)
+	}
+
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.WriteOnce(); err != nil {
+				log.Printf("Failed to export status: %v", err)
+			}
+		}
+	}
+}
+
+func (e *Exporter) Stop() {
+	e.cancel()
+}
+
+// WriteOnce collects the status and atomically replaces the export file
+func (e *Exporter) WriteOnce() error {
+	status := e.source()
+	status["generated_at"] = time.Now().UTC().Format(time.RFC3339)
+	if hostname, err := os.Hostname(); err == nil {
+		status["hostname"] = hostname
+	}
+
+	return utils.WriteJSONAtomic(e.config.Path, status, 0644)
+}
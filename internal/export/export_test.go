@@ -0,0 +1,80 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestWriteOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status", "zfsrabbit.json")
+	cfg := &config.ExportConfig{
+		Path:     path,
+		Interval: time.Minute,
+	}
+
+	exporter := New(cfg, func() map[string]interface{} {
+		return map[string]interface{}{
+			"healthy":      false,
+			"pendingSends": []string{"autosnap_2024-01-01_02-00-00"},
+		}
+	})
+
+	if err := exporter.WriteOnce(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected export file to exist: %v", err)
+	}
+
+	var status map[string]interface{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("Export is not valid JSON: %v", err)
+	}
+
+	if status["healthy"] != false {
+		t.Errorf("Expected healthy=false, got %v", status["healthy"])
+	}
+
+	if _, ok := status["generated_at"]; !ok {
+		t.Error("Expected generated_at timestamp in export")
+	}
+
+	// No temporary files may be left behind next to the export
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the export file in directory, got %d entries", len(entries))
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zfsrabbit.json")
+	exporter := New(&config.ExportConfig{Path: path, Interval: 10 * time.Millisecond}, func() map[string]interface{} {
+		return map[string]interface{}{"healthy": true}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		exporter.Start()
+		close(done)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	exporter.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Exporter did not stop")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected export file to be written: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Package failover reverses the replication direction after the backup
+// server has taken over: the old source is brought in line with the new
+// primary and becomes its backup target.
+package failover
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/zfs"
+)
+
+// Plan is what reversing replication will do to the local dataset
+type Plan struct {
+	LocalDataset  string
+	RemoteHost    string
+	RemoteDataset string
+	// The newest snapshot both sides have, matched by GUID so a renamed
+	// snapshot still counts; the names on each side usually agree
+	Common       string
+	CommonRemote string
+	Discard      []string // Local snapshots after Common that the new primary never received
+	Pull         []string // Snapshots the new primary took since it took over, oldest first
+}
+
+// NewPlan compares the local snapshots with the new primary's, both oldest
+// first, and finds where they diverged
+func NewPlan(localDataset, remoteHost, remoteDataset string, local, remote []zfs.SnapshotGUID) (*Plan, error) {
+	plan := &Plan{LocalDataset: localDataset, RemoteHost: remoteHost, RemoteDataset: remoteDataset}
+
+	remoteIndex := make(map[string]int)
+	for i, snapshot := range remote {
+		remoteIndex[snapshot.GUID] = i
+	}
+
+	common := -1
+	for i, snapshot := range local {
+		if _, ok := remoteIndex[snapshot.GUID]; ok {
+			common = i
+		}
+	}
+	if common < 0 {
+		return nil, fmt.Errorf("%s and %s:%s have no snapshot in common, so the old source needs a full resync",
+			localDataset, remoteHost, remoteDataset)
+	}
+
+	plan.Common = local[common].Name
+	for _, snapshot := range local[common+1:] {
+		plan.Discard = append(plan.Discard, snapshot.Name)
+	}
+	remoteCommon := remoteIndex[local[common].GUID]
+	plan.CommonRemote = remote[remoteCommon].Name
+	for _, snapshot := range remote[remoteCommon+1:] {
+		plan.Pull = append(plan.Pull, snapshot.Name)
+	}
+	return plan, nil
+}
+
+// String describes the plan for the operator to confirm
+func (p *Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Reverse replication: %s:%s becomes the source, %s becomes its target\n\n", p.RemoteHost, p.RemoteDataset, p.LocalDataset)
+	fmt.Fprintf(&b, "Common snapshot: %s", p.Common)
+	if p.CommonRemote != p.Common {
+		fmt.Fprintf(&b, " (%s on %s)", p.CommonRemote, p.RemoteHost)
+	}
+	b.WriteString("\n")
+
+	if len(p.Discard) > 0 {
+		fmt.Fprintf(&b, "Roll %s back to %s, destroying %d snapshot(s) the new primary never received:\n", p.LocalDataset, p.Common, len(p.Discard))
+		for _, name := range p.Discard {
+			fmt.Fprintf(&b, "  - %s\n", name)
+		}
+	} else {
+		fmt.Fprintf(&b, "Roll %s back to %s, discarding any changes since\n", p.LocalDataset, p.Common)
+	}
+
+	if len(p.Pull) > 0 {
+		fmt.Fprintf(&b, "Receive %d snapshot(s) from %s, up to %s\n", len(p.Pull), p.RemoteHost, p.Pull[len(p.Pull)-1])
+	} else {
+		fmt.Fprintf(&b, "Nothing to receive, %s has no snapshots after %s\n", p.RemoteHost, p.CommonRemote)
+	}
+	fmt.Fprintf(&b, "Set readonly=on on %s so only replication changes it\n", p.LocalDataset)
+	return b.String()
+}
+
+// Apply carries out the plan: roll the local dataset back to the common
+// snapshot, receive the new primary's later snapshots and make the local
+// dataset read-only
+func Apply(ctx context.Context, plan *Plan, zfsManager *zfs.Manager, sshTransport *transport.SSHTransport, raw bool) error {
+	if err := zfsManager.RollbackSnapshot(plan.Common); err != nil {
+		return fmt.Errorf("failed to roll %s back to %s: %w", plan.LocalDataset, plan.Common, err)
+	}
+
+	if len(plan.Pull) > 0 {
+		err := sshTransport.Restore(ctx, transport.RestoreRequest{
+			Snapshot:     plan.Pull[len(plan.Pull)-1],
+			Since:        plan.CommonRemote,
+			LocalDataset: plan.LocalDataset,
+			Force:        true,
+			Raw:          raw,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to receive snapshots from %s: %w", plan.RemoteHost, err)
+		}
+	}
+
+	if err := zfs.SetProperties(plan.LocalDataset, map[string]string{"readonly": "on"}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReversedConfig returns the config for the new primary: its dataset is
+// the old target, replicated over SSH to the old source, reached as
+// user@host. Extra remotes and jobs are left out since their targets hold
+// copies of the old source.
+func ReversedConfig(cfg *config.Config, host, user string) ([]byte, error) {
+	reversed := *cfg
+	reversed.ZFS.Dataset = cfg.SSH.RemoteDataset
+	reversed.SSH.RemoteHost = host
+	reversed.SSH.RemoteUser = user
+	reversed.SSH.RemoteDataset = cfg.ZFS.Dataset
+	reversed.Remotes = nil
+	reversed.Jobs = nil
+	return yaml.Marshal(&reversed)
+}
@@ -0,0 +1,77 @@
+package failover
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/zfs"
+)
+
+func TestNewPlan(t *testing.T) {
+	local := snapshots("autosnap_1", "11", "autosnap_2", "22", "autosnap_3", "33", "autosnap_4", "44")
+	remote := snapshots("autosnap_1", "11", "before-failover", "22", "autosnap_5", "55", "autosnap_6", "66")
+
+	plan, err := NewPlan("tank/data", "backup", "backup/data", local, remote)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if plan.Common != "autosnap_2" || plan.CommonRemote != "before-failover" {
+		t.Errorf("Expected the renamed snapshot to be matched by GUID, got %q and %q", plan.Common, plan.CommonRemote)
+	}
+	if !slices.Equal(plan.Discard, []string{"autosnap_3", "autosnap_4"}) {
+		t.Errorf("Expected the unreplicated local snapshots to be discarded, got %v", plan.Discard)
+	}
+	if !slices.Equal(plan.Pull, []string{"autosnap_5", "autosnap_6"}) {
+		t.Errorf("Expected the new primary's snapshots to be pulled, got %v", plan.Pull)
+	}
+	if text := plan.String(); !strings.Contains(text, "before-failover on backup") || !strings.Contains(text, "up to autosnap_6") {
+		t.Errorf("Unexpected plan text:\n%s", text)
+	}
+
+	if _, err := NewPlan("tank/data", "backup", "backup/data", local, snapshots("other", "99")); err == nil {
+		t.Error("Expected an error without a common snapshot")
+	}
+}
+
+// snapshots builds a snapshot list from name, GUID pairs
+func snapshots(pairs ...string) []zfs.SnapshotGUID {
+	var list []zfs.SnapshotGUID
+	for i := 0; i < len(pairs); i += 2 {
+		list = append(list, zfs.SnapshotGUID{Name: pairs[i], GUID: pairs[i+1]})
+	}
+	return list
+}
+
+func TestReversedConfig(t *testing.T) {
+	cfg := &config.Config{
+		ZFS:     config.ZFSConfig{Dataset: "tank/data"},
+		SSH:     config.SSHConfig{RemoteHost: "backup", RemoteUser: "zfs", RemoteDataset: "backup/data", PrivateKey: "/root/.ssh/id_ed25519"},
+		Remotes: []config.RemoteConfig{{Name: "offsite"}},
+	}
+
+	data, err := ReversedConfig(cfg, "primary.example.com", "root")
+	if err != nil {
+		t.Fatalf("ReversedConfig: %v", err)
+	}
+	var reversed config.Config
+	if err := yaml.Unmarshal(data, &reversed); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if reversed.ZFS.Dataset != "backup/data" || reversed.SSH.RemoteDataset != "tank/data" {
+		t.Errorf("Expected the datasets to swap, got %s -> %s", reversed.ZFS.Dataset, reversed.SSH.RemoteDataset)
+	}
+	if reversed.SSH.RemoteHost != "primary.example.com" || reversed.SSH.RemoteUser != "root" || reversed.SSH.PrivateKey != cfg.SSH.PrivateKey {
+		t.Errorf("Unexpected target %+v", reversed.SSH)
+	}
+	if len(reversed.Remotes) != 0 {
+		t.Errorf("Expected remotes to be left out, got %v", reversed.Remotes)
+	}
+	if cfg.ZFS.Dataset != "tank/data" {
+		t.Error("Expected the original config to be unchanged")
+	}
+}
@@ -0,0 +1,97 @@
+// Package features lets large capabilities ship dark and be turned on or off
+// per site from the features section of config.yaml, without a rebuild.
+package features
+
+import "sort"
+
+// Flag names, as written under features: in config.yaml
+const (
+	ResumableSends    = "resumable_sends"
+	ObjectStoreTarget = "object_store_target"
+)
+
+// Stages describe how much a flag's capability can be trusted
+const (
+	Experimental = "experimental"
+	Beta         = "beta"
+	Stable       = "stable"
+)
+
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Stage       string `json:"stage"`
+	Default     bool   `json:"default"`
+}
+
+// Known lists every flag this build understands. A flag is removed once its
+// capability is no longer optional.
+var Known = []Flag{
+	{
+		Name:        ResumableSends,
+		Description: "Receive non-recursive sends with zfs receive -s and resume interrupted transfers from the remote's receive_resume_token",
+		Stage:       Stable,
+		Default:     true,
+	},
+	{
+		Name:        ObjectStoreTarget,
+		Description: "Replicate to an S3-compatible object store as well as over SSH",
+		Stage:       Experimental,
+	},
+}
+
+// Lookup returns the flag with the given name
+func Lookup(name string) (Flag, bool) {
+	for _, flag := range Known {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Enabled reports whether a flag is on, taking the site's overrides into
+// account. Unknown flags are always off.
+func Enabled(overrides map[string]bool, name string) bool {
+	flag, ok := Lookup(name)
+	if !ok {
+		return false
+	}
+	if enabled, set := overrides[name]; set {
+		return enabled
+	}
+	return flag.Default
+}
+
+// State is a flag and its effective value on this site
+type State struct {
+	Flag
+	Enabled    bool `json:"enabled"`
+	Overridden bool `json:"overridden"`
+}
+
+// List returns every known flag with its effective value, sorted by name
+func List(overrides map[string]bool) []State {
+	states := make([]State, 0, len(Known))
+	for _, flag := range Known {
+		_, overridden := overrides[flag.Name]
+		states = append(states, State{
+			Flag:       flag,
+			Enabled:    Enabled(overrides, flag.Name),
+			Overridden: overridden,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// EnabledNames returns the names of the flags that are on, sorted
+func EnabledNames(overrides map[string]bool) []string {
+	names := []string{}
+	for _, state := range List(overrides) {
+		if state.Enabled {
+			names = append(names, state.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,45 @@
+package features
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	if !Enabled(nil, ResumableSends) {
+		t.Error("resumable_sends should default on")
+	}
+	if Enabled(nil, ObjectStoreTarget) {
+		t.Error("object_store_target should default off")
+	}
+	if Enabled(map[string]bool{ResumableSends: false}, ResumableSends) {
+		t.Error("override should turn resumable_sends off")
+	}
+	if !Enabled(map[string]bool{ObjectStoreTarget: true}, ObjectStoreTarget) {
+		t.Error("override should turn object_store_target on")
+	}
+	if Enabled(map[string]bool{"no_such_flag": true}, "no_such_flag") {
+		t.Error("unknown flags should always be off")
+	}
+}
+
+func TestList(t *testing.T) {
+	states := List(map[string]bool{ObjectStoreTarget: true})
+	if len(states) != len(Known) {
+		t.Fatalf("expected %d flags, got %d", len(Known), len(states))
+	}
+
+	for _, state := range states {
+		if state.Name == ObjectStoreTarget && (!state.Enabled || !state.Overridden) {
+			t.Errorf("expected %s to be enabled by override: %+v", ObjectStoreTarget, state)
+		}
+		if state.Name == ResumableSends && (!state.Enabled || state.Overridden) {
+			t.Errorf("expected %s to be enabled by default: %+v", ResumableSends, state)
+		}
+	}
+
+	want := []string{ObjectStoreTarget, ResumableSends}
+	if got := EnabledNames(map[string]bool{ObjectStoreTarget: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("EnabledNames = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,235 @@
+// Package grpcapi serves the gRPC API defined in api/proto/zfsrabbit/v1
+// alongside the web server, for provisioning systems that want typed
+// messages and streamed restore progress rather than polling REST.
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/grpcapi/zfsrabbitv1"
+	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/restore"
+	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/zfs"
+)
+
+// Authenticator checks a bearer token, or a user and password when there is
+// no token, and reports the user and their role
+type Authenticator func(token, user, pass string) (name, role string, ok bool)
+
+// methodRoles is the role each call needs, the same as the write role of
+// the REST endpoint it mirrors. Calls missing from here need admin.
+var methodRoles = map[string]string{
+	zfsrabbitv1.ZFSRabbit_GetStatus_FullMethodName:       config.RoleViewer,
+	zfsrabbitv1.ZFSRabbit_ListSnapshots_FullMethodName:   config.RoleViewer,
+	zfsrabbitv1.ZFSRabbit_CreateSnapshot_FullMethodName:  config.RoleOperator,
+	zfsrabbitv1.ZFSRabbit_StartRestore_FullMethodName:    config.RoleAdmin,
+	zfsrabbitv1.ZFSRabbit_ConfirmRestore_FullMethodName:  config.RoleAdmin,
+	zfsrabbitv1.ZFSRabbit_ListRestoreJobs_FullMethodName: config.RoleViewer,
+	zfsrabbitv1.ZFSRabbit_WatchRestoreJob_FullMethodName: config.RoleViewer,
+}
+
+// readMethods aren't audited, like GET requests to the web server
+var readMethods = map[string]bool{
+	zfsrabbitv1.ZFSRabbit_GetStatus_FullMethodName:       true,
+	zfsrabbitv1.ZFSRabbit_ListSnapshots_FullMethodName:   true,
+	zfsrabbitv1.ZFSRabbit_ListRestoreJobs_FullMethodName: true,
+	zfsrabbitv1.ZFSRabbit_WatchRestoreJob_FullMethodName: true,
+}
+
+type Server struct {
+	zfsrabbitv1.UnimplementedZFSRabbitServer
+
+	config         *config.Config
+	scheduler      *scheduler.Scheduler
+	monitor        *monitor.Monitor
+	zfsManager     *zfs.Manager
+	restoreManager *restore.RestoreManager
+	authenticate   Authenticator
+	events         *events.Bus
+	tlsConfig      *tls.Config
+	grpcServer     *grpc.Server
+	closing        chan struct{} // Closed on shutdown to end restore job watches
+	closeOnce      sync.Once
+}
+
+func New(cfg *config.Config, sched *scheduler.Scheduler, mon *monitor.Monitor, zfsMgr *zfs.Manager, restoreMgr *restore.RestoreManager, auth Authenticator) *Server {
+	return &Server{
+		config:         cfg,
+		scheduler:      sched,
+		monitor:        mon,
+		zfsManager:     zfsMgr,
+		restoreManager: restoreMgr,
+		authenticate:   auth,
+		closing:        make(chan struct{}),
+	}
+}
+
+// SetEvents lets WatchRestoreJob send job changes as they happen rather
+// than when it next polls
+func (s *Server) SetEvents(bus *events.Bus) {
+	s.events = bus
+}
+
+// SetTLSConfig serves the API over TLS
+func (s *Server) SetTLSConfig(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// newGRPCServer returns a gRPC server with the ZFSRabbit service registered
+// behind authentication
+func (s *Server) newGRPCServer() *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryAuth),
+		grpc.StreamInterceptor(s.streamAuth),
+	}
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	zfsrabbitv1.RegisterZFSRabbitServer(server, s)
+	return server
+}
+
+// Start listens on server.grpc_port and serves the API in the background
+func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.config.Server.GRPCPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("gRPC server failed to listen on %s: %w", addr, err)
+	}
+
+	s.grpcServer = s.newGRPCServer()
+	if s.tlsConfig != nil {
+		log.Printf("gRPC server starting on %s (TLS)", addr)
+	} else {
+		log.Printf("gRPC server starting on %s", addr)
+	}
+
+	go func() {
+		if err := s.grpcServer.Serve(listener); err != nil {
+			log.Printf("gRPC server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown ends restore job watches and waits for the calls in flight to
+// finish, cutting them off if ctx ends first
+func (s *Server) Shutdown(ctx context.Context) {
+	s.closeOnce.Do(func() { close(s.closing) })
+	if s.grpcServer == nil {
+		return
+	}
+
+	log.Println("Gracefully shutting down gRPC server")
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+	}
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if readMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	resp, err := handler(ctx, req)
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	audit.Record(audit.Event{Actor: user, Action: info.FullMethod, Remote: remoteAddr(ctx), Outcome: outcome})
+	return resp, err
+}
+
+func (s *Server) streamAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := s.authorize(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// authorize authenticates a call from its authorization metadata, which
+// takes a bearer API token or basic auth like the web server, and checks
+// the caller's role allows the method
+func (s *Server) authorize(ctx context.Context, method string) (string, error) {
+	token, user, pass := callCredentials(ctx)
+	name, role, ok := s.authenticate(token, user, pass)
+	if !ok {
+		if name != "" {
+			audit.Record(audit.Event{Actor: name, Action: "login_failed", Remote: remoteAddr(ctx), Outcome: "denied"})
+		}
+		return "", status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	required, known := methodRoles[method]
+	if !known {
+		required = config.RoleAdmin
+	}
+	if !config.RoleAllows(role, required) {
+		audit.Record(audit.Event{Actor: name, Action: method, Remote: remoteAddr(ctx), Outcome: "denied"})
+		return "", status.Errorf(codes.PermissionDenied, "requires the %s role", required)
+	}
+	return name, nil
+}
+
+// callCredentials reads the bearer token or basic auth user and password
+// from a call's authorization metadata
+func callCredentials(ctx context.Context) (token, user, pass string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", "", ""
+	}
+
+	scheme, value, _ := strings.Cut(values[0], " ")
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return strings.TrimSpace(value), "", ""
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return "", "", ""
+		}
+		user, pass, _ = strings.Cut(string(decoded), ":")
+		return "", user, pass
+	}
+	return "", "", ""
+}
+
+func remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,317 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/grpcapi/zfsrabbitv1"
+	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/restore"
+	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/zfs"
+	"zfsrabbit/test/mocks"
+)
+
+// fakeZFS answers zfs commands by full command line. Runs of a command in
+// block wait for it to be closed, then fail.
+type fakeZFS struct {
+	mu      sync.Mutex
+	outputs map[string]string
+	runs    []string
+	block   map[string]chan struct{}
+	started chan string
+}
+
+func (f *fakeZFS) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func (f *fakeZFS) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+func (f *fakeZFS) Output(cmd *exec.Cmd) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return []byte(f.outputs[strings.Join(cmd.Args, " ")]), nil
+}
+
+func (f *fakeZFS) Run(cmd *exec.Cmd) error {
+	line := strings.Join(cmd.Args, " ")
+	f.mu.Lock()
+	f.runs = append(f.runs, line)
+	f.mu.Unlock()
+
+	for prefix, release := range f.block {
+		if strings.HasPrefix(line, prefix) {
+			f.started <- line
+			<-release
+			return errors.New("out of space")
+		}
+	}
+	return nil
+}
+
+const testSnapshots = "tank/data@daily\tWed Jul 17 18:00 2024\t1M\t2M\n" +
+	"tank/restored@daily\tThu Jul 18 18:00 2024\t3M\t4M\n"
+
+type testServer struct {
+	client    zfsrabbitv1.ZFSRabbitClient
+	scheduler *scheduler.Scheduler
+	zfs       *fakeZFS
+}
+
+// startTestServer serves the API over an in-memory connection. The token
+// names the caller's role, and admin can also sign in with a password.
+func startTestServer(t *testing.T, executor *fakeZFS) *testServer {
+	t.Helper()
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/data"},
+		SSH: config.SSHConfig{RemoteDataset: "backup/data"},
+	}
+
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+	sshTransport := transport.NewSSHTransport(&cfg.SSH)
+	sched := scheduler.New(cfg, zfsManager, sshTransport, mocks.NewMockAlerter())
+	restoreManager := restore.New(sshTransport, zfsManager)
+	bus := events.NewBus()
+	restoreManager.SetEvents(bus)
+
+	auth := func(token, user, pass string) (string, string, bool) {
+		if token != "" {
+			return token, token, config.ValidRole(token)
+		}
+		return user, config.RoleAdmin, user == "admin" && pass == "secret"
+	}
+	server := New(cfg, sched, monitor.New(cfg, mocks.NewMockAlerter()), zfsManager, restoreManager, auth)
+	server.SetEvents(bus)
+
+	listener := bufconn.Listen(1 << 20)
+	server.grpcServer = server.newGRPCServer()
+	go server.grpcServer.Serve(listener)
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &testServer{client: zfsrabbitv1.NewZFSRabbitClient(conn), scheduler: sched, zfs: executor}
+}
+
+func as(role string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+role)
+}
+
+func TestAuthentication(t *testing.T) {
+	server := startTestServer(t, &fakeZFS{})
+
+	_, err := server.client.GetStatus(context.Background(), &zfsrabbitv1.GetStatusRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected a call without credentials to be unauthenticated, got %v", err)
+	}
+
+	if _, err := server.client.GetStatus(as(config.RoleViewer), &zfsrabbitv1.GetStatusRequest{}); err != nil {
+		t.Errorf("Expected a viewer to read the status, got %v", err)
+	}
+
+	_, err = server.client.StartRestore(as(config.RoleOperator), &zfsrabbitv1.StartRestoreRequest{Snapshot: "daily", TargetDataset: "tank/restored"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected restores to need the admin role, got %v", err)
+	}
+
+	stream, err := server.client.WatchRestoreJob(as(config.RoleRequester), &zfsrabbitv1.WatchRestoreJobRequest{JobId: "restore_1"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected restore requesters not to watch jobs, got %v", err)
+	}
+
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:secret"))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", basic)
+	if _, err := server.client.ListRestoreJobs(ctx, &zfsrabbitv1.ListRestoreJobsRequest{}); err != nil {
+		t.Errorf("Expected basic auth to be accepted, got %v", err)
+	}
+}
+
+func TestStatusAndSnapshots(t *testing.T) {
+	server := startTestServer(t, &fakeZFS{outputs: map[string]string{
+		"zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/data": testSnapshots,
+	}})
+
+	report, err := server.client.GetStatus(as(config.RoleViewer), &zfsrabbitv1.GetStatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Healthy || len(report.Pools) != 0 || report.PendingSends != 0 {
+		t.Errorf("Expected a healthy status with no checks run yet, got %v", report)
+	}
+
+	resp, err := server.client.ListSnapshots(as(config.RoleViewer), &zfsrabbitv1.ListSnapshotsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots, got %v", resp.Snapshots)
+	}
+	first := resp.Snapshots[0]
+	if first.Name != "daily" || first.Dataset != "tank/data" || first.Used != "1M" || first.Refer != "2M" {
+		t.Errorf("Unexpected snapshot %v", first)
+	}
+	if first.Created.AsTime().Year() != 2024 {
+		t.Errorf("Expected the creation time to be set, got %v", first.Created)
+	}
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	release := make(chan struct{})
+	server := startTestServer(t, &fakeZFS{
+		block:   map[string]chan struct{}{"zfs snapshot": release},
+		started: make(chan string, 1),
+	})
+
+	resp, err := server.client.CreateSnapshot(as(config.RoleOperator), &zfsrabbitv1.CreateSnapshotRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "Snapshot triggered" {
+		t.Errorf("Unexpected response %q", resp.Message)
+	}
+
+	select {
+	case line := <-server.zfs.started:
+		if !strings.HasPrefix(line, "zfs snapshot tank/data@autosnap_") {
+			t.Errorf("Unexpected snapshot command %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the snapshot to be taken")
+	}
+
+	_, err = server.client.CreateSnapshot(as(config.RoleOperator), &zfsrabbitv1.CreateSnapshotRequest{})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("Expected a snapshot while one runs to be aborted, got %v", err)
+	}
+
+	// Let the snapshot fail and wait for the run to be recorded
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.scheduler.History(time.Time{}, time.Now().Add(time.Hour))) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the snapshot run to finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// divergedTarget answers zfs diff with a change, so restores to
+// tank/restored wait for confirmation
+type divergedTarget struct {
+	fakeZFS
+}
+
+func (d *divergedTarget) Output(cmd *exec.Cmd) ([]byte, error) {
+	return []byte("M\t/tank/restored/file\n"), nil
+}
+
+func TestRestoreWatch(t *testing.T) {
+	restore.SetCommandRunner(&divergedTarget{})
+	defer restore.SetCommandRunner(utils.DefaultRunner)
+
+	server := startTestServer(t, &fakeZFS{outputs: map[string]string{
+		"zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/data": testSnapshots,
+	}})
+	ctx, cancel := context.WithTimeout(as(config.RoleAdmin), 10*time.Second)
+	defer cancel()
+
+	job, err := server.client.StartRestore(ctx, &zfsrabbitv1.StartRestoreRequest{Snapshot: "daily", TargetDataset: "tank/restored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Id == "" || job.SnapshotName != "daily" || job.TargetDataset != "tank/restored" {
+		t.Fatalf("Unexpected job %v", job)
+	}
+
+	stream, err := server.client.WatchRestoreJob(ctx, &zfsrabbitv1.WatchRestoreJobRequest{JobId: job.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var statuses []string
+	confirmed := false
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Watch failed after %v: %v", statuses, err)
+		}
+		statuses = append(statuses, update.Status)
+
+		if update.Status == "awaiting_confirmation" && !confirmed {
+			if !update.RequiresConfirm || update.SafetyWarning == "" {
+				t.Errorf("Expected a safety warning while awaiting confirmation, got %v", update)
+			}
+			jobs, err := server.client.ListRestoreJobs(ctx, &zfsrabbitv1.ListRestoreJobsRequest{})
+			if err != nil || len(jobs.Jobs) != 1 || jobs.Jobs[0].Id != job.Id {
+				t.Errorf("Expected the job to be listed, got %v, %v", jobs, err)
+			}
+			if _, err := server.client.ConfirmRestore(ctx, &zfsrabbitv1.ConfirmRestoreRequest{JobId: job.Id}); err != nil {
+				t.Fatalf("ConfirmRestore failed: %v", err)
+			}
+			confirmed = true
+		}
+	}
+
+	if !confirmed {
+		t.Fatalf("Expected the job to wait for confirmation, got %v", statuses)
+	}
+	// There's no backup server to list the snapshot on once confirmed
+	last, err := server.client.ListRestoreJobs(ctx, &zfsrabbitv1.ListRestoreJobsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses[len(statuses)-1]; got != "failed" || last.Jobs[0].Status != "failed" || last.Jobs[0].Error == "" {
+		t.Errorf("Expected the watch to end when the job failed, got %v and %v", statuses, last.Jobs[0])
+	}
+	if last.Jobs[0].EndTime == nil {
+		t.Error("Expected the finished job to have an end time")
+	}
+
+	_, err = server.client.ConfirmRestore(ctx, &zfsrabbitv1.ConfirmRestoreRequest{JobId: "restore_missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected confirming an unknown job to be not found, got %v", err)
+	}
+	stream, err = server.client.WatchRestoreJob(ctx, &zfsrabbitv1.WatchRestoreJobRequest{JobId: "restore_missing"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected watching an unknown job to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+package grpcapi
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/grpcapi/zfsrabbitv1"
+	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/restore"
+	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/zfs"
+)
+
+// watchPollInterval is how often WatchRestoreJob checks a job between
+// events, which a slow watcher can miss
+const watchPollInterval = 2 * time.Second
+
+// GetStatus reports pool, disk and check health from the monitor's latest
+// results, like /api/status
+func (s *Server) GetStatus(ctx context.Context, req *zfsrabbitv1.GetStatusRequest) (*zfsrabbitv1.GetStatusResponse, error) {
+	systemStatus := s.monitor.GetSystemStatus()
+	resp := &zfsrabbitv1.GetStatusResponse{
+		Healthy:      true,
+		PendingSends: int32(len(s.scheduler.GetPendingSends())),
+	}
+
+	pools, _ := systemStatus["pools"].(map[string]interface{})
+	for _, name := range sortedKeys(pools) {
+		pool, ok := pools[name].(*zfs.PoolStatus)
+		if !ok {
+			continue
+		}
+		if pool.State != "ONLINE" || len(pool.Errors) > 0 {
+			resp.Healthy = false
+		}
+		resp.Pools = append(resp.Pools, &zfsrabbitv1.PoolStatus{
+			Pool:   pool.Pool,
+			State:  pool.State,
+			Status: pool.Status,
+			Errors: strings.Join(pool.Errors, "\n"),
+		})
+	}
+
+	disks, _ := systemStatus["disks"].(map[string]interface{})
+	for _, id := range sortedKeys(disks) {
+		disk, ok := disks[id].(*monitor.SMARTData)
+		if !ok {
+			continue
+		}
+		resp.Disks = append(resp.Disks, &zfsrabbitv1.DiskStatus{
+			Id:          disk.ID,
+			Device:      disk.Device,
+			Model:       disk.Model,
+			Healthy:     disk.Healthy,
+			Temperature: int32(disk.Temperature),
+		})
+	}
+
+	checks, _ := systemStatus["checks"].([]monitor.CheckStatus)
+	for _, check := range checks {
+		resp.Checks = append(resp.Checks, &zfsrabbitv1.CheckStatus{
+			Name:        check.Name,
+			Kind:        check.Kind,
+			Resource:    check.Resource,
+			LastSuccess: timestamp(check.LastSuccess),
+			LastError:   check.LastError,
+		})
+	}
+
+	return resp, nil
+}
+
+// ListSnapshots lists the local snapshots, oldest first, like /api/snapshots
+func (s *Server) ListSnapshots(ctx context.Context, req *zfsrabbitv1.ListSnapshotsRequest) (*zfsrabbitv1.ListSnapshotsResponse, error) {
+	snapshots, err := s.zfsManager.ListSnapshots()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &zfsrabbitv1.ListSnapshotsResponse{}
+	for _, snap := range snapshots {
+		resp.Snapshots = append(resp.Snapshots, &zfsrabbitv1.Snapshot{
+			Name:    snap.Name,
+			Dataset: snap.Dataset,
+			Created: timestamp(snap.Created),
+			Used:    snap.Used,
+			Refer:   snap.Refer,
+		})
+	}
+	return resp, nil
+}
+
+// CreateSnapshot snapshots and sends the zfs dataset in the background,
+// like POST /api/trigger/snapshot
+func (s *Server) CreateSnapshot(ctx context.Context, req *zfsrabbitv1.CreateSnapshotRequest) (*zfsrabbitv1.CreateSnapshotResponse, error) {
+	if err := s.scheduler.TriggerJob(""); err != nil {
+		if err.Error() == "snapshot operation already in progress" {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &zfsrabbitv1.CreateSnapshotResponse{Message: "Snapshot triggered"}, nil
+}
+
+// StartRestore starts restoring a snapshot from the backup server, like
+// POST /api/restore. An empty source dataset uses the default remote dataset.
+func (s *Server) StartRestore(ctx context.Context, req *zfsrabbitv1.StartRestoreRequest) (*zfsrabbitv1.RestoreJob, error) {
+	if req.Snapshot == "" || req.TargetDataset == "" {
+		return nil, status.Error(codes.InvalidArgument, "snapshot and target_dataset are required")
+	}
+	if err := validation.ValidateSnapshotName(req.Snapshot); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	for _, dataset := range []string{req.SourceDataset, req.TargetDataset} {
+		if dataset == "" {
+			continue
+		}
+		if err := validation.ValidateDatasetName(dataset); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	job, err := s.restoreManager.StartRestoreWithOptions(req.SourceDataset, req.Snapshot, req.TargetDataset, restore.MountOptions{})
+	if err != nil {
+		if err.Error() == "restore operation already in progress" {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return restoreJobMessage(job), nil
+}
+
+// ConfirmRestore lets a restore waiting for confirmation overwrite the
+// target's changes since its last snapshot, like POST /api/restore/confirm/{id}
+func (s *Server) ConfirmRestore(ctx context.Context, req *zfsrabbitv1.ConfirmRestoreRequest) (*zfsrabbitv1.RestoreJob, error) {
+	if req.JobId == "" {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	if _, found := s.restoreManager.GetJob(req.JobId); !found {
+		return nil, status.Errorf(codes.NotFound, "restore job %s not found", req.JobId)
+	}
+
+	if err := s.restoreManager.ConfirmDestructiveRestore(req.JobId); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to confirm restore: %v", err)
+	}
+
+	job, found := s.restoreManager.GetJob(req.JobId)
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "restore job %s not found", req.JobId)
+	}
+	return restoreJobMessage(job), nil
+}
+
+// ListRestoreJobs lists the restore jobs still tracked, oldest first, like
+// /api/restore/jobs
+func (s *Server) ListRestoreJobs(ctx context.Context, req *zfsrabbitv1.ListRestoreJobsRequest) (*zfsrabbitv1.ListRestoreJobsResponse, error) {
+	jobs := s.restoreManager.ListJobs()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartTime.Before(jobs[j].StartTime)
+	})
+
+	resp := &zfsrabbitv1.ListRestoreJobsResponse{}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, restoreJobMessage(job))
+	}
+	return resp, nil
+}
+
+// WatchRestoreJob sends the job's current state, then each change to it,
+// ending once the job completes, fails, is cancelled or is interrupted
+func (s *Server) WatchRestoreJob(req *zfsrabbitv1.WatchRestoreJobRequest, stream grpc.ServerStreamingServer[zfsrabbitv1.RestoreJob]) error {
+	if req.JobId == "" {
+		return status.Error(codes.InvalidArgument, "job_id is required")
+	}
+
+	var changes <-chan events.Event
+	if s.events != nil {
+		updates, unsubscribe := s.events.Subscribe()
+		defer unsubscribe()
+		changes = updates
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	var last *zfsrabbitv1.RestoreJob
+	for {
+		job, found := s.restoreManager.GetJob(req.JobId)
+		if !found {
+			if last == nil {
+				return status.Errorf(codes.NotFound, "restore job %s not found", req.JobId)
+			}
+			// Finished jobs are forgotten after an hour
+			return nil
+		}
+
+		current := restoreJobMessage(job)
+		if last == nil || !proto.Equal(current, last) {
+			if err := stream.Send(current); err != nil {
+				return err
+			}
+			last = current
+		}
+		if job.Finished() {
+			return nil
+		}
+
+		if err := s.waitForChange(stream.Context(), changes, ticker.C, req.JobId); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForChange blocks until the job publishes a change or the poll
+// interval passes, returning an error if the call or server ends first
+func (s *Server) waitForChange(ctx context.Context, changes <-chan events.Event, poll <-chan time.Time, jobID string) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.closing:
+			return status.Error(codes.Unavailable, "zfsrabbit is shutting down")
+		case <-poll:
+			return nil
+		case event := <-changes:
+			if change, ok := event.Data.(events.RestoreChange); ok && change.ID == jobID {
+				return nil
+			}
+		}
+	}
+}
+
+// restoreJobMessage converts a copy of a restore job for the API
+func restoreJobMessage(job *restore.RestoreJob) *zfsrabbitv1.RestoreJob {
+	msg := &zfsrabbitv1.RestoreJob{
+		Id:               job.ID,
+		SnapshotName:     job.SnapshotName,
+		SourceDataset:    job.SourceDataset,
+		TargetDataset:    job.TargetDataset,
+		Status:           job.Status,
+		Progress:         int32(job.Progress),
+		BytesTransferred: job.BytesTransferred,
+		TotalBytes:       job.TotalBytes,
+		TransferRate:     job.TransferRate,
+		Eta:              job.ETA,
+		StartTime:        timestamp(job.StartTime),
+		RequiresConfirm:  job.RequiresConfirm,
+		SafetyWarning:    job.SafetyWarning,
+	}
+	if job.EndTime != nil {
+		msg.EndTime = timestamp(*job.EndTime)
+	}
+	if job.Error != nil {
+		msg.Error = job.Error.Error()
+	}
+	return msg
+}
+
+// timestamp converts t, leaving the zero time unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
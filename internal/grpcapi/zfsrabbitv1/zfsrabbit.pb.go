@@ -0,0 +1,1149 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: zfsrabbit/v1/zfsrabbit.proto
+
+// ZFSRabbit machine API. Mirrors the REST endpoints under /api/ with typed
+// messages and server-streamed job progress for provisioning integrations.
+
+package zfsrabbitv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{0}
+}
+
+type PoolStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pool          string                 `protobuf:"bytes,1,opt,name=pool,proto3" json:"pool,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Errors        string                 `protobuf:"bytes,4,opt,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PoolStatus) Reset() {
+	*x = PoolStatus{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PoolStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PoolStatus) ProtoMessage() {}
+
+func (x *PoolStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PoolStatus.ProtoReflect.Descriptor instead.
+func (*PoolStatus) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PoolStatus) GetPool() string {
+	if x != nil {
+		return x.Pool
+	}
+	return ""
+}
+
+func (x *PoolStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *PoolStatus) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *PoolStatus) GetErrors() string {
+	if x != nil {
+		return x.Errors
+	}
+	return ""
+}
+
+type DiskStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Device        string                 `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	Healthy       bool                   `protobuf:"varint,4,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Temperature   int32                  `protobuf:"varint,5,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiskStatus) Reset() {
+	*x = DiskStatus{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiskStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiskStatus) ProtoMessage() {}
+
+func (x *DiskStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiskStatus.ProtoReflect.Descriptor instead.
+func (*DiskStatus) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DiskStatus) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DiskStatus) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *DiskStatus) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *DiskStatus) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *DiskStatus) GetTemperature() int32 {
+	if x != nil {
+		return x.Temperature
+	}
+	return 0
+}
+
+type CheckStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Resource      string                 `protobuf:"bytes,3,opt,name=resource,proto3" json:"resource,omitempty"`
+	LastSuccess   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_success,json=lastSuccess,proto3" json:"last_success,omitempty"`
+	LastError     string                 `protobuf:"bytes,5,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckStatus) Reset() {
+	*x = CheckStatus{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckStatus) ProtoMessage() {}
+
+func (x *CheckStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckStatus.ProtoReflect.Descriptor instead.
+func (*CheckStatus) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CheckStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CheckStatus) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *CheckStatus) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *CheckStatus) GetLastSuccess() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSuccess
+	}
+	return nil
+}
+
+func (x *CheckStatus) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+type GetStatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Healthy       bool                   `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Pools         []*PoolStatus          `protobuf:"bytes,2,rep,name=pools,proto3" json:"pools,omitempty"`
+	Disks         []*DiskStatus          `protobuf:"bytes,3,rep,name=disks,proto3" json:"disks,omitempty"`
+	Checks        []*CheckStatus         `protobuf:"bytes,4,rep,name=checks,proto3" json:"checks,omitempty"`
+	PendingSends  int32                  `protobuf:"varint,5,opt,name=pending_sends,json=pendingSends,proto3" json:"pending_sends,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusResponse) Reset() {
+	*x = GetStatusResponse{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusResponse) ProtoMessage() {}
+
+func (x *GetStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetStatusResponse) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetStatusResponse) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *GetStatusResponse) GetPools() []*PoolStatus {
+	if x != nil {
+		return x.Pools
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetDisks() []*DiskStatus {
+	if x != nil {
+		return x.Disks
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetChecks() []*CheckStatus {
+	if x != nil {
+		return x.Checks
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetPendingSends() int32 {
+	if x != nil {
+		return x.PendingSends
+	}
+	return 0
+}
+
+type Snapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Dataset       string                 `protobuf:"bytes,2,opt,name=dataset,proto3" json:"dataset,omitempty"`
+	Created       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created,proto3" json:"created,omitempty"`
+	Used          string                 `protobuf:"bytes,4,opt,name=used,proto3" json:"used,omitempty"`
+	Refer         string                 `protobuf:"bytes,5,opt,name=refer,proto3" json:"refer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Snapshot) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Snapshot) GetDataset() string {
+	if x != nil {
+		return x.Dataset
+	}
+	return ""
+}
+
+func (x *Snapshot) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *Snapshot) GetUsed() string {
+	if x != nil {
+		return x.Used
+	}
+	return ""
+}
+
+func (x *Snapshot) GetRefer() string {
+	if x != nil {
+		return x.Refer
+	}
+	return ""
+}
+
+type ListSnapshotsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSnapshotsRequest) Reset() {
+	*x = ListSnapshotsRequest{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSnapshotsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSnapshotsRequest) ProtoMessage() {}
+
+func (x *ListSnapshotsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSnapshotsRequest.ProtoReflect.Descriptor instead.
+func (*ListSnapshotsRequest) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{6}
+}
+
+type ListSnapshotsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Snapshots     []*Snapshot            `protobuf:"bytes,1,rep,name=snapshots,proto3" json:"snapshots,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSnapshotsResponse) Reset() {
+	*x = ListSnapshotsResponse{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSnapshotsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSnapshotsResponse) ProtoMessage() {}
+
+func (x *ListSnapshotsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSnapshotsResponse.ProtoReflect.Descriptor instead.
+func (*ListSnapshotsResponse) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListSnapshotsResponse) GetSnapshots() []*Snapshot {
+	if x != nil {
+		return x.Snapshots
+	}
+	return nil
+}
+
+type CreateSnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSnapshotRequest) Reset() {
+	*x = CreateSnapshotRequest{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSnapshotRequest) ProtoMessage() {}
+
+func (x *CreateSnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSnapshotRequest.ProtoReflect.Descriptor instead.
+func (*CreateSnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{8}
+}
+
+type CreateSnapshotResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSnapshotResponse) Reset() {
+	*x = CreateSnapshotResponse{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSnapshotResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSnapshotResponse) ProtoMessage() {}
+
+func (x *CreateSnapshotResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSnapshotResponse.ProtoReflect.Descriptor instead.
+func (*CreateSnapshotResponse) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CreateSnapshotResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type StartRestoreRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Snapshot      string                 `protobuf:"bytes,1,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	SourceDataset string                 `protobuf:"bytes,2,opt,name=source_dataset,json=sourceDataset,proto3" json:"source_dataset,omitempty"`
+	TargetDataset string                 `protobuf:"bytes,3,opt,name=target_dataset,json=targetDataset,proto3" json:"target_dataset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartRestoreRequest) Reset() {
+	*x = StartRestoreRequest{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartRestoreRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartRestoreRequest) ProtoMessage() {}
+
+func (x *StartRestoreRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartRestoreRequest.ProtoReflect.Descriptor instead.
+func (*StartRestoreRequest) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StartRestoreRequest) GetSnapshot() string {
+	if x != nil {
+		return x.Snapshot
+	}
+	return ""
+}
+
+func (x *StartRestoreRequest) GetSourceDataset() string {
+	if x != nil {
+		return x.SourceDataset
+	}
+	return ""
+}
+
+func (x *StartRestoreRequest) GetTargetDataset() string {
+	if x != nil {
+		return x.TargetDataset
+	}
+	return ""
+}
+
+type ConfirmRestoreRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfirmRestoreRequest) Reset() {
+	*x = ConfirmRestoreRequest{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfirmRestoreRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfirmRestoreRequest) ProtoMessage() {}
+
+func (x *ConfirmRestoreRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfirmRestoreRequest.ProtoReflect.Descriptor instead.
+func (*ConfirmRestoreRequest) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ConfirmRestoreRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type RestoreJob struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SnapshotName     string                 `protobuf:"bytes,2,opt,name=snapshot_name,json=snapshotName,proto3" json:"snapshot_name,omitempty"`
+	SourceDataset    string                 `protobuf:"bytes,3,opt,name=source_dataset,json=sourceDataset,proto3" json:"source_dataset,omitempty"`
+	TargetDataset    string                 `protobuf:"bytes,4,opt,name=target_dataset,json=targetDataset,proto3" json:"target_dataset,omitempty"`
+	Status           string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Progress         int32                  `protobuf:"varint,6,opt,name=progress,proto3" json:"progress,omitempty"`
+	BytesTransferred int64                  `protobuf:"varint,7,opt,name=bytes_transferred,json=bytesTransferred,proto3" json:"bytes_transferred,omitempty"`
+	TotalBytes       int64                  `protobuf:"varint,8,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	TransferRate     float64                `protobuf:"fixed64,9,opt,name=transfer_rate,json=transferRate,proto3" json:"transfer_rate,omitempty"`
+	Eta              string                 `protobuf:"bytes,10,opt,name=eta,proto3" json:"eta,omitempty"`
+	StartTime        *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime          *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Error            string                 `protobuf:"bytes,13,opt,name=error,proto3" json:"error,omitempty"`
+	RequiresConfirm  bool                   `protobuf:"varint,14,opt,name=requires_confirm,json=requiresConfirm,proto3" json:"requires_confirm,omitempty"`
+	SafetyWarning    string                 `protobuf:"bytes,15,opt,name=safety_warning,json=safetyWarning,proto3" json:"safety_warning,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *RestoreJob) Reset() {
+	*x = RestoreJob{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestoreJob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestoreJob) ProtoMessage() {}
+
+func (x *RestoreJob) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestoreJob.ProtoReflect.Descriptor instead.
+func (*RestoreJob) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RestoreJob) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RestoreJob) GetSnapshotName() string {
+	if x != nil {
+		return x.SnapshotName
+	}
+	return ""
+}
+
+func (x *RestoreJob) GetSourceDataset() string {
+	if x != nil {
+		return x.SourceDataset
+	}
+	return ""
+}
+
+func (x *RestoreJob) GetTargetDataset() string {
+	if x != nil {
+		return x.TargetDataset
+	}
+	return ""
+}
+
+func (x *RestoreJob) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *RestoreJob) GetProgress() int32 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *RestoreJob) GetBytesTransferred() int64 {
+	if x != nil {
+		return x.BytesTransferred
+	}
+	return 0
+}
+
+func (x *RestoreJob) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *RestoreJob) GetTransferRate() float64 {
+	if x != nil {
+		return x.TransferRate
+	}
+	return 0
+}
+
+func (x *RestoreJob) GetEta() string {
+	if x != nil {
+		return x.Eta
+	}
+	return ""
+}
+
+func (x *RestoreJob) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *RestoreJob) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *RestoreJob) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *RestoreJob) GetRequiresConfirm() bool {
+	if x != nil {
+		return x.RequiresConfirm
+	}
+	return false
+}
+
+func (x *RestoreJob) GetSafetyWarning() string {
+	if x != nil {
+		return x.SafetyWarning
+	}
+	return ""
+}
+
+type ListRestoreJobsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRestoreJobsRequest) Reset() {
+	*x = ListRestoreJobsRequest{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRestoreJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRestoreJobsRequest) ProtoMessage() {}
+
+func (x *ListRestoreJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRestoreJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListRestoreJobsRequest) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{13}
+}
+
+type ListRestoreJobsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*RestoreJob          `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRestoreJobsResponse) Reset() {
+	*x = ListRestoreJobsResponse{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRestoreJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRestoreJobsResponse) ProtoMessage() {}
+
+func (x *ListRestoreJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRestoreJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListRestoreJobsResponse) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ListRestoreJobsResponse) GetJobs() []*RestoreJob {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type WatchRestoreJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRestoreJobRequest) Reset() {
+	*x = WatchRestoreJobRequest{}
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRestoreJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRestoreJobRequest) ProtoMessage() {}
+
+func (x *WatchRestoreJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zfsrabbit_v1_zfsrabbit_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRestoreJobRequest.ProtoReflect.Descriptor instead.
+func (*WatchRestoreJobRequest) Descriptor() ([]byte, []int) {
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *WatchRestoreJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+var File_zfsrabbit_v1_zfsrabbit_proto protoreflect.FileDescriptor
+
+const file_zfsrabbit_v1_zfsrabbit_proto_rawDesc = "" +
+	"\n" +
+	"\x1czfsrabbit/v1/zfsrabbit.proto\x12\fzfsrabbit.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x12\n" +
+	"\x10GetStatusRequest\"f\n" +
+	"\n" +
+	"PoolStatus\x12\x12\n" +
+	"\x04pool\x18\x01 \x01(\tR\x04pool\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x16\n" +
+	"\x06errors\x18\x04 \x01(\tR\x06errors\"\x86\x01\n" +
+	"\n" +
+	"DiskStatus\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06device\x18\x02 \x01(\tR\x06device\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12\x18\n" +
+	"\ahealthy\x18\x04 \x01(\bR\ahealthy\x12 \n" +
+	"\vtemperature\x18\x05 \x01(\x05R\vtemperature\"\xaf\x01\n" +
+	"\vCheckStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x1a\n" +
+	"\bresource\x18\x03 \x01(\tR\bresource\x12=\n" +
+	"\flast_success\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\vlastSuccess\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x05 \x01(\tR\tlastError\"\xe5\x01\n" +
+	"\x11GetStatusResponse\x12\x18\n" +
+	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12.\n" +
+	"\x05pools\x18\x02 \x03(\v2\x18.zfsrabbit.v1.PoolStatusR\x05pools\x12.\n" +
+	"\x05disks\x18\x03 \x03(\v2\x18.zfsrabbit.v1.DiskStatusR\x05disks\x121\n" +
+	"\x06checks\x18\x04 \x03(\v2\x19.zfsrabbit.v1.CheckStatusR\x06checks\x12#\n" +
+	"\rpending_sends\x18\x05 \x01(\x05R\fpendingSends\"\x98\x01\n" +
+	"\bSnapshot\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\adataset\x18\x02 \x01(\tR\adataset\x124\n" +
+	"\acreated\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x12\x12\n" +
+	"\x04used\x18\x04 \x01(\tR\x04used\x12\x14\n" +
+	"\x05refer\x18\x05 \x01(\tR\x05refer\"\x16\n" +
+	"\x14ListSnapshotsRequest\"M\n" +
+	"\x15ListSnapshotsResponse\x124\n" +
+	"\tsnapshots\x18\x01 \x03(\v2\x16.zfsrabbit.v1.SnapshotR\tsnapshots\"\x17\n" +
+	"\x15CreateSnapshotRequest\"2\n" +
+	"\x16CreateSnapshotResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"\x7f\n" +
+	"\x13StartRestoreRequest\x12\x1a\n" +
+	"\bsnapshot\x18\x01 \x01(\tR\bsnapshot\x12%\n" +
+	"\x0esource_dataset\x18\x02 \x01(\tR\rsourceDataset\x12%\n" +
+	"\x0etarget_dataset\x18\x03 \x01(\tR\rtargetDataset\".\n" +
+	"\x15ConfirmRestoreRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\xa2\x04\n" +
+	"\n" +
+	"RestoreJob\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12#\n" +
+	"\rsnapshot_name\x18\x02 \x01(\tR\fsnapshotName\x12%\n" +
+	"\x0esource_dataset\x18\x03 \x01(\tR\rsourceDataset\x12%\n" +
+	"\x0etarget_dataset\x18\x04 \x01(\tR\rtargetDataset\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x1a\n" +
+	"\bprogress\x18\x06 \x01(\x05R\bprogress\x12+\n" +
+	"\x11bytes_transferred\x18\a \x01(\x03R\x10bytesTransferred\x12\x1f\n" +
+	"\vtotal_bytes\x18\b \x01(\x03R\n" +
+	"totalBytes\x12#\n" +
+	"\rtransfer_rate\x18\t \x01(\x01R\ftransferRate\x12\x10\n" +
+	"\x03eta\x18\n" +
+	" \x01(\tR\x03eta\x129\n" +
+	"\n" +
+	"start_time\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x12\x14\n" +
+	"\x05error\x18\r \x01(\tR\x05error\x12)\n" +
+	"\x10requires_confirm\x18\x0e \x01(\bR\x0frequiresConfirm\x12%\n" +
+	"\x0esafety_warning\x18\x0f \x01(\tR\rsafetyWarning\"\x18\n" +
+	"\x16ListRestoreJobsRequest\"G\n" +
+	"\x17ListRestoreJobsResponse\x12,\n" +
+	"\x04jobs\x18\x01 \x03(\v2\x18.zfsrabbit.v1.RestoreJobR\x04jobs\"/\n" +
+	"\x16WatchRestoreJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId2\xe3\x04\n" +
+	"\tZFSRabbit\x12L\n" +
+	"\tGetStatus\x12\x1e.zfsrabbit.v1.GetStatusRequest\x1a\x1f.zfsrabbit.v1.GetStatusResponse\x12X\n" +
+	"\rListSnapshots\x12\".zfsrabbit.v1.ListSnapshotsRequest\x1a#.zfsrabbit.v1.ListSnapshotsResponse\x12[\n" +
+	"\x0eCreateSnapshot\x12#.zfsrabbit.v1.CreateSnapshotRequest\x1a$.zfsrabbit.v1.CreateSnapshotResponse\x12K\n" +
+	"\fStartRestore\x12!.zfsrabbit.v1.StartRestoreRequest\x1a\x18.zfsrabbit.v1.RestoreJob\x12O\n" +
+	"\x0eConfirmRestore\x12#.zfsrabbit.v1.ConfirmRestoreRequest\x1a\x18.zfsrabbit.v1.RestoreJob\x12^\n" +
+	"\x0fListRestoreJobs\x12$.zfsrabbit.v1.ListRestoreJobsRequest\x1a%.zfsrabbit.v1.ListRestoreJobsResponse\x12S\n" +
+	"\x0fWatchRestoreJob\x12$.zfsrabbit.v1.WatchRestoreJobRequest\x1a\x18.zfsrabbit.v1.RestoreJob0\x01B(Z&zfsrabbit/internal/grpcapi/zfsrabbitv1b\x06proto3"
+
+var (
+	file_zfsrabbit_v1_zfsrabbit_proto_rawDescOnce sync.Once
+	file_zfsrabbit_v1_zfsrabbit_proto_rawDescData []byte
+)
+
+func file_zfsrabbit_v1_zfsrabbit_proto_rawDescGZIP() []byte {
+	file_zfsrabbit_v1_zfsrabbit_proto_rawDescOnce.Do(func() {
+		file_zfsrabbit_v1_zfsrabbit_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_zfsrabbit_v1_zfsrabbit_proto_rawDesc), len(file_zfsrabbit_v1_zfsrabbit_proto_rawDesc)))
+	})
+	return file_zfsrabbit_v1_zfsrabbit_proto_rawDescData
+}
+
+var file_zfsrabbit_v1_zfsrabbit_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_zfsrabbit_v1_zfsrabbit_proto_goTypes = []any{
+	(*GetStatusRequest)(nil),        // 0: zfsrabbit.v1.GetStatusRequest
+	(*PoolStatus)(nil),              // 1: zfsrabbit.v1.PoolStatus
+	(*DiskStatus)(nil),              // 2: zfsrabbit.v1.DiskStatus
+	(*CheckStatus)(nil),             // 3: zfsrabbit.v1.CheckStatus
+	(*GetStatusResponse)(nil),       // 4: zfsrabbit.v1.GetStatusResponse
+	(*Snapshot)(nil),                // 5: zfsrabbit.v1.Snapshot
+	(*ListSnapshotsRequest)(nil),    // 6: zfsrabbit.v1.ListSnapshotsRequest
+	(*ListSnapshotsResponse)(nil),   // 7: zfsrabbit.v1.ListSnapshotsResponse
+	(*CreateSnapshotRequest)(nil),   // 8: zfsrabbit.v1.CreateSnapshotRequest
+	(*CreateSnapshotResponse)(nil),  // 9: zfsrabbit.v1.CreateSnapshotResponse
+	(*StartRestoreRequest)(nil),     // 10: zfsrabbit.v1.StartRestoreRequest
+	(*ConfirmRestoreRequest)(nil),   // 11: zfsrabbit.v1.ConfirmRestoreRequest
+	(*RestoreJob)(nil),              // 12: zfsrabbit.v1.RestoreJob
+	(*ListRestoreJobsRequest)(nil),  // 13: zfsrabbit.v1.ListRestoreJobsRequest
+	(*ListRestoreJobsResponse)(nil), // 14: zfsrabbit.v1.ListRestoreJobsResponse
+	(*WatchRestoreJobRequest)(nil),  // 15: zfsrabbit.v1.WatchRestoreJobRequest
+	(*timestamppb.Timestamp)(nil),   // 16: google.protobuf.Timestamp
+}
+var file_zfsrabbit_v1_zfsrabbit_proto_depIdxs = []int32{
+	16, // 0: zfsrabbit.v1.CheckStatus.last_success:type_name -> google.protobuf.Timestamp
+	1,  // 1: zfsrabbit.v1.GetStatusResponse.pools:type_name -> zfsrabbit.v1.PoolStatus
+	2,  // 2: zfsrabbit.v1.GetStatusResponse.disks:type_name -> zfsrabbit.v1.DiskStatus
+	3,  // 3: zfsrabbit.v1.GetStatusResponse.checks:type_name -> zfsrabbit.v1.CheckStatus
+	16, // 4: zfsrabbit.v1.Snapshot.created:type_name -> google.protobuf.Timestamp
+	5,  // 5: zfsrabbit.v1.ListSnapshotsResponse.snapshots:type_name -> zfsrabbit.v1.Snapshot
+	16, // 6: zfsrabbit.v1.RestoreJob.start_time:type_name -> google.protobuf.Timestamp
+	16, // 7: zfsrabbit.v1.RestoreJob.end_time:type_name -> google.protobuf.Timestamp
+	12, // 8: zfsrabbit.v1.ListRestoreJobsResponse.jobs:type_name -> zfsrabbit.v1.RestoreJob
+	0,  // 9: zfsrabbit.v1.ZFSRabbit.GetStatus:input_type -> zfsrabbit.v1.GetStatusRequest
+	6,  // 10: zfsrabbit.v1.ZFSRabbit.ListSnapshots:input_type -> zfsrabbit.v1.ListSnapshotsRequest
+	8,  // 11: zfsrabbit.v1.ZFSRabbit.CreateSnapshot:input_type -> zfsrabbit.v1.CreateSnapshotRequest
+	10, // 12: zfsrabbit.v1.ZFSRabbit.StartRestore:input_type -> zfsrabbit.v1.StartRestoreRequest
+	11, // 13: zfsrabbit.v1.ZFSRabbit.ConfirmRestore:input_type -> zfsrabbit.v1.ConfirmRestoreRequest
+	13, // 14: zfsrabbit.v1.ZFSRabbit.ListRestoreJobs:input_type -> zfsrabbit.v1.ListRestoreJobsRequest
+	15, // 15: zfsrabbit.v1.ZFSRabbit.WatchRestoreJob:input_type -> zfsrabbit.v1.WatchRestoreJobRequest
+	4,  // 16: zfsrabbit.v1.ZFSRabbit.GetStatus:output_type -> zfsrabbit.v1.GetStatusResponse
+	7,  // 17: zfsrabbit.v1.ZFSRabbit.ListSnapshots:output_type -> zfsrabbit.v1.ListSnapshotsResponse
+	9,  // 18: zfsrabbit.v1.ZFSRabbit.CreateSnapshot:output_type -> zfsrabbit.v1.CreateSnapshotResponse
+	12, // 19: zfsrabbit.v1.ZFSRabbit.StartRestore:output_type -> zfsrabbit.v1.RestoreJob
+	12, // 20: zfsrabbit.v1.ZFSRabbit.ConfirmRestore:output_type -> zfsrabbit.v1.RestoreJob
+	14, // 21: zfsrabbit.v1.ZFSRabbit.ListRestoreJobs:output_type -> zfsrabbit.v1.ListRestoreJobsResponse
+	12, // 22: zfsrabbit.v1.ZFSRabbit.WatchRestoreJob:output_type -> zfsrabbit.v1.RestoreJob
+	16, // [16:23] is the sub-list for method output_type
+	9,  // [9:16] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_zfsrabbit_v1_zfsrabbit_proto_init() }
+func file_zfsrabbit_v1_zfsrabbit_proto_init() {
+	if File_zfsrabbit_v1_zfsrabbit_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_zfsrabbit_v1_zfsrabbit_proto_rawDesc), len(file_zfsrabbit_v1_zfsrabbit_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_zfsrabbit_v1_zfsrabbit_proto_goTypes,
+		DependencyIndexes: file_zfsrabbit_v1_zfsrabbit_proto_depIdxs,
+		MessageInfos:      file_zfsrabbit_v1_zfsrabbit_proto_msgTypes,
+	}.Build()
+	File_zfsrabbit_v1_zfsrabbit_proto = out.File
+	file_zfsrabbit_v1_zfsrabbit_proto_goTypes = nil
+	file_zfsrabbit_v1_zfsrabbit_proto_depIdxs = nil
+}
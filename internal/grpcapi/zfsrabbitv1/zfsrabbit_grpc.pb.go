@@ -0,0 +1,370 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: zfsrabbit/v1/zfsrabbit.proto
+
+// ZFSRabbit machine API. Mirrors the REST endpoints under /api/ with typed
+// messages and server-streamed job progress for provisioning integrations.
+
+package zfsrabbitv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ZFSRabbit_GetStatus_FullMethodName       = "/zfsrabbit.v1.ZFSRabbit/GetStatus"
+	ZFSRabbit_ListSnapshots_FullMethodName   = "/zfsrabbit.v1.ZFSRabbit/ListSnapshots"
+	ZFSRabbit_CreateSnapshot_FullMethodName  = "/zfsrabbit.v1.ZFSRabbit/CreateSnapshot"
+	ZFSRabbit_StartRestore_FullMethodName    = "/zfsrabbit.v1.ZFSRabbit/StartRestore"
+	ZFSRabbit_ConfirmRestore_FullMethodName  = "/zfsrabbit.v1.ZFSRabbit/ConfirmRestore"
+	ZFSRabbit_ListRestoreJobs_FullMethodName = "/zfsrabbit.v1.ZFSRabbit/ListRestoreJobs"
+	ZFSRabbit_WatchRestoreJob_FullMethodName = "/zfsrabbit.v1.ZFSRabbit/WatchRestoreJob"
+)
+
+// ZFSRabbitClient is the client API for ZFSRabbit service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ZFSRabbitClient interface {
+	// GetStatus mirrors GET /api/status
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	// ListSnapshots mirrors GET /api/snapshots
+	ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error)
+	// CreateSnapshot mirrors POST /api/trigger/snapshot
+	CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*CreateSnapshotResponse, error)
+	// StartRestore mirrors POST /api/restore
+	StartRestore(ctx context.Context, in *StartRestoreRequest, opts ...grpc.CallOption) (*RestoreJob, error)
+	// ConfirmRestore mirrors POST /api/restore/confirm/{id}
+	ConfirmRestore(ctx context.Context, in *ConfirmRestoreRequest, opts ...grpc.CallOption) (*RestoreJob, error)
+	// ListRestoreJobs mirrors GET /api/restore/jobs
+	ListRestoreJobs(ctx context.Context, in *ListRestoreJobsRequest, opts ...grpc.CallOption) (*ListRestoreJobsResponse, error)
+	// WatchRestoreJob streams job updates until the job completes or fails
+	WatchRestoreJob(ctx context.Context, in *WatchRestoreJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RestoreJob], error)
+}
+
+type zFSRabbitClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewZFSRabbitClient(cc grpc.ClientConnInterface) ZFSRabbitClient {
+	return &zFSRabbitClient{cc}
+}
+
+func (c *zFSRabbitClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatusResponse)
+	err := c.cc.Invoke(ctx, ZFSRabbit_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSRabbitClient) ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSnapshotsResponse)
+	err := c.cc.Invoke(ctx, ZFSRabbit_ListSnapshots_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSRabbitClient) CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*CreateSnapshotResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateSnapshotResponse)
+	err := c.cc.Invoke(ctx, ZFSRabbit_CreateSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSRabbitClient) StartRestore(ctx context.Context, in *StartRestoreRequest, opts ...grpc.CallOption) (*RestoreJob, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreJob)
+	err := c.cc.Invoke(ctx, ZFSRabbit_StartRestore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSRabbitClient) ConfirmRestore(ctx context.Context, in *ConfirmRestoreRequest, opts ...grpc.CallOption) (*RestoreJob, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreJob)
+	err := c.cc.Invoke(ctx, ZFSRabbit_ConfirmRestore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSRabbitClient) ListRestoreJobs(ctx context.Context, in *ListRestoreJobsRequest, opts ...grpc.CallOption) (*ListRestoreJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRestoreJobsResponse)
+	err := c.cc.Invoke(ctx, ZFSRabbit_ListRestoreJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zFSRabbitClient) WatchRestoreJob(ctx context.Context, in *WatchRestoreJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RestoreJob], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ZFSRabbit_ServiceDesc.Streams[0], ZFSRabbit_WatchRestoreJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRestoreJobRequest, RestoreJob]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ZFSRabbit_WatchRestoreJobClient = grpc.ServerStreamingClient[RestoreJob]
+
+// ZFSRabbitServer is the server API for ZFSRabbit service.
+// All implementations must embed UnimplementedZFSRabbitServer
+// for forward compatibility.
+type ZFSRabbitServer interface {
+	// GetStatus mirrors GET /api/status
+	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	// ListSnapshots mirrors GET /api/snapshots
+	ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsResponse, error)
+	// CreateSnapshot mirrors POST /api/trigger/snapshot
+	CreateSnapshot(context.Context, *CreateSnapshotRequest) (*CreateSnapshotResponse, error)
+	// StartRestore mirrors POST /api/restore
+	StartRestore(context.Context, *StartRestoreRequest) (*RestoreJob, error)
+	// ConfirmRestore mirrors POST /api/restore/confirm/{id}
+	ConfirmRestore(context.Context, *ConfirmRestoreRequest) (*RestoreJob, error)
+	// ListRestoreJobs mirrors GET /api/restore/jobs
+	ListRestoreJobs(context.Context, *ListRestoreJobsRequest) (*ListRestoreJobsResponse, error)
+	// WatchRestoreJob streams job updates until the job completes or fails
+	WatchRestoreJob(*WatchRestoreJobRequest, grpc.ServerStreamingServer[RestoreJob]) error
+	mustEmbedUnimplementedZFSRabbitServer()
+}
+
+// UnimplementedZFSRabbitServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedZFSRabbitServer struct{}
+
+func (UnimplementedZFSRabbitServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedZFSRabbitServer) ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSnapshots not implemented")
+}
+func (UnimplementedZFSRabbitServer) CreateSnapshot(context.Context, *CreateSnapshotRequest) (*CreateSnapshotResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSnapshot not implemented")
+}
+func (UnimplementedZFSRabbitServer) StartRestore(context.Context, *StartRestoreRequest) (*RestoreJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRestore not implemented")
+}
+func (UnimplementedZFSRabbitServer) ConfirmRestore(context.Context, *ConfirmRestoreRequest) (*RestoreJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmRestore not implemented")
+}
+func (UnimplementedZFSRabbitServer) ListRestoreJobs(context.Context, *ListRestoreJobsRequest) (*ListRestoreJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRestoreJobs not implemented")
+}
+func (UnimplementedZFSRabbitServer) WatchRestoreJob(*WatchRestoreJobRequest, grpc.ServerStreamingServer[RestoreJob]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRestoreJob not implemented")
+}
+func (UnimplementedZFSRabbitServer) mustEmbedUnimplementedZFSRabbitServer() {}
+func (UnimplementedZFSRabbitServer) testEmbeddedByValue()                   {}
+
+// UnsafeZFSRabbitServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ZFSRabbitServer will
+// result in compilation errors.
+type UnsafeZFSRabbitServer interface {
+	mustEmbedUnimplementedZFSRabbitServer()
+}
+
+func RegisterZFSRabbitServer(s grpc.ServiceRegistrar, srv ZFSRabbitServer) {
+	// If the following call pancis, it indicates UnimplementedZFSRabbitServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ZFSRabbit_ServiceDesc, srv)
+}
+
+func _ZFSRabbit_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSRabbitServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZFSRabbit_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSRabbitServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFSRabbit_ListSnapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSnapshotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSRabbitServer).ListSnapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZFSRabbit_ListSnapshots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSRabbitServer).ListSnapshots(ctx, req.(*ListSnapshotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFSRabbit_CreateSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSRabbitServer).CreateSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZFSRabbit_CreateSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSRabbitServer).CreateSnapshot(ctx, req.(*CreateSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFSRabbit_StartRestore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSRabbitServer).StartRestore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZFSRabbit_StartRestore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSRabbitServer).StartRestore(ctx, req.(*StartRestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFSRabbit_ConfirmRestore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmRestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSRabbitServer).ConfirmRestore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZFSRabbit_ConfirmRestore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSRabbitServer).ConfirmRestore(ctx, req.(*ConfirmRestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFSRabbit_ListRestoreJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRestoreJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZFSRabbitServer).ListRestoreJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ZFSRabbit_ListRestoreJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZFSRabbitServer).ListRestoreJobs(ctx, req.(*ListRestoreJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZFSRabbit_WatchRestoreJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRestoreJobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ZFSRabbitServer).WatchRestoreJob(m, &grpc.GenericServerStream[WatchRestoreJobRequest, RestoreJob]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ZFSRabbit_WatchRestoreJobServer = grpc.ServerStreamingServer[RestoreJob]
+
+// ZFSRabbit_ServiceDesc is the grpc.ServiceDesc for ZFSRabbit service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ZFSRabbit_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zfsrabbit.v1.ZFSRabbit",
+	HandlerType: (*ZFSRabbitServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStatus",
+			Handler:    _ZFSRabbit_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListSnapshots",
+			Handler:    _ZFSRabbit_ListSnapshots_Handler,
+		},
+		{
+			MethodName: "CreateSnapshot",
+			Handler:    _ZFSRabbit_CreateSnapshot_Handler,
+		},
+		{
+			MethodName: "StartRestore",
+			Handler:    _ZFSRabbit_StartRestore_Handler,
+		},
+		{
+			MethodName: "ConfirmRestore",
+			Handler:    _ZFSRabbit_ConfirmRestore_Handler,
+		},
+		{
+			MethodName: "ListRestoreJobs",
+			Handler:    _ZFSRabbit_ListRestoreJobs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRestoreJob",
+			Handler:       _ZFSRabbit_WatchRestoreJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "zfsrabbit/v1/zfsrabbit.proto",
+}
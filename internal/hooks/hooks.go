@@ -0,0 +1,90 @@
+// Package hooks runs operator-supplied commands: drill checks, standby
+// probes, restore hooks and the commands run around snapshots.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+)
+
+const (
+	defaultTimeout = 10 * time.Minute
+	maxOutput      = 4096
+)
+
+// Result is the outcome of running one hook
+type Result struct {
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Run runs hook through runner with env added to its environment. A hook
+// that outlives its timeout (default 10 minutes) is killed along with its
+// children.
+func Run(runner utils.CommandRunner, hook config.DrillHook, env []string) Result {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := runner.CommandContext(ctx, hook.Command, hook.Args...)
+	cmd.Env = append(cmd.Environ(), env...)
+	// Kill the whole process group so children holding the output pipe don't outlive the timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := runner.Run(cmd)
+	result := Result{
+		Name:     hook.Name,
+		Duration: time.Since(start),
+		Output:   truncate(strings.TrimSpace(output.String()), maxOutput),
+	}
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.ExitCode = -1
+		result.Error = fmt.Sprintf("timed out after %s", timeout)
+	case err != nil:
+		result.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		}
+		result.Error = err.Error()
+	default:
+		result.Passed = true
+	}
+
+	return result
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "... (truncated)"
+}
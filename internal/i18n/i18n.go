@@ -0,0 +1,180 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is used when no locale is configured, and fills in any
+// message another locale's catalog leaves out
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+var localeRegex = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+var (
+	mutex    sync.RWMutex
+	locale   = DefaultLocale
+	messages = mustLoad(DefaultLocale)
+)
+
+func mustLoad(name string) map[string]string {
+	catalog, err := Load(name, "")
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// Load returns the messages for a locale: the built-in English catalog,
+// overlaid with the built-in catalog for the locale, if any, and then with
+// <dir>/<locale>.json. An operator can use the last one to add a locale or to
+// replace single messages, such as runbook text.
+func Load(name, dir string) (map[string]string, error) {
+	if name == "" {
+		name = DefaultLocale
+	}
+	if !localeRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid locale %q", name)
+	}
+
+	catalog := make(map[string]string)
+	if err := mergeBuiltin(catalog, DefaultLocale); err != nil {
+		return nil, err
+	}
+
+	found := name == DefaultLocale
+	if !found {
+		if err := mergeBuiltin(catalog, name); err == nil {
+			found = true
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if dir != "" {
+		path := filepath.Join(dir, name+".json")
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := merge(catalog, data); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			found = true
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no messages for locale %q", name)
+	}
+	return catalog, nil
+}
+
+func mergeBuiltin(catalog map[string]string, name string) error {
+	data, err := builtin.ReadFile("locales/" + name + ".json")
+	if err != nil {
+		return err
+	}
+	return merge(catalog, data)
+}
+
+func merge(catalog map[string]string, data []byte) error {
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("invalid message catalog: %w", err)
+	}
+	for key, message := range overrides {
+		catalog[key] = message
+	}
+	return nil
+}
+
+// Locales lists the locales with a built-in catalog
+func Locales() []string {
+	entries, _ := builtin.ReadDir("locales")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Configure switches every later message to a locale
+func Configure(name, dir string) error {
+	catalog, err := Load(name, dir)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = DefaultLocale
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	locale = name
+	messages = catalog
+	return nil
+}
+
+// Locale returns the configured locale
+func Locale() string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return locale
+}
+
+// T returns the message for key formatted with args, or the key itself if
+// no catalog has it. Messages use fmt verbs; translations can reorder them
+// with explicit indexes such as %[2]s.
+func T(key string, args ...interface{}) string {
+	mutex.RLock()
+	message, ok := messages[key]
+	mutex.RUnlock()
+
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Runbook returns the runbook section for an alert, or "" if the runbook
+// message for key is empty
+func Runbook(key string, args ...interface{}) string {
+	mutex.RLock()
+	message := messages[key]
+	mutex.RUnlock()
+
+	if message == "" {
+		return ""
+	}
+	return T("alert.runbook", T(key, args...))
+}
+
+// Messages returns the messages whose keys start with prefix, e.g. "ui."
+func Messages(prefix string) map[string]string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	selected := make(map[string]string)
+	for key, message := range messages {
+		if strings.HasPrefix(key, prefix) {
+			selected[key] = message
+		}
+	}
+	return selected
+}
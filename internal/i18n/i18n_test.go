@@ -0,0 +1,104 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var verbRegex = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*[a-zA-Z%]`)
+
+// verbs returns the verbs a message formats, ignoring explicit indexes so
+// a translation may reorder them
+func verbs(message string) map[string]int {
+	counts := make(map[string]int)
+	for _, verb := range verbRegex.FindAllString(message, -1) {
+		counts[verb[len(verb)-1:]]++
+	}
+	return counts
+}
+
+func TestCatalogsMatchEnglish(t *testing.T) {
+	english, err := Load(DefaultLocale, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	for _, name := range Locales() {
+		data, err := builtin.ReadFile("locales/" + name + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		translated := make(map[string]string)
+		if err := merge(translated, data); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for key, message := range translated {
+			original, ok := english[key]
+			if !ok {
+				t.Errorf("%s: %s has no English message", name, key)
+				continue
+			}
+			if got, want := verbs(message), verbs(original); !reflect.DeepEqual(got, want) {
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{"alert.pool.runbook": "Bereitschaft anrufen: %[1]s"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{"ui.refresh": "Actualiser"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	german, err := Load("de", dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if german["alert.pool.runbook"] != "Bereitschaft anrufen: %[1]s" || german["alert.pool.state_field"] != "Zustand:" {
+		t.Errorf("Expected the override over the built-in German catalog, got %q and %q", german["alert.pool.runbook"], german["alert.pool.state_field"])
+	}
+
+	french, err := Load("fr", dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if french["ui.refresh"] != "Actualiser" || french["ui.disks"] != "Disks" {
+		t.Errorf("Expected a new locale to fall back to English, got %q and %q", french["ui.refresh"], french["ui.disks"])
+	}
+
+	for _, name := range []string{"xx", "../etc/passwd"} {
+		if _, err := Load(name, dir); err == nil {
+			t.Errorf("Expected locale %q to be rejected", name)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if err := Configure("de", ""); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	t.Cleanup(func() { Configure(DefaultLocale, "") })
+
+	if got := T("alert.disk.id", "wwn-1"); got != "Festplatten-ID: wwn-1\n" {
+		t.Errorf("Expected the German message, got %q", got)
+	}
+	if got := T("alert.missing"); got != "alert.missing" {
+		t.Errorf("Expected a missing message to fall back to its key, got %q", got)
+	}
+	if got := Runbook("alert.path.runbook", "wwn-1"); got != "" {
+		t.Errorf("Expected no section for an empty runbook, got %q", got)
+	}
+	if got := Runbook("alert.pool.runbook", "tank"); !strings.Contains(got, "zpool status -v tank") {
+		t.Errorf("Expected the pool runbook, got %q", got)
+	}
+	if ui := Messages("ui."); ui["ui.refresh"] != "Aktualisieren" || ui["alert.errors"] != "" {
+		t.Errorf("Expected only UI messages, got %v", ui)
+	}
+}
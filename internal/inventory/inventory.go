@@ -0,0 +1,354 @@
+// Package inventory collects the hardware and software details support and
+// vendor RMA requests ask for: host, ZFS versions, pool layout, disks and NICs.
+package inventory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/version"
+	"zfsrabbit/internal/zfs"
+)
+
+// Paths are variables so tests can point them at fixtures
+var (
+	procDir   = "/proc"
+	sysDir    = "/sys"
+	osRelease = "/etc/os-release"
+	zfsModVer = "/sys/module/zfs/version"
+)
+
+// Inventory is the document served by /api/inventory
+type Inventory struct {
+	Generated time.Time    `json:"generated"`
+	ZFSRabbit version.Info `json:"zfsrabbit"`
+	Host      Host         `json:"host"`
+	ZFS       ZFSVersion   `json:"zfs"`
+	Pools     []Pool       `json:"pools"`
+	Disks     []Disk       `json:"disks"`
+	NICs      []NIC        `json:"nics"`
+	Errors    []string     `json:"errors,omitempty"` // Sections that could not be collected
+}
+
+type Host struct {
+	Hostname    string `json:"hostname"`
+	OS          string `json:"os"`
+	Kernel      string `json:"kernel"`
+	Arch        string `json:"arch"`
+	CPUModel    string `json:"cpu_model"`
+	CPUs        int    `json:"cpus"`
+	MemoryBytes uint64 `json:"memory_bytes"`
+	Vendor      string `json:"vendor,omitempty"`
+	Product     string `json:"product,omitempty"`
+	Serial      string `json:"serial,omitempty"` // Readable by root only
+	BIOS        string `json:"bios,omitempty"`
+}
+
+type ZFSVersion struct {
+	Userland string `json:"userland"`
+	Kernel   string `json:"kernel"`
+}
+
+type Pool struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	SizeBytes uint64 `json:"size_bytes"`
+	Vdevs     []Vdev `json:"vdevs"`
+}
+
+// Vdev is a node in the pool's config tree; leaves are disks or partitions
+type Vdev struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	Children []Vdev `json:"children,omitempty"`
+}
+
+type Disk struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	Serial     string `json:"serial"`
+	Firmware   string `json:"firmware"`
+	WWN        string `json:"wwn,omitempty"`
+	SizeBytes  uint64 `json:"size_bytes"`
+	Transport  string `json:"transport"`
+	Rotational bool   `json:"rotational"`
+}
+
+type NIC struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac"`
+	MTU       int      `json:"mtu"`
+	Up        bool     `json:"up"`
+	SpeedMbps int      `json:"speed_mbps,omitempty"`
+	Driver    string   `json:"driver,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// Collect gathers the inventory. A section that fails is recorded in Errors
+// rather than failing the whole document, since a partial inventory is still
+// useful in a support case.
+func Collect(ctx context.Context) *Inventory {
+	inv := &Inventory{
+		Generated: time.Now(),
+		ZFSRabbit: version.Get(),
+		Host:      collectHost(),
+		ZFS:       collectZFSVersion(ctx),
+	}
+
+	var err error
+	if inv.Pools, err = collectPools(ctx); err != nil {
+		inv.Errors = append(inv.Errors, fmt.Sprintf("pools: %v", err))
+	}
+	if inv.Disks, err = collectDisks(ctx); err != nil {
+		inv.Errors = append(inv.Errors, fmt.Sprintf("disks: %v", err))
+	}
+	if inv.NICs, err = collectNICs(); err != nil {
+		inv.Errors = append(inv.Errors, fmt.Sprintf("nics: %v", err))
+	}
+
+	return inv
+}
+
+func collectHost() Host {
+	host := Host{
+		Arch:     runtime.GOARCH,
+		CPUs:     runtime.NumCPU(),
+		Kernel:   readTrimmed(filepath.Join(procDir, "sys/kernel/osrelease")),
+		CPUModel: cpuModel(readTrimmed(filepath.Join(procDir, "cpuinfo"))),
+		Vendor:   readTrimmed(filepath.Join(sysDir, "class/dmi/id/sys_vendor")),
+		Product:  readTrimmed(filepath.Join(sysDir, "class/dmi/id/product_name")),
+		Serial:   readTrimmed(filepath.Join(sysDir, "class/dmi/id/product_serial")),
+		BIOS:     readTrimmed(filepath.Join(sysDir, "class/dmi/id/bios_version")),
+	}
+	host.Hostname, _ = os.Hostname()
+	host.OS = parseOSRelease(readTrimmed(osRelease))
+	host.MemoryBytes = parseMemTotal(readTrimmed(filepath.Join(procDir, "meminfo")))
+	return host
+}
+
+func collectZFSVersion(ctx context.Context) ZFSVersion {
+	var v ZFSVersion
+	// OpenZFS 0.8+ prints "zfs-2.1.5-1" and "zfs-kmod-2.1.5-1"
+	if output, err := utils.DefaultRunner.Output(utils.DefaultRunner.CommandContext(ctx, "zfs", "version")); err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "zfs-kmod-"):
+				v.Kernel = strings.TrimPrefix(line, "zfs-kmod-")
+			case strings.HasPrefix(line, "zfs-"):
+				v.Userland = strings.TrimPrefix(line, "zfs-")
+			}
+		}
+	}
+	if v.Kernel == "" {
+		v.Kernel = readTrimmed(zfsModVer)
+	}
+	return v
+}
+
+func collectPools(ctx context.Context) ([]Pool, error) {
+	names, err := zfs.GetPoolsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]Pool, 0, len(names))
+	for _, name := range names {
+		status, err := zfs.GetPoolStatusContext(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		pool := Pool{Name: name, State: status.State, Vdevs: buildTopology(status.Config)}
+		if capacity, err := zfs.GetPoolCapacity(ctx, name); err == nil {
+			pool.SizeBytes = capacity.Size
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// buildTopology turns zpool status's flat, indented device list into a tree.
+// The pool's own row (depth 0) is dropped; its children become the roots.
+func buildTopology(devices []zfs.DeviceStatus) []Vdev {
+	var roots []Vdev
+	// path holds the index of the open node at each depth below the pool
+	var path []int
+
+	for _, device := range devices {
+		if device.Depth < 1 {
+			continue
+		}
+		level := device.Depth - 1
+		if level > len(path) {
+			level = len(path)
+		}
+		path = path[:level]
+
+		node := Vdev{Name: device.Name, State: device.State}
+		if level == 0 {
+			roots = append(roots, node)
+			path = append(path, len(roots)-1)
+			continue
+		}
+
+		parent := &roots[path[0]]
+		for _, i := range path[1:] {
+			parent = &parent.Children[i]
+		}
+		parent.Children = append(parent.Children, node)
+		path = append(path, len(parent.Children)-1)
+	}
+
+	return roots
+}
+
+var lsblkPairRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func collectDisks(ctx context.Context) ([]Disk, error) {
+	output, err := utils.DefaultRunner.Output(utils.DefaultRunner.CommandContext(ctx, "lsblk", "-d", "-n", "-b", "-P", "-o", "NAME,MODEL,SERIAL,REV,WWN,SIZE,TRAN,ROTA,TYPE"))
+	if err != nil {
+		return nil, err
+	}
+
+	disks := parseLsblk(string(output))
+	for i := range disks {
+		// Older lsblk leaves REV empty for NVMe; sysfs has it
+		if disks[i].Firmware == "" {
+			disks[i].Firmware = readTrimmed(filepath.Join(sysDir, "block", disks[i].Name, "device/firmware_rev"))
+		}
+	}
+	return disks, nil
+}
+
+func parseLsblk(output string) []Disk {
+	var disks []Disk
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := make(map[string]string)
+		for _, match := range lsblkPairRegex.FindAllStringSubmatch(line, -1) {
+			fields[match[1]] = strings.TrimSpace(match[2])
+		}
+
+		name := fields["NAME"]
+		if name == "" || fields["TYPE"] != "disk" {
+			continue
+		}
+
+		size, _ := strconv.ParseUint(fields["SIZE"], 10, 64)
+		disks = append(disks, Disk{
+			Name:       name,
+			Model:      fields["MODEL"],
+			Serial:     fields["SERIAL"],
+			Firmware:   fields["REV"],
+			WWN:        fields["WWN"],
+			SizeBytes:  size,
+			Transport:  fields["TRAN"],
+			Rotational: fields["ROTA"] == "1",
+		})
+	}
+
+	return disks
+}
+
+func collectNICs() ([]NIC, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var nics []NIC
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		nic := NIC{
+			Name: iface.Name,
+			MAC:  iface.HardwareAddr.String(),
+			MTU:  iface.MTU,
+			Up:   iface.Flags&net.FlagUp != 0,
+		}
+		// speed reads -1 (or fails) when the link is down
+		if speed, err := strconv.Atoi(readTrimmed(filepath.Join(sysDir, "class/net", iface.Name, "speed"))); err == nil && speed > 0 {
+			nic.SpeedMbps = speed
+		}
+		if driver, err := os.Readlink(filepath.Join(sysDir, "class/net", iface.Name, "device/driver")); err == nil {
+			nic.Driver = filepath.Base(driver)
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				nic.Addresses = append(nic.Addresses, addr.String())
+			}
+		}
+		nics = append(nics, nic)
+	}
+
+	return nics, nil
+}
+
+// parseOSRelease returns PRETTY_NAME from /etc/os-release
+func parseOSRelease(content string) string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			values[key] = strings.Trim(value, `"'`)
+		}
+	}
+
+	if values["PRETTY_NAME"] != "" {
+		return values["PRETTY_NAME"]
+	}
+	return strings.TrimSpace(values["NAME"] + " " + values["VERSION"])
+}
+
+// parseMemTotal returns MemTotal from /proc/meminfo in bytes
+func parseMemTotal(content string) uint64 {
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(line, "MemTotal:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "MemTotal:"))
+		if len(fields) == 0 {
+			return 0
+		}
+		kb, _ := strconv.ParseUint(fields[0], 10, 64)
+		return kb * 1024
+	}
+	return 0
+}
+
+func cpuModel(cpuinfo string) string {
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// "model name" on x86, "Model" on ARM boards
+		switch strings.TrimSpace(key) {
+		case "model name", "Model":
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
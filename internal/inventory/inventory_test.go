@@ -0,0 +1,92 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zfsrabbit/internal/zfs"
+)
+
+func TestBuildTopology(t *testing.T) {
+	devices := []zfs.DeviceStatus{
+		{Name: "tank", State: "ONLINE", Depth: 0},
+		{Name: "mirror-0", State: "ONLINE", Depth: 1},
+		{Name: "sda", State: "ONLINE", Depth: 2},
+		{Name: "sdb", State: "FAULTED", Depth: 2},
+		{Name: "raidz1-1", State: "ONLINE", Depth: 1},
+		{Name: "sdc", State: "ONLINE", Depth: 2},
+		{Name: "nvme0n1", State: "ONLINE", Depth: 1},
+	}
+
+	vdevs := buildTopology(devices)
+
+	if len(vdevs) != 3 {
+		t.Fatalf("Expected 3 top-level vdevs, got %+v", vdevs)
+	}
+	if vdevs[0].Name != "mirror-0" || len(vdevs[0].Children) != 2 || vdevs[0].Children[1].State != "FAULTED" {
+		t.Errorf("Unexpected mirror: %+v", vdevs[0])
+	}
+	if len(vdevs[1].Children) != 1 || vdevs[1].Children[0].Name != "sdc" {
+		t.Errorf("Unexpected raidz: %+v", vdevs[1])
+	}
+	if vdevs[2].Name != "nvme0n1" || len(vdevs[2].Children) != 0 {
+		t.Errorf("Expected a single-disk vdev, got %+v", vdevs[2])
+	}
+}
+
+func TestParseLsblk(t *testing.T) {
+	output := `NAME="sda" MODEL="ST8000NM000A-2KE1" SERIAL="WKD1ABCD" REV="SN04" WWN="0x5000c500c1234567" SIZE="8001563222016" TRAN="sata" ROTA="1" TYPE="disk"
+NAME="nvme0n1" MODEL="Samsung SSD 980 PRO 1TB" SERIAL="S5GXNX0R123456" REV="" WWN="" SIZE="1000204886016" TRAN="nvme" ROTA="0" TYPE="disk"
+NAME="sr0" MODEL="DVD-ROM" SERIAL="" REV="1.00" WWN="" SIZE="1073741312" TRAN="sata" ROTA="1" TYPE="rom"`
+
+	disks := parseLsblk(output)
+
+	if len(disks) != 2 {
+		t.Fatalf("Expected 2 disks, got %+v", disks)
+	}
+	if disks[0].Firmware != "SN04" || disks[0].SizeBytes != 8001563222016 || !disks[0].Rotational {
+		t.Errorf("Unexpected sda: %+v", disks[0])
+	}
+	if disks[1].Model != "Samsung SSD 980 PRO 1TB" || disks[1].Rotational {
+		t.Errorf("Unexpected nvme0n1: %+v", disks[1])
+	}
+}
+
+func TestCollectHost(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("proc/meminfo", "MemTotal:       65843212 kB\nMemFree:        1234 kB\n")
+	write("proc/cpuinfo", "processor\t: 0\nmodel name\t: AMD EPYC 7302P 16-Core Processor\n")
+	write("proc/sys/kernel/osrelease", "6.1.0-18-amd64\n")
+	write("sys/class/dmi/id/sys_vendor", "Supermicro\n")
+	write("os-release", "NAME=\"Debian GNU/Linux\"\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n")
+
+	defer func(proc, sys, release string) { procDir, sysDir, osRelease = proc, sys, release }(procDir, sysDir, osRelease)
+	procDir = filepath.Join(dir, "proc")
+	sysDir = filepath.Join(dir, "sys")
+	osRelease = filepath.Join(dir, "os-release")
+
+	host := collectHost()
+
+	if host.MemoryBytes != 65843212*1024 {
+		t.Errorf("Expected memory from meminfo, got %d", host.MemoryBytes)
+	}
+	if host.CPUModel != "AMD EPYC 7302P 16-Core Processor" {
+		t.Errorf("Unexpected CPU model %q", host.CPUModel)
+	}
+	if host.Kernel != "6.1.0-18-amd64" || host.Vendor != "Supermicro" {
+		t.Errorf("Unexpected kernel/vendor: %+v", host)
+	}
+	if host.OS != "Debian GNU/Linux 12 (bookworm)" {
+		t.Errorf("Unexpected OS %q", host.OS)
+	}
+}
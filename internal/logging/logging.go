@@ -0,0 +1,81 @@
+// Package logging sets up the daemon's structured logger and hands out
+// per-module loggers.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Setup makes slog write to w at the given level ("debug", "info", "warn"
+// or "error") in the given format ("text" or "json"). Messages from the log
+// package go through the same handler at info level.
+func Setup(w io.Writer, level, format string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// ParseLevel reads a log level name; "" is info
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// For returns the logger of a module, whose records carry module=<name>.
+// It follows whatever Setup installs later, so packages can create their
+// logger at init.
+func For(module string) *slog.Logger {
+	return slog.New(defaultHandler{attrs: []slog.Attr{slog.String("module", module)}})
+}
+
+// defaultHandler passes records to slog's default handler at the time they
+// are logged
+type defaultHandler struct {
+	attrs []slog.Attr
+}
+
+func (h defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h defaultHandler) Handle(ctx context.Context, record slog.Record) error {
+	return slog.Default().Handler().WithAttrs(h.attrs).Handle(ctx, record)
+}
+
+func (h defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return defaultHandler{attrs: append(slices.Clip(h.attrs), attrs...)}
+}
+
+// WithGroup binds to the current default handler; no module logs in groups
+func (h defaultHandler) WithGroup(name string) slog.Handler {
+	return slog.Default().Handler().WithAttrs(h.attrs).WithGroup(name)
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	// Created before Setup, like a package-level logger
+	logger := For("scheduler").With("job", "media")
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, "warn", "json"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	logger.Info("Created snapshot")
+	logger.Warn("Send failed", "target", "offsite")
+	log.Printf("Legacy message")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the warning at level warn, got %q", buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected JSON, got %q", lines[0])
+	}
+	for key, want := range map[string]string{"level": "WARN", "msg": "Send failed", "module": "scheduler", "job": "media", "target": "offsite"} {
+		if record[key] != want {
+			t.Errorf("Expected %s=%s, got %v", key, want, record[key])
+		}
+	}
+
+	buf.Reset()
+	if err := Setup(&buf, "info", "text"); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	log.Printf("Legacy message")
+	if !strings.Contains(buf.String(), "level=INFO") || !strings.Contains(buf.String(), `msg="Legacy message"`) {
+		t.Errorf("Expected log package output as an info record, got %q", buf.String())
+	}
+
+	if err := Setup(&buf, "verbose", "text"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+	if err := Setup(&buf, "info", "xml"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit operations from milliseconds (a remote zfs list) to
+// hours (a full send), in seconds
+var DefaultBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600, 14400}
+
+// Registry holds metrics and renders them in the Prometheus text format
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Default is the registry served by the /metrics endpoint
+var Default = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) {
+	r.mutex.Lock()
+	metrics := make([]metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mutex.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name() < metrics[j].name()
+	})
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Counter is a monotonically increasing value, optionally split by labels
+type Counter struct {
+	metricName string
+	help       string
+	labelNames []string
+	mutex      sync.Mutex
+	values     map[string]float64
+}
+
+// NewCounter creates a counter registered in the Default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{metricName: name, help: help, labelNames: labelNames, values: make(map[string]float64)}
+	Default.register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	key := labelKey(c.labelNames, labelValues)
+	c.mutex.Lock()
+	c.values[key] += delta
+	c.mutex.Unlock()
+}
+
+// Value returns the current count for the given label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := labelKey(c.labelNames, labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) name() string { return c.metricName }
+
+func (c *Counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.metricName, c.help, c.metricName)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, key, formatFloat(c.values[key]))
+	}
+}
+
+// Gauge is a value that can go up and down, optionally split by labels
+type Gauge struct {
+	metricName string
+	help       string
+	labelNames []string
+	mutex      sync.Mutex
+	values     map[string]float64
+}
+
+// NewGauge creates a gauge registered in the Default registry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{metricName: name, help: help, labelNames: labelNames, values: make(map[string]float64)}
+	Default.register(g)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	key := labelKey(g.labelNames, labelValues)
+	g.mutex.Lock()
+	g.values[key] = value
+	g.mutex.Unlock()
+}
+
+// Value returns the current value for the given label values
+func (g *Gauge) Value(labelValues ...string) float64 {
+	key := labelKey(g.labelNames, labelValues)
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.values[key]
+}
+
+func (g *Gauge) name() string { return g.metricName }
+
+func (g *Gauge) write(w io.Writer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.metricName, g.help, g.metricName)
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, key, formatFloat(g.values[key]))
+	}
+}
+
+// Histogram tracks the distribution of observed values, optionally split by labels
+type Histogram struct {
+	metricName string
+	help       string
+	labelNames []string
+	buckets    []float64
+	mutex      sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// NewHistogram creates a histogram registered in the Default registry. A nil
+// buckets slice uses DefaultBuckets.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{metricName: name, help: help, labelNames: labelNames, buckets: buckets, series: make(map[string]*histogramSeries)}
+	Default.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := labelKey(h.labelNames, labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Count returns how many values were observed for the given label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := labelKey(h.labelNames, labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) name() string { return h.metricName }
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.metricName, h.help, h.metricName)
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			le := labelKey(append(append([]string{}, h.labelNames...), "le"), append(append([]string{}, s.labelValues...), formatFloat(bound)))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, le, s.counts[i])
+		}
+		inf := labelKey(append(append([]string{}, h.labelNames...), "le"), append(append([]string{}, s.labelValues...), "+Inf"))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, inf, s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, key, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, key, s.count)
+	}
+}
+
+// labelKey renders label pairs as {a="x",b="y"}, which doubles as the series key
+func labelKey(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	r := &Registry{}
+	c := &Counter{metricName: "test_total", help: "Test counter", labelNames: []string{"op"}, values: make(map[string]float64)}
+	r.register(c)
+
+	c.Inc("send")
+	c.Add(2, "send")
+	c.Inc("exec")
+
+	if got := c.Value("send"); got != 3 {
+		t.Errorf("Expected 3 for send, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_total counter",
+		`test_total{op="exec"} 1`,
+		`test_total{op="send"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGauge(t *testing.T) {
+	r := &Registry{}
+	g := &Gauge{metricName: "test_ratio", help: "Test gauge", labelNames: []string{"dataset"}, values: make(map[string]float64)}
+	r.register(g)
+
+	g.Set(1, "tank/a")
+	g.Set(0.5, "tank/a")
+	g.Set(0, "tank/b")
+
+	if got := g.Value("tank/a"); got != 0.5 {
+		t.Errorf("Expected 0.5 for tank/a, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_ratio gauge",
+		`test_ratio{dataset="tank/a"} 0.5`,
+		`test_ratio{dataset="tank/b"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := &Registry{}
+	h := &Histogram{metricName: "test_seconds", help: "Test histogram", buckets: []float64{1, 10}, series: make(map[string]*histogramSeries)}
+	r.register(h)
+
+	h.Observe(0.5)
+	h.Observe(5)
+	h.Observe(50)
+
+	if h.Count() != 3 {
+		t.Errorf("Expected 3 observations, got %d", h.Count())
+	}
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{le="1"} 1`,
+		`test_seconds_bucket{le="10"} 2`,
+		`test_seconds_bucket{le="+Inf"} 3`,
+		"test_seconds_sum 55.5",
+		"test_seconds_count 3",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	got := labelKey([]string{"path"}, []string{`a"b\c`})
+	want := `{path="a\"b\\c"}`
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,332 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/zfs"
+)
+
+const (
+	CheckKindPool     = "pool"
+	CheckKindDisk     = "disk"
+	CheckKindCapacity = "capacity"
+	CheckKindScript   = "script"
+	CheckKindProperty = "properties"
+)
+
+// CheckStatus describes one independent health check loop
+type CheckStatus struct {
+	Name        string        `json:"name"`
+	Kind        string        `json:"kind"`
+	Resource    string        `json:"resource"`
+	Target      string        `json:"target,omitempty"` // Current device path when Resource is a stable ID
+	Interval    time.Duration `json:"interval"`
+	Timeout     time.Duration `json:"timeout"`
+	Running     bool          `json:"running"`
+	LastRun     time.Time     `json:"last_run"`
+	LastSuccess time.Time     `json:"last_success"`
+	LastError   string        `json:"last_error,omitempty"`
+	Throttled   string        `json:"throttled,omitempty"` // Why the check is running less often, if it is
+}
+
+type checkRunner struct {
+	status CheckStatus
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	result interface{} // Latest pool status or SMART data, for GetSystemStatus
+}
+
+// refreshChecks discovers pools and disks and makes sure each one has its own
+// check goroutine, stopping goroutines for resources that have disappeared.
+func (m *Monitor) refreshChecks() {
+	ctx, cancel := context.WithTimeout(m.ctx, m.checkTimeout())
+	defer cancel()
+
+	wanted := make(map[string]*checkRunner)
+
+	pools, poolsErr := zfs.GetPoolsContext(ctx)
+	if poolsErr != nil {
+		logger.Error("Failed to get ZFS pools", "err", poolsErr)
+	}
+	for _, pool := range pools {
+		pool := pool
+		m.addCheck(wanted, CheckKindPool, pool, m.config.Monitor.PoolInterval, func(ctx context.Context) error {
+			return m.checkPoolHealth(ctx, pool)
+		})
+		m.addCheck(wanted, CheckKindCapacity, pool, m.config.Monitor.CapacityInterval, func(ctx context.Context) error {
+			return m.checkPoolCapacity(ctx, pool)
+		})
+	}
+
+	disks, disksErr := m.getSystemDisks(ctx)
+	if disksErr != nil {
+		logger.Error("Failed to list system disks", "err", disksErr)
+	}
+	for _, disk := range disks {
+		disk := disk
+		runner := m.addCheck(wanted, CheckKindDisk, disk.ID, m.diskInterval(disk), func(ctx context.Context) error {
+			return m.checkDiskHealth(ctx, disk)
+		})
+		runner.status.Target = disk.Device
+	}
+
+	for _, script := range m.config.Monitor.ScriptChecks {
+		script := script
+		runner := m.addCheck(wanted, CheckKindScript, script.Name, script.Interval, func(ctx context.Context) error {
+			return m.runScriptCheck(ctx, script)
+		})
+		if script.Timeout > 0 {
+			runner.status.Timeout = script.Timeout
+		}
+		runner.status.Target = script.Command
+	}
+
+	for dataset, expected := range m.config.ExpectedProperties() {
+		dataset, expected := dataset, expected
+		m.addCheck(wanted, CheckKindProperty, dataset, m.config.Monitor.PropertyInterval, func(ctx context.Context) error {
+			return m.checkProperties(ctx, dataset, expected)
+		})
+	}
+
+	m.checksMutex.Lock()
+	defer m.checksMutex.Unlock()
+
+	// Only retire checks of a kind whose discovery succeeded, so a transient
+	// lsblk failure doesn't tear down every disk check
+	for name, runner := range m.checks {
+		if want, ok := wanted[name]; ok {
+			if want.status.Target == runner.status.Target {
+				continue
+			}
+			// Same resource under a new path (e.g. a disk with a new kernel name); restart against it
+			logger.Info("Check target moved", "check", name, "from", runner.status.Target, "to", want.status.Target)
+			runner.cancel()
+			delete(m.checks, name)
+			continue
+		}
+		if (runner.status.Kind == CheckKindDisk && disksErr != nil) ||
+			((runner.status.Kind == CheckKindPool || runner.status.Kind == CheckKindCapacity) && poolsErr != nil) {
+			continue
+		}
+		logger.Info("Stopping check, resource no longer present", "kind", runner.status.Kind, "resource", runner.status.Resource)
+		runner.cancel()
+		delete(m.checks, name)
+	}
+
+	for name, runner := range wanted {
+		if _, ok := m.checks[name]; ok {
+			continue
+		}
+		runCtx, runCancel := context.WithCancel(m.ctx)
+		runner.cancel = runCancel
+		m.checks[name] = runner
+		go m.runCheck(runCtx, runner)
+	}
+}
+
+func (m *Monitor) addCheck(checks map[string]*checkRunner, kind, resource string, interval time.Duration, run func(ctx context.Context) error) *checkRunner {
+	if interval <= 0 {
+		interval = m.discoveryInterval()
+	}
+
+	name := fmt.Sprintf("%s:%s", kind, resource)
+	runner := &checkRunner{
+		status: CheckStatus{
+			Name:     name,
+			Kind:     kind,
+			Resource: resource,
+			Interval: interval,
+			Timeout:  m.checkTimeout(),
+		},
+		run: run,
+	}
+	checks[name] = runner
+	return runner
+}
+
+func (m *Monitor) runCheck(ctx context.Context, runner *checkRunner) {
+	m.executeCheck(ctx, runner)
+
+	ticker := time.NewTicker(runner.status.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if m.deferCheck(runner, now) {
+				continue
+			}
+			m.executeCheck(ctx, runner)
+		}
+	}
+}
+
+func (m *Monitor) executeCheck(ctx context.Context, runner *checkRunner) {
+	checkCtx, cancel := context.WithTimeout(ctx, runner.status.Timeout)
+	defer cancel()
+
+	m.checksMutex.Lock()
+	runner.status.Running = true
+	runner.status.LastRun = time.Now()
+	m.checksMutex.Unlock()
+
+	err := runner.run(checkCtx)
+	if err == nil && checkCtx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("check timed out after %s", runner.status.Timeout)
+	}
+
+	m.checksMutex.Lock()
+	runner.status.Running = false
+	if err != nil {
+		runner.status.LastError = err.Error()
+	} else {
+		runner.status.LastError = ""
+		runner.status.LastSuccess = time.Now()
+	}
+	m.checksMutex.Unlock()
+
+	if err != nil && ctx.Err() == nil {
+		logger.Warn("Check failed", "check", runner.status.Name, "err", err)
+	}
+}
+
+// setCheckResult keeps what a check read about its resource, so status
+// requests can be answered without running zpool or smartctl again
+func (m *Monitor) setCheckResult(kind, resource string, result interface{}) {
+	m.checksMutex.Lock()
+	defer m.checksMutex.Unlock()
+	if runner, ok := m.checks[fmt.Sprintf("%s:%s", kind, resource)]; ok {
+		runner.result = result
+	}
+}
+
+// checkResults returns the latest result of every check of kind, by resource
+func (m *Monitor) checkResults(kind string) map[string]interface{} {
+	m.checksMutex.Lock()
+	defer m.checksMutex.Unlock()
+
+	results := make(map[string]interface{})
+	for _, runner := range m.checks {
+		if runner.status.Kind == kind && runner.result != nil {
+			results[runner.status.Resource] = runner.result
+		}
+	}
+	return results
+}
+
+// GetCheckStatuses returns a snapshot of every check loop, sorted by name
+func (m *Monitor) GetCheckStatuses() []CheckStatus {
+	m.checksMutex.Lock()
+	defer m.checksMutex.Unlock()
+
+	statuses := make([]CheckStatus, 0, len(m.checks))
+	for _, runner := range m.checks {
+		statuses = append(statuses, runner.status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+func (m *Monitor) discoveryInterval() time.Duration {
+	if m.config.Schedule.MonitorInterval > 0 {
+		return m.config.Schedule.MonitorInterval
+	}
+	return 5 * time.Minute
+}
+
+func (m *Monitor) checkTimeout() time.Duration {
+	if m.config.Monitor.CheckTimeout > 0 {
+		return m.config.Monitor.CheckTimeout
+	}
+	return 2 * time.Minute
+}
+
+func (m *Monitor) checkPoolCapacity(ctx context.Context, pool string) error {
+	capacity, err := zfs.GetPoolCapacity(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	severity := m.getCapacitySeverity(capacity.Capacity)
+	if severity > SeverityInfo {
+		m.sendCapacityAlert(capacity, severity)
+	}
+
+	return nil
+}
+
+func (m *Monitor) getCapacitySeverity(percent int) AlertSeverity {
+	warning := m.config.Monitor.CapacityWarningPercent
+	if warning <= 0 {
+		warning = 80
+	}
+	critical := m.config.Monitor.CapacityCriticalPercent
+	if critical <= 0 {
+		critical = 90
+	}
+
+	switch {
+	case percent >= critical:
+		return SeverityCritical
+	case percent >= warning:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+func (m *Monitor) sendCapacityAlert(capacity *zfs.PoolCapacity, severity AlertSeverity) {
+	alertKey := fmt.Sprintf("capacity_%s", capacity.Pool)
+	if !m.severityAlertDue(alertKey, severity) {
+		return
+	}
+
+	subject := fmt.Sprintf("[%s] ZFS Pool Capacity Alert: %s", severity.String(), capacity.Pool)
+	body := i18n.T("alert.capacity.body", severity.String(), capacity.Pool, capacity.Health, capacity.Capacity, capacity.Alloc, capacity.Free, capacity.Size)
+	body += i18n.Runbook("alert.capacity.runbook", capacity.Pool)
+
+	if m.silenced(subject, capacity.Pool) {
+		return
+	}
+	if err := m.alerter.SendAlert(subject, body); err != nil {
+		logger.Error("Failed to send capacity alert", "pool", capacity.Pool, "err", err)
+		return
+	}
+
+	m.recordSeverityAlert(alertKey, severity)
+	logger.Info("Sent capacity alert", "severity", severity.String(), "pool", capacity.Pool, "capacity", capacity.Capacity)
+}
+
+// severityAlertDue reports whether an alert for key should go out: always on
+// first occurrence or escalation, otherwise only once the cooldown has passed
+func (m *Monitor) severityAlertDue(alertKey string, severity AlertSeverity) bool {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	currentState, exists := m.alertStates[alertKey]
+	if !exists || severity > currentState.LastSeverity {
+		return true
+	}
+	return time.Since(currentState.LastAlertTime) >= m.alertCooldown
+}
+
+func (m *Monitor) recordSeverityAlert(alertKey string, severity AlertSeverity) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	m.alertStates[alertKey] = &AlertState{
+		LastAlertTime: time.Now(),
+		LastSeverity:  severity,
+	}
+	m.saveAlertStatesLocked()
+}
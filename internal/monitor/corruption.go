@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"zfsrabbit/internal/catalog"
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/zfs"
+)
+
+// SetCatalog makes the monitor flag replicated datasets and snapshots that
+// scrubs find permanent errors in
+func (m *Monitor) SetCatalog(c *catalog.Catalog) {
+	m.catalog = c
+}
+
+// affectedReplicas maps zpool permanent errors to the replicated datasets and
+// snapshots that may have carried the damaged data to the backup server
+func (m *Monitor) affectedReplicas(ctx context.Context, pool string, entries []string) []catalog.Suspect {
+	var mountpoints map[string]string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, "/") {
+			var err error
+			if mountpoints, err = zfs.GetMountpointsContext(ctx); err != nil {
+				logger.Warn("Failed to list mountpoints to map errors", "pool", pool, "err", err)
+			}
+			break
+		}
+	}
+
+	grouped := make(map[string]*catalog.Suspect)
+	for _, dataErr := range zfs.ParseDataErrors(entries, mountpoints) {
+		if !m.isReplicated(dataErr.Dataset) {
+			continue
+		}
+
+		key := dataErr.Dataset + "@" + dataErr.Snapshot
+		suspect, ok := grouped[key]
+		if !ok {
+			suspect = &catalog.Suspect{
+				Pool:     pool,
+				Dataset:  dataErr.Dataset,
+				Snapshot: dataErr.Snapshot,
+				Reason:   fmt.Sprintf("scrub found permanent errors in pool %s", pool),
+			}
+			grouped[key] = suspect
+		}
+		suspect.Paths = append(suspect.Paths, dataErr.Path)
+	}
+
+	suspects := make([]catalog.Suspect, 0, len(grouped))
+	for _, suspect := range grouped {
+		suspects = append(suspects, *suspect)
+	}
+	sort.Slice(suspects, func(i, j int) bool {
+		if suspects[i].Dataset != suspects[j].Dataset {
+			return suspects[i].Dataset < suspects[j].Dataset
+		}
+		return suspects[i].Snapshot < suspects[j].Snapshot
+	})
+	return suspects
+}
+
+// isReplicated reports whether dataset is sent to a backup server by the
+// default job or a jobs entry
+func (m *Monitor) isReplicated(dataset string) bool {
+	if dataset == "" {
+		return false
+	}
+	if covers(m.config.ZFS, dataset) {
+		return true
+	}
+	for _, job := range m.config.Jobs {
+		if covers(job.ZFSConfig, dataset) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether a job replicating zfsCfg sends dataset
+func covers(zfsCfg config.ZFSConfig, dataset string) bool {
+	root := zfsCfg.Dataset
+	if dataset == root {
+		return true
+	}
+	return zfsCfg.Recursive && strings.HasPrefix(dataset, root+"/")
+}
+
+// formatSuspects renders affected replicas for an alert body
+func formatSuspects(suspects []catalog.Suspect) string {
+	body := "\nReplicated data that may be corrupted (flagged for verification):\n"
+	for _, suspect := range suspects {
+		target := suspect.Target()
+		if suspect.Snapshot == "" {
+			target += " (live filesystem and all replicated snapshots)"
+		}
+		body += fmt.Sprintf("  %s\n", target)
+		for _, path := range suspect.Paths {
+			body += fmt.Sprintf("    %s\n", path)
+		}
+	}
+	return body
+}
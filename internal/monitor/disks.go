@@ -0,0 +1,291 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/i18n"
+)
+
+// byIDDir is where udev publishes stable disk links; a variable so tests can point it elsewhere
+var byIDDir = "/dev/disk/by-id"
+
+// sysBlockDir is used to find device-mapper holders of multipath member paths
+var sysBlockDir = "/sys/block"
+
+// DiskInfo identifies a physical disk independently of its kernel name,
+// which can change across reboots (sda becoming sdb)
+type DiskInfo struct {
+	Device          string // Current kernel path, e.g. /dev/sda
+	ID              string // Stable identity used to key alert state
+	ByIDPath        string // Matching /dev/disk/by-id link, if any
+	WWN             string
+	Serial          string
+	Model           string
+	Transport       string   // sata, sas, nvme, ...
+	Paths           []string // Every kernel path to this disk (more than one when multipathed)
+	FailedPaths     []string // Paths whose SCSI state is not running
+	MultipathDevice string   // dm-N holder when the disk is under device-mapper multipath
+}
+
+// IsMultipath reports whether the disk is reachable over more than one path
+func (d DiskInfo) IsMultipath() bool {
+	return len(d.Paths) > 1 || d.MultipathDevice != ""
+}
+
+// HealthyPaths returns how many paths to the disk are usable
+func (d DiskInfo) HealthyPaths() int {
+	return len(d.Paths) - len(d.FailedPaths)
+}
+
+var lsblkPairRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Disks returns the physical disks the monitor polls
+func (m *Monitor) Disks(ctx context.Context) ([]DiskInfo, error) {
+	return m.getSystemDisks(ctx)
+}
+
+func (m *Monitor) getSystemDisks(ctx context.Context) ([]DiskInfo, error) {
+	cmd := m.commands.CommandContext(ctx, "lsblk", "-d", "-n", "-P", "-o", "NAME,WWN,SERIAL,MODEL,TYPE,TRAN,STATE")
+	output, err := m.commands.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	disks := mergeMultipathDisks(parseLsblkDisks(string(output), resolveByIDLinks()))
+	for i := range disks {
+		disks[i].MultipathDevice = findMultipathHolder(disks[i].Paths)
+	}
+
+	return disks, nil
+}
+
+// parseLsblkDisks parses `lsblk -P` key="value" output, attaching the best
+// /dev/disk/by-id link for each kernel device name. Each kernel path is
+// returned separately; use mergeMultipathDisks to collapse shared disks.
+func parseLsblkDisks(output string, byID map[string]string) []DiskInfo {
+	var disks []DiskInfo
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := make(map[string]string)
+		for _, match := range lsblkPairRegex.FindAllStringSubmatch(line, -1) {
+			fields[match[1]] = strings.TrimSpace(match[2])
+		}
+
+		name := fields["NAME"]
+		if name == "" || strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "sr") {
+			continue
+		}
+
+		// Device-mapper nodes (multipath maps) can't be SMART-polled; the
+		// physical member paths are listed separately
+		if fields["TYPE"] == "mpath" || strings.HasPrefix(name, "dm-") {
+			continue
+		}
+
+		disk := DiskInfo{
+			Device:    "/dev/" + name,
+			ByIDPath:  byID[name],
+			WWN:       fields["WWN"],
+			Serial:    fields["SERIAL"],
+			Model:     fields["MODEL"],
+			Transport: fields["TRAN"],
+			Paths:     []string{"/dev/" + name},
+		}
+		if state := fields["STATE"]; state != "" && state != "running" && state != "live" {
+			disk.FailedPaths = []string{disk.Device}
+		}
+		disk.ID = stableDiskID(disk)
+		disks = append(disks, disk)
+	}
+
+	return disks
+}
+
+// stableDiskID prefers the WWN, then the by-id link name, then the serial,
+// falling back to the kernel name only when nothing better is known
+func stableDiskID(disk DiskInfo) string {
+	switch {
+	case disk.WWN != "":
+		return "wwn-" + disk.WWN
+	case disk.ByIDPath != "":
+		return filepath.Base(disk.ByIDPath)
+	case disk.Serial != "":
+		return "serial-" + disk.Serial
+	default:
+		return filepath.Base(disk.Device)
+	}
+}
+
+// resolveByIDLinks maps kernel device names (sda, nvme0n1) to their most
+// specific /dev/disk/by-id link
+func resolveByIDLinks() map[string]string {
+	links := make(map[string]string)
+
+	entries, err := os.ReadDir(byIDDir)
+	if err != nil {
+		return links
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.Contains(name, "-part") {
+			continue
+		}
+
+		linkPath := filepath.Join(byIDDir, name)
+		target, err := filepath.EvalSymlinks(linkPath)
+		if err != nil {
+			continue
+		}
+
+		kernelName := filepath.Base(target)
+		if existing, ok := links[kernelName]; !ok || byIDRank(name) < byIDRank(filepath.Base(existing)) {
+			links[kernelName] = linkPath
+		}
+	}
+
+	return links
+}
+
+func byIDRank(name string) int {
+	prefixes := []string{"wwn-", "nvme-eui.", "scsi-", "ata-", "nvme-"}
+	for i, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return i
+		}
+	}
+	return len(prefixes)
+}
+
+// mergeMultipathDisks collapses kernel paths that share a stable ID (the same
+// WWN seen through multiple SAS expanders/HBAs) into a single disk so it is
+// only SMART-polled once, through a path that is still working
+func mergeMultipathDisks(paths []DiskInfo) []DiskInfo {
+	var disks []DiskInfo
+	index := make(map[string]int)
+
+	for _, path := range paths {
+		i, seen := index[path.ID]
+		if !seen || path.WWN == "" {
+			index[path.ID] = len(disks)
+			disks = append(disks, path)
+			continue
+		}
+
+		disk := &disks[i]
+		disk.Paths = append(disk.Paths, path.Paths...)
+		disk.FailedPaths = append(disk.FailedPaths, path.FailedPaths...)
+
+		// Poll SMART through a healthy path
+		if len(path.FailedPaths) == 0 && contains(disk.FailedPaths, disk.Device) {
+			disk.Device = path.Device
+		}
+		if disk.ByIDPath == "" {
+			disk.ByIDPath = path.ByIDPath
+		}
+	}
+
+	return disks
+}
+
+// findMultipathHolder returns the /dev/dm-N device that holds any of the given paths
+func findMultipathHolder(paths []string) string {
+	for _, path := range paths {
+		holders, err := os.ReadDir(filepath.Join(sysBlockDir, filepath.Base(path), "holders"))
+		if err != nil {
+			continue
+		}
+		for _, holder := range holders {
+			if strings.HasPrefix(holder.Name(), "dm-") {
+				return "/dev/" + holder.Name()
+			}
+		}
+	}
+	return ""
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sendPathAlert reports lost paths to a multipathed disk. Losing some paths is
+// a warning (redundancy reduced); losing all of them is critical.
+func (m *Monitor) sendPathAlert(disk DiskInfo) {
+	severity := SeverityWarning
+	if disk.HealthyPaths() == 0 {
+		severity = SeverityCritical
+	}
+
+	alertKey := fmt.Sprintf("paths_%s", disk.ID)
+	if !m.severityAlertDue(alertKey, severity) {
+		return
+	}
+
+	subject := fmt.Sprintf("[%s] Disk Path Alert: %s", severity.String(), disk.ID)
+	body := i18n.T("alert.path.body", severity.String(), disk.ID, disk.Model, disk.Serial, disk.Transport,
+		disk.HealthyPaths(), len(disk.Paths), strings.Join(disk.FailedPaths, ", "))
+
+	if disk.MultipathDevice != "" {
+		body += i18n.T("alert.path.multipath", disk.MultipathDevice)
+	}
+
+	body += i18n.T("alert.path.note")
+	body += i18n.Runbook("alert.path.runbook", disk.ID)
+
+	if m.silenced(subject, "", append([]string{disk.ID, disk.Device, disk.ByIDPath, disk.Serial}, disk.Paths...)...) {
+		return
+	}
+	if err := m.alerter.SendAlert(subject, body); err != nil {
+		logger.Error("Failed to send path alert", "disk", disk.ID, "err", err)
+		return
+	}
+
+	m.recordSeverityAlert(alertKey, severity)
+	logger.Info("Sent path alert", "severity", severity.String(), "disk", disk.ID, "healthy_paths", disk.HealthyPaths(), "paths", len(disk.Paths))
+}
+
+// diskInterval returns the check interval for a disk, preferring a
+// monitor.disk_intervals entry for its ID, serial, by-id path or device
+func (m *Monitor) diskInterval(disk DiskInfo) time.Duration {
+	for _, key := range []string{disk.ID, disk.Serial, disk.ByIDPath, disk.Device} {
+		if interval, ok := m.config.Monitor.DiskIntervals[key]; ok && key != "" {
+			return interval
+		}
+	}
+	return m.config.Monitor.DiskInterval
+}
+
+// smartConcurrency is how many disks may be polled at once
+func smartConcurrency(cfg *config.Config) int {
+	if cfg.Monitor.SMARTConcurrency > 0 {
+		return cfg.Monitor.SMARTConcurrency
+	}
+	return 4
+}
+
+// acquireSMART waits for a free SMART polling slot, giving up when ctx ends
+func (m *Monitor) acquireSMART(ctx context.Context) (func(), error) {
+	select {
+	case m.smartSlots <- struct{}{}:
+		return func() { <-m.smartSlots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a SMART polling slot: %w", ctx.Err())
+	}
+}
+
+// isStandby reports whether smartctl -n standby skipped a spun down disk
+func isStandby(output string) bool {
+	return strings.Contains(output, "is in STANDBY mode") || strings.Contains(output, "is in SLEEP mode")
+}
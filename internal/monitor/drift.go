@@ -0,0 +1,177 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/zfs"
+)
+
+// PropertyDrift is a ZFS property whose value differs from the configured one
+type PropertyDrift struct {
+	Dataset  string    `json:"dataset"`
+	Property string    `json:"property"`
+	Expected string    `json:"expected"`
+	Actual   string    `json:"actual"`
+	Since    time.Time `json:"since"`
+}
+
+// checkProperties compares a dataset's properties with the expected ones
+func (m *Monitor) checkProperties(ctx context.Context, dataset string, expected map[string]string) error {
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	actual, err := zfs.GetPropertiesContext(ctx, dataset, names)
+	if err != nil {
+		return err
+	}
+
+	m.recordDrift(dataset, compareProperties(dataset, expected, actual), time.Now())
+	return nil
+}
+
+// compareProperties returns the expected properties whose actual value
+// differs, sorted by property name
+func compareProperties(dataset string, expected, actual map[string]string) []PropertyDrift {
+	var drifts []PropertyDrift
+	for name, want := range expected {
+		got, ok := actual[name]
+		if !ok {
+			got = "-"
+		}
+		if propertyMatches(want, got) {
+			continue
+		}
+		drifts = append(drifts, PropertyDrift{Dataset: dataset, Property: name, Expected: want, Actual: got})
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].Property < drifts[j].Property
+	})
+	return drifts
+}
+
+// propertyMatches compares values case-insensitively, and as sizes so that
+// recordsize 128K matches 131072
+func propertyMatches(want, got string) bool {
+	if strings.EqualFold(strings.TrimSpace(want), strings.TrimSpace(got)) {
+		return true
+	}
+	wantSize, wantErr := config.ParseRate(want)
+	gotSize, gotErr := config.ParseRate(got)
+	return wantErr == nil && gotErr == nil && wantSize == gotSize
+}
+
+// recordDrift stores a dataset's drift and alerts only when it differs from
+// what was seen last time, so a drifted property is reported once rather
+// than on every check
+func (m *Monitor) recordDrift(dataset string, drifts []PropertyDrift, now time.Time) {
+	m.stateMutex.Lock()
+	previous := m.drift[dataset]
+	since := make(map[string]time.Time, len(previous))
+	for _, drift := range previous {
+		since[drift.Property+"="+drift.Actual] = drift.Since
+	}
+
+	var added []PropertyDrift
+	for i := range drifts {
+		if at, ok := since[drifts[i].Property+"="+drifts[i].Actual]; ok {
+			drifts[i].Since = at
+			continue
+		}
+		drifts[i].Since = now
+		added = append(added, drifts[i])
+	}
+
+	if len(drifts) == 0 {
+		delete(m.drift, dataset)
+	} else {
+		m.drift[dataset] = drifts
+	}
+	m.stateMutex.Unlock()
+
+	if len(drifts) == 0 && len(previous) > 0 {
+		logger.Info("ZFS properties match the configuration again", "dataset", dataset)
+	}
+	if len(added) > 0 {
+		m.sendDriftAlert(dataset, added)
+	}
+}
+
+func (m *Monitor) sendDriftAlert(dataset string, drifts []PropertyDrift) {
+	var lines []string
+	for _, drift := range drifts {
+		lines = append(lines, fmt.Sprintf("  %s: expected %s, found %s", drift.Property, drift.Expected, drift.Actual))
+	}
+
+	subject := fmt.Sprintf("[WARNING] ZFS Property Drift: %s", dataset)
+	body := i18n.T("alert.drift.body", dataset, strings.Join(lines, "\n"))
+	body += i18n.Runbook("alert.drift.runbook", dataset)
+
+	if m.silenced(subject, datasetPool(dataset)) {
+		return
+	}
+	if err := m.alerter.SendAlert(subject, body); err != nil {
+		logger.Error("Failed to send property drift alert", "dataset", dataset, "err", err)
+		return
+	}
+	logger.Info("Sent property drift alert", "dataset", dataset, "changed", len(drifts))
+}
+
+// GetPropertyDrift returns every property that currently differs from the
+// configuration, sorted by dataset and property
+func (m *Monitor) GetPropertyDrift() []PropertyDrift {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	drifts := []PropertyDrift{}
+	for _, dataset := range m.drift {
+		drifts = append(drifts, dataset...)
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Dataset != drifts[j].Dataset {
+			return drifts[i].Dataset < drifts[j].Dataset
+		}
+		return drifts[i].Property < drifts[j].Property
+	})
+	return drifts
+}
+
+// ReapplyProperties sets a dataset's expected properties again, or those of
+// every drifted dataset if dataset is "", and checks them once more
+func (m *Monitor) ReapplyProperties(ctx context.Context, dataset string) error {
+	expected := m.config.ExpectedProperties()
+
+	datasets := []string{dataset}
+	if dataset == "" {
+		datasets = nil
+		m.stateMutex.Lock()
+		for name := range m.drift {
+			datasets = append(datasets, name)
+		}
+		m.stateMutex.Unlock()
+		sort.Strings(datasets)
+	}
+
+	for _, name := range datasets {
+		props, ok := expected[name]
+		if !ok {
+			return fmt.Errorf("no expected properties are configured for %s", name)
+		}
+		if err := zfs.SetProperties(name, props); err != nil {
+			return err
+		}
+		logger.Info("Reapplied expected ZFS properties", "dataset", name)
+		if err := m.checkProperties(ctx, name, props); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/i18n"
+)
+
+// eventTimeLayout is how `zpool events` prints an event's time, in local time
+const eventTimeLayout = "Jan 02 2006 15:04:05.000000000"
+
+// eventRestartDelay is how long to wait before following events again when
+// `zpool events -f` exits
+const eventRestartDelay = 30 * time.Second
+
+// ZFS event classes that are alerted on
+const (
+	eventChecksum       = "ereport.fs.zfs.checksum"
+	eventRemoved        = "resource.fs.zfs.removed"
+	eventStateChange    = "resource.fs.zfs.statechange"
+	eventResilverStart  = "sysevent.fs.zfs.resilver_start"
+	eventResilverFinish = "sysevent.fs.zfs.resilver_finish"
+)
+
+// ZpoolEvent is one event read from `zpool events -v`
+type ZpoolEvent struct {
+	EID       int64
+	Time      time.Time
+	Class     string
+	Pool      string
+	Vdev      string // Device path, or the vdev GUID when there is no path
+	VdevState string // New vdev state for statechange events, e.g. FAULTED
+}
+
+// watchEvents follows `zpool events -f` until the monitor stops, starting it
+// again whenever it exits. Events from before the watcher started, and any
+// replayed on a restart, are skipped.
+func (m *Monitor) watchEvents() {
+	logger.Info("Following zpool events")
+
+	started := time.Now()
+	var lastEID int64
+	handle := func(event ZpoolEvent) {
+		if event.EID != 0 {
+			if event.EID <= lastEID {
+				return
+			}
+			lastEID = event.EID
+		}
+		if event.Time.Before(started) {
+			return
+		}
+		m.handleEvent(event)
+	}
+
+	for {
+		err := m.followEvents(m.ctx, handle)
+		if m.ctx.Err() != nil {
+			return
+		}
+		logger.Warn("zpool events exited, following again later", "delay", eventRestartDelay, "err", err)
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(eventRestartDelay):
+		}
+	}
+}
+
+// followEvents runs `zpool events -f -v -H` and hands each event to handle
+// until the command exits or ctx is cancelled
+func (m *Monitor) followEvents(ctx context.Context, handle func(ZpoolEvent)) error {
+	cmd := m.commands.CommandContext(ctx, "zpool", "events", "-f", "-v", "-H")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start zpool events: %w", err)
+	}
+
+	parseErr := parseEvents(stdout, handle)
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+	return parseErr
+}
+
+// parseEvents reads `zpool events -v` output: each event is a line with its
+// time and class followed by indented "name = value" lines, and events are
+// separated by blank lines. Nested nvlists are skipped.
+func parseEvents(r io.Reader, handle func(ZpoolEvent)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event *ZpoolEvent
+	nested := 0
+	flush := func() {
+		if event != nil && event.Class != "" {
+			handle(*event)
+		}
+		event = nil
+		nested = 0
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+			continue
+		case line[0] != ' ' && line[0] != '\t':
+			flush()
+			event = parseEventHeader(trimmed)
+			continue
+		case event == nil:
+			continue
+		}
+
+		name, value, ok := strings.Cut(trimmed, " = ")
+		if !ok {
+			if strings.HasPrefix(trimmed, "(end ") && nested > 0 {
+				nested--
+			}
+			continue
+		}
+		if value == "(embedded nvlist)" {
+			nested++
+			continue
+		}
+		if nested > 0 {
+			continue
+		}
+
+		switch name {
+		case "class":
+			event.Class = unquote(value)
+		case "pool":
+			event.Pool = unquote(value)
+		case "vdev_path":
+			event.Vdev = unquote(value)
+		case "vdev_guid":
+			if event.Vdev == "" {
+				event.Vdev = value
+			}
+		case "vdev_state":
+			event.VdevState = unquote(value)
+		case "eid":
+			event.EID, _ = strconv.ParseInt(value, 0, 64)
+		}
+	}
+	flush()
+	return scanner.Err()
+}
+
+// parseEventHeader reads an event's "time class" line
+func parseEventHeader(line string) *ZpoolEvent {
+	event := &ZpoolEvent{}
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return event
+	}
+	event.Class = fields[len(fields)-1]
+	if t, err := time.ParseInLocation(eventTimeLayout, strings.Join(fields[:4], " "), time.Local); err == nil {
+		event.Time = t
+	}
+	return event
+}
+
+// unquote strips the quotes around string values, and the raw number
+// `zpool events` prints after named values such as vdev_state
+func unquote(value string) string {
+	if strings.HasPrefix(value, `"`) {
+		if end := strings.Index(value[1:], `"`); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	return value
+}
+
+// classifyEvent returns the severity and description of an event that should
+// be alerted on, and false for events that are only of interest to zed
+func classifyEvent(event ZpoolEvent) (AlertSeverity, string, bool) {
+	switch event.Class {
+	case eventChecksum:
+		return SeverityWarning, "Checksum error", true
+	case eventRemoved:
+		return SeverityCritical, "Device removed", true
+	case eventStateChange:
+		switch event.VdevState {
+		case "FAULTED", "REMOVED", "UNAVAIL":
+			return SeverityCritical, "Device " + event.VdevState, true
+		}
+	case eventResilverStart:
+		return SeverityInfo, "Resilver started", true
+	case eventResilverFinish:
+		return SeverityInfo, "Resilver finished", true
+	}
+	return SeverityInfo, "", false
+}
+
+// handleEvent alerts on an event as soon as it is seen. Device errors are
+// alerted on at most once per cooldown for each event class and device, so
+// a burst of checksum errors sends one alert; resilvers always are.
+func (m *Monitor) handleEvent(event ZpoolEvent) {
+	severity, description, ok := classifyEvent(event)
+	if !ok {
+		return
+	}
+
+	subject := fmt.Sprintf("[%s] ZFS Event: %s on %s", severity.String(), description, event.Pool)
+	if m.silenced(subject, event.Pool, event.Vdev) {
+		return
+	}
+
+	isResilver := event.Class == eventResilverStart || event.Class == eventResilverFinish
+	if !isResilver && !m.eventAlertDue(fmt.Sprintf("%s:%s:%s", event.Class, event.Pool, event.Vdev)) {
+		return
+	}
+
+	device := event.Vdev
+	if device == "" {
+		device = "-"
+	}
+	body := i18n.T("alert.event.body", severity.String(), description, event.Pool, device, display.Time(event.Time), event.Class)
+	if !isResilver {
+		body += i18n.Runbook("alert.pool.runbook", event.Pool)
+	}
+
+	if err := m.alerter.SendAlert(subject, body); err != nil {
+		logger.Error("Failed to send event alert", "class", event.Class, "pool", event.Pool, "err", err)
+		return
+	}
+	logger.Info("Sent event alert", "severity", severity.String(), "class", event.Class, "pool", event.Pool, "device", device)
+}
+
+// eventAlertDue reports whether an event alert for key is due, and if so
+// starts its cooldown
+func (m *Monitor) eventAlertDue(key string) bool {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	now := time.Now()
+	if last, ok := m.eventAlerts[key]; ok && now.Sub(last) < m.config.Monitor.Events.Cooldown {
+		return false
+	}
+	m.eventAlerts[key] = now
+	return true
+}
@@ -3,17 +3,28 @@ package monitor
 import (
 	"context"
 	"fmt"
-	"log"
-	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"zfsrabbit/internal/alert"
+	"zfsrabbit/internal/catalog"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/logging"
+	"zfsrabbit/internal/parse"
+	"zfsrabbit/internal/silence"
+	"zfsrabbit/internal/utils"
 	"zfsrabbit/internal/zfs"
 )
 
+var logger = logging.For("monitor")
+
 type AlertSeverity int
 
 const (
@@ -52,6 +63,20 @@ type Monitor struct {
 	cancel        context.CancelFunc
 	alertStates   map[string]*AlertState // Per-device alert state
 	alertCooldown time.Duration
+	stateMutex    sync.Mutex // Protects alertStates, shared by all check goroutines
+	checks        map[string]*checkRunner
+	checksMutex   sync.Mutex
+	catalog       *catalog.Catalog  // Optional, receives replicas flagged by scrub errors
+	scans         map[string]string // Pools with a scrub or resilver running
+	scanMutex     sync.Mutex
+	smartSlots    chan struct{}        // Limits how many disks are polled at once
+	eventAlerts   map[string]time.Time // Last alert per zpool event class and device, under stateMutex
+	commands      utils.CommandRunner
+	events        *events.Bus                  // Receives pool health changes
+	poolStates    map[string]events.PoolChange // Last published health per pool, under stateMutex
+	drift         map[string][]PropertyDrift   // Properties differing from the configuration per dataset, under stateMutex
+	slackIncident *alert.SlackIncident         // Open Slack alert thread, saved with alertStates
+	silences      *silence.Store               // Optional, holds back alerts during maintenance
 }
 
 type Alerter interface {
@@ -70,6 +95,8 @@ type PoolHealth struct {
 	Devices  []DeviceHealth
 	Degraded bool
 	Scrub    ScrubStatus
+	// Replicated datasets and snapshots the permanent errors map to
+	AffectedReplicas []catalog.Suspect
 }
 
 type DeviceHealth struct {
@@ -90,10 +117,19 @@ type ScrubStatus struct {
 }
 
 type SMARTData struct {
-	Device      string
-	Healthy     bool
-	Temperature int
-	Errors      []string
+	Device    string // Current kernel path, e.g. /dev/sda
+	ID        string // Stable identity (WWN/serial) that survives reboots
+	ByIDPath  string
+	Serial    string
+	Model     string
+	Transport string
+	// Multipath fields, only populated when the disk has more than one path
+	Paths           []string
+	FailedPaths     []string
+	MultipathDevice string
+	Healthy         bool
+	Temperature     int
+	Errors          []string
 	// NVMe-specific fields
 	IsNVMe           bool
 	CriticalWarning  int    // NVMe critical warning bits
@@ -101,34 +137,66 @@ type SMARTData struct {
 	AvailableSpare   int    // Spare capacity remaining
 	MaxTemperature   int    // Lifetime max temperature
 	DataUnitsWritten uint64 // Total data written (for wear tracking)
+	// Standby is set when the disk was spun down and left alone; no other
+	// SMART fields are read
+	Standby bool
 }
 
 func New(cfg *config.Config, alerter Alerter) *Monitor {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Monitor{
+	m := &Monitor{
 		config:        cfg,
 		alerter:       alerter,
 		ctx:           ctx,
 		cancel:        cancel,
 		alertStates:   make(map[string]*AlertState),
 		alertCooldown: 1 * time.Hour,
+		checks:        make(map[string]*checkRunner),
+		scans:         make(map[string]string),
+		smartSlots:    make(chan struct{}, smartConcurrency(cfg)),
+		eventAlerts:   make(map[string]time.Time),
+		commands:      utils.DefaultRunner,
+		poolStates:    make(map[string]events.PoolChange),
+		drift:         make(map[string][]PropertyDrift),
 	}
+
+	m.loadAlertStates()
+
+	return m
+}
+
+// SetCommandRunner replaces the runner used for smartctl, nvme, lsblk,
+// zpool events and script checks
+func (m *Monitor) SetCommandRunner(runner utils.CommandRunner) {
+	m.commands = runner
+}
+
+// Start launches an independent check goroutine per pool and disk and then
+// periodically rediscovers resources so new pools and disks get picked up.
+// SetEvents publishes pool health changes to bus
+func (m *Monitor) SetEvents(bus *events.Bus) {
+	m.events = bus
 }
 
 func (m *Monitor) Start() {
-	log.Println("Starting system monitor")
+	logger.Info("Starting system monitor")
+
+	m.refreshChecks()
+	if m.config.Monitor.Events.Enabled {
+		go m.watchEvents()
+	}
 
-	ticker := time.NewTicker(m.config.Schedule.MonitorInterval)
+	ticker := time.NewTicker(m.discoveryInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-m.ctx.Done():
-			log.Println("System monitor stopped")
+			logger.Info("System monitor stopped")
 			return
 		case <-ticker.C:
-			m.checkSystemHealth()
+			m.refreshChecks()
 		}
 	}
 }
@@ -137,29 +205,12 @@ func (m *Monitor) Stop() {
 	m.cancel()
 }
 
-func (m *Monitor) checkSystemHealth() {
-	pools, err := zfs.GetPools()
-	if err != nil {
-		log.Printf("Failed to get ZFS pools: %v", err)
-		return
-	}
-
-	for _, pool := range pools {
-		if err := m.checkPoolHealth(pool); err != nil {
-			log.Printf("Failed to check health of pool %s: %v", pool, err)
-		}
-	}
-
-	if err := m.checkDiskHealth(); err != nil {
-		log.Printf("Failed to check disk health: %v", err)
-	}
-}
-
-func (m *Monitor) checkPoolHealth(pool string) error {
-	status, err := zfs.GetPoolStatus(pool)
+func (m *Monitor) checkPoolHealth(ctx context.Context, pool string) error {
+	status, err := zfs.GetPoolStatusContext(ctx, pool)
 	if err != nil {
 		return err
 	}
+	m.setCheckResult(CheckKindPool, pool, status)
 
 	health := &PoolHealth{
 		Pool:   pool,
@@ -167,6 +218,7 @@ func (m *Monitor) checkPoolHealth(pool string) error {
 		Errors: status.Errors,
 		Scrub:  m.parseScrubStatus(status.Scan),
 	}
+	m.recordScan(pool, health.Scrub)
 
 	for _, device := range status.Config {
 		deviceHealth := DeviceHealth{
@@ -184,6 +236,19 @@ func (m *Monitor) checkPoolHealth(pool string) error {
 		}
 	}
 
+	if len(health.Errors) > 0 {
+		health.AffectedReplicas = m.affectedReplicas(ctx, pool, health.Errors)
+		if m.catalog != nil {
+			for _, suspect := range health.AffectedReplicas {
+				if m.catalog.FlagForVerification(suspect) {
+					logger.Warn("Flagged replica for verification after pool errors", "replica", suspect.Target(), "pool", pool)
+				}
+			}
+		}
+	}
+
+	m.publishPoolChange(health)
+
 	if health.State != "ONLINE" || health.Degraded || len(health.Errors) > 0 {
 		m.sendPoolAlert(health)
 	}
@@ -191,6 +256,26 @@ func (m *Monitor) checkPoolHealth(pool string) error {
 	return nil
 }
 
+// publishPoolChange announces a pool's health when it differs from the last check
+func (m *Monitor) publishPoolChange(health *PoolHealth) {
+	change := events.PoolChange{
+		Pool:      health.Pool,
+		State:     health.State,
+		Degraded:  health.Degraded,
+		Errors:    len(health.Errors),
+		Scrubbing: health.Scrub.InProgress,
+	}
+
+	m.stateMutex.Lock()
+	previous, seen := m.poolStates[health.Pool]
+	m.poolStates[health.Pool] = change
+	m.stateMutex.Unlock()
+
+	if !seen || previous != change {
+		m.events.Publish(events.TypePool, change)
+	}
+}
+
 func (m *Monitor) parseScrubStatus(scanLine string) ScrubStatus {
 	scrub := ScrubStatus{}
 
@@ -214,210 +299,160 @@ func (m *Monitor) parseScrubStatus(scanLine string) ScrubStatus {
 	return scrub
 }
 
-func (m *Monitor) checkDiskHealth() error {
-	disks, err := m.getSystemDisks()
-	if err != nil {
-		return err
+func (m *Monitor) checkDiskHealth(ctx context.Context, disk DiskInfo) error {
+	// Path failures are reported separately from disk failures: a dead HBA
+	// port or expander says nothing about the health of the platters
+	if len(disk.FailedPaths) > 0 {
+		m.sendPathAlert(disk)
 	}
 
-	for _, disk := range disks {
-		smart, err := m.getSMARTData(disk)
-		if err != nil {
-			log.Printf("Failed to get SMART data for %s: %v", disk, err)
-			continue
-		}
+	if disk.HealthyPaths() == 0 {
+		return fmt.Errorf("no healthy paths to %s", disk.ID)
+	}
 
-		if !smart.Healthy || len(smart.Errors) > 0 {
-			m.sendDiskAlert(smart)
-		}
+	// Leave the disks alone while a resilver or scrub is working them
+	if adaptation := m.Adaptation(); adaptation.SkipSMART {
+		return nil
+	}
+
+	smart, err := m.getDiskSMARTData(ctx, disk)
+	if err != nil {
+		return fmt.Errorf("failed to get SMART data for %s: %w", disk.Device, err)
+	}
+	m.setCheckResult(CheckKindDisk, disk.ID, smart)
+	if smart.Standby {
+		return nil
+	}
+
+	if !smart.Healthy || len(smart.Errors) > 0 {
+		m.sendDiskAlert(smart)
 	}
 
 	return nil
 }
 
-func (m *Monitor) getSystemDisks() ([]string, error) {
-	cmd := exec.Command("lsblk", "-d", "-n", "-o", "NAME")
-	output, err := cmd.Output()
+// getDiskSMARTData reads SMART data for a disk and tags it with the disk's stable identity
+func (m *Monitor) getDiskSMARTData(ctx context.Context, disk DiskInfo) (*SMARTData, error) {
+	smart, err := m.getSMARTData(ctx, disk.Device)
 	if err != nil {
 		return nil, err
 	}
 
-	var disks []string
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		disk := strings.TrimSpace(line)
-		if disk != "" && !strings.HasPrefix(disk, "loop") && !strings.HasPrefix(disk, "sr") {
-			disks = append(disks, "/dev/"+disk)
-		}
+	smart.ID = disk.ID
+	smart.ByIDPath = disk.ByIDPath
+	smart.Serial = disk.Serial
+	smart.Model = disk.Model
+	smart.Transport = disk.Transport
+	smart.MultipathDevice = disk.MultipathDevice
+	if disk.IsMultipath() {
+		smart.Paths = disk.Paths
+		smart.FailedPaths = disk.FailedPaths
 	}
-
-	return disks, nil
+	if !smart.Standby {
+		m.checkThresholds(smart)
+	}
+	return smart, nil
 }
 
-func (m *Monitor) getSMARTData(device string) (*SMARTData, error) {
+func (m *Monitor) getSMARTData(ctx context.Context, device string) (*SMARTData, error) {
 	smart := &SMARTData{
 		Device:  device,
 		Healthy: true,
 	}
 
+	release, err := m.acquireSMART(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Check if this is an NVMe device
 	if strings.Contains(device, "nvme") {
-		return m.getNVMeSMARTData(device, smart)
+		return m.getNVMeSMARTData(ctx, device, smart)
 	}
 
 	// Traditional SMART data for HDDs/SATA SSDs
-	cmd := exec.Command("smartctl", "-H", "-A", device)
-	output, err := cmd.Output()
+	args := []string{"-H", "-A", device}
+	if m.config.Monitor.SkipStandby {
+		// Don't spin up a sleeping disk just to read its attributes
+		args = append([]string{"-n", "standby"}, args...)
+	}
+	cmd := m.commands.CommandContext(ctx, "smartctl", args...)
+	output, err := m.commands.Output(cmd)
+	if isStandby(string(output)) {
+		smart.Standby = true
+		return smart, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if strings.Contains(line, "SMART overall-health") {
-			if !strings.Contains(line, "PASSED") {
-				smart.Healthy = false
-				smart.Errors = append(smart.Errors, "SMART health check failed")
-			}
-		}
+	m.parseSmartctlOutput(smart, string(output))
 
-		if strings.Contains(line, "Temperature_Celsius") {
-			fields := strings.Fields(line)
-			if len(fields) >= 10 {
-				if temp, err := strconv.Atoi(fields[9]); err == nil {
-					smart.Temperature = temp
-					if temp > 60 {
-						smart.Errors = append(smart.Errors, fmt.Sprintf("High temperature: %d°C", temp))
-					}
-				}
-			}
-		}
+	return smart, nil
+}
 
-		if strings.Contains(line, "Reallocated_Sector_Ct") ||
-			strings.Contains(line, "Current_Pending_Sector") ||
-			strings.Contains(line, "Offline_Uncorrectable") {
-			fields := strings.Fields(line)
-			if len(fields) >= 10 {
-				if value, err := strconv.Atoi(fields[9]); err == nil && value > 0 {
-					smart.Errors = append(smart.Errors, fmt.Sprintf("%s: %d", fields[1], value))
-				}
-			}
-		}
+// parseSmartctlOutput handles both ATA attribute tables and the SAS/SCSI
+// "SMART Health Status" layout
+func (m *Monitor) parseSmartctlOutput(smart *SMARTData, output string) {
+	report := parse.Smartctl(output)
+	if !report.Healthy {
+		smart.Healthy = false
 	}
-
-	return smart, nil
+	if report.Temperature > 0 {
+		smart.Temperature = report.Temperature
+	}
+	smart.Errors = append(smart.Errors, report.Errors...)
 }
 
-func (m *Monitor) getNVMeSMARTData(device string, smart *SMARTData) (*SMARTData, error) {
+func (m *Monitor) getNVMeSMARTData(ctx context.Context, device string, smart *SMARTData) (*SMARTData, error) {
 	smart.IsNVMe = true
 
 	// Use nvme-cli - the ONE way to get NVMe SMART data
-	if err := m.parseNVMeCLI(device, smart); err != nil {
+	if err := m.parseNVMeCLI(ctx, device, smart); err != nil {
 		return nil, fmt.Errorf("failed to get NVMe SMART data using nvme-cli: %w", err)
 	}
 
 	return smart, nil
 }
 
-func (m *Monitor) parseNVMeCLI(device string, smart *SMARTData) error {
-	cmd := exec.Command("nvme", "smart-log", device)
-	output, err := cmd.Output()
+func (m *Monitor) parseNVMeCLI(ctx context.Context, device string, smart *SMARTData) error {
+	cmd := m.commands.CommandContext(ctx, "nvme", "smart-log", device)
+	output, err := m.commands.Output(cmd)
 	if err != nil {
 		return err
 	}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Parse critical warning
-		if strings.Contains(line, "critical_warning") {
-			fields := strings.Fields(line)
-			if len(fields) >= 3 {
-				if warning, err := strconv.Atoi(fields[2]); err == nil {
-					smart.CriticalWarning = warning
-					if warning > 0 {
-						smart.Healthy = false
-						smart.Errors = append(smart.Errors, fmt.Sprintf("Critical warning: 0x%x", warning))
-					}
-				}
-			}
-		}
-
-		// Parse temperature
-		if strings.Contains(line, "temperature") && strings.Contains(line, "Celsius") {
-			fields := strings.Fields(line)
-			for _, field := range fields {
-				if temp, err := strconv.Atoi(field); err == nil && temp > 0 && temp < 200 {
-					smart.Temperature = temp
-					if temp > 60 {
-						smart.Errors = append(smart.Errors, fmt.Sprintf("High temperature: %d°C", temp))
-					}
-					break
-				}
-			}
-		}
-
-		// Parse percentage used (wear level)
-		if strings.Contains(line, "percentage_used") {
-			fields := strings.Fields(line)
-			if len(fields) >= 3 {
-				if used, err := strconv.Atoi(strings.TrimSuffix(fields[2], "%")); err == nil {
-					smart.PercentageUsed = used
-					if used > 90 {
-						smart.Errors = append(smart.Errors, fmt.Sprintf("High wear level: %d%%", used))
-					}
-				}
-			}
-		}
-
-		// Parse available spare
-		if strings.Contains(line, "available_spare") {
-			fields := strings.Fields(line)
-			if len(fields) >= 3 {
-				if spare, err := strconv.Atoi(strings.TrimSuffix(fields[2], "%")); err == nil {
-					smart.AvailableSpare = spare
-					if spare < 10 {
-						smart.Healthy = false
-						smart.Errors = append(smart.Errors, fmt.Sprintf("Low spare capacity: %d%%", spare))
-					}
-				}
-			}
-		}
-
-		// Parse data units written
-		if strings.Contains(line, "data_units_written") {
-			fields := strings.Fields(line)
-			if len(fields) >= 3 {
-				if written, err := strconv.ParseUint(strings.Replace(fields[2], ",", "", -1), 10, 64); err == nil {
-					smart.DataUnitsWritten = written
-				}
-			}
-		}
+	health := parse.NVMeSmartLog(string(output))
+	smart.CriticalWarning = health.CriticalWarning
+	if health.CriticalWarning > 0 {
+		smart.Healthy = false
+		smart.Errors = append(smart.Errors, fmt.Sprintf("Critical warning: 0x%x", health.CriticalWarning))
 	}
+	if health.Temperature > 0 {
+		smart.Temperature = health.Temperature
+	}
+	smart.PercentageUsed = health.PercentageUsed
+	smart.AvailableSpare = health.AvailableSpare
+	smart.DataUnitsWritten = health.DataUnitsWritten
 
 	return nil
 }
 
 func (m *Monitor) sendPoolAlert(health *PoolHealth) {
 	alertKey := fmt.Sprintf("pool_%s", health.Pool)
+
+	m.stateMutex.Lock()
 	currentState, exists := m.alertStates[alertKey]
+	inCooldown := exists && time.Since(currentState.LastAlertTime) < m.alertCooldown
+	m.stateMutex.Unlock()
 
-	if exists && time.Since(currentState.LastAlertTime) < m.alertCooldown {
+	if inCooldown {
 		return
 	}
 
-	subject := fmt.Sprintf("ZFS Pool Alert: %s", health.Pool)
-	body := fmt.Sprintf(`ZFS Pool Health Alert
-
-Pool: %s
-State: %s
-Degraded: %v
-
-Device Status:
-`, health.Pool, health.State, health.Degraded)
+	subject := fmt.Sprintf("[%s] ZFS Pool Alert: %s", poolSeverity(health.State).String(), health.Pool)
+	body := i18n.T("alert.pool.body", health.Pool, health.State, health.Degraded)
 
 	for _, device := range health.Devices {
 		body += fmt.Sprintf("  %s: %s (R:%d W:%d C:%d)\n",
@@ -425,20 +460,30 @@ Device Status:
 	}
 
 	if len(health.Errors) > 0 {
-		body += "\nErrors:\n"
+		body += i18n.T("alert.errors")
 		for _, err := range health.Errors {
 			body += fmt.Sprintf("  %s\n", err)
 		}
 	}
 
 	if health.Scrub.Errors > 0 {
-		body += fmt.Sprintf("\nScrub Errors: %d\n", health.Scrub.Errors)
+		body += i18n.T("alert.pool.scrub_errors", health.Scrub.Errors)
 	}
 
+	if len(health.AffectedReplicas) > 0 {
+		body += formatSuspects(health.AffectedReplicas)
+	}
+
+	body += i18n.Runbook("alert.pool.runbook", health.Pool)
+
+	if m.silenced(subject, health.Pool) {
+		return
+	}
 	if err := m.alerter.SendAlert(subject, body); err != nil {
-		log.Printf("Failed to send pool alert: %v", err)
+		logger.Error("Failed to send pool alert", "pool", health.Pool, "err", err)
 	} else {
 		// Update or create alert state for this pool
+		m.stateMutex.Lock()
 		if !exists {
 			m.alertStates[alertKey] = &AlertState{
 				LastAlertTime: time.Now(),
@@ -446,36 +491,29 @@ Device Status:
 		} else {
 			currentState.LastAlertTime = time.Now()
 		}
-		log.Printf("Sent pool health alert for %s", health.Pool)
+		m.saveAlertStatesLocked()
+		m.stateMutex.Unlock()
+		logger.Info("Sent pool health alert", "pool", health.Pool)
 	}
 }
 
+// poolSeverity is the severity of a pool alert, carried in its subject so
+// alerters needn't read the state from the localised body. Pools that have
+// FAULTED or worse are emergencies and any other pool that isn't ONLINE is
+// critical; an ONLINE pool is only alerted on for errors.
+func poolSeverity(state string) AlertSeverity {
+	switch state {
+	case "ONLINE":
+		return SeverityWarning
+	case "FAULTED", "UNAVAIL", "SUSPENDED":
+		return SeverityEmergency
+	}
+	return SeverityCritical
+}
+
 func (m *Monitor) getTemperatureSeverity(temperature int, isNVMe bool) AlertSeverity {
-	if isNVMe {
-		// NVMe drives have different temperature tolerances
-		switch {
-		case temperature >= 80:
-			return SeverityEmergency // Immediate action required
-		case temperature >= 70:
-			return SeverityCritical // Very concerning
-		case temperature >= 60:
-			return SeverityWarning // Watch closely
-		default:
-			return SeverityInfo
-		}
-	} else {
-		// Traditional HDD/SATA SSD thresholds
-		switch {
-		case temperature >= 70:
-			return SeverityEmergency
-		case temperature >= 60:
-			return SeverityCritical
-		case temperature >= 50:
-			return SeverityWarning
-		default:
-			return SeverityInfo
-		}
-	}
+	limits := m.classThresholds(isNVMe)
+	return risingSeverity(temperature, limits.TempWarning, limits.TempCritical, limits.TempEmergency)
 }
 
 func (m *Monitor) getCriticalWarningSeverity(warning int) AlertSeverity {
@@ -505,13 +543,8 @@ func (m *Monitor) getCriticalWarningSeverity(warning int) AlertSeverity {
 }
 
 func (m *Monitor) getOverallSeverity(smart *SMARTData) AlertSeverity {
-	maxSeverity := SeverityInfo
-
-	// Check temperature severity
-	tempSeverity := m.getTemperatureSeverity(smart.Temperature, smart.IsNVMe)
-	if tempSeverity > maxSeverity {
-		maxSeverity = tempSeverity
-	}
+	limits := m.diskThresholds(smart)
+	maxSeverity := risingSeverity(smart.Temperature, limits.TempWarning, limits.TempCritical, limits.TempEmergency)
 
 	// Check NVMe critical warning severity
 	if smart.IsNVMe && smart.CriticalWarning > 0 {
@@ -521,47 +554,42 @@ func (m *Monitor) getOverallSeverity(smart *SMARTData) AlertSeverity {
 		}
 	}
 
-	// Check wear level for NVMe
+	// Check wear level and available spare for NVMe
 	if smart.IsNVMe {
-		switch {
-		case smart.PercentageUsed >= 100:
-			if SeverityEmergency > maxSeverity {
-				maxSeverity = SeverityEmergency
-			}
-		case smart.PercentageUsed >= 95:
-			if SeverityCritical > maxSeverity {
-				maxSeverity = SeverityCritical
-			}
-		case smart.PercentageUsed >= 90:
-			if SeverityWarning > maxSeverity {
-				maxSeverity = SeverityWarning
-			}
+		if severity := risingSeverity(smart.PercentageUsed, limits.WearWarning, limits.WearCritical, limits.WearEmergency); severity > maxSeverity {
+			maxSeverity = severity
 		}
-	}
-
-	// Check available spare for NVMe
-	if smart.IsNVMe {
-		switch {
-		case smart.AvailableSpare <= 5:
-			if SeverityEmergency > maxSeverity {
-				maxSeverity = SeverityEmergency
-			}
-		case smart.AvailableSpare <= 10:
-			if SeverityCritical > maxSeverity {
-				maxSeverity = SeverityCritical
-			}
-		case smart.AvailableSpare <= 20:
-			if SeverityWarning > maxSeverity {
-				maxSeverity = SeverityWarning
-			}
+		if severity := fallingSeverity(smart.AvailableSpare, limits.SpareWarning, limits.SpareCritical, limits.SpareEmergency); severity > maxSeverity {
+			maxSeverity = severity
 		}
 	}
 
 	return maxSeverity
 }
 
+// alertKey keys disk alert state by stable identity so history follows the
+// physical disk rather than whatever kernel name it booted with
+func (smart *SMARTData) alertKey() string {
+	if smart.ID != "" {
+		return smart.ID
+	}
+	return smart.Device
+}
+
+// displayName shows the kernel name alongside the stable identity when known
+func (smart *SMARTData) displayName() string {
+	if smart.ID != "" && smart.ID != filepath.Base(smart.Device) {
+		return fmt.Sprintf("%s (%s)", smart.Device, smart.ID)
+	}
+	return smart.Device
+}
+
 func (m *Monitor) shouldSendAlert(smart *SMARTData, severity AlertSeverity) bool {
-	alertKey := smart.Device
+	alertKey := smart.alertKey()
+
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
 	currentState, exists := m.alertStates[alertKey]
 
 	if !exists {
@@ -572,6 +600,7 @@ func (m *Monitor) shouldSendAlert(smart *SMARTData, severity AlertSeverity) bool
 			LastTemperature:     smart.Temperature,
 			LastCriticalWarning: smart.CriticalWarning,
 		}
+		m.saveAlertStatesLocked()
 		return severity > SeverityInfo
 	}
 
@@ -602,6 +631,7 @@ func (m *Monitor) shouldSendAlert(smart *SMARTData, severity AlertSeverity) bool
 		currentState.LastSeverity = severity
 		currentState.LastTemperature = smart.Temperature
 		currentState.LastCriticalWarning = smart.CriticalWarning
+		m.saveAlertStatesLocked()
 		return true
 	}
 
@@ -611,6 +641,7 @@ func (m *Monitor) shouldSendAlert(smart *SMARTData, severity AlertSeverity) bool
 		currentState.LastSeverity = severity
 		currentState.LastTemperature = smart.Temperature
 		currentState.LastCriticalWarning = smart.CriticalWarning
+		m.saveAlertStatesLocked()
 		return true
 	}
 
@@ -620,94 +651,83 @@ func (m *Monitor) shouldSendAlert(smart *SMARTData, severity AlertSeverity) bool
 func (m *Monitor) sendDiskAlert(smart *SMARTData) {
 	severity := m.getOverallSeverity(smart)
 
-	if !m.shouldSendAlert(smart, severity) {
-		return
-	}
-
-	deviceType := "Disk"
+	// The subject stays in English so SNMP trap types and mail filters keep working
+	deviceType, localType := "Disk", i18n.T("alert.disk.type")
 	if smart.IsNVMe {
-		deviceType = "NVMe SSD"
+		deviceType, localType = "NVMe SSD", i18n.T("alert.disk.type_nvme")
 	}
 
 	// Include severity in subject
-	subject := fmt.Sprintf("[%s] %s Health Alert: %s", severity.String(), deviceType, smart.Device)
-	body := fmt.Sprintf(`%s Health Alert
+	subject := fmt.Sprintf("[%s] %s Health Alert: %s", severity.String(), deviceType, smart.displayName())
+
+	// Checked before shouldSendAlert, which starts the cooldown
+	if severity > SeverityInfo && m.silenced(subject, "", smart.Device, smart.ID, smart.ByIDPath, smart.Serial) {
+		return
+	}
+	if !m.shouldSendAlert(smart, severity) {
+		return
+	}
+	body := i18n.T("alert.disk.body", localType, severity.String(), smart.Device, smart.Healthy, display.Temperature(smart.Temperature))
 
-Severity: %s
-Device: %s
-Healthy: %v
-Temperature: %d°C
-`, deviceType, severity.String(), smart.Device, smart.Healthy, smart.Temperature)
+	if smart.ID != "" {
+		body += i18n.T("alert.disk.id", smart.ID)
+	}
+	if smart.ByIDPath != "" {
+		body += i18n.T("alert.disk.stable_path", smart.ByIDPath)
+	}
+	if smart.Model != "" || smart.Serial != "" {
+		body += i18n.T("alert.disk.model", smart.Model, smart.Serial)
+	}
 
 	// Add NVMe-specific information
 	if smart.IsNVMe {
-		body += fmt.Sprintf(`
-NVMe Specific Data:
-Critical Warning: 0x%x
-Wear Level: %d%%
-Available Spare: %d%%
-Data Written: %d units
-`, smart.CriticalWarning, smart.PercentageUsed, smart.AvailableSpare, smart.DataUnitsWritten)
+		body += i18n.T("alert.disk.nvme", smart.CriticalWarning, smart.PercentageUsed, smart.AvailableSpare, smart.DataUnitsWritten)
 
 		// Add critical warning explanations
 		if smart.CriticalWarning > 0 {
-			body += "\nCritical Warning Details:\n"
+			body += i18n.T("alert.disk.nvme_warnings")
 			if smart.CriticalWarning&1 != 0 {
-				body += "  - Available spare capacity has fallen below threshold\n"
+				body += i18n.T("alert.disk.nvme_spare")
 			}
 			if smart.CriticalWarning&2 != 0 {
-				body += "  - Temperature threshold exceeded\n"
+				body += i18n.T("alert.disk.nvme_temperature")
 			}
 			if smart.CriticalWarning&4 != 0 {
-				body += "  - NVM subsystem reliability degraded\n"
+				body += i18n.T("alert.disk.nvme_reliability")
 			}
 			if smart.CriticalWarning&8 != 0 {
-				body += "  - Media placed in read-only mode\n"
+				body += i18n.T("alert.disk.nvme_read_only")
 			}
 			if smart.CriticalWarning&16 != 0 {
-				body += "  - Persistent memory region backed up (if applicable)\n"
+				body += i18n.T("alert.disk.nvme_pmr")
 			}
 		}
 	}
 
 	if len(smart.Errors) > 0 {
-		body += "\nErrors:\n"
+		body += i18n.T("alert.errors")
 		for _, err := range smart.Errors {
 			body += fmt.Sprintf("  %s\n", err)
 		}
 	}
 
+	body += i18n.Runbook("alert.disk.runbook", smart.Device)
+
 	if err := m.alerter.SendAlert(subject, body); err != nil {
-		log.Printf("Failed to send disk alert: %v", err)
+		logger.Error("Failed to send disk alert", "disk", smart.displayName(), "err", err)
 	} else {
-		log.Printf("Sent %s [%s] health alert for %s", deviceType, severity.String(), smart.Device)
+		logger.Info("Sent disk health alert", "type", deviceType, "severity", severity.String(), "disk", smart.displayName())
 	}
 }
 
+// GetSystemStatus reports what the pool and disk checks last read, so a hung
+// zpool or smartctl holds up only its own check and never a status request.
+// Pools and disks whose first check hasn't finished are left out.
 func (m *Monitor) GetSystemStatus() map[string]interface{} {
 	status := make(map[string]interface{})
-
-	pools, err := zfs.GetPools()
-	if err == nil {
-		poolStatus := make(map[string]interface{})
-		for _, pool := range pools {
-			if health, err := zfs.GetPoolStatus(pool); err == nil {
-				poolStatus[pool] = health
-			}
-		}
-		status["pools"] = poolStatus
-	}
-
-	disks, err := m.getSystemDisks()
-	if err == nil {
-		diskStatus := make(map[string]interface{})
-		for _, disk := range disks {
-			if smart, err := m.getSMARTData(disk); err == nil {
-				diskStatus[disk] = smart
-			}
-		}
-		status["disks"] = diskStatus
-	}
-
+	status["pools"] = m.checkResults(CheckKindPool)
+	status["monitoring"] = m.Adaptation()
+	status["disks"] = m.checkResults(CheckKindDisk)
+	status["checks"] = m.GetCheckStatuses()
 	return status
 }
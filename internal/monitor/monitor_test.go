@@ -1,12 +1,20 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/silence"
+	"zfsrabbit/internal/utils"
 	"zfsrabbit/internal/zfs"
 )
 
@@ -553,6 +561,91 @@ func TestSendPoolAlert(t *testing.T) {
 	}
 }
 
+func TestPoolAlertSeverityInAnotherLocale(t *testing.T) {
+	if err := i18n.Configure("de", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer i18n.Configure("", "")
+
+	tests := []struct {
+		state    string
+		severity string
+	}{
+		{"FAULTED", "[EMERGENCY] "},
+		{"UNAVAIL", "[EMERGENCY] "},
+		{"DEGRADED", "[CRITICAL] "},
+	}
+
+	for _, tt := range tests {
+		alerter := NewMockAlerter()
+		monitor := New(&config.Config{}, alerter)
+		monitor.sendPoolAlert(&PoolHealth{Pool: "tank", State: tt.state, Degraded: true})
+
+		alert := alerter.GetLastAlert()
+		if alert == nil {
+			t.Fatalf("%s: expected an alert", tt.state)
+		}
+		// The body is German, so the severity must come with the subject
+		if !strings.Contains(alert.Body, "Zustand: "+tt.state) {
+			t.Errorf("%s: expected a German body, got %q", tt.state, alert.Body)
+		}
+		if alert.Subject != tt.severity+"ZFS Pool Alert: tank" {
+			t.Errorf("%s: expected severity %q, got subject %q", tt.state, tt.severity, alert.Subject)
+		}
+	}
+}
+
+func TestAffectedReplicas(t *testing.T) {
+	cfg := &config.Config{ZFS: config.ZFSConfig{Dataset: "tank/data", Recursive: true}}
+	monitor := New(cfg, NewMockAlerter())
+
+	entries := []string{
+		"tank/data:<0x10>",
+		"tank/data/child@snap1:/file",
+		"tank/other:/ignored",
+		"<metadata>:<0x3f>",
+	}
+
+	suspects := monitor.affectedReplicas(context.Background(), "tank", entries)
+	if len(suspects) != 2 {
+		t.Fatalf("Expected 2 affected replicas, got %d: %+v", len(suspects), suspects)
+	}
+	if suspects[0].Target() != "tank/data" || suspects[1].Target() != "tank/data/child@snap1" {
+		t.Errorf("Unexpected replicas: %s, %s", suspects[0].Target(), suspects[1].Target())
+	}
+
+	body := formatSuspects(suspects)
+	if !strings.Contains(body, "tank/data/child@snap1") || !strings.Contains(body, "/file") {
+		t.Errorf("Expected replicas in alert body, got:\n%s", body)
+	}
+}
+
+func TestAffectedReplicasOfJobs(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/data"},
+		Jobs: []config.JobConfig{
+			{Name: "vms", ZFSConfig: config.ZFSConfig{Dataset: "tank/vms"}},
+			{Name: "media", ZFSConfig: config.ZFSConfig{Dataset: "tank/media", Recursive: true}},
+		},
+	}
+	monitor := New(cfg, NewMockAlerter())
+
+	entries := []string{
+		"tank/vms@snap2:/disk.img",
+		"tank/vms/scratch:/ignored",
+		"tank/media/photos:/img.jpg",
+		"tank/other:/ignored",
+	}
+
+	suspects := monitor.affectedReplicas(context.Background(), "tank", entries)
+	if len(suspects) != 2 {
+		t.Fatalf("Expected 2 affected replicas, got %d: %+v", len(suspects), suspects)
+	}
+	if suspects[0].Target() != "tank/media/photos" || suspects[1].Target() != "tank/vms@snap2" {
+		t.Errorf("Unexpected replicas: %s, %s", suspects[0].Target(), suspects[1].Target())
+	}
+}
+
 func TestSendDiskAlert(t *testing.T) {
 	cfg := &config.Config{}
 	alerter := NewMockAlerter()
@@ -925,6 +1018,45 @@ func TestOverallSeverity(t *testing.T) {
 	}
 }
 
+func TestSMARTThresholdOverrides(t *testing.T) {
+	cfg := &config.Config{Monitor: config.MonitorConfig{Thresholds: config.ThresholdsConfig{
+		HDD:   config.SMARTThresholds{TempWarning: 58, TempCritical: 65, TempEmergency: 72},
+		NVMe:  config.SMARTThresholds{SpareWarning: 8, SpareCritical: 4, SpareEmergency: 2},
+		Disks: map[string]config.SMARTThresholds{"ZA1234": {TempWarning: 62}},
+	}}}
+	monitor := New(cfg, nil)
+
+	hot := &SMARTData{Device: "/dev/sda", Healthy: true, Temperature: 60}
+	monitor.checkThresholds(hot)
+	if monitor.getOverallSeverity(hot) != SeverityWarning || len(hot.Errors) != 1 {
+		t.Errorf("Expected a warning at 60°C with hdd.temp_warning 58, got %v %v", monitor.getOverallSeverity(hot), hot.Errors)
+	}
+
+	// The per-disk entry only moves the warning level; the rest comes from hdd
+	override := &SMARTData{Device: "/dev/sdb", Serial: "ZA1234", Healthy: true, Temperature: 60}
+	monitor.checkThresholds(override)
+	if severity := monitor.getOverallSeverity(override); severity != SeverityInfo || len(override.Errors) != 0 {
+		t.Errorf("Expected no alert for ZA1234 at 60°C, got %v %v", severity, override.Errors)
+	}
+	override.Temperature = 66
+	if severity := monitor.getOverallSeverity(override); severity != SeverityCritical {
+		t.Errorf("Expected hdd.temp_critical to still apply to ZA1234, got %v", severity)
+	}
+
+	// Enterprise NVMe with a smaller spare pool
+	nvme := &SMARTData{Device: "/dev/nvme0n1", IsNVMe: true, Healthy: true, Temperature: 40, AvailableSpare: 10, PercentageUsed: 92}
+	monitor.checkThresholds(nvme)
+	if !nvme.Healthy || len(nvme.Errors) != 1 || !strings.Contains(nvme.Errors[0], "wear") {
+		t.Errorf("Expected only the built-in wear warning, got healthy=%v %v", nvme.Healthy, nvme.Errors)
+	}
+	nvme.AvailableSpare = 3
+	nvme.Errors = nil
+	monitor.checkThresholds(nvme)
+	if nvme.Healthy || monitor.getOverallSeverity(nvme) != SeverityCritical {
+		t.Errorf("Expected spare at the critical level to mark the drive unhealthy, got healthy=%v %v", nvme.Healthy, monitor.getOverallSeverity(nvme))
+	}
+}
+
 func TestParseScrubStatus(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -974,3 +1106,745 @@ func TestParseScrubStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestCapacitySeverity(t *testing.T) {
+	cfg := &config.Config{
+		Monitor: config.MonitorConfig{
+			CapacityWarningPercent:  75,
+			CapacityCriticalPercent: 85,
+		},
+	}
+	monitor := New(cfg, NewMockAlerter())
+
+	tests := []struct {
+		percent  int
+		expected AlertSeverity
+	}{
+		{50, SeverityInfo},
+		{75, SeverityWarning},
+		{84, SeverityWarning},
+		{85, SeverityCritical},
+		{99, SeverityCritical},
+	}
+
+	for _, tt := range tests {
+		if severity := monitor.getCapacitySeverity(tt.percent); severity != tt.expected {
+			t.Errorf("Expected severity %v for %d%%, got %v", tt.expected, tt.percent, severity)
+		}
+	}
+}
+
+func TestCapacityAlertEscalation(t *testing.T) {
+	cfg := &config.Config{}
+	alerter := NewMockAlerter()
+	monitor := New(cfg, alerter)
+
+	capacity := &zfs.PoolCapacity{Pool: "tank", Capacity: 82, Health: "ONLINE"}
+
+	monitor.sendCapacityAlert(capacity, SeverityWarning)
+	monitor.sendCapacityAlert(capacity, SeverityWarning)
+	if alerter.GetAlertCount() != 1 {
+		t.Errorf("Expected 1 alert during cooldown, got %d", alerter.GetAlertCount())
+	}
+
+	capacity.Capacity = 95
+	monitor.sendCapacityAlert(capacity, SeverityCritical)
+	if alerter.GetAlertCount() != 2 {
+		t.Errorf("Expected escalation to bypass cooldown, got %d alerts", alerter.GetAlertCount())
+	}
+
+	if !strings.Contains(alerter.GetLastAlert().Subject, "CRITICAL") {
+		t.Errorf("Expected CRITICAL in subject, got: %s", alerter.GetLastAlert().Subject)
+	}
+}
+
+func TestExecuteCheckRecordsStatus(t *testing.T) {
+	cfg := &config.Config{}
+	monitor := New(cfg, NewMockAlerter())
+
+	runner := &checkRunner{
+		status: CheckStatus{Name: "pool:tank", Kind: CheckKindPool, Resource: "tank", Interval: time.Minute, Timeout: 50 * time.Millisecond},
+		run: func(ctx context.Context) error {
+			return nil
+		},
+	}
+	monitor.checks[runner.status.Name] = runner
+
+	monitor.executeCheck(context.Background(), runner)
+
+	statuses := monitor.GetCheckStatuses()
+	if len(statuses) != 1 {
+		t.Fatalf("Expected 1 check status, got %d", len(statuses))
+	}
+	if statuses[0].LastSuccess.IsZero() || statuses[0].LastError != "" {
+		t.Errorf("Expected successful check, got %+v", statuses[0])
+	}
+
+	// A hung check must be cut off by its timeout and recorded as a failure
+	runner.run = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	lastSuccess := statuses[0].LastSuccess
+
+	monitor.executeCheck(context.Background(), runner)
+
+	statuses = monitor.GetCheckStatuses()
+	if statuses[0].LastError == "" {
+		t.Error("Expected timed out check to record an error")
+	}
+	if !statuses[0].LastSuccess.Equal(lastSuccess) {
+		t.Error("Expected last success timestamp to be unchanged after failure")
+	}
+}
+
+// cannedRunner answers zpool and smartctl with canned output. Once block is
+// set, every command hangs until it is closed, like a stuck disk.
+type cannedRunner struct {
+	outputs map[string]string // Keyed by command name
+	block   chan struct{}
+}
+
+func (r *cannedRunner) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func (r *cannedRunner) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+func (r *cannedRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	if r.block != nil {
+		<-r.block
+	}
+	return []byte(r.outputs[cmd.Args[0]]), nil
+}
+
+func (r *cannedRunner) Run(cmd *exec.Cmd) error {
+	_, err := r.Output(cmd)
+	return err
+}
+
+func TestSystemStatusServesCachedResults(t *testing.T) {
+	runner := &cannedRunner{outputs: map[string]string{
+		"zpool": "  pool: tank\n state: ONLINE\n  scan: none requested\nconfig:\n\n\tNAME        STATE     READ WRITE CKSUM\n\ttank        ONLINE       0     0     0\n\t  sda       ONLINE       0     0     0\n\nerrors: No known data errors\n",
+		"smartctl": "SMART overall-health self-assessment test result: PASSED\n\n" +
+			"ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE\n" +
+			"194 Temperature_Celsius     0x0022   067   067   000    Old_age   Always       -       33\n",
+	}}
+	zfs.SetCommandRunner(runner)
+	defer zfs.SetCommandRunner(utils.DefaultRunner)
+
+	monitor := New(&config.Config{}, NewMockAlerter())
+	monitor.SetCommandRunner(runner)
+
+	disk := DiskInfo{ID: "wwn-0x5000c500a1b2c3d4", Device: "/dev/sda", Paths: []string{"/dev/sda"}}
+	checks := make(map[string]*checkRunner)
+	monitor.addCheck(checks, CheckKindPool, "tank", time.Minute, func(ctx context.Context) error {
+		return monitor.checkPoolHealth(ctx, "tank")
+	})
+	monitor.addCheck(checks, CheckKindDisk, disk.ID, time.Minute, func(ctx context.Context) error {
+		return monitor.checkDiskHealth(ctx, disk)
+	})
+	for name, check := range checks {
+		monitor.checks[name] = check
+		monitor.executeCheck(context.Background(), check)
+	}
+
+	// smartctl and zpool hang from now on, with the checks stuck in them
+	runner.block = make(chan struct{})
+	var stuck sync.WaitGroup
+	defer func() {
+		close(runner.block)
+		stuck.Wait()
+	}()
+	for _, check := range checks {
+		stuck.Add(1)
+		go func() {
+			defer stuck.Done()
+			monitor.executeCheck(context.Background(), check)
+		}()
+	}
+
+	done := make(chan map[string]interface{})
+	go func() { done <- monitor.GetSystemStatus() }()
+
+	var status map[string]interface{}
+	select {
+	case status = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetSystemStatus waited for the hung commands")
+	}
+
+	pools := status["pools"].(map[string]interface{})
+	if pool, ok := pools["tank"].(*zfs.PoolStatus); !ok || pool.State != "ONLINE" {
+		t.Errorf("Expected the cached status of tank, got %+v", pools)
+	}
+	disks := status["disks"].(map[string]interface{})
+	if smart, ok := disks[disk.ID].(*SMARTData); !ok || smart.Temperature != 33 {
+		t.Errorf("Expected the cached SMART data of %s, got %+v", disk.ID, disks)
+	}
+}
+
+func TestAlertStatePersistence(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			StateDir: t.TempDir(),
+		},
+	}
+	alerter := NewMockAlerter()
+	monitor := New(cfg, alerter)
+
+	smart := &SMARTData{
+		Device:          "/dev/nvme0n1",
+		IsNVMe:          true,
+		Temperature:     65,
+		CriticalWarning: 2,
+		AvailableSpare:  50,
+		Healthy:         false,
+	}
+	monitor.sendDiskAlert(smart)
+	if alerter.GetAlertCount() != 1 {
+		t.Fatalf("Expected 1 alert, got %d", alerter.GetAlertCount())
+	}
+
+	// A restarted monitor must remember the baseline and stay in cooldown
+	restartedAlerter := NewMockAlerter()
+	restarted := New(cfg, restartedAlerter)
+
+	state, exists := restarted.alertStates["/dev/nvme0n1"]
+	if !exists {
+		t.Fatal("Expected alert state to be restored after restart")
+	}
+	if state.LastTemperature != 65 || state.LastCriticalWarning != 2 {
+		t.Errorf("Restored state mismatch: %+v", state)
+	}
+
+	restarted.sendDiskAlert(smart)
+	if restartedAlerter.GetAlertCount() != 0 {
+		t.Errorf("Expected restored cooldown to suppress duplicate alert, got %d alerts", restartedAlerter.GetAlertCount())
+	}
+}
+
+func TestParseLsblkDisks(t *testing.T) {
+	output := `NAME="sda" WWN="0x5000c500a1b2c3d4" SERIAL="ZA1234" MODEL="ST4000NM0035"
+NAME="sdb" WWN="" SERIAL="WD-123" MODEL="WDC WD40EFRX"
+NAME="nvme0n1" WWN="" SERIAL="" MODEL="Samsung SSD 980"
+NAME="loop0" WWN="" SERIAL="" MODEL=""
+NAME="sr0" WWN="" SERIAL="" MODEL="DVD"`
+
+	byID := map[string]string{
+		"nvme0n1": "/dev/disk/by-id/nvme-eui.0025388b11b2c3d4",
+	}
+
+	disks := parseLsblkDisks(output, byID)
+	if len(disks) != 3 {
+		t.Fatalf("Expected 3 disks, got %d", len(disks))
+	}
+
+	expected := []struct {
+		device string
+		id     string
+	}{
+		{"/dev/sda", "wwn-0x5000c500a1b2c3d4"},
+		{"/dev/sdb", "serial-WD-123"},
+		{"/dev/nvme0n1", "nvme-eui.0025388b11b2c3d4"},
+	}
+
+	for i, exp := range expected {
+		if disks[i].Device != exp.device || disks[i].ID != exp.id {
+			t.Errorf("Disk %d: expected %s/%s, got %s/%s", i, exp.device, exp.id, disks[i].Device, disks[i].ID)
+		}
+	}
+
+	if disks[1].Model != "WDC WD40EFRX" {
+		t.Errorf("Expected model with spaces to be preserved, got %q", disks[1].Model)
+	}
+}
+
+func TestResolveByIDLinks(t *testing.T) {
+	dir := t.TempDir()
+	devDir := filepath.Join(dir, "dev")
+	linkDir := filepath.Join(dir, "by-id")
+	os.MkdirAll(devDir, 0755)
+	os.MkdirAll(linkDir, 0755)
+	os.WriteFile(filepath.Join(devDir, "sda"), nil, 0644)
+	os.WriteFile(filepath.Join(devDir, "sda1"), nil, 0644)
+
+	os.Symlink(filepath.Join(devDir, "sda"), filepath.Join(linkDir, "ata-ST4000_ZA1234"))
+	os.Symlink(filepath.Join(devDir, "sda"), filepath.Join(linkDir, "wwn-0x5000c500a1b2c3d4"))
+	os.Symlink(filepath.Join(devDir, "sda1"), filepath.Join(linkDir, "wwn-0x5000c500a1b2c3d4-part1"))
+
+	original := byIDDir
+	byIDDir = linkDir
+	defer func() { byIDDir = original }()
+
+	links := resolveByIDLinks()
+	if links["sda"] != filepath.Join(linkDir, "wwn-0x5000c500a1b2c3d4") {
+		t.Errorf("Expected wwn link to be preferred, got %q", links["sda"])
+	}
+	if _, exists := links["sda1"]; exists {
+		t.Error("Expected partition links to be ignored")
+	}
+}
+
+func TestDiskAlertStateFollowsStableID(t *testing.T) {
+	cfg := &config.Config{}
+	alerter := NewMockAlerter()
+	monitor := New(cfg, alerter)
+
+	smart := &SMARTData{
+		Device:      "/dev/sda",
+		ID:          "wwn-0x5000c500a1b2c3d4",
+		Temperature: 65,
+		Healthy:     false,
+	}
+	monitor.sendDiskAlert(smart)
+
+	if !strings.Contains(alerter.GetLastAlert().Subject, "/dev/sda (wwn-0x5000c500a1b2c3d4)") {
+		t.Errorf("Expected subject to show kernel name and stable ID, got: %s", alerter.GetLastAlert().Subject)
+	}
+
+	// Same disk after a reboot renamed it to sdb: cooldown must still apply
+	renamed := *smart
+	renamed.Device = "/dev/sdb"
+	monitor.sendDiskAlert(&renamed)
+
+	if alerter.GetAlertCount() != 1 {
+		t.Errorf("Expected renamed disk to share alert state, got %d alerts", alerter.GetAlertCount())
+	}
+}
+
+func TestMergeMultipathDisks(t *testing.T) {
+	output := `NAME="sda" WWN="0x5000c500a1b2c3d4" SERIAL="ZA1234" MODEL="ST4000NM0095" TYPE="disk" TRAN="sas" STATE="offline"
+NAME="sdb" WWN="0x5000c500a1b2c3d4" SERIAL="ZA1234" MODEL="ST4000NM0095" TYPE="disk" TRAN="sas" STATE="running"
+NAME="sdc" WWN="0x5000c500deadbeef" SERIAL="ZA5678" MODEL="ST4000NM0095" TYPE="disk" TRAN="sas" STATE="running"
+NAME="dm-0" WWN="" SERIAL="" MODEL="" TYPE="mpath" TRAN="" STATE="running"`
+
+	disks := mergeMultipathDisks(parseLsblkDisks(output, nil))
+	if len(disks) != 2 {
+		t.Fatalf("Expected 2 physical disks, got %d", len(disks))
+	}
+
+	multipath := disks[0]
+	if !multipath.IsMultipath() || len(multipath.Paths) != 2 {
+		t.Errorf("Expected disk with 2 paths, got %v", multipath.Paths)
+	}
+	if multipath.Device != "/dev/sdb" {
+		t.Errorf("Expected SMART polling through healthy path /dev/sdb, got %s", multipath.Device)
+	}
+	if multipath.HealthyPaths() != 1 || len(multipath.FailedPaths) != 1 || multipath.FailedPaths[0] != "/dev/sda" {
+		t.Errorf("Expected /dev/sda reported as failed path, got %v", multipath.FailedPaths)
+	}
+	if multipath.Transport != "sas" {
+		t.Errorf("Expected sas transport, got %s", multipath.Transport)
+	}
+
+	if disks[1].IsMultipath() {
+		t.Error("Expected single-path disk not to be reported as multipath")
+	}
+}
+
+func TestParseSASSmartctlOutput(t *testing.T) {
+	monitor := New(&config.Config{}, NewMockAlerter())
+
+	output := `=== START OF READ SMART DATA SECTION ===
+SMART Health Status: OK
+
+Current Drive Temperature:     41 C
+Drive Trip Temperature:        60 C
+
+Elements in grown defect list: 12`
+
+	smart := &SMARTData{Device: "/dev/sdb", Healthy: true}
+	monitor.parseSmartctlOutput(smart, output)
+
+	if !smart.Healthy {
+		t.Error("Expected SAS drive with OK status to be healthy")
+	}
+	if smart.Temperature != 41 {
+		t.Errorf("Expected temperature 41, got %d", smart.Temperature)
+	}
+	if len(smart.Errors) != 1 || !strings.Contains(smart.Errors[0], "Grown defect list: 12") {
+		t.Errorf("Expected grown defect error, got %v", smart.Errors)
+	}
+
+	failing := &SMARTData{Device: "/dev/sdc", Healthy: true}
+	monitor.parseSmartctlOutput(failing, "SMART Health Status: FAILURE PREDICTION THRESHOLD EXCEEDED")
+	if failing.Healthy {
+		t.Error("Expected failing SAS health status to mark drive unhealthy")
+	}
+}
+
+func TestPathAlertIsDistinctFromDiskAlert(t *testing.T) {
+	alerter := NewMockAlerter()
+	monitor := New(&config.Config{}, alerter)
+
+	disk := DiskInfo{
+		Device:      "/dev/sdb",
+		ID:          "wwn-0x5000c500a1b2c3d4",
+		Paths:       []string{"/dev/sda", "/dev/sdb"},
+		FailedPaths: []string{"/dev/sda"},
+	}
+
+	monitor.sendPathAlert(disk)
+	if !alerter.HasAlertWithSubject("[WARNING] Disk Path Alert") {
+		t.Errorf("Expected path warning, got %+v", alerter.GetLastAlert())
+	}
+
+	// Losing the last path escalates past the cooldown
+	disk.FailedPaths = []string{"/dev/sda", "/dev/sdb"}
+	monitor.sendPathAlert(disk)
+	if alerter.GetAlertCount() != 2 || !strings.Contains(alerter.GetLastAlert().Subject, "CRITICAL") {
+		t.Errorf("Expected critical path alert after all paths failed, got %d alerts", alerter.GetAlertCount())
+	}
+
+	if err := monitor.checkDiskHealth(context.Background(), disk); err == nil {
+		t.Error("Expected error when no healthy paths remain")
+	}
+	if alerter.HasAlertWithSubject("Disk Health Alert") {
+		t.Error("Path failure must not be reported as a disk health failure")
+	}
+}
+
+func TestScriptCheck(t *testing.T) {
+	alerter := NewMockAlerter()
+	monitor := New(&config.Config{}, alerter)
+
+	passing := config.ScriptCheck{
+		Name:    "passing",
+		Command: "/bin/sh",
+		Args:    []string{"-c", "exit 0"},
+	}
+	if err := monitor.runScriptCheck(context.Background(), passing); err != nil {
+		t.Errorf("Expected passing check to succeed, got %v", err)
+	}
+
+	expectedCode := config.ScriptCheck{
+		Name:             "degraded-ok",
+		Command:          "/bin/sh",
+		Args:             []string{"-c", "exit 3"},
+		ExpectedExitCode: 3,
+	}
+	if err := monitor.runScriptCheck(context.Background(), expectedCode); err != nil {
+		t.Errorf("Expected exit code 3 to match expectation, got %v", err)
+	}
+
+	if alerter.GetAlertCount() != 0 {
+		t.Fatalf("Expected no alerts for passing checks, got %d", alerter.GetAlertCount())
+	}
+
+	failing := config.ScriptCheck{
+		Name:     "nfs-exports",
+		Command:  "/bin/sh",
+		Args:     []string{"-c", "echo export /srv missing; exit 2"},
+		Severity: "critical",
+	}
+	if err := monitor.runScriptCheck(context.Background(), failing); err == nil {
+		t.Error("Expected failing check to return error")
+	}
+
+	lastAlert := alerter.GetLastAlert()
+	if lastAlert == nil || !strings.Contains(lastAlert.Subject, "[CRITICAL] Check Failed: nfs-exports") {
+		t.Fatalf("Expected critical check alert, got %+v", lastAlert)
+	}
+	if !strings.Contains(lastAlert.Body, "export /srv missing") {
+		t.Error("Expected script output in alert body")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	hung := config.ScriptCheck{
+		Name:    "hung",
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 5"},
+	}
+	if err := monitor.runScriptCheck(ctx, hung); err == nil {
+		t.Error("Expected timed out check to fail")
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for name, expected := range map[string]AlertSeverity{
+		"":          SeverityWarning,
+		"warning":   SeverityWarning,
+		"CRITICAL":  SeverityCritical,
+		"emergency": SeverityEmergency,
+	} {
+		severity, err := ParseSeverity(name)
+		if err != nil || severity != expected {
+			t.Errorf("ParseSeverity(%q) = %v, %v; expected %v", name, severity, err, expected)
+		}
+	}
+
+	if _, err := ParseSeverity("loud"); err == nil {
+		t.Error("Expected error for unknown severity")
+	}
+}
+
+func TestScanThrottle(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Monitor.ScanThrottle = config.ScanThrottleConfig{Enabled: true, DiskInterval: time.Hour, SkipSMART: true}
+	monitor := New(cfg, NewMockAlerter())
+
+	disk := &checkRunner{status: CheckStatus{Name: "disk:wwn-1", Kind: CheckKindDisk, Interval: 15 * time.Minute}}
+	pool := &checkRunner{status: CheckStatus{Name: "pool:tank", Kind: CheckKindPool, Interval: 5 * time.Minute}}
+	now := time.Now()
+	disk.status.LastRun = now.Add(-20 * time.Minute)
+	pool.status.LastRun = now.Add(-20 * time.Minute)
+
+	if monitor.deferCheck(disk, now) || monitor.Adaptation().Throttled {
+		t.Fatal("Expected no throttling without a scan")
+	}
+
+	monitor.recordScan("tank", monitor.parseScrubStatus("resilver in progress since Sun Jan  4 02:00:00 2026"))
+	adaptation := monitor.Adaptation()
+	if !adaptation.Throttled || adaptation.Scans["tank"] != "resilver" || !adaptation.SkipSMART {
+		t.Errorf("Expected a resilver to throttle monitoring, got %+v", adaptation)
+	}
+	if !monitor.deferCheck(disk, now) {
+		t.Error("Expected a disk check run 20 minutes ago to be deferred")
+	}
+	if disk.status.Throttled != "resilver of tank in progress" {
+		t.Errorf("Expected the throttling to be noted on the check, got %q", disk.status.Throttled)
+	}
+	if monitor.deferCheck(pool, now) {
+		t.Error("Expected pool checks to keep running during a scan")
+	}
+	if monitor.deferCheck(disk, now.Add(time.Hour)) {
+		t.Error("Expected the disk check to run once the throttled interval passed")
+	}
+
+	monitor.recordScan("tank", monitor.parseScrubStatus("resilvered 1.2T in 05:00:00 with 0 errors on Sun Jan  4 07:00:00 2026"))
+	if monitor.Adaptation().Throttled || monitor.deferCheck(disk, now) || disk.status.Throttled != "" {
+		t.Error("Expected monitoring to return to normal once the resilver finished")
+	}
+
+	cfg.Monitor.ScanThrottle.Enabled = false
+	monitor.recordScan("tank", monitor.parseScrubStatus("scrub in progress since Sun Jan  4 02:00:00 2026"))
+	if monitor.Adaptation().Throttled {
+		t.Error("Expected no throttling when disabled")
+	}
+}
+
+func TestDiskIntervalOverrides(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Monitor.DiskInterval = 15 * time.Minute
+	cfg.Monitor.DiskIntervals = map[string]time.Duration{
+		"wwn-0x5000c500a1b2c3d4": 6 * time.Hour,
+		"ZA1234":                 time.Hour,
+	}
+	monitor := New(cfg, NewMockAlerter())
+
+	tests := []struct {
+		disk DiskInfo
+		want time.Duration
+	}{
+		{DiskInfo{ID: "wwn-0x5000c500a1b2c3d4", Device: "/dev/sda"}, 6 * time.Hour},
+		{DiskInfo{ID: "ata-ST4000-ZA1234", Serial: "ZA1234", Device: "/dev/sdb"}, time.Hour},
+		{DiskInfo{ID: "ata-other", Device: "/dev/sdc"}, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := monitor.diskInterval(tt.disk); got != tt.want {
+			t.Errorf("Expected %s to be checked every %s, got %s", tt.disk.ID, tt.want, got)
+		}
+	}
+}
+
+func TestSMARTPollingLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Monitor.SMARTConcurrency = 2
+	monitor := New(cfg, NewMockAlerter())
+
+	first, err := monitor.acquireSMART(context.Background())
+	if err != nil {
+		t.Fatalf("acquireSMART failed: %v", err)
+	}
+	if _, err := monitor.acquireSMART(context.Background()); err != nil {
+		t.Fatalf("acquireSMART failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := monitor.acquireSMART(ctx); err == nil {
+		t.Fatal("Expected a third poll to wait for a free slot")
+	}
+
+	first()
+	if _, err := monitor.acquireSMART(context.Background()); err != nil {
+		t.Errorf("Expected a released slot to be reusable, got %v", err)
+	}
+}
+
+func TestIsStandby(t *testing.T) {
+	output := "smartctl 7.2 2020-12-30 r5155 [x86_64-linux-5.15.0] (local build)\n\nDevice is in STANDBY mode, exit(2)\n"
+	if !isStandby(output) {
+		t.Error("Expected a spun down disk to be detected")
+	}
+	if isStandby("SMART overall-health self-assessment test result: PASSED\n") {
+		t.Error("Expected an active disk not to be reported as in standby")
+	}
+}
+
+const zpoolEventsOutput = `Oct 16 2026 04:42:51.123456789	ereport.fs.zfs.checksum
+        class = "ereport.fs.zfs.checksum"
+        ena = 0x1c2ab1e2a1f00001
+        detector = (embedded nvlist)
+                version = 0x0
+                scheme = "zfs"
+                pool = 0x3c4e5d6f
+                vdev = 0x9a8b7c6d
+        (end detector)
+        pool = "tank"
+        pool_guid = 0x3c4e5d6f
+        vdev_guid = 0x9a8b7c6d
+        vdev_type = "disk"
+        vdev_path = "/dev/disk/by-id/ata-WDC_WD80EFAX-1-part1"
+        time = 0x68f07a0b 0x75bcd15
+        eid = 0x2a
+
+Oct 16 2026 04:43:02.000000001	resource.fs.zfs.statechange
+        version = 0x0
+        class = "resource.fs.zfs.statechange"
+        pool = "tank"
+        vdev_guid = 0x1234
+        vdev_state = "FAULTED" (0x5)
+        eid = 43
+
+Oct 16 2026 04:43:05.000000000	sysevent.fs.zfs.history_event
+        class = "sysevent.fs.zfs.history_event"
+        pool = "tank"
+        eid = 44
+`
+
+func TestParseEvents(t *testing.T) {
+	var events []ZpoolEvent
+	if err := parseEvents(strings.NewReader(zpoolEventsOutput), func(event ZpoolEvent) {
+		events = append(events, event)
+	}); err != nil {
+		t.Fatalf("parseEvents failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %+v", len(events), events)
+	}
+
+	checksum := events[0]
+	want := time.Date(2026, 10, 16, 4, 42, 51, 123456789, time.Local)
+	if checksum.Class != "ereport.fs.zfs.checksum" || checksum.Pool != "tank" || checksum.EID != 42 ||
+		checksum.Vdev != "/dev/disk/by-id/ata-WDC_WD80EFAX-1-part1" || !checksum.Time.Equal(want) {
+		t.Errorf("Unexpected checksum event, the detector's pool may have leaked in: %+v", checksum)
+	}
+	if events[1].VdevState != "FAULTED" || events[1].Vdev != "0x1234" || events[1].EID != 43 {
+		t.Errorf("Unexpected state change event: %+v", events[1])
+	}
+}
+
+func TestHandleEvent(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Monitor.Events.Cooldown = time.Hour
+	alerter := NewMockAlerter()
+	monitor := New(cfg, alerter)
+
+	checksum := ZpoolEvent{Class: "ereport.fs.zfs.checksum", Pool: "tank", Vdev: "/dev/sda1", Time: time.Now()}
+	for i := 0; i < 3; i++ {
+		monitor.handleEvent(checksum)
+	}
+	if alerter.GetAlertCount() != 1 || !alerter.HasAlertWithSubject("[WARNING] ZFS Event: Checksum error on tank") {
+		t.Fatalf("Expected one alert for a burst of checksum errors, got %+v", alerter.alerts)
+	}
+	if !strings.Contains(alerter.GetLastAlert().Body, "/dev/sda1") || !strings.Contains(alerter.GetLastAlert().Body, "zpool status -v tank") {
+		t.Errorf("Expected the device and runbook in the alert, got %s", alerter.GetLastAlert().Body)
+	}
+
+	checksum.Vdev = "/dev/sdb1"
+	monitor.handleEvent(checksum)
+	monitor.handleEvent(ZpoolEvent{Class: "resource.fs.zfs.statechange", Pool: "tank", VdevState: "ONLINE"})
+	monitor.handleEvent(ZpoolEvent{Class: "sysevent.fs.zfs.history_event", Pool: "tank"})
+	if alerter.GetAlertCount() != 2 {
+		t.Errorf("Expected another device to alert and routine events not to, got %d alerts", alerter.GetAlertCount())
+	}
+
+	for i := 0; i < 2; i++ {
+		monitor.handleEvent(ZpoolEvent{Class: "sysevent.fs.zfs.resilver_start", Pool: "tank"})
+	}
+	monitor.handleEvent(ZpoolEvent{Class: "resource.fs.zfs.removed", Pool: "tank", Vdev: "/dev/sdc1"})
+	if alerter.GetAlertCount() != 5 || !alerter.HasAlertWithSubject("[CRITICAL] ZFS Event: Device removed on tank") {
+		t.Errorf("Expected every resilver and the removal to alert, got %+v", alerter.alerts)
+	}
+}
+
+func TestCompareProperties(t *testing.T) {
+	expected := map[string]string{"compression": "lz4", "atime": "off", "recordsize": "128K", "com.example:owner": "ops"}
+	actual := map[string]string{"compression": "LZ4", "atime": "on", "recordsize": "131072"}
+
+	drifts := compareProperties("tank/data", expected, actual)
+	if len(drifts) != 2 {
+		t.Fatalf("Expected 2 drifted properties, got %+v", drifts)
+	}
+	if drifts[0].Property != "atime" || drifts[0].Actual != "on" || drifts[1].Property != "com.example:owner" || drifts[1].Actual != "-" {
+		t.Errorf("Unexpected drift: %+v", drifts)
+	}
+}
+
+func TestPropertyDriftAlertsOnChange(t *testing.T) {
+	alerter := NewMockAlerter()
+	monitor := New(&config.Config{}, alerter)
+	start := time.Now()
+
+	drift := func(actual string) []PropertyDrift {
+		return []PropertyDrift{{Dataset: "tank/data", Property: "atime", Expected: "off", Actual: actual}}
+	}
+
+	monitor.recordDrift("tank/data", drift("on"), start)
+	monitor.recordDrift("tank/data", drift("on"), start.Add(time.Hour))
+	if alerter.GetAlertCount() != 1 || !alerter.HasAlertWithSubject("ZFS Property Drift: tank/data") {
+		t.Fatalf("Expected one alert for unchanged drift, got %+v", alerter.alerts)
+	}
+	if got := monitor.GetPropertyDrift(); len(got) != 1 || !got[0].Since.Equal(start) {
+		t.Errorf("Expected drift since the first check, got %+v", got)
+	}
+
+	monitor.recordDrift("tank/data", nil, start.Add(2*time.Hour))
+	if len(monitor.GetPropertyDrift()) != 0 {
+		t.Error("Expected drift to clear once properties match")
+	}
+
+	monitor.recordDrift("tank/data", drift("on"), start.Add(3*time.Hour))
+	if alerter.GetAlertCount() != 2 {
+		t.Errorf("Expected drift returning to alert again, got %d alerts", alerter.GetAlertCount())
+	}
+}
+
+func TestSilencedAlerts(t *testing.T) {
+	cfg := &config.Config{}
+	alerter := NewMockAlerter()
+	monitor := New(cfg, alerter)
+
+	silences := silence.New("")
+	monitor.SetSilences(silences)
+	if _, err := silences.Add(silence.ScopeDevice, "sda", time.Hour, "replacing disk", "alice"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	smart := &SMARTData{Device: "/dev/sda", Healthy: false, Temperature: 75}
+	monitor.sendDiskAlert(smart)
+	if alerter.GetAlertCount() != 0 {
+		t.Fatalf("Expected silenced disk alert to be held back, got %d alerts", alerter.GetAlertCount())
+	}
+
+	// Another disk isn't covered
+	monitor.sendDiskAlert(&SMARTData{Device: "/dev/sdb", Healthy: false, Temperature: 75})
+	if alerter.GetAlertCount() != 1 {
+		t.Fatalf("Expected alert for an unsilenced disk, got %d", alerter.GetAlertCount())
+	}
+
+	// Once the silence ends the held back alert goes out, as no cooldown
+	// was started for it
+	for _, s := range silences.Active() {
+		silences.Remove(s.ID)
+	}
+	monitor.sendDiskAlert(smart)
+	if !alerter.HasAlertWithSubject("Disk Health Alert: /dev/sda") {
+		t.Error("Expected disk alert after the silence ended")
+	}
+}
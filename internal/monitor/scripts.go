@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/i18n"
+)
+
+// maxScriptOutput caps how much script output is kept for alerts and status
+const maxScriptOutput = 4096
+
+// ParseSeverity maps a config severity name to an AlertSeverity
+func ParseSeverity(name string) (AlertSeverity, error) {
+	switch strings.ToLower(name) {
+	case "info":
+		return SeverityInfo, nil
+	case "", "warning":
+		return SeverityWarning, nil
+	case "critical":
+		return SeverityCritical, nil
+	case "emergency":
+		return SeverityEmergency, nil
+	default:
+		return SeverityInfo, fmt.Errorf("unknown severity %q", name)
+	}
+}
+
+// runScriptCheck runs a configured external check and alerts when its exit
+// code doesn't match the expected one
+func (m *Monitor) runScriptCheck(ctx context.Context, check config.ScriptCheck) error {
+	cmd := m.commands.CommandContext(ctx, check.Command, check.Args...)
+	// Run in its own process group so a timeout also kills anything the script spawned
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	runErr := m.commands.Run(cmd)
+
+	exitCode := 0
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) || ctx.Err() != nil {
+			// Could not start or was killed by the timeout
+			m.sendScriptAlert(check, -1, runErr.Error())
+			return fmt.Errorf("script check %s failed to run: %w", check.Name, runErr)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	if exitCode != check.ExpectedExitCode {
+		text := truncateOutput(output.String())
+		m.sendScriptAlert(check, exitCode, text)
+		return fmt.Errorf("script check %s exited with %d (expected %d): %s", check.Name, exitCode, check.ExpectedExitCode, text)
+	}
+
+	return nil
+}
+
+func (m *Monitor) sendScriptAlert(check config.ScriptCheck, exitCode int, output string) {
+	severity, err := ParseSeverity(check.Severity)
+	if err != nil {
+		severity = SeverityWarning
+	}
+
+	alertKey := fmt.Sprintf("script_%s", check.Name)
+	if !m.severityAlertDue(alertKey, severity) {
+		return
+	}
+
+	subject := fmt.Sprintf("[%s] Check Failed: %s", severity.String(), check.Name)
+	body := i18n.T("alert.check.body", severity.String(), check.Name, check.Command, strings.Join(check.Args, " "), exitCode, check.ExpectedExitCode)
+
+	if output != "" {
+		body += i18n.T("alert.check.output", output)
+	}
+
+	if m.silenced(subject, "") {
+		return
+	}
+	if err := m.alerter.SendAlert(subject, body); err != nil {
+		logger.Error("Failed to send check alert", "check", check.Name, "err", err)
+		return
+	}
+
+	m.recordSeverityAlert(alertKey, severity)
+	logger.Info("Sent alert for failed check", "severity", severity.String(), "check", check.Name)
+}
+
+func truncateOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxScriptOutput {
+		return output[:maxScriptOutput] + "... (truncated)"
+	}
+	return output
+}
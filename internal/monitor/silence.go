@@ -0,0 +1,34 @@
+package monitor
+
+import (
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/silence"
+)
+
+// SetSilences makes the monitor hold back alerts covered by an active silence
+func (m *Monitor) SetSilences(store *silence.Store) {
+	m.silences = store
+}
+
+// silenced reports whether an alert about pool and devices is covered by an
+// active silence. Silenced alerts aren't recorded as sent, so a problem that
+// outlasts the silence is alerted on once it ends.
+func (m *Monitor) silenced(subject, pool string, devices ...string) bool {
+	if m.silences == nil {
+		return false
+	}
+	s := m.silences.Match(pool, devices...)
+	if s == nil {
+		return false
+	}
+	logger.Info("Alert silenced", "subject", subject, "silence", s.ID, "scope", s.Describe(), "until", s.Expires.Format(time.RFC3339))
+	return true
+}
+
+// datasetPool returns the pool a dataset belongs to
+func datasetPool(dataset string) string {
+	pool, _, _ := strings.Cut(dataset, "/")
+	return pool
+}
@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"zfsrabbit/internal/alert"
+	"zfsrabbit/internal/state"
+	"zfsrabbit/internal/utils"
+)
+
+// persistedState is the on-disk form of the monitor baselines
+type persistedState struct {
+	AlertStates   map[string]*AlertState `json:"alert_states"`
+	SlackIncident *alert.SlackIncident   `json:"slack_incident,omitempty"`
+}
+
+func (m *Monitor) statePath() string {
+	return state.PathIn(m.config.Server.StateDir, state.MonitorFile)
+}
+
+// loadAlertStates restores alert baselines saved by a previous run so a
+// restart doesn't re-fire alerts or lose escalation context
+func (m *Monitor) loadAlertStates() {
+	path := m.statePath()
+	if path == "" {
+		return
+	}
+
+	var saved persistedState
+	if err := utils.ReadJSONFile(path, &saved); err != nil {
+		logger.Error("Failed to load monitor state", "path", path, "err", err)
+		return
+	}
+
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	for key, alertState := range saved.AlertStates {
+		if alertState != nil {
+			m.alertStates[key] = alertState
+		}
+	}
+	m.slackIncident = saved.SlackIncident
+
+	if len(saved.AlertStates) > 0 {
+		logger.Info("Restored monitor alert baselines", "count", len(saved.AlertStates), "path", path)
+	}
+}
+
+// saveAlertStatesLocked writes the alert baselines to the state directory.
+// Caller must hold stateMutex.
+func (m *Monitor) saveAlertStatesLocked() {
+	path := m.statePath()
+	if path == "" {
+		return
+	}
+
+	if err := utils.WriteJSONAtomic(path, persistedState{AlertStates: m.alertStates, SlackIncident: m.slackIncident}, 0600); err != nil {
+		logger.Error("Failed to save monitor state", "path", path, "err", err)
+	}
+}
+
+// SlackIncident returns the open Slack alert thread saved by SetSlackIncident,
+// making the monitor state an alert.IncidentStore
+func (m *Monitor) SlackIncident() *alert.SlackIncident {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	return m.slackIncident
+}
+
+// SetSlackIncident records the open Slack alert thread in the monitor state
+func (m *Monitor) SetSlackIncident(incident *alert.SlackIncident) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	m.slackIncident = incident
+	m.saveAlertStatesLocked()
+}
@@ -0,0 +1,106 @@
+package monitor
+
+import (
+	"fmt"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+)
+
+// Built-in SMART levels, used for anything monitor.thresholds leaves unset.
+// NVMe drives run hotter than HDDs and SATA SSDs, so their temperature
+// levels are higher.
+var (
+	defaultHDDThresholds = config.SMARTThresholds{
+		TempWarning:   50,
+		TempCritical:  60,
+		TempEmergency: 70,
+	}
+	defaultNVMeThresholds = config.SMARTThresholds{
+		TempWarning:    60,
+		TempCritical:   70,
+		TempEmergency:  80,
+		WearWarning:    90,
+		WearCritical:   95,
+		WearEmergency:  100,
+		SpareWarning:   20,
+		SpareCritical:  10,
+		SpareEmergency: 5,
+	}
+)
+
+// classThresholds returns the configured levels for a device class
+func (m *Monitor) classThresholds(isNVMe bool) config.SMARTThresholds {
+	if isNVMe {
+		return defaultNVMeThresholds.Merge(m.config.Monitor.Thresholds.NVMe)
+	}
+	return defaultHDDThresholds.Merge(m.config.Monitor.Thresholds.HDD)
+}
+
+// diskThresholds returns the levels for a disk: its class's, overridden by
+// a monitor.thresholds.disks entry for its ID, serial, by-id path or device
+func (m *Monitor) diskThresholds(smart *SMARTData) config.SMARTThresholds {
+	limits := m.classThresholds(smart.IsNVMe)
+	for _, key := range []string{smart.ID, smart.Serial, smart.ByIDPath, smart.Device} {
+		if override, ok := m.config.Monitor.Thresholds.Disks[key]; ok && key != "" {
+			return limits.Merge(override)
+		}
+	}
+	return limits
+}
+
+// checkThresholds adds an error for each reading at or past its warning
+// level, which is what gets a disk alerted. An NVMe drive whose spare has
+// fallen to the critical level is also marked unhealthy.
+func (m *Monitor) checkThresholds(smart *SMARTData) {
+	limits := m.diskThresholds(smart)
+
+	if smart.Temperature > 0 && risingSeverity(smart.Temperature, limits.TempWarning, limits.TempCritical, limits.TempEmergency) > SeverityInfo {
+		smart.Errors = append(smart.Errors, "High temperature: "+display.Temperature(smart.Temperature))
+	}
+
+	if !smart.IsNVMe {
+		return
+	}
+
+	if risingSeverity(smart.PercentageUsed, limits.WearWarning, limits.WearCritical, limits.WearEmergency) > SeverityInfo {
+		smart.Errors = append(smart.Errors, fmt.Sprintf("High wear level: %d%%", smart.PercentageUsed))
+	}
+
+	if severity := fallingSeverity(smart.AvailableSpare, limits.SpareWarning, limits.SpareCritical, limits.SpareEmergency); severity > SeverityInfo {
+		smart.Errors = append(smart.Errors, fmt.Sprintf("Low spare capacity: %d%%", smart.AvailableSpare))
+		if severity >= SeverityCritical {
+			smart.Healthy = false
+		}
+	}
+}
+
+// risingSeverity grades a reading that gets worse as it rises, such as
+// temperature or wear. A zero level is never reached.
+func risingSeverity(value, warning, critical, emergency int) AlertSeverity {
+	switch {
+	case emergency > 0 && value >= emergency:
+		return SeverityEmergency
+	case critical > 0 && value >= critical:
+		return SeverityCritical
+	case warning > 0 && value >= warning:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// fallingSeverity grades a reading that gets worse as it falls, such as
+// available spare
+func fallingSeverity(value, warning, critical, emergency int) AlertSeverity {
+	switch {
+	case value <= emergency:
+		return SeverityEmergency
+	case value <= critical:
+		return SeverityCritical
+	case value <= warning:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Adaptation reports how monitoring is adapted to pool activity. While any
+// pool is resilvering or scrubbing, disk checks run at the throttled interval
+// and may skip SMART reads, so monitoring doesn't add IO to busy disks.
+type Adaptation struct {
+	Throttled    bool              `json:"throttled"`
+	Reason       string            `json:"reason,omitempty"`
+	Scans        map[string]string `json:"scans,omitempty"` // Pool -> "scrub" or "resilver"
+	DiskInterval time.Duration     `json:"disk_interval,omitempty"`
+	SkipSMART    bool              `json:"skip_smart,omitempty"`
+}
+
+// recordScan remembers whether a pool has a scrub or resilver running, as
+// seen by its latest pool check
+func (m *Monitor) recordScan(pool string, scrub ScrubStatus) {
+	m.scanMutex.Lock()
+	defer m.scanMutex.Unlock()
+
+	if !scrub.InProgress {
+		if kind, ok := m.scans[pool]; ok {
+			logger.Info("Pool operation finished, resuming normal disk monitoring", "operation", kind, "pool", pool)
+			delete(m.scans, pool)
+		}
+		return
+	}
+
+	kind := "scrub"
+	if strings.Contains(scrub.Status, "resilver") {
+		kind = "resilver"
+	}
+	if m.scans[pool] != kind && m.config.Monitor.ScanThrottle.Enabled {
+		logger.Info("Pool operation in progress, throttling disk monitoring", "operation", kind, "pool", pool)
+	}
+	m.scans[pool] = kind
+}
+
+// Adaptation returns the current monitoring adaptation
+func (m *Monitor) Adaptation() Adaptation {
+	m.scanMutex.Lock()
+	defer m.scanMutex.Unlock()
+
+	throttle := m.config.Monitor.ScanThrottle
+	if !throttle.Enabled || len(m.scans) == 0 {
+		return Adaptation{}
+	}
+
+	adaptation := Adaptation{
+		Throttled:    true,
+		Scans:        make(map[string]string, len(m.scans)),
+		DiskInterval: throttle.DiskInterval,
+		SkipSMART:    throttle.SkipSMART,
+	}
+	var reasons []string
+	for pool, kind := range m.scans {
+		adaptation.Scans[pool] = kind
+		reasons = append(reasons, fmt.Sprintf("%s of %s", kind, pool))
+	}
+	sort.Strings(reasons)
+	adaptation.Reason = strings.Join(reasons, ", ") + " in progress"
+	return adaptation
+}
+
+// deferCheck reports whether a disk check should be skipped this tick
+// because a scan is running and the check already ran within the throttled
+// interval. The check's status notes the throttling either way.
+func (m *Monitor) deferCheck(runner *checkRunner, now time.Time) bool {
+	if runner.status.Kind != CheckKindDisk {
+		return false
+	}
+	adaptation := m.Adaptation()
+
+	m.checksMutex.Lock()
+	defer m.checksMutex.Unlock()
+
+	runner.status.Throttled = adaptation.Reason
+	return adaptation.Throttled && now.Sub(runner.status.LastRun) < adaptation.DiskInterval
+}
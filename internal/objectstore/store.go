@@ -0,0 +1,389 @@
+// Package objectstore keeps zfs send streams in S3-compatible object storage.
+// A stream is split into chunks that are compressed, optionally encrypted
+// with AES-256-GCM and uploaded as separate objects. A manifest written after
+// the last chunk lists them, so a stream without one was never completed.
+package objectstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+const (
+	manifestName       = "manifest.json"
+	CompressionGzip    = "gzip"
+	CompressionNone    = "none"
+	defaultChunkSize   = 64 << 20
+	encryptionKeyBytes = 32
+)
+
+// Manifest describes one stored send stream
+type Manifest struct {
+	Dataset      string    `json:"dataset"`
+	Snapshot     string    `json:"snapshot"`
+	Base         string    `json:"base,omitempty"` // Incremental from this snapshot; a full stream if empty
+	Created      time.Time `json:"created"`
+	Compression  string    `json:"compression"`
+	Encrypted    bool      `json:"encrypted"`
+	StreamBytes  int64     `json:"stream_bytes"`  // Size of the zfs send stream
+	StreamSHA256 string    `json:"stream_sha256"` // Checksum of the zfs send stream
+	Chunks       []Chunk   `json:"chunks"`
+}
+
+// Chunk is one object of a stream
+type Chunk struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`   // Stored size, after compression and encryption
+	SHA256 string `json:"sha256"` // Checksum of the stored object
+}
+
+// Store uploads and downloads send streams under a key prefix in a bucket
+type Store struct {
+	client      *Client
+	prefix      string
+	chunkSize   int64
+	compression string
+	aead        cipher.AEAD // nil when encryption is off
+}
+
+// New creates a store from the s3 config section
+func New(cfg *config.S3Config) (*Store, error) {
+	accessKey, secretKey := cfg.AccessKey, cfg.SecretKey
+	if accessKey == "" {
+		accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if secretKey == "" {
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3 credentials missing: set access_key and secret_key or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	client, err := NewClient(cfg.Endpoint, cfg.Region, cfg.Bucket, accessKey, secretKey, cfg.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize, err := config.ParseRate(cfg.ChunkSize)
+	if err != nil {
+		return nil, fmt.Errorf("chunk_size: %w", err)
+	}
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	store := &Store{
+		client:      client,
+		prefix:      strings.Trim(cfg.Prefix, "/"),
+		chunkSize:   chunkSize,
+		compression: cfg.Compression,
+	}
+	if store.compression == "" {
+		store.compression = CompressionGzip
+	}
+
+	if cfg.EncryptionKeyFile != "" {
+		key, err := readKey(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if store.aead, err = newAEAD(key); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// readKey loads a 256-bit key written as 64 hex characters, e.g. by
+// `openssl rand -hex 32`
+func readKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != encryptionKeyBytes {
+		return nil, fmt.Errorf("encryption key %s must hold 64 hex characters (openssl rand -hex 32)", path)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamPrefix is the key prefix of one snapshot's objects
+func (s *Store) streamPrefix(dataset, snapshot string) string {
+	return s.datasetPrefix(dataset) + snapshot + "/"
+}
+
+func (s *Store) datasetPrefix(dataset string) string {
+	if s.prefix == "" {
+		return dataset + "/"
+	}
+	return s.prefix + "/" + dataset + "/"
+}
+
+// Upload stores the send stream read from r as snapshot of dataset,
+// incremental from base unless base is empty. Chunks of a failed upload are
+// removed again.
+func (s *Store) Upload(ctx context.Context, dataset, snapshot, base string, r io.Reader) (_ *Manifest, err error) {
+	manifest := &Manifest{
+		Dataset:     dataset,
+		Snapshot:    snapshot,
+		Base:        base,
+		Created:     time.Now().UTC(),
+		Compression: s.compression,
+		Encrypted:   s.aead != nil,
+	}
+	prefix := s.streamPrefix(dataset, snapshot)
+	defer func() {
+		if err != nil {
+			for _, chunk := range manifest.Chunks {
+				s.client.Delete(context.WithoutCancel(ctx), chunk.Key)
+			}
+		}
+	}()
+
+	streamHash := sha256.New()
+	buf := make([]byte, s.chunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			streamHash.Write(buf[:n])
+			manifest.StreamBytes += int64(n)
+
+			object, sealErr := s.seal(buf[:n])
+			if sealErr != nil {
+				return nil, sealErr
+			}
+			key := fmt.Sprintf("%schunk-%06d", prefix, len(manifest.Chunks)+1)
+			if err := s.client.Put(ctx, key, object); err != nil {
+				return nil, fmt.Errorf("failed to upload chunk %d: %w", len(manifest.Chunks)+1, err)
+			}
+			sum := sha256.Sum256(object)
+			manifest.Chunks = append(manifest.Chunks, Chunk{Key: key, Size: int64(len(object)), SHA256: hex.EncodeToString(sum[:])})
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read send stream: %w", readErr)
+		}
+	}
+	manifest.StreamSHA256 = hex.EncodeToString(streamHash.Sum(nil))
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.Put(ctx, prefix+manifestName, data); err != nil {
+		return nil, fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// Download writes the send stream of manifest to w, verifying every chunk
+// and the reassembled stream
+func (s *Store) Download(ctx context.Context, manifest *Manifest, w io.Writer) error {
+	if manifest.Encrypted && s.aead == nil {
+		return fmt.Errorf("%s@%s is encrypted, set s3.encryption_key_file", manifest.Dataset, manifest.Snapshot)
+	}
+
+	streamHash := sha256.New()
+	out := io.MultiWriter(w, streamHash)
+	for i, chunk := range manifest.Chunks {
+		if err := s.downloadChunk(ctx, manifest, chunk, out); err != nil {
+			return fmt.Errorf("chunk %d of %s@%s: %w", i+1, manifest.Dataset, manifest.Snapshot, err)
+		}
+	}
+
+	if sum := hex.EncodeToString(streamHash.Sum(nil)); sum != manifest.StreamSHA256 {
+		return fmt.Errorf("%s@%s: reassembled stream checksum %s does not match manifest %s",
+			manifest.Dataset, manifest.Snapshot, sum, manifest.StreamSHA256)
+	}
+	return nil
+}
+
+func (s *Store) downloadChunk(ctx context.Context, manifest *Manifest, chunk Chunk, w io.Writer) error {
+	body, err := s.client.Get(ctx, chunk.Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	object, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(object); hex.EncodeToString(sum[:]) != chunk.SHA256 {
+		return fmt.Errorf("checksum mismatch, the object is corrupt")
+	}
+
+	data, err := s.open(object, manifest)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Delete removes a stored stream, its manifest first so a partly deleted
+// stream is never mistaken for a complete one
+func (s *Store) Delete(ctx context.Context, manifest *Manifest) error {
+	if err := s.client.Delete(ctx, s.streamPrefix(manifest.Dataset, manifest.Snapshot)+manifestName); err != nil {
+		return err
+	}
+	for _, chunk := range manifest.Chunks {
+		if err := s.client.Delete(ctx, chunk.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seal compresses and encrypts a chunk for upload
+func (s *Store) seal(data []byte) ([]byte, error) {
+	if s.compression == CompressionGzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	} else {
+		data = bytes.Clone(data)
+	}
+
+	if s.aead == nil {
+		return data, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// open reverses seal with the settings recorded in the manifest
+func (s *Store) open(object []byte, manifest *Manifest) ([]byte, error) {
+	data := object
+	if manifest.Encrypted {
+		nonceSize := s.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, fmt.Errorf("encrypted chunk too short")
+		}
+		var err error
+		if data, err = s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil); err != nil {
+			return nil, fmt.Errorf("failed to decrypt, wrong encryption key?")
+		}
+	}
+
+	if manifest.Compression == CompressionGzip {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	}
+	return data, nil
+}
+
+// Manifests returns the completed streams of dataset, oldest first
+func (s *Store) Manifests(ctx context.Context, dataset string) ([]*Manifest, error) {
+	keys, err := s.client.List(ctx, s.datasetPrefix(dataset))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []*Manifest
+	for _, key := range keys {
+		rest := strings.TrimPrefix(key, s.datasetPrefix(dataset))
+		// Only the dataset's own snapshots, not those of child datasets
+		if strings.Count(rest, "/") != 1 || !strings.HasSuffix(rest, "/"+manifestName) {
+			continue
+		}
+		manifest, err := s.readManifest(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Created.Before(manifests[j].Created) })
+	return manifests, nil
+}
+
+func (s *Store) readManifest(ctx context.Context, key string) (*Manifest, error) {
+	body, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// Chain returns the streams to receive, in order, to restore snapshot of
+// dataset: a full stream followed by the incrementals leading to it
+func (s *Store) Chain(ctx context.Context, dataset, snapshot string) ([]*Manifest, error) {
+	manifests, err := s.Manifests(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	return chain(manifests, snapshot)
+}
+
+func chain(manifests []*Manifest, snapshot string) ([]*Manifest, error) {
+	bySnapshot := make(map[string]*Manifest, len(manifests))
+	for _, m := range manifests {
+		bySnapshot[m.Snapshot] = m
+	}
+
+	var streams []*Manifest
+	for name := snapshot; ; {
+		m, ok := bySnapshot[name]
+		if !ok {
+			if len(streams) == 0 {
+				return nil, fmt.Errorf("snapshot %s is not in object storage", snapshot)
+			}
+			return nil, fmt.Errorf("snapshot %s needs %s, which is not in object storage", snapshot, name)
+		}
+		streams = append([]*Manifest{m}, streams...)
+		if m.Base == "" {
+			return streams, nil
+		}
+		if len(streams) > len(manifests) {
+			return nil, errors.New("manifests form a loop")
+		}
+		name = m.Base
+	}
+}
@@ -0,0 +1,173 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"zfsrabbit/internal/config"
+)
+
+// fakeS3 is an in-memory bucket speaking enough of the S3 API for the store
+type fakeS3 struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		type content struct{ Key string }
+		var result struct {
+			XMLName  xml.Name  `xml:"ListBucketResult"`
+			Contents []content `xml:"Contents"`
+		}
+		for k := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				result.Contents = append(result.Contents, content{Key: k})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+	}
+}
+
+func newTestStore(t *testing.T, cfg config.S3Config) (*Store, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg.Endpoint = server.URL
+	cfg.Bucket = "bucket"
+	cfg.Region = "us-east-1"
+	cfg.AccessKey = "AKID"
+	cfg.SecretKey = "secret"
+	cfg.PathStyle = true
+	store, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return store, fake
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "s3.key")
+	os.WriteFile(keyFile, []byte(strings.Repeat("ab", 32)+"\n"), 0600)
+
+	for _, cfg := range []config.S3Config{
+		{Prefix: "zfsrabbit", ChunkSize: "1M", Compression: "gzip"},
+		{Prefix: "zfsrabbit", ChunkSize: "1M", Compression: "none", EncryptionKeyFile: keyFile},
+	} {
+		store, fake := newTestStore(t, cfg)
+
+		stream := make([]byte, 2<<20+12345)
+		rand.New(rand.NewSource(1)).Read(stream[:1<<20]) // Half random, half compressible
+
+		ctx := context.Background()
+		full, err := store.Upload(ctx, "tank/data", "autosnap_1", "", bytes.NewReader(stream))
+		if err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+		if len(full.Chunks) != 3 || full.StreamBytes != int64(len(stream)) {
+			t.Fatalf("Expected 3 chunks of %d bytes, got %d chunks of %d", len(stream), len(full.Chunks), full.StreamBytes)
+		}
+		if _, err := store.Upload(ctx, "tank/data", "autosnap_2", "autosnap_1", strings.NewReader("incremental")); err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+		// A child dataset's streams are not the parent's
+		if _, err := store.Upload(ctx, "tank/data/child", "autosnap_1", "", strings.NewReader("child")); err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+
+		if cfg.EncryptionKeyFile != "" {
+			for key, object := range fake.objects {
+				if strings.HasSuffix(key, "chunk-000003") && bytes.Contains(object, stream[len(stream)-100:]) {
+					t.Error("Expected encrypted chunks not to contain the stream")
+				}
+			}
+		}
+
+		chain, err := store.Chain(ctx, "tank/data", "autosnap_2")
+		if err != nil {
+			t.Fatalf("Chain: %v", err)
+		}
+		if len(chain) != 2 || chain[0].Snapshot != "autosnap_1" || chain[1].Snapshot != "autosnap_2" {
+			t.Fatalf("Expected the full stream then the incremental, got %+v", chain)
+		}
+
+		var restored bytes.Buffer
+		if err := store.Download(ctx, chain[0], &restored); err != nil {
+			t.Fatalf("Download: %v", err)
+		}
+		if !bytes.Equal(restored.Bytes(), stream) {
+			t.Error("Downloaded stream differs from the upload")
+		}
+
+		// A damaged object is caught rather than fed to zfs receive
+		fake.objects[full.Chunks[1].Key][10] ^= 0xff
+		if err := store.Download(ctx, full, io.Discard); err == nil || !strings.Contains(err.Error(), "corrupt") {
+			t.Errorf("Expected a corrupt chunk to fail the download, got %v", err)
+		}
+	}
+}
+
+func TestChainNeedsEveryBase(t *testing.T) {
+	manifests := []*Manifest{
+		{Snapshot: "a"},
+		{Snapshot: "c", Base: "b"},
+	}
+	if _, err := chain(manifests, "c"); err == nil || !strings.Contains(err.Error(), "needs b") {
+		t.Errorf("Expected a missing base to be reported, got %v", err)
+	}
+	if _, err := chain(manifests, "z"); err == nil {
+		t.Error("Expected an unknown snapshot to be reported")
+	}
+}
+
+func TestUploadFailureRemovesChunks(t *testing.T) {
+	store, fake := newTestStore(t, config.S3Config{ChunkSize: "1M", Compression: "none"})
+
+	failing := io.MultiReader(bytes.NewReader(make([]byte, 1<<20+1)), errReader{})
+	if _, err := store.Upload(context.Background(), "tank/data", "autosnap_1", "", failing); err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("Expected the uploaded chunks to be removed, found %d objects", len(fake.objects))
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, io.ErrClosedPipe }
@@ -0,0 +1,154 @@
+// Package orphan finds zfs send and receive pipelines left running by a
+// zfsrabbit daemon that has since died. Every process the daemon starts for
+// a transfer is tagged with the daemon's PID in its environment, so on the
+// next start the survivors can be told apart from transfers run by hand.
+package orphan
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ownerEnv is the environment variable holding the PID of the daemon that
+// started a process
+const ownerEnv = "ZFSRABBIT_PID"
+
+// DefaultGrace is how long Stop waits after SIGTERM before sending SIGKILL
+const DefaultGrace = 10 * time.Second
+
+// Process is a tagged process whose daemon is gone
+type Process struct {
+	PID     int    `json:"pid"`
+	Owner   int    `json:"owner"` // PID of the daemon that started it
+	Command string `json:"command"`
+}
+
+// Mark tags cmd as started by this daemon; it must be called before cmd starts
+func Mark(cmd *exec.Cmd) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", ownerEnv, os.Getpid()))
+}
+
+// Find scans procDir (normally /proc) for tagged processes whose daemon is
+// no longer running. Processes that can't be read, typically because they
+// exited during the scan, are skipped.
+func Find(procDir string) ([]Process, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var orphans []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		environ, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "environ"))
+		if err != nil {
+			continue
+		}
+		owner := ownerOf(environ)
+		if owner == 0 || owner == self || alive(owner) {
+			continue
+		}
+
+		cmdline, _ := os.ReadFile(filepath.Join(procDir, entry.Name(), "cmdline"))
+		orphans = append(orphans, Process{
+			PID:     pid,
+			Owner:   owner,
+			Command: strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " ")),
+		})
+	}
+
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].PID < orphans[j].PID })
+	return orphans, nil
+}
+
+// ownerOf returns the daemon PID in a NUL-separated environment, or 0
+func ownerOf(environ []byte) int {
+	for _, variable := range strings.Split(string(environ), "\x00") {
+		if value, ok := strings.CutPrefix(variable, ownerEnv+"="); ok {
+			pid, _ := strconv.Atoi(value)
+			return pid
+		}
+	}
+	return 0
+}
+
+// alive reports whether a process with pid exists
+func alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Stop sends SIGTERM to each process and SIGKILL to any still running after
+// grace. It returns how many processes are gone.
+func Stop(processes []Process, grace time.Duration) int {
+	for _, process := range processes {
+		if err := syscall.Kill(process.PID, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+			log.Printf("Failed to stop orphaned process %d: %v", process.PID, err)
+		}
+	}
+
+	deadline := time.Now().Add(grace)
+	for {
+		running := 0
+		for _, process := range processes {
+			if alive(process.PID) {
+				running++
+			}
+		}
+		if running == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	stopped := 0
+	for _, process := range processes {
+		if alive(process.PID) {
+			log.Printf("Orphaned process %d ignored SIGTERM, killing it", process.PID)
+			if err := syscall.Kill(process.PID, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+				log.Printf("Failed to kill orphaned process %d: %v", process.PID, err)
+				continue
+			}
+		}
+		stopped++
+	}
+	return stopped
+}
+
+// Cleanup stops every orphaned transfer process under /proc, so a receive
+// left running by a crash doesn't hold its dataset busy. It returns the
+// processes it found.
+func Cleanup() []Process {
+	orphans, err := Find("/proc")
+	if err != nil {
+		log.Printf("Failed to look for orphaned transfer processes: %v", err)
+		return nil
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	for _, process := range orphans {
+		log.Printf("Found orphaned process %d started by zfsrabbit %d: %s", process.PID, process.Owner, process.Command)
+	}
+	stopped := Stop(orphans, DefaultGrace)
+	log.Printf("Stopped %d of %d orphaned transfer processes", stopped, len(orphans))
+	return orphans
+}
@@ -0,0 +1,81 @@
+package orphan
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// deadPID returns a PID with no process behind it
+func deadPID(t *testing.T) int {
+	for pid := 4194000; pid > 4190000; pid-- {
+		if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+			return pid
+		}
+	}
+	t.Skip("No free PID found")
+	return 0
+}
+
+func writeProc(t *testing.T, dir string, pid int, environ, cmdline string) {
+	procDir := filepath.Join(dir, fmt.Sprint(pid))
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(procDir, "environ"), []byte(environ), 0644)
+	os.WriteFile(filepath.Join(procDir, "cmdline"), []byte(cmdline), 0644)
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	dead := deadPID(t)
+
+	writeProc(t, dir, 101, fmt.Sprintf("PATH=/bin\x00ZFSRABBIT_PID=%d\x00", dead), "zfs\x00send\x00-I\x00tank@a\x00tank@b\x00")
+	writeProc(t, dir, 102, fmt.Sprintf("ZFSRABBIT_PID=%d\x00", os.Getpid()), "zfs\x00send\x00tank@c\x00")
+	writeProc(t, dir, 103, fmt.Sprintf("ZFSRABBIT_PID=%d\x00", os.Getppid()), "mbuffer\x00")
+	writeProc(t, dir, 104, "PATH=/bin\x00", "zfs\x00receive\x00tank/manual\x00")
+	os.MkdirAll(filepath.Join(dir, "self"), 0755)
+
+	orphans, err := Find(dir)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	want := []Process{{PID: 101, Owner: dead, Command: "zfs send -I tank@a tank@b"}}
+	if !slices.Equal(orphans, want) {
+		t.Errorf("Expected only the process of the dead daemon, got %+v", orphans)
+	}
+}
+
+func TestMark(t *testing.T) {
+	cmd := exec.Command("true")
+	Mark(cmd)
+	if ownerOf([]byte(cmd.Env[len(cmd.Env)-1])) != os.Getpid() {
+		t.Errorf("Expected the command to carry this process's PID, got %v", cmd.Env[len(cmd.Env)-1])
+	}
+}
+
+func TestStop(t *testing.T) {
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("Cannot start sleep: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	if stopped := Stop([]Process{{PID: cmd.Process.Pid}}, 5*time.Second); stopped != 1 {
+		t.Errorf("Expected the process to be stopped, got %d", stopped)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the process to have exited")
+	}
+}
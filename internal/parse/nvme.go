@@ -0,0 +1,96 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NVMeHealth is the health information from nvme smart-log
+type NVMeHealth struct {
+	CriticalWarning  int // Bit field; any bit set means the drive is in trouble
+	Temperature      int // Celsius, 0 if not reported
+	PercentageUsed   int // Wear level, can pass 100
+	AvailableSpare   int // Percent
+	DataUnitsWritten uint64
+}
+
+// NVMeSmartLog parses nvme smart-log output. Labels are matched by name, so
+// nvme-cli 1.x "critical_warning : 0" and 2.x "Critical Warning: 0x4" both
+// work, as do temperatures given as "35 C", "35°C (308 K)" or "308 Kelvin".
+func NVMeSmartLog(output string) NVMeHealth {
+	var log NVMeHealth
+	for _, line := range strings.Split(output, "\n") {
+		label, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(label)), " ", "_") {
+		case "critical_warning":
+			if warning, err := strconv.ParseInt(firstField(value), 0, 32); err == nil && warning >= 0 {
+				log.CriticalWarning = int(warning)
+			}
+		case "temperature", "composite_temperature":
+			if temp, ok := celsius(value); ok {
+				log.Temperature = temp
+			}
+		case "percentage_used":
+			if used, ok := leadingInt(value); ok {
+				log.PercentageUsed = used
+			}
+		case "available_spare":
+			if spare, ok := leadingInt(value); ok {
+				log.AvailableSpare = spare
+			}
+		case "data_units_written":
+			number := strings.ReplaceAll(firstField(value), ",", "")
+			if written, err := strconv.ParseUint(number, 10, 64); err == nil {
+				log.DataUnitsWritten = written
+			}
+		}
+	}
+	return log
+}
+
+// celsius reads a temperature in Celsius, converting from Kelvin when that
+// is the only unit given
+func celsius(value string) (int, bool) {
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ", "°", " ").Replace(value))
+	kelvin := -1
+	for i, field := range fields {
+		// "35C" is the number and unit run together
+		unit := ""
+		if trimmed, ok := strings.CutSuffix(field, "C"); ok {
+			field, unit = trimmed, "C"
+		} else if i+1 < len(fields) {
+			unit = fields[i+1]
+		}
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		switch unit {
+		case "C", "Celsius":
+			if number > 0 && number < 200 {
+				return number, true
+			}
+		case "K", "Kelvin":
+			if kelvin < 0 {
+				kelvin = number
+			}
+		}
+	}
+	if kelvin > 273 && kelvin < 473 {
+		return kelvin - 273, true
+	}
+	return 0, false
+}
+
+// firstField returns the first word of value, or "" if it is blank
+func firstField(value string) string {
+	if fields := strings.Fields(value); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
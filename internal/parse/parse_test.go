@@ -0,0 +1,240 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata from the parsers' current output")
+
+// corpus returns the .txt files in testdata/dir
+func corpus(t testing.TB, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", dir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no corpus files in testdata/%s", dir)
+	}
+	return files
+}
+
+// checkGolden compares what a parser made of each corpus file, as JSON, with
+// the file's .golden twin
+func checkGolden(t *testing.T, dir string, parse func(string) any) {
+	for _, file := range corpus(t, dir) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			input, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(parse(string(input)), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(file, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test ./internal/parse -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestZpoolStatusGolden(t *testing.T) {
+	checkGolden(t, "zpool", func(output string) any { return ZpoolStatuses(output) })
+}
+
+func TestSmartctlGolden(t *testing.T) {
+	checkGolden(t, "smartctl", func(output string) any { return Smartctl(output) })
+}
+
+func TestNVMeSmartLogGolden(t *testing.T) {
+	checkGolden(t, "nvme", func(output string) any { return NVMeSmartLog(output) })
+}
+
+func TestZpoolStatusClasses(t *testing.T) {
+	input, err := os.ReadFile("testdata/zpool/zfs-0.8-mirror-log-cache-spare.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := ZpoolStatus(string(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	classes := make(map[string]string)
+	for _, device := range status.Config {
+		classes[device.Name] = device.Class
+	}
+	if classes[status.Pool] != "" {
+		t.Errorf("Pool row should have no class, got %q", classes[status.Pool])
+	}
+	for _, class := range []string{"logs", "cache", "spares"} {
+		found := false
+		for _, device := range status.Config {
+			if device.Class == class {
+				found = true
+				if device.Depth < 1 {
+					t.Errorf("%s device %s has depth %d", class, device.Name, device.Depth)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("No %s devices found", class)
+		}
+	}
+}
+
+func TestZpoolStatusCounts(t *testing.T) {
+	tests := []struct {
+		field string
+		want  int
+		ok    bool
+	}{
+		{"0", 0, true},
+		{"12", 12, true},
+		{"1.50K", 1500, true},
+		{"2M", 2000000, true},
+		{"-", 0, true},
+		{"-1", 0, false},
+		{"K", 0, false},
+		{"(resilvering)", 0, false},
+		{"NaNK", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseCount(tt.field)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseCount(%q) = %d, %v, want %d, %v", tt.field, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestZpoolStatusNoPool(t *testing.T) {
+	if _, err := ZpoolStatus("no pools available\n"); err == nil {
+		t.Error("Expected an error when no pool is listed")
+	}
+}
+
+func TestSmartctlTemperature(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   int
+	}{
+		{"Min/Max raw value", "ID# ATTRIBUTE_NAME FLAG VALUE WORST THRESH TYPE UPDATED WHEN_FAILED RAW_VALUE\n194 Temperature_Celsius 0x0022 036 045 000 Old_age Always - 36 (Min/Max 20/45)\n", 36},
+		{"Celsius preferred over airflow", "ID# ATTRIBUTE_NAME FLAGS VALUE WORST THRESH FAIL RAW_VALUE\n190 Airflow_Temperature_Cel -O---K 060 050 045 - 40\n194 Temperature_Celsius -O---K 100 100 000 - 38\n", 38},
+		{"SAS", "Current Drive Temperature:     34 C\n", 34},
+		{"Missing", "SMART overall-health self-assessment test result: PASSED\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Smartctl(tt.output).Temperature; got != tt.want {
+				t.Errorf("Temperature = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNVMeTemperature(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"38 C", 38},
+		{"38C", 38},
+		{"42 °C (315 K)", 42},
+		{"344 Kelvin", 71},
+		{"315 K", 42},
+		{"0 C", 0},
+		{"unknown", 0},
+	}
+	for _, tt := range tests {
+		if got, _ := celsius(tt.value); got != tt.want {
+			t.Errorf("celsius(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+// Fuzzing starts from the corpus and checks the parsers never panic and
+// only return results that make sense
+
+func FuzzZpoolStatus(f *testing.F) {
+	for _, file := range corpus(f, "zpool") {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(input))
+	}
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, status := range ZpoolStatuses(output) {
+			for _, device := range status.Config {
+				if device.Name == "" || device.State == "" {
+					t.Errorf("Device with empty name or state: %+v", device)
+				}
+				if device.Depth < 0 {
+					t.Errorf("Negative depth: %+v", device)
+				}
+				if device.Read < 0 || device.Write < 0 || device.Cksum < 0 {
+					t.Errorf("Negative error count: %+v", device)
+				}
+			}
+		}
+	})
+}
+
+func FuzzSmartctl(f *testing.F) {
+	for _, file := range corpus(f, "smartctl") {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(input))
+	}
+	f.Fuzz(func(t *testing.T, output string) {
+		report := Smartctl(output)
+		if report.Temperature < 0 {
+			t.Errorf("Negative temperature %d", report.Temperature)
+		}
+		if !report.Healthy && len(report.Errors) == 0 {
+			t.Error("Unhealthy without a reason")
+		}
+	})
+}
+
+func FuzzNVMeSmartLog(f *testing.F) {
+	for _, file := range corpus(f, "nvme") {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(input))
+	}
+	f.Fuzz(func(t *testing.T, output string) {
+		health := NVMeSmartLog(output)
+		if health.Temperature < 0 || health.Temperature >= 200 {
+			t.Errorf("Implausible temperature %d", health.Temperature)
+		}
+		if health.CriticalWarning < 0 || health.PercentageUsed < 0 || health.AvailableSpare < 0 {
+			t.Errorf("Negative value: %+v", health)
+		}
+	})
+}
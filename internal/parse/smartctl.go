@@ -0,0 +1,156 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SmartctlReport is what smartctl -H -A says about a disk's health
+type SmartctlReport struct {
+	Healthy     bool
+	Temperature int      // Celsius, 0 if not reported
+	Errors      []string // Failed health checks and worrying counters
+}
+
+// failureAttributes are ATA attributes whose raw value should stay at zero
+var failureAttributes = map[string]bool{
+	"Reallocated_Sector_Ct":  true,
+	"Current_Pending_Sector": true,
+	"Offline_Uncorrectable":  true,
+}
+
+// temperatureAttributes are the ATA attributes drives report their
+// temperature in, most trusted first
+var temperatureAttributes = []string{"Temperature_Celsius", "Airflow_Temperature_Cel", "Temperature_Internal"}
+
+// Smartctl parses smartctl -H -A output: the ATA health line and attribute
+// table, or the SAS/SCSI health status and temperature lines
+func Smartctl(output string) SmartctlReport {
+	report := SmartctlReport{Healthy: true}
+	temperatures := make(map[string]int)
+	var table *attributeTable
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(line, "SMART overall-health"):
+			if _, result, _ := strings.Cut(line, ":"); !strings.Contains(result, "PASSED") {
+				report.Healthy = false
+				report.Errors = append(report.Errors, "SMART health check failed")
+			}
+
+		case strings.HasPrefix(line, "SMART Health Status:"):
+			// SAS drives report health and temperature in a different format
+			if value := labelValue(line, "SMART Health Status:"); value != "OK" {
+				report.Healthy = false
+				report.Errors = append(report.Errors, fmt.Sprintf("SAS health status: %s", value))
+			}
+
+		case strings.HasPrefix(line, "Current Drive Temperature:"):
+			if temp, ok := leadingInt(labelValue(line, "Current Drive Temperature:")); ok {
+				report.Temperature = temp
+			}
+
+		case strings.HasPrefix(line, "Elements in grown defect list:"):
+			if value, ok := leadingInt(labelValue(line, "Elements in grown defect list:")); ok && value > 0 {
+				report.Errors = append(report.Errors, fmt.Sprintf("Grown defect list: %d", value))
+			}
+
+		case strings.HasPrefix(line, "ID#"):
+			table = newAttributeTable(line)
+
+		case table != nil:
+			attribute, ok := table.row(line)
+			if !ok {
+				continue
+			}
+			if attribute.failing {
+				report.Healthy = false
+				report.Errors = append(report.Errors, fmt.Sprintf("%s is failing now", attribute.name))
+			}
+			if failureAttributes[attribute.name] && attribute.raw > 0 {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %d", attribute.name, attribute.raw))
+			}
+			temperatures[attribute.name] = attribute.raw
+		}
+	}
+
+	for _, name := range temperatureAttributes {
+		if temp, ok := temperatures[name]; ok && temp > 0 {
+			report.Temperature = temp
+			break
+		}
+	}
+	return report
+}
+
+// attributeTable locates the columns of an ATA attribute table from its
+// header, which differs between the default and -f brief layouts
+type attributeTable struct {
+	name       int
+	raw        int
+	whenFailed int // -1 in the brief layout, which folds it into FAIL flags
+}
+
+type attribute struct {
+	name    string
+	raw     int
+	failing bool
+}
+
+func newAttributeTable(header string) *attributeTable {
+	table := &attributeTable{name: -1, raw: -1, whenFailed: -1}
+	for i, column := range strings.Fields(header) {
+		switch column {
+		case "ATTRIBUTE_NAME":
+			table.name = i
+		case "RAW_VALUE":
+			table.raw = i
+		case "WHEN_FAILED":
+			table.whenFailed = i
+		}
+	}
+	if table.name < 0 || table.raw < 0 {
+		return nil
+	}
+	return table
+}
+
+// row reads one attribute. RAW_VALUE is the last column and may contain
+// spaces, e.g. "36 (Min/Max 20/45)", so only its leading number is used.
+func (t *attributeTable) row(line string) (attribute, bool) {
+	fields := strings.Fields(line)
+	if len(fields) <= t.raw || len(fields) <= t.name {
+		return attribute{}, false
+	}
+	if _, err := strconv.Atoi(fields[0]); err != nil {
+		return attribute{}, false
+	}
+	raw, ok := leadingInt(fields[t.raw])
+	if !ok {
+		return attribute{}, false
+	}
+	failing := t.whenFailed >= 0 && t.whenFailed < len(fields) && fields[t.whenFailed] == "FAILING_NOW"
+	return attribute{name: fields[t.name], raw: raw, failing: failing}, true
+}
+
+// labelValue returns the trimmed text after label
+func labelValue(line, label string) string {
+	return strings.TrimSpace(strings.TrimPrefix(line, label))
+}
+
+// leadingInt reads the digits at the start of s, e.g. 41 from "41 C"
+func leadingInt(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && end < 12 && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return 0, false
+	}
+	value, err := strconv.Atoi(s[:end])
+	return value, err == nil
+}
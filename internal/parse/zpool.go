@@ -0,0 +1,235 @@
+// Package parse reads the text output of zpool, smartctl and nvme-cli. The
+// formats drift between versions and pool layouts, so the parsers key off
+// headers and labels rather than fixed positions where they can, and are
+// checked against a corpus of real outputs in testdata and fuzzed.
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PoolStatus is one pool from zpool status
+type PoolStatus struct {
+	Pool   string
+	State  string
+	Status string // Explanation shown for unhealthy pools, joined onto one line
+	Action string
+	Scan   string // First line of the scan: the scrub or resilver and when it ran
+	// Progress of a running scan, e.g. "1.2T scanned at 1.2G/s, 12.3G issued
+	// at 40.2M/s, 1.2T total 0B repaired, 1.00% done, 08:39:44 to go"
+	ScanProgress string
+	Config       []DeviceStatus
+	ErrorSummary string   // What follows errors:, e.g. "No known data errors"
+	Errors       []string // Files with permanent errors
+}
+
+// DeviceStatus is one row of a pool's config
+type DeviceStatus struct {
+	Name  string
+	State string
+	Read  int
+	Write int
+	Cksum int
+	Depth int // Nesting in the config tree: 0 for the pool, 1 for top-level vdevs
+	// Allocation class section the device is listed under: "" for the
+	// pool's data vdevs, otherwise logs, cache, spares, special or dedup
+	Class string
+	Note  string // Anything after the counters, e.g. "(resilvering)" or "was /dev/sdb1"
+}
+
+// keyLine matches "  pool: tank" and the other labelled lines. Config rows
+// and continuation lines are tab indented, and draid vdev names contain
+// digits before their first colon, so neither is mistaken for a label.
+var keyLine = regexp.MustCompile(`^ *([a-z]+):(?:\s+(.*))?$`)
+
+// ZpoolStatus parses zpool status output for a single pool. If the output
+// covers several pools, only the first is returned.
+func ZpoolStatus(output string) (*PoolStatus, error) {
+	pools := ZpoolStatuses(output)
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("no pool found in zpool status output")
+	}
+	return pools[0], nil
+}
+
+// ZpoolStatuses parses zpool status output listing any number of pools
+func ZpoolStatuses(output string) []*PoolStatus {
+	var pools []*PoolStatus
+	var status *PoolStatus
+	var section string
+	var table *configTable
+
+	for _, raw := range strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+
+		if match := keyLine.FindStringSubmatch(raw); match != nil {
+			section = match[1]
+			value := strings.TrimSpace(match[2])
+			if section == "pool" {
+				status = &PoolStatus{Pool: value}
+				pools = append(pools, status)
+				table = nil
+				continue
+			}
+			if status == nil {
+				continue
+			}
+			switch section {
+			case "state":
+				status.State = value
+			case "status":
+				status.Status = value
+			case "action":
+				status.Action = value
+			case "scan":
+				status.Scan = value
+			case "errors":
+				status.ErrorSummary = value
+			}
+			continue
+		}
+
+		if status == nil || line == "" {
+			continue
+		}
+
+		switch section {
+		case "status":
+			status.Status = joinLine(status.Status, line)
+		case "action":
+			status.Action = joinLine(status.Action, line)
+		case "scan":
+			status.ScanProgress = joinLine(status.ScanProgress, line)
+		case "errors":
+			status.Errors = append(status.Errors, line)
+		case "config":
+			if table == nil {
+				// Everything up to the NAME header is ignored
+				if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "NAME" && fields[1] == "STATE" {
+					table = &configTable{base: indent(raw), counters: len(fields) - 2}
+				}
+				continue
+			}
+			if device, ok := table.row(raw); ok {
+				status.Config = append(status.Config, device)
+			}
+		}
+	}
+
+	return pools
+}
+
+// configTable tracks the layout of a pool's config section
+type configTable struct {
+	base     int    // Indentation of the NAME header, and so of the pool's row
+	counters int    // Columns after STATE: READ WRITE CKSUM, plus SLOW with -s
+	class    string // Allocation class of the rows that follow
+}
+
+// row parses one line of the config table. Class headings such as "logs"
+// switch the class of the rows below them and are not rows themselves.
+func (t *configTable) row(raw string) (DeviceStatus, bool) {
+	fields := strings.Fields(raw)
+	depth := max((indent(raw)-t.base)/2, 0)
+
+	if len(fields) == 1 {
+		if depth == 0 {
+			t.class = fields[0]
+		}
+		return DeviceStatus{}, false
+	}
+	if !isState(fields[1]) {
+		return DeviceStatus{}, false
+	}
+
+	device := DeviceStatus{Name: fields[0], State: fields[1], Depth: depth, Class: t.class}
+	rest := fields[2:]
+	// Spares list only a state; others have READ WRITE CKSUM
+	var counts []int
+	for len(rest) > 0 && len(counts) < t.counters {
+		count, ok := parseCount(rest[0])
+		if !ok {
+			break
+		}
+		counts = append(counts, count)
+		rest = rest[1:]
+	}
+	if len(counts) >= 3 {
+		device.Read, device.Write, device.Cksum = counts[0], counts[1], counts[2]
+	}
+	device.Note = strings.Join(rest, " ")
+	return device, true
+}
+
+// indent measures a line's leading whitespace, counting a tab as 8 columns
+func indent(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 8 - width%8
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// isState reports whether field looks like a device state such as ONLINE,
+// AVAIL or INUSE rather than a word of prose
+func isState(field string) bool {
+	if field == "" {
+		return false
+	}
+	for _, r := range field {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCount reads an error counter, which zpool abbreviates once it gets
+// large, e.g. 1.50K or 2M. The SLOW column shows "-" where it doesn't apply.
+func parseCount(field string) (int, bool) {
+	if field == "-" {
+		return 0, true
+	}
+	if count, err := strconv.Atoi(field); err == nil && count >= 0 {
+		return count, true
+	}
+	if field == "" {
+		return 0, false
+	}
+
+	multiplier := 1.0
+	switch field[len(field)-1] {
+	case 'K':
+		multiplier = 1e3
+	case 'M':
+		multiplier = 1e6
+	case 'G':
+		multiplier = 1e9
+	case 'T':
+		multiplier = 1e12
+	default:
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(field[:len(field)-1], 64)
+	if err != nil || !(value >= 0 && value*multiplier <= 1e15) {
+		return 0, false
+	}
+	return int(value * multiplier), true
+}
+
+func joinLine(text, line string) string {
+	if text == "" {
+		return line
+	}
+	return text + " " + line
+}
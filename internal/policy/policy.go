@@ -0,0 +1,215 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/robfig/cron/v3"
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/validation"
+)
+
+// Policy is the desired backup behaviour for one dataset
+type Policy struct {
+	Name      string    `json:"name"`
+	Dataset   string    `json:"dataset"`
+	Recursive bool      `json:"recursive"`
+	Schedule  Schedule  `json:"schedule"`
+	Retention Retention `json:"retention"`
+	Target    Target    `json:"target"`
+}
+
+type Schedule struct {
+	Snapshot string `json:"snapshot"` // Cron expression
+	Scrub    string `json:"scrub"`    // Cron expression
+}
+
+type Retention struct {
+	KeepSnapshots int  `json:"keep_snapshots"`
+	KeepHourly    int  `json:"keep_hourly,omitempty"`
+	KeepDaily     int  `json:"keep_daily,omitempty"`
+	KeepWeekly    int  `json:"keep_weekly,omitempty"`
+	KeepMonthly   int  `json:"keep_monthly,omitempty"`
+	KeepYearly    int  `json:"keep_yearly,omitempty"`
+	PruneRemote   bool `json:"prune_remote,omitempty"`
+}
+
+type Target struct {
+	Host    string `json:"host"`
+	User    string `json:"user"`
+	Dataset string `json:"dataset"`
+}
+
+// Set is the complete desired policy state. Applying a Set replaces whatever
+// was applied before, so the same document can be PUT repeatedly. The first
+// policy is for the zfs and ssh sections; any others are for the jobs entries
+// of the same name.
+type Set struct {
+	Policies []Policy `json:"policies"`
+}
+
+// FromConfig describes the policy currently in effect for cfg and each of
+// its jobs entries
+func FromConfig(cfg *config.Config) *Set {
+	set := &Set{Policies: []Policy{describe("default", &cfg.ZFS, cfg.Schedule.SnapshotCron, cfg.Schedule.ScrubCron, &cfg.SSH)}}
+	for i := range cfg.Jobs {
+		job := &cfg.Jobs[i]
+		// Scrubs are per pool, so jobs share the schedule section's
+		set.Policies = append(set.Policies, describe(job.Name, &job.ZFSConfig, job.SnapshotCron, cfg.Schedule.ScrubCron, &job.Target))
+	}
+	return set
+}
+
+func describe(name string, zfs *config.ZFSConfig, snapshotCron, scrubCron string, target *config.SSHConfig) Policy {
+	return Policy{
+		Name:      name,
+		Dataset:   zfs.Dataset,
+		Recursive: zfs.Recursive,
+		Schedule: Schedule{
+			Snapshot: snapshotCron,
+			Scrub:    scrubCron,
+		},
+		Retention: Retention{
+			KeepSnapshots: zfs.KeepSnapshots,
+			KeepHourly:    zfs.KeepHourly,
+			KeepDaily:     zfs.KeepDaily,
+			KeepWeekly:    zfs.KeepWeekly,
+			KeepMonthly:   zfs.KeepMonthly,
+			KeepYearly:    zfs.KeepYearly,
+			PruneRemote:   zfs.PruneRemote,
+		},
+		Target: Target{
+			Host:    target.RemoteHost,
+			User:    target.RemoteUser,
+			Dataset: target.RemoteDataset,
+		},
+	}
+}
+
+// Validate checks the set is complete and supported by this daemon
+func (s *Set) Validate() error {
+	if len(s.Policies) == 0 {
+		return fmt.Errorf("at least one policy is required")
+	}
+	names := make(map[string]bool)
+	for i, p := range s.Policies {
+		if p.Name == "" {
+			return fmt.Errorf("policies[%d].name cannot be empty", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate policy name %q", p.Name)
+		}
+		names[p.Name] = true
+
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) Validate() error {
+	if err := validation.ValidateDatasetName(p.Dataset); err != nil {
+		return fmt.Errorf("dataset: %w", err)
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	if _, err := parser.Parse(p.Schedule.Snapshot); err != nil {
+		return fmt.Errorf("invalid snapshot schedule '%s': %w", p.Schedule.Snapshot, err)
+	}
+	if _, err := parser.Parse(p.Schedule.Scrub); err != nil {
+		return fmt.Errorf("invalid scrub schedule '%s': %w", p.Schedule.Scrub, err)
+	}
+
+	if p.Retention.KeepSnapshots < 1 {
+		return fmt.Errorf("retention.keep_snapshots must be at least 1")
+	}
+	if p.Retention.KeepHourly < 0 || p.Retention.KeepDaily < 0 || p.Retention.KeepWeekly < 0 ||
+		p.Retention.KeepMonthly < 0 || p.Retention.KeepYearly < 0 {
+		return fmt.Errorf("retention counts cannot be negative")
+	}
+
+	if p.Target.Host == "" || p.Target.User == "" {
+		return fmt.Errorf("target.host and target.user are required")
+	}
+	if err := validation.ValidateDatasetName(p.Target.Dataset); err != nil {
+		return fmt.Errorf("target.dataset: %w", err)
+	}
+
+	return nil
+}
+
+// Matches checks every policy after the first names one of cfg's jobs
+// entries and keeps the scrub schedule, which only the first policy sets
+func (s *Set) Matches(cfg *config.Config) error {
+	for _, p := range s.Policies[1:] {
+		if !slices.ContainsFunc(cfg.Jobs, func(job config.JobConfig) bool { return job.Name == p.Name }) {
+			return fmt.Errorf("policy %s matches no jobs entry", p.Name)
+		}
+		if p.Schedule.Scrub != s.Policies[0].Schedule.Scrub {
+			return fmt.Errorf("policy %s: scrubs are per pool and follow the first policy's schedule", p.Name)
+		}
+	}
+	return nil
+}
+
+// Apply writes the policy set into cfg, reporting whether anything changed.
+// Policies naming no jobs entry are skipped; see Matches. cfg gets its own
+// copy of the jobs it had, so other copies of cfg are left as they were.
+func (s *Set) Apply(cfg *config.Config) bool {
+	before := FromConfig(cfg).Policies
+
+	p := s.Policies[0]
+	p.applyTo(&cfg.ZFS, &cfg.Schedule.SnapshotCron, &cfg.SSH)
+	cfg.Schedule.ScrubCron = p.Schedule.Scrub
+
+	cfg.Jobs = slices.Clone(cfg.Jobs)
+	for _, p := range s.Policies[1:] {
+		for i := range cfg.Jobs {
+			if job := &cfg.Jobs[i]; job.Name == p.Name {
+				p.applyTo(&job.ZFSConfig, &job.SnapshotCron, &job.Target)
+			}
+		}
+	}
+
+	// Name is bookkeeping only; compare the effective settings
+	after := FromConfig(cfg).Policies
+	return !slices.Equal(before, after)
+}
+
+func (p *Policy) applyTo(zfs *config.ZFSConfig, snapshotCron *string, target *config.SSHConfig) {
+	zfs.Dataset = p.Dataset
+	zfs.Recursive = p.Recursive
+	zfs.KeepSnapshots = p.Retention.KeepSnapshots
+	zfs.KeepHourly = p.Retention.KeepHourly
+	zfs.KeepDaily = p.Retention.KeepDaily
+	zfs.KeepWeekly = p.Retention.KeepWeekly
+	zfs.KeepMonthly = p.Retention.KeepMonthly
+	zfs.KeepYearly = p.Retention.KeepYearly
+	zfs.PruneRemote = p.Retention.PruneRemote
+	*snapshotCron = p.Schedule.Snapshot
+	target.RemoteHost = p.Target.Host
+	target.RemoteUser = p.Target.User
+	target.RemoteDataset = p.Target.Dataset
+}
+
+// Load reads a previously applied policy set; a missing file returns nil
+func Load(path string) (*Set, error) {
+	var set *Set
+	if err := utils.ReadJSONFile(path, &set); err != nil {
+		return nil, err
+	}
+	if set != nil {
+		if err := set.Validate(); err != nil {
+			return nil, fmt.Errorf("stored policy set is invalid: %w", err)
+		}
+	}
+	return set, nil
+}
+
+// Save persists the policy set so it survives restarts
+func (s *Set) Save(path string) error {
+	return utils.WriteJSONAtomic(path, s, 0600)
+}
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"zfsrabbit/internal/config"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		ZFS: config.ZFSConfig{
+			Dataset:       "tank/data",
+			Recursive:     true,
+			KeepSnapshots: 30,
+		},
+		SSH: config.SSHConfig{
+			RemoteHost:    "backup.example.com",
+			RemoteUser:    "root",
+			RemoteDataset: "backup/data",
+		},
+		Schedule: config.ScheduleConfig{
+			SnapshotCron: "0 2 * * *",
+			ScrubCron:    "0 3 * * 0",
+		},
+	}
+}
+
+func TestFromConfigRoundTrip(t *testing.T) {
+	cfg := testConfig()
+	set := FromConfig(cfg)
+
+	if err := set.Validate(); err != nil {
+		t.Fatalf("Policy derived from config should be valid: %v", err)
+	}
+
+	if set.Apply(cfg) {
+		t.Error("Applying the current policy should report no change")
+	}
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	cfg := testConfig()
+	set := FromConfig(cfg)
+	set.Policies[0].Retention.KeepSnapshots = 90
+	set.Policies[0].Target.Host = "dr.example.com"
+
+	if !set.Apply(cfg) {
+		t.Fatal("Expected first apply to report a change")
+	}
+	if cfg.ZFS.KeepSnapshots != 90 || cfg.SSH.RemoteHost != "dr.example.com" {
+		t.Errorf("Config not updated: keep=%d host=%s", cfg.ZFS.KeepSnapshots, cfg.SSH.RemoteHost)
+	}
+
+	if set.Apply(cfg) {
+		t.Error("Expected second apply of the same set to be a no-op")
+	}
+}
+
+func TestApplyReachesJobs(t *testing.T) {
+	cfg := testConfig()
+	cfg.Jobs = []config.JobConfig{{
+		Name:         "media",
+		ZFSConfig:    config.ZFSConfig{Dataset: "tank/media", KeepSnapshots: 7},
+		SnapshotCron: "0 4 * * *",
+		Target:       config.SSHConfig{RemoteHost: "media.example.com", RemoteUser: "root", RemoteDataset: "backup/media"},
+	}}
+	original := cfg.Jobs
+
+	set := FromConfig(cfg)
+	if len(set.Policies) != 2 || set.Policies[1].Name != "media" || set.Policies[1].Schedule.Scrub != cfg.Schedule.ScrubCron {
+		t.Fatalf("Expected a policy for the job sharing the scrub schedule, got %+v", set.Policies)
+	}
+
+	set.Policies[1].Retention.KeepSnapshots = 14
+	set.Policies[1].Schedule.Snapshot = "0 5 * * *"
+	if err := set.Matches(cfg); err != nil {
+		t.Fatalf("Expected the set to match the config: %v", err)
+	}
+	if !set.Apply(cfg) {
+		t.Fatal("Expected a change to the job's policy to be reported")
+	}
+	if job := cfg.Jobs[0]; job.KeepSnapshots != 14 || job.SnapshotCron != "0 5 * * *" {
+		t.Errorf("Job not updated: keep=%d cron=%s", job.KeepSnapshots, job.SnapshotCron)
+	}
+	if original[0].KeepSnapshots != 7 {
+		t.Error("Apply should leave the jobs slice it was given untouched")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	cfg := testConfig()
+	cfg.Jobs = []config.JobConfig{{Name: "media"}}
+
+	unknown := FromConfig(testConfig())
+	unknown.Policies = append(unknown.Policies, unknown.Policies[0])
+	unknown.Policies[1].Name = "photos"
+	if err := unknown.Matches(cfg); err == nil {
+		t.Error("Expected a policy naming no jobs entry to be rejected")
+	}
+
+	scrub := FromConfig(cfg)
+	scrub.Policies[1].Schedule.Scrub = "0 4 * * 0"
+	if err := scrub.Matches(cfg); err == nil {
+		t.Error("Expected a job policy with its own scrub schedule to be rejected")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Set)
+	}{
+		{"no policies", func(s *Set) { s.Policies = nil }},
+		{"duplicate name", func(s *Set) { s.Policies = append(s.Policies, s.Policies[0]) }},
+		{"empty name", func(s *Set) { s.Policies[0].Name = "" }},
+		{"bad dataset", func(s *Set) { s.Policies[0].Dataset = "tank/data; rm -rf /" }},
+		{"bad cron", func(s *Set) { s.Policies[0].Schedule.Snapshot = "every day" }},
+		{"zero retention", func(s *Set) { s.Policies[0].Retention.KeepSnapshots = 0 }},
+		{"missing target host", func(s *Set) { s.Policies[0].Target.Host = "" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := FromConfig(testConfig())
+			tt.modify(set)
+			if err := set.Validate(); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+
+	missing, err := Load(path)
+	if err != nil || missing != nil {
+		t.Fatalf("Expected nil set for missing file, got %v, %v", missing, err)
+	}
+
+	set := FromConfig(testConfig())
+	set.Policies[0].Name = "nightly"
+	if err := set.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Policies[0] != set.Policies[0] {
+		t.Errorf("Expected %+v, got %+v", set.Policies[0], loaded.Policies[0])
+	}
+}
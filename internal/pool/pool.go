@@ -0,0 +1,465 @@
+// Package pool helps create a new ZFS pool, or extend an existing one, from
+// disks the monitor knows about and nothing else is using. Every change is
+// planned and dry-run with zpool -n first, and only runs once confirmed.
+package pool
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/zfs"
+)
+
+// Actions a plan can take
+const (
+	ActionCreate = "create"
+	ActionAdd    = "add"
+)
+
+// minDisks is the smallest vdev each layout can be built from
+var minDisks = map[string]int{
+	"stripe": 1,
+	"mirror": 2,
+	"raidz1": 2,
+	"raidz2": 3,
+	"raidz3": 4,
+}
+
+// largeDisk is the size above which a single-parity vdev is risky: a second
+// failure during the long resilver loses the pool
+const largeDisk = 2 << 40
+
+// Disk is a candidate disk and, if it can't be used, why
+type Disk struct {
+	ID       string `json:"id"`
+	Device   string `json:"device"`
+	ByIDPath string `json:"by_id_path,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Serial   string `json:"serial,omitempty"`
+	Size     int64  `json:"size"`
+	InUse    string `json:"in_use,omitempty"`
+}
+
+// path is how the disk is named to zpool; by-id links survive reboots
+func (d Disk) path() string {
+	if d.ByIDPath != "" {
+		return d.ByIDPath
+	}
+	return d.Device
+}
+
+// Request describes the pool change to plan
+type Request struct {
+	Action string   `json:"action"` // create or add
+	Pool   string   `json:"pool"`
+	Layout string   `json:"layout"` // stripe, mirror, raidz1, raidz2 or raidz3
+	Disks  []string `json:"disks"`  // Disk IDs from Disks
+	// Add a vdev whose redundancy doesn't match the pool's
+	AllowMixedRedundancy bool `json:"allow_mixed_redundancy"`
+	// Pass -f, overriding zpool's own checks, which also refuse mixed
+	// redundancy. Only passed when asked for.
+	Force bool `json:"force"`
+}
+
+// Plan is a validated, dry-run pool change. Plans wait for confirmation
+// because the disks' contents are lost.
+type Plan struct {
+	ID       string     `json:"id"`
+	Request  Request    `json:"request"`
+	Disks    []Disk     `json:"disks"`
+	Command  []string   `json:"command"`
+	DryRun   string     `json:"dry_run"` // zpool -n output: the layout that would be created
+	Warnings []string   `json:"warnings,omitempty"`
+	Status   string     `json:"status"` // awaiting_confirmation, completed, failed
+	Output   string     `json:"output,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// runFunc runs a command and returns its combined output
+type runFunc func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return utils.CombinedOutput(utils.DefaultRunner, utils.DefaultRunner.CommandContext(ctx, name, args...))
+}
+
+// Assistant plans and runs pool changes
+type Assistant struct {
+	disks func(ctx context.Context) ([]monitor.DiskInfo, error)
+	run   runFunc
+	mutex sync.Mutex
+	plans map[string]*Plan
+}
+
+// New returns an assistant that picks disks from the monitor's disk list
+func New(mon *monitor.Monitor) *Assistant {
+	return newAssistant(mon.Disks, runCommand)
+}
+
+func newAssistant(disks func(ctx context.Context) ([]monitor.DiskInfo, error), run runFunc) *Assistant {
+	return &Assistant{disks: disks, run: run, plans: make(map[string]*Plan)}
+}
+
+// Disks lists the monitored disks with their size, marking those that hold
+// a pool, partitions or a filesystem as in use
+func (a *Assistant) Disks(ctx context.Context) ([]Disk, error) {
+	infos, err := a.disks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disks: %w", err)
+	}
+
+	output, err := a.run(ctx, "lsblk", "-b", "-n", "-P", "-o", "NAME,SIZE,TYPE,FSTYPE,MOUNTPOINT,PKNAME")
+	if err != nil {
+		return nil, fmt.Errorf("lsblk failed: %w", err)
+	}
+	sizes, usage := parseLsblkUsage(string(output))
+
+	disks := make([]Disk, 0, len(infos))
+	for _, info := range infos {
+		disk := Disk{
+			ID:       info.ID,
+			Device:   info.Device,
+			ByIDPath: info.ByIDPath,
+			Model:    info.Model,
+			Serial:   info.Serial,
+		}
+		for _, path := range append(info.Paths, info.MultipathDevice) {
+			name := strings.TrimPrefix(path, "/dev/")
+			if disk.Size == 0 {
+				disk.Size = sizes[name]
+			}
+			if disk.InUse == "" {
+				disk.InUse = usage[name]
+			}
+		}
+		if disk.InUse == "" && len(info.FailedPaths) > 0 {
+			disk.InUse = "has failed paths"
+		}
+		disks = append(disks, disk)
+	}
+
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Device < disks[j].Device })
+	return disks, nil
+}
+
+// lsblkPair matches a key="value" field of lsblk -P output
+var lsblkPair = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parseLsblkUsage reads `lsblk -b -P -o NAME,SIZE,TYPE,FSTYPE,MOUNTPOINT,PKNAME`
+// into each disk's size and, for disks that are in use, the reason. A disk is
+// in use if it or any partition on it carries a filesystem, a mount or a pool.
+func parseLsblkUsage(output string) (map[string]int64, map[string]string) {
+	sizes := make(map[string]int64)
+	usage := make(map[string]string)
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := make(map[string]string)
+		for _, match := range lsblkPair.FindAllStringSubmatch(line, -1) {
+			fields[match[1]] = strings.TrimSpace(match[2])
+		}
+		name := fields["NAME"]
+		if name == "" {
+			continue
+		}
+
+		disk := name
+		if parent := fields["PKNAME"]; parent != "" {
+			disk = parent
+		} else {
+			sizes[name], _ = strconv.ParseInt(fields["SIZE"], 10, 64)
+		}
+		if usage[disk] != "" {
+			continue
+		}
+
+		switch {
+		case fields["FSTYPE"] == "zfs_member":
+			usage[disk] = "member of a ZFS pool"
+		case fields["MOUNTPOINT"] != "":
+			usage[disk] = "mounted at " + fields["MOUNTPOINT"]
+		case fields["FSTYPE"] != "":
+			usage[disk] = fmt.Sprintf("has a %s signature", fields["FSTYPE"])
+		case disk != name:
+			usage[disk] = "has partition " + name
+		}
+	}
+
+	return sizes, usage
+}
+
+// Plan validates a request against the current disks and pool and dry-runs
+// it. Errors mean the change can't be made; warnings are returned in the plan.
+func (a *Assistant) Plan(ctx context.Context, req Request) (*Plan, error) {
+	plan, err := a.plan(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	a.plans[plan.ID] = plan
+	result := *plan
+	a.mutex.Unlock()
+	return &result, nil
+}
+
+func (a *Assistant) plan(ctx context.Context, req Request) (*Plan, error) {
+	if err := validation.ValidatePoolName(req.Pool); err != nil {
+		return nil, err
+	}
+	if _, ok := minDisks[req.Layout]; !ok {
+		return nil, fmt.Errorf("unknown layout %q (stripe, mirror, raidz1, raidz2 or raidz3)", req.Layout)
+	}
+
+	existing, err := a.existingLayout(ctx, req.Pool)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Action {
+	case ActionCreate:
+		if existing != nil {
+			return nil, fmt.Errorf("pool %s already exists", req.Pool)
+		}
+	case ActionAdd:
+		if existing == nil {
+			return nil, fmt.Errorf("pool %s does not exist", req.Pool)
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q (create or add)", req.Action)
+	}
+
+	candidates, err := a.Disks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectDisks(candidates, req.Disks)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := checkTopology(req, selected, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	command := zpoolCommand(req, selected)
+	dryRun := append([]string{command[0], "-n"}, command[1:]...)
+	output, err := a.run(ctx, "zpool", dryRun...)
+	if err != nil {
+		return nil, fmt.Errorf("zpool dry run failed: %s", strings.TrimSpace(string(output)))
+	}
+
+	return &Plan{
+		ID:       fmt.Sprintf("pool_%d", time.Now().UnixNano()),
+		Request:  req,
+		Disks:    selected,
+		Command:  append([]string{"zpool"}, command...),
+		DryRun:   strings.TrimSpace(string(output)),
+		Warnings: warnings,
+		Status:   "awaiting_confirmation",
+		Created:  time.Now(),
+	}, nil
+}
+
+// Confirm runs a planned change. The plan is checked again first so disks
+// that were put to use since planning are never overwritten.
+func (a *Assistant) Confirm(ctx context.Context, id string) (*Plan, error) {
+	a.mutex.Lock()
+	plan, ok := a.plans[id]
+	if !ok {
+		a.mutex.Unlock()
+		return nil, fmt.Errorf("pool plan %s not found", id)
+	}
+	if plan.Status != "awaiting_confirmation" {
+		a.mutex.Unlock()
+		return nil, fmt.Errorf("pool plan %s is not awaiting confirmation (status: %s)", id, plan.Status)
+	}
+	plan.Status = "running"
+	a.mutex.Unlock()
+
+	current, err := a.plan(ctx, plan.Request)
+	var output []byte
+	if err == nil {
+		output, err = a.run(ctx, current.Command[0], current.Command[1:]...)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	plan.Finished = &now
+	plan.Output = strings.TrimSpace(string(output))
+	if err != nil {
+		plan.Status = "failed"
+		plan.Error = err.Error()
+	} else {
+		plan.Status = "completed"
+	}
+	result := *plan
+	return &result, err
+}
+
+// Plans returns every plan, newest first
+func (a *Assistant) Plans() []Plan {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	plans := make([]Plan, 0, len(a.plans))
+	for _, plan := range a.plans {
+		plans = append(plans, *plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Created.After(plans[j].Created) })
+	return plans
+}
+
+// vdevLayout describes an existing pool's first data vdev
+type vdevLayout struct {
+	layout string
+	width  int
+}
+
+// existingLayout returns the layout of pool's data vdevs, or nil if the pool
+// doesn't exist
+func (a *Assistant) existingLayout(ctx context.Context, pool string) (*vdevLayout, error) {
+	output, err := a.run(ctx, "zpool", "list", "-H", "-o", "name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pools: %s", strings.TrimSpace(string(output)))
+	}
+	found := false
+	for _, name := range strings.Fields(string(output)) {
+		if name == pool {
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	output, err = a.run(ctx, "zpool", "status", pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pool %s: %s", pool, strings.TrimSpace(string(output)))
+	}
+	status, err := zfs.ParsePoolStatus(string(output))
+	if err != nil {
+		return nil, err
+	}
+	return poolLayout(status), nil
+}
+
+// poolLayout reads the first top-level vdev's type and width from the config
+func poolLayout(status *zfs.PoolStatus) *vdevLayout {
+	var layout *vdevLayout
+	for _, device := range status.Config {
+		switch {
+		case device.Depth == 1 && layout == nil:
+			layout = &vdevLayout{layout: "stripe"}
+			for _, kind := range []string{"mirror", "raidz1", "raidz2", "raidz3"} {
+				if strings.HasPrefix(device.Name, kind+"-") {
+					layout = &vdevLayout{layout: kind}
+				}
+			}
+		case device.Depth == 1:
+			return layout
+		case device.Depth == 2 && layout != nil && layout.layout != "stripe":
+			layout.width++
+		}
+	}
+	if layout == nil {
+		return &vdevLayout{layout: "stripe"}
+	}
+	return layout
+}
+
+func selectDisks(candidates []Disk, ids []string) ([]Disk, error) {
+	byID := make(map[string]Disk)
+	for _, disk := range candidates {
+		byID[disk.ID] = disk
+		byID[disk.Device] = disk
+	}
+
+	seen := make(map[string]bool)
+	var selected []Disk
+	for _, id := range ids {
+		disk, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("disk %s is not a monitored disk", id)
+		}
+		if seen[disk.ID] {
+			return nil, fmt.Errorf("disk %s is selected twice", id)
+		}
+		seen[disk.ID] = true
+		if disk.InUse != "" {
+			return nil, fmt.Errorf("disk %s (%s) is in use: %s", disk.ID, disk.Device, disk.InUse)
+		}
+		selected = append(selected, disk)
+	}
+	return selected, nil
+}
+
+// checkTopology rejects vdevs that can't be built and warns about ones that
+// waste space or lack redundancy
+func checkTopology(req Request, disks []Disk, existing *vdevLayout) ([]string, error) {
+	if len(disks) < minDisks[req.Layout] {
+		return nil, fmt.Errorf("%s needs at least %d disks, %d selected", req.Layout, minDisks[req.Layout], len(disks))
+	}
+
+	var warnings []string
+	smallest, largest := disks[0].Size, disks[0].Size
+	for _, disk := range disks[1:] {
+		smallest = min(smallest, disk.Size)
+		largest = max(largest, disk.Size)
+	}
+	if req.Layout != "stripe" && smallest > 0 && largest-smallest > largest/10 {
+		warnings = append(warnings, fmt.Sprintf("Disk sizes differ: every disk is used only up to the smallest (%s), leaving %s of the largest unused",
+			display.Bytes(smallest), display.Bytes(largest-smallest)))
+	}
+
+	switch {
+	case req.Layout == "stripe":
+		warnings = append(warnings, "No redundancy: losing any one disk loses the whole pool")
+	case req.Layout == "raidz1" && largest >= largeDisk:
+		warnings = append(warnings, "Single parity on large disks: a second failure during the long resilver loses the pool; consider raidz2")
+	}
+
+	if req.Action == ActionAdd && existing != nil {
+		if existing.layout != req.Layout {
+			if !req.AllowMixedRedundancy {
+				return nil, fmt.Errorf("pool %s is built from %s vdevs; adding a %s vdev mixes redundancy levels (set allow_mixed_redundancy to do it anyway)",
+					req.Pool, existing.layout, req.Layout)
+			}
+			warnings = append(warnings, fmt.Sprintf("Mixed redundancy: the pool's %s vdevs are joined by a %s vdev, and the pool is only as safe as its weakest vdev",
+				existing.layout, req.Layout))
+		} else if req.Layout != "stripe" && existing.width != len(disks) {
+			warnings = append(warnings, fmt.Sprintf("The new vdev has %d disks but the existing ones have %d; performance and space efficiency will be uneven",
+				len(disks), existing.width))
+		}
+		warnings = append(warnings, "Top-level vdevs can't always be removed again once added")
+	}
+
+	return warnings, nil
+}
+
+// zpoolCommand builds the zpool arguments for a request, without -n
+func zpoolCommand(req Request, disks []Disk) []string {
+	args := []string{req.Action}
+	if req.Force {
+		args = append(args, "-f")
+	}
+	args = append(args, req.Pool)
+	if req.Layout != "stripe" {
+		args = append(args, req.Layout)
+	}
+	for _, disk := range disks {
+		args = append(args, disk.path())
+	}
+	return args
+}
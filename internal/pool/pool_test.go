@@ -0,0 +1,188 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"zfsrabbit/internal/monitor"
+)
+
+const lsblkOutput = `NAME="sda" SIZE="4000787030016" TYPE="disk" FSTYPE="" MOUNTPOINT="" PKNAME=""
+NAME="sda1" SIZE="4000776716288" TYPE="part" FSTYPE="zfs_member" MOUNTPOINT="" PKNAME="sda"
+NAME="sdb" SIZE="4000787030016" TYPE="disk" FSTYPE="" MOUNTPOINT="" PKNAME=""
+NAME="sdc" SIZE="4000787030016" TYPE="disk" FSTYPE="" MOUNTPOINT="" PKNAME=""
+NAME="sdd" SIZE="8001563222016" TYPE="disk" FSTYPE="" MOUNTPOINT="" PKNAME=""
+NAME="sde" SIZE="500107862016" TYPE="disk" FSTYPE="" MOUNTPOINT="" PKNAME=""
+NAME="sde1" SIZE="536870912" TYPE="part" FSTYPE="vfat" MOUNTPOINT="/boot/efi" PKNAME="sde"
+NAME="sdf" SIZE="4000787030016" TYPE="disk" FSTYPE="" MOUNTPOINT="" PKNAME=""
+NAME="sdf1" SIZE="4000776716288" TYPE="part" FSTYPE="" MOUNTPOINT="" PKNAME="sdf"`
+
+const tankStatus = `  pool: tank
+ state: ONLINE
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        ONLINE       0     0     0
+	  mirror-0  ONLINE       0     0     0
+	    sda1    ONLINE       0     0     0
+	    sdx1    ONLINE       0     0     0
+
+errors: No known data errors
+`
+
+// fakeSystem answers the commands the assistant runs and records the rest
+type fakeSystem struct {
+	runs []string
+}
+
+func (f *fakeSystem) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	switch {
+	case name == "lsblk":
+		return []byte(lsblkOutput), nil
+	case command == "zpool list -H -o name":
+		return []byte("tank\n"), nil
+	case command == "zpool status tank":
+		return []byte(tankStatus), nil
+	}
+	f.runs = append(f.runs, command)
+	return []byte("would create"), nil
+}
+
+func monitoredDisks(ctx context.Context) ([]monitor.DiskInfo, error) {
+	var disks []monitor.DiskInfo
+	for _, name := range []string{"sda", "sdb", "sdc", "sdd", "sde", "sdf"} {
+		disks = append(disks, monitor.DiskInfo{
+			Device:   "/dev/" + name,
+			ID:       "wwn-" + name,
+			ByIDPath: "/dev/disk/by-id/wwn-" + name,
+			Paths:    []string{"/dev/" + name},
+		})
+	}
+	return disks, nil
+}
+
+func TestDisksMarksInUse(t *testing.T) {
+	a := newAssistant(monitoredDisks, (&fakeSystem{}).run)
+
+	disks, err := a.Disks(context.Background())
+	if err != nil {
+		t.Fatalf("Disks failed: %v", err)
+	}
+
+	inUse := make(map[string]string)
+	for _, disk := range disks {
+		inUse[disk.Device] = disk.InUse
+	}
+	expected := map[string]string{
+		"/dev/sda": "member of a ZFS pool",
+		"/dev/sdb": "",
+		"/dev/sdd": "",
+		"/dev/sde": "mounted at /boot/efi",
+		"/dev/sdf": "has partition sdf1",
+	}
+	for device, want := range expected {
+		if inUse[device] != want {
+			t.Errorf("Expected %s in use %q, got %q", device, want, inUse[device])
+		}
+	}
+	if disks[3].Size != 8001563222016 {
+		t.Errorf("Expected sdd's size from lsblk, got %d", disks[3].Size)
+	}
+}
+
+func TestPlanCreate(t *testing.T) {
+	system := &fakeSystem{}
+	a := newAssistant(monitoredDisks, system.run)
+
+	plan, err := a.Plan(context.Background(), Request{Action: ActionCreate, Pool: "backup", Layout: "mirror", Disks: []string{"wwn-sdb", "/dev/sdd"}})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	if got := strings.Join(plan.Command, " "); got != "zpool create backup mirror /dev/disk/by-id/wwn-sdb /dev/disk/by-id/wwn-sdd" {
+		t.Errorf("Unexpected command %q", got)
+	}
+	if len(system.runs) != 1 || !strings.HasPrefix(system.runs[0], "zpool create -n backup") {
+		t.Errorf("Expected only a dry run, got %v", system.runs)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "sizes differ") {
+		t.Errorf("Expected a size mismatch warning, got %v", plan.Warnings)
+	}
+
+	if _, err := a.Confirm(context.Background(), plan.ID); err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	if last := system.runs[len(system.runs)-1]; last != strings.Join(plan.Command, " ") {
+		t.Errorf("Expected the planned command to run, got %q", last)
+	}
+	if _, err := a.Confirm(context.Background(), plan.ID); err == nil {
+		t.Error("Expected a plan to run only once")
+	}
+}
+
+func TestPlanRejectsInvalidRequests(t *testing.T) {
+	a := newAssistant(monitoredDisks, (&fakeSystem{}).run)
+
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr string
+	}{
+		{"pool exists", Request{Action: ActionCreate, Pool: "tank", Layout: "mirror", Disks: []string{"wwn-sdb", "wwn-sdc"}}, "already exists"},
+		{"missing pool", Request{Action: ActionAdd, Pool: "backup", Layout: "mirror", Disks: []string{"wwn-sdb", "wwn-sdc"}}, "does not exist"},
+		{"disk in use", Request{Action: ActionCreate, Pool: "backup", Layout: "mirror", Disks: []string{"wwn-sda", "wwn-sdb"}}, "member of a ZFS pool"},
+		{"unknown disk", Request{Action: ActionCreate, Pool: "backup", Layout: "stripe", Disks: []string{"wwn-sdz"}}, "not a monitored disk"},
+		{"duplicate disk", Request{Action: ActionCreate, Pool: "backup", Layout: "mirror", Disks: []string{"wwn-sdb", "/dev/sdb"}}, "selected twice"},
+		{"too few disks", Request{Action: ActionCreate, Pool: "backup", Layout: "raidz2", Disks: []string{"wwn-sdb", "wwn-sdc"}}, "at least 3"},
+		{"mixed redundancy", Request{Action: ActionAdd, Pool: "tank", Layout: "stripe", Disks: []string{"wwn-sdb"}}, "mixes redundancy"},
+		{"bad name", Request{Action: ActionCreate, Pool: "mirror", Layout: "stripe", Disks: []string{"wwn-sdb"}}, "cannot begin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Plan(context.Background(), tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPlanAddWarnsAboutTopology(t *testing.T) {
+	a := newAssistant(monitoredDisks, (&fakeSystem{}).run)
+
+	plan, err := a.Plan(context.Background(), Request{Action: ActionAdd, Pool: "tank", Layout: "mirror", Disks: []string{"wwn-sdb", "wwn-sdc"}})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if got := strings.Join(plan.Command, " "); got != "zpool add tank mirror /dev/disk/by-id/wwn-sdb /dev/disk/by-id/wwn-sdc" {
+		t.Errorf("Unexpected command %q", got)
+	}
+
+	if _, err := a.Plan(context.Background(), Request{Action: ActionAdd, Pool: "tank", Layout: "stripe", Disks: []string{"wwn-sdb"}, Force: true}); err == nil {
+		t.Error("Expected -f alone not to allow mixed redundancy")
+	}
+
+	mixed, err := a.Plan(context.Background(), Request{Action: ActionAdd, Pool: "tank", Layout: "stripe", Disks: []string{"wwn-sdb"}, AllowMixedRedundancy: true})
+	if err != nil {
+		t.Fatalf("Mixed redundancy plan failed: %v", err)
+	}
+	if !strings.Contains(fmt.Sprint(mixed.Warnings), "Mixed redundancy") || !strings.Contains(fmt.Sprint(mixed.Warnings), "No redundancy") {
+		t.Errorf("Expected redundancy warnings, got %v", mixed.Warnings)
+	}
+	if slices.Contains(mixed.Command, "-f") {
+		t.Errorf("Expected no -f unless asked for, got %v", mixed.Command)
+	}
+
+	forced, err := a.Plan(context.Background(), Request{Action: ActionAdd, Pool: "tank", Layout: "stripe", Disks: []string{"wwn-sdb"}, AllowMixedRedundancy: true, Force: true})
+	if err != nil {
+		t.Fatalf("Forced plan failed: %v", err)
+	}
+	if forced.Command[2] != "-f" {
+		t.Errorf("Expected zpool add -f, got %v", forced.Command)
+	}
+}
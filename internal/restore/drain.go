@@ -0,0 +1,52 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// drainPoll is how often Drain checks whether restores have finished
+const drainPoll = 500 * time.Millisecond
+
+var errShuttingDown = errors.New("zfsrabbit is shutting down, restores can't be started")
+
+// Drain stops restores from starting or being confirmed, ahead of a
+// shutdown, and waits until those receiving finish or ctx is done. The IDs
+// of restores still running then are returned; they are cut off by the
+// shutdown and loaded as interrupted on the next start.
+func (r *RestoreManager) Drain(ctx context.Context) []string {
+	r.draining.Store(true)
+
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for {
+		running := r.Running()
+		if len(running) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			for _, id := range running {
+				logger.Warn("Restore job still running at shutdown, cutting it off", "job", id)
+			}
+			return running
+		case <-ticker.C:
+		}
+	}
+}
+
+// Running returns the IDs of tracked restores under way. Restores awaiting
+// confirmation have nothing running and aren't included.
+func (r *RestoreManager) Running() []string {
+	r.jobsMutex.RLock()
+	defer r.jobsMutex.RUnlock()
+
+	var running []string
+	for id, job := range r.jobs {
+		if !finished(job.Status) && job.Status != "awaiting_confirmation" {
+			running = append(running, id)
+		}
+	}
+	return running
+}
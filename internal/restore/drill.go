@@ -0,0 +1,336 @@
+package restore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/hooks"
+	"zfsrabbit/internal/state"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/zfs"
+)
+
+const maxDrillReports = 50
+
+// DrillTarget selects a remote dataset to rehearse; an empty snapshot means the latest
+type DrillTarget struct {
+	Dataset  string `json:"dataset"`
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// DrillHookResult is the outcome of a drill hook or standby probe
+type DrillHookResult = hooks.Result
+
+type DrillDatasetResult struct {
+	SourceDataset   string            `json:"source_dataset"`
+	Snapshot        string            `json:"snapshot"`
+	RestoredDataset string            `json:"restored_dataset"`
+	Passed          bool              `json:"passed"`
+	Error           string            `json:"error,omitempty"`
+	RestoreDuration time.Duration     `json:"restore_duration"`
+	Hooks           []DrillHookResult `json:"hooks"`
+}
+
+// DrillReport is the auditable record of one DR drill
+type DrillReport struct {
+	ID        string               `json:"id"`
+	Namespace string               `json:"namespace"`
+	Status    string               `json:"status"` // running, passed, failed
+	StartTime time.Time            `json:"start_time"`
+	EndTime   *time.Time           `json:"end_time,omitempty"`
+	Duration  time.Duration        `json:"duration"`
+	Datasets  []DrillDatasetResult `json:"datasets"`
+	CleanedUp bool                 `json:"cleaned_up"`
+}
+
+// DrillManager runs DR drills: restore selected datasets into an isolated
+// namespace, verify them with hooks and keep a report of the results
+type DrillManager struct {
+	config     *config.Config
+	transport  *transport.SSHTransport
+	drillMutex sync.Mutex // One drill at a time
+	mutex      sync.RWMutex
+	reports    []*DrillReport
+}
+
+func NewDrillManager(cfg *config.Config, transport *transport.SSHTransport) *DrillManager {
+	d := &DrillManager{
+		config:    cfg,
+		transport: transport,
+	}
+
+	if path := d.reportsPath(); path != "" {
+		if err := utils.ReadJSONFile(path, &d.reports); err != nil {
+			logger.Error("Failed to load drill reports", "path", path, "err", err)
+		}
+	}
+
+	return d
+}
+
+func (d *DrillManager) reportsPath() string {
+	return state.PathIn(d.config.Server.StateDir, state.DrillsFile)
+}
+
+// namespaceRoot is the parent of all drill restores, e.g. tank/drill
+func (d *DrillManager) namespaceRoot() string {
+	if d.config.Drill.Namespace != "" {
+		return d.config.Drill.Namespace
+	}
+	pool := strings.SplitN(d.config.ZFS.Dataset, "/", 2)[0]
+	return pool + "/drill"
+}
+
+// StartDrill validates the targets and runs the drill in the background
+func (d *DrillManager) StartDrill(targets []DrillTarget) (*DrillReport, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("at least one dataset is required")
+	}
+	for _, target := range targets {
+		if err := validation.ValidateDatasetName(target.Dataset); err != nil {
+			return nil, fmt.Errorf("dataset %s: %w", target.Dataset, err)
+		}
+		if target.Snapshot != "" {
+			if err := validation.ValidateSnapshotName(target.Snapshot); err != nil {
+				return nil, fmt.Errorf("snapshot %s: %w", target.Snapshot, err)
+			}
+		}
+	}
+
+	if !d.drillMutex.TryLock() {
+		return nil, fmt.Errorf("a DR drill is already in progress")
+	}
+
+	now := time.Now()
+	report := &DrillReport{
+		ID:        fmt.Sprintf("drill_%d", now.UnixNano()),
+		Namespace: fmt.Sprintf("%s/%s", d.namespaceRoot(), now.Format("2006-01-02")),
+		Status:    "running",
+		StartTime: now,
+	}
+
+	// A second drill on the same day gets its own namespace
+	if exists, _ := zfs.DatasetExists(report.Namespace); exists {
+		report.Namespace = fmt.Sprintf("%s/%s", d.namespaceRoot(), now.Format("2006-01-02_15-04-05"))
+	}
+
+	d.mutex.Lock()
+	d.reports = append(d.reports, report)
+	started := *report
+	d.mutex.Unlock()
+
+	go func() {
+		defer d.drillMutex.Unlock()
+		d.runDrill(report, targets)
+	}()
+
+	return &started, nil
+}
+
+func (d *DrillManager) runDrill(report *DrillReport, targets []DrillTarget) {
+	logger.Info("Starting DR drill", "drill", report.ID, "namespace", report.Namespace, "datasets", len(targets))
+
+	var results []DrillDatasetResult
+	nsErr := zfs.CreateDataset(report.Namespace)
+
+	for _, target := range targets {
+		result := DrillDatasetResult{SourceDataset: target.Dataset, Snapshot: target.Snapshot}
+		if nsErr != nil {
+			result.Error = fmt.Sprintf("failed to create drill namespace: %v", nsErr)
+		} else {
+			d.drillDataset(report.Namespace, &result)
+		}
+		results = append(results, result)
+	}
+
+	cleanedUp := false
+	if d.config.Drill.Cleanup && nsErr == nil {
+		if err := zfs.DestroyDataset(report.Namespace); err != nil {
+			logger.Error("Failed to clean up DR drill", "drill", report.ID, "namespace", report.Namespace, "err", err)
+		} else {
+			cleanedUp = true
+		}
+	}
+
+	end := time.Now()
+	status := "passed"
+	for _, result := range results {
+		if !result.Passed {
+			status = "failed"
+		}
+	}
+
+	d.mutex.Lock()
+	report.Datasets = results
+	report.CleanedUp = cleanedUp
+	report.EndTime = &end
+	report.Duration = end.Sub(report.StartTime)
+	report.Status = status
+	d.saveReportsLocked()
+	d.mutex.Unlock()
+
+	logger.Info("DR drill "+status, "drill", report.ID, "duration", report.Duration.Round(time.Second))
+}
+
+func (d *DrillManager) drillDataset(namespace string, result *DrillDatasetResult) {
+	if result.Snapshot == "" {
+		snapshots, err := d.transport.GetSnapshotsForDataset(result.SourceDataset)
+		if err != nil || len(snapshots) == 0 {
+			result.Error = fmt.Sprintf("no snapshots found for %s: %v", result.SourceDataset, err)
+			return
+		}
+		result.Snapshot = snapshots[len(snapshots)-1]
+	}
+
+	result.RestoredDataset = receivedDatasetName(namespace, result.SourceDataset)
+
+	start := time.Now()
+	// Safe mode: the drill namespace is fresh, so nothing may be overwritten
+	err := d.transport.RestoreSnapshotFromDatasetSafe(result.SourceDataset, result.Snapshot, namespace)
+	result.RestoreDuration = time.Since(start)
+	if err != nil {
+		result.Error = fmt.Sprintf("restore failed: %v", err)
+		return
+	}
+
+	mountpoint, _ := zfs.GetMountpoint(result.RestoredDataset)
+
+	result.Passed = true
+	for _, hook := range d.config.Drill.Hooks {
+		hookResult := runHook(hook, []string{
+			"ZFSRABBIT_DRILL_DATASET=" + result.RestoredDataset,
+			"ZFSRABBIT_DRILL_SOURCE=" + result.SourceDataset,
+			"ZFSRABBIT_DRILL_SNAPSHOT=" + result.Snapshot,
+			"ZFSRABBIT_DRILL_MOUNTPOINT=" + mountpoint,
+		})
+		if !hookResult.Passed {
+			result.Passed = false
+		}
+		result.Hooks = append(result.Hooks, hookResult)
+	}
+}
+
+// receivedDatasetName mirrors "zfs receive -d": the remote pool name is dropped
+// and the rest of the path is recreated under the namespace
+func receivedDatasetName(namespace, remoteDataset string) string {
+	parts := strings.SplitN(remoteDataset, "/", 2)
+	if len(parts) < 2 {
+		return namespace
+	}
+	return namespace + "/" + parts[1]
+}
+
+func runHook(hook config.DrillHook, env []string) DrillHookResult {
+	return hooks.Run(commands, hook, env)
+}
+
+func (d *DrillManager) saveReportsLocked() {
+	if len(d.reports) > maxDrillReports {
+		d.reports = d.reports[len(d.reports)-maxDrillReports:]
+	}
+
+	path := d.reportsPath()
+	if path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(path, d.reports, 0600); err != nil {
+		logger.Error("Failed to save drill reports", "path", path, "err", err)
+	}
+}
+
+// Compact drops finished reports that ended before before and then the
+// oldest past maxRows (0 keeps every row), returning how many were removed
+func (d *DrillManager) Compact(before time.Time, maxRows int) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var kept []*DrillReport
+	for _, report := range d.reports {
+		if before.IsZero() || report.EndTime == nil || !report.EndTime.Before(before) {
+			kept = append(kept, report)
+		}
+	}
+	if maxRows > 0 && len(kept) > maxRows {
+		kept = kept[len(kept)-maxRows:]
+	}
+
+	removed := len(d.reports) - len(kept)
+	if removed > 0 {
+		d.reports = kept
+		d.saveReportsLocked()
+	}
+	return removed
+}
+
+// ListReports returns drill reports, newest first
+func (d *DrillManager) ListReports() []DrillReport {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	reports := make([]DrillReport, 0, len(d.reports))
+	for _, report := range d.reports {
+		reports = append(reports, *report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].StartTime.After(reports[j].StartTime)
+	})
+	return reports
+}
+
+func (d *DrillManager) GetReport(id string) (DrillReport, bool) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	for _, report := range d.reports {
+		if report.ID == id {
+			return *report, true
+		}
+	}
+	return DrillReport{}, false
+}
+
+// Text renders the report in a plain format suitable for handing to auditors
+func (r *DrillReport) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "ZFSRabbit DR Drill Report\n\n")
+	fmt.Fprintf(&b, "Drill ID: %s\n", r.ID)
+	fmt.Fprintf(&b, "Result: %s\n", strings.ToUpper(r.Status))
+	fmt.Fprintf(&b, "Namespace: %s\n", r.Namespace)
+	fmt.Fprintf(&b, "Started: %s\n", r.StartTime.Format(time.RFC3339))
+	if r.EndTime != nil {
+		fmt.Fprintf(&b, "Finished: %s\n", r.EndTime.Format(time.RFC3339))
+		fmt.Fprintf(&b, "Total Duration: %s\n", r.Duration.Round(time.Second))
+	}
+	fmt.Fprintf(&b, "Cleaned Up: %t\n", r.CleanedUp)
+
+	for _, ds := range r.Datasets {
+		result := "PASSED"
+		if !ds.Passed {
+			result = "FAILED"
+		}
+		fmt.Fprintf(&b, "\nDataset: %s@%s [%s]\n", ds.SourceDataset, ds.Snapshot, result)
+		if ds.RestoredDataset != "" {
+			fmt.Fprintf(&b, "  Restored To: %s\n", ds.RestoredDataset)
+		}
+		fmt.Fprintf(&b, "  Restore Duration: %s\n", ds.RestoreDuration.Round(time.Second))
+		if ds.Error != "" {
+			fmt.Fprintf(&b, "  Error: %s\n", ds.Error)
+		}
+		for _, hook := range ds.Hooks {
+			hookResult := "passed"
+			if !hook.Passed {
+				hookResult = "failed: " + hook.Error
+			}
+			fmt.Fprintf(&b, "  Hook %s: %s (%s)\n", hook.Name, hookResult, hook.Duration.Round(time.Millisecond))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package restore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/state"
+	"zfsrabbit/internal/utils"
+)
+
+func TestDrillDatasetName(t *testing.T) {
+	tests := []struct {
+		namespace string
+		remote    string
+		expected  string
+	}{
+		{"tank/drill/2024-01-01", "backup/data", "tank/drill/2024-01-01/data"},
+		{"tank/drill/2024-01-01", "backup/hosts/web1", "tank/drill/2024-01-01/hosts/web1"},
+		{"tank/drill/2024-01-01", "backup", "tank/drill/2024-01-01"},
+	}
+
+	for _, tt := range tests {
+		if got := receivedDatasetName(tt.namespace, tt.remote); got != tt.expected {
+			t.Errorf("receivedDatasetName(%s, %s) = %s, expected %s", tt.namespace, tt.remote, got, tt.expected)
+		}
+	}
+}
+
+func TestRunDrillHook(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "verify.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"checking $ZFSRABBIT_DRILL_DATASET\"\n[ \"$ZFSRABBIT_DRILL_SNAPSHOT\" = good ]\n"), 0755)
+
+	hook := config.DrillHook{Name: "verify", Command: script}
+
+	result := runHook(hook, []string{"ZFSRABBIT_DRILL_DATASET=tank/drill/x", "ZFSRABBIT_DRILL_SNAPSHOT=good"})
+	if !result.Passed {
+		t.Errorf("Expected hook to pass, got %+v", result)
+	}
+	if result.Output != "checking tank/drill/x" {
+		t.Errorf("Expected hook to see drill environment, got %q", result.Output)
+	}
+
+	result = runHook(hook, []string{"ZFSRABBIT_DRILL_SNAPSHOT=bad"})
+	if result.Passed || result.ExitCode != 1 {
+		t.Errorf("Expected hook to fail with exit 1, got %+v", result)
+	}
+
+	slow := config.DrillHook{Name: "slow", Command: "/bin/sleep", Args: []string{"10"}, Timeout: 100 * time.Millisecond}
+	result = runHook(slow, nil)
+	if result.Passed || !strings.Contains(result.Error, "timed out") {
+		t.Errorf("Expected timeout failure, got %+v", result)
+	}
+}
+
+func TestStartDrillValidation(t *testing.T) {
+	drills := NewDrillManager(&config.Config{ZFS: config.ZFSConfig{Dataset: "tank/data"}}, nil)
+
+	if _, err := drills.StartDrill(nil); err == nil {
+		t.Error("Expected error for empty drill")
+	}
+	if _, err := drills.StartDrill([]DrillTarget{{Dataset: "backup/data; rm -rf /"}}); err == nil {
+		t.Error("Expected error for invalid dataset name")
+	}
+	if drills.namespaceRoot() != "tank/drill" {
+		t.Errorf("Expected default namespace tank/drill, got %s", drills.namespaceRoot())
+	}
+}
+
+func TestDrillReportsPersisted(t *testing.T) {
+	stateDir := t.TempDir()
+	end := time.Date(2024, 6, 1, 10, 30, 0, 0, time.UTC)
+	saved := []*DrillReport{{
+		ID:        "drill_1",
+		Namespace: "tank/drill/2024-06-01",
+		Status:    "failed",
+		StartTime: end.Add(-30 * time.Minute),
+		EndTime:   &end,
+		Duration:  30 * time.Minute,
+		Datasets: []DrillDatasetResult{{
+			SourceDataset:   "backup/data",
+			Snapshot:        "autosnap_2024-06-01",
+			RestoredDataset: "tank/drill/2024-06-01/data",
+			RestoreDuration: 25 * time.Minute,
+			Hooks:           []DrillHookResult{{Name: "checksum", Error: "exit status 1"}},
+		}},
+	}}
+	utils.WriteJSONAtomic(filepath.Join(stateDir, state.DrillsFile), saved, 0600)
+
+	drills := NewDrillManager(&config.Config{Server: config.ServerConfig{StateDir: stateDir}}, nil)
+
+	report, ok := drills.GetReport("drill_1")
+	if !ok {
+		t.Fatal("Expected saved drill report to be loaded")
+	}
+
+	text := report.Text()
+	for _, want := range []string{"Result: FAILED", "backup/data@autosnap_2024-06-01 [FAILED]", "Restore Duration: 25m0s", "Hook checksum: failed: exit status 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected report text to contain %q:\n%s", want, text)
+		}
+	}
+}
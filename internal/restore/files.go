@@ -0,0 +1,423 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/state"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/zfs"
+)
+
+// BrowseRequest selects the snapshot to fetch files from. A local snapshot
+// is read in place through the dataset's .zfs/snapshot directory; a remote
+// one is received into a temporary dataset first.
+type BrowseRequest struct {
+	Dataset  string `json:"dataset,omitempty"` // Defaults to the local or default remote dataset
+	Snapshot string `json:"snapshot"`
+	Local    bool   `json:"local,omitempty"`
+}
+
+// BrowseSession is an open snapshot that files can be listed and downloaded from
+type BrowseSession struct {
+	ID            string    `json:"id"`
+	SourceDataset string    `json:"source_dataset"`
+	Snapshot      string    `json:"snapshot"`
+	Local         bool      `json:"local"`
+	Dataset       string    `json:"dataset,omitempty"` // Temporary dataset a remote snapshot was received into
+	Status        string    `json:"status"`            // preparing, ready, failed
+	Error         string    `json:"error,omitempty"`
+	Created       time.Time `json:"created"`
+	ExpiresAt     time.Time `json:"expires_at"`
+
+	root      string // Directory session paths are relative to, once ready
+	namespace string // Destroyed when the session is closed; empty for local snapshots
+	cancel    context.CancelFunc
+	timer     *time.Timer
+}
+
+// FileEntry describes one file or directory in a snapshot
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // Relative to the snapshot root, starting with /
+	Type    string    `json:"type"` // file, dir, symlink or other
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// FileBrowser restores single files without a full dataset restore. Each
+// session expires after restore.browse_ttl, and closing it destroys any
+// temporary dataset it received.
+type FileBrowser struct {
+	config    *config.Config
+	transport *transport.SSHTransport
+	mutex     sync.Mutex
+	sessions  map[string]*BrowseSession
+}
+
+func NewFileBrowser(cfg *config.Config, transport *transport.SSHTransport) *FileBrowser {
+	return &FileBrowser{
+		config:    cfg,
+		transport: transport,
+		sessions:  make(map[string]*BrowseSession),
+	}
+}
+
+// namespaceRoot is the parent of all temporary browse datasets, e.g. tank/browse
+func (b *FileBrowser) namespaceRoot() string {
+	if b.config.Restore.BrowseNamespace != "" {
+		return b.config.Restore.BrowseNamespace
+	}
+	pool := strings.SplitN(b.config.ZFS.Dataset, "/", 2)[0]
+	return pool + "/browse"
+}
+
+// Open starts a session. Local snapshots are ready at once; remote ones are
+// received in the background while the session is "preparing".
+func (b *FileBrowser) Open(req BrowseRequest) (BrowseSession, error) {
+	if err := validation.ValidateSnapshotName(req.Snapshot); err != nil {
+		return BrowseSession{}, fmt.Errorf("snapshot %s: %w", req.Snapshot, err)
+	}
+
+	dataset := req.Dataset
+	if dataset == "" {
+		if req.Local {
+			dataset = b.config.ZFS.Dataset
+		} else {
+			dataset = b.transport.RemoteDataset()
+		}
+	}
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return BrowseSession{}, fmt.Errorf("dataset %s: %w", dataset, err)
+	}
+
+	now := time.Now()
+	session := &BrowseSession{
+		ID:            fmt.Sprintf("browse_%d", now.UnixNano()),
+		SourceDataset: dataset,
+		Snapshot:      req.Snapshot,
+		Local:         req.Local,
+		Created:       now,
+		ExpiresAt:     now.Add(b.config.Restore.BrowseTTL),
+	}
+
+	var ctx context.Context
+	if req.Local {
+		mountpoint, err := zfs.GetMountpoint(dataset)
+		if err != nil || mountpoint == "" {
+			return BrowseSession{}, fmt.Errorf("%s is not mounted", dataset)
+		}
+		root := filepath.Join(mountpoint, ".zfs", "snapshot", req.Snapshot)
+		if _, err := os.Stat(root); err != nil {
+			return BrowseSession{}, fmt.Errorf("snapshot %s@%s not found: %w", dataset, req.Snapshot, err)
+		}
+		session.root = root
+		session.Status = "ready"
+	} else {
+		session.namespace = fmt.Sprintf("%s/%s", b.namespaceRoot(), session.ID)
+		session.Status = "preparing"
+		ctx, session.cancel = context.WithCancel(context.Background())
+	}
+
+	id := session.ID
+	session.timer = time.AfterFunc(b.config.Restore.BrowseTTL, func() {
+		logger.Info("File restore session expired", "session", id)
+		b.Close(id)
+	})
+
+	b.mutex.Lock()
+	b.sessions[id] = session
+	opened := *session
+	b.mutex.Unlock()
+
+	if !req.Local {
+		go b.receive(ctx, session)
+	}
+
+	logger.Info("Opened file restore session", "session", id, "snapshot", dataset+"@"+req.Snapshot)
+	return opened, nil
+}
+
+// receive fetches a remote snapshot into the session's temporary namespace
+// and mounts it
+func (b *FileBrowser) receive(ctx context.Context, session *BrowseSession) {
+	dataset := receivedDatasetName(session.namespace, session.SourceDataset)
+	root, err := b.receiveAndMount(ctx, session, dataset)
+
+	b.mutex.Lock()
+	_, open := b.sessions[session.ID]
+	if err != nil {
+		session.Status = "failed"
+		session.Error = err.Error()
+	} else {
+		session.Status = "ready"
+		session.Dataset = dataset
+		session.root = root
+	}
+	b.mutex.Unlock()
+
+	if err != nil {
+		logger.Error("File restore session failed", "session", session.ID, "err", err)
+	}
+	// Close leaves the cleanup of a session that was still preparing to us
+	if !open {
+		b.destroy(session)
+	}
+}
+
+func (b *FileBrowser) receiveAndMount(ctx context.Context, session *BrowseSession, dataset string) (string, error) {
+	if err := zfs.CreateDataset(session.namespace); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", session.namespace, err)
+	}
+	mountRoot := filepath.Join(b.mountRoot(), session.ID)
+	if err := os.MkdirAll(mountRoot, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", mountRoot, err)
+	}
+
+	// Safe mode: the namespace is fresh, so nothing may be overwritten. The
+	// tree is received unmounted and moved under mountRoot, as a mountpoint
+	// set on the source would otherwise mount the copy over the live dataset.
+	err := b.transport.Restore(ctx, transport.RestoreRequest{
+		RemoteDataset: session.SourceDataset,
+		Snapshot:      session.Snapshot,
+		LocalDataset:  session.namespace,
+		Mountpoint:    mountRoot,
+	})
+	if err != nil {
+		return "", fmt.Errorf("restore failed: %w", err)
+	}
+
+	datasets, err := zfs.ListFilesystems(dataset)
+	if err != nil {
+		return "", err
+	}
+	for _, child := range datasets {
+		if err := mountUnder(child, mountRoot); err != nil {
+			return "", err
+		}
+	}
+
+	mountpoint, err := zfs.GetMountpoint(dataset)
+	if err != nil || mountpoint == "" {
+		return "", fmt.Errorf("%s is not mounted after zfs mount", dataset)
+	}
+	return mountpoint, nil
+}
+
+// mountUnder mounts a received dataset, first making sure it only mounts
+// when asked to and only somewhere under root
+func mountUnder(dataset, root string) error {
+	props, err := zfs.GetPropertiesContext(context.Background(), dataset, []string{"mountpoint", "canmount"})
+	if err != nil {
+		return err
+	}
+	if props["canmount"] != "noauto" {
+		if err := zfs.SetProperties(dataset, map[string]string{"canmount": "noauto"}); err != nil {
+			return err
+		}
+	}
+	if mountpoint := props["mountpoint"]; mountpoint != root && !strings.HasPrefix(mountpoint, root+"/") {
+		return fmt.Errorf("refusing to mount %s at %s, outside %s", dataset, mountpoint, root)
+	}
+	return zfs.MountDataset(dataset)
+}
+
+// mountRoot is the private directory remote snapshots are mounted under
+func (b *FileBrowser) mountRoot() string {
+	if dir := state.PathIn(b.config.Server.StateDir, state.BrowseDir); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "zfsrabbit-"+state.BrowseDir)
+}
+
+// Close ends a session and destroys its temporary dataset
+func (b *FileBrowser) Close(id string) error {
+	b.mutex.Lock()
+	session, exists := b.sessions[id]
+	if !exists {
+		b.mutex.Unlock()
+		return fmt.Errorf("file restore session %s not found", id)
+	}
+	delete(b.sessions, id)
+	session.timer.Stop()
+	preparing := session.Status == "preparing"
+	b.mutex.Unlock()
+
+	logger.Info("Closing file restore session", "session", id)
+	if session.cancel != nil {
+		session.cancel()
+	}
+	// A receive still in progress cleans up once it has stopped
+	if !preparing {
+		b.destroy(session)
+	}
+	return nil
+}
+
+// CloseAll ends every session, e.g. on shutdown
+func (b *FileBrowser) CloseAll() {
+	for _, session := range b.Sessions() {
+		b.Close(session.ID)
+	}
+}
+
+func (b *FileBrowser) destroy(session *BrowseSession) {
+	if session.namespace == "" {
+		return
+	}
+	if exists, _ := zfs.DatasetExists(session.namespace); exists {
+		if err := zfs.DestroyDataset(session.namespace); err != nil {
+			logger.Error("Failed to clean up file restore session", "session", session.ID, "namespace", session.namespace, "err", err)
+			return
+		}
+	}
+	// Only empty mountpoint directories are left once the datasets are gone
+	if err := os.RemoveAll(filepath.Join(b.mountRoot(), session.ID)); err != nil {
+		logger.Error("Failed to remove file restore session mountpoints", "session", session.ID, "err", err)
+	}
+}
+
+// Session returns a session by ID
+func (b *FileBrowser) Session(id string) (BrowseSession, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	session, exists := b.sessions[id]
+	if !exists {
+		return BrowseSession{}, false
+	}
+	return *session, true
+}
+
+// Sessions returns the open sessions, oldest first
+func (b *FileBrowser) Sessions() []BrowseSession {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sessions := make([]BrowseSession, 0, len(b.sessions))
+	for _, session := range b.sessions {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created.Before(sessions[j].Created)
+	})
+	return sessions
+}
+
+// root returns the directory a ready session's paths are relative to
+func (b *FileBrowser) root(id string) (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	session, exists := b.sessions[id]
+	if !exists {
+		return "", fmt.Errorf("file restore session %s not found", id)
+	}
+	if session.Status != "ready" {
+		return "", fmt.Errorf("file restore session %s is %s", id, session.Status)
+	}
+	return session.root, nil
+}
+
+// List returns the contents of a directory in the session's snapshot
+func (b *FileBrowser) List(id, path string) ([]FileEntry, error) {
+	root, err := b.root(id)
+	if err != nil {
+		return nil, err
+	}
+	dir, rel, err := resolvePath(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileEntry(info, strings.TrimSuffix(rel, "/")+"/"+entry.Name()))
+	}
+	return files, nil
+}
+
+// OpenFile opens a regular file in the session's snapshot for download
+func (b *FileBrowser) OpenFile(id, path string) (*os.File, FileEntry, error) {
+	root, err := b.root(id)
+	if err != nil {
+		return nil, FileEntry{}, err
+	}
+	full, rel, err := resolvePath(root, path)
+	if err != nil {
+		return nil, FileEntry{}, err
+	}
+
+	file, err := os.Open(full)
+	if err != nil {
+		return nil, FileEntry{}, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, FileEntry{}, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, FileEntry{}, fmt.Errorf("%s is not a regular file", rel)
+	}
+	return file, fileEntry(info, rel), nil
+}
+
+// resolvePath maps a path within a snapshot to the local filesystem,
+// following symlinks only as long as they stay inside the snapshot
+func resolvePath(root, path string) (string, string, error) {
+	rel := filepath.ToSlash(filepath.Clean("/" + path))
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", "", err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(resolvedRoot, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", "", fmt.Errorf("%s not found in snapshot", rel)
+	}
+	if resolved != resolvedRoot && !strings.HasPrefix(resolved, resolvedRoot+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("%s is outside the snapshot", rel)
+	}
+	return resolved, rel, nil
+}
+
+func fileEntry(info os.FileInfo, path string) FileEntry {
+	entry := FileEntry{
+		Name:    info.Name(),
+		Path:    path,
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime(),
+	}
+	switch {
+	case info.Mode().IsRegular():
+		entry.Type = "file"
+	case info.IsDir():
+		entry.Type = "dir"
+	case info.Mode()&os.ModeSymlink != 0:
+		entry.Type = "symlink"
+	default:
+		entry.Type = "other"
+	}
+	return entry
+}
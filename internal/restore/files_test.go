@@ -0,0 +1,180 @@
+package restore
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/zfs"
+)
+
+// browseSnapshot opens a ready session on a directory standing in for a snapshot
+func browseSnapshot(t *testing.T) (*FileBrowser, string, string) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "app.conf"), []byte("port=80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("etc/app.conf", filepath.Join(root, "conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewFileBrowser(&config.Config{}, nil)
+	session := &BrowseSession{ID: "browse_test", Status: "ready", root: root, timer: time.NewTimer(time.Hour)}
+	b.sessions[session.ID] = session
+	return b, session.ID, root
+}
+
+func TestFileBrowserList(t *testing.T) {
+	b, id, _ := browseSnapshot(t)
+
+	files, err := b.List(id, "/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	types := make(map[string]string)
+	for _, file := range files {
+		types[file.Path] = file.Type
+	}
+	expected := map[string]string{"/etc": "dir", "/conf": "symlink", "/escape": "symlink"}
+	for path, want := range expected {
+		if types[path] != want {
+			t.Errorf("Expected %s to be a %s, got %q", path, want, types[path])
+		}
+	}
+
+	files, err = b.List(id, "etc")
+	if err != nil || len(files) != 1 || files[0].Path != "/etc/app.conf" || files[0].Size != 8 {
+		t.Errorf("Expected /etc/app.conf in etc, got %+v (%v)", files, err)
+	}
+}
+
+func TestFileBrowserOpenFile(t *testing.T) {
+	b, id, _ := browseSnapshot(t)
+
+	file, entry, err := b.OpenFile(id, "/conf")
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if string(content) != "port=80\n" || entry.Name != "app.conf" {
+		t.Errorf("Expected the linked file's content, got %q from %s", content, entry.Name)
+	}
+
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{"/escape", "outside the snapshot"},
+		{"../../../etc/passwd", "not found"},
+		{"/etc", "not a regular file"},
+		{"/missing", "not found"},
+	}
+	for _, tt := range tests {
+		if _, _, err := b.OpenFile(id, tt.path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("OpenFile(%q): expected error containing %q, got %v", tt.path, tt.wantErr, err)
+		}
+	}
+}
+
+func TestFileBrowserSessions(t *testing.T) {
+	b, id, _ := browseSnapshot(t)
+
+	if _, err := b.Open(BrowseRequest{Snapshot: "bad snapshot!", Local: true}); err == nil {
+		t.Error("Expected an invalid snapshot name to be rejected")
+	}
+
+	b.sessions[id].Status = "preparing"
+	if _, err := b.List(id, "/"); err == nil || !strings.Contains(err.Error(), "preparing") {
+		t.Errorf("Expected a preparing session not to be browsable, got %v", err)
+	}
+
+	if err := b.Close(id); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, exists := b.Session(id); exists {
+		t.Error("Expected the session to be gone once closed")
+	}
+	if err := b.Close(id); err == nil {
+		t.Error("Expected closing an unknown session to fail")
+	}
+}
+
+// receivedZFS answers zfs get with a received dataset's properties and
+// records every zfs command
+type receivedZFS struct {
+	properties string
+	commands   []string
+}
+
+func (r *receivedZFS) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func (r *receivedZFS) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+func (r *receivedZFS) Output(cmd *exec.Cmd) ([]byte, error) {
+	r.commands = append(r.commands, strings.Join(cmd.Args, " "))
+	if len(cmd.Args) > 1 && cmd.Args[1] == "get" {
+		return []byte(r.properties), nil
+	}
+	return nil, nil
+}
+
+func (r *receivedZFS) Run(cmd *exec.Cmd) error {
+	_, err := r.Output(cmd)
+	return err
+}
+
+func TestMountUnderSessionDirectory(t *testing.T) {
+	defer zfs.SetCommandRunner(utils.DefaultRunner)
+	root := "/var/lib/zfsrabbit/browse/browse_1"
+
+	// A child sent with canmount=on is switched to noauto before mounting
+	runner := &receivedZFS{properties: "mountpoint\t" + root + "/db\ncanmount\ton\n"}
+	zfs.SetCommandRunner(runner)
+	if err := mountUnder("tank/browse/browse_1/data/db", root); err != nil {
+		t.Fatalf("mountUnder failed: %v", err)
+	}
+	want := []string{
+		"zfs get -H -o property,value mountpoint,canmount tank/browse/browse_1/data/db",
+		"zfs set canmount=noauto tank/browse/browse_1/data/db",
+		"zfs mount tank/browse/browse_1/data/db",
+	}
+	if !slices.Equal(runner.commands, want) {
+		t.Errorf("Expected commands %q, got %q", want, runner.commands)
+	}
+
+	// A mountpoint that escaped the session directory is never mounted,
+	// as it could be the live dataset's
+	runner = &receivedZFS{properties: "mountpoint\t/srv/db\ncanmount\tnoauto\n"}
+	zfs.SetCommandRunner(runner)
+	if err := mountUnder("tank/browse/browse_1/data/db", root); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Expected a mountpoint outside the session to be refused, got %v", err)
+	}
+	for _, command := range runner.commands {
+		if strings.HasPrefix(command, "zfs mount") {
+			t.Errorf("Expected no mount, ran %q", command)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"zfsrabbit/internal/utils"
+)
+
+// JobInterrupted is the status of a job that was still running, or waiting
+// for confirmation, when the daemon stopped. Its receive died with the
+// daemon, so it has to be started again.
+const JobInterrupted = "interrupted"
+
+// jobRetention is how long a finished job stays listed
+const jobRetention = time.Hour
+
+// storedJob is what is saved of a tracked job
+type storedJob struct {
+	ID               string            `json:"id"`
+	SnapshotName     string            `json:"snapshot"`
+	SourceDataset    string            `json:"source_dataset,omitempty"`
+	TargetDataset    string            `json:"target_dataset"`
+	Status           string            `json:"status"`
+	Progress         int               `json:"progress"`
+	BytesTransferred int64             `json:"bytes_transferred,omitempty"`
+	TotalBytes       int64             `json:"total_bytes,omitempty"`
+	StartTime        time.Time         `json:"start_time"`
+	EndTime          *time.Time        `json:"end_time,omitempty"`
+	Error            string            `json:"error,omitempty"`
+	Mount            MountOptions      `json:"mount"`
+	RestoredDataset  string            `json:"restored_dataset,omitempty"`
+	MountedAt        string            `json:"mounted_at,omitempty"`
+	Hooks            []DrillHookResult `json:"hooks,omitempty"`
+	Tier             string            `json:"tier,omitempty"`
+	Datasets         int               `json:"datasets,omitempty"`
+	DatasetsDone     int               `json:"datasets_done,omitempty"`
+}
+
+// finished reports whether status is one a job ends in
+func finished(status string) bool {
+	switch status {
+	case "completed", "failed", "cancelled", JobInterrupted:
+		return true
+	}
+	return false
+}
+
+// Finished reports whether the job has ended, successfully or not
+func (job *RestoreJob) Finished() bool {
+	return finished(job.Status)
+}
+
+// SetJobStore saves tracked jobs to path whenever one changes status and
+// loads the jobs saved there by the previous run. Jobs that hadn't finished
+// are marked interrupted; jobs that finished over an hour ago are dropped.
+func (r *RestoreManager) SetJobStore(path string) {
+	if path == "" {
+		return
+	}
+
+	var stored []storedJob
+	if err := utils.ReadJSONFile(path, &stored); err != nil {
+		logger.Error("Failed to load restore jobs", "path", path, "err", err)
+	}
+
+	now := time.Now()
+	r.jobsMutex.Lock()
+	defer r.jobsMutex.Unlock()
+	r.path = path
+
+	for _, s := range stored {
+		job := s.job()
+		if !finished(job.Status) {
+			logger.Warn("Restore job was running when zfsrabbit stopped, marking it interrupted", "job", job.ID, "status", job.Status)
+			job.Error = fmt.Errorf("interrupted by a zfsrabbit restart while %s", job.Status)
+			job.Status = JobInterrupted
+			job.RequiresConfirm = false
+			job.EndTime = &now
+		}
+		if job.EndTime == nil || now.Sub(*job.EndTime) >= jobRetention {
+			continue
+		}
+		job.savedStatus = job.Status
+		r.jobs[job.ID] = job
+		r.forgetAfter(job, jobRetention-now.Sub(*job.EndTime))
+	}
+	r.saveLocked()
+}
+
+// saveLocked writes the tracked jobs to the job store; callers hold jobsMutex
+func (r *RestoreManager) saveLocked() {
+	if r.path == "" {
+		return
+	}
+
+	stored := make([]storedJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		stored = append(stored, job.stored())
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].StartTime.Before(stored[j].StartTime) })
+
+	if err := utils.WriteJSONAtomic(r.path, stored, 0600); err != nil {
+		logger.Error("Failed to save restore jobs", "path", r.path, "err", err)
+	}
+}
+
+// forgetAfter stops tracking a job after d if it has finished by then
+func (r *RestoreManager) forgetAfter(job *RestoreJob, d time.Duration) {
+	go func() {
+		time.Sleep(d)
+		r.jobsMutex.Lock()
+		defer r.jobsMutex.Unlock()
+		if finished(job.Status) {
+			delete(r.jobs, job.ID)
+			r.saveLocked()
+		}
+	}()
+}
+
+func (job *RestoreJob) stored() storedJob {
+	s := storedJob{
+		ID:               job.ID,
+		SnapshotName:     job.SnapshotName,
+		SourceDataset:    job.SourceDataset,
+		TargetDataset:    job.TargetDataset,
+		Status:           job.Status,
+		Progress:         job.Progress,
+		BytesTransferred: job.BytesTransferred,
+		TotalBytes:       job.TotalBytes,
+		StartTime:        job.StartTime,
+		EndTime:          job.EndTime,
+		Mount:            job.Mount,
+		RestoredDataset:  job.RestoredDataset,
+		MountedAt:        job.MountedAt,
+		Hooks:            job.Hooks,
+		Tier:             job.Tier,
+		Datasets:         job.Datasets,
+		DatasetsDone:     job.DatasetsDone,
+	}
+	if job.Error != nil {
+		s.Error = job.Error.Error()
+	}
+	return s
+}
+
+// job rebuilds a loaded job. Nothing runs it, so its context is already done.
+func (s storedJob) job() *RestoreJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := &RestoreJob{
+		ID:               s.ID,
+		SnapshotName:     s.SnapshotName,
+		SourceDataset:    s.SourceDataset,
+		TargetDataset:    s.TargetDataset,
+		Status:           s.Status,
+		Progress:         s.Progress,
+		BytesTransferred: s.BytesTransferred,
+		TotalBytes:       s.TotalBytes,
+		StartTime:        s.StartTime,
+		EndTime:          s.EndTime,
+		Mount:            s.Mount,
+		RestoredDataset:  s.RestoredDataset,
+		MountedAt:        s.MountedAt,
+		Hooks:            s.Hooks,
+		Tier:             s.Tier,
+		Datasets:         s.Datasets,
+		DatasetsDone:     s.DatasetsDone,
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+	if s.Error != "" {
+		job.Error = errors.New(s.Error)
+	}
+	return job
+}
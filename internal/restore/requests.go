@@ -0,0 +1,297 @@
+package restore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/validation"
+)
+
+const maxRequests = 500
+
+// Request statuses
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+	RequestRejected = "rejected"
+)
+
+// Request is a restore asked for by someone who can't run restores
+// themselves. An admin approves it, which starts the restore, or rejects it.
+type Request struct {
+	ID            string     `json:"id"`
+	Snapshot      string     `json:"snapshot"`
+	SourceDataset string     `json:"source_dataset,omitempty"`
+	TargetDataset string     `json:"target_dataset"`
+	Mountpoint    string     `json:"mountpoint,omitempty"`
+	Reason        string     `json:"reason,omitempty"`
+	RequestedBy   string     `json:"requested_by"`
+	Requested     time.Time  `json:"requested"`
+	Status        string     `json:"status"`
+	DecidedBy     string     `json:"decided_by,omitempty"`
+	Decided       *time.Time `json:"decided,omitempty"`
+	Note          string     `json:"note,omitempty"` // Admin's comment on the decision
+	JobID         string     `json:"job_id,omitempty"`
+}
+
+// Notifier tells admins about new requests and requesters about decisions
+type Notifier interface {
+	SendAlert(subject, body string) error
+}
+
+// Requests is the persistent queue of delegated restore requests
+type Requests struct {
+	manager  *RestoreManager
+	notifier Notifier
+	path     string
+	mutex    sync.Mutex
+	requests []*Request
+}
+
+// NewRequests loads the request queue at path; an empty path keeps it in memory only
+func NewRequests(manager *RestoreManager, path string, notifier Notifier) *Requests {
+	q := &Requests{manager: manager, notifier: notifier, path: path}
+
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &q.requests); err != nil {
+			logger.Error("Failed to load restore requests", "path", path, "err", err)
+		}
+	}
+
+	return q
+}
+
+// Create queues a request and notifies admins
+func (q *Requests) Create(req Request) (Request, error) {
+	if err := validation.ValidateSnapshotName(req.Snapshot); err != nil {
+		return Request{}, fmt.Errorf("snapshot: %w", err)
+	}
+	if err := validation.ValidateDatasetName(req.TargetDataset); err != nil {
+		return Request{}, fmt.Errorf("target dataset: %w", err)
+	}
+	if req.SourceDataset != "" {
+		if err := validation.ValidateDatasetName(req.SourceDataset); err != nil {
+			return Request{}, fmt.Errorf("source dataset: %w", err)
+		}
+	}
+	if req.Mountpoint != "" {
+		if err := validation.ValidateMountpoint(req.Mountpoint); err != nil {
+			return Request{}, err
+		}
+	}
+	if req.RequestedBy == "" {
+		return Request{}, fmt.Errorf("requester is required")
+	}
+
+	req.ID = fmt.Sprintf("request_%d", time.Now().UnixNano())
+	req.Requested = time.Now()
+	req.Status = RequestPending
+	req.DecidedBy, req.Decided, req.Note, req.JobID = "", nil, "", ""
+
+	q.mutex.Lock()
+	stored := req
+	q.requests = append(q.requests, &stored)
+	q.saveLocked()
+	q.mutex.Unlock()
+
+	logger.Info("Restore requested", "request", req.ID, "user", req.RequestedBy, "snapshot", sourceOrDefault(req.SourceDataset)+"@"+req.Snapshot, "target", req.TargetDataset)
+	q.notify(fmt.Sprintf("Restore Request: %s", req.ID), req,
+		"A restore was requested and is waiting for an admin to approve or reject it.")
+	return req, nil
+}
+
+// Approve starts the requested restore. The request stays pending if the
+// restore can't start, e.g. because another one is running.
+func (q *Requests) Approve(id, admin, note string) (Request, error) {
+	q.mutex.Lock()
+	req, err := q.pendingLocked(id)
+	if err != nil {
+		q.mutex.Unlock()
+		return Request{}, err
+	}
+
+	mount := MountOptions{Mountpoint: req.Mountpoint}
+	job, err := q.manager.StartRestoreWithOptions(req.SourceDataset, req.Snapshot, req.TargetDataset, mount)
+	if err != nil {
+		q.mutex.Unlock()
+		return Request{}, fmt.Errorf("failed to start restore: %w", err)
+	}
+
+	q.decideLocked(req, RequestApproved, admin, note)
+	req.JobID = job.ID
+	q.saveLocked()
+	approved := *req
+	q.mutex.Unlock()
+
+	logger.Info("Restore request approved", "request", id, "user", admin, "job", job.ID)
+	q.notify(fmt.Sprintf("Restore Request Approved: %s", id), approved,
+		fmt.Sprintf("Approved by %s. Restore job %s has started.", admin, job.ID))
+	return approved, nil
+}
+
+// Reject closes a pending request without restoring anything
+func (q *Requests) Reject(id, admin, note string) (Request, error) {
+	q.mutex.Lock()
+	req, err := q.pendingLocked(id)
+	if err != nil {
+		q.mutex.Unlock()
+		return Request{}, err
+	}
+
+	q.decideLocked(req, RequestRejected, admin, note)
+	q.saveLocked()
+	rejected := *req
+	q.mutex.Unlock()
+
+	logger.Info("Restore request rejected", "request", id, "user", admin)
+	q.notify(fmt.Sprintf("Restore Request Rejected: %s", id), rejected,
+		fmt.Sprintf("Rejected by %s.", admin))
+	return rejected, nil
+}
+
+// Get returns a request by ID
+func (q *Requests) Get(id string) (Request, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, req := range q.requests {
+		if req.ID == id {
+			return *req, true
+		}
+	}
+	return Request{}, false
+}
+
+// List returns requests newest first. A non-empty requester limits the list
+// to that user's own requests.
+func (q *Requests) List(requester string) []Request {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	requests := make([]Request, 0, len(q.requests))
+	for _, req := range q.requests {
+		if requester == "" || req.RequestedBy == requester {
+			requests = append(requests, *req)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Requested.After(requests[j].Requested) })
+	return requests
+}
+
+// Compact drops decided requests older than before and then the oldest
+// decided ones past maxRows (0 keeps every row), returning how many were
+// removed. Pending requests are never dropped.
+func (q *Requests) Compact(before time.Time, maxRows int) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	decided := 0
+	for _, req := range q.requests {
+		if req.Status != RequestPending {
+			decided++
+		}
+	}
+
+	var kept []*Request
+	for _, req := range q.requests {
+		if req.Status != RequestPending {
+			expired := !before.IsZero() && req.Decided != nil && req.Decided.Before(before)
+			if expired || (maxRows > 0 && decided > maxRows) {
+				decided--
+				continue
+			}
+		}
+		kept = append(kept, req)
+	}
+
+	removed := len(q.requests) - len(kept)
+	if removed > 0 {
+		q.requests = kept
+		q.saveLocked()
+	}
+	return removed
+}
+
+func (q *Requests) pendingLocked(id string) (*Request, error) {
+	for _, req := range q.requests {
+		if req.ID != id {
+			continue
+		}
+		if req.Status != RequestPending {
+			return nil, fmt.Errorf("restore request %s is already %s", id, req.Status)
+		}
+		return req, nil
+	}
+	return nil, fmt.Errorf("restore request %s not found", id)
+}
+
+func (q *Requests) decideLocked(req *Request, status, admin, note string) {
+	now := time.Now()
+	req.Status = status
+	req.DecidedBy = admin
+	req.Decided = &now
+	req.Note = note
+}
+
+// saveLocked persists the queue, dropping the oldest decided requests past
+// the limit; callers hold the mutex
+func (q *Requests) saveLocked() {
+	for len(q.requests) > maxRequests {
+		dropped := false
+		for i, req := range q.requests {
+			if req.Status != RequestPending {
+				q.requests = append(q.requests[:i], q.requests[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			break
+		}
+	}
+
+	if q.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(q.path, q.requests, 0600); err != nil {
+		logger.Error("Failed to save restore requests", "path", q.path, "err", err)
+	}
+}
+
+func (q *Requests) notify(subject string, req Request, summary string) {
+	if q.notifier == nil {
+		return
+	}
+
+	body := fmt.Sprintf(`Restore Request
+
+Request: %s
+Requested By: %s
+Snapshot: %s@%s
+Target: %s
+`, req.ID, req.RequestedBy, sourceOrDefault(req.SourceDataset), req.Snapshot, req.TargetDataset)
+	if req.Mountpoint != "" {
+		body += fmt.Sprintf("Mountpoint: %s\n", req.Mountpoint)
+	}
+	if req.Reason != "" {
+		body += fmt.Sprintf("Reason: %s\n", req.Reason)
+	}
+	if req.Note != "" {
+		body += fmt.Sprintf("Note: %s\n", req.Note)
+	}
+	body += "\n" + summary + "\n"
+
+	if err := q.notifier.SendAlert(subject, body); err != nil {
+		logger.Error("Failed to send restore request notification", "err", err)
+	}
+}
+
+func sourceOrDefault(source string) string {
+	if source == "" {
+		return "default remote dataset"
+	}
+	return source
+}
@@ -0,0 +1,136 @@
+package restore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	subjects []string
+}
+
+func (n *recordingNotifier) SendAlert(subject, body string) error {
+	n.subjects = append(n.subjects, subject)
+	return nil
+}
+
+func TestRequestsCreateValidates(t *testing.T) {
+	q := NewRequests(nil, "", nil)
+
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"bad snapshot", Request{Snapshot: "bad;snap", TargetDataset: "tank/restored", RequestedBy: "alice"}, "snapshot"},
+		{"bad target", Request{Snapshot: "autosnap_2026-01-01_02-00-00", TargetDataset: "/tank", RequestedBy: "alice"}, "target dataset"},
+		{"bad mountpoint", Request{Snapshot: "autosnap_2026-01-01_02-00-00", TargetDataset: "tank/restored", Mountpoint: "srv", RequestedBy: "alice"}, "absolute path"},
+		{"no requester", Request{Snapshot: "autosnap_2026-01-01_02-00-00", TargetDataset: "tank/restored"}, "requester"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := q.Create(tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if len(q.List("")) != 0 {
+		t.Error("Invalid requests should not be queued")
+	}
+}
+
+func TestRequestsRejectAndList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restore_requests.json")
+	notifier := &recordingNotifier{}
+	q := NewRequests(nil, path, notifier)
+
+	alice, err := q.Create(Request{Snapshot: "autosnap_2026-01-01_02-00-00", TargetDataset: "tank/alice", RequestedBy: "alice"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if alice.Status != RequestPending {
+		t.Errorf("Expected pending, got %s", alice.Status)
+	}
+	if _, err := q.Create(Request{Snapshot: "autosnap_2026-01-02_02-00-00", TargetDataset: "tank/bob", RequestedBy: "bob", Reason: "lost a file"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if got := q.List("alice"); len(got) != 1 || got[0].ID != alice.ID {
+		t.Errorf("Expected only alice's request, got %+v", got)
+	}
+	if got := q.List(""); len(got) != 2 {
+		t.Errorf("Expected both requests, got %d", len(got))
+	}
+
+	rejected, err := q.Reject(alice.ID, "admin", "use the snapshot browser")
+	if err != nil {
+		t.Fatalf("Reject failed: %v", err)
+	}
+	if rejected.Status != RequestRejected || rejected.DecidedBy != "admin" || rejected.Decided == nil || rejected.Note == "" {
+		t.Errorf("Unexpected rejected request: %+v", rejected)
+	}
+
+	if _, err := q.Reject(alice.ID, "admin", ""); err == nil || !strings.Contains(err.Error(), "already rejected") {
+		t.Errorf("Expected already rejected error, got %v", err)
+	}
+	if _, err := q.Approve(alice.ID, "admin", ""); err == nil || !strings.Contains(err.Error(), "already rejected") {
+		t.Errorf("Expected approving a rejected request to fail, got %v", err)
+	}
+	if _, err := q.Reject("request_missing", "admin", ""); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	if len(notifier.subjects) != 3 || notifier.subjects[2] != "Restore Request Rejected: "+alice.ID {
+		t.Errorf("Unexpected notifications: %v", notifier.subjects)
+	}
+
+	reloaded := NewRequests(nil, path, nil)
+	got, ok := reloaded.Get(alice.ID)
+	if !ok || got.Status != RequestRejected {
+		t.Errorf("Expected rejected request to persist, got %+v (found %v)", got, ok)
+	}
+}
+
+func TestRequestsCompactKeepsPending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restore_requests.json")
+	q := NewRequests(nil, path, nil)
+
+	var ids []string
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		req, err := q.Create(Request{Snapshot: "autosnap_2026-01-01_02-00-00", TargetDataset: "tank/" + user, RequestedBy: user})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		ids = append(ids, req.ID)
+	}
+	for _, id := range ids[:3] {
+		if _, err := q.Reject(id, "admin", ""); err != nil {
+			t.Fatalf("Reject failed: %v", err)
+		}
+	}
+
+	// Backdate alice's decision past the history window
+	old := time.Now().AddDate(-1, 0, 0)
+	q.requests[0].Decided = &old
+
+	if removed := q.Compact(time.Now().AddDate(0, 0, -30), 1); removed != 2 {
+		t.Errorf("Expected alice (expired) and bob (over the limit) removed, got %d", removed)
+	}
+
+	reloaded := NewRequests(nil, path, nil)
+	remaining := reloaded.List("")
+	if len(remaining) != 2 {
+		t.Fatalf("Expected carol's decided and dave's pending request, got %+v", remaining)
+	}
+	if _, ok := reloaded.Get(ids[3]); !ok {
+		t.Error("Expected the pending request to survive compaction")
+	}
+	if _, ok := reloaded.Get(ids[2]); !ok {
+		t.Error("Expected the newest decided request to be kept")
+	}
+}
@@ -1,21 +1,55 @@
 package restore
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"zfsrabbit/internal/catalog"
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/logging"
+	"zfsrabbit/internal/throughput"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/validation"
 	"zfsrabbit/internal/zfs"
 )
 
+var logger = logging.For("restore")
+
 type RestoreManager struct {
 	transport    *transport.SSHTransport
 	zfsManager   *zfs.Manager
-	restoreMutex sync.Mutex // Prevents concurrent restore operations
+	mountHooks   []config.RestoreHook
+	postHooks    []config.PostRestoreHook
+	catalog      *catalog.Catalog    // Locates snapshots archived off the backup pool
+	throughput   *throughput.History // Past transfer rates, for restore time estimates
+	events       *events.Bus         // Receives job status and progress changes
+	parallelism  int                 // Datasets of a tree received at once; 1 sends the tree as one stream
+	restoreMutex sync.Mutex          // Prevents concurrent restore operations
+	draining     atomic.Bool         // Set once shutdown begins; no new restores start
+
+	// Tracked jobs. Every change to a job's state is made under jobsMutex,
+	// and callers only ever get copies, so they never see a half-made change.
+	jobs      map[string]*RestoreJob
+	jobsMutex sync.RWMutex
+	path      string // Job store; "" keeps jobs in memory only
+}
+
+// MountOptions controls how a restored dataset is made available once it is received
+type MountOptions struct {
+	Mountpoint string     // Set on the restored dataset; the inherited mountpoint is kept if empty
+	Mount      bool       // Mount it and run the mount hooks; implied by Mountpoint
+	Shares     zfs.Shares // Share properties set once mounted; implies Mount
+}
+
+// enabled reports whether the restore should be mounted
+func (o MountOptions) enabled() bool {
+	return o.Mount || o.Mountpoint != "" || !o.Shares.IsZero()
 }
 
 type RestoreJob struct {
@@ -23,7 +57,7 @@ type RestoreJob struct {
 	SnapshotName     string
 	SourceDataset    string
 	TargetDataset    string
-	Status           string // starting, verifying, safety_check, awaiting_confirmation, restoring, completed, failed
+	Status           string // starting, verifying, safety_check, awaiting_confirmation, restoring, completed, failed, cancelled, interrupted
 	Progress         int
 	BytesTransferred int64   // Actual bytes transferred
 	TotalBytes       int64   // Total bytes to transfer (estimated)
@@ -35,20 +69,175 @@ type RestoreJob struct {
 	RequiresConfirm  bool   // True if destructive operation needs user confirmation
 	SafetyWarning    string // Warning message about data loss
 	ForceConfirmed   bool   // Set to true by user to proceed with destructive operation
+	Mount            MountOptions
+	RestoredDataset  string            // Local dataset the snapshot was received into
+	MountedAt        string            // Where the restored files can be found, once mounted
+	Hooks            []DrillHookResult // Mount and post-restore hook results
+	Tier             string            // Where the snapshot was restored from: "pool" or an archive tier
+	Datasets         int               // Datasets in the tree, when received one at a time in parallel
+	DatasetsDone     int               // Datasets of the tree received so far
+
+	ctx         context.Context // Cancelled by CancelJob
+	cancel      context.CancelFunc
+	savedStatus string // Status last written to the job store
+}
+
+// commands runs zfs diff and restore and drill hooks
+var commands utils.CommandRunner = utils.DefaultRunner
+
+// SetCommandRunner replaces the runner used for zfs diff and hooks
+func SetCommandRunner(runner utils.CommandRunner) {
+	commands = runner
 }
 
 func New(transport *transport.SSHTransport, zfsManager *zfs.Manager) *RestoreManager {
 	return &RestoreManager{
-		transport:  transport,
-		zfsManager: zfsManager,
+		transport:   transport,
+		zfsManager:  zfsManager,
+		jobs:        make(map[string]*RestoreJob),
+		parallelism: 1,
 	}
 }
 
+// update changes a job's state atomically with respect to GetJob and ListJobs
+func (r *RestoreManager) update(job *RestoreJob, change func(job *RestoreJob)) {
+	r.jobsMutex.Lock()
+	defer r.jobsMutex.Unlock()
+	change(job)
+	r.publishLocked(job)
+}
+
+// publishLocked announces a job's current state, and saves the jobs if its
+// status changed; callers hold jobsMutex
+func (r *RestoreManager) publishLocked(job *RestoreJob) {
+	r.events.Publish(events.TypeRestore, events.RestoreChange{ID: job.ID, Status: job.Status, Progress: job.Progress})
+	if _, tracked := r.jobs[job.ID]; tracked && job.Status != job.savedStatus {
+		job.savedStatus = job.Status
+		r.saveLocked()
+	}
+}
+
+// copy returns a snapshot of the job that is safe to read while it runs;
+// callers hold jobsMutex
+func (job *RestoreJob) copy() *RestoreJob {
+	c := *job
+	c.Hooks = append([]DrillHookResult(nil), job.Hooks...)
+	if job.EndTime != nil {
+		endTime := *job.EndTime
+		c.EndTime = &endTime
+	}
+	return &c
+}
+
+// SetMountHooks sets the hooks run after a restore is mounted
+func (r *RestoreManager) SetMountHooks(hooks []config.RestoreHook) {
+	r.mountHooks = hooks
+}
+
+// SetPostHooks sets the hooks run after restores into matching datasets
+func (r *RestoreManager) SetPostHooks(hooks []config.PostRestoreHook) {
+	r.postHooks = hooks
+}
+
+// SetParallelism sets how many datasets of a snapshot's tree are received
+// at once. With 1, the default, the tree is sent as a single stream.
+func (r *RestoreManager) SetParallelism(n int) {
+	r.parallelism = max(n, 1)
+}
+
+// SetEvents publishes job status and progress changes to bus
+func (r *RestoreManager) SetEvents(bus *events.Bus) {
+	r.events = bus
+}
+
+// SetCatalog lets restores find snapshots that tiering moved to the archive
+func (r *RestoreManager) SetCatalog(c *catalog.Catalog) {
+	r.catalog = c
+}
+
+// SetThroughput records restore transfer rates in history and estimates
+// restore times from it
+func (r *RestoreManager) SetThroughput(history *throughput.History) {
+	r.throughput = history
+}
+
+// Estimate is the size of a snapshot's restore stream and how long
+// receiving it should take
+type Estimate struct {
+	Bytes    int64
+	Duration time.Duration // 0 when there is no throughput history to go on
+	Tier     string        // "pool" or the archive tier holding the snapshot
+}
+
+// EstimateRestore sizes restoring snapshotName from sourceDataset (the
+// default remote dataset if empty) with a dry-run send on the backup server,
+// or from the catalog for archived snapshots, and estimates the duration
+// from the throughput of recent restores and sends with that server
+func (r *RestoreManager) EstimateRestore(sourceDataset, snapshotName string) (Estimate, error) {
+	if err := validation.ValidateSnapshotName(snapshotName); err != nil {
+		return Estimate{}, fmt.Errorf("invalid snapshot name: %w", err)
+	}
+	if sourceDataset == "" {
+		sourceDataset = r.transport.RemoteDataset()
+	} else if err := validation.ValidateDatasetName(sourceDataset); err != nil {
+		return Estimate{}, fmt.Errorf("invalid source dataset: %w", err)
+	}
+
+	estimate := Estimate{Tier: "pool"}
+	if archived, ok := r.lookupArchived(sourceDataset, snapshotName); ok {
+		chain, err := r.catalog.ArchiveChain(sourceDataset, snapshotName)
+		if err != nil {
+			return Estimate{}, err
+		}
+		for _, stream := range chain {
+			estimate.Bytes += stream.Size
+		}
+		estimate.Tier = archived.Tier
+	} else {
+		size, err := r.transport.RemoteSendSize(sourceDataset, snapshotName)
+		if err != nil {
+			return Estimate{}, fmt.Errorf("failed to size %s@%s: %w", sourceDataset, snapshotName, err)
+		}
+		estimate.Bytes = size
+	}
+
+	if r.throughput != nil {
+		estimate.Duration = r.throughput.Estimate(r.transport.RemoteHost(), throughput.Restore, estimate.Bytes)
+	}
+	return estimate, nil
+}
+
+// restoreChain receives an incrementally archived snapshot: the full stream
+// its chain starts from, then each incremental stream in turn
+func (r *RestoreManager) restoreChain(job *RestoreJob, req transport.RestoreRequest, chain []catalog.Archived) error {
+	for i, stream := range chain {
+		link := req
+		link.ArchivePath = stream.Location
+		link.TotalBytes = stream.Size
+		if i > 0 {
+			// Only this restore has written to the dataset since the last stream
+			link.Force = true
+		}
+		logger.Info("Receiving archived stream", "job", job.ID, "stream", i+1, "streams", len(chain), "location", stream.Location)
+		if err := r.transport.Restore(job.ctx, link); err != nil {
+			return fmt.Errorf("archived stream %s: %w", stream.Location, err)
+		}
+	}
+	return nil
+}
+
+func (r *RestoreManager) lookupArchived(dataset, snapshot string) (catalog.Archived, bool) {
+	if r.catalog == nil {
+		return catalog.Archived{}, false
+	}
+	return r.catalog.LookupArchived(dataset, snapshot)
+}
+
 // ConfirmDestructiveRestore allows user to confirm and proceed with a destructive restore
 func (r *RestoreManager) ConfirmDestructiveRestore(jobID string) error {
-	activeJobsMutex.Lock()
-	defer activeJobsMutex.Unlock()
-	job, exists := activeJobs[jobID]
+	r.jobsMutex.Lock()
+	defer r.jobsMutex.Unlock()
+	job, exists := r.jobs[jobID]
 	if !exists {
 		return fmt.Errorf("restore job %s not found", jobID)
 	}
@@ -61,7 +250,11 @@ func (r *RestoreManager) ConfirmDestructiveRestore(jobID string) error {
 		return fmt.Errorf("restore job %s does not require confirmation", jobID)
 	}
 
-	log.Printf("User confirmed destructive restore for job %s - proceeding with data loss", jobID)
+	if r.draining.Load() {
+		return errShuttingDown
+	}
+
+	logger.Warn("User confirmed destructive restore, proceeding with data loss", "job", jobID)
 
 	// Set confirmation flag and restart the restore process
 	job.ForceConfirmed = true
@@ -79,6 +272,29 @@ func (r *RestoreManager) RestoreSnapshot(snapshotName, targetDataset string) (*R
 }
 
 func (r *RestoreManager) RestoreSnapshotFromDataset(sourceDataset, snapshotName, targetDataset string) (*RestoreJob, error) {
+	job, err := r.newJob(sourceDataset, snapshotName, targetDataset, MountOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return r.start(job), nil
+}
+
+// newJob validates the mount options and returns a job ready to start
+func (r *RestoreManager) newJob(sourceDataset, snapshotName, targetDataset string, mount MountOptions) (*RestoreJob, error) {
+	if mount.Mountpoint != "" {
+		if err := validation.ValidateMountpoint(mount.Mountpoint); err != nil {
+			return nil, err
+		}
+	}
+	for _, share := range []string{mount.Shares.NFS, mount.Shares.SMB} {
+		if share != "" {
+			if err := validation.ValidateShareOption(share); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	job := &RestoreJob{
 		ID:            generateJobID(),
 		SnapshotName:  snapshotName,
@@ -87,11 +303,21 @@ func (r *RestoreManager) RestoreSnapshotFromDataset(sourceDataset, snapshotName,
 		Status:        "starting",
 		Progress:      0,
 		StartTime:     time.Now(),
+		Mount:         mount,
+		ctx:           ctx,
+		cancel:        cancel,
 	}
+	return job, nil
+}
 
-	go r.performRestore(job)
+// start runs a job in the background and returns a copy of its initial state
+func (r *RestoreManager) start(job *RestoreJob) *RestoreJob {
+	r.jobsMutex.RLock()
+	started := job.copy()
+	r.jobsMutex.RUnlock()
 
-	return job, nil
+	go r.performRestore(job)
+	return started
 }
 
 func (r *RestoreManager) performRestore(job *RestoreJob) {
@@ -99,21 +325,16 @@ func (r *RestoreManager) performRestore(job *RestoreJob) {
 	if job.SourceDataset != "" {
 		sourceInfo = job.SourceDataset
 	}
-	log.Printf("Starting restore job %s: %s@%s -> %s", job.ID, sourceInfo, job.SnapshotName, job.TargetDataset)
+	logger.Info("Starting restore job", "job", job.ID, "snapshot", sourceInfo+"@"+job.SnapshotName, "target", job.TargetDataset)
 
 	defer func() {
-		if r := recover(); r != nil {
-			job.Status = "failed"
-			job.Error = fmt.Errorf("restore panic: %v", r)
-			endTime := time.Now()
-			job.EndTime = &endTime
-			log.Printf("Restore job %s failed with panic: %v", job.ID, r)
+		if p := recover(); p != nil {
+			r.failJob(job, fmt.Errorf("restore panic: %v", p))
 		}
 	}()
 
 	// CRITICAL SAFETY CHECK: Check if target dataset exists and warn about data loss
-	job.Status = "safety_check"
-	job.Progress = 5
+	r.setStep(job, "safety_check", 5)
 
 	exists, err := r.checkTargetDatasetExists(job.TargetDataset)
 	if err != nil {
@@ -130,9 +351,7 @@ func (r *RestoreManager) performRestore(job *RestoreJob) {
 
 		if hasUncommittedData && !job.ForceConfirmed {
 			// STOP and require manual confirmation
-			job.RequiresConfirm = true
-			job.Status = "awaiting_confirmation"
-			job.SafetyWarning = fmt.Sprintf("⚠️  DESTRUCTIVE OPERATION WARNING ⚠️\n\n"+
+			warning := fmt.Sprintf("⚠️  DESTRUCTIVE OPERATION WARNING ⚠️\n\n"+
 				"Target dataset '%s' contains data that will be PERMANENTLY LOST.\n"+
 				"ZFS restore will roll back to the snapshot, destroying any changes made after the last snapshot.\n\n"+
 				"🚨 DO NOT proceed if you have active workloads writing to this filesystem!\n\n"+
@@ -141,15 +360,19 @@ func (r *RestoreManager) performRestore(job *RestoreJob) {
 				"2. Manually confirm you want to lose uncommitted data\n"+
 				"3. Click 'Force Restore' to proceed\n\n"+
 				"This action cannot be undone!", job.TargetDataset, job.TargetDataset)
+			r.update(job, func(job *RestoreJob) {
+				job.RequiresConfirm = true
+				job.Status = "awaiting_confirmation"
+				job.SafetyWarning = warning
+			})
 
-			log.Printf("Restore job %s requires manual confirmation - target dataset has uncommitted data", job.ID)
+			logger.Warn("Restore job requires manual confirmation, target dataset has uncommitted data", "job", job.ID, "target", job.TargetDataset)
 			return // Wait for user confirmation
 		}
 	}
 
 	// Step 1: Verify remote snapshot exists
-	job.Status = "verifying"
-	job.Progress = 10
+	r.setStep(job, "verifying", 10)
 
 	var remoteSnapshots []string
 	var remoteErr error
@@ -178,14 +401,32 @@ func (r *RestoreManager) performRestore(job *RestoreJob) {
 		}
 	}
 
+	tier := "pool"
+	var archived catalog.Archived
+	var chain []catalog.Archived
 	if !found {
-		r.failJob(job, fmt.Errorf("snapshot %s not found on remote server", job.SnapshotName))
-		return
+		source := job.SourceDataset
+		if source == "" {
+			source = r.transport.RemoteDataset()
+		}
+		archived, found = r.lookupArchived(source, job.SnapshotName)
+		if !found {
+			r.failJob(job, fmt.Errorf("snapshot %s not found on remote server", job.SnapshotName))
+			return
+		}
+		var err error
+		if chain, err = r.catalog.ArchiveChain(source, job.SnapshotName); err != nil {
+			r.failJob(job, err)
+			return
+		}
+		tier = archived.Tier
+		logger.Info("Snapshot is archived, restoring from the archive", "job", job.ID, "snapshot", source+"@"+job.SnapshotName, "location", archived.Location, "earlier_streams", len(chain)-1)
 	}
 
+	r.update(job, func(job *RestoreJob) { job.Tier = tier })
+
 	// Step 2: Check if target dataset exists and handle appropriately
-	job.Status = "preparing"
-	job.Progress = 20
+	r.setStep(job, "preparing", 20)
 
 	// Check if target dataset already exists
 	exists, existsErr := r.datasetExists(job.TargetDataset)
@@ -196,61 +437,280 @@ func (r *RestoreManager) performRestore(job *RestoreJob) {
 
 	if exists {
 		// If dataset exists, we'll use -F flag to force overwrite
-		log.Printf("Target dataset %s exists, will overwrite", job.TargetDataset)
+		logger.Info("Target dataset exists, will overwrite", "job", job.ID, "target", job.TargetDataset)
 	}
 
 	// Step 3: Initiate restore from remote
-	job.Status = "restoring"
-	job.Progress = 30
-
-	var restoreErr error
+	r.setStep(job, "restoring", 30)
+
+	req := transport.RestoreRequest{
+		RemoteDataset: job.SourceDataset,
+		Snapshot:      job.SnapshotName,
+		LocalDataset:  job.TargetDataset,
+		Force:         job.ForceConfirmed,
+		Raw:           r.zfsManager.RawSend(),
+		Progress: func(progress transport.ProgressInfo) {
+			r.update(job, func(job *RestoreJob) { updateProgress(job, progress) })
+		},
+	}
+	if archived.Location != "" {
+		req.RemoteDataset = archived.Dataset
+		req.ArchivePath = archived.Location
+		req.TotalBytes = archived.Size
+	}
 	if job.ForceConfirmed {
 		// User confirmed destructive operation - use force mode
-		log.Printf("Restore job %s: Using DESTRUCTIVE mode (user confirmed)", job.ID)
-		if job.SourceDataset != "" {
-			restoreErr = r.transport.RestoreSnapshotFromDataset(job.SourceDataset, job.SnapshotName, job.TargetDataset)
-		} else {
-			restoreErr = r.transport.RestoreSnapshot(job.SnapshotName, job.TargetDataset)
-		}
+		logger.Warn("Using destructive mode (user confirmed)", "job", job.ID)
 	} else {
 		// Use safe mode - will fail if conflicts exist
-		log.Printf("Restore job %s: Using SAFE mode (no data loss)", job.ID)
-		if job.SourceDataset != "" {
-			restoreErr = r.transport.RestoreSnapshotFromDatasetSafe(job.SourceDataset, job.SnapshotName, job.TargetDataset)
-		} else {
-			restoreErr = r.transport.RestoreSnapshotSafe(job.SnapshotName, job.TargetDataset)
-		}
+		logger.Info("Using safe mode (no data loss)", "job", job.ID)
+	}
+	transferStarted := time.Now()
+	var restoreErr error
+	if len(chain) > 1 {
+		restoreErr = r.restoreChain(job, req, chain)
+	} else if tree := r.parallelTree(job, req); len(tree) > 1 {
+		logger.Info("Receiving dataset tree", "job", job.ID, "datasets", len(tree), "parallelism", r.parallelism)
+		restoreErr = r.restoreTree(job, req, tree, r.transport.Restore)
+	} else {
+		restoreErr = r.transport.Restore(job.ctx, req)
 	}
 
 	if restoreErr != nil {
 		r.failJob(job, fmt.Errorf("restore failed: %w", restoreErr))
 		return
 	}
+	if utils.DefaultRunner.DryRun() {
+		// Nothing was received, so there is nothing to verify or mount
+		r.completeJob(job)
+		return
+	}
+	if r.throughput != nil {
+		r.jobsMutex.RLock()
+		received := job.BytesTransferred
+		r.jobsMutex.RUnlock()
+		r.throughput.Record(r.transport.RemoteHost(), throughput.Restore, received, time.Since(transferStarted))
+	}
 
 	// Step 4: Verify restore completed successfully
-	job.Status = "verifying"
-	job.Progress = 90
+	r.setStep(job, "verifying", 90)
 
 	if err := r.verifyRestore(job.TargetDataset, job.SnapshotName); err != nil {
 		r.failJob(job, fmt.Errorf("restore verification failed: %w", err))
 		return
 	}
 
-	// Step 5: Complete
-	job.Status = "completed"
-	job.Progress = 100
-	endTime := time.Now()
-	job.EndTime = &endTime
+	if job.ctx.Err() != nil {
+		r.failJob(job, job.ctx.Err())
+		return
+	}
+
+	// Step 5: Make the restored files available to whoever asked for them
+	if job.Mount.enabled() {
+		r.setStep(job, "mounting", 95)
+
+		if err := r.mountRestored(job); err != nil {
+			r.failJob(job, fmt.Errorf("restore succeeded but mounting failed: %w", err))
+			return
+		}
+	}
+
+	// Step 6: Fix up the restored files, e.g. ownership from another host
+	r.runPostHooks(job)
+
+	// Step 7: Complete
+	r.completeJob(job)
+}
+
+func (r *RestoreManager) completeJob(job *RestoreJob) {
+	job.cancel()
+	r.update(job, func(job *RestoreJob) {
+		job.Status = "completed"
+		job.Progress = 100
+		endTime := time.Now()
+		job.EndTime = &endTime
+	})
+
+	logger.Info("Restore job completed successfully", "job", job.ID)
+}
+
+// setStep moves a job on to the next step of the restore
+func (r *RestoreManager) setStep(job *RestoreJob, status string, progress int) {
+	r.update(job, func(job *RestoreJob) {
+		job.Status = status
+		job.Progress = progress
+	})
+}
+
+// updateProgress records transfer progress, moving the job's progress from
+// 30% (transfer started) towards 90% as the stream is received
+func updateProgress(job *RestoreJob, progress transport.ProgressInfo) {
+	job.BytesTransferred = progress.BytesTransferred
+	job.TotalBytes = progress.TotalBytes
+	job.TransferRate = progress.TransferRate
+	job.ETA = progress.ETA
+	if progress.TotalBytes > 0 {
+		job.Progress = 30 + int(progress.Percentage*60/100)
+	}
+}
+
+// mountRestored sets the requested mountpoint, mounts the restored dataset
+// and runs the mount hooks. Hook failures are recorded but don't fail the job,
+// since the data is restored and mounted either way.
+func (r *RestoreManager) mountRestored(job *RestoreJob) error {
+	dataset, source := r.restoredDataset(job)
+	r.update(job, func(job *RestoreJob) { job.RestoredDataset = dataset })
+
+	if job.Mount.Mountpoint != "" {
+		if err := zfs.SetMountpoint(dataset, job.Mount.Mountpoint); err != nil {
+			return err
+		}
+	}
+
+	mountpoint, err := zfs.GetMountpoint(dataset)
+	if err != nil {
+		return fmt.Errorf("failed to check mountpoint of %s: %w", dataset, err)
+	}
+	if mountpoint == "" {
+		if err := zfs.MountDataset(dataset); err != nil {
+			return err
+		}
+		if mountpoint, err = zfs.GetMountpoint(dataset); err != nil || mountpoint == "" {
+			return fmt.Errorf("%s is not mounted after zfs mount", dataset)
+		}
+	}
+	r.update(job, func(job *RestoreJob) { job.MountedAt = mountpoint })
+	logger.Info("Restored dataset mounted", "job", job.ID, "dataset", dataset, "mountpoint", mountpoint)
+
+	if !job.Mount.Shares.IsZero() {
+		if err := zfs.SetShares(dataset, job.Mount.Shares); err != nil {
+			return err
+		}
+		logger.Info("Restored dataset shared", "job", job.ID, "dataset", dataset, "sharenfs", job.Mount.Shares.NFS, "sharesmb", job.Mount.Shares.SMB)
+	}
+
+	for _, hook := range r.mountHooks {
+		result := runHook(hook, []string{
+			"ZFSRABBIT_RESTORE_JOB_ID=" + job.ID,
+			"ZFSRABBIT_RESTORE_DATASET=" + dataset,
+			"ZFSRABBIT_RESTORE_SOURCE=" + source,
+			"ZFSRABBIT_RESTORE_SNAPSHOT=" + job.SnapshotName,
+			"ZFSRABBIT_RESTORE_MOUNTPOINT=" + mountpoint,
+		})
+		if !result.Passed {
+			logger.Error("Mount hook failed", "job", job.ID, "hook", hook.Name, "err", result.Error)
+		}
+		r.update(job, func(job *RestoreJob) { job.Hooks = append(job.Hooks, result) })
+	}
+
+	return nil
+}
+
+// restoredDataset returns the local dataset a job receives into and the
+// remote dataset it came from
+func (r *RestoreManager) restoredDataset(job *RestoreJob) (dataset, source string) {
+	source = job.SourceDataset
+	if source == "" {
+		source = r.transport.RemoteDataset()
+	}
+	return receivedDatasetName(job.TargetDataset, source), source
+}
+
+// runPostHooks runs the post-restore hooks matching the restored dataset and
+// logs their output with the job. Like mount hooks, a failure is recorded on
+// the job but doesn't fail it.
+func (r *RestoreManager) runPostHooks(job *RestoreJob) {
+	dataset, source := r.restoredDataset(job)
 
-	log.Printf("Restore job %s completed successfully", job.ID)
+	var hooks []config.PostRestoreHook
+	for _, hook := range r.postHooks {
+		if hook.Matches(dataset) {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	r.setStep(job, "post_hooks", 98)
+	r.update(job, func(job *RestoreJob) { job.RestoredDataset = dataset })
+
+	// Unmounted restores may still be mounted at an inherited mountpoint
+	mountpoint := job.MountedAt
+	if mountpoint == "" {
+		mountpoint, _ = zfs.GetMountpoint(dataset)
+	}
+
+	for _, hook := range hooks {
+		result := runHook(hook.RestoreHook, []string{
+			"ZFSRABBIT_RESTORE_JOB_ID=" + job.ID,
+			"ZFSRABBIT_RESTORE_DATASET=" + dataset,
+			"ZFSRABBIT_RESTORE_SOURCE=" + source,
+			"ZFSRABBIT_RESTORE_SNAPSHOT=" + job.SnapshotName,
+			"ZFSRABBIT_RESTORE_MOUNTPOINT=" + mountpoint,
+		})
+		for _, line := range strings.Split(result.Output, "\n") {
+			if line != "" {
+				logger.Info("Post hook output", "job", job.ID, "hook", hook.Name, "line", line)
+			}
+		}
+		if !result.Passed {
+			logger.Error("Post hook failed", "job", job.ID, "hook", hook.Name, "err", result.Error)
+		}
+		r.update(job, func(job *RestoreJob) { job.Hooks = append(job.Hooks, result) })
+	}
 }
 
 func (r *RestoreManager) failJob(job *RestoreJob, err error) {
-	job.Status = "failed"
-	job.Error = err
+	r.update(job, func(job *RestoreJob) { finishLocked(job, err) })
+}
+
+// finishLocked ends a job that didn't complete; callers hold jobsMutex
+func finishLocked(job *RestoreJob, err error) {
 	endTime := time.Now()
 	job.EndTime = &endTime
-	log.Printf("Restore job %s failed: %v", job.ID, err)
+
+	// A step failing because the job was cancelled is a cancellation, not a failure
+	cancelled := job.ctx.Err() != nil
+	job.cancel()
+	if cancelled {
+		job.Status = "cancelled"
+		logger.Info("Restore job cancelled", "job", job.ID)
+		return
+	}
+
+	job.Status = "failed"
+	job.Error = err
+	logger.Error("Restore job failed", "job", job.ID, "err", err)
+}
+
+// CancelJob aborts a restore: a running transfer is stopped by ending the
+// remote send and killing the local receive, and a restore awaiting
+// confirmation is dropped. A restore that already finished can't be cancelled.
+func (r *RestoreManager) CancelJob(jobID string) error {
+	r.jobsMutex.Lock()
+	defer r.jobsMutex.Unlock()
+	job, exists := r.jobs[jobID]
+	if !exists {
+		return fmt.Errorf("restore job %s not found", jobID)
+	}
+
+	switch {
+	case finished(job.Status):
+		return fmt.Errorf("restore job %s already %s", jobID, job.Status)
+	case job.Status == "awaiting_confirmation":
+		// Nothing is running, so the job is cancelled right away
+		job.RequiresConfirm = false
+		job.SafetyWarning = ""
+		job.cancel()
+		finishLocked(job, context.Canceled)
+		r.publishLocked(job)
+	default:
+		job.cancel()
+	}
+
+	logger.Info("Restore job cancellation requested", "job", jobID)
+	return nil
 }
 
 func (r *RestoreManager) datasetExists(dataset string) (bool, error) {
@@ -309,8 +769,8 @@ func (r *RestoreManager) checkForUncommittedData(dataset string) (bool, error) {
 
 func (r *RestoreManager) checkZFSDiffSinceSnapshot(dataset, snapshotName string) (bool, error) {
 	// Use ZFS diff - the ONE way to detect changes since snapshot
-	cmd := exec.Command("zfs", "diff", fmt.Sprintf("%s@%s", dataset, snapshotName))
-	output, err := cmd.Output()
+	cmd := commands.Command("zfs", "diff", fmt.Sprintf("%s@%s", dataset, snapshotName))
+	output, err := commands.Output(cmd)
 
 	if err != nil {
 		return false, fmt.Errorf("zfs diff command failed for %s@%s: %w", dataset, snapshotName, err)
@@ -322,7 +782,7 @@ func (r *RestoreManager) checkZFSDiffSinceSnapshot(dataset, snapshotName string)
 		return false, nil // No changes detected
 	}
 
-	log.Printf("ZFS diff detected changes in %s since %s:\n%s", dataset, snapshotName, diffOutput)
+	logger.Info("ZFS diff detected changes", "dataset", dataset, "snapshot", snapshotName, "changes", diffOutput)
 	return true, nil
 }
 
@@ -347,23 +807,24 @@ func generateJobID() string {
 	return fmt.Sprintf("restore_%d", time.Now().UnixNano())
 }
 
-// Job tracking for web interface
-var activeJobs = make(map[string]*RestoreJob)
-var activeJobsMutex sync.RWMutex // Protects activeJobs map
-
+// GetJob returns a copy of a tracked job's current state
 func (r *RestoreManager) GetJob(id string) (*RestoreJob, bool) {
-	activeJobsMutex.RLock()
-	defer activeJobsMutex.RUnlock()
-	job, exists := activeJobs[id]
-	return job, exists
+	r.jobsMutex.RLock()
+	defer r.jobsMutex.RUnlock()
+	job, exists := r.jobs[id]
+	if !exists {
+		return nil, false
+	}
+	return job.copy(), true
 }
 
+// ListJobs returns copies of every tracked job's current state
 func (r *RestoreManager) ListJobs() []*RestoreJob {
-	activeJobsMutex.RLock()
-	defer activeJobsMutex.RUnlock()
-	jobs := make([]*RestoreJob, 0, len(activeJobs))
-	for _, job := range activeJobs {
-		jobs = append(jobs, job)
+	r.jobsMutex.RLock()
+	defer r.jobsMutex.RUnlock()
+	jobs := make([]*RestoreJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		jobs = append(jobs, job.copy())
 	}
 	return jobs
 }
@@ -373,38 +834,34 @@ func (r *RestoreManager) StartRestoreWithTracking(snapshotName, targetDataset st
 }
 
 func (r *RestoreManager) StartRestoreFromDatasetWithTracking(sourceDataset, snapshotName, targetDataset string) (*RestoreJob, error) {
+	return r.StartRestoreWithOptions(sourceDataset, snapshotName, targetDataset, MountOptions{})
+}
+
+// StartRestoreWithOptions starts a tracked restore that is mounted as
+// requested once received. An empty sourceDataset uses the default remote dataset.
+func (r *RestoreManager) StartRestoreWithOptions(sourceDataset, snapshotName, targetDataset string, mount MountOptions) (*RestoreJob, error) {
+	if r.draining.Load() {
+		return nil, errShuttingDown
+	}
 	// Check if a restore is already in progress
 	if !r.restoreMutex.TryLock() {
 		return nil, fmt.Errorf("restore operation already in progress")
 	}
 	defer r.restoreMutex.Unlock()
 
-	var job *RestoreJob
-	var err error
-
-	if sourceDataset != "" {
-		job, err = r.RestoreSnapshotFromDataset(sourceDataset, snapshotName, targetDataset)
-	} else {
-		job, err = r.RestoreSnapshot(snapshotName, targetDataset)
-	}
-
+	job, err := r.newJob(sourceDataset, snapshotName, targetDataset, mount)
 	if err != nil {
 		return nil, err
 	}
 
-	activeJobsMutex.Lock()
-	activeJobs[job.ID] = job
-	activeJobsMutex.Unlock()
+	// Track the job before it starts so its first state change is visible
+	r.jobsMutex.Lock()
+	r.jobs[job.ID] = job
+	r.publishLocked(job)
+	r.jobsMutex.Unlock()
 
 	// Clean up completed jobs after 1 hour
-	go func() {
-		time.Sleep(1 * time.Hour)
-		if job.Status == "completed" || job.Status == "failed" {
-			activeJobsMutex.Lock()
-			delete(activeJobs, job.ID)
-			activeJobsMutex.Unlock()
-		}
-	}()
+	r.forgetAfter(job, jobRetention)
 
-	return job, nil
+	return r.start(job), nil
 }
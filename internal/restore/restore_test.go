@@ -1,10 +1,19 @@
 package restore
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"zfsrabbit/internal/catalog"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/throughput"
 	"zfsrabbit/internal/transport"
 	"zfsrabbit/internal/zfs"
 )
@@ -160,8 +169,8 @@ func TestStartRestoreWithTracking(t *testing.T) {
 		t.Error("Expected job to be tracked")
 	}
 
-	if trackedJob != job {
-		t.Error("Expected tracked job to be the same instance")
+	if trackedJob.ID != job.ID || trackedJob.SnapshotName != job.SnapshotName {
+		t.Error("Expected tracked job to match the started job")
 	}
 }
 
@@ -225,8 +234,8 @@ func TestGetJob(t *testing.T) {
 		t.Error("Expected job to exist")
 	}
 
-	if retrievedJob != job {
-		t.Error("Expected retrieved job to be the same instance")
+	if retrievedJob.ID != job.ID || retrievedJob.TargetDataset != job.TargetDataset {
+		t.Error("Expected retrieved job to match the started job")
 	}
 }
 
@@ -286,3 +295,324 @@ func TestListJobs(t *testing.T) {
 func TestPerformRestore_SkipIntegration(t *testing.T) {
 	t.Skip("Skipping restore integration test - requires ZFS and SSH connectivity")
 }
+
+func TestRestoreWithInvalidMountpoint(t *testing.T) {
+	sshTransport := transport.NewSSHTransport(&config.SSHConfig{RemoteDataset: "backup/test"})
+	manager := New(sshTransport, zfs.New("tank/test", "lz4", false))
+
+	_, err := manager.StartRestoreWithOptions("", "test-snapshot", "tank/restored", MountOptions{Mountpoint: "/srv/../etc"})
+	if err == nil {
+		t.Fatal("Expected an invalid mountpoint to be rejected before the restore starts")
+	}
+}
+
+func TestMountOptionsEnabled(t *testing.T) {
+	if (MountOptions{}).enabled() {
+		t.Error("Expected no mounting by default")
+	}
+	if !(MountOptions{Mount: true}).enabled() || !(MountOptions{Mountpoint: "/srv/restore"}).enabled() {
+		t.Error("Expected Mount or a Mountpoint to enable mounting")
+	}
+}
+
+func TestUpdateProgress(t *testing.T) {
+	job := &RestoreJob{Progress: 30}
+
+	updateProgress(job, transport.ProgressInfo{BytesTransferred: 512, TransferRate: 1.5})
+	if job.Progress != 30 || job.BytesTransferred != 512 {
+		t.Errorf("Expected bytes without a percentage when the size is unknown, got %d%% and %d bytes", job.Progress, job.BytesTransferred)
+	}
+
+	updateProgress(job, transport.ProgressInfo{BytesTransferred: 500, TotalBytes: 1000, Percentage: 50, ETA: "10s"})
+	if job.Progress != 60 {
+		t.Errorf("Expected half the transfer to be 60%%, got %d%%", job.Progress)
+	}
+	if job.TotalBytes != 1000 || job.ETA != "10s" {
+		t.Errorf("Expected total and ETA to be recorded, got %d and %q", job.TotalBytes, job.ETA)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	manager := New(transport.NewSSHTransport(&config.SSHConfig{RemoteDataset: "backup/test"}), zfs.New("tank/test", "lz4", false))
+
+	track := func(status string) *RestoreJob {
+		ctx, cancel := context.WithCancel(context.Background())
+		job := &RestoreJob{ID: generateJobID(), Status: status, RequiresConfirm: status == "awaiting_confirmation", ctx: ctx, cancel: cancel}
+		manager.jobsMutex.Lock()
+		manager.jobs[job.ID] = job
+		manager.jobsMutex.Unlock()
+		return job
+	}
+
+	waiting := track("awaiting_confirmation")
+	if err := manager.CancelJob(waiting.ID); err != nil {
+		t.Fatalf("CancelJob failed: %v", err)
+	}
+	if waiting.Status != "cancelled" || waiting.EndTime == nil || waiting.Error != nil {
+		t.Errorf("Expected the waiting job to be cancelled without an error, got %s (%v)", waiting.Status, waiting.Error)
+	}
+	if err := manager.ConfirmDestructiveRestore(waiting.ID); err == nil {
+		t.Error("Expected a cancelled job not to be confirmable")
+	}
+
+	running := track("restoring")
+	if err := manager.CancelJob(running.ID); err != nil {
+		t.Fatalf("CancelJob failed: %v", err)
+	}
+	if running.ctx.Err() == nil {
+		t.Error("Expected the running job's transfer to be cancelled")
+	}
+
+	failed := track("restoring")
+	manager.failJob(failed, errors.New("cannot receive: out of space"))
+	if failed.Status != "failed" || failed.Error == nil || failed.EndTime == nil {
+		t.Errorf("Expected a job that wasn't cancelled to fail with its error, got %s (%v)", failed.Status, failed.Error)
+	}
+
+	if err := manager.CancelJob(track("completed").ID); err == nil {
+		t.Error("Expected a completed job not to be cancellable")
+	}
+	if err := manager.CancelJob("restore_missing"); err == nil {
+		t.Error("Expected an unknown job to fail")
+	}
+
+	got, ok := manager.GetJob(running.ID)
+	if !ok {
+		t.Fatal("Expected the running job to be tracked")
+	}
+	got.Status = "changed"
+	if again, _ := manager.GetJob(running.ID); again.Status == "changed" {
+		t.Error("Expected GetJob to return a copy of the job")
+	}
+}
+
+func TestEstimateRestore(t *testing.T) {
+	cfg := &config.SSHConfig{
+		RemoteHost:    "test.example.com",
+		RemoteUser:    "testuser",
+		RemoteDataset: "backup/test",
+	}
+	manager := New(transport.NewSSHTransport(cfg), zfs.New("tank/test", "lz4", false))
+
+	archive := catalog.Open("")
+	archive.RecordArchived(catalog.Archived{Dataset: "backup/test", Snapshot: "old", Tier: catalog.TierArchive, Location: "/archive/old.zfs", Size: 90 * 1024 * 1024})
+	manager.SetCatalog(archive)
+
+	estimate, err := manager.EstimateRestore("", "old")
+	if err != nil {
+		t.Fatalf("EstimateRestore failed: %v", err)
+	}
+	if estimate.Bytes != 90*1024*1024 || estimate.Tier != catalog.TierArchive || estimate.Duration != 0 {
+		t.Errorf("Expected the archived size without a duration, got %+v", estimate)
+	}
+
+	// Restores from this server ran at 1 MiB/s; sends elsewhere don't count
+	history := throughput.Open("")
+	history.Record("test.example.com", throughput.Restore, 60*1024*1024, time.Minute)
+	history.Record("other.example.com", throughput.Restore, 600*1024*1024, time.Minute)
+	manager.SetThroughput(history)
+
+	estimate, err = manager.EstimateRestore("backup/test", "old")
+	if err != nil || estimate.Duration != 90*time.Second {
+		t.Errorf("Expected 90s at 1 MiB/s, got %+v (%v)", estimate, err)
+	}
+
+	if _, err := manager.EstimateRestore("", "bad;name"); err == nil {
+		t.Error("Expected an invalid snapshot name to be rejected")
+	}
+}
+
+func TestRunPostHooks(t *testing.T) {
+	manager := New(transport.NewSSHTransport(&config.SSHConfig{RemoteDataset: "backup/home"}), zfs.New("tank/test", "lz4", false))
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "chown.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"fixing $ZFSRABBIT_RESTORE_MOUNTPOINT\"\n"), 0755)
+	hook := func(name, pattern string) config.PostRestoreHook {
+		return config.PostRestoreHook{Dataset: pattern, RestoreHook: config.RestoreHook{Name: name, Command: script}}
+	}
+	manager.SetPostHooks([]config.PostRestoreHook{
+		hook("home", "tank/restored/*"),
+		hook("everything", ""),
+		hook("other", "tank/db/*"),
+	})
+
+	job := &RestoreJob{ID: generateJobID(), TargetDataset: "tank/restored", MountedAt: "/srv/restored"}
+	manager.runPostHooks(job)
+
+	if job.RestoredDataset != "tank/restored/home" {
+		t.Errorf("Expected restored dataset tank/restored/home, got %q", job.RestoredDataset)
+	}
+	if len(job.Hooks) != 2 || job.Hooks[0].Name != "home" || job.Hooks[1].Name != "everything" {
+		t.Fatalf("Expected the two matching hooks to run, got %+v", job.Hooks)
+	}
+	if !job.Hooks[0].Passed || job.Hooks[0].Output != "fixing /srv/restored" {
+		t.Errorf("Expected hook output on the job, got %+v", job.Hooks[0])
+	}
+}
+
+func TestJobStoreMarksUnfinishedJobsInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restore_jobs.json")
+	newManager := func() *RestoreManager {
+		manager := New(transport.NewSSHTransport(&config.SSHConfig{RemoteDataset: "backup/test"}), zfs.New("tank/test", "lz4", false))
+		manager.SetJobStore(path)
+		return manager
+	}
+
+	first := newManager()
+	track := func(id, status string) *RestoreJob {
+		ctx, cancel := context.WithCancel(context.Background())
+		job := &RestoreJob{ID: id, SnapshotName: "autosnap_2024-01-01_00-00-00", TargetDataset: "tank/restored", StartTime: time.Now(), ctx: ctx, cancel: cancel}
+		first.jobsMutex.Lock()
+		first.jobs[id] = job
+		first.jobsMutex.Unlock()
+		first.update(job, func(job *RestoreJob) { job.Status = status })
+		return job
+	}
+	track("restore_running", "restoring")
+	done := track("restore_done", "starting")
+	first.completeJob(done)
+	old := track("restore_old", "failed")
+	first.update(old, func(job *RestoreJob) {
+		ended := time.Now().Add(-2 * jobRetention)
+		job.EndTime = &ended
+		job.Status = "cancelled"
+	})
+
+	second := newManager()
+	running, ok := second.GetJob("restore_running")
+	if !ok {
+		t.Fatal("Expected the running job to be loaded")
+	}
+	if running.Status != JobInterrupted || running.EndTime == nil || running.Error == nil {
+		t.Errorf("Expected the running job to be interrupted with an error, got %s (%v)", running.Status, running.Error)
+	}
+	if err := second.CancelJob(running.ID); err == nil {
+		t.Error("Expected an interrupted job not to be cancellable")
+	}
+	if completed, ok := second.GetJob("restore_done"); !ok || completed.Status != "completed" || completed.Progress != 100 {
+		t.Errorf("Expected the completed job to be loaded as it was, got %+v", completed)
+	}
+	if _, ok := second.GetJob("restore_old"); ok {
+		t.Error("Expected a job that finished over an hour ago to be dropped")
+	}
+
+	// The interrupted status was saved, so a third run loads it unchanged
+	third := newManager()
+	if again, ok := third.GetJob("restore_running"); !ok || again.Error.Error() != running.Error.Error() {
+		t.Errorf("Expected the interrupted job to survive another restart, got %+v", again)
+	}
+}
+
+func TestRestoreTreeParallel(t *testing.T) {
+	manager := New(transport.NewSSHTransport(&config.SSHConfig{RemoteDataset: "backup/vms"}), zfs.New("tank/vms", "lz4", false))
+	manager.SetParallelism(3)
+
+	tree := []transport.DatasetSize{{Dataset: "backup/vms", Bytes: 100}}
+	for i := 0; i < 10; i++ {
+		tree = append(tree, transport.DatasetSize{Dataset: fmt.Sprintf("backup/vms/vm%d", i), Bytes: 100})
+	}
+	job, err := manager.newJob("", "daily", "tank", MountOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	received := make(map[string]bool)
+	running, peak := 0, 0
+	receive := func(ctx context.Context, req transport.RestoreRequest) error {
+		mutex.Lock()
+		if !req.Single || req.TotalBytes != 100 {
+			t.Errorf("Expected a single dataset send with its size, got %+v", req)
+		}
+		if req.RemoteDataset != "backup/vms" && !received["backup/vms"] {
+			t.Errorf("%s started before its parent was received", req.RemoteDataset)
+		}
+		running++
+		peak = max(peak, running)
+		mutex.Unlock()
+
+		req.Progress(transport.ProgressInfo{BytesTransferred: 50})
+		time.Sleep(5 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		received[req.RemoteDataset] = true
+		mutex.Unlock()
+		return nil
+	}
+
+	if err := manager.restoreTree(job, transport.RestoreRequest{Snapshot: "daily", LocalDataset: "tank"}, tree, receive); err != nil {
+		t.Fatalf("restoreTree failed: %v", err)
+	}
+	if len(received) != len(tree) {
+		t.Errorf("Expected %d datasets received, got %d", len(tree), len(received))
+	}
+	if peak > 3 || peak < 2 {
+		t.Errorf("Expected up to 3 receives at once, peak was %d", peak)
+	}
+	if job.Datasets != len(tree) || job.DatasetsDone != len(tree) {
+		t.Errorf("Expected %d of %d datasets done, got %d of %d", len(tree), len(tree), job.DatasetsDone, job.Datasets)
+	}
+	if job.TotalBytes != 1100 || job.BytesTransferred != 1100 {
+		t.Errorf("Expected aggregate progress of 1100 of 1100 bytes, got %d of %d", job.BytesTransferred, job.TotalBytes)
+	}
+}
+
+func TestRestoreTreeStopsOnFailure(t *testing.T) {
+	manager := New(transport.NewSSHTransport(&config.SSHConfig{RemoteDataset: "backup/vms"}), zfs.New("tank/vms", "lz4", false))
+	manager.SetParallelism(2)
+
+	tree := []transport.DatasetSize{{Dataset: "backup/vms"}, {Dataset: "backup/vms/a"}, {Dataset: "backup/vms/a/disk0"}}
+	job, err := manager.newJob("", "daily", "tank", MountOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var started []string
+	receive := func(ctx context.Context, req transport.RestoreRequest) error {
+		started = append(started, req.RemoteDataset)
+		if req.RemoteDataset == "backup/vms/a" {
+			return errors.New("cannot receive: out of space")
+		}
+		return nil
+	}
+
+	err = manager.restoreTree(job, transport.RestoreRequest{Snapshot: "daily", LocalDataset: "tank"}, tree, receive)
+	if err == nil || !strings.Contains(err.Error(), "backup/vms/a: cannot receive") {
+		t.Errorf("Expected the failing dataset's error, got %v", err)
+	}
+	if len(started) != 2 {
+		t.Errorf("Expected the children of a failed dataset not to start, got %v", started)
+	}
+}
+
+func TestDrainWaitsForRunningRestores(t *testing.T) {
+	manager := New(transport.NewSSHTransport(&config.SSHConfig{RemoteDataset: "backup/test"}), zfs.New("tank/test", "lz4", false))
+
+	running, _ := manager.newJob("", "daily", "tank/running", MountOptions{})
+	running.Status = "restoring"
+	waiting, _ := manager.newJob("", "daily", "tank/waiting", MountOptions{})
+	waiting.ID += "_waiting"
+	waiting.Status = "awaiting_confirmation"
+	manager.jobs[running.ID] = running
+	manager.jobs[waiting.ID] = waiting
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if cut := manager.Drain(ctx); len(cut) != 1 || cut[0] != running.ID {
+		t.Errorf("Expected only the receiving restore to be cut off, got %v", cut)
+	}
+
+	if _, err := manager.StartRestoreWithOptions("", "daily", "tank/new", MountOptions{}); err == nil {
+		t.Error("Expected restores to be refused while draining")
+	}
+	if err := manager.ConfirmDestructiveRestore(waiting.ID); err == nil {
+		t.Error("Expected confirmations to be refused while draining")
+	}
+
+	manager.completeJob(running)
+	if cut := manager.Drain(context.Background()); cut != nil {
+		t.Errorf("Expected nothing left running, got %v", cut)
+	}
+}
@@ -0,0 +1,342 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/metrics"
+	"zfsrabbit/internal/throughput"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/zfs"
+)
+
+var (
+	standbyReadyGauge = metrics.NewGauge("zfsrabbit_standby_ready",
+		"1 if the last readiness check found the standby able to take over", "host")
+	standbyFailoverGauge = metrics.NewGauge("zfsrabbit_standby_failover_seconds",
+		"Estimated time until the standby could take over, including catching up", "host")
+)
+
+// Names of the standby readiness checks
+const (
+	CheckReachable  = "reachable"
+	CheckLatest     = "latest_snapshot"
+	CheckMount      = "mount"
+	CheckProperties = "properties"
+)
+
+// StandbyCheck is the outcome of one readiness check
+type StandbyCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// StandbyReport is the result of a readiness check of the standby
+type StandbyReport struct {
+	Checked         time.Time         `json:"checked"`
+	Host            string            `json:"host"`
+	Dataset         string            `json:"dataset"`          // The standby's copy of zfs.dataset
+	Ready           bool              `json:"ready"`            // Every check and probe passed
+	LatestSnapshot  string            `json:"latest_snapshot"`  // Newest snapshot on the source
+	AppliedSnapshot string            `json:"applied_snapshot"` // Newest snapshot the standby has of the source's
+	CatchUpBytes    int64             `json:"catch_up_bytes"`   // Still to be sent before the standby is current
+	Checks          []StandbyCheck    `json:"checks"`
+	Probes          []DrillHookResult `json:"probes"`
+	// How long a failover would take: sending what the standby is missing,
+	// then running the probes. Unknown without transfer history to go on.
+	FailoverEstimate *time.Duration `json:"failover_estimate,omitempty"`
+}
+
+// Summary is the report in one line, e.g. "Failover ready within 12 minutes"
+func (r *StandbyReport) Summary() string {
+	if !r.Ready {
+		var failed []string
+		for _, check := range r.Checks {
+			if !check.Passed {
+				failed = append(failed, check.Name)
+			}
+		}
+		for _, probe := range r.Probes {
+			if !probe.Passed {
+				failed = append(failed, "probe "+probe.Name)
+			}
+		}
+		return "Standby not ready: " + strings.Join(failed, ", ") + " failed"
+	}
+	if r.FailoverEstimate == nil {
+		return "Failover ready once the standby catches up (no transfer history to estimate how long that takes)"
+	}
+	minutes := int(r.FailoverEstimate.Round(time.Minute) / time.Minute)
+	if minutes < 1 {
+		return "Failover ready within 1 minute"
+	}
+	return fmt.Sprintf("Failover ready within %d minutes", minutes)
+}
+
+// Standby keeps the primary backup target as a warm standby. It holds the
+// received datasets read-only or unmounted and periodically checks that the
+// standby has the latest snapshot, that properties match the source and that
+// the configured probes pass.
+type Standby struct {
+	config     *config.Config
+	transport  *transport.SSHTransport
+	zfsManager *zfs.Manager
+	throughput *throughput.History
+	notifier   Notifier
+	now        func() time.Time
+	mutex      sync.RWMutex
+	report     *StandbyReport
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+func NewStandby(cfg *config.Config, transport *transport.SSHTransport, zfsManager *zfs.Manager, notifier Notifier) *Standby {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Standby{
+		config:     cfg,
+		transport:  transport,
+		zfsManager: zfsManager,
+		notifier:   notifier,
+		now:        time.Now,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// SetThroughput estimates catch-up time from the history of past sends
+func (s *Standby) SetThroughput(history *throughput.History) {
+	s.throughput = history
+}
+
+// Start checks readiness every standby.interval until Stop is called
+func (s *Standby) Start() {
+	if !s.config.Standby.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(s.config.Standby.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.Check()
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Standby) Stop() {
+	s.cancel()
+}
+
+// Report returns the latest readiness report; nil before the first check
+func (s *Standby) Report() *StandbyReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.report
+}
+
+// Check enforces the mount mode, runs the readiness checks and probes, and
+// alerts when the standby stops being ready
+func (s *Standby) Check() *StandbyReport {
+	cfg := s.config.Standby
+	report := &StandbyReport{
+		Checked: s.now(),
+		Host:    s.transport.RemoteHost(),
+		Dataset: s.transport.RemoteDataset(),
+	}
+
+	remote, err := s.transport.ListRemoteSnapshots()
+	report.addCheck(CheckReachable, err == nil, errorDetail(err))
+	if err == nil {
+		s.checkLatest(report, remote)
+
+		err = s.transport.KeepStandby(cfg.Mount)
+		report.addCheck(CheckMount, err == nil, errorDetail(err))
+
+		mismatches, err := s.compareProperties(cfg.Properties)
+		if err != nil {
+			report.addCheck(CheckProperties, false, err.Error())
+		} else {
+			report.addCheck(CheckProperties, len(mismatches) == 0, strings.Join(mismatches, ", "))
+		}
+	}
+
+	env := []string{
+		"ZFSRABBIT_STANDBY_HOST=" + report.Host,
+		"ZFSRABBIT_STANDBY_DATASET=" + report.Dataset,
+		"ZFSRABBIT_STANDBY_SNAPSHOT=" + report.AppliedSnapshot,
+	}
+	var probeTime time.Duration
+	for _, probe := range cfg.Probes {
+		result := runHook(probe, env)
+		probeTime += result.Duration
+		report.Probes = append(report.Probes, result)
+	}
+
+	// A standby that's behind can still take over once it has caught up,
+	// which is part of the estimate; one without any snapshot can't
+	report.Ready = report.AppliedSnapshot != ""
+	for _, check := range report.Checks {
+		if !check.Passed && check.Name != CheckLatest {
+			report.Ready = false
+		}
+	}
+	for _, probe := range report.Probes {
+		report.Ready = report.Ready && probe.Passed
+	}
+
+	switch {
+	case !report.Ready:
+	case report.LatestSnapshot == report.AppliedSnapshot:
+		report.FailoverEstimate = &probeTime
+	case s.throughput != nil:
+		if catchUp := s.throughput.Estimate(report.Host, throughput.Send, report.CatchUpBytes); catchUp > 0 {
+			estimate := catchUp + probeTime
+			report.FailoverEstimate = &estimate
+		}
+	}
+
+	s.record(report)
+	return report
+}
+
+// checkLatest compares the newest source snapshot with what the standby has
+// and sizes the send that would bring it up to date
+func (s *Standby) checkLatest(report *StandbyReport, remote []string) {
+	snapshots, err := s.zfsManager.ListSnapshots()
+	if err != nil {
+		report.addCheck(CheckLatest, false, err.Error())
+		return
+	}
+	var local []string
+	for _, snapshot := range snapshots {
+		if snapshot.Dataset == s.config.ZFS.Dataset {
+			local = append(local, snapshot.Name)
+		}
+	}
+
+	report.LatestSnapshot, report.AppliedSnapshot = latestApplied(local, remote)
+	switch {
+	case report.LatestSnapshot == "":
+		report.addCheck(CheckLatest, false, "the source has no snapshots")
+	case report.LatestSnapshot == report.AppliedSnapshot:
+		report.addCheck(CheckLatest, true, report.AppliedSnapshot)
+	default:
+		size, err := s.zfsManager.EstimateSend(report.AppliedSnapshot, report.LatestSnapshot)
+		if err == nil {
+			report.CatchUpBytes = size
+		}
+		applied := report.AppliedSnapshot
+		if applied == "" {
+			applied = "none"
+		}
+		report.addCheck(CheckLatest, false, fmt.Sprintf("%s not applied, the standby has %s", report.LatestSnapshot, applied))
+	}
+}
+
+// latestApplied returns the newest of the local snapshots, oldest first,
+// and the newest of them the standby has
+func latestApplied(local, remote []string) (latest, applied string) {
+	onStandby := make(map[string]bool)
+	for _, name := range remote {
+		onStandby[name] = true
+	}
+	for _, name := range local {
+		latest = name
+		if onStandby[name] {
+			applied = name
+		}
+	}
+	return latest, applied
+}
+
+// compareProperties returns the properties whose value differs between the
+// source and the standby, e.g. "recordsize: 128K on the source, 1M on the standby"
+func (s *Standby) compareProperties(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	local, err := zfs.GetPropertiesContext(s.ctx, s.config.ZFS.Dataset, names)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := s.transport.RemoteProperties(names)
+	if err != nil {
+		return nil, err
+	}
+	return propertyMismatches(names, local, remote), nil
+}
+
+func propertyMismatches(names []string, local, remote map[string]string) []string {
+	var mismatches []string
+	for _, name := range names {
+		if local[name] != remote[name] {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s on the source, %s on the standby", name, local[name], remote[name]))
+		}
+	}
+	return mismatches
+}
+
+// record keeps report as the latest and alerts when the standby was ready
+// (or not checked yet) and no longer is
+func (s *Standby) record(report *StandbyReport) {
+	s.mutex.Lock()
+	previous := s.report
+	s.report = report
+	s.mutex.Unlock()
+
+	ready := 0.0
+	if report.Ready {
+		ready = 1
+	}
+	standbyReadyGauge.Set(ready, report.Host)
+	if report.FailoverEstimate != nil {
+		standbyFailoverGauge.Set(report.FailoverEstimate.Seconds(), report.Host)
+	}
+
+	logger.Info("Standby checked", "host", report.Host, "summary", report.Summary())
+	if report.Ready || (previous != nil && !previous.Ready) {
+		return
+	}
+
+	subject := fmt.Sprintf("[WARNING] Standby Not Ready: %s", report.Host)
+	if err := s.notifier.SendAlert(subject, report.alertBody(s.config.ZFS.Dataset)); err != nil {
+		logger.Error("Failed to send standby alert", "host", report.Host, "err", err)
+	}
+}
+
+func (r *StandbyReport) addCheck(name string, passed bool, detail string) {
+	r.Checks = append(r.Checks, StandbyCheck{Name: name, Passed: passed, Detail: detail})
+}
+
+func (r *StandbyReport) alertBody(dataset string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Standby Readiness\n\nDataset: %s\nStandby: %s:%s\n\n%s\n\n", dataset, r.Host, r.Dataset, r.Summary())
+	for _, check := range r.Checks {
+		if !check.Passed {
+			fmt.Fprintf(&b, "- %s: %s\n", check.Name, check.Detail)
+		}
+	}
+	for _, probe := range r.Probes {
+		if !probe.Passed {
+			fmt.Fprintf(&b, "- probe %s: %s\n", probe.Name, probe.Error)
+		}
+	}
+	return b.String()
+}
+
+func errorDetail(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
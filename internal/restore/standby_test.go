@@ -0,0 +1,88 @@
+package restore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/transport"
+)
+
+func TestLatestApplied(t *testing.T) {
+	tests := []struct {
+		local, remote   []string
+		latest, applied string
+	}{
+		{[]string{"a", "b", "c"}, []string{"a", "b", "c"}, "c", "c"},
+		{[]string{"a", "b", "c"}, []string{"a"}, "c", "a"},
+		{[]string{"b", "c"}, []string{"a"}, "c", ""},
+		{nil, []string{"a"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		latest, applied := latestApplied(tt.local, tt.remote)
+		if latest != tt.latest || applied != tt.applied {
+			t.Errorf("latestApplied(%v, %v) = %q, %q, expected %q, %q", tt.local, tt.remote, latest, applied, tt.latest, tt.applied)
+		}
+	}
+}
+
+func TestPropertyMismatches(t *testing.T) {
+	names := []string{"compression", "recordsize"}
+	local := map[string]string{"compression": "lz4", "recordsize": "128K"}
+
+	if got := propertyMismatches(names, local, map[string]string{"compression": "lz4", "recordsize": "128K"}); len(got) != 0 {
+		t.Errorf("Expected no mismatches, got %v", got)
+	}
+	got := propertyMismatches(names, local, map[string]string{"compression": "lz4", "recordsize": "1M"})
+	if len(got) != 1 || got[0] != "recordsize: 128K on the source, 1M on the standby" {
+		t.Errorf("Expected a recordsize mismatch, got %v", got)
+	}
+}
+
+func TestStandbySummary(t *testing.T) {
+	twelve := 12*time.Minute + 10*time.Second
+	tests := []struct {
+		report StandbyReport
+		want   string
+	}{
+		{StandbyReport{Ready: true, FailoverEstimate: &twelve}, "Failover ready within 12 minutes"},
+		{StandbyReport{Ready: true, FailoverEstimate: new(time.Duration)}, "Failover ready within 1 minute"},
+		{StandbyReport{Ready: true}, "Failover ready once the standby catches up"},
+		{StandbyReport{
+			Checks: []StandbyCheck{{Name: CheckReachable, Passed: true}, {Name: CheckProperties}},
+			Probes: []DrillHookResult{{Name: "postgres"}},
+		}, "Standby not ready: properties, probe postgres failed"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.report.Summary(); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Summary() = %q, expected %q", got, tt.want)
+		}
+	}
+}
+
+func TestStandbyAlertsOnceWhenNotReady(t *testing.T) {
+	notifier := &recordingNotifier{}
+	cfg := &config.Config{SSH: config.SSHConfig{RemoteHost: "standby", RemoteDataset: "backup/data"}}
+	s := NewStandby(cfg, transport.NewSSHTransport(&cfg.SSH), nil, notifier)
+
+	estimate := time.Minute
+	s.record(&StandbyReport{Host: "standby", Ready: true, FailoverEstimate: &estimate})
+	s.record(&StandbyReport{Host: "standby", Checks: []StandbyCheck{{Name: CheckMount, Detail: "permission denied"}}})
+	s.record(&StandbyReport{Host: "standby", Checks: []StandbyCheck{{Name: CheckMount, Detail: "permission denied"}}})
+
+	if len(notifier.subjects) != 1 || notifier.subjects[0] != "[WARNING] Standby Not Ready: standby" {
+		t.Errorf("Expected one alert when the standby stopped being ready, got %v", notifier.subjects)
+	}
+	if got := standbyReadyGauge.Value("standby"); got != 0 {
+		t.Errorf("Expected the ready gauge to be 0, got %v", got)
+	}
+
+	s.record(&StandbyReport{Host: "standby", Ready: true, FailoverEstimate: &estimate})
+	s.record(&StandbyReport{Host: "standby"})
+	if len(notifier.subjects) != 2 {
+		t.Errorf("Expected a new alert after the standby recovered and failed again, got %v", notifier.subjects)
+	}
+}
@@ -0,0 +1,153 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
+)
+
+// receiveFunc receives one stream; the transport's Restore outside of tests
+type receiveFunc func(ctx context.Context, req transport.RestoreRequest) error
+
+// parallelTree lists the datasets to receive one at a time, or nothing if
+// the snapshot goes as a single stream: parallelism is off, the snapshot is
+// archived as one stream file, or the tree can't be listed
+func (r *RestoreManager) parallelTree(job *RestoreJob, req transport.RestoreRequest) []transport.DatasetSize {
+	if r.parallelism <= 1 || req.ArchivePath != "" || utils.DefaultRunner.DryRun() {
+		return nil
+	}
+
+	source := req.RemoteDataset
+	if source == "" {
+		source = r.transport.RemoteDataset()
+	}
+	tree, err := r.transport.RemoteTree(source, req.Snapshot, req.Raw)
+	if err != nil {
+		logger.Warn("Failed to list the datasets to restore, receiving them as one stream", "job", job.ID, "dataset", source, "err", err)
+		return nil
+	}
+	return tree
+}
+
+// restoreTree receives each dataset of a snapshot's tree with its own send,
+// up to parallelism at once, rather than the whole tree as one zfs send -R
+// stream. Hundreds of small datasets, such as one per container or VM, go
+// far faster side by side than one after another. A dataset is received
+// only once its parent has been, so the tree is worked through a level at a
+// time. Progress is reported for the tree as a whole, and the first failure
+// stops the rest.
+func (r *RestoreManager) restoreTree(job *RestoreJob, req transport.RestoreRequest, tree []transport.DatasetSize, receive receiveFunc) error {
+	ctx, cancel := context.WithCancel(job.ctx)
+	defer cancel()
+
+	progress := newTreeProgress(tree, func(info transport.ProgressInfo) {
+		r.update(job, func(job *RestoreJob) { updateProgress(job, info) })
+	})
+	r.update(job, func(job *RestoreJob) {
+		job.Datasets = len(tree)
+		job.DatasetsDone = 0
+	})
+
+	var failOnce sync.Once
+	var failure error
+	slots := make(chan struct{}, r.parallelism)
+
+	for _, level := range treeLevels(tree) {
+		var wg sync.WaitGroup
+		for _, i := range level {
+			slots <- struct{}{}
+			if ctx.Err() != nil {
+				<-slots
+				break
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				child := req
+				child.RemoteDataset = tree[i].Dataset
+				child.Single = true
+				child.TotalBytes = tree[i].Bytes
+				child.Progress = func(info transport.ProgressInfo) { progress.update(i, info.BytesTransferred) }
+
+				if err := receive(ctx, child); err != nil {
+					failOnce.Do(func() {
+						failure = fmt.Errorf("%s: %w", tree[i].Dataset, err)
+						cancel()
+					})
+					return
+				}
+				progress.update(i, tree[i].Bytes)
+				r.update(job, func(job *RestoreJob) { job.DatasetsDone++ })
+			}()
+		}
+		wg.Wait()
+
+		if failure != nil {
+			return failure
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("restore cancelled: %w", err)
+		}
+	}
+	return nil
+}
+
+// treeLevels groups the indexes of a tree's datasets by depth, shallowest first
+func treeLevels(tree []transport.DatasetSize) [][]int {
+	byDepth := make(map[int][]int)
+	for i, dataset := range tree {
+		depth := strings.Count(dataset.Dataset, "/")
+		byDepth[depth] = append(byDepth[depth], i)
+	}
+
+	depths := make([]int, 0, len(byDepth))
+	for depth := range byDepth {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+
+	levels := make([][]int, len(depths))
+	for i, depth := range depths {
+		levels[i] = byDepth[depth]
+	}
+	return levels
+}
+
+// treeProgress adds up the progress of datasets received side by side
+type treeProgress struct {
+	mutex   sync.Mutex
+	bytes   []int64 // Received so far per dataset
+	total   int64
+	started time.Time
+	report  func(transport.ProgressInfo)
+}
+
+func newTreeProgress(tree []transport.DatasetSize, report func(transport.ProgressInfo)) *treeProgress {
+	p := &treeProgress{bytes: make([]int64, len(tree)), started: time.Now(), report: report}
+	for _, dataset := range tree {
+		p.total += dataset.Bytes
+	}
+	return p
+}
+
+// update records how much of dataset i has been received and reports the total
+func (p *treeProgress) update(i int, bytes int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.bytes[i] = bytes
+	var received int64
+	for _, b := range p.bytes {
+		received += b
+	}
+	p.report(transport.MeasureProgress(received, p.total, time.Since(p.started)))
+}
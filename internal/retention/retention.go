@@ -0,0 +1,131 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+// snapshotPrefix and snapshotTimeFormat match the names the scheduler gives
+// its snapshots, e.g. autosnap_2024-01-01_02-00-00
+const (
+	snapshotPrefix     = "autosnap_"
+	snapshotTimeFormat = "2006-01-02_15-04-05"
+)
+
+// Policy keeps snapshots grandfather-father-son style: the newest Last
+// snapshots, plus the newest snapshot in each of the most recent Hourly
+// hours, Daily days, Weekly ISO weeks, Monthly months and Yearly years. A
+// snapshot kept by any rule is kept.
+type Policy struct {
+	Last    int
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+}
+
+// Snapshot is what retention needs to know about a snapshot
+type Snapshot struct {
+	Name    string
+	Created time.Time
+}
+
+// FromConfig returns the retention policy configured for the zfs section
+func FromConfig(cfg *config.ZFSConfig) Policy {
+	return Policy{
+		Last:    cfg.KeepSnapshots,
+		Hourly:  cfg.KeepHourly,
+		Daily:   cfg.KeepDaily,
+		Weekly:  cfg.KeepWeekly,
+		Monthly: cfg.KeepMonthly,
+		Yearly:  cfg.KeepYearly,
+	}
+}
+
+// String summarises the policy for logs and catalog entries, e.g.
+// "keep 7, 24 hourly, 30 daily"
+func (p Policy) String() string {
+	parts := []string{fmt.Sprintf("keep %d", p.Last)}
+	for _, rule := range p.rules() {
+		if rule.count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", rule.count, rule.name))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Prune returns the snapshots the policy doesn't keep, oldest first. The
+// newest snapshot is always kept since the next incremental send needs it.
+func (p Policy) Prune(snapshots []Snapshot) []Snapshot {
+	sorted := make([]Snapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Created.After(sorted[j].Created) })
+
+	keep := make([]bool, len(sorted))
+	for i := 0; i < len(sorted) && i < max(p.Last, 1); i++ {
+		keep[i] = true
+	}
+
+	for _, rule := range p.rules() {
+		kept := 0
+		last := ""
+		for i, snapshot := range sorted {
+			if kept >= rule.count {
+				break
+			}
+			period := rule.period(snapshot.Created)
+			if period == last {
+				continue
+			}
+			keep[i] = true
+			last = period
+			kept++
+		}
+	}
+
+	var pruned []Snapshot
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if !keep[i] {
+			pruned = append(pruned, sorted[i])
+		}
+	}
+	return pruned
+}
+
+// SnapshotTime reads the creation time from a snapshot name the scheduler
+// created. Other snapshots report false and are never pruned by name alone.
+func SnapshotTime(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, snapshotPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	created, err := time.ParseInLocation(snapshotTimeFormat, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return created, true
+}
+
+type rule struct {
+	name   string
+	count  int
+	period func(time.Time) string
+}
+
+func (p Policy) rules() []rule {
+	return []rule{
+		{"hourly", p.Hourly, func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{"daily", p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{"weekly", p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{"monthly", p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{"yearly", p.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+}
@@ -0,0 +1,121 @@
+package retention
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// hourlySnapshots returns one snapshot per hour, oldest first, ending at end
+func hourlySnapshots(end time.Time, hours int) []Snapshot {
+	snapshots := make([]Snapshot, hours)
+	for i := range snapshots {
+		created := end.Add(-time.Duration(hours-1-i) * time.Hour)
+		snapshots[i] = Snapshot{Name: "autosnap_" + created.Format(snapshotTimeFormat), Created: created}
+	}
+	return snapshots
+}
+
+func names(snapshots []Snapshot) []string {
+	out := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		out[i] = snapshot.Name
+	}
+	return out
+}
+
+func TestPruneKeepLast(t *testing.T) {
+	snapshots := hourlySnapshots(time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local), 5)
+
+	pruned := Policy{Last: 2}.Prune(snapshots)
+	if got := strings.Join(names(pruned), ","); got != strings.Join(names(snapshots[:3]), ",") {
+		t.Errorf("Expected the 3 oldest to be pruned oldest first, got %s", got)
+	}
+
+	if pruned := (Policy{}).Prune(snapshots); len(pruned) != 4 {
+		t.Errorf("Expected the newest snapshot to always be kept, got %d pruned", len(pruned))
+	}
+}
+
+func TestPruneGFS(t *testing.T) {
+	// Ten days of hourly snapshots ending at noon
+	end := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	snapshots := hourlySnapshots(end, 10*24)
+
+	policy := Policy{Last: 1, Hourly: 6, Daily: 7}
+	pruned := policy.Prune(snapshots)
+
+	prunedSet := make(map[string]bool)
+	for _, name := range names(pruned) {
+		prunedSet[name] = true
+	}
+	var kept []Snapshot
+	for _, snapshot := range snapshots {
+		if !prunedSet[snapshot.Name] {
+			kept = append(kept, snapshot)
+		}
+	}
+
+	// 6 hourly (today 07:00-12:00, which also covers today's daily) plus the
+	// last snapshot (23:00) of each of the previous 6 days
+	if len(kept) != 12 {
+		t.Fatalf("Expected 12 snapshots kept, got %d: %v", len(kept), names(kept))
+	}
+	for _, snapshot := range kept[:6] {
+		if snapshot.Created.Hour() != 23 {
+			t.Errorf("Expected daily snapshots to be the last of each day, got %s", snapshot.Name)
+		}
+	}
+	if !kept[len(kept)-1].Created.Equal(end) {
+		t.Errorf("Expected newest snapshot to be kept, got %s", kept[len(kept)-1].Name)
+	}
+}
+
+func TestPruneWeeklyMonthlyYearly(t *testing.T) {
+	var snapshots []Snapshot
+	for day := time.Date(2024, 1, 1, 2, 0, 0, 0, time.Local); day.Before(time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)); day = day.AddDate(0, 0, 1) {
+		snapshots = append(snapshots, Snapshot{Name: "autosnap_" + day.Format(snapshotTimeFormat), Created: day})
+	}
+
+	pruned := Policy{Last: 1, Weekly: 4, Monthly: 3, Yearly: 2}.Prune(snapshots)
+	kept := len(snapshots) - len(pruned)
+
+	// Newest (2025-12-31) is the last of its ISO week, month and year; it
+	// counts once for each. Weekly adds 3 Sundays, monthly Nov 30 and Oct 31,
+	// yearly 2024-12-31.
+	if kept != 1+3+2+1 {
+		t.Errorf("Expected 7 snapshots kept, got %d", kept)
+	}
+}
+
+func TestPruneUnsortedInput(t *testing.T) {
+	snapshots := hourlySnapshots(time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local), 3)
+	reversed := []Snapshot{snapshots[2], snapshots[0], snapshots[1]}
+
+	pruned := Policy{Last: 1}.Prune(reversed)
+	if got := strings.Join(names(pruned), ","); got != snapshots[0].Name+","+snapshots[1].Name {
+		t.Errorf("Expected pruning to follow creation time, got %s", got)
+	}
+}
+
+func TestSnapshotTime(t *testing.T) {
+	created, ok := SnapshotTime("autosnap_2026-03-10_02-00-00")
+	if !ok || !created.Equal(time.Date(2026, 3, 10, 2, 0, 0, 0, time.Local)) {
+		t.Errorf("Unexpected time %v (ok %v)", created, ok)
+	}
+
+	for _, name := range []string{"manual-before-upgrade", "autosnap_yesterday"} {
+		if _, ok := SnapshotTime(name); ok {
+			t.Errorf("Expected %s not to parse", name)
+		}
+	}
+}
+
+func TestPolicyString(t *testing.T) {
+	if got := (Policy{Last: 30}).String(); got != "keep 30" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+	if got := (Policy{Last: 7, Hourly: 24, Monthly: 12}).String(); got != "keep 7, 24 hourly, 12 monthly" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+}
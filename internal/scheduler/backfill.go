@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
+)
+
+// BackfillGap is the run of local snapshots one target is missing after the
+// newest snapshot the two have in common
+type BackfillGap struct {
+	Job     string   `json:"job"`
+	Dataset string   `json:"dataset"`
+	Target  string   `json:"target"`
+	From    string   `json:"from,omitempty"` // Newest snapshot both sides have
+	Missing []string `json:"missing"`        // Oldest first
+	Blocker string   `json:"blocker,omitempty"`
+}
+
+// findGap works out which local snapshots a target lacks after the newest
+// common one. Both lists are oldest first. zfs can't insert snapshots before
+// the remote's newest, so a remote that has moved past every local snapshot
+// or shares none can't be backfilled.
+func findGap(local, remote []string) (from string, missing []string, blocker string) {
+	if len(remote) == 0 {
+		return "", nil, "the target has no snapshots yet; the next sync sends a full stream"
+	}
+
+	common := -1
+	for i, name := range local {
+		if slices.Contains(remote, name) {
+			common = i
+		}
+	}
+	if common < 0 {
+		return "", nil, "no snapshot is shared with the target"
+	}
+	from = local[common]
+	if newest := remote[len(remote)-1]; newest != from {
+		return from, nil, fmt.Sprintf("the target's newest snapshot %s no longer exists locally", newest)
+	}
+
+	for _, name := range local[common+1:] {
+		if !slices.Contains(remote, name) {
+			missing = append(missing, name)
+		}
+	}
+	return from, missing, ""
+}
+
+// BackfillGaps reports what a backfill of a job would send to each target
+func (s *Scheduler) BackfillGaps(name string) ([]BackfillGap, error) {
+	job, err := s.job(name)
+	if err != nil {
+		return nil, err
+	}
+	return job.backfillGaps()
+}
+
+// TriggerBackfill sends the snapshots each of a job's targets is missing in
+// the background, for example after a target was offline and only the newest
+// snapshot was sent when it came back
+func (s *Scheduler) TriggerBackfill(name string) error {
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	if !job.sendMutex.TryLock() {
+		return fmt.Errorf("snapshot operation already in progress")
+	}
+	job.sendMutex.Unlock()
+
+	go job.backfill()
+	return nil
+}
+
+// job returns the scheduler for a jobs entry, or s for "" or s's own name
+func (s *Scheduler) job(name string) (*Scheduler, error) {
+	if name == "" || name == s.name {
+		return s, nil
+	}
+	for _, job := range s.jobs {
+		if job.name == name {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown job %q", name)
+}
+
+func (s *Scheduler) backfillGaps() ([]BackfillGap, error) {
+	localSnapshots, err := s.zfsManager.ListSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local snapshots: %w", err)
+	}
+	local := make([]string, len(localSnapshots))
+	for i, snapshot := range localSnapshots {
+		local[i] = snapshot.Name
+	}
+
+	gaps := make([]BackfillGap, 0, len(s.targets))
+	for _, target := range s.targets {
+		gap := BackfillGap{Job: s.name, Dataset: s.Config().ZFS.Dataset, Target: target.name, Missing: []string{}}
+		remote, err := target.transport.ListRemoteSnapshots()
+		if err != nil {
+			gap.Blocker = fmt.Sprintf("failed to list remote snapshots: %v", err)
+		} else {
+			var missing []string
+			gap.From, missing, gap.Blocker = findGap(local, remote)
+			gap.Missing = append(gap.Missing, missing...)
+		}
+		gaps = append(gaps, gap)
+	}
+	return gaps, nil
+}
+
+func (s *Scheduler) backfill() {
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+
+	gaps, err := s.backfillGaps()
+	if err != nil {
+		s.logger.Error("Backfill failed", "dataset", s.Config().ZFS.Dataset, "err", err)
+		return
+	}
+
+	for i, gap := range gaps {
+		target := s.targets[i]
+		if gap.Blocker != "" {
+			s.logger.Warn("Skipping backfill", "dataset", gap.Dataset, "target", target.name, "reason", gap.Blocker)
+			continue
+		}
+		if len(gap.Missing) == 0 {
+			s.logger.Info("Target has every snapshot, nothing to backfill", "dataset", gap.Dataset, "target", target.name, "from", gap.From)
+			continue
+		}
+
+		started := time.Now()
+		to := gap.Missing[len(gap.Missing)-1]
+		err := s.sendRange(target, gap.From, to)
+		s.recordRun(RunSnapshot, gap.Dataset, fmt.Sprintf("backfill of %d snapshots to %s", len(gap.Missing), target.name), started, err)
+		if err != nil {
+			s.logger.Error("Backfill failed", "dataset", gap.Dataset, "target", target.name, "err", err)
+			s.alerter.SendSyncFailure(to, gap.Dataset, fmt.Errorf("backfill to %s: %w", target.name, err))
+			continue
+		}
+
+		if utils.DefaultRunner.DryRun() {
+			continue
+		}
+
+		// Snapshots waiting for a retry are now on the target
+		s.statusMutex.Lock()
+		target.pending = slices.DeleteFunc(target.pending, func(name string) bool {
+			return slices.Contains(gap.Missing, name)
+		})
+		s.statusMutex.Unlock()
+		s.savePending()
+		s.logger.Info("Backfilled snapshots", "count", len(gap.Missing), "dataset", gap.Dataset, "target", target.name, "from", gap.From, "to", to)
+		s.sendSyncSuccess(to, gap.Dataset, time.Since(started))
+	}
+}
+
+// sendRange sends every snapshot after from up to to in one zfs send -I
+// stream, recording the outcome against target like a regular send
+func (s *Scheduler) sendRange(target *replicationTarget, from, to string) error {
+	s.startSend(target, to)
+	defer s.endSend(target)
+	s.publishSend(target, to, events.PhaseStarted, nil)
+
+	err := s.sendIncrementalRange(target.transport, from, to)
+	if err != nil {
+		s.recordSendOutcome(target, err)
+		s.publishSend(target, to, events.PhaseFailed, err)
+		return err
+	}
+	s.publishSend(target, to, events.PhaseCompleted, nil)
+
+	if utils.DefaultRunner.DryRun() {
+		return nil
+	}
+	s.recordSendOutcome(target, nil)
+	s.noteRemoteSnapshot(target, to)
+	return nil
+}
+
+func (s *Scheduler) sendIncrementalRange(dest *transport.SSHTransport, fromSnapshot, toSnapshot string) error {
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: would send snapshot range", "from", fromSnapshot, "to", toSnapshot, "host", dest.RemoteHost(), "remote_dataset", dest.RemoteDataset())
+		return nil
+	}
+
+	sendCmd, err := s.zfsManager.SendIncrementalRange(fromSnapshot, toSnapshot)
+	if err != nil {
+		return err
+	}
+
+	stdout, err := sendCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := sendCmd.Start(); err != nil {
+		return err
+	}
+
+	if err := s.receiveStream(dest, stdout, true); err != nil {
+		sendCmd.Process.Kill()
+		return err
+	}
+
+	return sendCmd.Wait()
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"sort"
+
+	"zfsrabbit/internal/retention"
+)
+
+// bookmarkSentSnapshot bookmarks a snapshot every target has received, so
+// the next send can start from the bookmark even after retention has
+// destroyed the snapshot, then prunes bookmarks beyond zfs.keep_bookmarks
+func (s *Scheduler) bookmarkSentSnapshot(snapshotName string) {
+	if err := s.zfsManager.CreateBookmark(snapshotName); err != nil {
+		s.logger.Error("Failed to bookmark sent snapshot", "snapshot", snapshotName, "err", err)
+		return
+	}
+	s.logger.Debug("Bookmarked sent snapshot", "snapshot", snapshotName)
+
+	if s.Config().ZFS.KeepBookmarks > 0 {
+		if err := s.pruneBookmarks(s.Config().ZFS.KeepBookmarks); err != nil {
+			s.logger.Error("Failed to prune old bookmarks", "err", err)
+		}
+	}
+}
+
+// pruneBookmarks destroys all but the newest keep bookmarks zfsrabbit made.
+// Bookmarks made by hand are left alone.
+func (s *Scheduler) pruneBookmarks(keep int) error {
+	bookmarks, err := s.zfsManager.ListBookmarks()
+	if err != nil {
+		return err
+	}
+
+	var ours []string
+	for _, name := range bookmarks {
+		if _, ok := retention.SnapshotTime(name); ok {
+			ours = append(ours, name)
+		}
+	}
+	if len(ours) <= keep {
+		return nil
+	}
+	// autosnap_ timestamps sort chronologically
+	sort.Strings(ours)
+
+	for _, name := range ours[:len(ours)-keep] {
+		if err := s.zfsManager.DestroyBookmark(name); err != nil {
+			s.logger.Error("Failed to delete old bookmark", "bookmark", name, "err", err)
+			continue
+		}
+		s.logger.Info("Deleted old bookmark", "bookmark", name)
+	}
+	return nil
+}
+
+// bookmarkedSnapshots returns the snapshots that already have a bookmark
+// when bookmark_on_send is enabled, nil otherwise
+func (s *Scheduler) bookmarkedSnapshots() map[string]bool {
+	if !s.Config().ZFS.BookmarkOnSend {
+		return nil
+	}
+	bookmarks, err := s.zfsManager.ListBookmarks()
+	if err != nil {
+		s.logger.Warn("Failed to list bookmarks", "err", err)
+		return nil
+	}
+	bookmarked := make(map[string]bool, len(bookmarks))
+	for _, name := range bookmarks {
+		bookmarked[name] = true
+	}
+	return bookmarked
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/zfs"
+)
+
+// sendsDeferred reports whether scheduled sends should wait because the
+// pool is busy, per schedule.idle_deferral. Manual triggers never wait.
+// Callers hold sendMutex.
+func (s *Scheduler) sendsDeferred() bool {
+	deferral := s.Config().Schedule.IdleDeferral
+	if !deferral.Enabled {
+		return false
+	}
+	if !s.deferredSince.IsZero() && deferral.MaxDelay > 0 && time.Since(s.deferredSince) >= deferral.MaxDelay {
+		s.logger.Warn("Sends have waited too long for the pool to go quiet, sending anyway",
+			"dataset", s.Config().ZFS.Dataset, "deferred_since", display.Time(s.deferredSince))
+		s.setDeferredSince(time.Time{})
+		return false
+	}
+
+	pool, _, _ := strings.Cut(s.Config().ZFS.Dataset, "/")
+	stat, err := zfs.GetPoolIOStat(s.ctx, pool, deferral.Sample)
+	if err != nil {
+		s.logger.Warn("Failed to measure the pool load, not deferring sends", "pool", pool, "err", err)
+		s.setDeferredSince(time.Time{})
+		return false
+	}
+
+	reason := busyReason(stat, deferral)
+	if reason == "" {
+		if !s.deferredSince.IsZero() {
+			s.logger.Info("Pool is quiet again, resuming sends", "pool", pool, "dataset", s.Config().ZFS.Dataset)
+		}
+		s.setDeferredSince(time.Time{})
+		return false
+	}
+
+	if s.deferredSince.IsZero() {
+		s.setDeferredSince(time.Now())
+	}
+	s.logger.Info("Deferring sends", "dataset", s.Config().ZFS.Dataset, "reason", reason)
+	return true
+}
+
+// setDeferredSince records when sends started waiting; callers hold sendMutex
+func (s *Scheduler) setDeferredSince(since time.Time) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	s.deferredSince = since
+}
+
+// busyReason explains why a pool counts as busy, or returns "" if it doesn't
+func busyReason(stat *zfs.PoolIOStat, deferral config.IdleDeferralConfig) string {
+	maxBandwidth, _ := config.ParseRate(deferral.MaxBandwidth) // Checked by Validate
+	if bandwidth := int64(stat.ReadBytes + stat.WriteBytes); maxBandwidth > 0 && bandwidth > maxBandwidth {
+		return fmt.Sprintf("%s is moving %s/s, over the %s/s limit",
+			stat.Pool, display.Bytes(bandwidth), display.Bytes(maxBandwidth))
+	}
+	if wait := max(stat.ReadWait, stat.WriteWait); deferral.MaxLatency > 0 && wait > deferral.MaxLatency {
+		return fmt.Sprintf("%s IO waits %s, over the %s limit", stat.Pool, wait.Round(time.Microsecond), deferral.MaxLatency)
+	}
+	return ""
+}
+
+// deferSend queues a new snapshot for every target without sending it
+func (s *Scheduler) deferSend(snapshotName string, started time.Time) {
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: would defer send while the pool is busy", "snapshot", snapshotName)
+		return
+	}
+	s.statusMutex.Lock()
+	for _, target := range s.targets {
+		target.pending = append(target.pending, snapshotName)
+	}
+	s.statusMutex.Unlock()
+	s.savePending()
+	s.recordRun(RunSnapshot, s.Config().ZFS.Dataset, snapshotName+" (send deferred while the pool is busy)", started, nil)
+	s.logger.Info("Created snapshot, its send waits for the pool to go quiet", "snapshot", snapshotName)
+}
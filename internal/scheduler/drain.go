@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// Drain stops snapshots and sends from starting, ahead of a shutdown, and
+// waits until those in flight finish or ctx is done. The sends still
+// running then are returned for Stop to cut off. Those received resumably
+// leave a resume token on the target that the next start picks up from;
+// the others start over.
+func (s *Scheduler) Drain(ctx context.Context) []string {
+	s.cron.Stop()
+
+	schedulers := append([]*Scheduler{s}, s.jobs...)
+	idle := make(chan struct{})
+	go func() {
+		// Held until exit, so nothing new starts once a send finishes
+		for _, job := range schedulers {
+			job.sendMutex.Lock()
+		}
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+
+	var sends []string
+	for _, job := range schedulers {
+		for _, target := range job.targetStatus() {
+			if target.Sending == "" {
+				continue
+			}
+			job.logger.Warn("Send still running at shutdown, cutting it off", "target", target.Name,
+				"snapshot", target.Sending, "sent", target.SentBytes, "resumable", job.resumable())
+			sends = append(sends, fmt.Sprintf("%s@%s to %s", job.Config().ZFS.Dataset, target.Sending, target.Name))
+		}
+	}
+	return sends
+}
+
+// InFlight counts the sends running in this and every other job
+func (s *Scheduler) InFlight() int {
+	count := 0
+	for _, job := range append([]*Scheduler{s}, s.jobs...) {
+		for _, target := range job.targetStatus() {
+			if target.Sending != "" {
+				count++
+			}
+		}
+	}
+	return count
+}
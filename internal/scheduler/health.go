@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"zfsrabbit/internal/metrics"
+)
+
+// recentSends is how many of a target's latest sends its success rate covers
+const recentSends = 20
+
+// defaultRPO is the recovery point objective when there is no SLA max_age
+// and the snapshot schedule can't be parsed
+const defaultRPO = 24 * time.Hour
+
+// How much of the 100 point score each factor is worth
+const (
+	successWeight = 35
+	lagWeight     = 35
+	verifyWeight  = 15
+	chainWeight   = 15
+)
+
+var healthGauge = metrics.NewGauge("zfsrabbit_replication_health_score",
+	"Composite 0-100 health of a dataset's replication to one target", "job", "dataset", "target")
+
+// PairHealth scores the replication of one dataset to one target, from 0
+// (broken) to 100 (healthy), for triage across many pairs
+type PairHealth struct {
+	Job         string   `json:"job"`
+	Dataset     string   `json:"dataset"`
+	Target      string   `json:"target"`
+	Score       int      `json:"score"`
+	Emoji       string   `json:"emoji"`
+	SuccessRate float64  `json:"success_rate"` // Of the last 20 sends; 1 if there were none
+	LagSeconds  int64    `json:"lag_seconds"`  // Since the last successful send
+	RPOSeconds  int64    `json:"rpo_seconds"`
+	Unverified  int      `json:"unverified"` // Replicas flagged as needing verification
+	Pending     int      `json:"pending"`    // Snapshots missing from the target's chain
+	Issues      []string `json:"issues,omitempty"`
+}
+
+// HealthEmoji summarises a score for the dashboard and Slack
+func HealthEmoji(score int) string {
+	switch {
+	case score >= 90:
+		return "🟢"
+	case score >= 70:
+		return "🟡"
+	default:
+		return "🔴"
+	}
+}
+
+// recordOutcome remembers whether a send to target succeeded; callers hold
+// statusMutex
+func (t *replicationTarget) recordOutcome(success bool) {
+	t.outcomes = append(t.outcomes, success)
+	if len(t.outcomes) > recentSends {
+		t.outcomes = t.outcomes[len(t.outcomes)-recentSends:]
+	}
+}
+
+// successRate is the fraction of recent sends that succeeded
+func (t *replicationTarget) successRate() float64 {
+	if len(t.outcomes) == 0 {
+		return 1
+	}
+	succeeded := 0
+	for _, success := range t.outcomes {
+		if success {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(len(t.outcomes))
+}
+
+// Health scores every job's replication to each of its targets and updates
+// the health metric
+func (s *Scheduler) Health() []PairHealth {
+	now := time.Now()
+	pairs := s.pairHealth(now)
+	for _, job := range s.jobs {
+		pairs = append(pairs, job.pairHealth(now)...)
+	}
+	for _, pair := range pairs {
+		healthGauge.Set(float64(pair.Score), pair.Job, pair.Dataset, pair.Target)
+	}
+	return pairs
+}
+
+func (s *Scheduler) pairHealth(now time.Time) []PairHealth {
+	dataset := s.Config().ZFS.Dataset
+	rpo := s.rpo(now)
+
+	unverified := 0
+	for _, suspect := range s.catalog.NeedsVerification() {
+		if suspect.Dataset == dataset {
+			unverified++
+		}
+	}
+
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	pairs := make([]PairHealth, 0, len(s.targets))
+	for _, target := range s.targets {
+		since := target.lastSuccess
+		if since.IsZero() {
+			since = s.created
+		}
+		pairs = append(pairs, scorePair(PairHealth{
+			Job:         s.name,
+			Dataset:     dataset,
+			Target:      target.name,
+			SuccessRate: target.successRate(),
+			LagSeconds:  int64(now.Sub(since).Seconds()),
+			RPOSeconds:  int64(rpo.Seconds()),
+			Unverified:  unverified,
+			Pending:     len(target.pending),
+		}))
+	}
+	return pairs
+}
+
+// scorePair fills in a pair's score, emoji and issues from its measurements
+func scorePair(pair PairHealth) PairHealth {
+	score := successWeight * pair.SuccessRate
+	if pair.SuccessRate < 1 {
+		pair.Issues = append(pair.Issues, fmt.Sprintf("%.0f%% of recent sends succeeded", pair.SuccessRate*100))
+	}
+
+	// Full marks within the RPO, falling to nothing at twice the RPO
+	lag, rpo := float64(pair.LagSeconds), float64(pair.RPOSeconds)
+	switch {
+	case rpo <= 0 || lag <= rpo:
+		score += lagWeight
+	case lag < 2*rpo:
+		score += lagWeight * (2*rpo - lag) / rpo
+		pair.Issues = append(pair.Issues, fmt.Sprintf("last success %s ago exceeds the %s RPO", roundDuration(lag), roundDuration(rpo)))
+	default:
+		pair.Issues = append(pair.Issues, fmt.Sprintf("last success %s ago is over twice the %s RPO", roundDuration(lag), roundDuration(rpo)))
+	}
+
+	if pair.Unverified == 0 {
+		score += verifyWeight
+	} else {
+		pair.Issues = append(pair.Issues, fmt.Sprintf("%d replicas need verification", pair.Unverified))
+	}
+
+	// Each snapshot missing from the chain costs a third of the chain points
+	if pair.Pending < 3 {
+		score += chainWeight * float64(3-pair.Pending) / 3
+	}
+	if pair.Pending > 0 {
+		pair.Issues = append(pair.Issues, fmt.Sprintf("%d snapshots waiting to be sent", pair.Pending))
+	}
+
+	pair.Score = int(score + 0.5)
+	pair.Emoji = HealthEmoji(pair.Score)
+	return pair
+}
+
+// rpo is the longest a pair may go without a successful send: the dataset's
+// SLA max_age, otherwise two snapshot intervals
+func (s *Scheduler) rpo(now time.Time) time.Duration {
+	cfg := s.Config()
+	for _, objective := range cfg.SLAs {
+		if objective.MaxAge <= 0 {
+			continue
+		}
+		// An SLA without a dataset is for the zfs section, i.e. the default job
+		if objective.Dataset == cfg.ZFS.Dataset || (objective.Dataset == "" && s.name == "default") {
+			return objective.MaxAge
+		}
+	}
+
+	schedule, err := cron.ParseStandard(cfg.Schedule.SnapshotCron)
+	if err != nil {
+		return defaultRPO
+	}
+	next := schedule.Next(now)
+	return 2 * schedule.Next(next).Sub(next)
+}
+
+func roundDuration(seconds float64) time.Duration {
+	return (time.Duration(seconds) * time.Second).Round(time.Minute)
+}
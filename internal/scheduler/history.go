@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"zfsrabbit/internal/compact"
+	"zfsrabbit/internal/utils"
+)
+
+// Kinds of run kept in the run history
+const (
+	RunSnapshot = "snapshot"
+	RunScrub    = "scrub"
+)
+
+// maxUpcoming caps how many times one schedule is listed, so a frequent
+// cron expression can't flood the calendar
+const maxUpcoming = 200
+
+// Run is one finished snapshot or scrub run
+type Run struct {
+	Kind     string    `json:"kind"`
+	Job      string    `json:"job"`    // Job name for snapshots
+	Target   string    `json:"target"` // Dataset for snapshots, pool for scrubs
+	Detail   string    `json:"detail,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// ScheduledRun is an upcoming run of a cron schedule
+type ScheduledRun struct {
+	Kind     string    `json:"kind"`
+	Job      string    `json:"job"`
+	Target   string    `json:"target"`
+	Schedule string    `json:"schedule"`
+	At       time.Time `json:"at"`
+}
+
+// runHistory persists finished runs, shared by every job
+type runHistory struct {
+	path  string
+	mutex sync.Mutex
+	runs  []Run
+}
+
+// openRunHistory loads the runs at path; an empty path keeps them in memory only
+func openRunHistory(path string) *runHistory {
+	h := &runHistory{path: path}
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &h.runs); err != nil {
+			logger.Error("Failed to load run history", "path", path, "err", err)
+		}
+	}
+	return h
+}
+
+func (h *runHistory) add(run Run) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.runs = append(h.runs, run)
+	h.saveLocked()
+}
+
+// between returns the runs that started in [from, to), oldest first
+func (h *runHistory) between(from, to time.Time) []Run {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	runs := []Run{}
+	for _, run := range h.runs {
+		if !run.Started.Before(from) && run.Started.Before(to) {
+			runs = append(runs, run)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	return runs
+}
+
+// Compact drops runs that finished before before, then the oldest past maxRows
+func (h *runHistory) Compact(before time.Time, maxRows int) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var kept []Run
+	for _, run := range h.runs {
+		if before.IsZero() || !run.Finished.Before(before) {
+			kept = append(kept, run)
+		}
+	}
+	if maxRows > 0 && len(kept) > maxRows {
+		kept = kept[len(kept)-maxRows:]
+	}
+
+	removed := len(h.runs) - len(kept)
+	if removed > 0 {
+		h.runs = kept
+		h.saveLocked()
+	}
+	return removed
+}
+
+func (h *runHistory) saveLocked() {
+	if h.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(h.path, h.runs, 0600); err != nil {
+		logger.Error("Failed to save run history", "path", h.path, "err", err)
+	}
+}
+
+// recordRun adds a run that started at started and has just finished
+func (s *Scheduler) recordRun(kind, target, detail string, started time.Time, err error) {
+	// A dry run changed nothing, so it has no place in the history
+	if utils.DefaultRunner.DryRun() {
+		return
+	}
+	run := Run{
+		Kind:     kind,
+		Job:      s.name,
+		Target:   target,
+		Detail:   detail,
+		Started:  started,
+		Finished: time.Now(),
+		Success:  err == nil,
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	s.history.add(run)
+}
+
+// History returns the snapshot and scrub runs of every job that started in
+// [from, to), oldest first
+func (s *Scheduler) History(from, to time.Time) []Run {
+	return s.history.between(from, to)
+}
+
+// RunHistory returns the run history so it can be compacted
+func (s *Scheduler) RunHistory() compact.Store {
+	return s.history
+}
+
+// Upcoming returns every job's scheduled snapshot runs and the scrub runs
+// between from and to, soonest first
+func (s *Scheduler) Upcoming(from, to time.Time) []ScheduledRun {
+	cfg := s.Config()
+	runs := upcoming(ScheduledRun{Kind: RunSnapshot, Job: s.name, Target: cfg.ZFS.Dataset, Schedule: cfg.Schedule.SnapshotCron}, from, to)
+	for _, job := range s.jobs {
+		jobCfg := job.Config()
+		runs = append(runs, upcoming(ScheduledRun{Kind: RunSnapshot, Job: job.name, Target: jobCfg.ZFS.Dataset, Schedule: jobCfg.Schedule.SnapshotCron}, from, to)...)
+	}
+	runs = append(runs, upcoming(ScheduledRun{Kind: RunScrub, Job: s.name, Target: "all pools", Schedule: cfg.Schedule.ScrubCron}, from, to)...)
+
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].At.Before(runs[j].At) })
+	return runs
+}
+
+// upcoming lists the times run's schedule fires between from and to
+func upcoming(run ScheduledRun, from, to time.Time) []ScheduledRun {
+	schedule, err := cron.ParseStandard(run.Schedule)
+	if err != nil {
+		return nil
+	}
+
+	var runs []ScheduledRun
+	for at := schedule.Next(from.Add(-time.Second)); at.Before(to) && len(runs) < maxUpcoming; at = schedule.Next(at) {
+		run.At = at
+		runs = append(runs, run)
+	}
+	return runs
+}
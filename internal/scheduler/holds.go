@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"zfsrabbit/internal/zfs"
+)
+
+// sendHoldTag is the zfs hold tag placed on snapshots while they are sent
+const sendHoldTag = "zfsrabbit-send"
+
+// holdForSend holds each named snapshot so retention, another job or an
+// operator can't destroy it mid-send; empty names are skipped. The returned
+// func releases the holds that were placed.
+func (s *Scheduler) holdForSend(snapshots ...string) func() {
+	var held []string
+	for _, name := range snapshots {
+		if name == "" {
+			continue
+		}
+		if err := s.zfsManager.HoldSnapshot(name, sendHoldTag); err != nil {
+			// The send goes ahead, it just isn't protected
+			s.logger.Warn("Failed to hold snapshot for send", "snapshot", name, "err", err)
+			continue
+		}
+		held = append(held, name)
+	}
+
+	return func() {
+		for _, name := range held {
+			if err := s.zfsManager.ReleaseSnapshot(name, sendHoldTag); err != nil {
+				s.logger.Error("Failed to release hold after send", "snapshot", name, "err", err)
+			}
+		}
+	}
+}
+
+// releaseStaleHolds releases send holds left behind when the daemon died
+// mid-send; callers hold sendMutex so no send of this job is in flight
+func (s *Scheduler) releaseStaleHolds() {
+	holds, err := s.zfsManager.ListHolds()
+	if err != nil {
+		s.logger.Warn("Failed to check for leftover send holds", "err", err)
+		return
+	}
+	for _, hold := range holds {
+		// Recursive holds are released from the top-level snapshot
+		if hold.Tag != sendHoldTag || hold.Dataset != s.Config().ZFS.Dataset {
+			continue
+		}
+		if err := s.zfsManager.ReleaseSnapshot(hold.Snapshot, sendHoldTag); err != nil {
+			s.logger.Error("Failed to release leftover send hold", "snapshot", hold.Snapshot, "err", err)
+			continue
+		}
+		s.logger.Info("Released leftover send hold", "snapshot", hold.Snapshot)
+	}
+}
+
+// heldSnapshots returns the names of the managed dataset's snapshots that
+// have a hold of any kind, nil if they can't be listed
+func (s *Scheduler) heldSnapshots() map[string]bool {
+	holds, err := s.zfsManager.ListHolds()
+	if err != nil {
+		s.logger.Warn("Failed to list snapshot holds", "err", err)
+		return nil
+	}
+	held := make(map[string]bool)
+	for _, hold := range holds {
+		if hold.Dataset == s.Config().ZFS.Dataset {
+			held[hold.Snapshot] = true
+		}
+	}
+	return held
+}
+
+// Holds returns the holds on every job's snapshots: zfsrabbit's own during
+// sends and any placed by hand
+func (s *Scheduler) Holds() ([]zfs.Hold, error) {
+	holds := []zfs.Hold{}
+	for _, sched := range append([]*Scheduler{s}, s.jobs...) {
+		jobHolds, err := sched.zfsManager.ListHolds()
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, jobHolds...)
+	}
+	return holds, nil
+}
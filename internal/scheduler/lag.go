@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"zfsrabbit/internal/metrics"
+	"zfsrabbit/internal/retention"
+)
+
+// lagCheckSchedule is how often each target's newest snapshot is looked up
+const lagCheckSchedule = "@every 10m"
+
+var lagGauge = metrics.NewGauge("zfsrabbit_replication_lag_seconds",
+	"Age of the newest snapshot on a replication target", "job", "dataset", "target")
+
+// TargetLag is how far a target is behind: the age of the newest snapshot
+// it holds
+type TargetLag struct {
+	Job           string     `json:"job"`
+	Dataset       string     `json:"dataset"`
+	Target        string     `json:"target"`
+	Newest        string     `json:"newest,omitempty"` // Newest zfsrabbit snapshot on the target
+	NewestTime    *time.Time `json:"newest_time,omitempty"`
+	LagSeconds    int64      `json:"lag_seconds"` // Since the job started if the target has no snapshot yet
+	MaxLagSeconds int64      `json:"max_lag_seconds,omitempty"`
+	Lagging       bool       `json:"lagging"`
+	Error         string     `json:"error,omitempty"` // Listing failed; lag is from the last snapshot known to be there
+}
+
+// noteRemoteSnapshot remembers the newest snapshot known to be on target,
+// so lag can still be judged while the target can't be listed
+func (s *Scheduler) noteRemoteSnapshot(target *replicationTarget, snapshotName string) {
+	created, ok := retention.SnapshotTime(snapshotName)
+	if !ok {
+		return
+	}
+	s.lagMutex.Lock()
+	defer s.lagMutex.Unlock()
+	if created.After(target.newestTime) {
+		target.newest, target.newestTime = snapshotName, created
+	}
+}
+
+// newestSnapshot returns the newest of the snapshots zfsrabbit named, or ""
+func newestSnapshot(names []string) (string, time.Time) {
+	var newest string
+	var newestTime time.Time
+	for _, name := range names {
+		if created, ok := retention.SnapshotTime(name); ok && created.After(newestTime) {
+			newest, newestTime = name, created
+		}
+	}
+	return newest, newestTime
+}
+
+// CheckLag looks up the newest snapshot on every job's targets, updates the
+// lag metric and alerts once per target when its lag exceeds zfs.max_lag
+func (s *Scheduler) CheckLag() []TargetLag {
+	now := time.Now()
+	var lags []TargetLag
+	for _, sched := range append([]*Scheduler{s}, s.jobs...) {
+		lags = append(lags, sched.checkLag(now)...)
+	}
+	return lags
+}
+
+func (s *Scheduler) checkLag(now time.Time) []TargetLag {
+	cfg := s.Config()
+	dataset := cfg.ZFS.Dataset
+	maxLag := cfg.ZFS.MaxLag
+
+	lags := make([]TargetLag, 0, len(s.targets))
+	for _, target := range s.targets {
+		lag := TargetLag{Job: s.name, Dataset: dataset, Target: target.name, MaxLagSeconds: int64(maxLag.Seconds())}
+
+		// Sends run in parallel with this, as with backfill gaps, since
+		// listing doesn't change anything on the target
+		remote, err := target.transport.ListRemoteSnapshots()
+
+		s.lagMutex.Lock()
+		if err != nil {
+			lag.Error = err.Error()
+		} else {
+			target.newest, target.newestTime = newestSnapshot(remote)
+		}
+		since := s.created
+		if !target.newestTime.IsZero() {
+			since = target.newestTime
+			newestTime := target.newestTime
+			lag.Newest, lag.NewestTime = target.newest, &newestTime
+		}
+		age := now.Sub(since)
+		lag.LagSeconds = int64(age.Seconds())
+		lag.Lagging = maxLag > 0 && age > maxLag
+		alert := lag.Lagging && !target.lagging
+		recovered := !lag.Lagging && target.lagging
+		target.lagging = lag.Lagging
+		s.lagMutex.Unlock()
+
+		lagGauge.Set(age.Seconds(), s.name, dataset, target.name)
+		if alert {
+			s.logger.Warn("Replication is lagging", "target", target.name, "newest", lag.Newest, "lag", age.Round(time.Minute), "max_lag", maxLag)
+			s.alertLag(target, lag, age, maxLag)
+		}
+		if recovered {
+			s.logger.Info("Replication caught up", "target", target.name, "newest", lag.Newest)
+		}
+		lags = append(lags, lag)
+	}
+	return lags
+}
+
+func (s *Scheduler) alertLag(target *replicationTarget, lag TargetLag, age, maxLag time.Duration) {
+	newest := "none"
+	if lag.Newest != "" {
+		newest = lag.Newest
+	}
+	subject := fmt.Sprintf("[WARNING] Replication Lag: %s", lag.Dataset)
+	body := fmt.Sprintf("Replication Lag\n\n%s has not replicated to %s (%s) in %s.\n\nDataset: %s\nNewest snapshot on target: %s\nmax_lag: %s\nJob: %s\n",
+		lag.Dataset, target.name, target.transport.Config().RemoteHost, formatLag(age),
+		lag.Dataset, newest, maxLag, lag.Job)
+	if lag.Error != "" {
+		body += fmt.Sprintf("\nThe target could not be listed, so this is measured from the last snapshot known to be there: %s\n", lag.Error)
+	}
+	if err := s.alerter.SendAlert(subject, body); err != nil {
+		s.logger.Error("Failed to send lag alert", "err", err)
+	}
+}
+
+// formatLag rounds a lag for people, e.g. 26h or 45m
+func formatLag(age time.Duration) string {
+	if age >= time.Hour {
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(age.Minutes()))
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+
+	"zfsrabbit/internal/utils"
+)
+
+// uploadSnapshot copies snapshotName to object storage, as an incremental
+// from the newest uploaded snapshot that still exists locally, otherwise as
+// a full stream
+func (s *Scheduler) uploadSnapshot(snapshotName string) error {
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: would upload snapshot to object storage", "snapshot", snapshotName)
+		return nil
+	}
+
+	dataset := s.Config().ZFS.Dataset
+	manifests, err := s.objectStore.Manifests(s.ctx, dataset)
+	if err != nil {
+		return fmt.Errorf("failed to list uploaded snapshots: %w", err)
+	}
+	localSnapshots, err := s.zfsManager.ListSnapshots()
+	if err != nil {
+		return fmt.Errorf("failed to list local snapshots: %w", err)
+	}
+	local := make(map[string]bool)
+	for _, snapshot := range localSnapshots {
+		if snapshot.Dataset == dataset {
+			local[snapshot.Name] = true
+		}
+	}
+
+	var base string
+	for _, manifest := range manifests {
+		if manifest.Snapshot == snapshotName {
+			return nil // Already uploaded, e.g. by a retry
+		}
+		if local[manifest.Snapshot] {
+			base = manifest.Snapshot // Manifests are oldest first
+		}
+	}
+
+	var sendCmd *exec.Cmd
+	if base == "" {
+		sendCmd, err = s.zfsManager.SendSnapshot(snapshotName)
+	} else {
+		sendCmd, err = s.zfsManager.SendIncremental(base, snapshotName)
+	}
+	if err != nil {
+		return err
+	}
+
+	stdout, err := sendCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := sendCmd.Start(); err != nil {
+		return err
+	}
+
+	manifest, err := s.objectStore.Upload(s.ctx, dataset, snapshotName, base, stdout)
+	if err != nil {
+		sendCmd.Process.Kill()
+		io.Copy(io.Discard, stdout)
+		sendCmd.Wait()
+		return err
+	}
+	if err := sendCmd.Wait(); err != nil {
+		// The stream may be truncated, so it must not be restored from
+		if deleteErr := s.objectStore.Delete(s.ctx, manifest); deleteErr != nil {
+			s.logger.Error("Failed to remove incomplete upload", "snapshot", snapshotName, "err", deleteErr)
+		}
+		return fmt.Errorf("zfs send failed: %w", err)
+	}
+
+	s.logger.Info("Uploaded snapshot to object storage", "snapshot", snapshotName, "base", base,
+		"chunks", len(manifest.Chunks), "bytes", manifest.StreamBytes)
+	return nil
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"sync"
+
+	"zfsrabbit/internal/utils"
+)
+
+// pendingStore persists every target's retry queue so failed sends are
+// retried after a restart. Queues are keyed by job, target and destination,
+// so a target pointed at a different server or dataset starts empty.
+type pendingStore struct {
+	path   string
+	mutex  sync.Mutex
+	queues map[string][]string
+}
+
+// openPendingStore loads the queues at path; an empty path keeps them in memory only
+func openPendingStore(path string) *pendingStore {
+	p := &pendingStore{path: path, queues: make(map[string][]string)}
+
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &p.queues); err != nil {
+			logger.Error("Failed to load pending sends", "path", path, "err", err)
+		}
+		if p.queues == nil {
+			p.queues = make(map[string][]string)
+		}
+	}
+
+	return p
+}
+
+func (p *pendingStore) get(key string) []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.queues[key]...)
+}
+
+// set replaces one queue and persists the store
+func (p *pendingStore) set(key string, snapshots []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(snapshots) == 0 {
+		if _, ok := p.queues[key]; !ok {
+			return
+		}
+		delete(p.queues, key)
+	} else {
+		p.queues[key] = append([]string(nil), snapshots...)
+	}
+	p.saveLocked()
+}
+
+// retain drops queues for targets that are no longer configured
+func (p *pendingStore) retain(keys map[string]bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	dropped := false
+	for key, snapshots := range p.queues {
+		if !keys[key] {
+			logger.Warn("Dropping pending sends of a target that is no longer configured", "count", len(snapshots), "target", key)
+			delete(p.queues, key)
+			dropped = true
+		}
+	}
+	if dropped {
+		p.saveLocked()
+	}
+}
+
+func (p *pendingStore) saveLocked() {
+	if p.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(p.path, p.queues, 0600); err != nil {
+		logger.Error("Failed to save pending sends", "path", p.path, "err", err)
+	}
+}
+
+// pendingKey names a target's queue in the store
+func (s *Scheduler) pendingKey(target *replicationTarget) string {
+	return s.name + "/" + target.name + "/" + target.transport.Config().RemoteHost + ":" + target.transport.Config().RemoteDataset
+}
+
+// restorePending reloads every target's queue from the store
+func (s *Scheduler) restorePending() {
+	for _, target := range s.targets {
+		pending := s.pendingStore.get(s.pendingKey(target))
+		s.statusMutex.Lock()
+		target.pending = pending
+		s.statusMutex.Unlock()
+		if len(target.pending) > 0 {
+			s.logger.Info("Restored pending sends", "count", len(target.pending), "dataset", s.Config().ZFS.Dataset, "target", target.name)
+		}
+	}
+}
+
+// savePending persists every target's queue; callers hold sendMutex
+func (s *Scheduler) savePending() {
+	for _, target := range s.targets {
+		s.pendingStore.set(s.pendingKey(target), target.pending)
+	}
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"io"
+	"time"
+
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/transport"
+)
+
+// progressInterval is how often a send in progress reports its byte count
+const progressInterval = time.Second
+
+// progressReader counts the bytes of a send stream as it is read
+type progressReader struct {
+	reader     io.Reader
+	onProgress func(sent int64)
+	sent       int64
+	reported   time.Time
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.sent += int64(n)
+	if now := time.Now(); now.Sub(p.reported) >= progressInterval || err == io.EOF {
+		p.reported = now
+		p.onProgress(p.sent)
+	}
+	return n, err
+}
+
+// targetFor returns the target that sends through dest, if any
+func (s *Scheduler) targetFor(dest *transport.SSHTransport) *replicationTarget {
+	for _, target := range s.targets {
+		if target.transport == dest {
+			return target
+		}
+	}
+	return nil
+}
+
+// publishSend announces a send to target moving into phase
+func (s *Scheduler) publishSend(target *replicationTarget, snapshotName, phase string, err error) {
+	progress := events.SendProgress{
+		Job:            s.name,
+		Target:         target.name,
+		Snapshot:       snapshotName,
+		Phase:          phase,
+		SentBytes:      target.sent.Load(),
+		EstimatedBytes: target.estimate,
+	}
+	if err != nil {
+		progress.Error = err.Error()
+	}
+	s.events.Publish(events.TypeSend, progress)
+}
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"slices"
+)
+
+// Reconcile brings every job's send queues in line with its backup servers
+// after a restart. A crash mid-send leaves the snapshot queued, so snapshots
+// that reached a target before the daemon died are dropped from its queue.
+// A partial receive that can no longer be resumed is discarded so it can't
+// block the next send; a resumable one is left for the next send to finish.
+// Send holds the crash left on local snapshots are released.
+func (s *Scheduler) Reconcile() {
+	for _, sched := range append([]*Scheduler{s}, s.jobs...) {
+		sched.sendMutex.Lock()
+		sched.releaseStaleHolds()
+		for _, target := range sched.targets {
+			sched.reconcileTarget(target)
+		}
+		sched.savePending()
+		sched.sendMutex.Unlock()
+	}
+}
+
+// reconcileTarget checks one target's queue and partial receive; callers
+// hold sendMutex
+func (s *Scheduler) reconcileTarget(target *replicationTarget) {
+	if len(target.pending) > 0 {
+		remote, err := target.transport.ListRemoteSnapshots()
+		if err != nil {
+			s.logger.Warn("Failed to reconcile pending sends, keeping them queued", "target", target.name, "err", err)
+		} else {
+			pending, delivered := dropDelivered(target.pending, remote)
+			s.statusMutex.Lock()
+			target.pending = pending
+			s.statusMutex.Unlock()
+			if len(delivered) > 0 {
+				s.logger.Info("Dropped pending sends already on target", "count", len(delivered), "dataset", s.Config().ZFS.Dataset, "target", target.name, "snapshots", delivered)
+			}
+		}
+	}
+
+	if !s.resumable() {
+		return
+	}
+	token, err := target.transport.RemoteResumeToken()
+	if err != nil || token == "" {
+		return
+	}
+	if err := s.zfsManager.ValidateResumeToken(token); err != nil {
+		s.logger.Warn("Discarding partial receive that can no longer be resumed", "target", target.name, "err", err)
+		if err := target.transport.AbortPartialReceive(); err != nil {
+			s.logger.Error("Failed to discard partial receive", "target", target.name, "err", err)
+		}
+		return
+	}
+	s.logger.Info("Found an interrupted send, the next send resumes it", "target", target.name)
+}
+
+// dropDelivered splits pending into the snapshots still to send and those
+// the remote already has
+func dropDelivered(pending, remote []string) (kept, delivered []string) {
+	for _, snapshot := range pending {
+		if slices.Contains(remote, snapshot) {
+			delivered = append(delivered, snapshot)
+		} else {
+			kept = append(kept, snapshot)
+		}
+	}
+	return kept, delivered
+}
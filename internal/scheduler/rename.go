@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+)
+
+// DatasetRename is a managed dataset that no longer exists under its
+// configured name but was found under another by its GUID
+type DatasetRename struct {
+	Job      string    `json:"job"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	GUID     string    `json:"guid"`
+	Detected time.Time `json:"detected"`
+}
+
+// guidStore remembers the GUID of each managed dataset, keyed by name
+type guidStore struct {
+	path  string
+	mutex sync.Mutex
+	guids map[string]string
+}
+
+// openGUIDStore loads the GUIDs at path; an empty path keeps them in memory only
+func openGUIDStore(path string) *guidStore {
+	g := &guidStore{path: path, guids: make(map[string]string)}
+
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &g.guids); err != nil {
+			logger.Error("Failed to load dataset GUIDs", "path", path, "err", err)
+		}
+		if g.guids == nil {
+			g.guids = make(map[string]string)
+		}
+	}
+
+	return g
+}
+
+func (g *guidStore) get(dataset string) string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.guids[dataset]
+}
+
+// set records a dataset's GUID, persisting the store only when it changed
+func (g *guidStore) set(dataset, guid string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.guids[dataset] == guid {
+		return
+	}
+	g.guids[dataset] = guid
+	g.saveLocked()
+}
+
+// rename moves the GUID recorded for from to to
+func (g *guidStore) rename(from, to string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if guid, ok := g.guids[from]; ok {
+		delete(g.guids, from)
+		g.guids[to] = guid
+		g.saveLocked()
+	}
+}
+
+func (g *guidStore) saveLocked() {
+	if g.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(g.path, g.guids, 0600); err != nil {
+		logger.Error("Failed to save dataset GUIDs", "path", g.path, "err", err)
+	}
+}
+
+// checkDatasetRename records the managed dataset's GUID while it exists.
+// Once it is gone, the GUID is looked up under other names; if the dataset
+// was renamed an alert is sent and, with zfs.follow_renames, replication
+// switches to the new name. Otherwise an error is returned until the
+// rename is accepted. A dataset that is simply missing is left for the
+// snapshot to fail on as before. Called with sendMutex held.
+func (s *Scheduler) checkDatasetRename() error {
+	dataset := s.Config().ZFS.Dataset
+	if guid, err := s.zfsManager.DatasetGUID(); err == nil && guid != "" {
+		s.datasetGUIDs.set(dataset, guid)
+		s.setRename(nil)
+		return nil
+	}
+
+	guid := s.datasetGUIDs.get(dataset)
+	if guid == "" {
+		return nil
+	}
+	renamed, err := s.zfsManager.FindDatasetByGUID(guid)
+	if err != nil || renamed == "" || renamed == dataset {
+		return nil
+	}
+
+	rename := s.PendingRename()
+	if rename == nil || rename.To != renamed {
+		rename = &DatasetRename{Job: s.name, From: dataset, To: renamed, GUID: guid, Detected: time.Now()}
+		s.setRename(rename)
+		s.logger.Warn("Dataset was renamed", "from", dataset, "to", renamed, "guid", guid)
+		s.alertRename(rename)
+	}
+
+	if s.Config().ZFS.FollowRenames {
+		return s.applyRename(rename)
+	}
+	return fmt.Errorf("%s was renamed to %s; accept the rename to resume replication", dataset, renamed)
+}
+
+// PendingRename returns the detected rename of this job's dataset that has
+// not been accepted yet, or nil
+func (s *Scheduler) PendingRename() *DatasetRename {
+	s.renameMutex.Lock()
+	defer s.renameMutex.Unlock()
+	return s.rename
+}
+
+func (s *Scheduler) setRename(rename *DatasetRename) {
+	s.renameMutex.Lock()
+	defer s.renameMutex.Unlock()
+	s.rename = rename
+}
+
+// Renames returns the pending dataset renames of every job
+func (s *Scheduler) Renames() []DatasetRename {
+	var renames []DatasetRename
+	for _, sched := range append([]*Scheduler{s}, s.jobs...) {
+		if rename := sched.PendingRename(); rename != nil {
+			renames = append(renames, *rename)
+		}
+	}
+	return renames
+}
+
+// AcceptRename switches the job whose dataset from was renamed to the new
+// name, in the config file as well as in memory
+func (s *Scheduler) AcceptRename(from string) (*DatasetRename, error) {
+	for _, sched := range append([]*Scheduler{s}, s.jobs...) {
+		rename := sched.PendingRename()
+		if rename == nil || rename.From != from {
+			continue
+		}
+		sched.sendMutex.Lock()
+		defer sched.sendMutex.Unlock()
+		return rename, sched.applyRename(rename)
+	}
+	return nil, fmt.Errorf("no rename of %s has been detected", from)
+}
+
+// applyRename points the config file, the ZFS manager, the snapshot catalog
+// and the recorded GUID at the dataset's new name. Called with sendMutex held.
+func (s *Scheduler) applyRename(rename *DatasetRename) error {
+	cfg := s.Config()
+	if cfg.Path != "" {
+		if err := config.RenameDataset(cfg.Path, rename.From, rename.To); err != nil {
+			return fmt.Errorf("failed to update %s: %w", cfg.Path, err)
+		}
+	}
+
+	next := *cfg
+	next.ZFS.Dataset = rename.To
+	s.config.Store(&next)
+	s.zfsManager.SetDataset(rename.To)
+	s.catalog.RenameDataset(rename.From, rename.To)
+	s.datasetGUIDs.rename(rename.From, rename.To)
+	s.setRename(nil)
+
+	s.logger.Info("Following renamed dataset", "from", rename.From, "to", rename.To)
+	return nil
+}
+
+func (s *Scheduler) alertRename(rename *DatasetRename) {
+	action := "Replication is paused until the rename is accepted with POST /api/renames, or set zfs.follow_renames to follow renames automatically."
+	if s.Config().ZFS.FollowRenames {
+		action = "zfs.follow_renames is set, so the config file, snapshot catalog and replication now use the new name."
+	}
+	subject := fmt.Sprintf("[WARNING] Dataset Renamed: %s", rename.From)
+	body := fmt.Sprintf("Dataset Renamed\n\nDataset: %s\nNew name: %s\nGUID: %s\nJob: %s\n\n%s\n",
+		rename.From, rename.To, rename.GUID, rename.Job, action)
+	if err := s.alerter.SendAlert(subject, body); err != nil {
+		s.logger.Error("Failed to send rename alert", "err", err)
+	}
+}
@@ -0,0 +1,318 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/catalog"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/zfs"
+)
+
+// resendHoldTag marks local snapshots that a corrective re-send depends on
+const resendHoldTag = "zfsrabbit-resend"
+
+// ResendJob replaces the remote copies of snapshots flagged as suspect by
+// re-sending them from the local source. Jobs start as a plan that must be
+// confirmed, because the remote snapshots are destroyed before being re-sent.
+type ResendJob struct {
+	ID       string     `json:"id"`
+	Dataset  string     `json:"dataset"`
+	Snapshot string     `json:"snapshot,omitempty"`
+	Base     string     `json:"base,omitempty"` // Last good snapshot kept on the backup server; empty for a full re-send
+	Replace  []string   `json:"replace"`        // Remote snapshots destroyed and re-sent, oldest first
+	Blockers []string   `json:"blockers,omitempty"`
+	Status   string     `json:"status"` // blocked, awaiting_confirmation, running, completed, failed
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// PlanResend works out how to replace the remote copy of a flagged dataset or
+// snapshot and runs the safety checks. The plan is kept until confirmed.
+func (s *Scheduler) PlanResend(dataset, snapshot string) (*ResendJob, error) {
+	if !s.isFlagged(dataset, snapshot) {
+		return nil, fmt.Errorf("%s is not flagged for verification", catalog.Suspect{Dataset: dataset, Snapshot: snapshot}.Target())
+	}
+
+	job, err := s.planResend(dataset, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	s.resendMutex.Lock()
+	s.resendJobs[job.ID] = job
+	result := *job
+	s.resendMutex.Unlock()
+
+	return &result, nil
+}
+
+// ConfirmResend starts a planned re-send
+func (s *Scheduler) ConfirmResend(id string) error {
+	s.resendMutex.Lock()
+	job, ok := s.resendJobs[id]
+	if !ok {
+		s.resendMutex.Unlock()
+		return fmt.Errorf("re-send job %s not found", id)
+	}
+	if job.Status != "awaiting_confirmation" {
+		s.resendMutex.Unlock()
+		return fmt.Errorf("re-send job %s is not awaiting confirmation (status: %s)", id, job.Status)
+	}
+	job.Status = "running"
+	s.resendMutex.Unlock()
+
+	go s.runResend(job)
+	return nil
+}
+
+// ResendJobs returns all planned and executed re-sends, newest first
+func (s *Scheduler) ResendJobs() []ResendJob {
+	s.resendMutex.Lock()
+	defer s.resendMutex.Unlock()
+
+	jobs := make([]ResendJob, 0, len(s.resendJobs))
+	for _, job := range s.resendJobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Created.After(jobs[j].Created)
+	})
+	return jobs
+}
+
+func (s *Scheduler) isFlagged(dataset, snapshot string) bool {
+	for _, suspect := range s.catalog.NeedsVerification() {
+		if suspect.Dataset == dataset && suspect.Snapshot == snapshot {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scheduler) planResend(dataset, snapshot string) (*ResendJob, error) {
+	now := time.Now()
+	job := &ResendJob{
+		ID:       fmt.Sprintf("resend_%d", now.UnixNano()),
+		Dataset:  dataset,
+		Snapshot: snapshot,
+		Created:  now,
+	}
+
+	remoteSnapshots, err := s.transport.ListRemoteSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote snapshots: %w", err)
+	}
+	localSnapshots, err := s.zfsManager.ListSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local snapshots: %w", err)
+	}
+
+	local := make(map[string]bool, len(localSnapshots))
+	for _, snap := range localSnapshots {
+		local[snap.Name] = true
+	}
+
+	planReplacement(job, remoteSnapshots, local)
+	job.Blockers = append(job.Blockers, s.sourceErrors()...)
+
+	job.Status = "awaiting_confirmation"
+	if len(job.Blockers) > 0 {
+		job.Status = "blocked"
+	}
+	return job, nil
+}
+
+// planReplacement picks the remote snapshots to replace and the base to
+// re-send them from, recording blockers for anything that would lose data
+func planReplacement(job *ResendJob, remoteSnapshots []string, local map[string]bool) {
+	// A flagged snapshot and everything received after it must be replaced,
+	// since later incrementals were built on top of it
+	start := 0
+	if job.Snapshot != "" {
+		start = -1
+		for i, remote := range remoteSnapshots {
+			if remote == job.Snapshot {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			job.Blockers = append(job.Blockers, fmt.Sprintf("snapshot %s is not on the backup server", job.Snapshot))
+			return
+		}
+	}
+
+	if start > 0 && local[remoteSnapshots[start-1]] {
+		job.Base = remoteSnapshots[start-1]
+	} else {
+		// Without a shared snapshot before the damage the whole chain is re-sent
+		start = 0
+	}
+	job.Replace = append([]string{}, remoteSnapshots[start:]...)
+
+	if len(job.Replace) == 0 {
+		job.Blockers = append(job.Blockers, "the backup server holds no snapshots to replace")
+	}
+	for _, name := range job.Replace {
+		if !local[name] {
+			job.Blockers = append(job.Blockers, fmt.Sprintf("remote snapshot %s no longer exists locally and would be lost", name))
+		}
+	}
+}
+
+// sourceErrors reports permanent errors still present in the replicated
+// datasets; re-sending from a damaged source would only copy the damage
+func (s *Scheduler) sourceErrors() []string {
+	root := s.Config().ZFS.Dataset
+	pool := strings.SplitN(root, "/", 2)[0]
+
+	status, err := zfs.GetPoolStatus(pool)
+	if err != nil {
+		return []string{fmt.Sprintf("could not check pool %s for errors: %v", pool, err)}
+	}
+
+	var mountpoints map[string]string
+	if len(status.Errors) > 0 {
+		mountpoints, _ = zfs.GetMountpointsContext(context.Background())
+	}
+
+	var blockers []string
+	for _, dataErr := range zfs.ParseDataErrors(status.Errors, mountpoints) {
+		if dataErr.Dataset == root || strings.HasPrefix(dataErr.Dataset, root+"/") {
+			blockers = append(blockers, fmt.Sprintf("source still has permanent errors (%s); repair the files and scrub %s first", dataErr.Entry, pool))
+		}
+	}
+	return blockers
+}
+
+func (s *Scheduler) runResend(job *ResendJob) {
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+
+	err := s.executeResend(job)
+
+	s.resendMutex.Lock()
+	finished := time.Now()
+	job.Finished = &finished
+	if err != nil {
+		job.Status = "failed"
+		job.Error = err.Error()
+	} else {
+		job.Status = "completed"
+	}
+	s.resendMutex.Unlock()
+
+	if err != nil {
+		s.logger.Error("Corrective re-send failed", "resend", job.ID, "err", err)
+		s.alerter.SendSyncFailure(job.Replace[len(job.Replace)-1], s.Config().ZFS.Dataset, fmt.Errorf("corrective re-send failed: %w", err))
+		return
+	}
+
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: corrective re-send left the remote snapshots in place", "resend", job.ID)
+		return
+	}
+	s.catalog.ClearVerification(job.Dataset, job.Snapshot)
+	s.logger.Info("Corrective re-send replaced remote snapshots", "resend", job.ID, "count", len(job.Replace), "dataset", s.Config().ZFS.Dataset)
+	s.alerter.SendSyncSuccess(job.Replace[len(job.Replace)-1], s.Config().ZFS.Dataset, finished.Sub(job.Created))
+}
+
+func (s *Scheduler) executeResend(job *ResendJob) error {
+	// The chain may have moved on while the plan awaited confirmation
+	current, err := s.planResend(job.Dataset, job.Snapshot)
+	if err != nil {
+		return err
+	}
+	if len(current.Blockers) > 0 {
+		return fmt.Errorf("safety check failed: %s", strings.Join(current.Blockers, "; "))
+	}
+	if current.Base != job.Base || strings.Join(current.Replace, ",") != strings.Join(job.Replace, ",") {
+		return fmt.Errorf("backup chain changed since the plan was made, plan the re-send again")
+	}
+
+	// Hold every snapshot the re-send reads so retention can't prune them mid-way
+	held := job.Replace
+	if job.Base != "" {
+		held = append([]string{job.Base}, job.Replace...)
+	}
+	for i, name := range held {
+		if err := s.zfsManager.HoldSnapshot(name, resendHoldTag); err != nil {
+			for _, h := range held[:i] {
+				s.zfsManager.ReleaseSnapshot(h, resendHoldTag)
+			}
+			return fmt.Errorf("failed to hold %s: %w", name, err)
+		}
+	}
+	defer func() {
+		for _, name := range held {
+			if err := s.zfsManager.ReleaseSnapshot(name, resendHoldTag); err != nil {
+				s.logger.Warn("Failed to release hold", "resend", job.ID, "snapshot", name, "err", err)
+			}
+		}
+	}()
+
+	first, last := job.Replace[0], job.Replace[len(job.Replace)-1]
+
+	if job.Base != "" {
+		s.logger.Info("Destroying remote snapshots to re-send them", "resend", job.ID, "first", first, "last", last, "base", job.Base)
+		if err := s.transport.DestroyRemoteSnapshotRange(first, last); err != nil {
+			return err
+		}
+		return s.streamResend(s.Config().SSH.RemoteDataset, job.Base, last)
+	}
+
+	// Receive the full chain beside the existing copy and only swap it in once complete
+	staging := s.Config().SSH.RemoteDataset + "_resend"
+	s.logger.Info("Re-sending full chain", "resend", job.ID, "first", first, "last", last, "staging", staging)
+	if err := s.streamResend(staging, "", first); err != nil {
+		return err
+	}
+	if first != last {
+		if err := s.streamResend(staging, first, last); err != nil {
+			return err
+		}
+	}
+	return s.transport.ReplaceRemoteDataset(staging)
+}
+
+// streamResend sends a full stream of toSnapshot, or every snapshot after
+// fromSnapshot up to toSnapshot, into remoteDataset
+func (s *Scheduler) streamResend(remoteDataset, fromSnapshot, toSnapshot string) error {
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: would re-send snapshot range", "from", fromSnapshot, "to", toSnapshot, "remote_dataset", remoteDataset)
+		return nil
+	}
+
+	var sendCmd *exec.Cmd
+	var err error
+	if fromSnapshot == "" {
+		sendCmd, err = s.zfsManager.SendSnapshot(toSnapshot)
+	} else {
+		sendCmd, err = s.zfsManager.SendIncrementalRange(fromSnapshot, toSnapshot)
+	}
+	if err != nil {
+		return err
+	}
+
+	stdout, err := sendCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := sendCmd.Start(); err != nil {
+		return err
+	}
+
+	if err := s.transport.SendSnapshotTo(stdout, remoteDataset, fromSnapshot != ""); err != nil {
+		sendCmd.Process.Kill()
+		return err
+	}
+
+	return sendCmd.Wait()
+}
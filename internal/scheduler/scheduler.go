@@ -2,82 +2,509 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/robfig/cron/v3"
+	"zfsrabbit/internal/catalog"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/features"
+	"zfsrabbit/internal/logging"
+	"zfsrabbit/internal/objectstore"
+	"zfsrabbit/internal/policy"
+	"zfsrabbit/internal/retention"
+	"zfsrabbit/internal/selfbackup"
+	"zfsrabbit/internal/sla"
+	"zfsrabbit/internal/state"
+	"zfsrabbit/internal/throughput"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
 	"zfsrabbit/internal/zfs"
 )
 
+var logger = logging.For("scheduler")
+
 type Scheduler struct {
-	cron         *cron.Cron
-	config       *config.Config
-	zfsManager   *zfs.Manager
-	transport    *transport.SSHTransport
-	alerter      SyncAlerter
-	ctx          context.Context
-	cancel       context.CancelFunc
-	pendingSends []string // Snapshots that failed to send and need retry
-	sendMutex    sync.Mutex // Prevents concurrent sends to same backup server
+	name          string // "default" for the zfs section, otherwise the jobs entry's name
+	logger        *slog.Logger
+	cron          *cron.Cron
+	snapshotEntry cron.EntryID
+	scrubEntry    cron.EntryID
+	config        atomic.Pointer[config.Config] // Replaced whole by ApplyPolicy between runs, never modified
+	zfsManager    *zfs.Manager
+	transport     *transport.SSHTransport
+	alerter       SyncAlerter
+	catalog       *catalog.Catalog
+	slaTracker    *sla.Tracker
+	ctx           context.Context
+	cancel        context.CancelFunc
+	targets       []*replicationTarget // ssh first, then each configured remote
+	sendMutex     sync.Mutex           // Prevents concurrent sends to same backup server
+	tierMutex     sync.Mutex           // Archiving runs outside sendMutex, one run at a time
+	resendJobs    map[string]*ResendJob
+	resendMutex   sync.Mutex
+	jobs          []*Scheduler // One per jobs entry, sharing cron, catalog and workers
+	pendingStore  *pendingStore
+	history       *runHistory
+	throughput    *throughput.History
+	workers       chan struct{} // Slots for schedule.max_concurrent_jobs
+	events        *events.Bus   // Receives send progress
+	created       time.Time     // Lag is measured from here until a target's first success
+	deferredSince time.Time     // When sends started waiting for a busy pool
+	// Also uploads snapshots to S3 when s3 is enabled
+	objectStore *objectstore.Store
+	// GUIDs of the managed datasets, to recognise them after a zfs rename
+	datasetGUIDs *guidStore
+	rename       *DatasetRename // Detected and not yet accepted; guarded by renameMutex
+	renameMutex  sync.Mutex
+	// Guards each target's newest remote snapshot and lag alert state
+	lagMutex sync.Mutex
+	// Guards deferredSince and each target's queue, outcomes and send in
+	// progress for status reads; they are written holding both locks
+	statusMutex sync.Mutex
+}
+
+// JobStatus reports one dataset's replication job
+type JobStatus struct {
+	Name     string         `json:"name"`
+	Dataset  string         `json:"dataset"`
+	Schedule string         `json:"schedule"`
+	Targets  []TargetStatus `json:"targets"`
+
+	DeferredSince *time.Time `json:"deferred_since,omitempty"` // Sends are waiting for the pool to go quiet
+}
+
+// replicationTarget is one backup server snapshots are replicated to. Each
+// has its own retry queue so an unreachable server doesn't hold back the others.
+type replicationTarget struct {
+	name        string
+	transport   *transport.SSHTransport // Also holds the target's SSH config
+	pending     []string                // Snapshots that failed to send and need retry
+	lastSuccess time.Time
+	lastError   string
+
+	sending     string // Snapshot being sent, if any
+	sendStarted time.Time
+	estimate    int64 // Dry-run size of the stream being sent; 0 if unknown
+	sent        atomic.Int64
+	outcomes    []bool // Whether each of the latest sends succeeded, oldest first
+
+	// Newest snapshot known to be on the target and whether its lag has
+	// been alerted on, under lagMutex
+	newest     string
+	newestTime time.Time
+	lagging    bool
+}
+
+// TargetStatus reports replication state for one target
+type TargetStatus struct {
+	Name        string     `json:"name"`
+	Job         string     `json:"job"` // "default" for the zfs section
+	Host        string     `json:"host"`
+	Dataset     string     `json:"dataset"`
+	Pending     []string   `json:"pending"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	// The send in progress, with its dry-run size and expected duration
+	Sending          string     `json:"sending,omitempty"`
+	SendStarted      *time.Time `json:"send_started,omitempty"`
+	EstimatedBytes   int64      `json:"estimated_bytes,omitempty"`
+	EstimatedSeconds int64      `json:"estimated_seconds,omitempty"`
+	SentBytes        int64      `json:"sent_bytes,omitempty"`
 }
 
 type SyncAlerter interface {
+	SendSyncStart(snapshot, dataset string, estimatedBytes int64, eta time.Duration) error
 	SendSyncSuccess(snapshot, dataset string, duration time.Duration) error
 	SendSyncFailure(snapshot, dataset string, err error) error
+	SendAlert(subject, body string) error
 }
 
 func New(cfg *config.Config, zfsManager *zfs.Manager, transport *transport.SSHTransport, alerter SyncAlerter) *Scheduler {
+	s := newScheduler("default", cfg, zfsManager, transport, alerter)
+	s.catalog = catalog.Open(state.PathIn(cfg.Server.StateDir, state.CatalogFile))
+	s.workers = make(chan struct{}, max(cfg.Schedule.MaxConcurrentJobs, 1))
+	s.pendingStore = openPendingStore(state.PathIn(cfg.Server.StateDir, state.PendingFile))
+	s.history = openRunHistory(state.PathIn(cfg.Server.StateDir, state.RunsFile))
+	s.throughput = throughput.Open(state.PathIn(cfg.Server.StateDir, state.ThroughputFile))
+	s.datasetGUIDs = openGUIDStore(state.PathIn(cfg.Server.StateDir, state.DatasetsFile))
+
+	for _, job := range cfg.Jobs {
+		s.jobs = append(s.jobs, newJob(s, job))
+	}
+
+	// A recursive job leaves out children other jobs replicate, so their
+	// data isn't snapshotted and sent twice
+	for _, sched := range append([]*Scheduler{s}, s.jobs...) {
+		if sched.zfsManager.Recursive() {
+			sched.zfsManager.SetExcluded(cfg.ManagedChildren(sched.Config().ZFS.Dataset))
+		}
+	}
+
+	configured := make(map[string]bool)
+	for _, sched := range append([]*Scheduler{s}, s.jobs...) {
+		sched.restorePending()
+		for _, target := range sched.targets {
+			configured[sched.pendingKey(target)] = true
+		}
+	}
+	s.pendingStore.retain(configured)
+	return s
+}
+
+func newScheduler(name string, cfg *config.Config, zfsManager *zfs.Manager, transport *transport.SSHTransport, alerter SyncAlerter) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Scheduler{
+	s := &Scheduler{
+		name:       name,
+		logger:     logger.With("job", name),
 		cron:       cron.New(),
-		config:     cfg,
 		zfsManager: zfsManager,
 		transport:  transport,
 		alerter:    alerter,
+		targets:    newTargets(cfg, transport),
+		resendJobs: make(map[string]*ResendJob),
+		history:    openRunHistory(""),
+		throughput: throughput.Open(""),
 		ctx:        ctx,
 		cancel:     cancel,
+		created:    time.Now(),
+	}
+
+	s.config.Store(cfg)
+	s.datasetGUIDs = openGUIDStore("")
+
+	switch {
+	case !cfg.S3.Enabled:
+	case !features.Enabled(cfg.Features, features.ObjectStoreTarget):
+		s.logger.Warn("Object storage disabled", "reason", "s3.enabled is set but the object_store_target feature flag is off")
+	default:
+		store, err := objectstore.New(&cfg.S3)
+		if err != nil {
+			s.logger.Error("Object storage disabled", "err", err)
+		} else {
+			s.objectStore = store
+		}
+	}
+	return s
+}
+
+// newJob builds the scheduler for a jobs entry. It runs on a copy of the
+// config with the job's dataset, retention and target in place of the zfs
+// and ssh sections, so the replication code is shared with the default job.
+// Extra remotes and self-backup only apply to the default job.
+func newJob(parent *Scheduler, job config.JobConfig) *Scheduler {
+	cfg := *parent.Config()
+	cfg.ZFS = job.ZFSConfig
+	cfg.SSH = job.Target
+	cfg.Remotes = nil
+	cfg.Schedule.SnapshotCron = job.SnapshotCron
+	cfg.SelfBackup.Enabled = false
+	cfg.Jobs = nil
+
+	zfsManager := zfs.New(job.Dataset, job.SendCompression, job.Recursive)
+	zfsManager.SetRawSend(job.RawSend)
+	s := newScheduler(job.Name, &cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), parent.alerter)
+	s.cron = parent.cron
+	s.catalog = parent.catalog
+	s.workers = parent.workers
+	s.pendingStore = parent.pendingStore
+	s.history = parent.history
+	s.throughput = parent.throughput
+	s.datasetGUIDs = parent.datasetGUIDs
+	s.ctx, s.cancel = parent.ctx, parent.cancel
+	return s
+}
+
+// newTargets builds the fan-out list: the ssh section reuses the shared
+// transport, each remote gets its own connection
+func newTargets(cfg *config.Config, primary *transport.SSHTransport) []*replicationTarget {
+	targets := []*replicationTarget{{name: "primary", transport: primary}}
+	for i := range cfg.Remotes {
+		remote := &cfg.Remotes[i]
+		targets = append(targets, &replicationTarget{
+			name:      remote.Name,
+			transport: transport.NewSSHTransport(&remote.SSHConfig),
+		})
 	}
+	return targets
 }
 
 func (s *Scheduler) Start() error {
-	if _, err := s.cron.AddFunc(s.config.Schedule.SnapshotCron, s.performSnapshot); err != nil {
-		return fmt.Errorf("failed to add snapshot job: %w", err)
+	if err := s.scheduleJobs(); err != nil {
+		return err
 	}
 
-	if _, err := s.cron.AddFunc(s.config.Schedule.ScrubCron, s.performScrub); err != nil {
-		return fmt.Errorf("failed to add scrub job: %w", err)
+	for _, job := range s.jobs {
+		if err := job.scheduleJobs(); err != nil {
+			return fmt.Errorf("job %s: %w", job.name, err)
+		}
 	}
 
-	if _, err := s.cron.AddFunc(s.config.Schedule.RetryCron, s.performRetry); err != nil {
+	if _, err := s.cron.AddFunc(s.Config().Schedule.RetryCron, s.performRetry); err != nil {
 		return fmt.Errorf("failed to add retry job: %w", err)
 	}
 
+	if _, err := s.cron.AddFunc(lagCheckSchedule, func() { s.CheckLag() }); err != nil {
+		return fmt.Errorf("failed to add lag check: %w", err)
+	}
+
 	s.cron.Start()
-	log.Println("Scheduler started")
+	s.logger.Info("Scheduler started")
+	return nil
+}
+
+// scheduleJobs (re)registers the policy-driven snapshot and scrub jobs.
+// Scrubs are per pool, so only the default job schedules them.
+func (s *Scheduler) scheduleJobs() error {
+	cfg := s.Config()
+	snapshotEntry, err := s.cron.AddFunc(cfg.Schedule.SnapshotCron, s.runScheduled)
+	if err != nil {
+		return fmt.Errorf("failed to add snapshot job: %w", err)
+	}
+
+	var scrubEntry cron.EntryID
+	if s.name == "default" {
+		scrubEntry, err = s.cron.AddFunc(cfg.Schedule.ScrubCron, s.performScrub)
+		if err != nil {
+			s.cron.Remove(snapshotEntry)
+			return fmt.Errorf("failed to add scrub job: %w", err)
+		}
+	}
+
+	if s.snapshotEntry != 0 {
+		s.cron.Remove(s.snapshotEntry)
+	}
+	if s.scrubEntry != 0 {
+		s.cron.Remove(s.scrubEntry)
+	}
+	s.snapshotEntry = snapshotEntry
+	s.scrubEntry = scrubEntry
 	return nil
 }
 
+// ApplyPolicy reconciles the running schedules, datasets and replication
+// targets of the default job and each jobs entry to the given policy set. It
+// waits for each job's in-flight send to finish and reports whether anything
+// changed.
+func (s *Scheduler) ApplyPolicy(set *policy.Set) (bool, error) {
+	if err := set.Validate(); err != nil {
+		return false, err
+	}
+	if err := set.Matches(s.Config()); err != nil {
+		return false, err
+	}
+
+	changed, err := s.applyPolicy(set.Policies[0])
+	if err != nil {
+		return changed, err
+	}
+	for _, p := range set.Policies[1:] {
+		for _, job := range s.jobs {
+			if job.name != p.Name {
+				continue
+			}
+			jobChanged, err := job.applyPolicy(p)
+			changed = changed || jobChanged
+			if err != nil {
+				return changed, err
+			}
+		}
+	}
+	return changed, nil
+}
+
+// applyPolicy swaps in a copy of the config with p applied. Holding
+// sendMutex means no run is using the dataset, connection or config being
+// replaced; runs load the config once, so they see the old or the new one.
+func (s *Scheduler) applyPolicy(p policy.Policy) (bool, error) {
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+
+	previous := s.Config()
+	next := *previous
+	if !(&policy.Set{Policies: []policy.Policy{p}}).Apply(&next) {
+		return false, nil
+	}
+	s.config.Store(&next)
+
+	if previous.Schedule.SnapshotCron != next.Schedule.SnapshotCron || previous.Schedule.ScrubCron != next.Schedule.ScrubCron {
+		if err := s.scheduleJobs(); err != nil {
+			return true, err
+		}
+	}
+
+	if previous.ZFS.Dataset != next.ZFS.Dataset || previous.ZFS.Recursive != next.ZFS.Recursive {
+		s.zfsManager.Reconfigure(next.ZFS.Dataset, next.ZFS.Recursive)
+	}
+
+	if previous.SSH != next.SSH || previous.ZFS.Dataset != next.ZFS.Dataset {
+		// Pending snapshots belong to the old replication pair
+		if primary := s.targets[0]; len(primary.pending) > 0 {
+			s.logger.Warn("Dropping pending sends after replication target change", "count", len(primary.pending))
+			s.statusMutex.Lock()
+			primary.pending = nil
+			s.statusMutex.Unlock()
+			s.savePending()
+		}
+		// Reconnects to the new target on next use
+		s.transport.Reconfigure(&next.SSH)
+	}
+
+	s.logger.Info("Applied policy", "policy", p.Name, "dataset", p.Dataset,
+		"target", fmt.Sprintf("%s@%s:%s", p.Target.User, p.Target.Host, p.Target.Dataset),
+		"snapshot_cron", p.Schedule.Snapshot, "keep", p.Retention.KeepSnapshots)
+	return true, nil
+}
+
+// Config returns the config in effect, which a policy change replaces rather
+// than modifies, so callers can read it without locking
+func (s *Scheduler) Config() *config.Config {
+	return s.config.Load()
+}
+
+// DestroyedSnapshots returns the catalog trail of snapshots pruned by retention
+func (s *Scheduler) DestroyedSnapshots() []catalog.Entry {
+	return s.catalog.Destroyed()
+}
+
+// Catalog returns the snapshot catalog so other components can record into it
+func (s *Scheduler) Catalog() *catalog.Catalog {
+	return s.catalog
+}
+
+// Throughput returns the transfer history shared by every job, which restores
+// also record into and estimate from
+func (s *Scheduler) Throughput() *throughput.History {
+	return s.throughput
+}
+
+// SetEvents publishes send progress of this and every job to bus
+func (s *Scheduler) SetEvents(bus *events.Bus) {
+	s.events = bus
+	for _, job := range s.jobs {
+		job.events = bus
+	}
+}
+
+// SetSLATracker reports replications that reach every target to tracker
+func (s *Scheduler) SetSLATracker(tracker *sla.Tracker) {
+	s.slaTracker = tracker
+	for _, job := range s.jobs {
+		job.slaTracker = tracker
+	}
+}
+
+// Policy returns the policy set currently in effect
+func (s *Scheduler) Policy() *policy.Set {
+	set := policy.FromConfig(s.Config())
+	// Jobs entries may have been changed since by their own policies
+	for i, job := range s.jobs {
+		set.Policies[i+1] = policy.FromConfig(job.Config()).Policies[0]
+		set.Policies[i+1].Name = job.name
+	}
+	return set
+}
+
 func (s *Scheduler) Stop() {
 	s.cancel()
 	s.cron.Stop()
-	log.Println("Scheduler stopped")
+	for _, target := range s.targets[1:] {
+		target.transport.Close()
+	}
+	for _, job := range s.jobs {
+		for _, target := range job.targets {
+			target.transport.Close()
+		}
+	}
+	s.logger.Info("Scheduler stopped")
+}
+
+// runScheduled takes a snapshot once one of the schedule.max_concurrent_jobs
+// worker slots is free
+func (s *Scheduler) runScheduled() {
+	if !s.acquireWorker() {
+		return
+	}
+	defer s.releaseWorker()
+
+	s.snapshotAndSend(true)
 }
 
+// performSnapshot takes a manually triggered snapshot, which waits for a
+// worker slot like scheduled ones
 func (s *Scheduler) performSnapshot() {
+	if !s.acquireWorker() {
+		return
+	}
+	defer s.releaseWorker()
+
+	s.snapshotAndSend(false)
+}
+
+// acquireWorker waits for one of the worker slots shared by every job,
+// reporting false if the scheduler stops first
+func (s *Scheduler) acquireWorker() bool {
+	select {
+	case s.workers <- struct{}{}:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *Scheduler) releaseWorker() {
+	<-s.workers
+}
+
+// snapshotAndSend takes a snapshot and replicates it to every target. When
+// deferrable, the send waits in the retry queue if the pool is busy.
+func (s *Scheduler) snapshotAndSend(deferrable bool) {
+	if !s.replicateSnapshot(deferrable) || !s.Config().Tiering.Enabled {
+		return
+	}
+
+	// Archiving reads old streams off the backup pool, which can take hours,
+	// so it doesn't hold up the next send
+	if err := s.tierOldSnapshots(); err != nil {
+		s.logger.Error("Failed to archive old snapshots", "err", err)
+	}
+}
+
+// replicateSnapshot is the part of snapshotAndSend under sendMutex. It
+// reports whether every target has the snapshot.
+func (s *Scheduler) replicateSnapshot(deferrable bool) bool {
 	// Use mutex to prevent concurrent sends to same backup server
 	s.sendMutex.Lock()
 	defer s.sendMutex.Unlock()
 	
-	log.Println("Starting scheduled snapshot")
-	
+	s.logger.Info("Starting scheduled snapshot", "dataset", s.Config().ZFS.Dataset)
+
+	if err := s.checkDatasetRename(); err != nil {
+		// Alerted once when the rename was detected
+		s.logger.Error("Skipping snapshot", "err", err)
+		s.recordRun(RunSnapshot, s.Config().ZFS.Dataset, "", time.Now(), err)
+		return false
+	}
+
+	// Loaded after a followed rename; a policy change waits for sendMutex
+	cfg := s.Config()
+
+	deferred := deferrable && s.sendsDeferred()
+
 	// First, try to send any pending snapshots from previous failures
-	if len(s.pendingSends) > 0 {
-		log.Printf("Attempting to retry %d pending snapshots", len(s.pendingSends))
+	if pending := s.pendingCount(); pending > 0 && !deferred {
+		s.logger.Info("Retrying pending snapshots first", "count", pending)
 		s.retryPendingSendsUnsafe() // Don't fail if retry fails, just log
 	}
 	
@@ -86,41 +513,264 @@ func (s *Scheduler) performSnapshot() {
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
 	snapshotName := fmt.Sprintf("autosnap_%s", timestamp)
 
-	if err := s.zfsManager.CreateSnapshot(snapshotName); err != nil {
-		log.Printf("Failed to create snapshot: %v", err)
-		s.alerter.SendSyncFailure(snapshotName, s.config.ZFS.Dataset, err)
+	if err := s.runPreSnapshotHooks(snapshotName); err != nil {
+		s.logger.Error("Skipping snapshot", "snapshot", snapshotName, "err", err)
+		s.runPostSnapshotHooks(snapshotName, snapshotSkipped)
+		s.alerter.SendSyncFailure(snapshotName, cfg.ZFS.Dataset, err)
+		s.recordRun(RunSnapshot, cfg.ZFS.Dataset, snapshotName, startTime, err)
+		return false
+	}
+
+	err := s.zfsManager.CreateSnapshot(snapshotName)
+	status := snapshotTaken
+	if err != nil {
+		status = snapshotFailed
+	}
+	s.runPostSnapshotHooks(snapshotName, status)
+	if err != nil {
+		s.logger.Error("Failed to create snapshot", "snapshot", snapshotName, "err", err)
+		s.alerter.SendSyncFailure(snapshotName, cfg.ZFS.Dataset, err)
+		s.recordRun(RunSnapshot, cfg.ZFS.Dataset, snapshotName, startTime, err)
+		return false
+	}
+
+	s.logger.Info("Created snapshot", "snapshot", snapshotName)
+
+	if deferred {
+		s.deferSend(snapshotName, startTime)
+		return false
+	}
+
+	failed := 0
+	for _, target := range s.targets {
+		if err := s.sendSnapshot(target, snapshotName); err != nil {
+			failed++
+			s.logger.Error("Failed to send snapshot", "snapshot", snapshotName, "target", target.name, "err", err)
+			s.alerter.SendSyncFailure(snapshotName, cfg.ZFS.Dataset, s.targetError(target, err))
+
+			// Add to this target's pending sends for retry, unless the
+			// snapshot was never really created
+			if utils.DefaultRunner.DryRun() {
+				continue
+			}
+			s.statusMutex.Lock()
+			target.pending = append(target.pending, snapshotName)
+			s.statusMutex.Unlock()
+			s.logger.Info("Queued snapshot for retry", "snapshot", snapshotName, "target", target.name, "pending", len(target.pending))
+		}
+	}
+
+	// Object storage is a second copy; a failed upload alerts but doesn't
+	// hold back retention, the next upload is then a full stream if needed
+	if s.objectStore != nil {
+		if err := s.uploadSnapshot(snapshotName); err != nil {
+			s.logger.Error("Failed to upload snapshot to object storage", "snapshot", snapshotName, "err", err)
+			s.alerter.SendSyncFailure(snapshotName, cfg.ZFS.Dataset, fmt.Errorf("object storage: %w", err))
+		}
+	}
+
+	if failed > 0 {
+		s.savePending()
+		s.recordRun(RunSnapshot, cfg.ZFS.Dataset, snapshotName, startTime,
+			fmt.Errorf("send failed to %d of %d targets", failed, len(s.targets)))
+	} else {
+		duration := time.Since(startTime)
+		s.recordRun(RunSnapshot, cfg.ZFS.Dataset, snapshotName, startTime, nil)
+		s.logger.Info("Sent snapshot", "snapshot", snapshotName, "duration", duration)
+		s.sendSyncSuccess(snapshotName, cfg.ZFS.Dataset, duration)
+		s.recordSLASuccess()
+
+		if cfg.ZFS.BookmarkOnSend {
+			s.bookmarkSentSnapshot(snapshotName)
+		}
+	}
+
+	// Retention runs even when a target is offline, keeping what that target
+	// still needs to catch up
+	if err := s.cleanupOldSnapshots(); err != nil {
+		s.logger.Error("Failed to clean up old snapshots", "err", err)
+	}
+
+	// Self-backup waits until every target has the snapshot
+	if failed > 0 {
+		return false
+	}
+	if cfg.SelfBackup.Enabled {
+		s.backupOwnState()
+	}
+	return true
+}
+
+// backupOwnState copies zfsrabbit's config and state directory to the backup
+// server so a replacement host can be bootstrapped from it
+func (s *Scheduler) backupOwnState() {
+	host, err := os.Hostname()
+	if err != nil {
+		s.logger.Error("Failed to back up zfsrabbit state", "err", err)
+		return
+	}
+
+	cfg := s.Config()
+	remotePath := selfbackup.RemotePath(cfg.SelfBackup.RemoteDir, host)
+	if err := selfbackup.Backup(s.transport, remotePath, cfg.Path, cfg.Server.StateDir); err != nil {
+		s.logger.Error("Failed to back up zfsrabbit state", "err", err)
 		return
 	}
 
-	log.Printf("Created snapshot: %s", snapshotName)
+	s.logger.Info("Backed up zfsrabbit config and state", "host", cfg.SSH.RemoteHost, "path", remotePath)
+}
+
+// sendSnapshot replicates snapshotName to one target and records the outcome
+func (s *Scheduler) sendSnapshot(target *replicationTarget, snapshotName string) error {
+	s.startSend(target, snapshotName)
+	defer s.endSend(target)
+	s.publishSend(target, snapshotName, events.PhaseStarted, nil)
+
+	// Queue the snapshot on disk while it is in flight, so a crash mid-send
+	// leaves it to be reconciled and retried on the next start
+	if !slices.Contains(target.pending, snapshotName) && !utils.DefaultRunner.DryRun() {
+		key := s.pendingKey(target)
+		s.pendingStore.set(key, append(slices.Clone(target.pending), snapshotName))
+		defer func() { s.pendingStore.set(key, target.pending) }()
+	}
+
+	err := s.replicate(target, snapshotName)
+	if err != nil {
+		s.recordSendOutcome(target, err)
+		s.publishSend(target, snapshotName, events.PhaseFailed, err)
+		return err
+	}
+	s.publishSend(target, snapshotName, events.PhaseCompleted, nil)
+
+	// Nothing was sent, so the target's health and lag stay as they were
+	if utils.DefaultRunner.DryRun() {
+		return nil
+	}
+	s.recordSendOutcome(target, nil)
+	s.noteRemoteSnapshot(target, snapshotName)
+	if target.estimate > 0 {
+		s.throughput.Record(target.transport.Config().RemoteHost, throughput.Send, target.estimate, target.lastSuccess.Sub(target.sendStarted))
+	}
+	return nil
+}
+
+// startSend marks target as sending snapshotName
+func (s *Scheduler) startSend(target *replicationTarget, snapshotName string) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	target.sending = snapshotName
+	target.sendStarted = time.Now()
+	target.estimate = 0
+	target.sent.Store(0)
+}
+
+func (s *Scheduler) endSend(target *replicationTarget) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	target.sending = ""
+}
 
-	if err := s.sendSnapshot(snapshotName); err != nil {
-		log.Printf("Failed to send snapshot: %v", err)
-		s.alerter.SendSyncFailure(snapshotName, s.config.ZFS.Dataset, err)
-		
-		// Add to pending sends for retry
-		s.pendingSends = append(s.pendingSends, snapshotName)
-		log.Printf("Added snapshot %s to retry queue (%d pending)", snapshotName, len(s.pendingSends))
+// recordSendOutcome records a finished send to target; err is nil on success
+func (s *Scheduler) recordSendOutcome(target *replicationTarget, err error) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	target.recordOutcome(err == nil)
+	if err != nil {
+		target.lastError = err.Error()
 		return
 	}
+	target.lastSuccess = time.Now()
+	target.lastError = ""
+}
 
-	duration := time.Since(startTime)
-	log.Printf("Successfully sent snapshot: %s (took %s)", snapshotName, duration)
-	s.alerter.SendSyncSuccess(snapshotName, s.config.ZFS.Dataset, duration)
+// estimateSend sizes the stream about to be sent to target with a dry run,
+// then logs and announces the send. A failed estimate doesn't stop the send.
+func (s *Scheduler) estimateSend(target *replicationTarget, from, snapshotName string) {
+	size, err := s.zfsManager.EstimateSend(from, snapshotName)
+	if err != nil {
+		s.logger.Warn("Sending with unknown size", "snapshot", snapshotName, "target", target.name, "err", err)
+		return
+	}
+	s.statusMutex.Lock()
+	target.estimate = size
+	s.statusMutex.Unlock()
 
-	if err := s.cleanupOldSnapshots(); err != nil {
-		log.Printf("Failed to cleanup old snapshots: %v", err)
+	eta := s.eta(target, size)
+	attrs := []any{"snapshot", snapshotName, "target", target.name, "size", display.Bytes(size)}
+	if eta > 0 {
+		attrs = append(attrs, "eta", eta)
+	}
+	s.logger.Info("Sending snapshot", attrs...)
+
+	if err := s.alerter.SendSyncStart(snapshotName, s.Config().ZFS.Dataset, size, eta); err != nil {
+		s.logger.Warn("Failed to send sync start notification", "err", err)
 	}
 }
 
-func (s *Scheduler) sendSnapshot(snapshotName string) error {
-	remoteSnapshots, err := s.transport.ListRemoteSnapshots()
+// eta estimates how long sending size bytes to the target takes, from the
+// throughput of recent transfers with its server or else the configured rate
+// limit; 0 if unknown
+func (s *Scheduler) eta(target *replicationTarget, size int64) time.Duration {
+	rate := s.throughput.Rate(target.transport.Config().RemoteHost, throughput.Send)
+	if rate == 0 {
+		if limit, err := config.ParseRate(target.transport.Config().MaxSendRate); err == nil && limit > 0 {
+			rate = float64(limit)
+		}
+	}
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(size) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// targetError names the target in errors once there is more than one
+func (s *Scheduler) targetError(target *replicationTarget, err error) error {
+	if len(s.targets) == 1 {
+		return err
+	}
+	return fmt.Errorf("target %s (%s:%s): %w", target.name, target.transport.Config().RemoteHost, target.transport.Config().RemoteDataset, err)
+}
+
+func (s *Scheduler) replicate(target *replicationTarget, snapshotName string) error {
+	dest := target.transport
+	remoteSnapshots, err := dest.ListRemoteSnapshots()
 	if err != nil {
 		return fmt.Errorf("failed to list remote snapshots, aborting sync to prevent data loss: %w", err)
 	}
 
+	// The snapshot wasn't really created, so there is nothing to stream
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: would send snapshot", "snapshot", snapshotName, "target", target.name, "remote_snapshots", len(remoteSnapshots), "host", target.transport.Config().RemoteHost, "remote_dataset", target.transport.Config().RemoteDataset)
+		return nil
+	}
+
+	resumed, err := s.resumeInterruptedSend(dest)
+	if err != nil {
+		return fmt.Errorf("failed to resume interrupted send: %w", err)
+	}
+	if resumed {
+		// The resumed transfer moved the remote's newest snapshot forward
+		if remoteSnapshots, err = dest.ListRemoteSnapshots(); err != nil {
+			return fmt.Errorf("failed to list remote snapshots, aborting sync to prevent data loss: %w", err)
+		}
+	}
+
+	for _, remote := range remoteSnapshots {
+		if remote == snapshotName {
+			return nil // Already there, e.g. a resumed transfer of this snapshot
+		}
+	}
+
+	// Hold the snapshot being sent, and below its incremental base, so
+	// nothing can destroy them until the send is over
+	defer s.holdForSend(snapshotName)()
+
 	if len(remoteSnapshots) == 0 {
-		return s.sendFullSnapshot(snapshotName)
+		s.estimateSend(target, "", snapshotName)
+		return s.sendFullSnapshot(dest, snapshotName)
 	}
 
 	localSnapshots, err := s.zfsManager.ListSnapshots()
@@ -137,14 +787,149 @@ func (s *Scheduler) sendSnapshot(snapshotName string) error {
 		}
 	}
 
+	defer s.holdForSend(lastCommon)()
+
+	// A newer common point may survive only as a bookmark after retention pruned it
+	if bookmark := s.lastCommonBookmark(remoteSnapshots, lastCommon); bookmark != "" {
+		s.estimateSend(target, "#"+bookmark, snapshotName)
+		return s.sendIncrementalFromBookmark(dest, bookmark, snapshotName)
+	}
+
 	if lastCommon == "" {
-		return s.sendFullSnapshot(snapshotName)
+		s.estimateSend(target, "", snapshotName)
+		return s.sendFullSnapshot(dest, snapshotName)
+	}
+
+	s.estimateSend(target, lastCommon, snapshotName)
+	return s.sendIncrementalSnapshot(dest, lastCommon, snapshotName)
+}
+
+// lastCommonBookmark returns a local bookmark matching a remote snapshot newer
+// than lastCommon, or "" if there is none or bookmarks can't be used
+func (s *Scheduler) lastCommonBookmark(remoteSnapshots []string, lastCommon string) string {
+	if s.zfsManager.Recursive() {
+		return "" // zfs send -R can't start from a bookmark
+	}
+
+	bookmarks, err := s.zfsManager.ListBookmarks()
+	if err != nil || len(bookmarks) == 0 {
+		return ""
+	}
+
+	var best string
+	for _, remote := range remoteSnapshots {
+		if remote == lastCommon {
+			best = "" // The snapshot itself is at least as recent
+			continue
+		}
+		for _, bookmark := range bookmarks {
+			if bookmark == remote {
+				best = remote
+			}
+		}
+	}
+	return best
+}
+
+func (s *Scheduler) sendIncrementalFromBookmark(dest *transport.SSHTransport, bookmark, snapshotName string) error {
+	s.logger.Info("Sending incrementally from bookmark", "snapshot", snapshotName, "bookmark", bookmark)
+
+	sendCmd, err := s.zfsManager.SendIncrementalFromBookmark(bookmark, snapshotName)
+	if err != nil {
+		return err
+	}
+
+	stdout, err := sendCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := sendCmd.Start(); err != nil {
+		return err
+	}
+
+	if err := s.receiveStream(dest, stdout, true); err != nil {
+		sendCmd.Process.Kill()
+		return err
+	}
+
+	return sendCmd.Wait()
+}
+
+// resumeInterruptedSend finishes a transfer that was cut off mid-stream, so
+// the next send continues from where it stopped instead of starting over. It
+// reports whether a transfer was resumed.
+func (s *Scheduler) resumeInterruptedSend(dest *transport.SSHTransport) (bool, error) {
+	if !s.resumable() {
+		return false, nil
+	}
+
+	token, err := dest.RemoteResumeToken()
+	if err != nil {
+		s.logger.Warn("Failed to check for an interrupted send, sending normally", "err", err)
+		return false, nil
+	}
+	if token == "" {
+		return false, nil
+	}
+
+	if err := s.zfsManager.ValidateResumeToken(token); err != nil {
+		// Typically the snapshot being sent was pruned since, so the partial
+		// state can never complete and would block every later receive
+		s.logger.Warn("Discarding partial receive that can no longer be resumed", "err", err)
+		return false, dest.AbortPartialReceive()
+	}
+
+	s.logger.Info("Resuming interrupted send")
+
+	sendCmd, err := s.zfsManager.SendResume(token)
+	if err != nil {
+		return false, err
+	}
+
+	stdout, err := sendCmd.StdoutPipe()
+	if err != nil {
+		return false, err
+	}
+
+	if err := sendCmd.Start(); err != nil {
+		return false, err
+	}
+
+	if err := dest.SendSnapshotResumable(stdout); err != nil {
+		sendCmd.Process.Kill()
+		return false, err
+	}
+
+	if err := sendCmd.Wait(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// receiveStream pipes a send stream to dest. Non-recursive streams are
+// received resumably so an interrupted transfer can pick up where it stopped.
+func (s *Scheduler) receiveStream(dest *transport.SSHTransport, stream io.Reader, isIncremental bool) error {
+	if target := s.targetFor(dest); target != nil {
+		stream = &progressReader{reader: stream, onProgress: func(sent int64) {
+			target.sent.Store(sent)
+			s.publishSend(target, target.sending, events.PhaseProgress, nil)
+		}}
 	}
+	if !s.resumable() {
+		return dest.SendSnapshot(stream, isIncremental)
+	}
+	return dest.SendSnapshotResumable(stream)
+}
 
-	return s.sendIncrementalSnapshot(lastCommon, snapshotName)
+// resumable reports whether sends are received with -s. Recursive sends never
+// are, and the resumable_sends flag turns it off for sites whose remote
+// zfs is too old to support it.
+func (s *Scheduler) resumable() bool {
+	return !s.zfsManager.Recursive() && features.Enabled(s.Config().Features, features.ResumableSends)
 }
 
-func (s *Scheduler) sendFullSnapshot(snapshotName string) error {
+func (s *Scheduler) sendFullSnapshot(dest *transport.SSHTransport, snapshotName string) error {
 	sendCmd, err := s.zfsManager.SendSnapshot(snapshotName)
 	if err != nil {
 		return err
@@ -159,7 +944,7 @@ func (s *Scheduler) sendFullSnapshot(snapshotName string) error {
 		return err
 	}
 
-	if err := s.transport.SendSnapshot(stdout, false); err != nil {
+	if err := s.receiveStream(dest, stdout, false); err != nil {
 		sendCmd.Process.Kill()
 		return err
 	}
@@ -167,7 +952,7 @@ func (s *Scheduler) sendFullSnapshot(snapshotName string) error {
 	return sendCmd.Wait()
 }
 
-func (s *Scheduler) sendIncrementalSnapshot(fromSnapshot, toSnapshot string) error {
+func (s *Scheduler) sendIncrementalSnapshot(dest *transport.SSHTransport, fromSnapshot, toSnapshot string) error {
 	sendCmd, err := s.zfsManager.SendIncremental(fromSnapshot, toSnapshot)
 	if err != nil {
 		return err
@@ -182,7 +967,7 @@ func (s *Scheduler) sendIncrementalSnapshot(fromSnapshot, toSnapshot string) err
 		return err
 	}
 
-	if err := s.transport.SendSnapshot(stdout, true); err != nil {
+	if err := s.receiveStream(dest, stdout, true); err != nil {
 		sendCmd.Process.Kill()
 		return err
 	}
@@ -196,37 +981,259 @@ func (s *Scheduler) cleanupOldSnapshots() error {
 		return err
 	}
 
-	const maxSnapshots = 30
-	if len(snapshots) <= maxSnapshots {
-		return nil
+	cfg := s.Config()
+	keep := retention.FromConfig(&cfg.ZFS)
+	reason := fmt.Sprintf("retention policy (%s)", keep)
+
+	candidates := make([]retention.Snapshot, len(snapshots))
+	byName := make(map[string]zfs.Snapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		candidates[i] = retention.Snapshot{Name: snapshot.Name, Created: snapshot.Created}
+		byName[snapshot.Name] = snapshot
 	}
 
-	toDelete := snapshots[:len(snapshots)-maxSnapshots]
-	for _, snapshot := range toDelete {
+	bookmarked := s.bookmarkedSnapshots()
+	held := s.heldSnapshots()
+	needed := s.neededByLaggingTargets(snapshots)
+	for _, pruned := range keep.Prune(candidates) {
+		snapshot := byName[pruned.Name]
+		if held[snapshot.Name] && snapshot.Dataset == cfg.ZFS.Dataset {
+			s.logger.Info("Keeping held snapshot past retention", "snapshot", snapshot.Name)
+			continue
+		}
+		if target := needed[snapshot.Name]; target != "" {
+			s.logger.Info("Keeping snapshot past retention for a lagging target", "snapshot", snapshot.Name, "target", target)
+			continue
+		}
+		if utils.DefaultRunner.DryRun() {
+			s.logger.Info("Dry run: would destroy old snapshot", "snapshot", snapshot.Name, "reason", reason)
+			continue
+		}
+
+		var bookmark string
+		if bookmarked[snapshot.Name] && snapshot.Dataset == cfg.ZFS.Dataset {
+			bookmark = fmt.Sprintf("%s#%s", snapshot.Dataset, snapshot.Name)
+		} else if cfg.ZFS.BookmarkOnDestroy {
+			// Recursive snapshots are destroyed on every child, so each child is bookmarked
+			if err := s.zfsManager.CreateBookmarks(snapshot.Name); err != nil {
+				// Keep the snapshot rather than lose the incremental source
+				s.logger.Error("Failed to bookmark snapshot, keeping it", "snapshot", snapshot.Name, "err", err)
+				continue
+			}
+			bookmark = fmt.Sprintf("%s#%s", snapshot.Dataset, snapshot.Name)
+		}
+
 		if err := s.zfsManager.DestroySnapshot(snapshot.Name); err != nil {
-			log.Printf("Failed to delete old snapshot %s: %v", snapshot.Name, err)
-		} else {
-			log.Printf("Deleted old snapshot: %s", snapshot.Name)
+			s.logger.Error("Failed to delete old snapshot", "snapshot", snapshot.Name, "err", err)
+			continue
+		}
+
+		s.catalog.RecordDestroyed(catalog.Entry{
+			Dataset:  snapshot.Dataset,
+			Snapshot: snapshot.Name,
+			Bookmark: bookmark,
+			Created:  snapshot.Created,
+			Reason:   reason,
+		})
+		s.logger.Info("Deleted old snapshot", "snapshot", snapshot.Name)
+	}
+
+	if cfg.ZFS.PruneRemote {
+		for _, target := range s.targets {
+			if err := s.pruneRemoteSnapshots(target, keep); err != nil {
+				s.logger.Error("Failed to prune remote snapshots", "target", target.name, "err", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// neededByLaggingTargets maps the snapshots that targets with pending sends
+// still need to the first such target: the pending snapshots themselves and
+// the incremental base to send them from, which is the newest snapshot known
+// to be on the target or, when that isn't known, the newest one taken before
+// the oldest pending send. Callers hold sendMutex.
+func (s *Scheduler) neededByLaggingTargets(snapshots []zfs.Snapshot) map[string]string {
+	dataset := s.Config().ZFS.Dataset
+	var local []zfs.Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot.Dataset == dataset {
+			local = append(local, snapshot)
+		}
+	}
+	slices.SortStableFunc(local, func(a, b zfs.Snapshot) int {
+		return a.Created.Compare(b.Created)
+	})
+
+	needed := make(map[string]string)
+	need := func(name, target string) {
+		if _, ok := needed[name]; !ok {
+			needed[name] = target
+		}
+	}
+	for _, target := range s.targets {
+		if len(target.pending) == 0 {
+			continue
+		}
+		for _, name := range target.pending {
+			need(name, target.name)
+		}
+
+		s.lagMutex.Lock()
+		newest := target.newest
+		s.lagMutex.Unlock()
+		if newest != "" {
+			need(newest, target.name)
+			continue
+		}
+		oldest := slices.IndexFunc(local, func(snapshot zfs.Snapshot) bool {
+			return slices.Contains(target.pending, snapshot.Name)
+		})
+		if oldest > 0 {
+			need(local[oldest-1].Name, target.name)
+		}
+	}
+	return needed
+}
+
+// pruneRemoteSnapshots applies keep to a target's remote dataset. Only
+// snapshots zfsrabbit created are considered; anything else is left alone.
+func (s *Scheduler) pruneRemoteSnapshots(target *replicationTarget, keep retention.Policy) error {
+	names, err := target.transport.ListRemoteSnapshots()
+	if err != nil {
+		return err
+	}
+
+	var candidates []retention.Snapshot
+	for _, name := range names {
+		if created, ok := retention.SnapshotTime(name); ok {
+			candidates = append(candidates, retention.Snapshot{Name: name, Created: created})
 		}
 	}
 
+	pruned := keep.Prune(candidates)
+	if len(pruned) == 0 {
+		return nil
+	}
+
+	toDestroy := make([]string, len(pruned))
+	for i, snapshot := range pruned {
+		toDestroy[i] = snapshot.Name
+	}
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: would prune remote snapshots", "snapshots", toDestroy, "target", target.name, "host", target.transport.Config().RemoteHost, "remote_dataset", target.transport.Config().RemoteDataset)
+		return nil
+	}
+	if err := target.transport.DestroyRemoteSnapshots(toDestroy); err != nil {
+		return err
+	}
+
+	s.logger.Info("Pruned remote snapshots", "count", len(toDestroy), "target", target.name, "host", target.transport.Config().RemoteHost, "remote_dataset", target.transport.Config().RemoteDataset)
+	return nil
+}
+
+// tierOldSnapshots moves snapshots older than tiering.after_days off the
+// primary backup pool: each is written to the archive directory, recorded in
+// the catalog and only then destroyed on the pool. Streams are incremental
+// from the previously archived snapshot, which stays on the pool as the base
+// for the next one; a chain starts with a full stream whenever there is no
+// base left. The newest snapshot always stays, since the next incremental
+// send needs it.
+func (s *Scheduler) tierOldSnapshots() error {
+	if !s.tierMutex.TryLock() {
+		s.logger.Info("Skipping tiering, the previous run is still archiving")
+		return nil
+	}
+	defer s.tierMutex.Unlock()
+
+	primary := s.targets[0]
+	names, err := primary.transport.ListRemoteSnapshots()
+	if err != nil {
+		return err
+	}
+
+	var newest time.Time
+	created := make(map[string]time.Time)
+	for _, name := range names {
+		if t, ok := retention.SnapshotTime(name); ok {
+			created[name] = t
+			if t.After(newest) {
+				newest = t
+			}
+		}
+	}
+	slices.SortStableFunc(names, func(a, b string) int { return created[a].Compare(created[b]) })
+
+	cfg := s.Config()
+	cutoff := time.Now().AddDate(0, 0, -cfg.Tiering.AfterDays)
+	dataset := primary.transport.Config().RemoteDataset
+	var base string
+	for _, name := range names {
+		t, ok := created[name]
+		if !ok {
+			continue
+		}
+		if _, archived := s.catalog.LookupArchived(dataset, name); archived {
+			// Left on the pool by an earlier run as the next stream's base
+			base = name
+			continue
+		}
+		if !t.Before(cutoff) || t.Equal(newest) {
+			continue
+		}
+
+		location := filepath.Join(cfg.Tiering.ArchiveDir, dataset, name+".zfs")
+		if utils.DefaultRunner.DryRun() {
+			s.logger.Info("Dry run: would archive snapshot", "snapshot", dataset+"@"+name, "base", base, "location", location)
+			continue
+		}
+		size, err := primary.transport.ArchiveRemoteSnapshot(name, base, location)
+		if err != nil {
+			return err
+		}
+		s.catalog.RecordArchived(catalog.Archived{
+			Dataset:  dataset,
+			Snapshot: name,
+			Tier:     catalog.TierArchive,
+			Location: location,
+			Base:     base,
+			Size:     size,
+			Created:  t,
+		})
+		s.logger.Info("Archived snapshot", "snapshot", dataset+"@"+name, "base", base, "location", location, "bytes", size)
+
+		// The previous base is no longer needed on the pool
+		if base != "" {
+			if err := primary.transport.DestroyRemoteSnapshots([]string{base}); err != nil {
+				return err
+			}
+		}
+		base = name
+	}
+
 	return nil
 }
 
 func (s *Scheduler) performScrub() {
-	log.Println("Starting scheduled scrub")
+	s.logger.Info("Starting scheduled scrub")
+	started := time.Now()
 
 	pools, err := zfs.GetPools()
 	if err != nil {
-		log.Printf("Failed to get pools: %v", err)
+		s.logger.Error("Failed to get pools", "err", err)
+		s.recordRun(RunScrub, "all pools", "", started, err)
 		return
 	}
 
 	for _, pool := range pools {
-		log.Printf("Starting scrub for pool: %s", pool)
-		if err := zfs.ScrubPool(pool); err != nil {
-			log.Printf("Failed to start scrub for pool %s: %v", pool, err)
+		s.logger.Info("Starting scrub", "pool", pool)
+		err := zfs.ScrubPool(pool)
+		if err != nil {
+			s.logger.Error("Failed to start scrub", "pool", pool, "err", err)
 		}
+		// The run is the scrub being started; its result shows in the pool status
+		s.recordRun(RunScrub, pool, "started", started, err)
 	}
 }
 
@@ -248,61 +1255,214 @@ func (s *Scheduler) TriggerScrub() error {
 
 // performRetry runs on scheduled basis to retry failed snapshot sends
 func (s *Scheduler) performRetry() {
+	for _, job := range s.jobs {
+		job.performRetry()
+	}
+
 	s.sendMutex.Lock()
 	defer s.sendMutex.Unlock()
 	
-	if len(s.pendingSends) == 0 {
+	pending := s.pendingCount()
+	if pending == 0 {
 		return // Nothing to retry
 	}
-	
-	log.Printf("Scheduled retry: attempting to send %d pending snapshots", len(s.pendingSends))
+
+	if s.sendsDeferred() {
+		return
+	}
+
+	s.logger.Info("Scheduled retry of pending snapshots", "count", pending)
 	s.retryPendingSendsUnsafe()
 }
 
-// RetryPendingSends attempts to send any snapshots that failed to send previously (thread-safe)
+// RetryPendingSends attempts to send any snapshots of every job that failed to send previously (thread-safe)
 func (s *Scheduler) RetryPendingSends() error {
-	s.sendMutex.Lock()
-	defer s.sendMutex.Unlock()
-	
-	return s.retryPendingSendsUnsafe()
+	var errs []error
+	for _, job := range append([]*Scheduler{s}, s.jobs...) {
+		job.sendMutex.Lock()
+		if err := job.retryPendingSendsUnsafe(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", job.name, err))
+		}
+		job.sendMutex.Unlock()
+	}
+	return errors.Join(errs...)
 }
 
 // retryPendingSendsUnsafe does the actual retry work (assumes caller holds sendMutex)
 func (s *Scheduler) retryPendingSendsUnsafe() error {
-	if len(s.pendingSends) == 0 {
-		log.Printf("No pending snapshots to retry")
+	pending := s.pendingCount()
+	if pending == 0 {
+		s.logger.Debug("No pending snapshots to retry")
 		return nil
 	}
-	
-	log.Printf("Retrying %d pending snapshot sends", len(s.pendingSends))
-	
-	// Process pending sends
-	var stillPending []string
-	for _, snapshotName := range s.pendingSends {
-		log.Printf("Retrying send for snapshot: %s", snapshotName)
-		
-		if err := s.sendSnapshot(snapshotName); err != nil {
-			log.Printf("Retry failed for snapshot %s: %v", snapshotName, err)
-			stillPending = append(stillPending, snapshotName)
-		} else {
-			log.Printf("Successfully sent snapshot on retry: %s", snapshotName)
-			s.alerter.SendSyncSuccess(snapshotName, s.config.ZFS.Dataset, 0)
+
+	s.logger.Info("Retrying pending snapshot sends", "count", pending)
+
+	for _, target := range s.targets {
+		// Process this target's pending sends
+		var stillPending []string
+		for _, snapshotName := range target.pending {
+			s.logger.Info("Retrying send", "snapshot", snapshotName, "target", target.name)
+
+			if err := s.sendSnapshot(target, snapshotName); err != nil {
+				s.logger.Error("Retry failed", "snapshot", snapshotName, "target", target.name, "err", err)
+				stillPending = append(stillPending, snapshotName)
+			} else if utils.DefaultRunner.DryRun() {
+				// Nothing was sent, so the snapshot stays queued
+				stillPending = append(stillPending, snapshotName)
+			} else {
+				s.logger.Info("Sent snapshot on retry", "snapshot", snapshotName, "target", target.name)
+				s.sendSyncSuccess(snapshotName, s.Config().ZFS.Dataset, 0)
+			}
 		}
+
+		// Update pending list with only failed retries
+		s.statusMutex.Lock()
+		target.pending = stillPending
+		s.statusMutex.Unlock()
 	}
-	
-	// Update pending list with only failed retries
-	s.pendingSends = stillPending
-	
-	if len(s.pendingSends) > 0 {
-		log.Printf("%d snapshots still pending after retry", len(s.pendingSends))
-		return fmt.Errorf("%d snapshots still failed to send", len(s.pendingSends))
+	if utils.DefaultRunner.DryRun() {
+		return nil
 	}
-	
-	log.Printf("All pending snapshots successfully sent")
+	s.savePending()
+
+	if remaining := s.pendingCount(); remaining > 0 {
+		s.logger.Warn("Snapshot sends still pending after retry", "count", remaining)
+		return fmt.Errorf("%d snapshot sends still failed", remaining)
+	}
+
+	s.logger.Info("All pending snapshots sent")
+	s.recordSLASuccess()
 	return nil
 }
 
-// GetPendingSends returns the list of snapshots that failed to send
+// sendSyncSuccess notifies a completed sync. In dry-run mode nothing was
+// sent, so there is nothing to notify.
+func (s *Scheduler) sendSyncSuccess(snapshotName, dataset string, duration time.Duration) {
+	if utils.DefaultRunner.DryRun() {
+		s.logger.Info("Dry run: not notifying sync success", "snapshot", snapshotName, "dataset", dataset)
+		return
+	}
+	s.alerter.SendSyncSuccess(snapshotName, dataset, duration)
+}
+
+// recordSLASuccess tells the SLA tracker every target is now up to date
+func (s *Scheduler) recordSLASuccess() {
+	if s.slaTracker != nil && !utils.DefaultRunner.DryRun() {
+		s.slaTracker.RecordSuccess(s.Config().ZFS.Dataset, time.Now())
+	}
+}
+
+// pendingCount returns the number of queued sends across all targets
+func (s *Scheduler) pendingCount() int {
+	count := 0
+	for _, target := range s.targets {
+		count += len(target.pending)
+	}
+	return count
+}
+
+// GetPendingSends returns the snapshots that failed to send to at least one
+// target. Snapshots of jobs entries are qualified with their dataset, since
+// each job names its snapshots the same way.
 func (s *Scheduler) GetPendingSends() []string {
-	return s.pendingSends
+	pending := s.pendingSends("")
+	for _, job := range s.jobs {
+		pending = append(pending, job.pendingSends(job.Config().ZFS.Dataset+"@")...)
+	}
+	return pending
+}
+
+// pendingSends returns this job's snapshots still to send, each after prefix
+func (s *Scheduler) pendingSends(prefix string) []string {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	var pending []string
+	seen := make(map[string]bool)
+	for _, target := range s.targets {
+		for _, snapshot := range target.pending {
+			if !seen[snapshot] {
+				seen[snapshot] = true
+				pending = append(pending, prefix+snapshot)
+			}
+		}
+	}
+	return pending
+}
+
+// TriggerJob takes a snapshot for the named job now; "default" is the zfs section
+func (s *Scheduler) TriggerJob(name string) error {
+	job, err := s.job(name)
+	if err != nil {
+		return err
+	}
+	return job.TriggerSnapshot()
+}
+
+// Jobs returns the status of every replication job, the default one first
+func (s *Scheduler) Jobs() []JobStatus {
+	statuses := []JobStatus{s.jobStatus()}
+	for _, job := range s.jobs {
+		statuses = append(statuses, job.jobStatus())
+	}
+	return statuses
+}
+
+func (s *Scheduler) jobStatus() JobStatus {
+	cfg := s.Config()
+	status := JobStatus{
+		Name:     s.name,
+		Dataset:  cfg.ZFS.Dataset,
+		Schedule: cfg.Schedule.SnapshotCron,
+		Targets:  s.targetStatus(),
+	}
+	s.statusMutex.Lock()
+	if since := s.deferredSince; !since.IsZero() {
+		status.DeferredSince = &since
+	}
+	s.statusMutex.Unlock()
+	return status
+}
+
+// TargetStatus returns per-target replication state, primary first, then
+// the targets of each jobs entry
+func (s *Scheduler) TargetStatus() []TargetStatus {
+	statuses := s.targetStatus()
+	for _, job := range s.jobs {
+		statuses = append(statuses, job.targetStatus()...)
+	}
+	return statuses
+}
+
+// targetStatus returns the replication state of this job's own targets
+func (s *Scheduler) targetStatus() []TargetStatus {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+
+	statuses := make([]TargetStatus, 0, len(s.targets))
+	for _, target := range s.targets {
+		status := TargetStatus{
+			Name:      target.name,
+			Job:       s.name,
+			Host:      target.transport.Config().RemoteHost,
+			Dataset:   target.transport.Config().RemoteDataset,
+			Pending:   append([]string{}, target.pending...),
+			LastError: target.lastError,
+		}
+		if !target.lastSuccess.IsZero() {
+			lastSuccess := target.lastSuccess
+			status.LastSuccess = &lastSuccess
+		}
+		if target.sending != "" {
+			started := target.sendStarted
+			status.Sending = target.sending
+			status.SendStarted = &started
+			status.EstimatedBytes = target.estimate
+			status.EstimatedSeconds = int64(s.eta(target, target.estimate).Seconds())
+			status.SentBytes = target.sent.Load()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
 }
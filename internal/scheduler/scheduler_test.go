@@ -1,11 +1,28 @@
 package scheduler
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
 
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/sla"
+	"zfsrabbit/internal/throughput"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
 	"zfsrabbit/internal/zfs"
 	"zfsrabbit/test/mocks"
 )
@@ -28,6 +45,10 @@ func (m *MockZFSExecutor) Command(name string, args ...string) *exec.Cmd {
 	return exec.Command("echo", "mock")
 }
 
+func (m *MockZFSExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return m.Command(name, args...)
+}
+
 func (m *MockZFSExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
 	cmdStr := cmd.String()
 	if err, exists := m.errors[cmdStr]; exists {
@@ -82,7 +103,7 @@ func TestNewScheduler(t *testing.T) {
 		t.Fatal("Expected scheduler to be created")
 	}
 
-	if scheduler.config != cfg {
+	if scheduler.Config() != cfg {
 		t.Error("Config not set correctly")
 	}
 
@@ -346,3 +367,1224 @@ func TestPerformSnapshot_SkipIntegration(t *testing.T) {
 func TestPerformScrub_SkipIntegration(t *testing.T) {
 	t.Skip("Skipping scrub integration test - requires ZFS commands")
 }
+
+// recordingExecutor answers Output by full command line and records every Run
+type recordingExecutor struct {
+	outputs map[string]string
+	errors  map[string]error
+	runs    []string
+}
+
+func (r *recordingExecutor) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func (r *recordingExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+func (r *recordingExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
+	line := strings.Join(cmd.Args, " ")
+	return []byte(r.outputs[line]), r.errors[line]
+}
+
+func (r *recordingExecutor) Run(cmd *exec.Cmd) error {
+	r.runs = append(r.runs, strings.Join(cmd.Args, " "))
+	return nil
+}
+
+func TestCleanupBookmarksAndCatalogsDestroyedSnapshots(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{
+			Dataset:           "tank/test",
+			KeepSnapshots:     1,
+			BookmarkOnDestroy: true,
+		},
+	}
+
+	executor := &recordingExecutor{outputs: map[string]string{
+		"zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/test": "tank/test@snap1\tWed Jul 17 18:00 2024\t1M\t1M\n" +
+			"tank/test@snap2\tThu Jul 18 18:00 2024\t1M\t1M\n" +
+			"tank/test@snap3\tFri Jul 19 18:00 2024\t1M\t1M\n",
+	}}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	if err := scheduler.cleanupOldSnapshots(); err != nil {
+		t.Fatalf("cleanupOldSnapshots failed: %v", err)
+	}
+
+	expected := []string{
+		"zfs bookmark tank/test@snap1 tank/test#snap1",
+		"zfs destroy tank/test@snap1",
+		"zfs bookmark tank/test@snap2 tank/test#snap2",
+		"zfs destroy tank/test@snap2",
+	}
+	if strings.Join(executor.runs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(executor.runs, "\n"))
+	}
+
+	destroyed := scheduler.DestroyedSnapshots()
+	if len(destroyed) != 2 {
+		t.Fatalf("Expected 2 catalog entries, got %d", len(destroyed))
+	}
+	if destroyed[0].Bookmark != "tank/test#snap1" || destroyed[0].Reason != "retention policy (keep 1)" {
+		t.Errorf("Unexpected catalog entry: %+v", destroyed[0])
+	}
+}
+
+func TestCleanupBookmarksEveryChildWhenRecursive(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{
+			Dataset:           "tank/test",
+			Recursive:         true,
+			KeepSnapshots:     1,
+			BookmarkOnDestroy: true,
+		},
+	}
+
+	executor := &recordingExecutor{outputs: map[string]string{
+		"zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/test": "tank/test@snap1\tWed Jul 17 18:00 2024\t1M\t1M\n" +
+			"tank/test@snap2\tThu Jul 18 18:00 2024\t1M\t1M\n",
+		// The child was bookmarked by an earlier run that failed to destroy snap1
+		"zfs list -H -o name -t snapshot,bookmark -r tank/test": "tank/test@snap1\ntank/test@snap2\n" +
+			"tank/test/child@snap1\ntank/test/child#snap1\ntank/test/child@snap2\ntank/test/child/grand@snap1\n",
+	}}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", true, executor)
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	if err := scheduler.cleanupOldSnapshots(); err != nil {
+		t.Fatalf("cleanupOldSnapshots failed: %v", err)
+	}
+
+	expected := []string{
+		"zfs bookmark tank/test@snap1 tank/test#snap1",
+		"zfs bookmark tank/test/child/grand@snap1 tank/test/child/grand#snap1",
+		"zfs destroy -r tank/test@snap1",
+	}
+	if strings.Join(executor.runs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(executor.runs, "\n"))
+	}
+	if destroyed := scheduler.DestroyedSnapshots(); len(destroyed) != 1 || destroyed[0].Bookmark != "tank/test#snap1" {
+		t.Errorf("Unexpected catalog entries: %+v", destroyed)
+	}
+}
+
+func TestCleanupKeepsWhatLaggingTargetsNeed(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 1},
+		SSH: config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+		Remotes: []config.RemoteConfig{
+			{Name: "offsite", SSHConfig: config.SSHConfig{RemoteHost: "offsite.test.invalid", RemoteDataset: "vault/test"}},
+		},
+	}
+
+	listing := ""
+	for day := 15; day <= 19; day++ {
+		listing += fmt.Sprintf("tank/test@snap%d\t%s\t1M\t1M\n", day-14, time.Date(2024, 7, day, 18, 0, 0, 0, time.Local).Format("Mon Jan 2 15:04 2006"))
+	}
+	list := "zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/test"
+
+	for _, tt := range []struct {
+		newest string
+		kept   string
+	}{
+		// Unknown, so the base is the snapshot before the oldest pending one
+		{"", "snap3"},
+		{"snap2", "snap2"},
+	} {
+		executor := &recordingExecutor{outputs: map[string]string{list: listing}}
+		zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+		scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+		offsite := scheduler.targets[1]
+		offsite.pending = []string{"snap4"}
+		offsite.newest = tt.newest
+
+		if err := scheduler.cleanupOldSnapshots(); err != nil {
+			t.Fatalf("cleanupOldSnapshots failed: %v", err)
+		}
+
+		var destroyed []string
+		for _, name := range []string{"snap1", "snap2", "snap3"} {
+			if name != tt.kept {
+				destroyed = append(destroyed, "zfs destroy tank/test@"+name)
+			}
+		}
+		if !slices.Equal(executor.runs, destroyed) {
+			t.Errorf("newest %q: expected the offsite base %s and pending snap4 kept, got:\n%s", tt.newest, tt.kept, strings.Join(executor.runs, "\n"))
+		}
+	}
+}
+
+func TestObjectStoreFeatureFlag(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		enabled  bool
+		features map[string]bool
+		want     bool
+	}{
+		{"s3 disabled", false, map[string]bool{"object_store_target": true}, false},
+		{"flag off", true, nil, false},
+		{"flag on", true, map[string]bool{"object_store_target": true}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				ZFS:      config.ZFSConfig{Dataset: "tank/test"},
+				Features: tc.features,
+				S3: config.S3Config{
+					Enabled:     tc.enabled,
+					Endpoint:    "http://127.0.0.1:9000",
+					Bucket:      "zfs-backups",
+					AccessKey:   "access",
+					SecretKey:   "secret",
+					ChunkSize:   "64M",
+					Compression: "gzip",
+				},
+			}
+			zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, &recordingExecutor{})
+			scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+			if got := scheduler.objectStore != nil; got != tc.want {
+				t.Errorf("Expected object storage enabled %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLastCommonBookmark(t *testing.T) {
+	cfg := &config.Config{ZFS: config.ZFSConfig{Dataset: "tank/test"}}
+	executor := &recordingExecutor{outputs: map[string]string{
+		"zfs list -t bookmark -H -o name -d 1 tank/test": "tank/test#snap1\ntank/test#snap2\n",
+	}}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	remote := []string{"snap1", "snap2", "snap3"}
+
+	if got := scheduler.lastCommonBookmark(remote, ""); got != "snap2" {
+		t.Errorf("Expected newest bookmark snap2 with no common snapshot, got %q", got)
+	}
+	if got := scheduler.lastCommonBookmark(remote, "snap3"); got != "" {
+		t.Errorf("Expected common snapshot newer than bookmarks to win, got %q", got)
+	}
+
+	recursive := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", true, executor)
+	scheduler = New(cfg, recursive, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	if got := scheduler.lastCommonBookmark(remote, ""); got != "" {
+		t.Errorf("Expected bookmarks to be ignored for recursive sends, got %q", got)
+	}
+}
+
+func TestBookmarkSentSnapshot(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{
+			Dataset:        "tank/test",
+			KeepSnapshots:  1,
+			BookmarkOnSend: true,
+			KeepBookmarks:  2,
+		},
+	}
+
+	executor := &recordingExecutor{outputs: map[string]string{
+		"zfs list -t bookmark -H -o name -d 1 tank/test": "tank/test#autosnap_2024-07-19_18-00-00\n" +
+			"tank/test#manual\n" +
+			"tank/test#autosnap_2024-07-17_18-00-00\n" +
+			"tank/test#autosnap_2024-07-18_18-00-00\n",
+		"zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/test": "tank/test@autosnap_2024-07-18_18-00-00\tThu Jul 18 18:00 2024\t1M\t1M\n" +
+			"tank/test@autosnap_2024-07-19_18-00-00\tFri Jul 19 18:00 2024\t1M\t1M\n",
+	}}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	scheduler.bookmarkSentSnapshot("autosnap_2024-07-19_18-00-00")
+	if err := scheduler.cleanupOldSnapshots(); err != nil {
+		t.Fatalf("cleanupOldSnapshots failed: %v", err)
+	}
+
+	// The pruned snapshot already has a bookmark, so it isn't made again
+	expected := []string{
+		"zfs bookmark tank/test@autosnap_2024-07-19_18-00-00 tank/test#autosnap_2024-07-19_18-00-00",
+		"zfs destroy tank/test#autosnap_2024-07-17_18-00-00",
+		"zfs destroy tank/test@autosnap_2024-07-18_18-00-00",
+	}
+	if strings.Join(executor.runs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(executor.runs, "\n"))
+	}
+
+	destroyed := scheduler.DestroyedSnapshots()
+	if len(destroyed) != 1 || destroyed[0].Bookmark != "tank/test#autosnap_2024-07-18_18-00-00" {
+		t.Errorf("Expected the catalog to record the existing bookmark, got %+v", destroyed)
+	}
+}
+
+func TestDatasetRename(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("zfs:\n  dataset: tank/old # Replicated\nsla:\n  - dataset: tank/old/db\n    max_age: 2h\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Path: path, ZFS: config.ZFSConfig{Dataset: "tank/old"}}
+
+	executor := &recordingExecutor{
+		outputs: map[string]string{
+			"zfs get -H -o value guid tank/old":             "1234\n",
+			"zfs list -H -o name,guid -t filesystem,volume": "tank/other\t99\ntank/new\t1234\n",
+		},
+		errors: map[string]error{},
+	}
+	alerter := mocks.NewMockAlerter()
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), alerter)
+
+	if err := scheduler.checkDatasetRename(); err != nil {
+		t.Fatalf("Expected no rename while the dataset exists, got %v", err)
+	}
+
+	executor.errors["zfs get -H -o value guid tank/old"] = fmt.Errorf("dataset does not exist")
+	for range 2 {
+		if err := scheduler.checkDatasetRename(); err == nil {
+			t.Fatal("Expected replication to pause until the rename is accepted")
+		}
+	}
+	if len(alerter.SentAlerts) != 1 || !strings.Contains(alerter.SentAlerts[0].Body, "New name: tank/new") {
+		t.Errorf("Expected one rename alert, got %+v", alerter.SentAlerts)
+	}
+	renames := scheduler.Renames()
+	if len(renames) != 1 || renames[0].From != "tank/old" || renames[0].To != "tank/new" {
+		t.Fatalf("Unexpected renames: %+v", renames)
+	}
+
+	if _, err := scheduler.AcceptRename("tank/old"); err != nil {
+		t.Fatalf("AcceptRename failed: %v", err)
+	}
+	if dataset := scheduler.Config().ZFS.Dataset; dataset != "tank/new" || len(scheduler.Renames()) != 0 {
+		t.Errorf("Expected the job to follow the rename, dataset is %s", dataset)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "dataset: tank/new # Replicated") || !strings.Contains(string(data), "dataset: tank/new/db") {
+		t.Errorf("Expected the config file to use the new name, got:\n%s", data)
+	}
+	if _, err := os.Stat(path + ".bak"); err != nil {
+		t.Errorf("Expected the previous config to be kept: %v", err)
+	}
+}
+
+func TestSendHolds(t *testing.T) {
+	cfg := &config.Config{ZFS: config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 1}}
+	executor := &recordingExecutor{outputs: map[string]string{
+		"zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/test": "tank/test@snap1\tWed Jul 17 18:00 2024\t1M\t1M\n" +
+			"tank/test@snap2\tThu Jul 18 18:00 2024\t1M\t1M\n" +
+			"tank/test@snap3\tFri Jul 19 18:00 2024\t1M\t1M\n",
+		"zfs list -t snapshot -H -o name,userrefs -d 1 tank/test": "tank/test@snap1\t1\ntank/test@snap2\t0\ntank/test@snap3\t0\n",
+		"zfs holds -H -p tank/test@snap1":                         "tank/test@snap1\tzfsrabbit-send\t1721239200\n",
+	}}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	release := scheduler.holdForSend("snap2", "", "snap3")
+	release()
+
+	// A held snapshot outlives retention
+	if err := scheduler.cleanupOldSnapshots(); err != nil {
+		t.Fatalf("cleanupOldSnapshots failed: %v", err)
+	}
+
+	// Leftover send holds are released on restart
+	scheduler.releaseStaleHolds()
+
+	expected := []string{
+		"zfs hold zfsrabbit-send tank/test@snap2",
+		"zfs hold zfsrabbit-send tank/test@snap3",
+		"zfs release zfsrabbit-send tank/test@snap2",
+		"zfs release zfsrabbit-send tank/test@snap3",
+		"zfs destroy tank/test@snap2",
+		"zfs release zfsrabbit-send tank/test@snap1",
+	}
+	if strings.Join(executor.runs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(executor.runs, "\n"))
+	}
+}
+
+func TestCheckLag(t *testing.T) {
+	cfg := &config.Config{ZFS: config.ZFSConfig{Dataset: "tank/data", MaxLag: 24 * time.Hour}}
+	alerter := mocks.NewMockAlerter()
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, &recordingExecutor{})
+	// Without a private key the target can't be listed, so lag is measured
+	// from the newest snapshot known to have been sent
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), alerter)
+	target := scheduler.targets[0]
+
+	now := time.Now()
+	scheduler.noteRemoteSnapshot(target, "autosnap_"+now.Add(-26*time.Hour).Format("2006-01-02_15-04-05"))
+	scheduler.noteRemoteSnapshot(target, "manual")
+	for range 2 {
+		lags := scheduler.checkLag(now)
+		if len(lags) != 1 || !lags[0].Lagging || lags[0].Error == "" || lags[0].LagSeconds < 26*3600 {
+			t.Fatalf("Expected the target to lag, got %+v", lags)
+		}
+	}
+	if len(alerter.SentAlerts) != 1 || !strings.Contains(alerter.SentAlerts[0].Body, "tank/data has not replicated to primary") ||
+		!strings.Contains(alerter.SentAlerts[0].Body, "in 26h") {
+		t.Fatalf("Expected one lag alert, got %+v", alerter.SentAlerts)
+	}
+
+	newest := "autosnap_" + now.Add(-time.Hour).Format("2006-01-02_15-04-05")
+	scheduler.noteRemoteSnapshot(target, newest)
+	lags := scheduler.checkLag(now)
+	if lags[0].Lagging || lags[0].Newest != newest {
+		t.Errorf("Expected the target to have caught up, got %+v", lags)
+	}
+
+	// Lagging again is alerted on again
+	scheduler.checkLag(now.Add(48 * time.Hour))
+	if len(alerter.SentAlerts) != 2 {
+		t.Errorf("Expected a second lag alert, got %d", len(alerter.SentAlerts))
+	}
+}
+
+func TestSnapshotHooks(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "hooks.log")
+	hook := func(name, script, onFailure string) config.SnapshotHook {
+		return config.SnapshotHook{
+			DrillHook: config.DrillHook{Name: name, Command: "/bin/sh", Args: []string{"-c", "{ " + script + "; } >> " + log}},
+			OnFailure: onFailure,
+		}
+	}
+	cfg := &config.Config{ZFS: config.ZFSConfig{
+		Dataset: "tank/test",
+		PreSnapshot: []config.SnapshotHook{
+			hook("flush", "echo flush $ZFSRABBIT_SNAPSHOT_DATASET@$ZFSRABBIT_SNAPSHOT_NAME; false", config.HookContinue),
+			hook("lock", "echo lock; false", ""),
+			hook("never", "echo never", ""),
+		},
+		PostSnapshot: []config.SnapshotHook{hook("unlock", "echo unlock $ZFSRABBIT_SNAPSHOT_STATUS", "")},
+	}}
+	alerter := mocks.NewMockAlerter()
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, &recordingExecutor{})
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), alerter)
+
+	err := scheduler.runPreSnapshotHooks("snap1")
+	if err == nil || !strings.Contains(err.Error(), "pre_snapshot hook lock failed") {
+		t.Fatalf("Expected the lock hook to abort the snapshot, got %v", err)
+	}
+	scheduler.runPostSnapshotHooks("snap1", snapshotSkipped)
+
+	data, _ := os.ReadFile(log)
+	if want := "flush tank/test@snap1\nlock\nunlock skipped\n"; string(data) != want {
+		t.Errorf("Expected hooks to run as\n%s\ngot:\n%s", want, data)
+	}
+	if len(alerter.SentAlerts) != 1 || !strings.Contains(alerter.SentAlerts[0].Body, "flush failed") {
+		t.Errorf("Expected an alert for the hook that continued, got %+v", alerter.SentAlerts)
+	}
+}
+
+func TestEstimateSend(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/test"},
+		SSH: config.SSHConfig{RemoteHost: "backup", MaxSendRate: "1M"},
+	}
+	executor := &recordingExecutor{outputs: map[string]string{
+		"zfs send -nP -i tank/test@snap1 tank/test@snap2": "incremental\tsnap1\ttank/test@snap2\t10485760\nsize\t10485760\n",
+	}}
+	alerter := mocks.NewMockAlerter()
+	scheduler := New(cfg, zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor), transport.NewSSHTransport(&cfg.SSH), alerter)
+	target := scheduler.targets[0]
+
+	target.sending = "snap2"
+	scheduler.estimateSend(target, "snap1", "snap2")
+	if target.estimate != 10485760 {
+		t.Fatalf("Expected an estimate of 10485760 bytes, got %d", target.estimate)
+	}
+	if len(alerter.SyncStarts) != 1 || alerter.SyncStarts[0].ETA != 10*time.Second {
+		t.Errorf("Expected a sync start at the 1M rate limit (10s), got %+v", alerter.SyncStarts)
+	}
+
+	// Measured throughput wins over the rate limit
+	scheduler.Throughput().Record(cfg.SSH.RemoteHost, throughput.Send, 20*1024*1024, 10*time.Second)
+	status := scheduler.TargetStatus()[0]
+	if status.Sending != "snap2" || status.EstimatedBytes != 10485760 || status.EstimatedSeconds != 5 {
+		t.Errorf("Unexpected target status: %+v", status)
+	}
+
+	// A failed dry run only loses the estimate
+	scheduler.estimateSend(target, "", "missing")
+	if target.estimate != 10485760 || len(alerter.SyncStarts) != 1 {
+		t.Errorf("Expected a failed estimate to be skipped, got %d bytes and %d notifications", target.estimate, len(alerter.SyncStarts))
+	}
+}
+
+func TestPlanReplacement(t *testing.T) {
+	remote := []string{"snap1", "snap2", "snap3", "snap4"}
+	local := map[string]bool{"snap2": true, "snap3": true, "snap4": true}
+
+	job := &ResendJob{Snapshot: "snap3"}
+	planReplacement(job, remote, local)
+	if job.Base != "snap2" || strings.Join(job.Replace, ",") != "snap3,snap4" || len(job.Blockers) != 0 {
+		t.Errorf("Expected incremental re-send of snap3,snap4 from snap2, got %+v", job)
+	}
+
+	// snap1 is gone locally, so a full re-send would lose it from the backup
+	job = &ResendJob{Snapshot: "snap2"}
+	planReplacement(job, remote, local)
+	if job.Base != "" || len(job.Replace) != 4 || len(job.Blockers) != 1 {
+		t.Errorf("Expected blocked full re-send, got %+v", job)
+	}
+
+	job = &ResendJob{Snapshot: "missing"}
+	planReplacement(job, remote, local)
+	if len(job.Replace) != 0 || len(job.Blockers) != 1 {
+		t.Errorf("Expected blocker for snapshot missing on backup server, got %+v", job)
+	}
+}
+
+func TestPlanResendRequiresFlag(t *testing.T) {
+	cfg := &config.Config{ZFS: config.ZFSConfig{Dataset: "tank/test"}}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, &recordingExecutor{})
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	if _, err := scheduler.PlanResend("tank/test", "snap1"); err == nil {
+		t.Error("Expected error planning a re-send for an unflagged snapshot")
+	}
+	if err := scheduler.ConfirmResend("resend_missing"); err == nil {
+		t.Error("Expected error confirming an unknown re-send")
+	}
+}
+
+func TestFanOutTracksTargetsIndependently(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH: config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+		Remotes: []config.RemoteConfig{
+			{Name: "offsite", SSHConfig: config.SSHConfig{RemoteHost: "offsite.test.invalid", RemoteDataset: "vault/test"}},
+		},
+	}
+
+	// No private key is configured, so every send fails before dialling
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	alerter := mocks.NewMockAlerter()
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), alerter)
+
+	// Status reads run alongside the sends, as from the web server
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			scheduler.TargetStatus()
+			scheduler.GetPendingSends()
+			scheduler.Health()
+		}
+	}()
+	scheduler.performSnapshot()
+	<-done
+
+	statuses := scheduler.TargetStatus()
+	if len(statuses) != 2 || statuses[0].Name != "primary" || statuses[1].Name != "offsite" {
+		t.Fatalf("Expected primary and offsite targets, got %+v", statuses)
+	}
+	for _, status := range statuses {
+		if len(status.Pending) != 1 || status.LastError == "" {
+			t.Errorf("Expected one pending send and an error for %s, got %+v", status.Name, status)
+		}
+	}
+	if alerter.GetSyncFailureCount() != 2 {
+		t.Errorf("Expected a failure alert per target, got %d", alerter.GetSyncFailureCount())
+	}
+	if !strings.Contains(alerter.SyncFailures[1].Error.Error(), "offsite") {
+		t.Errorf("Expected failure to name the target, got %v", alerter.SyncFailures[1].Error)
+	}
+	if pending := scheduler.GetPendingSends(); len(pending) != 1 {
+		t.Errorf("Expected the snapshot listed once across targets, got %v", pending)
+	}
+
+	// Clearing one target's queue leaves the other's alone
+	scheduler.targets[1].pending = nil
+	if err := scheduler.RetryPendingSends(); err == nil {
+		t.Error("Expected retry to fail while the primary is unreachable")
+	}
+	if len(scheduler.targets[0].pending) != 1 || len(scheduler.targets[1].pending) != 0 {
+		t.Errorf("Expected only the primary to stay pending, got %v / %v", scheduler.targets[0].pending, scheduler.targets[1].pending)
+	}
+}
+
+func TestBusyReason(t *testing.T) {
+	deferral := config.IdleDeferralConfig{Enabled: true, MaxBandwidth: "100M", MaxLatency: 20 * time.Millisecond}
+
+	tests := []struct {
+		stat zfs.PoolIOStat
+		busy string
+	}{
+		{zfs.PoolIOStat{Pool: "tank", ReadBytes: 30 << 20, WriteBytes: 40 << 20, WriteWait: 5 * time.Millisecond}, ""},
+		{zfs.PoolIOStat{Pool: "tank", ReadBytes: 60 << 20, WriteBytes: 50 << 20}, "tank is moving"},
+		{zfs.PoolIOStat{Pool: "tank", ReadWait: 35 * time.Millisecond}, "tank IO waits 35ms"},
+	}
+	for _, tt := range tests {
+		reason := busyReason(&tt.stat, deferral)
+		if tt.busy == "" && reason != "" || !strings.Contains(reason, tt.busy) {
+			t.Errorf("busyReason(%+v) = %q, want %q", tt.stat, reason, tt.busy)
+		}
+	}
+
+	// A threshold left unset never makes the pool busy
+	if reason := busyReason(&zfs.PoolIOStat{ReadBytes: 1 << 40}, config.IdleDeferralConfig{MaxLatency: time.Second}); reason != "" {
+		t.Errorf("Expected bandwidth to be ignored without max_bandwidth, got %q", reason)
+	}
+}
+
+func TestDeferSendQueuesForEveryTarget(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/test"},
+		SSH: config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+		Remotes: []config.RemoteConfig{
+			{Name: "offsite", SSHConfig: config.SSHConfig{RemoteHost: "offsite.test.invalid", RemoteDataset: "vault/test"}},
+		},
+	}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	alerter := mocks.NewMockAlerter()
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), alerter)
+
+	scheduler.deferSend("autosnap_deferred", time.Now())
+
+	for _, status := range scheduler.TargetStatus() {
+		if !slices.Equal(status.Pending, []string{"autosnap_deferred"}) || status.LastError != "" {
+			t.Errorf("Expected %s to queue the snapshot without an error, got %+v", status.Name, status)
+		}
+	}
+	if alerter.GetSyncFailureCount() != 0 {
+		t.Errorf("Expected no failure alerts for a deferred send, got %d", alerter.GetSyncFailureCount())
+	}
+}
+
+func TestJobsRunIndependently(t *testing.T) {
+	cfg := &config.Config{
+		ZFS:      config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH:      config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+		Schedule: config.ScheduleConfig{SnapshotCron: "0 2 * * *", MaxConcurrentJobs: 1},
+		Remotes: []config.RemoteConfig{
+			{Name: "offsite", SSHConfig: config.SSHConfig{RemoteHost: "offsite.test.invalid", RemoteDataset: "vault/test"}},
+		},
+		Jobs: []config.JobConfig{{
+			Name:         "media",
+			ZFSConfig:    config.ZFSConfig{Dataset: "tank/media", KeepSnapshots: 7},
+			SnapshotCron: "0 */6 * * *",
+			Target:       config.SSHConfig{RemoteHost: "media.test.invalid", RemoteDataset: "backup/media"},
+		}},
+	}
+
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	jobs := scheduler.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("Expected the default job and media, got %+v", jobs)
+	}
+	if jobs[0].Name != "default" || jobs[0].Dataset != "tank/test" || len(jobs[0].Targets) != 2 {
+		t.Errorf("Unexpected default job: %+v", jobs[0])
+	}
+	media := jobs[1]
+	if media.Name != "media" || media.Dataset != "tank/media" || media.Schedule != "0 */6 * * *" {
+		t.Errorf("Unexpected media job: %+v", media)
+	}
+	if len(media.Targets) != 1 || media.Targets[0].Host != "media.test.invalid" {
+		t.Errorf("Expected media to replicate only to its own target, got %+v", media.Targets)
+	}
+
+	child := scheduler.jobs[0]
+	if child.catalog != scheduler.catalog || child.workers != scheduler.workers {
+		t.Error("Expected jobs to share the catalog and worker pool")
+	}
+	if cfg.ZFS.Dataset != "tank/test" || len(cfg.Remotes) != 1 {
+		t.Error("Building a job must not modify the shared config")
+	}
+
+	if err := scheduler.TriggerJob("missing"); err == nil {
+		t.Error("Expected unknown job to fail")
+	}
+
+	// The status report covers every job's targets
+	scheduler.targets[1].pending = []string{"autosnap_2024-03-01_02-00-00"}
+	child.targets[0].pending = []string{"autosnap_2024-03-01_02-00-00"}
+	statuses := scheduler.TargetStatus()
+	if len(statuses) != 3 || statuses[2].Job != "media" || statuses[2].Host != "media.test.invalid" {
+		t.Errorf("Expected the media job's target after the default job's, got %+v", statuses)
+	}
+	pending := scheduler.GetPendingSends()
+	if !slices.Equal(pending, []string{"autosnap_2024-03-01_02-00-00", "tank/media@autosnap_2024-03-01_02-00-00"}) {
+		t.Errorf("Expected pending sends of both jobs, got %v", pending)
+	}
+}
+
+func TestApplyPolicyReachesJobs(t *testing.T) {
+	cfg := &config.Config{
+		ZFS:      config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH:      config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteUser: "root", RemoteDataset: "backup/test"},
+		Schedule: config.ScheduleConfig{SnapshotCron: "0 2 * * *", ScrubCron: "0 3 * * 0", MaxConcurrentJobs: 1},
+		Jobs: []config.JobConfig{{
+			Name:         "media",
+			ZFSConfig:    config.ZFSConfig{Dataset: "tank/media", KeepSnapshots: 7},
+			SnapshotCron: "0 */6 * * *",
+			Target:       config.SSHConfig{RemoteHost: "media.test.invalid", RemoteUser: "root", RemoteDataset: "backup/media"},
+		}},
+	}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	set := scheduler.Policy()
+	if len(set.Policies) != 2 || set.Policies[1].Name != "media" {
+		t.Fatalf("Expected policies for the default job and media, got %+v", set.Policies)
+	}
+	set.Policies[1].Retention.KeepSnapshots = 14
+	set.Policies[1].Schedule.Snapshot = "0 */4 * * *"
+	set.Policies[1].Target.Host = "media2.test.invalid"
+
+	// Status reads run alongside, as from the web server
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			scheduler.Jobs()
+			scheduler.Upcoming(time.Now(), time.Now().Add(24*time.Hour))
+		}
+	}()
+	changed, err := scheduler.ApplyPolicy(set)
+	<-done
+	if err != nil || !changed {
+		t.Fatalf("Expected the job's policy to apply, got changed=%v err=%v", changed, err)
+	}
+
+	media := scheduler.jobs[0]
+	if keep := media.Config().ZFS.KeepSnapshots; keep != 14 {
+		t.Errorf("Expected media to keep 14 snapshots, got %d", keep)
+	}
+	if jobs := scheduler.Jobs(); jobs[1].Schedule != "0 */4 * * *" || jobs[1].Targets[0].Host != "media2.test.invalid" {
+		t.Errorf("Expected media's schedule and target to follow its policy, got %+v", jobs[1])
+	}
+	if media.transport.RemoteHost() != "media2.test.invalid" {
+		t.Errorf("Expected media's transport to be reconfigured, got %s", media.transport.RemoteHost())
+	}
+	if got := scheduler.Policy().Policies[1]; got != set.Policies[1] {
+		t.Errorf("Expected the applied policy back, got %+v", got)
+	}
+	if cfg.Jobs[0].KeepSnapshots != 7 || scheduler.Config().ZFS.KeepSnapshots != 30 {
+		t.Error("Applying a job's policy must not modify the loaded config or the default job")
+	}
+
+	set.Policies[1].Name = "photos"
+	if _, err := scheduler.ApplyPolicy(set); err == nil {
+		t.Error("Expected a policy naming no jobs entry to be rejected")
+	}
+}
+
+func TestRunScheduledWaitsForWorker(t *testing.T) {
+	cfg := &config.Config{
+		ZFS:      config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		Schedule: config.ScheduleConfig{MaxConcurrentJobs: 1},
+	}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	// Hold the only slot, then stop: the scheduled run gives up without
+	// snapshotting
+	scheduler.workers <- struct{}{}
+	scheduler.cancel()
+	scheduler.runScheduled()
+
+	if statuses := scheduler.TargetStatus(); len(statuses[0].Pending) != 0 {
+		t.Errorf("Expected no snapshot while every worker is busy, got %+v", statuses[0])
+	}
+
+	// Manual triggers wait for a slot too
+	scheduler.performSnapshot()
+	if statuses := scheduler.TargetStatus(); len(statuses[0].Pending) != 0 {
+		t.Errorf("Expected no manual snapshot while every worker is busy, got %+v", statuses[0])
+	}
+}
+
+func TestPendingSendsSurviveRestart(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{StateDir: t.TempDir()},
+		ZFS:    config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH:    config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+		Remotes: []config.RemoteConfig{
+			{Name: "offsite", SSHConfig: config.SSHConfig{RemoteHost: "offsite.test.invalid", RemoteDataset: "vault/test"}},
+		},
+	}
+
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	scheduler.performSnapshot()
+
+	restarted := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	for i, target := range restarted.targets {
+		if len(target.pending) != 1 || target.pending[0] != scheduler.targets[i].pending[0] {
+			t.Errorf("Expected %s's failed send to be restored, got %v", target.name, target.pending)
+		}
+	}
+
+	// A target that is no longer configured loses its queue
+	cfg.Remotes = nil
+	New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	cfg.Remotes = []config.RemoteConfig{
+		{Name: "offsite", SSHConfig: config.SSHConfig{RemoteHost: "offsite.test.invalid", RemoteDataset: "vault/test"}},
+	}
+	readded := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	if len(readded.targets[0].pending) != 1 || len(readded.targets[1].pending) != 0 {
+		t.Errorf("Expected only the primary queue to remain, got %v / %v", readded.targets[0].pending, readded.targets[1].pending)
+	}
+
+	// A successful retry clears the stored queue
+	readded.targets[0].pending = nil
+	readded.savePending()
+	if again := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter()); again.pendingCount() != 0 {
+		t.Errorf("Expected no pending sends after the queue was cleared, got %d", again.pendingCount())
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	kept, delivered := dropDelivered([]string{"snap1", "snap2", "snap3"}, []string{"snap0", "snap1", "snap3"})
+	if !slices.Equal(kept, []string{"snap2"}) || !slices.Equal(delivered, []string{"snap1", "snap3"}) {
+		t.Errorf("Expected snap1 and snap3 to be delivered, got kept %v delivered %v", kept, delivered)
+	}
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{StateDir: t.TempDir()},
+		ZFS:    config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH:    config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+	}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	scheduler.targets[0].pending = []string{"snap1"}
+
+	// An unreachable remote can't confirm delivery, so the queue is kept
+	scheduler.Reconcile()
+	restarted := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	if !slices.Equal(restarted.targets[0].pending, []string{"snap1"}) {
+		t.Errorf("Expected the queue to be kept, got %v", restarted.targets[0].pending)
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	cfg := &config.Config{
+		Server:   config.ServerConfig{StateDir: t.TempDir()},
+		ZFS:      config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH:      config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+		Schedule: config.ScheduleConfig{SnapshotCron: "0 2 * * *", ScrubCron: "0 3 * * 0"},
+		Jobs: []config.JobConfig{
+			{Name: "home", ZFSConfig: config.ZFSConfig{Dataset: "tank/home"}, SnapshotCron: "30 1 * * *"},
+		},
+	}
+
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	started := time.Now()
+	scheduler.performSnapshot()
+
+	restarted := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	runs := restarted.History(started.Add(-time.Minute), time.Now().Add(time.Minute))
+	if len(runs) != 1 || runs[0].Kind != RunSnapshot || runs[0].Job != "default" || runs[0].Success ||
+		!strings.Contains(runs[0].Error, "1 of 1 targets") || !strings.HasPrefix(runs[0].Detail, "autosnap_") {
+		t.Fatalf("Expected the failed snapshot run to be kept across a restart, got %+v", runs)
+	}
+	if runs := restarted.History(started.Add(time.Hour), started.Add(2*time.Hour)); len(runs) != 0 {
+		t.Errorf("Expected no runs outside the range, got %+v", runs)
+	}
+	if removed := restarted.RunHistory().Compact(time.Now().Add(time.Minute), 0); removed != 1 {
+		t.Errorf("Expected compaction to drop the run, removed %d", removed)
+	}
+
+	// Sunday 4 January 2026: both snapshot jobs each day, the scrub on Sunday
+	from := time.Date(2026, 1, 4, 0, 0, 0, 0, time.Local)
+	upcoming := scheduler.Upcoming(from, from.AddDate(0, 0, 2))
+	var summary []string
+	for _, run := range upcoming {
+		summary = append(summary, fmt.Sprintf("%s %s %s", run.At.Format("02 15:04"), run.Kind, run.Job))
+	}
+	want := "04 01:30 snapshot home, 04 02:00 snapshot default, 04 03:00 scrub default, 05 01:30 snapshot home, 05 02:00 snapshot default"
+	if strings.Join(summary, ", ") != want {
+		t.Errorf("Unexpected upcoming runs:\n%s\nwant\n%s", strings.Join(summary, ", "), want)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	var reported []int64
+	reader := &progressReader{
+		reader:     strings.NewReader(strings.Repeat("x", 10)),
+		onProgress: func(sent int64) { reported = append(reported, sent) },
+	}
+
+	buf := make([]byte, 4)
+	for {
+		if _, err := reader.Read(buf); err != nil {
+			break
+		}
+	}
+
+	// The first read reports right away, later ones are throttled until the end
+	if len(reported) != 2 || reported[0] != 4 || reported[1] != 10 {
+		t.Errorf("Expected progress at 4 and 10 bytes, got %v", reported)
+	}
+}
+
+func TestScorePair(t *testing.T) {
+	healthy := scorePair(PairHealth{SuccessRate: 1, LagSeconds: 3600, RPOSeconds: 7200})
+	if healthy.Score != 100 || healthy.Emoji != "🟢" || len(healthy.Issues) != 0 {
+		t.Errorf("Expected a perfect score, got %+v", healthy)
+	}
+
+	// Half the sends failing, lag at 1.5x the RPO and a gap in the chain
+	degraded := scorePair(PairHealth{SuccessRate: 0.5, LagSeconds: 10800, RPOSeconds: 7200, Pending: 1})
+	if degraded.Score != 60 || degraded.Emoji != "🔴" || len(degraded.Issues) != 3 {
+		t.Errorf("Expected a score of 60 with three issues, got %+v", degraded)
+	}
+
+	broken := scorePair(PairHealth{SuccessRate: 0, LagSeconds: 86400, RPOSeconds: 3600, Unverified: 1, Pending: 5})
+	if broken.Score != 0 {
+		t.Errorf("Expected a score of 0, got %+v", broken)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	cfg := &config.Config{
+		ZFS:      config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH:      config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+		Schedule: config.ScheduleConfig{SnapshotCron: "0 * * * *"},
+	}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	pairs := scheduler.Health()
+	if len(pairs) != 1 || pairs[0].Score != 100 || pairs[0].RPOSeconds != 7200 {
+		t.Fatalf("Expected a healthy pair with a two hour RPO, got %+v", pairs)
+	}
+
+	for i := 0; i < 4; i++ {
+		scheduler.targets[0].recordOutcome(i%2 == 0)
+	}
+	cfg.SLAs = []config.SLAConfig{{MaxAge: time.Hour}}
+	pairs = scheduler.Health()
+	if pairs[0].SuccessRate != 0.5 || pairs[0].RPOSeconds != 3600 {
+		t.Errorf("Expected a 50%% success rate and the SLA max_age as RPO, got %+v", pairs[0])
+	}
+}
+
+func TestFindGap(t *testing.T) {
+	local := []string{"s1", "s2", "s3", "s4", "s5"}
+
+	// The target was offline for s3 and s4, then received s5 on its own
+	from, missing, blocker := findGap(local, []string{"s1", "s2"})
+	if from != "s2" || !slices.Equal(missing, []string{"s3", "s4", "s5"}) || blocker != "" {
+		t.Errorf("Expected s3..s5 after s2, got %q %v %q", from, missing, blocker)
+	}
+
+	from, missing, blocker = findGap(local, []string{"s1", "s5"})
+	if from != "s5" || len(missing) != 0 || blocker != "" {
+		t.Errorf("Expected nothing to backfill past the remote's newest, got %q %v %q", from, missing, blocker)
+	}
+
+	if _, _, blocker = findGap(local, []string{"s2", "old"}); blocker == "" {
+		t.Error("Expected a remote snapshot missing locally to block the backfill")
+	}
+	if _, _, blocker = findGap(local, []string{"other"}); blocker == "" {
+		t.Error("Expected no common snapshot to block the backfill")
+	}
+	if _, _, blocker = findGap(local, nil); blocker == "" {
+		t.Error("Expected an empty target to block the backfill")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH: config.SSHConfig{RemoteHost: "primary.test.invalid", RemoteDataset: "backup/test"},
+	}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+
+	// Nothing in flight: drained right away, and no send can start after
+	idle := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	if sends := idle.Drain(context.Background()); sends != nil {
+		t.Errorf("Expected nothing cut off, got %v", sends)
+	}
+	if err := idle.TriggerSnapshot(); err == nil {
+		t.Error("Expected a snapshot triggered after draining to be refused")
+	}
+
+	// A send that outlasts the drain is reported
+	busy := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+	busy.sendMutex.Lock()
+	busy.targets[0].sending = "autosnap_2024-03-01_02-00-00"
+	if busy.InFlight() != 1 {
+		t.Errorf("Expected one send in flight, got %d", busy.InFlight())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	sends := busy.Drain(ctx)
+	if len(sends) != 1 || !strings.Contains(sends[0], "autosnap_2024-03-01_02-00-00") {
+		t.Errorf("Expected the running send to be cut off, got %v", sends)
+	}
+	busy.sendMutex.Unlock()
+}
+
+// fakeBackupServer is an SSH server on localhost that answers commands from
+// outputs and records every command it is sent
+type fakeBackupServer struct {
+	addr     string
+	key      string // Private key file to log in with
+	outputs  map[string]string
+	mutex    sync.Mutex
+	commands []string
+}
+
+func startFakeBackupServer(t *testing.T, outputs map[string]string) *fakeBackupServer {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeBackupServer{addr: listener.Addr().String(), key: keyPath, outputs: outputs}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server
+}
+
+func (f *fakeBackupServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are served")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				var exec struct{ Command string }
+				if req.Type != "exec" || ssh.Unmarshal(req.Payload, &exec) != nil {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+
+				f.mutex.Lock()
+				f.commands = append(f.commands, exec.Command)
+				f.mutex.Unlock()
+
+				status := uint32(0)
+				if output, ok := f.outputs[exec.Command]; ok {
+					channel.Write([]byte(output))
+				} else {
+					fmt.Fprintf(channel.Stderr(), "unexpected command: %s\n", exec.Command)
+					status = 1
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+func (f *fakeBackupServer) received() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return slices.Clone(f.commands)
+}
+
+// stateFiles returns the content of every file in dir
+func stateFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func TestTierOldSnapshotsArchivesIncrementally(t *testing.T) {
+	recent := "autosnap_" + time.Now().Format("2006-01-02_15-04-05")
+	old := []string{"autosnap_2024-07-17_02-00-00", "autosnap_2024-07-18_02-00-00", "autosnap_2024-07-19_02-00-00"}
+	archive := func(flags, name string) string {
+		location := "/mnt/cold/zfsrabbit/backup/test/" + name + ".zfs"
+		return fmt.Sprintf("mkdir -p \"/mnt/cold/zfsrabbit/backup/test\" && zfs send %s \"backup/test@%s\" > \"%s.partial\" && mv \"%s.partial\" \"%s\" && wc -c < \"%s\"",
+			flags, name, location, location, location, location)
+	}
+	server := startFakeBackupServer(t, map[string]string{
+		"zfs list -t snapshot -H -o name backup/test": "backup/test@" + strings.Join(append(slices.Clone(old), recent), "\nbackup/test@") + "\n",
+		archive("-R", old[0]):                         "1000\n",
+		archive("-R -i @"+old[0], old[1]):             "10\n",
+		archive("-R -i @"+old[1], old[2]):             "20\n",
+		`zfs destroy -r "backup/test@` + old[0] + `"`: "",
+		`zfs destroy -r "backup/test@` + old[1] + `"`: "",
+	})
+	cfg := &config.Config{
+		ZFS:     config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 30},
+		SSH:     config.SSHConfig{RemoteHost: server.addr, RemoteUser: "root", PrivateKey: server.key, RemoteDataset: "backup/test"},
+		Tiering: config.TieringConfig{Enabled: true, AfterDays: 30, ArchiveDir: "/mnt/cold/zfsrabbit"},
+	}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, NewMockZFSExecutor())
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), mocks.NewMockAlerter())
+
+	if err := scheduler.tierOldSnapshots(); err != nil {
+		t.Fatalf("tierOldSnapshots failed: %v", err)
+	}
+
+	// The newest archived snapshot stays on the pool as the next base
+	for _, command := range server.received() {
+		if strings.Contains(command, "destroy") && strings.Contains(command, old[2]) {
+			t.Errorf("Expected %s to stay on the pool, got %s", old[2], command)
+		}
+	}
+	chain, err := scheduler.Catalog().ArchiveChain("backup/test", old[2])
+	if err != nil {
+		t.Fatalf("ArchiveChain failed: %v", err)
+	}
+	if len(chain) != 3 || chain[0].Base != "" || chain[1].Base != old[0] || chain[2].Base != old[1] || chain[2].Size != 20 {
+		t.Errorf("Expected a full stream then two incrementals, got %+v", chain)
+	}
+
+	// A later run continues the chain from the base left on the pool
+	server.mutex.Lock()
+	server.outputs["zfs list -t snapshot -H -o name backup/test"] = "backup/test@" + old[2] + "\nbackup/test@autosnap_2024-07-20_02-00-00\nbackup/test@" + recent + "\n"
+	server.outputs[archive("-R -i @"+old[2], "autosnap_2024-07-20_02-00-00")] = "30\n"
+	server.outputs[`zfs destroy -r "backup/test@`+old[2]+`"`] = ""
+	server.mutex.Unlock()
+	if err := scheduler.tierOldSnapshots(); err != nil {
+		t.Fatalf("Second tierOldSnapshots failed: %v", err)
+	}
+	if chain, err := scheduler.Catalog().ArchiveChain("backup/test", "autosnap_2024-07-20_02-00-00"); err != nil || len(chain) != 4 {
+		t.Errorf("Expected the chain to grow to 4 streams, got %+v, %v", chain, err)
+	}
+}
+
+func TestDryRunLeavesStateAlone(t *testing.T) {
+	utils.DefaultRunner.SetDryRun(true)
+	defer utils.DefaultRunner.SetDryRun(false)
+
+	server := startFakeBackupServer(t, map[string]string{
+		"zfs list -t snapshot -H -o name backup/test": "backup/test@autosnap_2024-07-17_02-00-00\n" +
+			"backup/test@autosnap_2024-07-18_02-00-00\n" +
+			"backup/test@autosnap_2024-07-19_02-00-00\n",
+	})
+	stateDir := t.TempDir()
+	cfg := &config.Config{
+		Server:  config.ServerConfig{StateDir: stateDir},
+		ZFS:     config.ZFSConfig{Dataset: "tank/test", KeepSnapshots: 1, PruneRemote: true},
+		SSH:     config.SSHConfig{RemoteHost: server.addr, RemoteUser: "root", PrivateKey: server.key, RemoteDataset: "backup/test"},
+		Tiering: config.TieringConfig{Enabled: true, AfterDays: 30, ArchiveDir: "/mnt/cold/zfsrabbit"},
+		SLAs:    []config.SLAConfig{{Dataset: "tank/test", MaxAge: 24 * time.Hour}},
+	}
+	executor := &recordingExecutor{outputs: map[string]string{
+		"zfs list -t snapshot -H -o name,creation,used,refer -s creation tank/test": "tank/test@snap1\tWed Jul 17 18:00 2024\t1M\t1M\n" +
+			"tank/test@snap2\tThu Jul 18 18:00 2024\t1M\t1M\n" +
+			"tank/test@snap3\tFri Jul 19 18:00 2024\t1M\t1M\n",
+	}}
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, "", false, executor)
+	alerter := mocks.NewMockAlerter()
+	scheduler := New(cfg, zfsManager, transport.NewSSHTransport(&cfg.SSH), alerter)
+	tracker := sla.New(cfg, filepath.Join(stateDir, "sla.json"), alerter)
+	scheduler.SetSLATracker(tracker)
+	scheduler.targets[0].pending = []string{"autosnap_2024-07-16_02-00-00"}
+	scheduler.savePending()
+	before := stateFiles(t, stateDir)
+
+	// Replication, including the retry of the pending send, then retention
+	// and tiering once every target "has" the snapshot
+	scheduler.performSnapshot()
+	if err := scheduler.cleanupOldSnapshots(); err != nil {
+		t.Fatalf("cleanupOldSnapshots failed: %v", err)
+	}
+	if err := scheduler.tierOldSnapshots(); err != nil {
+		t.Fatalf("tierOldSnapshots failed: %v", err)
+	}
+
+	if after := stateFiles(t, stateDir); fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Errorf("Expected the state directory to be unchanged, got\n%v\nwant\n%v", after, before)
+	}
+	if destroyed := scheduler.DestroyedSnapshots(); len(destroyed) != 0 {
+		t.Errorf("Expected no destroyed snapshots in the catalog, got %+v", destroyed)
+	}
+	if archived := scheduler.Catalog().ArchivedSnapshots(); len(archived) != 0 {
+		t.Errorf("Expected no archived snapshots in the catalog, got %+v", archived)
+	}
+	if runs := scheduler.History(time.Time{}, time.Now().Add(time.Minute)); len(runs) != 0 {
+		t.Errorf("Expected no runs in the history, got %+v", runs)
+	}
+	if status := tracker.Status(); len(status) != 1 || status[0].LastSuccess != nil {
+		t.Errorf("Expected no SLA success, got %+v", status)
+	}
+	if alerter.GetSyncSuccessCount() != 0 || alerter.GetSyncFailureCount() != 0 {
+		t.Errorf("Expected no sync alerts, got %d successes and %d failures", alerter.GetSyncSuccessCount(), alerter.GetSyncFailureCount())
+	}
+	if pending := scheduler.targets[0].pending; !slices.Equal(pending, []string{"autosnap_2024-07-16_02-00-00"}) {
+		t.Errorf("Expected the pending send to stay queued, got %v", pending)
+	}
+	if status := scheduler.TargetStatus()[0]; status.LastSuccess != nil || status.LastError != "" {
+		t.Errorf("Expected the target's status to be untouched, got %+v", status)
+	}
+
+	for _, run := range executor.runs {
+		if strings.HasPrefix(run, "zfs destroy") || strings.HasPrefix(run, "zfs bookmark") {
+			t.Errorf("Expected no local snapshot to be destroyed, ran %s", run)
+		}
+	}
+	for _, command := range server.received() {
+		if !strings.HasPrefix(command, "zfs list") {
+			t.Errorf("Expected the backup server only to be listed, it was sent %s", command)
+		}
+	}
+	if len(server.received()) == 0 {
+		t.Error("Expected the backup server's snapshots to be listed")
+	}
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/hooks"
+	"zfsrabbit/internal/utils"
+)
+
+// Values of ZFSRABBIT_SNAPSHOT_STATUS for post_snapshot hooks
+const (
+	snapshotTaken   = "ok"
+	snapshotFailed  = "failed"
+	snapshotSkipped = "skipped" // A pre_snapshot hook failed and aborted it
+)
+
+// runPreSnapshotHooks runs the job's pre_snapshot hooks in order. A failed
+// hook with on_failure: abort stops there and returns an error, and the
+// snapshot is skipped; other failures are alerted on and the snapshot is
+// taken anyway.
+func (s *Scheduler) runPreSnapshotHooks(snapshotName string) error {
+	env := s.snapshotHookEnv(snapshotName)
+	for _, hook := range s.Config().ZFS.PreSnapshot {
+		result := hooks.Run(utils.DefaultRunner, hook.DrillHook, env)
+		if result.Passed {
+			s.logger.Debug("Pre-snapshot hook passed", "hook", hook.Name, "duration", result.Duration)
+			continue
+		}
+
+		err := hookError("pre_snapshot", result)
+		if hook.OnFailure != config.HookContinue {
+			return err
+		}
+		s.logger.Warn("Pre-snapshot hook failed, taking the snapshot anyway", "hook", hook.Name, "err", err)
+		s.alertHookFailure(snapshotName, err, "The snapshot was taken anyway (on_failure: continue), but may not be application-consistent.")
+	}
+	return nil
+}
+
+// runPostSnapshotHooks runs every post_snapshot hook whatever became of the
+// snapshot, so a lock or freeze taken by a pre_snapshot hook is always
+// released. Failures are alerted on.
+func (s *Scheduler) runPostSnapshotHooks(snapshotName, status string) {
+	env := append(s.snapshotHookEnv(snapshotName), "ZFSRABBIT_SNAPSHOT_STATUS="+status)
+	for _, hook := range s.Config().ZFS.PostSnapshot {
+		result := hooks.Run(utils.DefaultRunner, hook.DrillHook, env)
+		if result.Passed {
+			s.logger.Debug("Post-snapshot hook passed", "hook", hook.Name, "duration", result.Duration)
+			continue
+		}
+
+		err := hookError("post_snapshot", result)
+		s.logger.Error("Post-snapshot hook failed", "hook", hook.Name, "err", err)
+		s.alertHookFailure(snapshotName, err, "Check that whatever the pre_snapshot hooks paused or locked has been resumed.")
+	}
+}
+
+func (s *Scheduler) snapshotHookEnv(snapshotName string) []string {
+	return []string{
+		"ZFSRABBIT_SNAPSHOT_JOB=" + s.name,
+		"ZFSRABBIT_SNAPSHOT_DATASET=" + s.Config().ZFS.Dataset,
+		"ZFSRABBIT_SNAPSHOT_NAME=" + snapshotName,
+	}
+}
+
+func (s *Scheduler) alertHookFailure(snapshotName string, err error, advice string) {
+	subject := fmt.Sprintf("[WARNING] Snapshot Hook Failed: %s", s.Config().ZFS.Dataset)
+	body := fmt.Sprintf("Snapshot Hook Failed\n\nDataset: %s\nSnapshot: %s\nJob: %s\n\n%v\n\n%s\n",
+		s.Config().ZFS.Dataset, snapshotName, s.name, err, advice)
+	if err := s.alerter.SendAlert(subject, body); err != nil {
+		s.logger.Error("Failed to send hook alert", "err", err)
+	}
+}
+
+func hookError(kind string, result hooks.Result) error {
+	message := fmt.Sprintf("%s hook %s failed: %s", kind, result.Name, result.Error)
+	if result.Output != "" {
+		message += ": " + strings.ReplaceAll(result.Output, "\n", " ")
+	}
+	return errors.New(message)
+}
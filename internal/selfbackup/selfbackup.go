@@ -0,0 +1,168 @@
+package selfbackup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/utils"
+)
+
+const (
+	configEntry = "config.yaml"
+	statePrefix = "state/"
+)
+
+// Uploader and Downloader are implemented by transport.SSHTransport
+type Uploader interface {
+	UploadFile(remotePath string, r io.Reader) error
+}
+
+type Downloader interface {
+	DownloadFile(remotePath string, w io.Writer) error
+}
+
+// RemotePath is where the archive for host is kept on the backup server
+func RemotePath(remoteDir, host string) string {
+	return path.Join(remoteDir, host+".tar.gz")
+}
+
+// Backup archives the config file and state directory and uploads them
+func Backup(u Uploader, remotePath, configPath, stateDir string) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(WriteArchive(pw, configPath, stateDir))
+	}()
+
+	err := u.UploadFile(remotePath, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// Restore downloads an archive made by Backup and unpacks it onto this host
+func Restore(d Downloader, remotePath, configPath, stateDir string) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(d.DownloadFile(remotePath, pw))
+	}()
+
+	err := ExtractArchive(pr, configPath, stateDir)
+	pr.CloseWithError(err)
+	return err
+}
+
+// WriteArchive writes a gzipped tar of the config file and the durable files
+// in stateDir. The lock file, quarantined and temporary files are skipped.
+func WriteArchive(w io.Writer, configPath, stateDir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if configPath != "" {
+		if err := addFile(tw, configPath, configEntry); err != nil {
+			return fmt.Errorf("failed to archive config: %w", err)
+		}
+	}
+
+	if stateDir != "" {
+		entries, err := os.ReadDir(stateDir)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read state directory: %w", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.Type().IsRegular() || !isStateFile(name) {
+				continue
+			}
+			if err := addFile(tw, filepath.Join(stateDir, name), statePrefix+name); err != nil {
+				return fmt.Errorf("failed to archive %s: %w", name, err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func isStateFile(name string) bool {
+	return filepath.Ext(name) == ".json" && !strings.HasPrefix(name, ".")
+}
+
+func addFile(tw *tar.Writer, src, name string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// ExtractArchive unpacks an archive from WriteArchive. An existing config is
+// kept alongside as <config>.bak before being replaced.
+func ExtractArchive(r io.Reader, configPath, stateDir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("invalid backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid backup archive: %w", err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case header.Name == configEntry:
+			if existing, err := os.ReadFile(configPath); err == nil {
+				if err := utils.WriteFileAtomic(configPath+".bak", existing, 0600); err != nil {
+					return fmt.Errorf("failed to keep existing config: %w", err)
+				}
+			}
+			if err := utils.WriteFileAtomic(configPath, data, 0600); err != nil {
+				return fmt.Errorf("failed to restore config: %w", err)
+			}
+
+		case strings.HasPrefix(header.Name, statePrefix):
+			name := strings.TrimPrefix(header.Name, statePrefix)
+			// Only flat, well-formed state file names; never follow paths out of stateDir
+			if name != filepath.Base(name) || !isStateFile(name) {
+				return fmt.Errorf("unexpected entry in backup archive: %s", header.Name)
+			}
+			if err := utils.WriteFileAtomic(filepath.Join(stateDir, name), data, 0600); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+
+		default:
+			return fmt.Errorf("unexpected entry in backup archive: %s", header.Name)
+		}
+	}
+}
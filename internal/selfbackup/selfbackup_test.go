@@ -0,0 +1,93 @@
+package selfbackup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memoryRemote stands in for the backup server
+type memoryRemote struct {
+	files map[string][]byte
+}
+
+func (m *memoryRemote) UploadFile(remotePath string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.files[remotePath] = data
+	return nil
+}
+
+func (m *memoryRemote) DownloadFile(remotePath string, w io.Writer) error {
+	_, err := w.Write(m.files[remotePath])
+	return err
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	configPath := filepath.Join(src, "config.yaml")
+	stateDir := filepath.Join(src, "state")
+	os.MkdirAll(stateDir, 0750)
+
+	os.WriteFile(configPath, []byte("version: 2\n"), 0600)
+	os.WriteFile(filepath.Join(stateDir, "monitor_state.json"), []byte(`{"alert_states":{}}`), 0600)
+	os.WriteFile(filepath.Join(stateDir, "zfsrabbit.lock"), []byte("123\n"), 0600)
+	os.WriteFile(filepath.Join(stateDir, "alert_outbox.json.corrupt-20240101-000000"), []byte("{"), 0600)
+
+	remote := &memoryRemote{files: make(map[string][]byte)}
+	remotePath := RemotePath("/var/backups/zfsrabbit", "host1")
+
+	if err := Backup(remote, remotePath, configPath, stateDir); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if remotePath != "/var/backups/zfsrabbit/host1.tar.gz" {
+		t.Errorf("Unexpected remote path %s", remotePath)
+	}
+
+	dst := t.TempDir()
+	newConfig := filepath.Join(dst, "config.yaml")
+	newState := filepath.Join(dst, "state")
+	os.WriteFile(newConfig, []byte("old: true\n"), 0600)
+
+	if err := Restore(remote, remotePath, newConfig, newState); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if data, _ := os.ReadFile(newConfig); string(data) != "version: 2\n" {
+		t.Errorf("Config not restored, got %q", data)
+	}
+	if data, _ := os.ReadFile(newConfig + ".bak"); string(data) != "old: true\n" {
+		t.Errorf("Existing config not kept as .bak, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(newState, "monitor_state.json")); err != nil {
+		t.Errorf("State file not restored: %v", err)
+	}
+	for _, skipped := range []string{"zfsrabbit.lock", "alert_outbox.json.corrupt-20240101-000000"} {
+		if _, err := os.Stat(filepath.Join(newState, skipped)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be part of the backup", skipped)
+		}
+	}
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	data := []byte("{}")
+	tw.WriteHeader(&tar.Header{Name: "state/../../etc/evil.json", Mode: 0600, Size: int64(len(data))})
+	tw.Write(data)
+	tw.Close()
+	gz.Close()
+
+	dst := t.TempDir()
+	err := ExtractArchive(&buf, filepath.Join(dst, "config.yaml"), filepath.Join(dst, "state"))
+	if err == nil {
+		t.Error("Expected traversal entry to be rejected")
+	}
+}
@@ -5,14 +5,33 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"strings"
+	"sync"
 	"time"
 
 	"zfsrabbit/internal/alert"
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/compact"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/export"
+	"zfsrabbit/internal/grpcapi"
+	"zfsrabbit/internal/i18n"
 	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/orphan"
+	"zfsrabbit/internal/policy"
 	"zfsrabbit/internal/restore"
 	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/silence"
+	"zfsrabbit/internal/sla"
+	"zfsrabbit/internal/state"
+	"zfsrabbit/internal/systemd"
+	"zfsrabbit/internal/telegram"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/update"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/version"
 	"zfsrabbit/internal/web"
 	"zfsrabbit/internal/zfs"
 )
@@ -25,7 +44,15 @@ type Server struct {
 	monitor        *monitor.Monitor
 	multiAlerter   *alert.MultiAlerter
 	webServer      *web.Server
+	grpcServer     *grpcapi.Server
 	restoreManager *restore.RestoreManager
+	exporter       *export.Exporter
+	updateChecker  *update.Checker
+	slaTracker     *sla.Tracker
+	standby        *restore.Standby
+	compactor      *compact.Compactor
+	telegramBot    *telegram.Bot
+	stateDir       *state.Dir
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -36,21 +63,134 @@ func New(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("system dependency check failed: %w", err)
 	}
 
+	// Lock the state directory before any store reads from it
+	var stateDir *state.Dir
+	if cfg.Server.StateDir != "" {
+		dir, err := state.Open(cfg.Server.StateDir)
+		if err != nil {
+			return nil, err
+		}
+		stateDir = dir
+
+		// A policy set applied through the API overrides the YAML until replaced
+		set, err := policy.Load(stateDir.Path(state.PoliciesFile))
+		if err != nil {
+			stateDir.Close()
+			return nil, fmt.Errorf("failed to load policy set: %w", err)
+		}
+		if set != nil {
+			if err := set.Matches(cfg); err != nil {
+				log.Printf("WARNING: stored policy set no longer fits the config: %v", err)
+			}
+			if set.Apply(cfg) {
+				log.Printf("Applied stored policy set from %s", cfg.Server.StateDir)
+			}
+		}
+	} else {
+		log.Printf("WARNING: server.state_dir not set, alert baselines and queues will not survive restarts")
+	}
+
+	utils.DefaultRunner.SetDryRun(cfg.DryRun)
+	if cfg.DryRun {
+		log.Printf("WARNING: dry-run mode, snapshots, sends, destroys and restores are logged but not performed")
+	}
+
+	if err := display.Configure(cfg.Display); err != nil {
+		return nil, err
+	}
+	if err := i18n.Configure(cfg.Display.Locale, cfg.Display.MessagesDir); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	zfsManager := zfs.New(cfg.ZFS.Dataset, cfg.ZFS.SendCompression, cfg.ZFS.Recursive)
+	zfsManager.SetRawSend(cfg.ZFS.RawSend)
 
 	transport := transport.NewSSHTransport(&cfg.SSH)
 
-	multiAlerter := alert.NewMultiAlerter(&cfg.Email, &cfg.Slack)
+	multiAlerter := alert.NewMultiAlerter(cfg, state.PathIn(cfg.Server.StateDir, state.OutboxFile))
+	multiAlerter.SetDigestStore(state.PathIn(cfg.Server.StateDir, state.DigestFile))
+
+	if cfg.Syslog.Enabled && cfg.Syslog.Audit {
+		audit.AddSink(multiAlerter.RecordAudit)
+	}
+
+	// Live updates for the dashboard
+	bus := events.NewBus()
 
 	monitor := monitor.New(cfg, multiAlerter)
+	monitor.SetEvents(bus)
+	multiAlerter.SetIncidentStore(monitor)
+
+	// Maintenance windows, set from the web API or Slack
+	silences := silence.New(state.PathIn(cfg.Server.StateDir, state.SilencesFile))
+	monitor.SetSilences(silences)
+
+	alertHistory := alert.NewHistory(state.PathIn(cfg.Server.StateDir, state.AlertsFile))
+	multiAlerter.SetHistory(alertHistory)
 
 	scheduler := scheduler.New(cfg, zfsManager, transport, multiAlerter)
+	monitor.SetCatalog(scheduler.Catalog())
+	scheduler.SetEvents(bus)
+
+	slaTracker := sla.New(cfg, state.PathIn(cfg.Server.StateDir, state.SLAFile), multiAlerter)
+	scheduler.SetSLATracker(slaTracker)
+	multiAlerter.SetCompliance(slaTracker)
 
 	restoreManager := restore.New(transport, zfsManager)
+	restoreManager.SetMountHooks(cfg.Restore.MountHooks)
+	restoreManager.SetPostHooks(cfg.Restore.PostHooks)
+	restoreManager.SetParallelism(cfg.Restore.Parallelism)
+	restoreManager.SetCatalog(scheduler.Catalog())
+	restoreManager.SetThroughput(scheduler.Throughput())
+	restoreManager.SetEvents(bus)
+	restoreManager.SetJobStore(state.PathIn(cfg.Server.StateDir, state.JobsFile))
 
 	webServer := web.NewServer(cfg, scheduler, monitor, zfsManager, restoreManager, transport)
+	webServer.SetSLATracker(slaTracker)
+	webServer.SetEvents(bus)
+	webServer.SetSilences(silences)
+	webServer.SetAlertHistory(alertHistory)
+	restoreRequests := restore.NewRequests(restoreManager, state.PathIn(cfg.Server.StateDir, state.RequestsFile), multiAlerter)
+	webServer.SetRestoreRequests(restoreRequests)
+
+	// The gRPC API shares the web server's users and API tokens
+	var grpcServer *grpcapi.Server
+	if cfg.Server.GRPCPort != 0 {
+		grpcServer = grpcapi.New(cfg, scheduler, monitor, zfsManager, restoreManager, webServer.CheckAPICredentials)
+		grpcServer.SetEvents(bus)
+	}
+
+	standby := restore.NewStandby(cfg, transport, zfsManager, multiAlerter)
+	standby.SetThroughput(scheduler.Throughput())
+	webServer.SetStandby(standby)
+
+	compactor := compact.New(&cfg.Store, stateDir)
+	compactor.Register("catalog", scheduler.Catalog())
+	compactor.Register("run_history", scheduler.RunHistory())
+	compactor.Register("throughput", scheduler.Throughput())
+	compactor.Register("restore_requests", restoreRequests)
+	compactor.Register("drill_reports", webServer.DrillManager())
+	compactor.Register("alert_history", alertHistory)
+	webServer.SetCompactor(compactor)
+
+	var exporter *export.Exporter
+	if cfg.Export.Path != "" {
+		exporter = export.New(&cfg.Export, webServer.StatusReport)
+	}
+
+	var updateChecker *update.Checker
+	if cfg.Update.Enabled {
+		updateChecker = update.NewChecker(&cfg.Update, version.Get().Version)
+		webServer.SetUpdateChecker(updateChecker)
+		multiAlerter.SetUpdateChecker(updateChecker)
+	}
+
+	var telegramBot *telegram.Bot
+	if cfg.Telegram.Enabled && cfg.Telegram.Commands {
+		telegramBot = telegram.NewBot(&cfg.Telegram, scheduler, monitor, zfsManager)
+	}
 
 	return &Server{
 		config:         cfg,
@@ -60,20 +200,50 @@ func New(cfg *config.Config) (*Server, error) {
 		monitor:        monitor,
 		multiAlerter:   multiAlerter,
 		webServer:      webServer,
+		grpcServer:     grpcServer,
 		restoreManager: restoreManager,
+		exporter:       exporter,
+		updateChecker:  updateChecker,
+		slaTracker:     slaTracker,
+		standby:        standby,
+		compactor:      compactor,
+		telegramBot:    telegramBot,
+		stateDir:       stateDir,
 		ctx:            ctx,
 		cancel:         cancel,
 	}, nil
 }
 
 func (s *Server) Start() error {
-	log.Println("Starting ZFSRabbit server")
+	log.Printf("Starting ZFSRabbit server (%s)", version.Get())
+
+	// A crash mid-transfer can leave send or receive processes running and
+	// the send queues out of date
+	orphan.Cleanup()
+	go s.scheduler.Reconcile()
 
 	if err := s.scheduler.Start(); err != nil {
 		return err
 	}
 
+	if err := s.compactor.Start(); err != nil {
+		return err
+	}
+
+	go s.multiAlerter.Start()
 	go s.monitor.Start()
+	go s.slaTracker.Start()
+	go s.standby.Start()
+
+	if s.exporter != nil {
+		go s.exporter.Start()
+	}
+	if s.updateChecker != nil {
+		go s.updateChecker.Start()
+	}
+	if s.telegramBot != nil {
+		go s.telegramBot.Start()
+	}
 
 	log.Printf("ZFSRabbit started - Web interface available at http://localhost:%d", s.config.Server.Port)
 	if s.config.GetAdminPassword() == "" {
@@ -82,12 +252,28 @@ func (s *Server) Start() error {
 		log.Printf("Admin authentication enabled (password from %s)", s.config.Server.AdminPassEnv)
 	}
 
+	if s.grpcServer != nil {
+		if s.config.Server.TLS.Enabled {
+			tlsConfig, err := web.TLSConfig(&s.config.Server)
+			if err != nil {
+				return err
+			}
+			s.grpcServer.SetTLSConfig(tlsConfig)
+		}
+		if err := s.grpcServer.Start(); err != nil {
+			return err
+		}
+	}
+
+	systemd.Ready()
 	return s.webServer.Start()
 }
 
 func (s *Server) Stop() {
 	log.Println("Stopping ZFSRabbit server")
 
+	s.drain()
+
 	// Create context with timeout for graceful shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
@@ -95,16 +281,101 @@ func (s *Server) Stop() {
 	// Stop components in reverse order
 	s.scheduler.Stop()
 	s.monitor.Stop()
+	s.slaTracker.Stop()
+	s.standby.Stop()
+	s.compactor.Stop()
+	s.multiAlerter.Stop()
+	if s.exporter != nil {
+		s.exporter.Stop()
+	}
+	if s.updateChecker != nil {
+		s.updateChecker.Stop()
+	}
+	if s.telegramBot != nil {
+		s.telegramBot.Stop()
+	}
 
 	// Gracefully shutdown web server
 	if err := s.webServer.Shutdown(shutdownCtx); err != nil {
 		log.Printf("Web server shutdown error: %v", err)
 	}
+	if s.grpcServer != nil {
+		s.grpcServer.Shutdown(shutdownCtx)
+	}
 
 	s.transport.Close()
+	if s.stateDir != nil {
+		s.stateDir.Close()
+	}
 	s.cancel()
 }
 
+// drainStatusInterval is how often progress is logged while draining
+const drainStatusInterval = 10 * time.Second
+
+// drain lets the sends and restores in flight finish before Stop cuts them
+// off, waiting up to server.drain_timeout. No new ones start meanwhile.
+func (s *Server) drain() {
+	timeout := s.config.Server.DrainTimeout
+	if timeout <= 0 {
+		systemd.Stopping("Stopping")
+		return
+	}
+
+	sends, restores := s.scheduler.InFlight(), len(s.restoreManager.Running())
+	status := fmt.Sprintf("Draining %d sends and %d restores", sends, restores)
+	if sends+restores > 0 {
+		log.Printf("%s, waiting up to %s", status, timeout)
+	}
+	systemd.Stopping(status)
+	// Leave systemd time for the rest of Stop after the drain
+	systemd.ExtendTimeout(timeout + 30*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cutSends, cutRestores []string
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		cutSends = s.scheduler.Drain(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		cutRestores = s.restoreManager.Drain(ctx)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(drainStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			if len(cutSends)+len(cutRestores) == 0 {
+				if sends+restores > 0 {
+					log.Printf("Drained all sends and restores")
+				}
+				systemd.Status("Drained, stopping")
+				return
+			}
+			log.Printf("WARNING: drain timed out after %s, cutting off sends (%s) and restores (%s)",
+				timeout, strings.Join(cutSends, ", "), strings.Join(cutRestores, ", "))
+			systemd.Status(fmt.Sprintf("Drain timed out, cutting off %d sends and %d restores", len(cutSends), len(cutRestores)))
+			return
+		case <-ticker.C:
+			status := fmt.Sprintf("Draining %d sends and %d restores", s.scheduler.InFlight(), len(s.restoreManager.Running()))
+			log.Printf("%s", status)
+			systemd.Status(status)
+		}
+	}
+}
+
 func checkSystemDependencies() error {
 	requiredCommands := []string{"zfs", "zpool", "mbuffer", "pv", "nvme", "smartctl"}
 
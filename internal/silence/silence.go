@@ -0,0 +1,193 @@
+// Package silence keeps alert silences: maintenance windows during which
+// the monitor holds back alerts about a pool, a device, or everything.
+package silence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/utils"
+)
+
+// What a silence covers
+const (
+	ScopeAll    = "all"
+	ScopePool   = "pool"
+	ScopeDevice = "device"
+)
+
+// MaxDuration is the longest a silence may last, so a forgotten one can't
+// hide alerts indefinitely
+const MaxDuration = 30 * 24 * time.Hour
+
+// Silence holds back alerts matching its scope until it expires
+type Silence struct {
+	ID      string    `json:"id"`
+	Scope   string    `json:"scope"`            // all, pool or device
+	Target  string    `json:"target,omitempty"` // Pool or device name; empty for all
+	Reason  string    `json:"reason,omitempty"`
+	By      string    `json:"created_by"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// Matches reports whether the silence covers an alert about pool and
+// devices. Devices match by kernel name, /dev path, by-id name or serial.
+func (s Silence) Matches(pool string, devices ...string) bool {
+	switch s.Scope {
+	case ScopeAll:
+		return true
+	case ScopePool:
+		return pool != "" && pool == s.Target
+	case ScopeDevice:
+		target := deviceName(s.Target)
+		for _, device := range devices {
+			if device != "" && deviceName(device) == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deviceName strips /dev and /dev/disk/by-id from a device path
+func deviceName(device string) string {
+	device = strings.TrimPrefix(device, "/dev/disk/by-id/")
+	return strings.TrimPrefix(device, "/dev/")
+}
+
+// Store keeps silences, persisted at path when it is set. Expired silences
+// are dropped whenever the store is saved.
+type Store struct {
+	mutex    sync.Mutex
+	path     string
+	silences []Silence
+	now      func() time.Time
+}
+
+func New(path string) *Store {
+	s := &Store{path: path, now: time.Now}
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &s.silences); err != nil {
+			log.Printf("Failed to load alert silences from %s: %v", path, err)
+		}
+	}
+	return s
+}
+
+// Add silences alerts in scope about target for duration
+func (s *Store) Add(scope, target string, duration time.Duration, reason, by string) (Silence, error) {
+	switch scope {
+	case ScopeAll:
+		target = ""
+	case ScopePool, ScopeDevice:
+		if target == "" {
+			return Silence{}, fmt.Errorf("a %s silence needs the %s name", scope, scope)
+		}
+	default:
+		return Silence{}, fmt.Errorf("scope must be all, pool or device")
+	}
+	if duration <= 0 || duration > MaxDuration {
+		return Silence{}, fmt.Errorf("duration must be between 1s and %s", MaxDuration)
+	}
+
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return Silence{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	silence := Silence{
+		ID:      "sil_" + hex.EncodeToString(buf),
+		Scope:   scope,
+		Target:  target,
+		Reason:  reason,
+		By:      by,
+		Created: now,
+		Expires: now.Add(duration),
+	}
+	s.silences = append(s.silences, silence)
+	s.saveLocked()
+
+	log.Printf("Alerts silenced by %s: %s until %s (%s)", by, silence.Describe(), silence.Expires.Format(time.RFC3339), silence.ID)
+	return silence, nil
+}
+
+// Remove ends a silence early, reporting whether it was active
+func (s *Store) Remove(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	for i, silence := range s.silences {
+		if silence.ID == id && now.Before(silence.Expires) {
+			s.silences = append(s.silences[:i], s.silences[i+1:]...)
+			s.saveLocked()
+			log.Printf("Alert silence %s (%s) removed", id, silence.Describe())
+			return true
+		}
+	}
+	return false
+}
+
+// Active returns the silences in effect, soonest to expire first
+func (s *Store) Active() []Silence {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	active := []Silence{}
+	for _, silence := range s.silences {
+		if now.Before(silence.Expires) {
+			active = append(active, silence)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Expires.Before(active[j].Expires) })
+	return active
+}
+
+// Match returns the active silence covering an alert about pool and
+// devices, or nil
+func (s *Store) Match(pool string, devices ...string) *Silence {
+	for _, silence := range s.Active() {
+		if silence.Matches(pool, devices...) {
+			return &silence
+		}
+	}
+	return nil
+}
+
+// Describe names what a silence covers, e.g. "pool tank"
+func (s Silence) Describe() string {
+	if s.Scope == ScopeAll {
+		return "all alerts"
+	}
+	return s.Scope + " " + s.Target
+}
+
+func (s *Store) saveLocked() {
+	now := s.now()
+	active := s.silences[:0]
+	for _, silence := range s.silences {
+		if now.Before(silence.Expires) {
+			active = append(active, silence)
+		}
+	}
+	s.silences = active
+
+	if s.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(s.path, s.silences, 0600); err != nil {
+		log.Printf("Failed to save alert silences: %v", err)
+	}
+}
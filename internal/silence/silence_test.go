@@ -0,0 +1,109 @@
+package silence
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		silence Silence
+		pool    string
+		devices []string
+		want    bool
+	}{
+		{"all", Silence{Scope: ScopeAll}, "", nil, true},
+		{"pool", Silence{Scope: ScopePool, Target: "tank"}, "tank", nil, true},
+		{"other pool", Silence{Scope: ScopePool, Target: "tank"}, "backup", nil, false},
+		{"pool alert without pool", Silence{Scope: ScopePool, Target: "tank"}, "", []string{"sda"}, false},
+		{"kernel name", Silence{Scope: ScopeDevice, Target: "sda"}, "", []string{"/dev/sda"}, true},
+		{"by-id name", Silence{Scope: ScopeDevice, Target: "/dev/disk/by-id/ata-WDC_123"}, "", []string{"/dev/sda", "ata-WDC_123"}, true},
+		{"serial", Silence{Scope: ScopeDevice, Target: "WD-123"}, "tank", []string{"/dev/sdb", "WD-123"}, true},
+		{"other device", Silence{Scope: ScopeDevice, Target: "sda"}, "tank", []string{"/dev/sdb", ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.Matches(tt.pool, tt.devices...); got != tt.want {
+				t.Errorf("Matches(%q, %v) = %v, want %v", tt.pool, tt.devices, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddValidates(t *testing.T) {
+	s := New("")
+
+	tests := []struct {
+		name     string
+		scope    string
+		target   string
+		duration time.Duration
+	}{
+		{"unknown scope", "host", "nas", time.Hour},
+		{"pool without name", ScopePool, "", time.Hour},
+		{"device without name", ScopeDevice, "", time.Hour},
+		{"no duration", ScopeAll, "", 0},
+		{"too long", ScopeAll, "", MaxDuration + time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Add(tt.scope, tt.target, tt.duration, "", "alice"); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+	if active := s.Active(); len(active) != 0 {
+		t.Errorf("Expected no silences after failed adds, got %+v", active)
+	}
+}
+
+func TestStoreExpiresAndPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silences.json")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s := New(path)
+	s.now = func() time.Time { return now }
+
+	pool, err := s.Add(ScopePool, "tank", 2*time.Hour, "disk swap", "alice")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	device, err := s.Add(ScopeDevice, "sda", 30*time.Minute, "", "bob")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if got := s.Match("tank"); got == nil || got.ID != pool.ID {
+		t.Errorf("Expected pool silence to match, got %+v", got)
+	}
+	if got := s.Match("", "/dev/sda"); got == nil || got.ID != device.ID {
+		t.Errorf("Expected device silence to match, got %+v", got)
+	}
+
+	// Reloaded from disk, the silences outlast a restart
+	reloaded := New(path)
+	reloaded.now = func() time.Time { return now.Add(time.Hour) }
+	if active := reloaded.Active(); len(active) != 1 || active[0].ID != pool.ID || active[0].Reason != "disk swap" {
+		t.Fatalf("Expected only the pool silence after an hour, got %+v", active)
+	}
+	if reloaded.Match("", "/dev/sda") != nil {
+		t.Error("Expected the device silence to have expired")
+	}
+
+	if !reloaded.Remove(pool.ID) {
+		t.Fatal("Expected Remove to end the pool silence")
+	}
+	if reloaded.Remove(pool.ID) {
+		t.Error("Expected a second Remove to fail")
+	}
+	if reloaded.Match("tank") != nil {
+		t.Error("Expected no silence after Remove")
+	}
+	if active := New(path).Active(); len(active) != 0 {
+		t.Errorf("Expected removal to be saved, got %+v", active)
+	}
+}
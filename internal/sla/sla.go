@@ -0,0 +1,513 @@
+// Package sla tracks backup service levels: a daily deadline each dataset's
+// replication must finish by, and a maximum age for its newest replica.
+package sla
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/metrics"
+	"zfsrabbit/internal/utils"
+)
+
+const (
+	dateFormat    = "2006-01-02"
+	maxDays       = 400 // A little over a year of daily results
+	checkInterval = time.Minute
+)
+
+var (
+	compliantGauge = metrics.NewGauge("zfsrabbit_sla_compliant",
+		"1 if the dataset currently meets its SLA, 0 if not", "dataset")
+	lastSuccessGauge = metrics.NewGauge("zfsrabbit_sla_last_success_timestamp_seconds",
+		"Unix time of the last replication that reached every target", "dataset")
+	monthComplianceGauge = metrics.NewGauge("zfsrabbit_sla_month_compliance_ratio",
+		"Fraction of this month's completed days on which the SLA was met", "dataset")
+)
+
+type Alerter interface {
+	SendAlert(subject, body string) error
+}
+
+// Day is one day's result for a dataset
+type Day struct {
+	Date            string     `json:"date"`                // YYYY-MM-DD in the SLA's timezone
+	Completed       *time.Time `json:"completed,omitempty"` // First replication in the window ending at this day's finish_by
+	DeadlineChecked bool       `json:"deadline_checked,omitempty"`
+	DeadlineMissed  bool       `json:"deadline_missed,omitempty"`
+	MaxAgeBreached  bool       `json:"max_age_breached,omitempty"`
+}
+
+// Met reports whether the day met every objective
+func (d *Day) Met() bool {
+	return !d.DeadlineMissed && !d.MaxAgeBreached
+}
+
+// history is the persisted record for one dataset
+type history struct {
+	Since       time.Time `json:"since"` // When tracking began; max_age counts from here until the first success
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Stale       bool      `json:"stale,omitempty"` // max_age is currently exceeded
+	Days        []*Day    `json:"days"`
+}
+
+// Compliance summarises one month. Only completed days are counted, and days
+// zfsrabbit was not running have no result.
+type Compliance struct {
+	Month   string  `json:"month"` // YYYY-MM
+	Days    int     `json:"days"`
+	Met     int     `json:"met"`
+	Percent float64 `json:"percent"`
+}
+
+// Status is the current SLA state for one dataset
+type Status struct {
+	Dataset     string     `json:"dataset"`
+	FinishBy    string     `json:"finish_by,omitempty"`
+	MaxAge      string     `json:"max_age,omitempty"`
+	Timezone    string     `json:"timezone,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Compliant   bool       `json:"compliant"`
+	Breaches    []string   `json:"breaches,omitempty"`
+	ThisMonth   Compliance `json:"this_month"`
+	LastMonth   Compliance `json:"last_month"`
+}
+
+// Tracker records successful replications and evaluates them against the
+// configured SLAs, alerting when one is breached
+type Tracker struct {
+	config   *config.Config
+	path     string
+	alerter  Alerter
+	now      func() time.Time
+	mutex    sync.Mutex
+	datasets map[string]*history
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// New loads the tracker state at path; an empty path keeps it in memory only
+func New(cfg *config.Config, path string, alerter Alerter) *Tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Tracker{
+		config:   cfg,
+		path:     path,
+		alerter:  alerter,
+		now:      time.Now,
+		datasets: make(map[string]*history),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &t.datasets); err != nil {
+			log.Printf("Failed to load SLA state from %s: %v", path, err)
+		}
+	}
+
+	return t
+}
+
+// Start evaluates the SLAs every minute until Stop is called
+func (t *Tracker) Start() {
+	if len(t.config.SLAs) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		t.Check()
+
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tracker) Stop() {
+	t.cancel()
+}
+
+// RecordSuccess notes that a snapshot of dataset reached every replication target
+func (t *Tracker) RecordSuccess(dataset string, at time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	sla, ok := t.slaFor(dataset)
+	if !ok {
+		return
+	}
+
+	h := t.historyFor(dataset, at)
+	h.LastSuccess = at
+	if h.Stale {
+		h.Stale = false
+		log.Printf("SLA for %s is met again", dataset)
+	}
+
+	if minutes, ok := finishBy(sla); ok {
+		deadline := nextDeadline(at.In(location(sla)), minutes)
+		day := h.day(deadline.Format(dateFormat))
+		if day.Completed == nil {
+			completed := at
+			day.Completed = &completed
+		}
+	}
+
+	t.updateMetrics(dataset, sla, h, at)
+	t.saveLocked()
+}
+
+// Check evaluates every SLA at the current time. Deadlines are checked once,
+// the first time Check runs after them.
+func (t *Tracker) Check() {
+	now := t.now()
+
+	type alert struct{ subject, body string }
+	var alerts []alert
+
+	t.mutex.Lock()
+	changed := false
+	for _, sla := range t.config.SLAs {
+		dataset := sla.DatasetOr(t.config.ZFS.Dataset)
+		if _, exists := t.datasets[dataset]; !exists {
+			changed = true
+		}
+		h := t.historyFor(dataset, now)
+		local := now.In(location(sla))
+		today := h.day(local.Format(dateFormat))
+
+		if sla.MaxAge > 0 && now.Sub(h.lastSuccessOrSince()) > sla.MaxAge {
+			if !today.MaxAgeBreached {
+				today.MaxAgeBreached = true
+				changed = true
+			}
+			if !h.Stale {
+				h.Stale = true
+				changed = true
+				alerts = append(alerts, alert{
+					subject: fmt.Sprintf("[WARNING] Backup SLA Alert: %s", dataset),
+					body: slaAlertBody(dataset, sla, h, fmt.Sprintf("No successful replication in the last %s (max_age %s).",
+						now.Sub(h.lastSuccessOrSince()).Round(time.Minute), sla.MaxAge)),
+				})
+			}
+		}
+
+		if minutes, ok := finishBy(sla); ok && !today.DeadlineChecked {
+			deadline := deadlineOn(local, minutes)
+			if !local.Before(deadline) {
+				today.DeadlineChecked = true
+				changed = true
+				// A window that opened before tracking began can't be judged fairly
+				if today.Completed == nil && !deadline.AddDate(0, 0, -1).Before(h.Since) {
+					today.DeadlineMissed = true
+					alerts = append(alerts, alert{
+						subject: fmt.Sprintf("[WARNING] Backup SLA Alert: %s", dataset),
+						body:    slaAlertBody(dataset, sla, h, fmt.Sprintf("Replication did not finish by %s.", sla.FinishBy)),
+					})
+				}
+			}
+		}
+
+		t.updateMetrics(dataset, sla, h, now)
+	}
+	if changed {
+		t.saveLocked()
+	}
+	t.mutex.Unlock()
+
+	for _, a := range alerts {
+		log.Printf("%s", a.subject)
+		if err := t.alerter.SendAlert(a.subject, a.body); err != nil {
+			log.Printf("Failed to send SLA alert: %v", err)
+		}
+	}
+}
+
+// Status returns the state of every configured SLA
+func (t *Tracker) Status() []Status {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	statuses := make([]Status, 0, len(t.config.SLAs))
+	for _, sla := range t.config.SLAs {
+		dataset := sla.DatasetOr(t.config.ZFS.Dataset)
+		local := now.In(location(sla))
+
+		status := Status{
+			Dataset:   dataset,
+			FinishBy:  sla.FinishBy,
+			Timezone:  sla.Timezone,
+			Compliant: true,
+		}
+		if sla.MaxAge > 0 {
+			status.MaxAge = sla.MaxAge.String()
+		}
+
+		if h, ok := t.datasets[dataset]; ok {
+			if !h.LastSuccess.IsZero() {
+				lastSuccess := h.LastSuccess
+				status.LastSuccess = &lastSuccess
+			}
+			if h.Stale {
+				status.Breaches = append(status.Breaches, fmt.Sprintf("no successful replication within %s", sla.MaxAge))
+			}
+			if today := h.find(local.Format(dateFormat)); today != nil && today.DeadlineMissed {
+				status.Breaches = append(status.Breaches, fmt.Sprintf("missed today's %s deadline", sla.FinishBy))
+			}
+			status.Compliant = len(status.Breaches) == 0
+			status.ThisMonth = h.compliance(local, local)
+			status.LastMonth = h.compliance(local.AddDate(0, 0, -local.Day()), local)
+		} else {
+			status.ThisMonth = Compliance{Month: local.Format("2006-01")}
+			status.LastMonth = Compliance{Month: local.AddDate(0, 0, -local.Day()).Format("2006-01")}
+		}
+
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Compliance returns the given month's compliance for dataset, for reports
+func (t *Tracker) Compliance(dataset string, month time.Time) Compliance {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	sla, _ := t.slaFor(dataset)
+	now := t.now().In(location(sla))
+	if h, ok := t.datasets[dataset]; ok {
+		return h.compliance(month.In(now.Location()), now)
+	}
+	return Compliance{Month: month.Format("2006-01")}
+}
+
+// Result is one dataset's SLA outcome for a day that has ended
+type Result struct {
+	Dataset   string     `json:"dataset"`
+	Date      string     `json:"date"` // YYYY-MM-DD in the SLA's timezone
+	Met       bool       `json:"met"`
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// Results returns the outcome of each completed day from the date of from up
+// to before the date of to, oldest first
+func (t *Tracker) Results(from, to time.Time) []Result {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	var results []Result
+	for _, sla := range t.config.SLAs {
+		dataset := sla.DatasetOr(t.config.ZFS.Dataset)
+		h, ok := t.datasets[dataset]
+		if !ok {
+			continue
+		}
+		loc := location(sla)
+		today := now.In(loc).Format(dateFormat)
+		first, last := from.In(loc).Format(dateFormat), to.In(loc).Format(dateFormat)
+		for _, day := range h.Days {
+			if day.Date < first || day.Date >= last || day.Date >= today {
+				continue
+			}
+			results = append(results, Result{Dataset: dataset, Date: day.Date, Met: day.Met(), Completed: day.Completed})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Date < results[j].Date })
+	return results
+}
+
+// Deadline is an upcoming finish_by check for a dataset
+type Deadline struct {
+	Dataset string    `json:"dataset"`
+	At      time.Time `json:"at"`
+}
+
+// Deadlines returns the finish_by deadlines between from and to
+func (t *Tracker) Deadlines(from, to time.Time) []Deadline {
+	var deadlines []Deadline
+	for _, sla := range t.config.SLAs {
+		minutes, ok := finishBy(sla)
+		if !ok {
+			continue
+		}
+		dataset := sla.DatasetOr(t.config.ZFS.Dataset)
+		for at := nextDeadline(from.In(location(sla)), minutes); at.Before(to); at = nextDeadline(at.Add(time.Minute), minutes) {
+			deadlines = append(deadlines, Deadline{Dataset: dataset, At: at})
+		}
+	}
+	sort.SliceStable(deadlines, func(i, j int) bool { return deadlines[i].At.Before(deadlines[j].At) })
+	return deadlines
+}
+
+func (t *Tracker) slaFor(dataset string) (config.SLAConfig, bool) {
+	for _, sla := range t.config.SLAs {
+		if sla.DatasetOr(t.config.ZFS.Dataset) == dataset {
+			return sla, true
+		}
+	}
+	return config.SLAConfig{}, false
+}
+
+// historyFor returns the dataset's history, starting it at now if there is none
+func (t *Tracker) historyFor(dataset string, now time.Time) *history {
+	h, ok := t.datasets[dataset]
+	if !ok {
+		h = &history{Since: now}
+		t.datasets[dataset] = h
+	}
+	return h
+}
+
+func (t *Tracker) updateMetrics(dataset string, sla config.SLAConfig, h *history, now time.Time) {
+	local := now.In(location(sla))
+
+	compliant := !h.Stale
+	if today := h.find(local.Format(dateFormat)); today != nil && today.DeadlineMissed {
+		compliant = false
+	}
+	if compliant {
+		compliantGauge.Set(1, dataset)
+	} else {
+		compliantGauge.Set(0, dataset)
+	}
+
+	if !h.LastSuccess.IsZero() {
+		lastSuccessGauge.Set(float64(h.LastSuccess.Unix()), dataset)
+	}
+	if month := h.compliance(local, local); month.Days > 0 {
+		monthComplianceGauge.Set(float64(month.Met)/float64(month.Days), dataset)
+	}
+}
+
+// saveLocked persists the tracker; callers hold the mutex
+func (t *Tracker) saveLocked() {
+	if t.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(t.path, t.datasets, 0600); err != nil {
+		log.Printf("Failed to save SLA state to %s: %v", t.path, err)
+	}
+}
+
+func (h *history) lastSuccessOrSince() time.Time {
+	if h.LastSuccess.IsZero() {
+		return h.Since
+	}
+	return h.LastSuccess
+}
+
+func (h *history) find(date string) *Day {
+	for _, day := range h.Days {
+		if day.Date == date {
+			return day
+		}
+	}
+	return nil
+}
+
+// day returns the result for date, adding it if needed
+func (h *history) day(date string) *Day {
+	if day := h.find(date); day != nil {
+		return day
+	}
+
+	day := &Day{Date: date}
+	h.Days = append(h.Days, day)
+	sort.Slice(h.Days, func(i, j int) bool { return h.Days[i].Date < h.Days[j].Date })
+	if len(h.Days) > maxDays {
+		h.Days = h.Days[len(h.Days)-maxDays:]
+	}
+	return day
+}
+
+// compliance counts the days of month that ended before today
+func (h *history) compliance(month, today time.Time) Compliance {
+	prefix := month.Format("2006-01")
+	todayDate := today.Format(dateFormat)
+
+	c := Compliance{Month: prefix}
+	for _, day := range h.Days {
+		if !strings.HasPrefix(day.Date, prefix+"-") || day.Date >= todayDate {
+			continue
+		}
+		c.Days++
+		if day.Met() {
+			c.Met++
+		}
+	}
+	if c.Days > 0 {
+		c.Percent = float64(c.Met) * 100 / float64(c.Days)
+	}
+	return c
+}
+
+func slaAlertBody(dataset string, sla config.SLAConfig, h *history, reason string) string {
+	lastSuccess := "never"
+	if !h.LastSuccess.IsZero() {
+		lastSuccess = h.LastSuccess.Format(time.RFC3339)
+	}
+
+	var objectives []string
+	if sla.FinishBy != "" {
+		objectives = append(objectives, fmt.Sprintf("finish by %s %s", sla.FinishBy, location(sla)))
+	}
+	if sla.MaxAge > 0 {
+		objectives = append(objectives, fmt.Sprintf("replicated at least every %s", sla.MaxAge))
+	}
+
+	return fmt.Sprintf(`Backup SLA Alert
+
+Dataset: %s
+Objective: %s
+Last Success: %s
+
+%s
+`, dataset, strings.Join(objectives, ", "), lastSuccess, reason)
+}
+
+// finishBy returns the SLA's deadline in minutes after midnight
+func finishBy(sla config.SLAConfig) (int, bool) {
+	if sla.FinishBy == "" {
+		return 0, false
+	}
+	minutes, err := config.ParseClock(sla.FinishBy)
+	if err != nil {
+		return 0, false // Validated at load
+	}
+	return minutes, true
+}
+
+func location(sla config.SLAConfig) *time.Location {
+	if sla.Timezone != "" {
+		if loc, err := time.LoadLocation(sla.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// deadlineOn returns the deadline on the day containing t, in t's location
+func deadlineOn(t time.Time, minutes int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), minutes/60, minutes%60, 0, 0, t.Location())
+}
+
+// nextDeadline returns the first deadline at or after t
+func nextDeadline(t time.Time, minutes int) time.Time {
+	deadline := deadlineOn(t, minutes)
+	if t.After(deadline) {
+		deadline = deadlineOn(t.AddDate(0, 0, 1), minutes)
+	}
+	return deadline
+}
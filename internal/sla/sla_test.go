@@ -0,0 +1,138 @@
+package sla
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+type recordingAlerter struct {
+	subjects []string
+	bodies   []string
+}
+
+func (a *recordingAlerter) SendAlert(subject, body string) error {
+	a.subjects = append(a.subjects, subject)
+	a.bodies = append(a.bodies, body)
+	return nil
+}
+
+func newTestTracker(t *testing.T, sla config.SLAConfig) (*Tracker, *recordingAlerter, *time.Time) {
+	cfg := &config.Config{
+		ZFS:  config.ZFSConfig{Dataset: "tank/data"},
+		SLAs: []config.SLAConfig{sla},
+	}
+	alerter := &recordingAlerter{}
+	tracker := New(cfg, filepath.Join(t.TempDir(), "sla.json"), alerter)
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	return tracker, alerter, &now
+}
+
+func TestFinishByDeadline(t *testing.T) {
+	tracker, alerter, now := newTestTracker(t, config.SLAConfig{FinishBy: "06:00", Timezone: "UTC"})
+
+	// Tracking starts mid-window, so March 1st's deadline isn't judged
+	tracker.Check()
+	*now = time.Date(2026, 3, 1, 6, 1, 0, 0, time.UTC)
+	tracker.Check()
+	if len(alerter.subjects) != 0 {
+		t.Fatalf("Expected no alert for a window that began before tracking, got %v", alerter.subjects)
+	}
+
+	// 07:00 on the 1st counts towards the 2nd's deadline
+	tracker.RecordSuccess("tank/data", time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC))
+	*now = time.Date(2026, 3, 2, 6, 1, 0, 0, time.UTC)
+	tracker.Check()
+	if len(alerter.subjects) != 0 {
+		t.Fatalf("Expected deadline to be met, got %v", alerter.subjects)
+	}
+
+	*now = time.Date(2026, 3, 3, 6, 1, 0, 0, time.UTC)
+	tracker.Check()
+	tracker.Check()
+	if len(alerter.subjects) != 1 || alerter.subjects[0] != "[WARNING] Backup SLA Alert: tank/data" {
+		t.Fatalf("Expected one missed deadline alert, got %v", alerter.subjects)
+	}
+	if !strings.Contains(alerter.bodies[0], "did not finish by 06:00") {
+		t.Errorf("Unexpected alert body: %s", alerter.bodies[0])
+	}
+
+	status := tracker.Status()[0]
+	if status.Compliant || len(status.Breaches) != 1 {
+		t.Errorf("Expected a breach today, got %+v", status)
+	}
+
+	*now = time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	march := tracker.Compliance("tank/data", *now)
+	if march.Month != "2026-03" || march.Days != 3 || march.Met != 2 {
+		t.Errorf("Expected 2 of 3 days met in March, got %+v", march)
+	}
+}
+
+func TestResultsAndDeadlines(t *testing.T) {
+	tracker, _, now := newTestTracker(t, config.SLAConfig{FinishBy: "06:00", Timezone: "UTC"})
+
+	tracker.Check()
+	*now = time.Date(2026, 3, 2, 6, 1, 0, 0, time.UTC)
+	tracker.Check()
+	*now = time.Date(2026, 3, 3, 6, 1, 0, 0, time.UTC)
+	tracker.Check()
+
+	// The 3rd hasn't ended, so only the 1st and 2nd have results
+	results := tracker.Results(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	if len(results) != 2 || results[0].Date != "2026-03-01" || !results[0].Met || results[1].Met {
+		t.Errorf("Expected the 1st met and the 2nd missed, got %+v", results)
+	}
+	if results := tracker.Results(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)); len(results) != 1 {
+		t.Errorf("Expected only the 2nd, got %+v", results)
+	}
+
+	deadlines := tracker.Deadlines(*now, now.Add(48*time.Hour))
+	if len(deadlines) != 2 || !deadlines[0].At.Equal(time.Date(2026, 3, 4, 6, 0, 0, 0, time.UTC)) || deadlines[0].Dataset != "tank/data" {
+		t.Errorf("Expected the next two deadlines from the 4th, got %+v", deadlines)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	tracker, alerter, now := newTestTracker(t, config.SLAConfig{MaxAge: 24 * time.Hour, Timezone: "UTC"})
+
+	tracker.RecordSuccess("tank/data", *now)
+	*now = now.Add(23 * time.Hour)
+	tracker.Check()
+	if len(alerter.subjects) != 0 {
+		t.Fatalf("Expected no alert within max_age, got %v", alerter.subjects)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	tracker.Check()
+	*now = now.Add(time.Hour)
+	tracker.Check()
+	if len(alerter.subjects) != 1 {
+		t.Fatalf("Expected one alert per breach, got %v", alerter.subjects)
+	}
+	if tracker.Status()[0].Compliant {
+		t.Error("Expected SLA to be breached")
+	}
+
+	tracker.RecordSuccess("tank/data", *now)
+	if status := tracker.Status()[0]; !status.Compliant {
+		t.Errorf("Expected success to clear the breach, got %+v", status)
+	}
+}
+
+func TestStatePersists(t *testing.T) {
+	tracker, _, now := newTestTracker(t, config.SLAConfig{FinishBy: "06:00", Timezone: "UTC"})
+	tracker.RecordSuccess("tank/data", *now)
+
+	reopened := New(tracker.config, tracker.path, &recordingAlerter{})
+	reopened.now = tracker.now
+	status := reopened.Status()[0]
+	if status.LastSuccess == nil || !status.LastSuccess.Equal(*now) {
+		t.Errorf("Expected last success to survive a restart, got %+v", status)
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"net/http"
 	"strings"
 
+	"zfsrabbit/internal/audit"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
 	"zfsrabbit/internal/monitor"
 	"zfsrabbit/internal/restore"
 	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/silence"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/version"
 	"zfsrabbit/internal/zfs"
 )
 
@@ -21,6 +25,10 @@ type CommandHandler struct {
 	zfsManager     *zfs.Manager
 	restoreManager *restore.RestoreManager
 	transport      *transport.SSHTransport
+
+	restoreRequests *restore.Requests
+	silences        *silence.Store
+	apiURL          string // Slack Web API base URL
 }
 
 type SlashCommandRequest struct {
@@ -34,6 +42,7 @@ type SlashCommandRequest struct {
 	Command     string `json:"command"`
 	Text        string `json:"text"`
 	ResponseURL string `json:"response_url"`
+	TriggerID   string `json:"trigger_id"`
 }
 
 type SlashCommandResponse struct {
@@ -73,17 +82,50 @@ func NewCommandHandler(cfg *config.SlackConfig, sched *scheduler.Scheduler, mon
 		zfsManager:     zfsMgr,
 		restoreManager: restoreMgr,
 		transport:      transport,
+		apiURL:         "https://slack.com/api",
 	}
 }
 
-func (h *CommandHandler) HandleSlashCommand(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// SetRestoreRequests enables the request/approve/reject commands
+func (h *CommandHandler) SetRestoreRequests(requests *restore.Requests) {
+	h.restoreRequests = requests
+}
+
+// isAdmin reports whether a Slack user may restore and decide requests
+func (h *CommandHandler) isAdmin(req SlashCommandRequest) bool {
+	return h.config.SlackRole(req.UserName, req.UserID) == config.RoleAdmin
+}
+
+// commandRoles is the least role each command needs, viewer if not listed.
+// restore, approve and reject check for admins themselves so they can point
+// others at the request workflow.
+var commandRoles = map[string]string{
+	"help":      config.RoleRequester,
+	"request":   config.RoleRequester,
+	"requests":  config.RoleRequester,
+	"restore":   config.RoleRequester,
+	"approve":   config.RoleRequester,
+	"reject":    config.RoleRequester,
+	"snapshot":  config.RoleOperator,
+	"scrub":     config.RoleOperator,
+	"backfill":  config.RoleOperator,
+	"silence":   config.RoleOperator,
+	"unsilence": config.RoleOperator,
+	"migrate":   config.RoleAdmin,
+}
+
+func commandRole(command string, args []string) string {
+	if command == "migrate" && len(args) > 1 && args[1] == "status" {
+		return config.RoleViewer
+	}
+	if role, ok := commandRoles[command]; ok {
+		return role
 	}
+	return config.RoleViewer
+}
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+func (h *CommandHandler) HandleSlashCommand(w http.ResponseWriter, r *http.Request) {
+	if !h.readForm(w, r, func() string { return r.FormValue("token") }) {
 		return
 	}
 
@@ -98,12 +140,7 @@ func (h *CommandHandler) HandleSlashCommand(w http.ResponseWriter, r *http.Reque
 		Command:     r.FormValue("command"),
 		Text:        r.FormValue("text"),
 		ResponseURL: r.FormValue("response_url"),
-	}
-
-	// Verify token
-	if req.Token != h.config.SlashToken {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+		TriggerID:   r.FormValue("trigger_id"),
 	}
 
 	response := h.processCommand(req)
@@ -120,6 +157,13 @@ func (h *CommandHandler) processCommand(req SlashCommandRequest) SlashCommandRes
 
 	command := strings.ToLower(args[0])
 
+	if required := commandRole(command, args); !config.RoleAllows(h.config.SlackRole(req.UserName, req.UserID), required) {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("You need the %s role to use `%s`.", required, command),
+		}
+	}
+
 	switch command {
 	case "status":
 		return h.getSystemStatus()
@@ -127,6 +171,12 @@ func (h *CommandHandler) processCommand(req SlashCommandRequest) SlashCommandRes
 		return h.triggerSnapshot()
 	case "scrub":
 		return h.triggerScrub()
+	case "backfill":
+		job := ""
+		if len(args) > 1 {
+			job = args[1]
+		}
+		return h.triggerBackfill(job)
 	case "snapshots":
 		return h.listSnapshots()
 	case "pools":
@@ -134,13 +184,66 @@ func (h *CommandHandler) processCommand(req SlashCommandRequest) SlashCommandRes
 	case "disks":
 		return h.getDiskStatus()
 	case "restore":
+		if !h.isAdmin(req) {
+			return SlashCommandResponse{
+				ResponseType: "ephemeral",
+				Text:         "You are not allowed to restore directly. Use `request <snapshot> <dataset> [mountpoint]` to ask an admin.",
+			}
+		}
+		if len(args) == 1 {
+			return h.openRestoreModal(req)
+		}
+		if len(args) < 3 {
+			return SlashCommandResponse{
+				ResponseType: "ephemeral",
+				Text:         "Usage: restore <snapshot_name> <target_dataset> [mountpoint], or restore alone for a dialog",
+			}
+		}
+		mountpoint := ""
+		if len(args) > 3 {
+			mountpoint = args[3]
+		}
+		return h.triggerRestore(req, args[1], args[2], mountpoint)
+	case "request":
 		if len(args) < 3 {
 			return SlashCommandResponse{
 				ResponseType: "ephemeral",
-				Text:         "Usage: restore <snapshot_name> <target_dataset>",
+				Text:         "Usage: request <snapshot_name> <target_dataset> [mountpoint]",
+			}
+		}
+		mountpoint := ""
+		if len(args) > 3 {
+			mountpoint = args[3]
+		}
+		return h.requestRestore(req, args[1], args[2], mountpoint)
+	case "requests":
+		return h.listRestoreRequests(req)
+	case "approve", "reject":
+		if !h.isAdmin(req) {
+			return SlashCommandResponse{
+				ResponseType: "ephemeral",
+				Text:         "Only admins can approve or reject restore requests.",
 			}
 		}
-		return h.triggerRestore(args[1], args[2])
+		if len(args) < 2 {
+			return SlashCommandResponse{
+				ResponseType: "ephemeral",
+				Text:         fmt.Sprintf("Usage: %s <request_id> [note]", command),
+			}
+		}
+		return h.decideRestoreRequest(req, command, args[1], strings.Join(args[2:], " "))
+	case "silence":
+		return h.silenceAlerts(req, args)
+	case "silences":
+		return h.listSilences()
+	case "unsilence":
+		if len(args) < 2 {
+			return SlashCommandResponse{
+				ResponseType: "ephemeral",
+				Text:         "Usage: unsilence <silence_id>",
+			}
+		}
+		return h.unsilence(req, args[1])
 	case "jobs":
 		return h.getRestoreJobs()
 	case "remote":
@@ -185,6 +288,8 @@ func (h *CommandHandler) processCommand(req SlashCommandRequest) SlashCommandRes
 				Text:         "Usage: `migrate start|status|cutover`",
 			}
 		}
+	case "version":
+		return h.showVersion()
 	case "help":
 		return h.showHelp()
 	default:
@@ -201,16 +306,26 @@ func (h *CommandHandler) showHelp() SlashCommandResponse {
 • *status* - Show overall system status
 • *snapshot* - Create a new snapshot immediately
 • *scrub* - Start ZFS pool scrub
+• *backfill [job]* - Send snapshots a target missed while it was offline
 • *snapshots* - List recent snapshots
 • *pools* - Show ZFS pool status
 • *disks* - Show disk health status
-• *restore <snapshot> <dataset>* - Restore a snapshot
+• *restore <snapshot> <dataset> [mountpoint]* - Restore a snapshot, optionally mounting it
+• *restore* - Pick the dataset, snapshot and target in a dialog
+• *request <snapshot> <dataset> [mountpoint]* - Ask an admin to approve a restore
+• *requests* - Show restore requests
+• *approve <request-id> [note]* - Approve a restore request and start it
+• *reject <request-id> [note]* - Reject a restore request
+• *silence all|pool <name>|device <name> <duration> [reason]* - Hold back alerts during maintenance
+• *silences* - Show active alert silences
+• *unsilence <silence-id>* - End a silence early
 • *jobs* - Show active restore jobs
 • *remote* - Show all remote datasets
 • *browse <dataset>* - Browse snapshots in a remote dataset
 • *migrate start <source> <host> <target>* - Start workload migration
 • *migrate status* - Show migration progress
 • *migrate cutover <job-id>* - Complete migration cutover
+• *version* - Show the running version, commit and build date
 • *help* - Show this help message
 
 Example: ` + "`/zfsrabbit status`"
@@ -262,6 +377,24 @@ func (h *CommandHandler) getSystemStatus() SlashCommandResponse {
 		},
 	})
 
+	if pairs := h.scheduler.Health(); len(pairs) > 0 {
+		lines := []string{"*Replication Health:*"}
+		for _, pair := range pairs {
+			line := fmt.Sprintf("%s %d `%s` → %s", pair.Emoji, pair.Score, pair.Dataset, pair.Target)
+			if len(pair.Issues) > 0 {
+				line += ": " + strings.Join(pair.Issues, "; ")
+			}
+			lines = append(lines, line)
+		}
+		blocks = append(blocks, SlackBlock{
+			Type: "section",
+			Text: &SlackText{
+				Type: "mrkdwn",
+				Text: strings.Join(lines, "\n"),
+			},
+		})
+	}
+
 	return SlashCommandResponse{
 		ResponseType: "in_channel",
 		Blocks:       blocks,
@@ -282,6 +415,48 @@ func (h *CommandHandler) triggerSnapshot() SlashCommandResponse {
 	}
 }
 
+func (h *CommandHandler) triggerBackfill(job string) SlashCommandResponse {
+	gaps, err := h.scheduler.BackfillGaps(job)
+	if err != nil {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("❌ Failed to check for missing snapshots: %s", err.Error()),
+		}
+	}
+
+	lines := []string{}
+	missing := 0
+	for _, gap := range gaps {
+		switch {
+		case gap.Blocker != "":
+			lines = append(lines, fmt.Sprintf("⚠️ `%s` → %s: %s", gap.Dataset, gap.Target, gap.Blocker))
+		case len(gap.Missing) == 0:
+			lines = append(lines, fmt.Sprintf("✅ `%s` → %s: nothing missing", gap.Dataset, gap.Target))
+		default:
+			missing += len(gap.Missing)
+			lines = append(lines, fmt.Sprintf("🔁 `%s` → %s: %d snapshots after `%s`", gap.Dataset, gap.Target, len(gap.Missing), gap.From))
+		}
+	}
+	if missing == 0 {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         "No snapshots to backfill.\n" + strings.Join(lines, "\n"),
+		}
+	}
+
+	if err := h.scheduler.TriggerBackfill(job); err != nil {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("❌ Failed to start backfill: %s", err.Error()),
+		}
+	}
+
+	return SlashCommandResponse{
+		ResponseType: "in_channel",
+		Text:         "📦 Backfill started:\n" + strings.Join(lines, "\n"),
+	}
+}
+
 func (h *CommandHandler) triggerScrub() SlashCommandResponse {
 	if err := h.scheduler.TriggerScrub(); err != nil {
 		return SlashCommandResponse{
@@ -296,6 +471,20 @@ func (h *CommandHandler) triggerScrub() SlashCommandResponse {
 	}
 }
 
+func (h *CommandHandler) showVersion() SlashCommandResponse {
+	info := version.Get()
+	text := fmt.Sprintf("🐇 ZFSRabbit %s", info.Short())
+	if info.BuildDate != "" {
+		text += fmt.Sprintf(", built %s", info.BuildDate)
+	}
+	text += fmt.Sprintf(" with %s", info.GoVersion)
+
+	return SlashCommandResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+	}
+}
+
 func (h *CommandHandler) listSnapshots() SlashCommandResponse {
 	snapshots, err := h.zfsManager.ListSnapshots()
 	if err != nil {
@@ -323,7 +512,7 @@ func (h *CommandHandler) listSnapshots() SlashCommandResponse {
 		snap := snapshots[i]
 		text += fmt.Sprintf("• `%s` - %s (%s)\n",
 			snap.Name,
-			snap.Created.Format("Jan 02 15:04"),
+			display.ShortTime(snap.Created),
 			snap.Used)
 	}
 
@@ -405,8 +594,8 @@ func (h *CommandHandler) getDiskStatus() SlashCommandResponse {
 
 			fields = append(fields, SlackField{
 				Type: "mrkdwn",
-				Text: fmt.Sprintf("*%s %s:*\n%s %d°C", emoji, disk,
-					map[bool]string{true: "Healthy", false: "Issues"}[healthy], temp),
+				Text: fmt.Sprintf("*%s %s:*\n%s %s", emoji, disk,
+					map[bool]string{true: "Healthy", false: "Issues"}[healthy], display.Temperature(temp)),
 				Short: true,
 			})
 		}
@@ -423,21 +612,140 @@ func (h *CommandHandler) getDiskStatus() SlashCommandResponse {
 	}
 }
 
-func (h *CommandHandler) triggerRestore(snapshot, dataset string) SlashCommandResponse {
-	job, err := h.restoreManager.StartRestoreWithTracking(snapshot, dataset)
+func (h *CommandHandler) triggerRestore(req SlashCommandRequest, snapshot, dataset, mountpoint string) SlashCommandResponse {
+	job, err := h.restoreManager.StartRestoreWithOptions("", snapshot, dataset, restore.MountOptions{Mountpoint: mountpoint})
 	if err != nil {
+		recordSlackAction(req, "restore "+snapshot, "failed")
 		return SlashCommandResponse{
 			ResponseType: "ephemeral",
 			Text:         fmt.Sprintf("❌ Failed to start restore: %s", err.Error()),
 		}
 	}
 
+	recordSlackAction(req, "restore "+snapshot, "success")
+
+	return SlashCommandResponse{
+		ResponseType: "in_channel",
+		Text:         restoreStartedText(job, snapshot, dataset, mountpoint),
+	}
+}
+
+func restoreStartedText(job *restore.RestoreJob, snapshot, dataset, mountpoint string) string {
+	text := fmt.Sprintf("🔄 Restore job `%s` started!\nRestoring `%s` to `%s`", job.ID, snapshot, dataset)
+	if mountpoint != "" {
+		text += fmt.Sprintf(", mounting at `%s`", mountpoint)
+	}
+	return text
+}
+
+func (h *CommandHandler) requestRestore(req SlashCommandRequest, snapshot, dataset, mountpoint string) SlashCommandResponse {
+	if h.restoreRequests == nil {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "Restore requests are not enabled."}
+	}
+
+	created, err := h.restoreRequests.Create(restore.Request{
+		Snapshot:      snapshot,
+		TargetDataset: dataset,
+		Mountpoint:    mountpoint,
+		RequestedBy:   slackActor(req),
+	})
+	if err != nil {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("❌ Failed to create restore request: %s", err.Error()),
+		}
+	}
+	recordSlackAction(req, "restore_requested "+created.ID, "success")
+
+	return SlashCommandResponse{
+		ResponseType: "in_channel",
+		Text: fmt.Sprintf("📝 Restore request `%s` created: `%s` to `%s`. Waiting for an admin to `approve %s` or `reject %s`.",
+			created.ID, snapshot, dataset, created.ID, created.ID),
+	}
+}
+
+func (h *CommandHandler) listRestoreRequests(req SlashCommandRequest) SlashCommandResponse {
+	if h.restoreRequests == nil {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "Restore requests are not enabled."}
+	}
+
+	requester := slackActor(req)
+	if h.isAdmin(req) {
+		requester = ""
+	}
+	requests := h.restoreRequests.List(requester)
+	if len(requests) == 0 {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "No restore requests."}
+	}
+
+	text := "*Restore Requests:*\n"
+	for _, request := range requests {
+		emoji := "⏳"
+		switch request.Status {
+		case restore.RequestApproved:
+			emoji = "✅"
+		case restore.RequestRejected:
+			emoji = "🚫"
+		}
+		text += fmt.Sprintf("• %s `%s` - %s `%s` to `%s` by %s\n",
+			emoji, request.ID, request.Status, request.Snapshot, request.TargetDataset, request.RequestedBy)
+		if request.JobID != "" {
+			text += fmt.Sprintf("  Job: `%s`\n", request.JobID)
+		}
+		if request.Note != "" {
+			text += fmt.Sprintf("  Note: %s\n", request.Note)
+		}
+	}
+
+	return SlashCommandResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+	}
+}
+
+func (h *CommandHandler) decideRestoreRequest(req SlashCommandRequest, action, id, note string) SlashCommandResponse {
+	if h.restoreRequests == nil {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "Restore requests are not enabled."}
+	}
+
+	var decided restore.Request
+	var err error
+	if action == "approve" {
+		decided, err = h.restoreRequests.Approve(id, slackActor(req), note)
+	} else {
+		decided, err = h.restoreRequests.Reject(id, slackActor(req), note)
+	}
+	if err != nil {
+		recordSlackAction(req, "restore_request_"+action+" "+id, "failed")
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("❌ Failed to %s restore request: %s", action, err.Error()),
+		}
+	}
+	recordSlackAction(req, "restore_request_"+decided.Status+" "+id, "success")
+
+	text := fmt.Sprintf("🚫 Restore request `%s` rejected", id)
+	if decided.Status == restore.RequestApproved {
+		text = fmt.Sprintf("✅ Restore request `%s` approved, restore job `%s` started", id, decided.JobID)
+	}
 	return SlashCommandResponse{
 		ResponseType: "in_channel",
-		Text:         fmt.Sprintf("🔄 Restore job `%s` started!\nRestoring `%s` to `%s`", job.ID, snapshot, dataset),
+		Text:         text,
 	}
 }
 
+// slackActor names a Slack user in requests and the audit log
+func slackActor(req SlashCommandRequest) string {
+	if req.UserName != "" {
+		return "slack:" + req.UserName
+	}
+	return "slack:" + req.UserID
+}
+
+func recordSlackAction(req SlashCommandRequest, action, outcome string) {
+	audit.Record(audit.Event{Actor: slackActor(req), Action: action, Remote: "slack", Outcome: outcome})
+}
+
 func (h *CommandHandler) getRestoreJobs() SlashCommandResponse {
 	jobs := h.restoreManager.ListJobs()
 
@@ -464,6 +772,9 @@ func (h *CommandHandler) getRestoreJobs() SlashCommandResponse {
 		} else {
 			text += fmt.Sprintf("• %s `%s` - %s (%d%%)\n", emoji, job.ID, job.Status, job.Progress)
 		}
+		if job.MountedAt != "" {
+			text += fmt.Sprintf("  Mounted at: `%s`\n", job.MountedAt)
+		}
 		if job.Error != nil {
 			text += fmt.Sprintf("  Error: %s\n", job.Error.Error())
 		}
@@ -1,6 +1,8 @@
 package slack
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -12,6 +14,7 @@ import (
 	"zfsrabbit/internal/monitor"
 	"zfsrabbit/internal/restore"
 	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/silence"
 	"zfsrabbit/internal/transport"
 	"zfsrabbit/internal/zfs"
 	"zfsrabbit/test/mocks"
@@ -24,6 +27,10 @@ func (m *MockZFSExecutor) Command(name string, args ...string) *exec.Cmd {
 	return exec.Command("echo", "mock")
 }
 
+func (m *MockZFSExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return m.Command(name, args...)
+}
+
 func (m *MockZFSExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
 	return []byte(""), nil
 }
@@ -217,6 +224,29 @@ func TestSlackCommandsScrub(t *testing.T) {
 	}
 }
 
+func TestSlackCommandsVersion(t *testing.T) {
+	handler := createTestHandler(t)
+
+	data := url.Values{}
+	data.Set("token", "test-token")
+	data.Set("command", "/zfsrabbit")
+	data.Set("text", "version")
+
+	req := httptest.NewRequest("POST", "/slack/command", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	handler.HandleSlashCommand(w, req)
+
+	var response SlashCommandResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.Contains(response.Text, "ZFSRabbit dev") || !strings.Contains(response.Text, "go1.") {
+		t.Errorf("Expected the version and Go version, got %q", response.Text)
+	}
+}
+
 func TestSlackCommandsJobs(t *testing.T) {
 	handler := createTestHandler(t)
 
@@ -242,6 +272,122 @@ func TestSlackCommandsJobs(t *testing.T) {
 	}
 }
 
+func TestSlackCommandsRestoreRequests(t *testing.T) {
+	handler := createTestHandler(t)
+	handler.config.AdminUsers = []string{"boss"}
+	handler.SetRestoreRequests(restore.NewRequests(handler.restoreManager, "", nil))
+
+	user := SlashCommandRequest{UserID: "U1", UserName: "alice"}
+	admin := SlashCommandRequest{UserID: "U2", UserName: "boss"}
+
+	resp := handler.processCommand(SlashCommandRequest{UserName: "alice", Text: "restore autosnap_2026-01-01_02-00-00 tank/restored"})
+	if !strings.Contains(resp.Text, "not allowed") {
+		t.Errorf("Expected non-admin restore to be refused, got %q", resp.Text)
+	}
+
+	user.Text = "request autosnap_2026-01-01_02-00-00 tank/restored"
+	resp = handler.processCommand(user)
+	if !strings.Contains(resp.Text, "created") {
+		t.Fatalf("Expected request to be created, got %q", resp.Text)
+	}
+
+	requests := handler.restoreRequests.List("")
+	if len(requests) != 1 || requests[0].RequestedBy != "slack:alice" {
+		t.Fatalf("Unexpected requests: %+v", requests)
+	}
+	id := requests[0].ID
+
+	user.Text = "reject " + id
+	if resp = handler.processCommand(user); !strings.Contains(resp.Text, "Only admins") {
+		t.Errorf("Expected non-admin reject to be refused, got %q", resp.Text)
+	}
+
+	admin.Text = "reject " + id + " not needed"
+	if resp = handler.processCommand(admin); !strings.Contains(resp.Text, "rejected") {
+		t.Errorf("Expected admin to reject, got %q", resp.Text)
+	}
+
+	got, _ := handler.restoreRequests.Get(id)
+	if got.Status != restore.RequestRejected || got.DecidedBy != "slack:boss" || got.Note != "not needed" {
+		t.Errorf("Unexpected decided request: %+v", got)
+	}
+}
+
+func TestSlackCommandsSilences(t *testing.T) {
+	handler := createTestHandler(t)
+	handler.SetSilences(silence.New(""))
+
+	req := SlashCommandRequest{UserID: "U1", UserName: "alice"}
+
+	req.Text = "silence pool tank"
+	if resp := handler.processCommand(req); !strings.Contains(resp.Text, "Usage") {
+		t.Errorf("Expected usage without a duration, got %q", resp.Text)
+	}
+
+	req.Text = "silence pool tank 2h swapping a disk"
+	if resp := handler.processCommand(req); !strings.Contains(resp.Text, "pool tank silenced") {
+		t.Fatalf("Expected pool to be silenced, got %q", resp.Text)
+	}
+	active := handler.silences.Active()
+	if len(active) != 1 || active[0].By != "slack:alice" || active[0].Reason != "swapping a disk" {
+		t.Fatalf("Unexpected silences: %+v", active)
+	}
+
+	req.Text = "silences"
+	if resp := handler.processCommand(req); !strings.Contains(resp.Text, active[0].ID) {
+		t.Errorf("Expected silences to list %s, got %q", active[0].ID, resp.Text)
+	}
+
+	req.Text = "unsilence " + active[0].ID
+	if resp := handler.processCommand(req); !strings.Contains(resp.Text, "ended") {
+		t.Errorf("Expected silence to end, got %q", resp.Text)
+	}
+	if len(handler.silences.Active()) != 0 {
+		t.Error("Expected no silences after unsilence")
+	}
+
+	handler.config.Roles = map[string]string{"U2": config.RoleViewer}
+	viewer := SlashCommandRequest{UserID: "U2", UserName: "vera", Text: "silence all 1h"}
+	if resp := handler.processCommand(viewer); !strings.Contains(resp.Text, "operator role") {
+		t.Errorf("Expected viewer silence to be refused, got %q", resp.Text)
+	}
+}
+
+func TestSlackCommandRoles(t *testing.T) {
+	handler := createTestHandler(t)
+	handler.config.Roles = map[string]string{
+		"U1": config.RoleViewer,
+		"U2": config.RoleOperator,
+		"U3": config.RoleRequester,
+	}
+
+	viewer := SlashCommandRequest{UserID: "U1", UserName: "vera", Text: "scrub"}
+	if resp := handler.processCommand(viewer); !strings.Contains(resp.Text, "operator role") {
+		t.Errorf("Expected viewer scrub to be refused, got %q", resp.Text)
+	}
+
+	requester := SlashCommandRequest{UserID: "U3", UserName: "rita", Text: "status"}
+	if resp := handler.processCommand(requester); !strings.Contains(resp.Text, "viewer role") {
+		t.Errorf("Expected requester status to be refused, got %q", resp.Text)
+	}
+
+	operator := SlashCommandRequest{UserID: "U2", UserName: "otto", Text: "migrate start tank/data host tank/data"}
+	if resp := handler.processCommand(operator); !strings.Contains(resp.Text, "admin role") {
+		t.Errorf("Expected operator migrate to be refused, got %q", resp.Text)
+	}
+	operator.Text = "restore autosnap_2026-01-01_02-00-00 tank/restored"
+	if resp := handler.processCommand(operator); !strings.Contains(resp.Text, "not allowed") {
+		t.Errorf("Expected operator restore to be refused, got %q", resp.Text)
+	}
+
+	// Unmapped users fall back to the default role
+	handler.config.DefaultRole = config.RoleViewer
+	stranger := SlashCommandRequest{UserID: "U9", UserName: "sam", Text: "snapshot"}
+	if resp := handler.processCommand(stranger); !strings.Contains(resp.Text, "operator role") {
+		t.Errorf("Expected default viewer snapshot to be refused, got %q", resp.Text)
+	}
+}
+
 func TestSlackCommandsUnknown(t *testing.T) {
 	handler := createTestHandler(t)
 
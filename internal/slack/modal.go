@@ -0,0 +1,365 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"zfsrabbit/internal/restore"
+	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/version"
+)
+
+const (
+	// restoreModalID is the callback_id of the restore dialog
+	restoreModalID = "restore_modal"
+
+	// Block IDs of the restore dialog; each block's element uses the same action_id
+	blockDataset    = "dataset"
+	blockSnapshot   = "snapshot"
+	blockTarget     = "target"
+	blockMountpoint = "mountpoint"
+
+	// maxOptions is the most options Slack accepts in an options response
+	maxOptions = 100
+)
+
+// interactionPayload is the JSON in the "payload" form field of Slack
+// interactivity and options load requests
+type interactionPayload struct {
+	Type      string `json:"type"` // view_submission or block_suggestion
+	Token     string `json:"token"`
+	TriggerID string `json:"trigger_id"`
+	User      struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ActionID string `json:"action_id"` // block_suggestion only
+	Value    string `json:"value"`     // Text typed so far, block_suggestion only
+	View     struct {
+		CallbackID      string `json:"callback_id"`
+		PrivateMetadata string `json:"private_metadata"`
+		State           struct {
+			Values map[string]map[string]blockState `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+}
+
+type blockState struct {
+	Value          string       `json:"value"`
+	SelectedOption *SlackOption `json:"selected_option"`
+}
+
+// SlackOption is one choice of a select menu
+type SlackOption struct {
+	Text  SlackText `json:"text"`
+	Value string    `json:"value"`
+}
+
+// modalMetadata is carried through the dialog so the result can be posted
+// where the command was typed
+type modalMetadata struct {
+	ChannelID   string `json:"channel_id"`
+	ResponseURL string `json:"response_url"`
+}
+
+// value returns what was entered or selected in a block of the dialog
+func (p interactionPayload) value(block string) string {
+	state := p.View.State.Values[block][block]
+	if state.SelectedOption != nil {
+		return state.SelectedOption.Value
+	}
+	return strings.TrimSpace(state.Value)
+}
+
+func (p interactionPayload) request() SlashCommandRequest {
+	var metadata modalMetadata
+	json.Unmarshal([]byte(p.View.PrivateMetadata), &metadata)
+	return SlashCommandRequest{
+		UserID:      p.User.ID,
+		UserName:    p.User.Username,
+		ChannelID:   metadata.ChannelID,
+		ResponseURL: metadata.ResponseURL,
+	}
+}
+
+// parsePayload reads and authenticates an interactivity or options request
+func (h *CommandHandler) parsePayload(w http.ResponseWriter, r *http.Request) (interactionPayload, bool) {
+	var payload interactionPayload
+	var parseErr error
+	ok := h.readForm(w, r, func() string {
+		parseErr = json.Unmarshal([]byte(r.FormValue("payload")), &payload)
+		return payload.Token
+	})
+	if !ok {
+		return payload, false
+	}
+	if parseErr != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return payload, false
+	}
+	return payload, true
+}
+
+// openRestoreModal shows the restore dialog for `/zfsrabbit restore`
+// without arguments
+func (h *CommandHandler) openRestoreModal(req SlashCommandRequest) SlashCommandResponse {
+	if h.config.BotToken == "" || req.TriggerID == "" {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         "Usage: restore <snapshot_name> <target_dataset> [mountpoint]",
+		}
+	}
+
+	metadata, _ := json.Marshal(modalMetadata{ChannelID: req.ChannelID, ResponseURL: req.ResponseURL})
+	err := h.callAPI("views.open", map[string]interface{}{
+		"trigger_id": req.TriggerID,
+		"view":       restoreModal(string(metadata), h.transport.RemoteDataset()),
+	})
+	if err != nil {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("❌ Failed to open the restore dialog: %s", err.Error()),
+		}
+	}
+	return SlashCommandResponse{ResponseType: "ephemeral", Text: "Opening the restore dialog..."}
+}
+
+func restoreModal(metadata, defaultDataset string) map[string]interface{} {
+	datasetSelect := map[string]interface{}{
+		"type":             "external_select",
+		"action_id":        blockDataset,
+		"placeholder":      plainText("Remote dataset"),
+		"min_query_length": 0,
+	}
+	if defaultDataset != "" {
+		datasetSelect["initial_option"] = option(defaultDataset)
+	}
+
+	return map[string]interface{}{
+		"type":             "modal",
+		"callback_id":      restoreModalID,
+		"private_metadata": metadata,
+		"title":            plainText("Restore Snapshot"),
+		"submit":           plainText("Restore"),
+		"close":            plainText("Cancel"),
+		"blocks": []map[string]interface{}{
+			inputBlock(blockDataset, "Dataset", datasetSelect, false),
+			inputBlock(blockSnapshot, "Snapshot", map[string]interface{}{
+				"type":             "external_select",
+				"action_id":        blockSnapshot,
+				"placeholder":      plainText("Newest first, type to filter"),
+				"min_query_length": 0,
+			}, false),
+			inputBlock(blockTarget, "Target dataset", map[string]interface{}{
+				"type":        "plain_text_input",
+				"action_id":   blockTarget,
+				"placeholder": plainText("e.g. tank/restored"),
+			}, false),
+			inputBlock(blockMountpoint, "Mountpoint", map[string]interface{}{
+				"type":        "plain_text_input",
+				"action_id":   blockMountpoint,
+				"placeholder": plainText("Leave empty to keep the dataset's own"),
+			}, true),
+		},
+	}
+}
+
+func inputBlock(id, label string, element map[string]interface{}, optional bool) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "input",
+		"block_id": id,
+		"label":    plainText(label),
+		"element":  element,
+		"optional": optional,
+	}
+}
+
+func plainText(text string) SlackText {
+	return SlackText{Type: "plain_text", Text: text}
+}
+
+func option(value string) SlackOption {
+	text := value
+	// Slack rejects option text over 75 characters; keep the distinctive end
+	if len(text) > 75 {
+		text = "..." + text[len(text)-72:]
+	}
+	return SlackOption{Text: plainText(text), Value: value}
+}
+
+// callAPI calls a Slack Web API method with the bot token
+func (h *CommandHandler) callAPI(method string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", h.apiURL+"/"+method, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+h.config.BotToken)
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s returned status %d", method, resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("%s: %s", method, result.Error)
+	}
+	return nil
+}
+
+// HandleOptions serves the dataset and snapshot choices of the restore
+// dialog as the user types (Slack's options load URL)
+func (h *CommandHandler) HandleOptions(w http.ResponseWriter, r *http.Request) {
+	payload, ok := h.parsePayload(w, r)
+	if !ok {
+		return
+	}
+
+	var choices []string
+	switch payload.ActionID {
+	case blockDataset:
+		datasets, err := h.transport.ListAllRemoteDatasets()
+		if err != nil {
+			log.Printf("Slack options: failed to list remote datasets: %v", err)
+		}
+		for dataset := range datasets {
+			choices = append(choices, dataset)
+		}
+		sort.Strings(choices)
+
+	case blockSnapshot:
+		dataset := payload.value(blockDataset)
+		if dataset == "" {
+			dataset = h.transport.RemoteDataset()
+		}
+		if err := validation.ValidateDatasetName(dataset); err != nil {
+			break
+		}
+		snapshots, err := h.transport.GetSnapshotsForDataset(dataset)
+		if err != nil {
+			log.Printf("Slack options: failed to list snapshots of %s: %v", dataset, err)
+		}
+		for i := len(snapshots) - 1; i >= 0; i-- {
+			choices = append(choices, snapshots[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"options": filterOptions(choices, payload.Value),
+	})
+}
+
+// filterOptions keeps the choices containing query, in order, up to the
+// most Slack will show
+func filterOptions(choices []string, query string) []SlackOption {
+	query = strings.ToLower(strings.TrimSpace(query))
+	options := []SlackOption{}
+	for _, choice := range choices {
+		if len(options) == maxOptions {
+			break
+		}
+		if strings.Contains(strings.ToLower(choice), query) {
+			options = append(options, option(choice))
+		}
+	}
+	return options
+}
+
+// HandleInteraction receives submissions of the restore dialog (Slack's
+// interactivity request URL)
+func (h *CommandHandler) HandleInteraction(w http.ResponseWriter, r *http.Request) {
+	payload, ok := h.parsePayload(w, r)
+	if !ok {
+		return
+	}
+	if payload.Type != "view_submission" || payload.View.CallbackID != restoreModalID {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	req := payload.request()
+	dataset := payload.value(blockDataset)
+	snapshot := payload.value(blockSnapshot)
+	target := payload.value(blockTarget)
+	mountpoint := payload.value(blockMountpoint)
+
+	errors := make(map[string]string)
+	if !h.isAdmin(req) {
+		errors[blockTarget] = "You are not allowed to restore directly. Use `/zfsrabbit request` to ask an admin."
+	} else if err := validation.ValidateDatasetName(target); err != nil {
+		errors[blockTarget] = err.Error()
+	} else if mountpoint != "" {
+		if err := validation.ValidateMountpoint(mountpoint); err != nil {
+			errors[blockMountpoint] = err.Error()
+		}
+	}
+
+	if len(errors) == 0 {
+		job, err := h.restoreManager.StartRestoreWithOptions(dataset, snapshot, target, restore.MountOptions{Mountpoint: mountpoint})
+		if err != nil {
+			recordSlackAction(req, "restore "+snapshot, "failed")
+			errors[blockSnapshot] = fmt.Sprintf("Failed to start restore: %s", err.Error())
+		} else {
+			recordSlackAction(req, "restore "+snapshot, "success")
+			go h.respond(req.ResponseURL, SlashCommandResponse{
+				ResponseType: "in_channel",
+				Text:         restoreStartedText(job, snapshot, target, mountpoint),
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(errors) > 0 {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"response_action": "errors",
+			"errors":          errors,
+		})
+		return
+	}
+	// An empty body closes the dialog
+	w.WriteHeader(http.StatusOK)
+}
+
+// respond posts a message to a slash command's response URL
+func (h *CommandHandler) respond(responseURL string, response SlashCommandResponse) {
+	if responseURL == "" {
+		return
+	}
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, responseURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Failed to post Slack response: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to post Slack response: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,127 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRestoreModalOpen(t *testing.T) {
+	handler := createTestHandler(t)
+
+	var opened map[string]interface{}
+	var auth string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/views.open" {
+			t.Errorf("Unexpected API call %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&opened)
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer api.Close()
+	handler.apiURL = api.URL
+
+	req := SlashCommandRequest{UserName: "alice", Text: "restore", TriggerID: "trigger-1", ChannelID: "C1"}
+	if resp := handler.processCommand(req); !strings.Contains(resp.Text, "Usage") {
+		t.Errorf("Expected usage without a bot token, got %q", resp.Text)
+	}
+
+	handler.config.BotToken = "xoxb-test"
+	if resp := handler.processCommand(req); !strings.Contains(resp.Text, "Opening") {
+		t.Fatalf("Expected the dialog to open, got %q", resp.Text)
+	}
+	if auth != "Bearer xoxb-test" || opened["trigger_id"] != "trigger-1" {
+		t.Errorf("Unexpected views.open call: auth %q, body %v", auth, opened)
+	}
+	view, _ := opened["view"].(map[string]interface{})
+	if view["callback_id"] != restoreModalID || !strings.Contains(view["private_metadata"].(string), "C1") {
+		t.Errorf("Unexpected view: %v", view)
+	}
+}
+
+func postPayload(handler http.HandlerFunc, payload string) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set("payload", payload)
+	req := httptest.NewRequest("POST", "/slack/interactive", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func submission(user, target string) string {
+	return fmt.Sprintf(`{"type":"view_submission","token":"test-token","user":{"id":"U1","username":%q},
+		"view":{"callback_id":"restore_modal","state":{"values":{
+			"dataset":{"dataset":{"selected_option":{"value":"backup/test"}}},
+			"snapshot":{"snapshot":{"selected_option":{"value":"autosnap_2026-01-01_02-00-00"}}},
+			"target":{"target":{"value":%q}}}}}}`, user, target)
+}
+
+func TestRestoreModalSubmission(t *testing.T) {
+	handler := createTestHandler(t)
+	handler.config.AdminUsers = []string{"boss"}
+
+	if w := postPayload(handler.HandleInteraction, `{"type":"view_submission","token":"wrong"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong token, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name      string
+		user      string
+		target    string
+		wantError string
+	}{
+		{"not admin", "alice", "tank/restored", "not allowed"},
+		{"invalid target", "boss", "tank/bad name", "target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postPayload(handler.HandleInteraction, submission(tt.user, tt.target))
+			var resp struct {
+				ResponseAction string            `json:"response_action"`
+				Errors         map[string]string `json:"errors"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Expected a JSON response, got %q", w.Body.String())
+			}
+			found := false
+			for block, message := range resp.Errors {
+				found = found || strings.Contains(block+" "+message, tt.wantError)
+			}
+			if resp.ResponseAction != "errors" || !found {
+				t.Errorf("Expected an error mentioning %q, got %+v", tt.wantError, resp)
+			}
+		})
+	}
+}
+
+func TestFilterOptions(t *testing.T) {
+	choices := []string{"autosnap_2026-01-02", "autosnap_2026-01-01", "manual_before_upgrade"}
+
+	options := filterOptions(choices, "AUTO")
+	if len(options) != 2 || options[0].Value != "autosnap_2026-01-02" {
+		t.Errorf("Expected the autosnaps in order, got %+v", options)
+	}
+
+	if options := filterOptions(nil, ""); options == nil || len(options) != 0 {
+		t.Errorf("Expected an empty, non-nil list, got %#v", options)
+	}
+
+	var many []string
+	for i := 0; i < 150; i++ {
+		many = append(many, fmt.Sprintf("snap_%d", i))
+	}
+	if options := filterOptions(many, ""); len(options) != maxOptions {
+		t.Errorf("Expected %d options at most, got %d", maxOptions, len(options))
+	}
+
+	long := strings.Repeat("a", 80) + "_end"
+	if opt := option(long); len(opt.Text.Text) != 75 || opt.Value != long || !strings.HasSuffix(opt.Text.Text, "_end") {
+		t.Errorf("Expected long option text to be shortened, got %q", opt.Text.Text)
+	}
+}
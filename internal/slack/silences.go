@@ -0,0 +1,91 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/silence"
+)
+
+const silenceUsage = "Usage: `silence all <duration> [reason]`, `silence pool <name> <duration> [reason]` or `silence device <name> <duration> [reason]`, e.g. `silence pool tank 2h disk swap`"
+
+// SetSilences enables the silence, silences and unsilence commands
+func (h *CommandHandler) SetSilences(store *silence.Store) {
+	h.silences = store
+}
+
+// silenceAlerts handles "silence <all|pool name|device name> <duration> [reason]"
+func (h *CommandHandler) silenceAlerts(req SlashCommandRequest, args []string) SlashCommandResponse {
+	if h.silences == nil {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "Alert silences are not available."}
+	}
+
+	// args[0] is the command itself
+	if len(args) < 3 {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: silenceUsage}
+	}
+	scope, target, rest := strings.ToLower(args[1]), "", args[2:]
+	if scope != silence.ScopeAll {
+		target, rest = args[2], args[3:]
+	}
+	if len(rest) == 0 {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: silenceUsage}
+	}
+	duration, err := time.ParseDuration(rest[0])
+	if err != nil {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: silenceUsage}
+	}
+
+	created, err := h.silences.Add(scope, target, duration, strings.Join(rest[1:], " "), slackActor(req))
+	if err != nil {
+		return SlashCommandResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf("❌ Failed to silence alerts: %s", err.Error()),
+		}
+	}
+	recordSlackAction(req, "silence "+created.ID, "success")
+
+	return SlashCommandResponse{
+		ResponseType: "in_channel",
+		Text: fmt.Sprintf("🔕 Alerts for %s silenced until %s by %s. End it early with `unsilence %s`.",
+			created.Describe(), display.Time(created.Expires), created.By, created.ID),
+	}
+}
+
+func (h *CommandHandler) listSilences() SlashCommandResponse {
+	if h.silences == nil {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "Alert silences are not available."}
+	}
+
+	silences := h.silences.Active()
+	if len(silences) == 0 {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "No alerts are silenced."}
+	}
+
+	text := "*Alert Silences:*\n"
+	for _, s := range silences {
+		text += fmt.Sprintf("• 🔕 `%s` - %s until %s by %s\n", s.ID, s.Describe(), display.Time(s.Expires), s.By)
+		if s.Reason != "" {
+			text += fmt.Sprintf("  Reason: %s\n", s.Reason)
+		}
+	}
+	return SlashCommandResponse{ResponseType: "ephemeral", Text: text}
+}
+
+func (h *CommandHandler) unsilence(req SlashCommandRequest, id string) SlashCommandResponse {
+	if h.silences == nil {
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: "Alert silences are not available."}
+	}
+	if !h.silences.Remove(id) {
+		recordSlackAction(req, "unsilence "+id, "failure")
+		return SlashCommandResponse{ResponseType: "ephemeral", Text: fmt.Sprintf("❌ No active silence `%s`.", id)}
+	}
+	recordSlackAction(req, "unsilence "+id, "success")
+
+	return SlashCommandResponse{
+		ResponseType: "in_channel",
+		Text:         fmt.Sprintf("🔔 Silence `%s` ended, alerts are back on.", id),
+	}
+}
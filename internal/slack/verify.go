@@ -0,0 +1,99 @@
+package slack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxRequestSize bounds the body read from Slack before it is verified
+	maxRequestSize = 1 << 20
+	// maxSignatureAge is how old a signed request may be before it is
+	// refused as a replay
+	maxSignatureAge = 5 * time.Minute
+)
+
+// readForm parses a POST from Slack and checks it came from Slack. token
+// returns the verification token the request carries, once parsed.
+func (h *CommandHandler) readForm(w http.ResponseWriter, r *http.Request, token func() string) bool {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return false
+	}
+
+	if err := h.verify(r.Header, body, token()); err != nil {
+		log.Printf("Refused Slack request to %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// verify checks a request's signature when slack.signing_secret is set, or
+// else its verification token. Without either every request is refused, as
+// anyone could otherwise claim to be a Slack admin.
+func (h *CommandHandler) verify(header http.Header, body []byte, token string) error {
+	switch {
+	case h.config.SigningSecret != "":
+		return verifySignature(h.config.SigningSecret, header, body, time.Now())
+	case h.config.SlashToken != "":
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.config.SlashToken)) != 1 {
+			return errors.New("wrong verification token")
+		}
+		return nil
+	default:
+		return errors.New("neither slack.signing_secret nor slack.slash_token is set")
+	}
+}
+
+// verifySignature checks X-Slack-Signature, an HMAC-SHA256 of the timestamp
+// and body keyed with the app's signing secret
+func verifySignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("request is not signed")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return fmt.Errorf("timestamp is %s off", age.Round(time.Second))
+	}
+
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(sign(secret, timestamp, body))) != 1 {
+		return errors.New("wrong signature")
+	}
+	return nil
+}
+
+// sign returns the X-Slack-Signature of a request
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
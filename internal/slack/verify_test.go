@@ -0,0 +1,68 @@
+package slack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackRequestsRefusedWithoutToken(t *testing.T) {
+	handler := createTestHandler(t)
+	handler.config.SlashToken = ""
+
+	// An empty token in the request must not match the unset one
+	if w := postPayload(handler.HandleInteraction, `{"type":"view_submission","token":""}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a dialog submission, got %d", w.Code)
+	}
+	if w := postPayload(handler.HandleOptions, `{"type":"block_suggestion","action_id":"dataset"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for dataset options, got %d", w.Code)
+	}
+
+	data := url.Values{"command": {"/zfsrabbit"}, "text": {"status"}}
+	req := httptest.NewRequest("POST", "/slack/command", strings.NewReader(data.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.HandleSlashCommand(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a slash command, got %d", w.Code)
+	}
+}
+
+func TestSlackSignedRequests(t *testing.T) {
+	handler := createTestHandler(t)
+	handler.config.SigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+	body := url.Values{"command": {"/zfsrabbit"}, "text": {"help"}}.Encode()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		want      int
+	}{
+		{"signed", now, sign(handler.config.SigningSecret, now, []byte(body)), http.StatusOK},
+		{"unsigned", "", "", http.StatusUnauthorized},
+		{"wrong secret", now, sign("other", now, []byte(body)), http.StatusUnauthorized},
+		{"replayed", stale, sign(handler.config.SigningSecret, stale, []byte(body)), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/slack/command", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-Slack-Request-Timestamp", tt.timestamp)
+			req.Header.Set("X-Slack-Signature", tt.signature)
+			w := httptest.NewRecorder()
+			handler.HandleSlashCommand(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Files kept in the state directory by the persistent stores
+const (
+	MonitorFile    = "monitor_state.json"
+	OutboxFile     = "alert_outbox.json"
+	PoliciesFile   = "policies.json"
+	DrillsFile     = "drill_reports.json"
+	CatalogFile    = "snapshot_catalog.json"
+	SLAFile        = "sla_state.json"
+	RequestsFile   = "restore_requests.json"
+	PendingFile    = "pending_sends.json"
+	RunsFile       = "run_history.json"
+	ThroughputFile = "throughput_history.json"
+	TokensFile     = "api_tokens.json"
+	DatasetsFile   = "dataset_guids.json"
+	JobsFile       = "restore_jobs.json"
+	MigrationFile  = "migration_session.json"
+	SilencesFile   = "silences.json"
+	AlertsFile     = "alert_history.json"
+	DigestFile     = "email_digest.json"
+
+	// BrowseDir holds the mountpoints of snapshots received for file restores
+	BrowseDir = "browse"
+
+	lockFile = "zfsrabbit.lock"
+
+	quarantineSuffix     = ".corrupt-"
+	quarantineTimeFormat = "20060102-150405"
+)
+
+// File is a file in the state directory and its size
+type File struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Dir is an opened, exclusively locked state directory
+type Dir struct {
+	path string
+	lock *os.File
+}
+
+// PathIn returns the path of a state file within dir, or "" when no state
+// directory is configured (stores then keep their data in memory only)
+func PathIn(dir, name string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// Open creates the state directory if needed, takes an exclusive lock so two
+// daemons can't share it, and checks the integrity of the files inside
+func Open(path string) (*Dir, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("state directory must be an absolute path: %s", path)
+	}
+
+	if err := os.MkdirAll(path, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("state directory %s is not a directory", path)
+	}
+
+	lock, err := acquireLock(filepath.Join(path, lockFile))
+	if err != nil {
+		return nil, fmt.Errorf("state directory %s: %w", path, err)
+	}
+
+	d := &Dir{path: path, lock: lock}
+
+	if err := d.checkIntegrity(); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("state directory %s failed integrity check: %w", path, err)
+	}
+
+	return d, nil
+}
+
+func acquireLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder, _ := os.ReadFile(path)
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("in use by another zfsrabbit process (pid %s)", strings.TrimSpace(string(holder)))
+		}
+		return nil, fmt.Errorf("failed to lock: %w", err)
+	}
+
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return f, nil
+}
+
+// checkIntegrity removes temp files left by interrupted atomic writes and
+// quarantines state files that no longer parse, so one corrupt store doesn't
+// stop the daemon (the store starts empty and the bad copy is kept for review)
+func (d *Dir) checkIntegrity() error {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		full := filepath.Join(d.path, name)
+
+		if strings.HasPrefix(name, ".") && strings.Contains(name, ".tmp-") {
+			log.Printf("Removing incomplete state file %s", full)
+			os.Remove(full)
+			continue
+		}
+
+		if filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", name, err)
+		}
+		if json.Valid(data) {
+			continue
+		}
+
+		quarantine := fmt.Sprintf("%s%s%s", full, quarantineSuffix, time.Now().Format(quarantineTimeFormat))
+		if err := os.Rename(full, quarantine); err != nil {
+			return fmt.Errorf("cannot quarantine corrupt %s: %w", name, err)
+		}
+		log.Printf("WARNING: state file %s is corrupt, moved to %s", full, quarantine)
+	}
+
+	return nil
+}
+
+// Path returns the absolute path of a file in the state directory
+func (d *Dir) Path(name string) string {
+	return filepath.Join(d.path, name)
+}
+
+// Files lists the regular files in the state directory by name
+func (d *Dir) Files() ([]File, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, File{Name: entry.Name(), Bytes: info.Size()})
+	}
+	return files, nil
+}
+
+// RemoveQuarantined deletes corrupt state files that were quarantined before
+// before, returning how many were removed
+func (d *Dir) RemoveQuarantined(before time.Time) int {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return 0
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		i := strings.LastIndex(name, quarantineSuffix)
+		if i < 0 || !entry.Type().IsRegular() {
+			continue
+		}
+		quarantined, err := time.ParseInLocation(quarantineTimeFormat, name[i+len(quarantineSuffix):], time.Local)
+		if err != nil || !quarantined.Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.path, name)); err != nil {
+			log.Printf("Failed to remove quarantined state file %s: %v", name, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// Close releases the directory lock
+func (d *Dir) Close() error {
+	if d.lock == nil {
+		return nil
+	}
+	err := d.lock.Close()
+	d.lock = nil
+	return err
+}
@@ -0,0 +1,77 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenLocksDirectory(t *testing.T) {
+	path := t.TempDir()
+
+	dir, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Expected second Open to fail while locked, got %v", err)
+	}
+
+	dir.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected Open to succeed after Close: %v", err)
+	}
+	reopened.Close()
+}
+
+func TestOpenRejectsRelativePath(t *testing.T) {
+	if _, err := Open("relative/state"); err == nil {
+		t.Error("Expected error for relative path")
+	}
+}
+
+func TestIntegrityCheck(t *testing.T) {
+	path := t.TempDir()
+
+	good := filepath.Join(path, MonitorFile)
+	corrupt := filepath.Join(path, OutboxFile)
+	leftover := filepath.Join(path, "."+PoliciesFile+".tmp-123")
+
+	os.WriteFile(good, []byte(`{"alert_states":{}}`), 0600)
+	os.WriteFile(corrupt, []byte(`[{"channel":"email",`), 0600)
+	os.WriteFile(leftover, []byte(`{`), 0600)
+
+	dir, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dir.Close()
+
+	if _, err := os.Stat(good); err != nil {
+		t.Errorf("Valid state file should be kept: %v", err)
+	}
+	if _, err := os.Stat(corrupt); !os.IsNotExist(err) {
+		t.Error("Corrupt state file should be moved aside")
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Error("Leftover temp file should be removed")
+	}
+
+	matches, _ := filepath.Glob(corrupt + ".corrupt-*")
+	if len(matches) != 1 {
+		t.Errorf("Expected one quarantined copy, found %v", matches)
+	}
+}
+
+func TestPathIn(t *testing.T) {
+	if PathIn("", MonitorFile) != "" {
+		t.Error("Expected empty path without a state directory")
+	}
+	if got := PathIn("/var/lib/zfsrabbit", MonitorFile); got != "/var/lib/zfsrabbit/monitor_state.json" {
+		t.Errorf("Unexpected path %s", got)
+	}
+}
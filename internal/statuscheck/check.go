@@ -0,0 +1,84 @@
+package statuscheck
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"zfsrabbit/internal/config"
+)
+
+// CheckResult is the outcome of a Nagios-style check, as served by the
+// /api/check endpoints
+type CheckResult struct {
+	State    string   `json:"state"` // OK, WARNING, CRITICAL or UNKNOWN
+	Code     int      `json:"code"`  // The matching plugin exit code
+	Message  string   `json:"message"`
+	Perfdata []string `json:"perfdata,omitempty"`
+}
+
+var stateNames = map[int]string{
+	ExitHealthy:   "OK",
+	ExitWarning:   "WARNING",
+	ExitUnhealthy: "CRITICAL",
+	ExitUnknown:   "UNKNOWN",
+}
+
+// NewCheckResult returns a result with the state for code
+func NewCheckResult(code int, format string, args ...interface{}) CheckResult {
+	return CheckResult{
+		State:   stateNames[code],
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// precedence orders states for Escalate: CRITICAL over WARNING over UNKNOWN
+// over OK, so a check that could only read part of its data still reports
+// the problems it found
+var precedence = map[int]int{
+	ExitHealthy:   0,
+	ExitUnknown:   1,
+	ExitWarning:   2,
+	ExitUnhealthy: 3,
+}
+
+// Escalate raises the result to code if that is worse
+func (r *CheckResult) Escalate(code int) {
+	if precedence[code] > precedence[r.Code] {
+		r.Code = code
+		r.State = stateNames[code]
+	}
+}
+
+// String formats the result as plugin output: "STATE - message | perfdata"
+func (r CheckResult) String() string {
+	line := fmt.Sprintf("%s - %s", r.State, r.Message)
+	if len(r.Perfdata) > 0 {
+		line += " | " + strings.Join(r.Perfdata, " ")
+	}
+	return line
+}
+
+// RunCheck runs the named check on the daemon, prints its plugin output to
+// out and returns its exit code. baseURL defaults to the local daemon.
+func RunCheck(cfg *config.Config, name string, params url.Values, baseURL string, opts Options, out io.Writer) int {
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://127.0.0.1:%d", cfg.Server.Port)
+	}
+	endpoint := fmt.Sprintf("%s/api/check/%s", strings.TrimSuffix(baseURL, "/"), url.PathEscape(name))
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	var result CheckResult
+	if err := getJSON(endpoint, cfg.GetAdminPassword(), opts.Timeout, &result); err != nil {
+		result = NewCheckResult(ExitUnknown, "%v", err)
+	} else if _, ok := stateNames[result.Code]; !ok {
+		result = NewCheckResult(ExitUnknown, "unexpected check code %d", result.Code)
+	}
+
+	fmt.Fprintln(out, result.String())
+	return result.Code
+}
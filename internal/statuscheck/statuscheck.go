@@ -0,0 +1,198 @@
+package statuscheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+// Exit codes follow the Nagios plugin convention so the status command can
+// be used as a check as is
+const (
+	ExitHealthy   = 0
+	ExitWarning   = 1
+	ExitUnhealthy = 2
+	ExitUnknown   = 3
+)
+
+// Options configures a status check
+type Options struct {
+	URL     string // Status endpoint; defaults to the local daemon's /api/status
+	JSON    bool   // Print the status document instead of a summary
+	Timeout time.Duration
+}
+
+// Fetch returns the daemon's status document from its API. If the daemon
+// can't be reached, the status export file is used instead as long as it
+// is fresh, i.e. written within three export intervals.
+func Fetch(cfg *config.Config, opts Options) (map[string]interface{}, error) {
+	url := opts.URL
+	if url == "" {
+		url = fmt.Sprintf("http://127.0.0.1:%d/api/status", cfg.Server.Port)
+	}
+
+	status, err := fetchAPI(url, cfg.GetAdminPassword(), opts.Timeout)
+	if err == nil {
+		return status, nil
+	}
+	if cfg.Export.Path == "" {
+		return nil, err
+	}
+
+	exported, exportErr := readExport(&cfg.Export)
+	if exportErr != nil {
+		return nil, fmt.Errorf("%v; status export: %v", err, exportErr)
+	}
+	return exported, nil
+}
+
+func fetchAPI(url, password string, timeout time.Duration) (map[string]interface{}, error) {
+	var status map[string]interface{}
+	if err := getJSON(url, password, timeout, &status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// getJSON decodes the JSON document at url, authenticating as admin
+func getJSON(url, password string, timeout time.Duration, v interface{}) error {
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("admin", password)
+
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach zfsrabbit: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
+
+func readExport(cfg *config.ExportConfig) (map[string]interface{}, error) {
+	info, err := os.Stat(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if age := time.Since(info.ModTime()); age > 3*cfg.Interval {
+		return nil, fmt.Errorf("%s is stale (written %s ago)", cfg.Path, age.Round(time.Second))
+	}
+
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	var status map[string]interface{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("invalid status in %s: %w", cfg.Path, err)
+	}
+	return status, nil
+}
+
+// Run prints the status to out and returns the exit code: healthy,
+// unhealthy, or unknown if no status could be read
+func Run(cfg *config.Config, opts Options, out io.Writer) int {
+	status, err := Fetch(cfg, opts)
+	if err != nil {
+		if opts.JSON {
+			json.NewEncoder(out).Encode(map[string]interface{}{"healthy": false, "error": err.Error()})
+		} else {
+			fmt.Fprintf(out, "UNKNOWN: %v\n", err)
+		}
+		return ExitUnknown
+	}
+
+	problems := Problems(status)
+	if opts.JSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(status)
+	} else if len(problems) == 0 {
+		fmt.Fprintln(out, "OK: zfsrabbit is healthy")
+	} else {
+		fmt.Fprintf(out, "CRITICAL: %d problems\n", len(problems))
+		for _, problem := range problems {
+			fmt.Fprintf(out, "- %s\n", problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return ExitUnhealthy
+	}
+	return ExitHealthy
+}
+
+// Problems lists what makes a status document unhealthy: pools that are not
+// ONLINE or have errors, unhealthy disks, failing checks and targets with
+// pending sends
+func Problems(status map[string]interface{}) []string {
+	var problems []string
+
+	for _, name := range sortedKeys(status["pools"]) {
+		pool, _ := status["pools"].(map[string]interface{})[name].(map[string]interface{})
+		state, _ := pool["State"].(string)
+		errors, _ := pool["Errors"].([]interface{})
+		if state != "ONLINE" || len(errors) > 0 {
+			problems = append(problems, fmt.Sprintf("pool %s is %s with %d errors", name, state, len(errors)))
+		}
+	}
+
+	for _, id := range sortedKeys(status["disks"]) {
+		disk, _ := status["disks"].(map[string]interface{})[id].(map[string]interface{})
+		if healthy, ok := disk["Healthy"].(bool); ok && !healthy {
+			problems = append(problems, fmt.Sprintf("disk %s is unhealthy", id))
+		}
+	}
+
+	checks, _ := status["checks"].([]interface{})
+	for _, item := range checks {
+		check, _ := item.(map[string]interface{})
+		if lastError, _ := check["last_error"].(string); lastError != "" {
+			problems = append(problems, fmt.Sprintf("check %v failing: %s", check["name"], lastError))
+		}
+	}
+
+	targets, _ := status["targets"].([]interface{})
+	for _, item := range targets {
+		target, _ := item.(map[string]interface{})
+		if pending, _ := target["pending"].([]interface{}); len(pending) > 0 {
+			problems = append(problems, fmt.Sprintf("target %v has %d pending sends", target["name"], len(pending)))
+		}
+	}
+
+	// Whatever the daemon itself considers unhealthy
+	if healthy, ok := status["healthy"].(bool); ok && !healthy && len(problems) == 0 {
+		problems = append(problems, "zfsrabbit reports issues")
+	}
+
+	return problems
+}
+
+func sortedKeys(value interface{}) []string {
+	items, _ := value.(map[string]interface{})
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
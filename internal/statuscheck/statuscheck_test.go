@@ -0,0 +1,141 @@
+package statuscheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+const degradedStatus = `{
+	"healthy": false,
+	"pools": {"tank": {"Pool": "tank", "State": "DEGRADED", "Errors": []}},
+	"disks": {"sda": {"Healthy": true}},
+	"checks": [{"name": "nfs", "last_error": ""}],
+	"targets": [{"name": "primary", "pending": ["autosnap_2026-01-01_02-00-00"]}]
+}`
+
+func statusServer(t *testing.T, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testConfig(t *testing.T) *config.Config {
+	t.Setenv("ZFSRABBIT_TEST_PASS", "secret")
+	return &config.Config{Server: config.ServerConfig{AdminPassEnv: "ZFSRABBIT_TEST_PASS"}}
+}
+
+func TestRun(t *testing.T) {
+	cfg := testConfig(t)
+
+	healthy := statusServer(t, `{"healthy": true, "pools": {"tank": {"State": "ONLINE"}}}`)
+	var out bytes.Buffer
+	if code := Run(cfg, Options{URL: healthy.URL}, &out); code != ExitHealthy || !strings.HasPrefix(out.String(), "OK") {
+		t.Errorf("Expected OK, got %d: %s", code, out.String())
+	}
+
+	degraded := statusServer(t, degradedStatus)
+	out.Reset()
+	code := Run(cfg, Options{URL: degraded.URL}, &out)
+	if code != ExitUnhealthy || !strings.Contains(out.String(), "pool tank is DEGRADED") || !strings.Contains(out.String(), "1 pending sends") {
+		t.Errorf("Expected the degraded pool and pending sends, got %d: %s", code, out.String())
+	}
+
+	out.Reset()
+	if code := Run(cfg, Options{URL: degraded.URL, JSON: true}, &out); code != ExitUnhealthy {
+		t.Errorf("Expected unhealthy exit code with --json, got %d", code)
+	}
+	var status map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &status); err != nil || status["healthy"] != false {
+		t.Errorf("Expected the status document as JSON, got %s (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	cfg.Server.AdminPassEnv = "ZFSRABBIT_TEST_UNSET"
+	if code := Run(cfg, Options{URL: degraded.URL}, &out); code != ExitUnknown || !strings.Contains(out.String(), "401") {
+		t.Errorf("Expected unknown on a rejected password, got %d: %s", code, out.String())
+	}
+}
+
+func TestFetchFallsBackToExport(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Export = config.ExportConfig{Path: filepath.Join(t.TempDir(), "status.json"), Interval: time.Minute}
+	opts := Options{URL: "http://127.0.0.1:1/api/status", Timeout: time.Second}
+
+	if _, err := Fetch(cfg, opts); err == nil {
+		t.Fatal("Expected an error without a reachable daemon or export file")
+	}
+
+	if err := os.WriteFile(cfg.Export.Path, []byte(degradedStatus), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, err := Fetch(cfg, opts)
+	if err != nil || status["healthy"] != false {
+		t.Fatalf("Expected the exported status, got %v (%v)", status, err)
+	}
+
+	stale := time.Now().Add(-time.Hour)
+	os.Chtimes(cfg.Export.Path, stale, stale)
+	if _, err := Fetch(cfg, opts); err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Errorf("Expected a stale export to be rejected, got %v", err)
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	cfg := testConfig(t)
+
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/check/pool-health" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		fmt.Fprint(w, `{"state":"WARNING","code":1,"message":"tank is 85% full","perfdata":["'tank'=85%;80;90;0;100"]}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	params := url.Values{"warning": {"80"}, "critical": {"90"}}
+	if code := RunCheck(cfg, "pool-health", params, server.URL+"/", Options{}, &out); code != ExitWarning {
+		t.Errorf("Expected the warning exit code, got %d", code)
+	}
+	if out.String() != "WARNING - tank is 85% full | 'tank'=85%;80;90;0;100\n" || query != "critical=90&warning=80" {
+		t.Errorf("Unexpected output %q for query %q", out.String(), query)
+	}
+
+	out.Reset()
+	if code := RunCheck(cfg, "disk-usage", nil, server.URL, Options{}, &out); code != ExitUnknown || !strings.HasPrefix(out.String(), "UNKNOWN - ") {
+		t.Errorf("Expected UNKNOWN for a missing check, got %d: %s", code, out.String())
+	}
+}
+
+func TestCheckResultEscalate(t *testing.T) {
+	result := NewCheckResult(ExitHealthy, "")
+	for _, code := range []int{ExitUnknown, ExitHealthy, ExitWarning, ExitUnknown} {
+		result.Escalate(code)
+	}
+	if result.Code != ExitWarning || result.State != "WARNING" {
+		t.Errorf("Expected WARNING to outrank UNKNOWN, got %s", result.State)
+	}
+	result.Escalate(ExitUnhealthy)
+	if result.State != "CRITICAL" {
+		t.Errorf("Expected CRITICAL, got %s", result.State)
+	}
+}
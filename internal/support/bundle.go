@@ -0,0 +1,151 @@
+// Package support builds the tarball users attach when asking for help:
+// sanitized config, recent logs, job history, pool state and catalog.
+package support
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+)
+
+const redacted = "REDACTED"
+
+// File is one entry in a bundle
+type File struct {
+	Name string
+	Data []byte
+}
+
+// JSONFile encodes v as an indented JSON entry
+func JSONFile(name string, v interface{}) File {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errorFile(name, err)
+	}
+	return File{Name: name, Data: append(data, '\n')}
+}
+
+// CommandFile runs a command and records its combined output. A failure is
+// written into the entry rather than aborting the bundle.
+func CommandFile(ctx context.Context, name, command string, args ...string) File {
+	output, err := utils.CombinedOutput(utils.DefaultRunner, utils.DefaultRunner.CommandContext(ctx, command, args...))
+	if err != nil {
+		output = append(output, fmt.Sprintf("\n[%s %s failed: %v]\n", command, strings.Join(args, " "), err)...)
+	}
+	return File{Name: name, Data: output}
+}
+
+// LogsFile holds the recent daemon log lines
+func LogsFile(name string) File {
+	lines := RecentLogs.Lines()
+	return File{Name: name, Data: []byte(strings.Join(lines, "\n") + "\n")}
+}
+
+// ConfigFile is the running config as YAML with every secret replaced
+func ConfigFile(name string, cfg *config.Config) File {
+	data, err := yaml.Marshal(Sanitize(cfg))
+	if err != nil {
+		return errorFile(name, err)
+	}
+	return File{Name: name, Data: data}
+}
+
+// Sanitize returns a deep copy of cfg with every field tagged secret:"true"
+// redacted, so a secret added to the config is never left out
+func Sanitize(cfg *config.Config) *config.Config {
+	clean := new(config.Config)
+	copyRedacted(reflect.ValueOf(clean).Elem(), reflect.ValueOf(cfg).Elem(), false)
+	return clean
+}
+
+// copyRedacted deep-copies src into dst, redacting strings, and the values
+// of maps and lists of strings, when secret is set
+func copyRedacted(dst, src reflect.Value, secret bool) {
+	switch src.Kind() {
+	case reflect.Struct:
+		t := src.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				// Not config, e.g. the internals of a time.Time
+				dst.Set(src)
+				return
+			}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			copyRedacted(dst.Field(i), src.Field(i), t.Field(i).Tag.Get("secret") == "true")
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			copyRedacted(dst.Index(i), src.Index(i), secret)
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		for iter := src.MapRange(); iter.Next(); {
+			value := reflect.New(src.Type().Elem()).Elem()
+			copyRedacted(value, iter.Value(), secret)
+			dst.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		copyRedacted(dst.Elem(), src.Elem(), secret)
+	case reflect.String:
+		if secret && src.String() != "" {
+			dst.SetString(redacted)
+		} else {
+			dst.Set(src)
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+func errorFile(name string, err error) File {
+	return File{Name: name + ".error", Data: []byte(err.Error() + "\n")}
+}
+
+// Write writes files as a gzipped tar under a top-level directory named prefix
+func Write(w io.Writer, prefix string, files []File) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	now := time.Now()
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    prefix + "/" + file.Name,
+			Mode:    0600,
+			Size:    int64(len(file.Data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.Data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
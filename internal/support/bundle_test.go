@@ -0,0 +1,142 @@
+package support
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"zfsrabbit/internal/config"
+)
+
+func TestLogBufferKeepsLatestLines(t *testing.T) {
+	buf := NewLogBuffer(3)
+	buf.Write([]byte("one\ntwo\n"))
+	buf.Write([]byte("thr"))
+	buf.Write([]byte("ee\nfour\nfive\n"))
+
+	if got := strings.Join(buf.Lines(), ","); got != "three,four,five" {
+		t.Errorf("Expected the last three lines, got %q", got)
+	}
+}
+
+func TestSanitizeRedactsSecrets(t *testing.T) {
+	cfg := &config.Config{
+		Email: config.EmailConfig{SMTPHost: "smtp.example.com", SMTPPassword: "hunter2"},
+		Slack: config.SlackConfig{WebhookURL: "https://hooks.slack.com/services/T/B/X"},
+	}
+
+	clean := Sanitize(cfg)
+
+	if clean.Email.SMTPPassword != redacted || clean.Slack.WebhookURL != redacted {
+		t.Errorf("Expected secrets to be redacted, got %+v / %+v", clean.Email, clean.Slack)
+	}
+	if clean.Email.SMTPHost != "smtp.example.com" || clean.Slack.SlashToken != "" {
+		t.Errorf("Expected other fields to be kept as-is, got %+v / %+v", clean.Email, clean.Slack)
+	}
+	if cfg.Email.SMTPPassword != "hunter2" {
+		t.Error("Sanitize must not modify the running config")
+	}
+}
+
+// fillSecrets sets every field tagged secret:"true" below v to a value only
+// a secret holds, adding an entry to lists of sections to reach their
+// fields, and returns the paths of the fields it set
+func fillSecrets(v reflect.Value, path string) []string {
+	var filled []string
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := path + "." + field.Name
+		if field.Tag.Get("secret") == "true" {
+			switch value.Kind() {
+			case reflect.String:
+				value.SetString("s3cr3t-" + field.Name)
+			case reflect.Map:
+				value.Set(reflect.ValueOf(map[string]string{"Authorization": "Bearer s3cr3t-header"}))
+			default:
+				panic("unsupported secret field " + name)
+			}
+			filled = append(filled, name)
+			continue
+		}
+		switch {
+		case value.Kind() == reflect.Struct:
+			filled = append(filled, fillSecrets(value, name)...)
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+			value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+			filled = append(filled, fillSecrets(value.Index(0), name+"[0]")...)
+		}
+	}
+	return filled
+}
+
+func TestSanitizeRedactsEverySecret(t *testing.T) {
+	cfg := &config.Config{}
+	filled := fillSecrets(reflect.ValueOf(cfg).Elem(), "Config")
+
+	for _, want := range []string{
+		"Config.S3.AccessKey", "Config.S3.SecretKey", "Config.Telegram.BotToken", "Config.Teams.WebhookURL",
+		"Config.Push.Token", "Config.Webhooks[0].URL", "Config.Webhooks[0].Headers",
+		"Config.Server.Users[0].PasswordHash", "Config.Owners[0].SlackWebhookURL", "Config.Slack.SigningSecret",
+	} {
+		if !slices.Contains(filled, want) {
+			t.Errorf("Expected %s to be tagged secret", want)
+		}
+	}
+
+	data, err := yaml.Marshal(Sanitize(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Errorf("Expected every secret redacted, got:\n%s", data)
+	}
+	if strings.Count(string(data), redacted) != len(filled) {
+		t.Errorf("Expected %d redacted values, got:\n%s", len(filled), data)
+	}
+	if cfg.Webhooks[0].Headers["Authorization"] != "Bearer s3cr3t-header" || cfg.Server.Users[0].PasswordHash == redacted {
+		t.Error("Sanitize must not modify the running config, including its lists and maps")
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	var out bytes.Buffer
+	files := []File{
+		{Name: "a.txt", Data: []byte("hello")},
+		JSONFile("b.json", map[string]int{"x": 1}),
+	}
+	if err := Write(&out, "bundle", files); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+
+	if strings.Join(names, ",") != "bundle/a.txt,bundle/b.json" {
+		t.Errorf("Unexpected entries: %v", names)
+	}
+}
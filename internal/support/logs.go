@@ -0,0 +1,54 @@
+package support
+
+import (
+	"strings"
+	"sync"
+)
+
+// RecentLogs keeps the daemon's latest log lines for support bundles; main
+// tees the standard logger into it
+var RecentLogs = NewLogBuffer(5000)
+
+// LogBuffer is an io.Writer that keeps the last maxLines lines written to it
+type LogBuffer struct {
+	mu       sync.Mutex
+	lines    []string
+	next     int
+	full     bool
+	partial  string
+	maxLines int
+}
+
+func NewLogBuffer(maxLines int) *LogBuffer {
+	return &LogBuffer{lines: make([]string, maxLines), maxLines: maxLines}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	text := b.partial + string(p)
+	parts := strings.Split(text, "\n")
+	// The last element is an unterminated line (or "" after a newline)
+	b.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % b.maxLines
+		if b.next == 0 {
+			b.full = true
+		}
+	}
+
+	return len(p), nil
+}
+
+// Lines returns the buffered lines, oldest first
+func (b *LogBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string{}, b.lines[:b.next]...)
+	}
+	return append(append([]string{}, b.lines[b.next:]...), b.lines[:b.next]...)
+}
@@ -0,0 +1,56 @@
+// Package systemd reports the daemon's state to systemd with the sd_notify
+// protocol. Outside a Type=notify unit NOTIFY_SOCKET is unset and every
+// call does nothing.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notify sends newline separated assignments such as "READY=1" to the
+// socket named by NOTIFY_SOCKET
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to reach systemd: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Ready tells systemd startup has finished
+func Ready() error {
+	return Notify("READY=1")
+}
+
+// Stopping tells systemd shutdown has begun, with a status line saying why
+// it may take a while
+func Stopping(status string) error {
+	return Notify("STOPPING=1\nSTATUS=" + status)
+}
+
+// Status sets the status line shown by systemctl status
+func Status(status string) error {
+	return Notify("STATUS=" + status)
+}
+
+// ExtendTimeout asks systemd to allow d more for the current start or stop
+// before giving up on it
+func ExtendTimeout(d time.Duration) error {
+	return Notify(fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d", d.Microseconds()))
+}
@@ -0,0 +1,44 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if err := Stopping("Draining 1 send"); err != nil {
+		t.Fatalf("Stopping failed: %v", err)
+	}
+	if err := ExtendTimeout(90 * time.Second); err != nil {
+		t.Fatalf("ExtendTimeout failed: %v", err)
+	}
+
+	for _, want := range []string{"STOPPING=1\nSTATUS=Draining 1 send", "EXTEND_TIMEOUT_USEC=90000000"} {
+		buf := make([]byte, 256)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Errorf("Got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Ready(); err != nil {
+		t.Errorf("Expected no error outside systemd, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+// Package telegram answers commands sent to the alert bot from its chats,
+// mirroring the Slack status, snapshots and snapshot commands.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/alert"
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/zfs"
+)
+
+const (
+	apiBase = "https://api.telegram.org"
+	// pollTimeout is how long Telegram holds a getUpdates call open
+	// waiting for a message
+	pollTimeout = 50 * time.Second
+	// retryDelay is the pause after a failed poll
+	retryDelay = 10 * time.Second
+)
+
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type message struct {
+	Date int64 `json:"date"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// Bot long-polls the Telegram Bot API for commands and replies to them in
+// the chat they came from. Only chats listed in telegram.chat_ids are
+// answered.
+type Bot struct {
+	config     *config.TelegramConfig
+	scheduler  *scheduler.Scheduler
+	monitor    *monitor.Monitor
+	zfsManager *zfs.Manager
+	client     *http.Client
+	apiBase    string
+	started    time.Time
+	offset     int64 // Next update to fetch
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+func NewBot(cfg *config.TelegramConfig, sched *scheduler.Scheduler, mon *monitor.Monitor, zfsMgr *zfs.Manager) *Bot {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bot{
+		config:     cfg,
+		scheduler:  sched,
+		monitor:    mon,
+		zfsManager: zfsMgr,
+		client:     &http.Client{Timeout: pollTimeout + 30*time.Second},
+		apiBase:    apiBase,
+		started:    time.Now(),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start polls for commands until Stop is called
+func (b *Bot) Start() {
+	log.Printf("Listening for Telegram commands from %d chats", len(b.config.ChatIDs))
+	for {
+		if err := b.poll(b.ctx, pollTimeout); err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Telegram command poll failed: %v", err)
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+		}
+	}
+}
+
+func (b *Bot) Stop() {
+	b.cancel()
+}
+
+// poll fetches the next updates, waiting up to timeout for one, and
+// answers the commands among them
+func (b *Bot) poll(ctx context.Context, timeout time.Duration) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"offset":          b.offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.apiBase+"/bot"+b.config.BotToken+"/getUpdates", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The URL holds the token, keep it out of logs
+		return fmt.Errorf("getUpdates failed: %s", strings.ReplaceAll(err.Error(), b.config.BotToken, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool     `json:"ok"`
+		Description string   `json:"description"`
+		Result      []update `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("getUpdates returned status %d", resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("getUpdates failed: %s", result.Description)
+	}
+
+	for _, u := range result.Result {
+		if u.UpdateID >= b.offset {
+			b.offset = u.UpdateID + 1
+		}
+		if u.Message != nil {
+			b.handle(*u.Message)
+		}
+	}
+	return nil
+}
+
+// handle answers a command from an allowed chat. Messages sent before the
+// bot started are skipped so a restart doesn't replay them.
+func (b *Bot) handle(msg message) {
+	if !b.allowed(msg.Chat.ID) || time.Unix(msg.Date, 0).Before(b.started.Truncate(time.Second)) {
+		return
+	}
+	command := commandName(msg.Text)
+	if command == "" {
+		return
+	}
+
+	reply := b.processCommand(command)
+	if err := alert.SendTelegram(b.ctx, b.client, b.apiBase, b.config.BotToken, msg.Chat.ID, reply); err != nil {
+		log.Printf("Failed to answer Telegram /%s: %v", command, err)
+	}
+}
+
+func (b *Bot) allowed(chatID int64) bool {
+	for _, id := range b.config.ChatIDs {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// commandName returns the command in a message such as "/status" or
+// "/status@zfsrabbit_bot", or "" if it isn't one
+func commandName(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return ""
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	return strings.ToLower(name)
+}
+
+func (b *Bot) processCommand(command string) string {
+	switch command {
+	case "status":
+		return b.getSystemStatus()
+	case "snapshots":
+		return b.listSnapshots()
+	case "snapshot":
+		return b.triggerSnapshot()
+	default:
+		return b.showHelp()
+	}
+}
+
+func (b *Bot) showHelp() string {
+	return strings.Join([]string{
+		"🐰 <b>ZFSRabbit Commands</b>",
+		"/status - System and replication health",
+		"/snapshots - Recent snapshots",
+		"/snapshot - Create a snapshot and replicate it now",
+	}, "\n")
+}
+
+func (b *Bot) getSystemStatus() string {
+	status := b.monitor.GetSystemStatus()
+
+	healthy := true
+	if pools, ok := status["pools"].(map[string]interface{}); ok {
+		for _, pool := range pools {
+			if poolData, ok := pool.(map[string]interface{}); ok {
+				if state, ok := poolData["State"].(string); ok && state != "ONLINE" {
+					healthy = false
+					break
+				}
+			}
+		}
+	}
+
+	lines := []string{"🐰 <b>ZFSRabbit System Status</b>"}
+	if healthy {
+		lines = append(lines, "✅ <b>Overall Status:</b> All systems healthy")
+	} else {
+		lines = append(lines, "⚠️ <b>Overall Status:</b> Issues detected")
+	}
+
+	if pairs := b.scheduler.Health(); len(pairs) > 0 {
+		lines = append(lines, "", "<b>Replication Health:</b>")
+		for _, pair := range pairs {
+			line := fmt.Sprintf("%s %d `%s` → %s", pair.Emoji, pair.Score, pair.Dataset, pair.Target)
+			if len(pair.Issues) > 0 {
+				line += ": " + strings.Join(pair.Issues, "; ")
+			}
+			lines = append(lines, alert.TelegramHTML(line))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (b *Bot) triggerSnapshot() string {
+	if err := b.scheduler.TriggerSnapshot(); err != nil {
+		return alert.TelegramHTML(fmt.Sprintf("❌ Failed to trigger snapshot: %s", err.Error()))
+	}
+	return "📸 Snapshot creation started! Check back in a few minutes for completion status."
+}
+
+func (b *Bot) listSnapshots() string {
+	snapshots, err := b.zfsManager.ListSnapshots()
+	if err != nil {
+		return alert.TelegramHTML(fmt.Sprintf("❌ Failed to list snapshots: %s", err.Error()))
+	}
+	if len(snapshots) == 0 {
+		return "No snapshots found."
+	}
+
+	// Show last 10 snapshots
+	limit := 10
+	if len(snapshots) < limit {
+		limit = len(snapshots)
+	}
+
+	lines := []string{"<b>Recent Snapshots:</b>"}
+	for _, snap := range snapshots[len(snapshots)-limit:] {
+		lines = append(lines, alert.TelegramHTML(fmt.Sprintf("• `%s` - %s (%s)", snap.Name, display.ShortTime(snap.Created), snap.Used)))
+	}
+	return strings.Join(lines, "\n")
+}
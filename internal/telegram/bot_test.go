@@ -0,0 +1,138 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/zfs"
+	"zfsrabbit/test/mocks"
+)
+
+type mockExecutor struct{}
+
+func (m *mockExecutor) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command("echo", "mock")
+}
+
+func (m *mockExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return m.Command(name, args...)
+}
+
+func (m *mockExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
+	return []byte(""), nil
+}
+
+func (m *mockExecutor) Run(cmd *exec.Cmd) error {
+	return nil
+}
+
+func createTestBot(t *testing.T, apiBase string) *Bot {
+	cfg := &config.Config{
+		ZFS: config.ZFSConfig{Dataset: "tank/test", SendCompression: "lz4"},
+		SSH: config.SSHConfig{
+			RemoteHost:    "nonexistent.test.invalid",
+			RemoteUser:    "testuser",
+			RemoteDataset: "backup/test",
+		},
+		Telegram: config.TelegramConfig{
+			Enabled:  true,
+			BotToken: "123:secret",
+			ChatIDs:  []int64{42},
+			Commands: true,
+		},
+	}
+
+	zfsManager := zfs.NewWithExecutor(cfg.ZFS.Dataset, cfg.ZFS.SendCompression, false, &mockExecutor{})
+	sshTransport := transport.NewSSHTransport(&cfg.SSH)
+	mockAlerter := mocks.NewMockAlerter()
+	sched := scheduler.New(cfg, zfsManager, sshTransport, mockAlerter)
+	mon := monitor.New(cfg, mockAlerter)
+
+	bot := NewBot(&cfg.Telegram, sched, mon, zfsManager)
+	bot.apiBase = apiBase
+	return bot
+}
+
+func TestBotAnswersAllowedChats(t *testing.T) {
+	now := time.Now().Unix()
+	var mu sync.Mutex
+	var offsets []float64
+	replies := make(map[float64]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/bot123:secret/getUpdates":
+			offsets = append(offsets, body["offset"].(float64))
+			fmt.Fprintf(w, `{"ok":true,"result":[
+				{"update_id":7,"message":{"date":%[1]d,"chat":{"id":42},"text":"/snapshots@zfsrabbit_bot"}},
+				{"update_id":8,"message":{"date":%[1]d,"chat":{"id":99},"text":"/snapshot"}},
+				{"update_id":9,"message":{"date":%[2]d,"chat":{"id":42},"text":"/snapshot"}},
+				{"update_id":10,"message":{"date":%[1]d,"chat":{"id":42},"text":"hello"}}
+			]}`, now, now-3600)
+		case "/bot123:secret/sendMessage":
+			replies[body["chat_id"].(float64)] += body["text"].(string)
+			w.Write([]byte(`{"ok":true,"result":{}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	bot := createTestBot(t, server.URL)
+	for i := 0; i < 2; i++ {
+		if err := bot.poll(context.Background(), 0); err != nil {
+			t.Fatalf("poll failed: %v", err)
+		}
+	}
+
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 11 {
+		t.Errorf("Expected the offset to move past fetched updates, got %v", offsets)
+	}
+	if len(replies) != 1 || !strings.Contains(replies[42], "No snapshots found.") {
+		t.Errorf("Expected only the allowed chat's /snapshots answered, got %v", replies)
+	}
+	if strings.Contains(replies[42], "Snapshot creation started") {
+		t.Errorf("Expected /snapshot sent before startup to be skipped")
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	tests := map[string]string{
+		"/status":                 "status",
+		"/Snapshot@zfsrabbit_bot": "snapshot",
+		"/snapshots now":          "snapshots",
+		"status":                  "",
+		"":                        "",
+	}
+	for text, want := range tests {
+		if got := commandName(text); got != want {
+			t.Errorf("commandName(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestBotStatus(t *testing.T) {
+	bot := createTestBot(t, "")
+	if got := bot.processCommand("status"); !strings.Contains(got, "ZFSRabbit System Status") {
+		t.Errorf("Unexpected status reply: %q", got)
+	}
+	if got := bot.processCommand("help"); !strings.Contains(got, "/snapshot - ") {
+		t.Errorf("Unexpected help reply: %q", got)
+	}
+}
@@ -0,0 +1,149 @@
+// Package throughput records how fast streams moved to and from each backup
+// server, so the duration of a send or restore can be estimated from what
+// the link actually achieved rather than from its configured rate limit.
+package throughput
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/utils"
+)
+
+// Directions a stream can move in
+const (
+	Send    = "send"    // Replication to the backup server
+	Restore = "restore" // Restore from the backup server
+)
+
+// Transfers shorter than minElapsed are dominated by setup time and say
+// little about the link, so they are not recorded
+const minElapsed = time.Second
+
+// recentSamples is how many of a host's latest transfers the rate is taken from
+const recentSamples = 10
+
+// Sample is one finished transfer
+type Sample struct {
+	Host      string    `json:"host"`
+	Direction string    `json:"direction"`
+	Bytes     int64     `json:"bytes"`
+	Seconds   float64   `json:"seconds"`
+	Finished  time.Time `json:"finished"`
+}
+
+// History persists transfer samples per backup server
+type History struct {
+	path    string
+	mutex   sync.Mutex
+	samples []Sample
+}
+
+// Open loads the samples at path; an empty path keeps them in memory only
+func Open(path string) *History {
+	h := &History{path: path}
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &h.samples); err != nil {
+			log.Printf("Failed to load throughput history from %s: %v", path, err)
+		}
+	}
+	return h
+}
+
+// Record adds a transfer of size bytes to or from host that just took elapsed
+func (h *History) Record(host, direction string, size int64, elapsed time.Duration) {
+	if size <= 0 || elapsed < minElapsed {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.samples = append(h.samples, Sample{
+		Host:      host,
+		Direction: direction,
+		Bytes:     size,
+		Seconds:   elapsed.Seconds(),
+		Finished:  time.Now(),
+	})
+	h.saveLocked()
+}
+
+// Rate returns the median bytes per second of the latest transfers in
+// direction with host, falling back to transfers the other way when there
+// are none; 0 if nothing has been recorded
+func (h *History) Rate(host, direction string) float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if rate := h.rateLocked(host, direction); rate > 0 {
+		return rate
+	}
+	if direction == Restore {
+		return h.rateLocked(host, Send)
+	}
+	return h.rateLocked(host, Restore)
+}
+
+func (h *History) rateLocked(host, direction string) float64 {
+	var rates []float64
+	for i := len(h.samples) - 1; i >= 0 && len(rates) < recentSamples; i-- {
+		sample := h.samples[i]
+		if sample.Host == host && sample.Direction == direction {
+			rates = append(rates, float64(sample.Bytes)/sample.Seconds)
+		}
+	}
+	if len(rates) == 0 {
+		return 0
+	}
+
+	sort.Float64s(rates)
+	middle := len(rates) / 2
+	if len(rates)%2 == 0 {
+		return (rates[middle-1] + rates[middle]) / 2
+	}
+	return rates[middle]
+}
+
+// Estimate returns how long moving size bytes in direction with host should
+// take at the recorded rate; 0 if there is no history to go on
+func (h *History) Estimate(host, direction string, size int64) time.Duration {
+	rate := h.Rate(host, direction)
+	if rate == 0 || size <= 0 {
+		return 0
+	}
+	return time.Duration(float64(size) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// Compact drops samples that finished before before, then the oldest past maxRows
+func (h *History) Compact(before time.Time, maxRows int) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var kept []Sample
+	for _, sample := range h.samples {
+		if before.IsZero() || !sample.Finished.Before(before) {
+			kept = append(kept, sample)
+		}
+	}
+	if maxRows > 0 && len(kept) > maxRows {
+		kept = kept[len(kept)-maxRows:]
+	}
+
+	removed := len(h.samples) - len(kept)
+	if removed > 0 {
+		h.samples = kept
+		h.saveLocked()
+	}
+	return removed
+}
+
+func (h *History) saveLocked() {
+	if h.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(h.path, h.samples, 0600); err != nil {
+		log.Printf("Failed to save throughput history to %s: %v", h.path, err)
+	}
+}
@@ -0,0 +1,56 @@
+package throughput
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const mib = 1024 * 1024
+
+func TestRate(t *testing.T) {
+	history := Open(filepath.Join(t.TempDir(), "throughput.json"))
+	if rate := history.Rate("backup", Restore); rate != 0 {
+		t.Errorf("Expected no rate without history, got %f", rate)
+	}
+
+	// Sends stand in for restores until a restore has been recorded
+	history.Record("backup", Send, 100*mib, 10*time.Second)
+	if rate := history.Rate("backup", Restore); rate != 10*mib {
+		t.Errorf("Expected the send rate of 10 MiB/s, got %f", rate)
+	}
+
+	// The median ignores one slow outlier
+	history.Record("backup", Restore, 40*mib, 10*time.Second)
+	history.Record("backup", Restore, 50*mib, 10*time.Second)
+	history.Record("backup", Restore, 1*mib, 10*time.Second)
+	if rate := history.Rate("backup", Restore); rate != 4*mib {
+		t.Errorf("Expected the median restore rate of 4 MiB/s, got %f", rate)
+	}
+
+	// Too short to measure, and other hosts, don't count
+	history.Record("backup", Restore, 500*mib, 100*time.Millisecond)
+	history.Record("offsite", Restore, 500*mib, 10*time.Second)
+	if estimate := history.Estimate("backup", Restore, 8100*4*mib); estimate != 2*time.Hour+15*time.Minute {
+		t.Errorf("Expected 2h15m, got %s", estimate)
+	}
+}
+
+func TestPersistAndCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "throughput.json")
+	history := Open(path)
+	for i := 0; i < 5; i++ {
+		history.Record("backup", Send, 10*mib, time.Second)
+	}
+
+	reopened := Open(path)
+	if rate := reopened.Rate("backup", Send); rate != 10*mib {
+		t.Fatalf("Expected the samples to survive a restart, got a rate of %f", rate)
+	}
+	if removed := reopened.Compact(time.Time{}, 2); removed != 3 {
+		t.Errorf("Expected 3 samples compacted, got %d", removed)
+	}
+	if removed := reopened.Compact(time.Now().Add(time.Minute), 0); removed != 2 {
+		t.Errorf("Expected the rest to be older than the cutoff, got %d removed", removed)
+	}
+}
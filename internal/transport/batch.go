@@ -0,0 +1,156 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBatchSize bounds how many commands go into one remote script
+const maxBatchSize = 200
+
+// BatchResult is the output of one command in a batch
+type BatchResult struct {
+	Output   string
+	ExitCode int
+}
+
+// RunBatch runs several remote commands in a single SSH session. The commands
+// are combined into one shell script that wraps each command's stdout in
+// delimiter lines, so a refresh that used to cost one round trip per command
+// costs one per batch. Results are returned in command order; a failing
+// command is reported through its ExitCode rather than failing the batch.
+func (t *SSHTransport) RunBatch(commands []string) ([]BatchResult, error) {
+	results := make([]BatchResult, 0, len(commands))
+
+	for start := 0; start < len(commands); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(commands) {
+			end = len(commands)
+		}
+
+		chunk, err := t.runBatchChunk(commands[start:end])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunk...)
+	}
+
+	return results, nil
+}
+
+func (t *SSHTransport) runBatchChunk(commands []string) (_ []BatchResult, err error) {
+	client, err := t.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	defer observeCommand(opBatch, time.Now(), &err)
+
+	token, err := batchToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	// Feed the script on stdin so large batches don't hit argument length limits
+	session.Stdin = strings.NewReader(buildBatchScript(token, commands))
+
+	if err := session.Run("sh -s"); err != nil {
+		return nil, fmt.Errorf("batch execution failed: %w", err)
+	}
+
+	return parseBatchOutput(stdout.String(), token, len(commands))
+}
+
+func batchToken() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func buildBatchScript(token string, commands []string) string {
+	var script strings.Builder
+	for i, command := range commands {
+		fmt.Fprintf(&script, "echo '%s %d begin'\n", batchMarker(token), i)
+		// Subshell keeps one command's cd/exit/set from leaking into the next
+		fmt.Fprintf(&script, "( %s ) 2>/dev/null; rc=$?\n", command)
+		fmt.Fprintf(&script, "echo; echo \"%s %d end $rc\"\n", batchMarker(token), i)
+	}
+	return script.String()
+}
+
+func batchMarker(token string) string {
+	return "__ZFSRABBIT_BATCH_" + token
+}
+
+// parseBatchOutput splits the script output back into per-command results
+func parseBatchOutput(output, token string, count int) ([]BatchResult, error) {
+	marker := batchMarker(token)
+	results := make([]BatchResult, count)
+	seen := make([]bool, count)
+
+	current := -1
+	var body strings.Builder
+
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, marker+" ") {
+			if current >= 0 {
+				body.WriteString(line)
+				body.WriteString("\n")
+			}
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, marker))
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("malformed batch marker: %q", line)
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil || index < 0 || index >= count {
+			return nil, fmt.Errorf("malformed batch marker: %q", line)
+		}
+
+		switch fields[1] {
+		case "begin":
+			current = index
+			body.Reset()
+		case "end":
+			if index != current || len(fields) != 3 {
+				return nil, fmt.Errorf("malformed batch marker: %q", line)
+			}
+			exitCode, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("malformed batch marker: %q", line)
+			}
+			// The script adds a newline before the end marker in case output lacked one
+			out := strings.TrimSuffix(body.String(), "\n")
+			results[index] = BatchResult{Output: strings.TrimSuffix(out, "\n"), ExitCode: exitCode}
+			seen[index] = true
+			current = -1
+		default:
+			return nil, fmt.Errorf("malformed batch marker: %q", line)
+		}
+	}
+
+	for i, ok := range seen {
+		if !ok {
+			return nil, fmt.Errorf("batch output incomplete: no result for command %d", i)
+		}
+	}
+
+	return results, nil
+}
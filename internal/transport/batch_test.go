@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatchScriptRoundTrip(t *testing.T) {
+	commands := []string{
+		"printf 'tank/a\\ntank/b\\n'",
+		"printf 'no trailing newline'",
+		"exit 3",
+		"true",
+		"echo one; echo two >&2; exit 1",
+	}
+
+	token := "deadbeef"
+	cmd := exec.Command("sh", "-s")
+	cmd.Stdin = strings.NewReader(buildBatchScript(token, commands))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Batch script failed: %v", err)
+	}
+
+	results, err := parseBatchOutput(string(output), token, len(commands))
+	if err != nil {
+		t.Fatalf("parseBatchOutput failed: %v", err)
+	}
+
+	expected := []BatchResult{
+		{Output: "tank/a\ntank/b", ExitCode: 0},
+		{Output: "no trailing newline", ExitCode: 0},
+		{Output: "", ExitCode: 3},
+		{Output: "", ExitCode: 0},
+		{Output: "one", ExitCode: 1},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, results)
+	}
+}
+
+func TestParseBatchOutputIncomplete(t *testing.T) {
+	output := "__ZFSRABBIT_BATCH_abc 0 begin\nfoo\n\n__ZFSRABBIT_BATCH_abc 0 end 0\n__ZFSRABBIT_BATCH_abc 1 begin\nbar\n"
+
+	if _, err := parseBatchOutput(output, "abc", 2); err == nil {
+		t.Error("Expected error for truncated batch output")
+	}
+}
+
+func TestGroupSnapshotsByDataset(t *testing.T) {
+	datasets := "backup\nbackup/host1\nbackup/host2\n"
+	snapshots := "backup/host1@snap1\nbackup/host1@snap2\nbackup/host2@snap1\nother/gone@snap1\n"
+
+	grouped := groupSnapshotsByDataset(datasets, snapshots)
+
+	expected := map[string][]string{
+		"backup/host1": {"snap1", "snap2"},
+		"backup/host2": {"snap1"},
+	}
+	if !reflect.DeepEqual(grouped, expected) {
+		t.Errorf("Expected %v, got %v", expected, grouped)
+	}
+}
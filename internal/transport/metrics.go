@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"io"
+	"time"
+
+	"zfsrabbit/internal/metrics"
+)
+
+// Operation labels for per-command metrics
+const (
+	opExec     = "exec"
+	opBatch    = "batch"
+	opSend     = "send"
+	opRestore  = "restore"
+	opUpload   = "upload"
+	opDownload = "download"
+)
+
+var (
+	connectAttempts = metrics.NewCounter("zfsrabbit_ssh_connect_attempts_total",
+		"SSH connection attempts to the backup server")
+	connectFailures = metrics.NewCounter("zfsrabbit_ssh_connect_failures_total",
+		"SSH connection attempts that failed")
+	reconnects = metrics.NewCounter("zfsrabbit_ssh_reconnects_total",
+		"SSH connections established after an earlier connection was lost or closed")
+	connectDuration = metrics.NewHistogram("zfsrabbit_ssh_connect_duration_seconds",
+		"Time to dial and authenticate an SSH connection", nil)
+	commandDuration = metrics.NewHistogram("zfsrabbit_ssh_command_duration_seconds",
+		"Time spent running a remote command, excluding connection setup", nil, "operation")
+	commandFailures = metrics.NewCounter("zfsrabbit_ssh_command_failures_total",
+		"Remote commands that returned an error", "operation")
+	bytesSent = metrics.NewCounter("zfsrabbit_ssh_bytes_sent_total",
+		"Bytes streamed to the backup server", "operation")
+	bytesReceived = metrics.NewCounter("zfsrabbit_ssh_bytes_received_total",
+		"Bytes streamed from the backup server", "operation")
+)
+
+// observeCommand records the latency and outcome of a remote command. It is
+// deferred with a pointer to the caller's named error result.
+func observeCommand(operation string, start time.Time, err *error) {
+	commandDuration.Observe(time.Since(start).Seconds(), operation)
+	if *err != nil {
+		commandFailures.Inc(operation)
+	}
+}
+
+// countingReader counts bytes read through it into a counter
+type countingReader struct {
+	r         io.Reader
+	counter   *metrics.Counter
+	operation string
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.counter.Add(float64(n), c.operation)
+	}
+	return n, err
+}
+
+// countingWriter counts bytes written through it into a counter
+type countingWriter struct {
+	w         io.Writer
+	counter   *metrics.Counter
+	operation string
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		c.counter.Add(float64(n), c.operation)
+	}
+	return n, err
+}
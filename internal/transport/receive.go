@@ -0,0 +1,176 @@
+package transport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"zfsrabbit/internal/orphan"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/validation"
+)
+
+// ProgressInfo contains real-time transfer progress data
+type ProgressInfo struct {
+	BytesTransferred int64
+	TotalBytes       int64   // 0 when the stream size is unknown
+	TransferRate     float64 // MB/sec
+	ETA              string
+	Percentage       float64
+}
+
+// progressTracker turns byte counts into progress reports
+type progressTracker struct {
+	total   int64
+	report  func(ProgressInfo)
+	started time.Time
+}
+
+// newProgressTracker returns nil when nobody wants progress reports
+func newProgressTracker(total int64, report func(ProgressInfo)) *progressTracker {
+	if report == nil {
+		return nil
+	}
+	return &progressTracker{total: total, report: report, started: time.Now()}
+}
+
+func (p *progressTracker) update(bytes int64, now time.Time) ProgressInfo {
+	info := MeasureProgress(bytes, p.total, now.Sub(p.started))
+	p.report(info)
+	return info
+}
+
+// MeasureProgress works out the rate, percentage and ETA of a transfer that
+// has moved bytes of total in elapsed
+func MeasureProgress(bytes, total int64, elapsed time.Duration) ProgressInfo {
+	info := ProgressInfo{BytesTransferred: bytes, TotalBytes: total}
+
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		info.TransferRate = float64(bytes) / seconds / (1024 * 1024)
+	}
+	if total > 0 {
+		info.Percentage = float64(bytes) * 100 / float64(total)
+		if info.Percentage > 100 {
+			info.Percentage = 100
+		}
+		if info.TransferRate > 0 && bytes < total {
+			remaining := float64(total-bytes) / (info.TransferRate * 1024 * 1024)
+			info.ETA = (time.Duration(remaining) * time.Second).String()
+		}
+	}
+	return info
+}
+
+// parseProgressLine reads a byte count printed by pv -n -b
+func parseProgressLine(line string) (int64, bool) {
+	bytes, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+	if err != nil || bytes < 0 {
+		return 0, false
+	}
+	return bytes, true
+}
+
+// receiver feeds a stream into a single local zfs receive. pv counts the
+// bytes on their way in and prints the running total to stderr every
+// second, which is parsed into progress reports; any other stderr output is
+// kept for the error message.
+type receiver struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	done   chan struct{} // Closed once stderr has been read to the end
+	stderr []string
+}
+
+// startReceiver starts a zfs receive into dataset. With a mountpoint the
+// stream is received unmounted, with that mountpoint inherited by every
+// dataset in it and canmount=noauto, so nothing mounts over a path taken
+// from the stream.
+func startReceiver(dataset, bufferSize string, forceOverwrite bool, mountpoint string, progress *progressTracker) (*receiver, error) {
+	if mountpoint != "" {
+		if err := validation.ValidateMountpoint(mountpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sanitize inputs to prevent command injection
+	sanitizedSize := validation.SanitizeCommand(bufferSize)
+	sanitizedDataset := validation.SanitizeCommand(dataset)
+
+	// Use -d flag to strip first element of path (avoids nesting issues)
+	// Example: remote "data1/helix-backup" -> local "data" (strips "data1")
+	receiveFlags := "-d"
+	if forceOverwrite {
+		receiveFlags += " -F" // Add force flag for destructive operations
+	}
+	if mountpoint != "" {
+		receiveFlags += " -u -o mountpoint=" + mountpoint + " -o canmount=noauto"
+	}
+
+	// pv reports progress, mbuffer smooths out the network stream
+	command := fmt.Sprintf("pv -n -b -f -i 1 | mbuffer -q -s 128k -m %s | zfs receive %s %s",
+		sanitizedSize, receiveFlags, sanitizedDataset)
+	cmd := utils.DefaultRunner.Command("sh", "-c", command)
+	// Run the pipeline in its own process group so Kill stops all of it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	orphan.Mark(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	r := &receiver{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go r.readStderr(stderr, progress)
+	return r, nil
+}
+
+func (r *receiver) readStderr(stderr io.Reader, progress *progressTracker) {
+	defer close(r.done)
+
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if bytes, ok := parseProgressLine(line); ok {
+			if progress != nil {
+				progress.update(bytes, time.Now())
+			}
+			continue
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			r.stderr = append(r.stderr, line)
+		}
+	}
+}
+
+func (r *receiver) Write(p []byte) (int, error) {
+	return r.stdin.Write(p)
+}
+
+// Kill stops the receive pipeline; zfs receive discards the partial stream
+func (r *receiver) Kill() {
+	if r.cmd.Process != nil {
+		syscall.Kill(-r.cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// Close ends the stream and waits for zfs receive to finish
+func (r *receiver) Close() error {
+	r.stdin.Close()
+	<-r.done
+	if err := r.cmd.Wait(); err != nil {
+		return fmt.Errorf("zfs receive failed: %v: %s", err, strings.Join(r.stderr, "; "))
+	}
+	return nil
+}
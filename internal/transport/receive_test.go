@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	if bytes, ok := parseProgressLine("524288\n"); !ok || bytes != 524288 {
+		t.Errorf("Expected 524288, got %d (%v)", bytes, ok)
+	}
+	if _, ok := parseProgressLine("cannot receive new filesystem stream: destination exists"); ok {
+		t.Error("Expected zfs receive errors not to parse as progress")
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	var reports []ProgressInfo
+	tracker := newProgressTracker(400*1024*1024, func(info ProgressInfo) { reports = append(reports, info) })
+
+	info := tracker.update(100*1024*1024, tracker.started.Add(10*time.Second))
+	if info.Percentage != 25 {
+		t.Errorf("Expected 25%%, got %v", info.Percentage)
+	}
+	if info.TransferRate != 10 {
+		t.Errorf("Expected 10 MB/sec, got %v", info.TransferRate)
+	}
+	if info.ETA != "30s" {
+		t.Errorf("Expected 30s remaining, got %q", info.ETA)
+	}
+	if len(reports) != 1 {
+		t.Errorf("Expected the update to be reported, got %d reports", len(reports))
+	}
+
+	if newProgressTracker(0, nil) != nil {
+		t.Error("Expected no tracker without a report function")
+	}
+}
@@ -1,23 +1,34 @@
 package transport
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/logging"
+	"zfsrabbit/internal/utils"
 	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/zfs"
 )
 
+var logger = logging.For("transport")
+
 type SSHTransport struct {
+	mu     sync.Mutex // Guards config, client and connected
 	config *config.SSHConfig
 	client *ssh.Client
+	// connected is set once a connection has succeeded, so later connects count as reconnects
+	connected bool
 }
 
 func NewSSHTransport(cfg *config.SSHConfig) *SSHTransport {
@@ -27,8 +38,31 @@ func NewSSHTransport(cfg *config.SSHConfig) *SSHTransport {
 }
 
 func (t *SSHTransport) Connect() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connect()
+}
+
+// connection returns the client, connecting first if there isn't one
+func (t *SSHTransport) connection() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == nil {
+		if err := t.connect(); err != nil {
+			return nil, err
+		}
+	}
+	return t.client, nil
+}
+
+// connect dials the backup server; the caller holds t.mu
+func (t *SSHTransport) connect() error {
+	connectAttempts.Inc()
+	start := time.Now()
+
 	key, err := loadPrivateKey(t.config.PrivateKey)
 	if err != nil {
+		connectFailures.Inc()
 		return fmt.Errorf("failed to load private key: %w", err)
 	}
 
@@ -49,59 +83,157 @@ func (t *SSHTransport) Connect() error {
 
 	client, err := ssh.Dial("tcp", host, config)
 	if err != nil {
+		connectFailures.Inc()
 		return fmt.Errorf("failed to connect to remote host: %w", err)
 	}
+	connectDuration.Observe(time.Since(start).Seconds())
 
+	if t.connected {
+		reconnects.Inc()
+	}
+	logger.Debug("Connected to backup server", "host", host, "reconnect", t.connected, "duration", time.Since(start))
+	t.connected = true
 	t.client = client
 	return nil
 }
 
 func (t *SSHTransport) Close() error {
-	if t.client != nil {
-		return t.client.Close()
+	t.mu.Lock()
+	client := t.client
+	t.client = nil
+	t.mu.Unlock()
+
+	if client != nil {
+		return client.Close()
 	}
 	return nil
 }
 
+// Reconfigure points the transport at a different backup server or dataset,
+// e.g. after a policy change. The current connection is closed, so callers
+// make sure no transfer is using it.
+func (t *SSHTransport) Reconfigure(cfg *config.SSHConfig) {
+	t.mu.Lock()
+	t.config = cfg
+	t.mu.Unlock()
+	t.Close()
+}
+
+// Config returns the SSH config in effect, which Reconfigure can replace
+func (t *SSHTransport) Config() *config.SSHConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.config
+}
+
+// dryRun logs an operation that would change the backup server and reports
+// whether it must be skipped because the daemon is in dry-run mode
+func (t *SSHTransport) dryRun(format string, args ...interface{}) bool {
+	if !utils.DefaultRunner.DryRun() {
+		return false
+	}
+	logger.Info("Dry run: would "+fmt.Sprintf(format, args...), "host", t.RemoteHost())
+	return true
+}
+
 func (t *SSHTransport) SendSnapshot(snapshotReader io.Reader, isIncremental bool) error {
-	if t.client == nil {
-		if err := t.Connect(); err != nil {
-			return err
-		}
+	return t.SendSnapshotTo(snapshotReader, t.RemoteDataset(), isIncremental)
+}
+
+// SendSnapshotTo receives a send stream into remoteDataset instead of the configured one
+func (t *SSHTransport) SendSnapshotTo(snapshotReader io.Reader, remoteDataset string, isIncremental bool) error {
+	return t.receive(snapshotReader, remoteDataset, "-F")
+}
+
+// SendSnapshotResumable is SendSnapshot with zfs receive -s, so an interrupted
+// transfer leaves a receive_resume_token to continue from rather than being
+// discarded. Recursive (-R) streams can't be resumed and should use SendSnapshot.
+func (t *SSHTransport) SendSnapshotResumable(snapshotReader io.Reader) error {
+	return t.receive(snapshotReader, t.RemoteDataset(), "-s -F")
+}
+
+// RemoteResumeToken returns the receive_resume_token left on the configured
+// remote dataset by an interrupted resumable receive, or "" if there is none
+func (t *SSHTransport) RemoteResumeToken() (string, error) {
+	output, err := t.ExecuteCommand(fmt.Sprintf("zfs get -H -o value receive_resume_token \"%s\"", validation.SanitizeCommand(t.RemoteDataset())))
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(output)
+	if token == "-" {
+		return "", nil
+	}
+	return token, nil
+}
+
+// AbortPartialReceive discards the saved state of an interrupted resumable
+// receive, which otherwise blocks any new receive into the dataset
+func (t *SSHTransport) AbortPartialReceive() error {
+	if t.dryRun("abort the partial receive into %s", t.RemoteDataset()) {
+		return nil
+	}
+	if _, err := t.ExecuteCommand(fmt.Sprintf("zfs receive -A \"%s\"", validation.SanitizeCommand(t.RemoteDataset()))); err != nil {
+		return fmt.Errorf("failed to abort partial receive on %s: %w", t.RemoteDataset(), err)
+	}
+	return nil
+}
+
+func (t *SSHTransport) receive(snapshotReader io.Reader, remoteDataset, receiveFlags string) (err error) {
+	if t.dryRun("receive a stream into %s", remoteDataset) {
+		// Drain the stream so the local zfs send can finish
+		_, err := io.Copy(io.Discard, snapshotReader)
+		return err
+	}
+	client, err := t.connection()
+	if err != nil {
+		return err
 	}
 
-	session, err := t.client.NewSession()
+	defer observeCommand(opSend, time.Now(), &err)
+
+	session, err := client.NewSession()
 	if err != nil {
 		return fmt.Errorf("failed to create SSH session: %w", err)
 	}
 	defer session.Close()
 
+	settings := t.Config()
+
 	// Sanitize dataset name to prevent command injection
-	sanitizedDataset := validation.SanitizeCommand(t.config.RemoteDataset)
-	sanitizedMbufferSize := validation.SanitizeCommand(t.config.MbufferSize)
+	sanitizedDataset := validation.SanitizeCommand(remoteDataset)
+	sanitizedMbufferSize := validation.SanitizeCommand(settings.MbufferSize)
 
 	// Build command safely - BACKUP OPERATIONS: Use -F for automation (backup server should be clean)
 	// This prioritizes automation over data safety on backup server (expected behavior)
-	receiveCmd := fmt.Sprintf("mbuffer -s 128k -m %s | zfs receive -F %s",
-		sanitizedMbufferSize, sanitizedDataset)
+	receiveCmd := fmt.Sprintf("mbuffer -s 128k -m %s | zfs receive %s %s",
+		sanitizedMbufferSize, receiveFlags, sanitizedDataset)
 
-	session.Stdin = snapshotReader
+	// Throttle in-process so no extra tools are needed on either end
+	if rate, _ := config.ParseRate(settings.MaxSendRate); rate > 0 {
+		snapshotReader = newThrottledReader(snapshotReader, rate)
+	}
+
+	logger.Debug("Receiving stream", "host", settings.RemoteHost, "command", receiveCmd)
+	session.Stdin = &countingReader{r: snapshotReader, counter: bytesSent, operation: opSend}
 	return session.Run(receiveCmd)
 }
 
-func (t *SSHTransport) ExecuteCommand(command string) (string, error) {
-	if t.client == nil {
-		if err := t.Connect(); err != nil {
-			return "", err
-		}
+func (t *SSHTransport) ExecuteCommand(command string) (_ string, err error) {
+	client, err := t.connection()
+	if err != nil {
+		return "", err
 	}
 
-	session, err := t.client.NewSession()
+	defer observeCommand(opExec, time.Now(), &err)
+
+	session, err := client.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("failed to create SSH session: %w", err)
 	}
 	defer session.Close()
 
+	logger.Debug("Running remote command", "host", t.RemoteHost(), "command", command)
 	output, err := session.Output(command)
 	if err != nil {
 		return "", fmt.Errorf("command execution failed: %w", err)
@@ -110,8 +242,170 @@ func (t *SSHTransport) ExecuteCommand(command string) (string, error) {
 	return string(output), nil
 }
 
+// UploadFile streams r to remotePath on the backup server. The file is written
+// under a temporary name and renamed so a partial upload never replaces a good copy.
+func (t *SSHTransport) UploadFile(remotePath string, r io.Reader) (err error) {
+	if t.dryRun("upload %s", remotePath) {
+		return nil
+	}
+	client, err := t.connection()
+	if err != nil {
+		return err
+	}
+
+	defer observeCommand(opUpload, time.Now(), &err)
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	sanitizedPath := validation.SanitizeCommand(remotePath)
+	sanitizedDir := validation.SanitizeCommand(filepath.Dir(remotePath))
+	uploadCmd := fmt.Sprintf("mkdir -p \"%s\" && cat > \"%s.tmp\" && mv \"%s.tmp\" \"%s\"",
+		sanitizedDir, sanitizedPath, sanitizedPath, sanitizedPath)
+
+	session.Stdin = &countingReader{r: r, counter: bytesSent, operation: opUpload}
+	if err := session.Run(uploadCmd); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// DownloadFile streams remotePath from the backup server into w
+func (t *SSHTransport) DownloadFile(remotePath string, w io.Writer) (err error) {
+	client, err := t.connection()
+	if err != nil {
+		return err
+	}
+
+	defer observeCommand(opDownload, time.Now(), &err)
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdout = &countingWriter{w: w, counter: bytesReceived, operation: opDownload}
+	if err := session.Run(fmt.Sprintf("cat \"%s\"", validation.SanitizeCommand(remotePath))); err != nil {
+		return fmt.Errorf("failed to download %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// DestroyRemoteSnapshotRange destroys first through last (inclusive) on the
+// configured remote dataset and its children
+func (t *SSHTransport) DestroyRemoteSnapshotRange(first, last string) error {
+	if err := validation.ValidateSnapshotName(first); err != nil {
+		return err
+	}
+	if err := validation.ValidateSnapshotName(last); err != nil {
+		return err
+	}
+
+	target := fmt.Sprintf("%s@%s%%%s", validation.SanitizeCommand(t.RemoteDataset()), first, last)
+	if t.dryRun("destroy %s", target) {
+		return nil
+	}
+	if _, err := t.ExecuteCommand(fmt.Sprintf("zfs destroy -r \"%s\"", target)); err != nil {
+		return fmt.Errorf("failed to destroy remote snapshots %s: %w", target, err)
+	}
+	return nil
+}
+
+// DestroyRemoteSnapshots destroys the named snapshots on the configured
+// remote dataset and its children in one command
+func (t *SSHTransport) DestroyRemoteSnapshots(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	for _, name := range names {
+		if err := validation.ValidateSnapshotName(name); err != nil {
+			return err
+		}
+	}
+
+	target := fmt.Sprintf("%s@%s", validation.SanitizeCommand(t.RemoteDataset()), strings.Join(names, ","))
+	if t.dryRun("destroy %s", target) {
+		return nil
+	}
+	if _, err := t.ExecuteCommand(fmt.Sprintf("zfs destroy -r \"%s\"", target)); err != nil {
+		return fmt.Errorf("failed to destroy remote snapshots %s: %w", target, err)
+	}
+	return nil
+}
+
+// ArchiveRemoteSnapshot writes a replication stream of snapshot to
+// archivePath on the backup server and returns its size. The stream is
+// incremental from base, which must still be on the pool, or full if base is
+// empty. It goes to a temporary file first so a partial archive is never
+// mistaken for a complete one.
+func (t *SSHTransport) ArchiveRemoteSnapshot(snapshot, base, archivePath string) (int64, error) {
+	if err := validation.ValidateSnapshotName(snapshot); err != nil {
+		return 0, err
+	}
+	flags := "-R"
+	if base != "" {
+		if err := validation.ValidateSnapshotName(base); err != nil {
+			return 0, err
+		}
+		flags += " -i @" + base
+	}
+	if err := validation.ValidateArchivePath(archivePath); err != nil {
+		return 0, err
+	}
+
+	source := fmt.Sprintf("%s@%s", validation.SanitizeCommand(t.RemoteDataset()), snapshot)
+	if t.dryRun("archive %s to %s", source, archivePath) {
+		return 0, nil
+	}
+	cmd := fmt.Sprintf("mkdir -p \"%s\" && zfs send %s \"%s\" > \"%s.partial\" && mv \"%s.partial\" \"%s\" && wc -c < \"%s\"",
+		filepath.Dir(archivePath), flags, source, archivePath, archivePath, archivePath, archivePath)
+	output, err := t.ExecuteCommand(cmd)
+	if err != nil {
+		t.ExecuteCommand(fmt.Sprintf("rm -f \"%s.partial\"", archivePath))
+		return 0, fmt.Errorf("failed to archive %s to %s: %w", source, archivePath, err)
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected size of archive %s: %q", archivePath, output)
+	}
+	return size, nil
+}
+
+// ReplaceRemoteDataset swaps a fully received staging dataset in place of the configured remote dataset
+func (t *SSHTransport) ReplaceRemoteDataset(staging string) error {
+	if err := validation.ValidateDatasetName(staging); err != nil {
+		return err
+	}
+
+	if t.dryRun("replace %s with %s", t.RemoteDataset(), staging) {
+		return nil
+	}
+
+	remote := validation.SanitizeCommand(t.RemoteDataset())
+	cmd := fmt.Sprintf("zfs destroy -r \"%s\" && zfs rename \"%s\" \"%s\"", remote, validation.SanitizeCommand(staging), remote)
+	if _, err := t.ExecuteCommand(cmd); err != nil {
+		return fmt.Errorf("failed to replace %s with %s: %w", t.RemoteDataset(), staging, err)
+	}
+	return nil
+}
+
+// RemoteDataset returns the configured dataset on the backup server
+func (t *SSHTransport) RemoteDataset() string {
+	return t.Config().RemoteDataset
+}
+
+// RemoteHost returns the backup server this transport connects to
+func (t *SSHTransport) RemoteHost() string {
+	return t.Config().RemoteHost
+}
+
 func (t *SSHTransport) ListRemoteSnapshots() ([]string, error) {
-	output, err := t.ExecuteCommand(fmt.Sprintf("zfs list -t snapshot -H -o name %s", t.config.RemoteDataset))
+	output, err := t.ExecuteCommand(fmt.Sprintf("zfs list -t snapshot -H -o name %s", t.RemoteDataset()))
 	if err != nil {
 		return nil, err
 	}
@@ -130,35 +424,53 @@ func (t *SSHTransport) ListRemoteSnapshots() ([]string, error) {
 	return snapshots, nil
 }
 
+// ListRemoteSnapshotGUIDs returns the remote dataset's own snapshots with
+// their GUIDs, oldest first
+func (t *SSHTransport) ListRemoteSnapshotGUIDs() ([]zfs.SnapshotGUID, error) {
+	output, err := t.ExecuteCommand(fmt.Sprintf("zfs list -t snapshot -H -o name,guid -s creation -d 1 \"%s\"",
+		validation.SanitizeCommand(t.RemoteDataset())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %w", t.RemoteDataset(), err)
+	}
+	return zfs.ParseSnapshotGUIDs(output), nil
+}
+
 func (t *SSHTransport) ListAllRemoteDatasets() (map[string][]string, error) {
-	// Get all datasets on remote server
-	output, err := t.ExecuteCommand("zfs list -H -o name -t filesystem,volume")
+	// Datasets and all their snapshots in one round trip rather than one per dataset
+	results, err := t.RunBatch([]string{
+		"zfs list -H -o name -t filesystem,volume",
+		"zfs list -H -o name -t snapshot",
+	})
 	if err != nil {
 		return nil, err
 	}
+	if results[0].ExitCode != 0 {
+		return nil, fmt.Errorf("failed to list remote datasets (exit %d)", results[0].ExitCode)
+	}
 
-	datasets := make(map[string][]string)
-	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return groupSnapshotsByDataset(results[0].Output, results[1].Output), nil
+}
 
-	for _, dataset := range lines {
-		dataset = strings.TrimSpace(dataset)
-		if dataset == "" {
-			continue
+// groupSnapshotsByDataset maps each listed dataset to its snapshot names,
+// leaving out datasets without snapshots
+func groupSnapshotsByDataset(datasetOutput, snapshotOutput string) map[string][]string {
+	known := make(map[string]bool)
+	for _, dataset := range strings.Split(datasetOutput, "\n") {
+		if dataset = strings.TrimSpace(dataset); dataset != "" {
+			known[dataset] = true
 		}
+	}
 
-		// Get snapshots for this dataset
-		snapshots, err := t.GetSnapshotsForDataset(dataset)
-		if err != nil {
-			// Continue if we can't get snapshots for this dataset
+	datasets := make(map[string][]string)
+	for _, line := range strings.Split(snapshotOutput, "\n") {
+		dataset, snapshot, ok := strings.Cut(strings.TrimSpace(line), "@")
+		if !ok || !known[dataset] {
 			continue
 		}
-
-		if len(snapshots) > 0 {
-			datasets[dataset] = snapshots
-		}
+		datasets[dataset] = append(datasets[dataset], snapshot)
 	}
 
-	return datasets, nil
+	return datasets
 }
 
 func (t *SSHTransport) GetSnapshotsForDataset(dataset string) ([]string, error) {
@@ -167,6 +479,10 @@ func (t *SSHTransport) GetSnapshotsForDataset(dataset string) ([]string, error)
 		return nil, err
 	}
 
+	return parseSnapshotNames(output), nil
+}
+
+func parseSnapshotNames(output string) []string {
 	var snapshots []string
 	lines := strings.Split(strings.TrimSpace(output), "\n")
 	for _, line := range lines {
@@ -178,16 +494,22 @@ func (t *SSHTransport) GetSnapshotsForDataset(dataset string) ([]string, error)
 		}
 	}
 
-	return snapshots, nil
+	return snapshots
 }
 
 func (t *SSHTransport) GetRemoteDatasetInfo(dataset string) (*RemoteDatasetInfo, error) {
-	output, err := t.ExecuteCommand(fmt.Sprintf("zfs list -H -o name,used,avail,refer,mountpoint %s", dataset))
+	results, err := t.RunBatch([]string{
+		fmt.Sprintf("zfs list -H -o name,used,avail,refer,mountpoint %s", dataset),
+		fmt.Sprintf("zfs list -t snapshot -H -o name %s", dataset),
+	})
 	if err != nil {
 		return nil, err
 	}
+	if results[0].ExitCode != 0 {
+		return nil, fmt.Errorf("dataset %s not found", dataset)
+	}
 
-	lines := strings.Split(strings.TrimSpace(output), "\n")
+	lines := strings.Split(strings.TrimSpace(results[0].Output), "\n")
 	if len(lines) == 0 || lines[0] == "" {
 		return nil, fmt.Errorf("dataset %s not found", dataset)
 	}
@@ -197,7 +519,7 @@ func (t *SSHTransport) GetRemoteDatasetInfo(dataset string) (*RemoteDatasetInfo,
 		return nil, fmt.Errorf("invalid dataset info format")
 	}
 
-	snapshots, _ := t.GetSnapshotsForDataset(dataset)
+	snapshots := parseSnapshotNames(results[1].Output)
 
 	return &RemoteDatasetInfo{
 		Name:       fields[0],
@@ -219,104 +541,216 @@ type RemoteDatasetInfo struct {
 }
 
 func (t *SSHTransport) RestoreSnapshot(snapshotName, localDataset string) error {
-	return t.RestoreSnapshotFromDataset(t.config.RemoteDataset, snapshotName, localDataset)
+	return t.RestoreSnapshotFromDataset(t.RemoteDataset(), snapshotName, localDataset)
 }
 
 func (t *SSHTransport) RestoreSnapshotSafe(snapshotName, localDataset string) error {
-	return t.RestoreSnapshotFromDatasetSafe(t.config.RemoteDataset, snapshotName, localDataset)
+	return t.RestoreSnapshotFromDatasetSafe(t.RemoteDataset(), snapshotName, localDataset)
 }
 
 func (t *SSHTransport) RestoreSnapshotFromDataset(remoteDataset, snapshotName, localDataset string) error {
-	return t.restoreSnapshotFromDataset(remoteDataset, snapshotName, localDataset, true) // Force mode
+	return t.Restore(context.Background(), RestoreRequest{RemoteDataset: remoteDataset, Snapshot: snapshotName, LocalDataset: localDataset, Force: true})
 }
 
 func (t *SSHTransport) RestoreSnapshotFromDatasetSafe(remoteDataset, snapshotName, localDataset string) error {
-	return t.restoreSnapshotFromDataset(remoteDataset, snapshotName, localDataset, false) // Safe mode
+	return t.Restore(context.Background(), RestoreRequest{RemoteDataset: remoteDataset, Snapshot: snapshotName, LocalDataset: localDataset})
 }
 
-func (t *SSHTransport) restoreSnapshotFromDataset(remoteDataset, snapshotName, localDataset string, forceOverwrite bool) error {
-	if t.client == nil {
-		if err := t.Connect(); err != nil {
+// RestoreArchivedSnapshot receives a stream written by ArchiveRemoteSnapshot,
+// overwriting the local dataset if needed
+func (t *SSHTransport) RestoreArchivedSnapshot(archivePath, remoteDataset, localDataset string) error {
+	return t.Restore(context.Background(), RestoreRequest{RemoteDataset: remoteDataset, ArchivePath: archivePath, LocalDataset: localDataset, Force: true})
+}
+
+// RestoreArchivedSnapshotSafe receives an archived stream without overwriting
+func (t *SSHTransport) RestoreArchivedSnapshotSafe(archivePath, remoteDataset, localDataset string) error {
+	return t.Restore(context.Background(), RestoreRequest{RemoteDataset: remoteDataset, ArchivePath: archivePath, LocalDataset: localDataset})
+}
+
+// RestoreRequest describes a stream to receive from the backup server
+type RestoreRequest struct {
+	RemoteDataset string // Dataset the snapshot was taken from; the configured remote dataset if empty
+	Snapshot      string
+	Since         string // Send only the snapshots after this one (zfs send -I), which the local dataset has
+	ArchivePath   string // Receive this archived stream instead of sending Snapshot from the pool
+	LocalDataset  string
+	Force         bool               // Overwrite the local dataset (zfs receive -F)
+	Raw           bool               // Send as stored (zfs send -w), for datasets replicated raw
+	Single        bool               // Send RemoteDataset alone with its properties, not its whole tree
+	Mountpoint    string             // Receive unmounted with this mountpoint for the whole tree and canmount=noauto
+	TotalBytes    int64              // Stream size if known; estimated with a dry run send otherwise
+	Progress      func(ProgressInfo) // Called about once a second while the stream is received
+}
+
+// Restore receives a snapshot or archived stream from the backup server
+// into a local dataset, reporting progress as it goes. Cancelling ctx ends
+// the remote send and kills the local receive.
+func (t *SSHTransport) Restore(ctx context.Context, req RestoreRequest) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("restore cancelled: %w", err)
+	}
+	if req.RemoteDataset == "" {
+		req.RemoteDataset = t.RemoteDataset()
+	}
+	if req.ArchivePath != "" {
+		if err := validation.ValidateArchivePath(req.ArchivePath); err != nil {
 			return err
 		}
 	}
+	if _, err := t.connection(); err != nil {
+		return err
+	}
 
-	session, err := t.client.NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create SSH session: %w", err)
+	if t.dryRun("receive %s@%s into local %s", req.RemoteDataset, req.Snapshot, req.LocalDataset) {
+		return nil
 	}
-	defer session.Close()
 
-	// Determine send flags based on whether we need recursive send
-	sendCmd := fmt.Sprintf("zfs send -R %s@%s", remoteDataset, snapshotName) // Always use -R for full dataset trees
+	var sendCmd string
+	if req.ArchivePath != "" {
+		sendCmd = fmt.Sprintf("cat \"%s\"", req.ArchivePath)
+	} else {
+		flags := "-R" // Full dataset trees unless restored one dataset at a time
+		if req.Single {
+			flags = "-p"
+		}
+		if req.Raw {
+			flags += " -w"
+		}
+		if req.Since != "" {
+			flags += " -I @" + validation.SanitizeCommand(req.Since)
+		}
+		sendCmd = fmt.Sprintf("zfs send %s %s@%s", flags, req.RemoteDataset, req.Snapshot)
 
-	session.Stdout = &mbufferReceiver{
-		dataset:        localDataset,
-		size:           t.config.MbufferSize,
-		forceOverwrite: forceOverwrite,
-		remoteDataset:  remoteDataset, // Pass source dataset name for proper mapping
+		if req.TotalBytes == 0 && req.Progress != nil && req.Since == "" && !req.Single {
+			size, err := t.RemoteSendSize(req.RemoteDataset, req.Snapshot)
+			if err != nil {
+				logger.Warn("Failed to estimate restore size, progress will be reported in bytes only", "snapshot", req.RemoteDataset+"@"+req.Snapshot, "err", err)
+			}
+			req.TotalBytes = size
+		}
 	}
 
-	return session.Run(sendCmd)
+	return t.restoreStream(ctx, sendCmd, req)
 }
 
-type mbufferReceiver struct {
-	dataset        string
-	size           string
-	forceOverwrite bool              // Use -F flag for destructive operations
-	remoteDataset  string            // Source dataset name for proper mapping
-	progressChan   chan ProgressInfo // Channel for real-time progress updates
+// RemoteSendSize estimates the size of a full recursive send of a remote
+// snapshot with a dry run
+func (t *SSHTransport) RemoteSendSize(remoteDataset, snapshotName string) (int64, error) {
+	output, err := t.ExecuteCommand(fmt.Sprintf("zfs send -nP -R %s@%s",
+		validation.SanitizeCommand(remoteDataset), validation.SanitizeCommand(snapshotName)))
+	if err != nil {
+		return 0, err
+	}
+	return zfs.ParseSendSize(output)
 }
 
-// ProgressInfo contains real-time transfer progress data
-type ProgressInfo struct {
-	BytesTransferred int64
-	TotalBytes       int64
-	TransferRate     float64 // MB/sec
-	ETA              string
-	Percentage       float64
+// DatasetSize is a dataset in a snapshot's tree and the size of sending
+// its snapshot on its own
+type DatasetSize struct {
+	Dataset string
+	Bytes   int64
 }
 
-func (m *mbufferReceiver) Write(p []byte) (n int, err error) {
-	// Sanitize inputs to prevent command injection
-	sanitizedSize := validation.SanitizeCommand(m.size)
-	sanitizedDataset := validation.SanitizeCommand(m.dataset)
+// RemoteTree lists remoteDataset and those of its children that have
+// snapshotName, parents before children, each with the size of a
+// RestoreRequest with Single set. The sizes are taken in one batch.
+func (t *SSHTransport) RemoteTree(remoteDataset, snapshotName string, raw bool) ([]DatasetSize, error) {
+	output, err := t.ExecuteCommand(fmt.Sprintf("zfs list -H -o name -t snapshot -r %s", validation.SanitizeCommand(remoteDataset)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots under %s: %w", remoteDataset, err)
+	}
+	datasets := snapshotTree(output, snapshotName)
+	if len(datasets) == 0 {
+		return nil, fmt.Errorf("snapshot %s not found under %s", snapshotName, remoteDataset)
+	}
 
-	// Build command safely - choose safe vs. destructive mode with proper dataset mapping
-	var safeCommand string
+	flags := "-p"
+	if raw {
+		flags += " -w"
+	}
+	commands := make([]string, len(datasets))
+	for i, dataset := range datasets {
+		commands[i] = fmt.Sprintf("zfs send -nP %s %s@%s", flags,
+			validation.SanitizeCommand(dataset), validation.SanitizeCommand(snapshotName))
+	}
+	results, err := t.RunBatch(commands)
+	if err != nil {
+		return nil, err
+	}
 
-	// Use -d flag to strip first element of path (avoids nesting issues)
-	// Example: remote "data1/helix-backup" -> local "data" (strips "data1")
-	receiveFlags := "-d"
-	if m.forceOverwrite {
-		receiveFlags += " -F" // Add force flag for destructive operations
+	tree := make([]DatasetSize, len(datasets))
+	for i, result := range results {
+		if result.ExitCode != 0 {
+			return nil, fmt.Errorf("failed to size %s@%s (exit %d)", datasets[i], snapshotName, result.ExitCode)
+		}
+		size, err := zfs.ParseSendSize(result.Output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to size %s@%s: %w", datasets[i], snapshotName, err)
+		}
+		tree[i] = DatasetSize{Dataset: datasets[i], Bytes: size}
 	}
+	return tree, nil
+}
 
-	// Use pv for progress monitoring with mbuffer for buffering
-	// pv provides real-time transfer rate, ETA, and progress percentage
-	safeCommand = fmt.Sprintf("pv -f -r -a -b | mbuffer -s 128k -m %s | zfs receive %s %s",
-		sanitizedSize, receiveFlags, sanitizedDataset)
+// snapshotTree picks the datasets holding snapshot out of zfs list output.
+// Sorting by name puts every parent before its children.
+func snapshotTree(output, snapshot string) []string {
+	var datasets []string
+	for _, line := range strings.Split(output, "\n") {
+		dataset, name, ok := strings.Cut(strings.TrimSpace(line), "@")
+		if ok && name == snapshot {
+			datasets = append(datasets, dataset)
+		}
+	}
+	sort.Strings(datasets)
+	return datasets
+}
 
-	cmd := exec.Command("sh", "-c", safeCommand)
+// restoreStream runs sendCmd on the backup server and receives its output locally
+func (t *SSHTransport) restoreStream(ctx context.Context, sendCmd string, req RestoreRequest) (err error) {
+	defer observeCommand(opRestore, time.Now(), &err)
 
-	stdin, err := cmd.StdinPipe()
+	client, err := t.connection()
 	if err != nil {
-		return 0, err
+		return err
 	}
-
-	if err := cmd.Start(); err != nil {
-		return 0, err
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create SSH session: %w", err)
 	}
+	defer session.Close()
 
-	n, err = stdin.Write(p)
+	receiver, err := startReceiver(req.LocalDataset, t.Config().MbufferSize, req.Force, req.Mountpoint, newProgressTracker(req.TotalBytes, req.Progress))
 	if err != nil {
-		stdin.Close()
-		cmd.Wait()
-		return n, err
-	}
+		return fmt.Errorf("failed to start zfs receive: %w", err)
+	}
+	session.Stdout = &countingWriter{w: receiver, counter: bytesReceived, operation: opRestore}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Signal(ssh.SIGTERM)
+			session.Close()
+			receiver.Kill()
+		case <-finished:
+		}
+	}()
 
-	stdin.Close()
-	return n, cmd.Wait()
+	runErr := session.Run(sendCmd)
+	receiveErr := receiver.Close()
+	if ctx.Err() != nil {
+		return fmt.Errorf("restore cancelled: %w", ctx.Err())
+	}
+	// A failed receive also breaks the stream, so its error explains more
+	if receiveErr != nil {
+		return receiveErr
+	}
+	if runErr != nil {
+		return fmt.Errorf("remote send failed: %w", runErr)
+	}
+	return nil
 }
 
 func loadPrivateKey(keyPath string) (ssh.Signer, error) {
@@ -1,9 +1,11 @@
 package transport
 
 import (
+	"strings"
 	"testing"
 
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
 )
 
 func TestNewSSHTransport(t *testing.T) {
@@ -86,3 +88,42 @@ func TestSSHTransportSendSnapshot_SkipIntegration(t *testing.T) {
 func TestSSHTransportRestoreSnapshot_SkipIntegration(t *testing.T) {
 	t.Skip("Skipping SSH integration test - requires live SSH connection and ZFS")
 }
+
+func TestDryRunSkipsRemoteChanges(t *testing.T) {
+	utils.DefaultRunner.SetDryRun(true)
+	defer utils.DefaultRunner.SetDryRun(false)
+
+	// The host doesn't exist, so any attempt to connect would fail
+	transport := NewSSHTransport(&config.SSHConfig{
+		RemoteHost:    "nonexistent.test.invalid",
+		RemoteUser:    "root",
+		RemoteDataset: "backup/test",
+	})
+
+	if err := transport.DestroyRemoteSnapshots([]string{"autosnap_2024-01-01_00-00-00"}); err != nil {
+		t.Errorf("Expected destroy to be skipped, got %v", err)
+	}
+	if err := transport.ReplaceRemoteDataset("backup/test-staging"); err != nil {
+		t.Errorf("Expected replace to be skipped, got %v", err)
+	}
+	if err := transport.UploadFile("/var/backups/zfsrabbit/state.tar.gz", strings.NewReader("state")); err != nil {
+		t.Errorf("Expected upload to be skipped, got %v", err)
+	}
+
+	stream := strings.NewReader("zfs send stream")
+	if err := transport.SendSnapshot(stream, false); err != nil {
+		t.Errorf("Expected receive to be skipped, got %v", err)
+	}
+	if stream.Len() != 0 {
+		t.Error("Expected the send stream to be drained so zfs send can exit")
+	}
+}
+
+func TestSnapshotTree(t *testing.T) {
+	output := "backup/vms/b@daily\nbackup/vms@hourly\nbackup/vms/a/disk0@daily\nbackup/vms@daily\nbackup/vms/a@daily\nbackup/vms/c@hourly\n"
+	got := strings.Join(snapshotTree(output, "daily"), ",")
+	want := "backup/vms,backup/vms/a,backup/vms/a/disk0,backup/vms/b"
+	if got != want {
+		t.Errorf("snapshotTree = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"fmt"
+	"strings"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/validation"
+)
+
+// RemoteProperties returns the values of the named properties on the
+// configured remote dataset; names are checked by the config
+func (t *SSHTransport) RemoteProperties(names []string) (map[string]string, error) {
+	if len(names) == 0 {
+		return map[string]string{}, nil
+	}
+	output, err := t.ExecuteCommand(fmt.Sprintf("zfs get -H -o property,value %s \"%s\"",
+		validation.SanitizeCommand(strings.Join(names, ",")), validation.SanitizeCommand(t.RemoteDataset())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties of %s: %w", t.RemoteDataset(), err)
+	}
+	return parsePropertyValues(output), nil
+}
+
+// parsePropertyValues reads zfs get -H -o property,value output
+func parsePropertyValues(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if name, value, ok := strings.Cut(line, "\t"); ok {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// KeepStandby keeps the remote dataset and its children from being changed
+// on the standby: readonly sets readonly=on, unmounted sets canmount=noauto
+// and unmounts them, children first
+func (t *SSHTransport) KeepStandby(mode string) error {
+	remote := validation.SanitizeCommand(t.RemoteDataset())
+
+	var cmd string
+	switch mode {
+	case config.StandbyReadOnly:
+		cmd = fmt.Sprintf("zfs set readonly=on \"%s\"", remote)
+	case config.StandbyUnmounted:
+		cmd = fmt.Sprintf("zfs list -H -o name -r \"%s\" | sort -r | while read -r ds; do zfs set canmount=noauto \"$ds\" || exit 1; "+
+			"if [ \"$(zfs get -H -o value mounted \"$ds\")\" = yes ]; then zfs unmount \"$ds\" || exit 1; fi; done", remote)
+	default:
+		return fmt.Errorf("unknown standby mode %q", mode)
+	}
+
+	if t.dryRun("keep %s %s", remote, mode) {
+		return nil
+	}
+	if _, err := t.ExecuteCommand(cmd); err != nil {
+		return fmt.Errorf("failed to keep %s %s: %w", t.RemoteDataset(), mode, err)
+	}
+	return nil
+}
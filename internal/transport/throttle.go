@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"io"
+	"time"
+)
+
+// minThrottleBurst keeps the bucket large enough that mbuffer-sized reads
+// aren't split into tiny pieces at low rates
+const minThrottleBurst = 64 * 1024
+
+// throttledReader limits how fast a stream can be read with a token bucket
+// refilled at rate bytes per second
+type throttledReader struct {
+	r      io.Reader
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+func newThrottledReader(r io.Reader, bytesPerSecond int64) *throttledReader {
+	burst := float64(bytesPerSecond) / 4
+	if burst < minThrottleBurst {
+		burst = minThrottleBurst
+	}
+	return &throttledReader{
+		r:      r,
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > int(t.burst) {
+		p = p[:int(t.burst)]
+	}
+
+	t.refill()
+	if need := float64(len(p)) - t.tokens; need > 0 {
+		t.sleep(time.Duration(need / t.rate * float64(time.Second)))
+		t.refill()
+	}
+
+	n, err := t.r.Read(p)
+	t.tokens -= float64(n)
+	return n, err
+}
+
+func (t *throttledReader) refill() {
+	now := t.now()
+	t.tokens += now.Sub(t.last).Seconds() * t.rate
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+}
@@ -0,0 +1,33 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestThrottledReaderPacesToRate(t *testing.T) {
+	const rate = 1 << 20 // 1 MiB/s
+
+	clock := time.Unix(0, 0)
+	var slept time.Duration
+
+	reader := newThrottledReader(bytes.NewReader(make([]byte, 10<<20)), rate)
+	reader.last = clock
+	reader.now = func() time.Time { return clock }
+	reader.sleep = func(d time.Duration) {
+		slept += d
+		clock = clock.Add(d)
+	}
+
+	n, err := io.Copy(io.Discard, reader)
+	if err != nil || n != 10<<20 {
+		t.Fatalf("Expected to read 10 MiB, got %d (%v)", n, err)
+	}
+
+	// The initial burst (a quarter second's worth) is free
+	if slept < 9*time.Second || slept > 10*time.Second {
+		t.Errorf("Expected about 9.75s of throttling for 10 MiB at 1 MiB/s, got %s", slept)
+	}
+}
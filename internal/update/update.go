@@ -0,0 +1,194 @@
+// Package update periodically asks a release endpoint whether a newer
+// zfsrabbit is available. It is off by default.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+// Status is the result of the most recent check
+type Status struct {
+	Current         string    `json:"current"`
+	Latest          string    `json:"latest,omitempty"`
+	UpdateAvailable bool      `json:"update_available"`
+	ReleaseURL      string    `json:"release_url,omitempty"`
+	Checked         time.Time `json:"checked,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// release accepts the GitHub "latest release" document as well as a plain
+// {"version": ..., "url": ...} one for self-hosted endpoints
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+type Checker struct {
+	config  *config.UpdateConfig
+	current string
+	client  *http.Client
+	mu      sync.RWMutex
+	status  Status
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewChecker(cfg *config.UpdateConfig, current string) *Checker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Checker{
+		config:  cfg,
+		current: current,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		status:  Status{Current: current},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start checks immediately and then every interval until Stop is called
+func (c *Checker) Start() {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Check(c.ctx); err != nil {
+			log.Printf("Update check failed: %v", err)
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) Stop() {
+	c.cancel()
+}
+
+// Status returns the result of the last check
+func (c *Checker) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Check fetches the latest release and records whether it is newer
+func (c *Checker) Check(ctx context.Context) error {
+	latest, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.Checked = time.Now()
+	if err != nil {
+		c.status.Error = err.Error()
+		return err
+	}
+
+	wasAvailable := c.status.UpdateAvailable && c.status.Latest == latest.Version
+	c.status.Error = ""
+	c.status.Latest = latest.Version
+	c.status.ReleaseURL = latest.URL
+	c.status.UpdateAvailable = Newer(latest.Version, c.current)
+
+	if c.status.UpdateAvailable && !wasAvailable {
+		log.Printf("zfsrabbit %s is available (running %s): %s", latest.Version, c.current, latest.URL)
+	}
+	return nil
+}
+
+func (c *Checker) fetch(ctx context.Context) (release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.URL, nil)
+	if err != nil {
+		return release{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "zfsrabbit/"+c.current)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return release{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return release{}, fmt.Errorf("%s returned %s", c.config.URL, resp.Status)
+	}
+
+	var r release
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return release{}, fmt.Errorf("invalid release document: %w", err)
+	}
+	if r.Version == "" {
+		r.Version = r.TagName
+	}
+	if r.URL == "" {
+		r.URL = r.HTMLURL
+	}
+	if r.Version == "" {
+		return release{}, fmt.Errorf("release document has no version")
+	}
+	return r, nil
+}
+
+// Newer reports whether latest is a later version than current. Versions are
+// compared as dotted numbers with an optional "v" prefix; a pre-release
+// (1.2.0-rc1) sorts before its release. Development builds never report an update.
+func Newer(latest, current string) bool {
+	l, lPre, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, cPre, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var lv, cv int
+		if i < len(l) {
+			lv = l[i]
+		}
+		if i < len(c) {
+			cv = c[i]
+		}
+		if lv != cv {
+			return lv > cv
+		}
+	}
+
+	// Same numbers: a release beats its pre-release
+	return lPre == "" && cPre != ""
+}
+
+func parseVersion(v string) ([]int, string, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, pre, _ := strings.Cut(v, "-")
+	if v == "" {
+		return nil, "", false
+	}
+
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, "", false
+		}
+		parts = append(parts, n)
+	}
+	return parts, pre, true
+}
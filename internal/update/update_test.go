@@ -0,0 +1,100 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"1.10.0", "1.9.0", true},
+		{"v1.2.0", "1.2.0", false},
+		{"v1.2", "v1.2.0", false},
+		{"v1.2.1", "v1.2", true},
+		{"v1.2.0", "v1.2.0-rc1", true},
+		{"v1.2.0-rc2", "v1.2.0", false},
+		{"v1.1.0", "v1.2.0", false},
+		{"v1.2.0", "dev", false},
+		{"garbage", "v1.0.0", false},
+	}
+
+	for _, tt := range tests {
+		if got := Newer(tt.latest, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		latest  string
+		url     string
+		current string
+		want    bool
+	}{
+		{
+			name:    "github release",
+			body:    `{"tag_name": "v1.3.0", "html_url": "https://github.com/helixml/zfsrabbit/releases/tag/v1.3.0"}`,
+			latest:  "v1.3.0",
+			url:     "https://github.com/helixml/zfsrabbit/releases/tag/v1.3.0",
+			current: "v1.2.0",
+			want:    true,
+		},
+		{
+			name:    "plain document",
+			body:    `{"version": "1.2.0", "url": "https://mirror.example.com/zfsrabbit/1.2.0"}`,
+			latest:  "1.2.0",
+			url:     "https://mirror.example.com/zfsrabbit/1.2.0",
+			current: "v1.2.0",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			checker := NewChecker(&config.UpdateConfig{Enabled: true, URL: server.URL, Interval: time.Hour}, tt.current)
+			if err := checker.Check(context.Background()); err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+
+			status := checker.Status()
+			if status.Latest != tt.latest || status.ReleaseURL != tt.url || status.UpdateAvailable != tt.want {
+				t.Errorf("unexpected status: %+v", status)
+			}
+			if status.Checked.IsZero() {
+				t.Error("expected Checked to be set")
+			}
+		})
+	}
+}
+
+func TestCheckRecordsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	checker := NewChecker(&config.UpdateConfig{Enabled: true, URL: server.URL, Interval: time.Hour}, "v1.0.0")
+	if err := checker.Check(context.Background()); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+	if status := checker.Status(); status.Error == "" || status.UpdateAvailable {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
@@ -1,45 +1,215 @@
 package utils
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"log"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
-// TimeoutExecutor wraps exec.Cmd with timeout functionality
-type TimeoutExecutor struct {
-	defaultTimeout time.Duration
+// CommandRunner builds and runs external commands. Every package that shells
+// out goes through one, so tests can substitute a fake and dry-run mode
+// covers every command the daemon runs.
+type CommandRunner interface {
+	Command(name string, args ...string) *exec.Cmd
+	CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd
+	Output(cmd *exec.Cmd) ([]byte, error)
+	Run(cmd *exec.Cmd) error
 }
 
-// NewTimeoutExecutor creates a new timeout executor with a default timeout
-func NewTimeoutExecutor(timeout time.Duration) *TimeoutExecutor {
-	return &TimeoutExecutor{
-		defaultTimeout: timeout,
-	}
+// CommandError is a failed command together with what it wrote to stderr
+type CommandError struct {
+	Command string
+	Stderr  string
+	Err     error
 }
 
-// CommandWithTimeout creates a command with a timeout context
-func (e *TimeoutExecutor) CommandWithTimeout(ctx context.Context, name string, args ...string) *exec.Cmd {
-	if ctx == nil {
-		ctx, _ = context.WithTimeout(context.Background(), e.defaultTimeout)
+// Error leaves out the command line, which callers already describe
+func (e *CommandError) Error() string {
+	if e.Stderr == "" {
+		return e.Err.Error()
 	}
-	return exec.CommandContext(ctx, name, args...)
+	return fmt.Sprintf("%v: %s", e.Err, e.Stderr)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Runner is the CommandRunner that executes commands on this host. Output
+// and Run capture stderr into the returned error unless the caller has
+// already redirected it, enforce the runner's timeout, and in dry-run mode
+// log mutating commands instead of running them.
+type Runner struct {
+	mu      sync.RWMutex
+	timeout time.Duration
+	dryRun  bool
+}
+
+// DefaultRunner is shared by every package so the daemon-wide dry-run flag
+// applies to all of them
+var DefaultRunner = NewRunner(0)
+
+// NewRunner creates a runner that kills Output and Run commands after
+// timeout; zero means no limit beyond the command's own context
+func NewRunner(timeout time.Duration) *Runner {
+	return &Runner{timeout: timeout}
+}
+
+// SetDryRun turns dry-run mode on or off
+func (r *Runner) SetDryRun(dryRun bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dryRun = dryRun
+}
+
+// DryRun reports whether mutating commands are skipped
+func (r *Runner) DryRun() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dryRun
+}
+
+// SetTimeout changes how long Output and Run wait before killing a command
+func (r *Runner) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
 }
 
-// Command creates a command with the default timeout
-func (e *TimeoutExecutor) Command(name string, args ...string) *exec.Cmd {
-	ctx, _ := context.WithTimeout(context.Background(), e.defaultTimeout)
+func (r *Runner) Command(name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func (r *Runner) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
 	return exec.CommandContext(ctx, name, args...)
 }
 
-// RunWithTimeout runs a command with timeout and returns error
-func (e *TimeoutExecutor) RunWithTimeout(ctx context.Context, name string, args ...string) error {
-	cmd := e.CommandWithTimeout(ctx, name, args...)
-	return cmd.Run()
+// Output runs cmd and returns its stdout
+func (r *Runner) Output(cmd *exec.Cmd) ([]byte, error) {
+	if r.skip(cmd) {
+		return nil, nil
+	}
+
+	var stdout bytes.Buffer
+	if cmd.Stdout == nil {
+		cmd.Stdout = &stdout
+	}
+	err := r.wait(cmd)
+	return stdout.Bytes(), err
+}
+
+// Run runs cmd to completion
+func (r *Runner) Run(cmd *exec.Cmd) error {
+	if r.skip(cmd) {
+		return nil
+	}
+	return r.wait(cmd)
+}
+
+func (r *Runner) wait(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	captured := cmd.Stderr == nil
+	if captured {
+		cmd.Stderr = &stderr
+	}
+
+	if err := cmd.Start(); err != nil {
+		return &CommandError{Command: describe(cmd), Err: err}
+	}
+
+	r.mu.RLock()
+	timeout := r.timeout
+	r.mu.RUnlock()
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			cmd.Process.Kill()
+		})
+		defer timer.Stop()
+	}
+
+	err := cmd.Wait()
+	if err == nil {
+		return nil
+	}
+	if !captured {
+		return err
+	}
+	return &CommandError{Command: describe(cmd), Stderr: strings.TrimSpace(stderr.String()), Err: err}
+}
+
+// CombinedOutput runs cmd through runner and returns its stdout and stderr
+// interleaved, for output shown to people rather than parsed
+func CombinedOutput(runner CommandRunner, cmd *exec.Cmd) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := runner.Run(cmd)
+	return output.Bytes(), err
+}
+
+// skip logs and reports whether cmd must not run because of dry-run mode
+func (r *Runner) skip(cmd *exec.Cmd) bool {
+	if !r.DryRun() || !IsMutating(cmd.Args) {
+		return false
+	}
+	log.Printf("Dry run: would run %s", describe(cmd))
+	return true
+}
+
+// mutatingSubcommands are the zfs and zpool subcommands that change state
+var mutatingSubcommands = map[string]map[string]bool{
+	"zfs": {
+		"create": true, "destroy": true, "snapshot": true, "rollback": true,
+		"clone": true, "promote": true, "rename": true, "set": true,
+		"inherit": true, "receive": true, "recv": true, "bookmark": true,
+		"hold": true, "release": true, "mount": true, "unmount": true,
+		"umount": true, "share": true, "unshare": true, "load-key": true,
+		"unload-key": true, "change-key": true,
+	},
+	"zpool": {
+		"create": true, "destroy": true, "add": true, "remove": true,
+		"attach": true, "detach": true, "replace": true, "scrub": true,
+		"trim": true, "online": true, "offline": true, "clear": true,
+		"export": true, "import": true, "split": true, "upgrade": true,
+		"labelclear": true, "resilver": true, "initialize": true, "set": true,
+		"reguid": true,
+	},
+}
+
+// IsMutating reports whether a command line changes ZFS state. Shell
+// pipelines can't be inspected, so they count as mutating.
+func IsMutating(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	name := filepath.Base(args[0])
+	if name == "sh" || name == "bash" {
+		return true
+	}
+	subcommands, ok := mutatingSubcommands[name]
+	if !ok || len(args) < 2 {
+		return false
+	}
+	if !subcommands[args[1]] {
+		return false
+	}
+	// zpool create/add -n only prints the layout it would use
+	if name == "zpool" && (args[1] == "create" || args[1] == "add") {
+		for _, arg := range args[2:] {
+			if arg == "-n" {
+				return false
+			}
+		}
+	}
+	return true
 }
 
-// OutputWithTimeout runs a command and returns output with timeout
-func (e *TimeoutExecutor) OutputWithTimeout(ctx context.Context, name string, args ...string) ([]byte, error) {
-	cmd := e.CommandWithTimeout(ctx, name, args...)
-	return cmd.Output()
+func describe(cmd *exec.Cmd) string {
+	return strings.Join(cmd.Args, " ")
 }
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunnerCapturesStderr(t *testing.T) {
+	runner := NewRunner(0)
+
+	err := runner.Run(runner.Command("sh", "-c", "echo 'dataset does not exist' >&2; exit 1"))
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("Expected CommandError, got %v", err)
+	}
+	if cmdErr.Stderr != "dataset does not exist" {
+		t.Errorf("Expected stderr to be captured, got %q", cmdErr.Stderr)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Errorf("Expected the exit error to be unwrappable, got %v", err)
+	}
+}
+
+func TestRunnerOutput(t *testing.T) {
+	runner := NewRunner(0)
+
+	output, err := runner.Output(runner.Command("echo", "tank"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(output)) != "tank" {
+		t.Errorf("Expected stdout, got %q", output)
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	runner := NewRunner(100 * time.Millisecond)
+
+	start := time.Now()
+	if err := runner.Run(runner.Command("sleep", "10")); err == nil {
+		t.Fatal("Expected timed out command to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected command to be killed after the timeout, took %s", elapsed)
+	}
+}
+
+func TestRunnerDryRun(t *testing.T) {
+	runner := NewRunner(0)
+	runner.SetDryRun(true)
+
+	// A mutating command is logged, not run
+	if err := runner.Run(runner.Command("zfs", "destroy", "tank/does-not-exist")); err != nil {
+		t.Errorf("Expected dry-run destroy to be skipped, got %v", err)
+	}
+
+	// Read-only commands still run
+	output, err := runner.Output(runner.Command("echo", "ok"))
+	if err != nil || strings.TrimSpace(string(output)) != "ok" {
+		t.Errorf("Expected read-only command to run in dry-run mode, got %q, %v", output, err)
+	}
+}
+
+func TestIsMutating(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"zfs", "list", "-H"}, false},
+		{[]string{"zfs", "snapshot", "tank@now"}, true},
+		{[]string{"/sbin/zfs", "destroy", "tank@old"}, true},
+		{[]string{"zfs", "send", "tank@now"}, false},
+		{[]string{"zpool", "status", "tank"}, false},
+		{[]string{"zpool", "scrub", "tank"}, true},
+		{[]string{"zpool", "create", "-n", "tank", "mirror", "sda", "sdb"}, false},
+		{[]string{"zpool", "create", "tank", "mirror", "sda", "sdb"}, true},
+		{[]string{"sh", "-c", "zfs receive tank"}, true},
+		{[]string{"smartctl", "-H", "/dev/sda"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsMutating(tt.args); got != tt.want {
+			t.Errorf("IsMutating(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
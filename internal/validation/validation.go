@@ -12,6 +12,10 @@ var datasetNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-
 // ZFS snapshot name validation regex
 var snapshotNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
 
+// sharenfs/sharesmb values: on, off or a comma separated option list such as
+// rw=@10.0.0.0/24,no_root_squash
+var shareOptionRegex = regexp.MustCompile(`^[a-zA-Z0-9@=,.:/_+-]+$`)
+
 // ValidateDatasetName validates ZFS dataset names to prevent injection attacks
 func ValidateDatasetName(name string) error {
 	if name == "" {
@@ -34,6 +38,34 @@ func ValidateDatasetName(name string) error {
 	return nil
 }
 
+// ZFS pool names start with a letter; zpool reserves the vdev type keywords
+var poolNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.:-]*$`)
+
+var reservedPoolNames = []string{"mirror", "raidz", "draid", "spare", "log", "cache", "special", "dedup"}
+
+// ValidatePoolName validates a name for a new ZFS pool
+func ValidatePoolName(name string) error {
+	if name == "" {
+		return fmt.Errorf("pool name cannot be empty")
+	}
+
+	if len(name) > 255 {
+		return fmt.Errorf("pool name too long (max 255 characters)")
+	}
+
+	if !poolNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid pool name format")
+	}
+
+	for _, reserved := range reservedPoolNames {
+		if strings.HasPrefix(name, reserved) {
+			return fmt.Errorf("pool name cannot begin with %q", reserved)
+		}
+	}
+
+	return nil
+}
+
 // ValidateSnapshotName validates ZFS snapshot names
 func ValidateSnapshotName(name string) error {
 	if name == "" {
@@ -56,6 +88,56 @@ func ValidateSnapshotName(name string) error {
 	return nil
 }
 
+// ValidateMountpoint validates a mountpoint path to set on a dataset
+func ValidateMountpoint(path string) error {
+	return validateAbsolutePath("mountpoint", path)
+}
+
+// ValidateShareOption validates a sharenfs or sharesmb property value
+func ValidateShareOption(value string) error {
+	if value == "" {
+		return fmt.Errorf("share option cannot be empty")
+	}
+
+	if len(value) > 1024 {
+		return fmt.Errorf("share option too long (max 1024 characters)")
+	}
+
+	if !shareOptionRegex.MatchString(value) {
+		return fmt.Errorf("share option contains invalid characters")
+	}
+
+	return nil
+}
+
+// ValidateArchivePath validates a file or directory path on the backup server
+// that snapshot streams are archived to
+func ValidateArchivePath(path string) error {
+	return validateAbsolutePath("archive path", path)
+}
+
+func validateAbsolutePath(kind, path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("%s must be an absolute path", kind)
+	}
+
+	if len(path) > 1024 {
+		return fmt.Errorf("%s too long (max 1024 characters)", kind)
+	}
+
+	if strings.ContainsAny(path, ";|&$`\"'\\*?[]{}()<> \t\n") {
+		return fmt.Errorf("%s contains invalid characters", kind)
+	}
+
+	for _, element := range strings.Split(path, "/") {
+		if element == ".." {
+			return fmt.Errorf("%s cannot contain ..", kind)
+		}
+	}
+
+	return nil
+}
+
 // SanitizeCommand sanitizes shell command arguments by escaping dangerous characters
 func SanitizeCommand(arg string) string {
 	// Remove or escape potentially dangerous characters
@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -37,6 +38,91 @@ func TestValidateDatasetName(t *testing.T) {
 	}
 }
 
+func TestValidateMountpoint(t *testing.T) {
+	tests := []struct {
+		path  string
+		valid bool
+	}{
+		{"/srv/restore/tank-data", true},
+		{"/mnt/restore_2026-01-01", true},
+		{"relative/path", false},
+		{"", false},
+		{"/srv/../etc", false},
+		{"/srv/restore;rm -rf /", false},
+		{"/srv/with space", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateMountpoint(tt.path)
+		if tt.valid && err != nil {
+			t.Errorf("Expected %q to be valid, got error: %v", tt.path, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Expected %q to be invalid, but validation passed", tt.path)
+		}
+	}
+}
+
+func TestValidateShareOption(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"on", true},
+		{"off", true},
+		{"rw=@10.0.0.0/24,no_root_squash", true},
+		{"ro,sec=sys", true},
+		{"", false},
+		{"on;rm -rf /", false},
+		{"rw=host one", false},
+		{"$(reboot)", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateShareOption(tt.value)
+		if tt.valid && err != nil {
+			t.Errorf("Expected %q to be valid, got error: %v", tt.value, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Expected %q to be invalid, but validation passed", tt.value)
+		}
+	}
+}
+
+func TestValidateArchivePath(t *testing.T) {
+	if err := ValidateArchivePath("/cold/zfsrabbit"); err != nil {
+		t.Errorf("Expected valid archive path, got %v", err)
+	}
+	if err := ValidateArchivePath("cold"); err == nil || !strings.Contains(err.Error(), "archive path") {
+		t.Errorf("Expected archive path error, got %v", err)
+	}
+}
+
+func TestValidatePoolName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"tank", true},
+		{"backup-2026.a:b", true},
+		{"", false},
+		{"2tank", false},
+		{"mirror1", false},
+		{"tank/data", false},
+		{"tank;rm", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePoolName(tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("Expected %q to be valid, got error: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Expected %q to be invalid, but validation passed", tt.name)
+		}
+	}
+}
+
 func TestValidateSnapshotName(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,81 @@
+// Package version reports which zfsrabbit build is running.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X zfsrabbit/internal/version.Version=..."
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a dirty tree
+}
+
+// Get returns the build details, falling back to the VCS stamp Go embeds
+// when the linker flags weren't set
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
+
+// String formats the version for logs and --version output
+func (i Info) String() string {
+	return "zfsrabbit " + i.Short() + " " + i.GoVersion
+}
+
+// Short is the version and abbreviated commit, for alert footers and the UI
+func (i Info) Short() string {
+	s := i.Version
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " (" + commit
+		if i.Modified {
+			s += "-dirty"
+		}
+		s += ")"
+	}
+	return s
+}
+
+// UserAgent identifies zfsrabbit to the webhooks and APIs it calls, so
+// receivers can tell which deployment sent a request
+func UserAgent() string {
+	return "zfsrabbit/" + Version
+}
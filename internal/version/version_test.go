@@ -0,0 +1,17 @@
+package version
+
+import "testing"
+
+func TestInfoFormatting(t *testing.T) {
+	info := Info{Version: "v1.2.0", Commit: "0123456789abcdef", GoVersion: "go1.24.6", Modified: true}
+
+	if got := info.Short(); got != "v1.2.0 (0123456789ab-dirty)" {
+		t.Errorf("Short() = %q", got)
+	}
+	if got := info.String(); got != "zfsrabbit v1.2.0 (0123456789ab-dirty) go1.24.6" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Info{Version: "dev", GoVersion: "go1.24.6"}).String(); got != "zfsrabbit dev go1.24.6" {
+		t.Errorf("String() without a commit = %q", got)
+	}
+}
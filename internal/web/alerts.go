@@ -0,0 +1,64 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"zfsrabbit/internal/alert"
+	"zfsrabbit/internal/monitor"
+)
+
+// defaultAlertLimit is how many alerts /api/alerts returns without a limit
+const defaultAlertLimit = 100
+
+// handleAlerts lists sent alerts, newest first. The severity (minimum), type,
+// channel, q (subject text), since (RFC 3339 or a duration such as 24h) and
+// limit query parameters filter them.
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.alertHistory == nil {
+		http.Error(w, "Alert history is not available", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := alert.HistoryFilter{
+		MinSeverity: query.Get("severity"),
+		Type:        query.Get("type"),
+		Channel:     query.Get("channel"),
+		Query:       query.Get("q"),
+		Limit:       defaultAlertLimit,
+	}
+	if filter.MinSeverity != "" {
+		if _, err := monitor.ParseSeverity(filter.MinSeverity); err != nil {
+			http.Error(w, "severity must be info, warning, critical or emergency", http.StatusBadRequest)
+			return
+		}
+	}
+	if since := query.Get("since"); since != "" {
+		if d, err := time.ParseDuration(since); err == nil {
+			filter.Since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.Since = t
+		} else {
+			http.Error(w, "since must be an RFC 3339 time or a duration such as 24h", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a positive number, or 0 for every alert", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.alertHistory.List(filter))
+}
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"zfsrabbit/internal/scheduler"
+)
+
+// Calendar event kinds beyond the scheduler's snapshot and scrub runs
+const (
+	eventVerification = "verification" // DR drills
+	eventAudit        = "audit"        // SLA deadline checks
+)
+
+// maxCalendarRange bounds one history or schedule request
+const maxCalendarRange = 93 * 24 * time.Hour
+
+// calendarEvent is one entry on the calendar: a past run or an upcoming one
+type calendarEvent struct {
+	Kind   string     `json:"kind"` // snapshot, scrub, verification or audit
+	Title  string     `json:"title"`
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Date   string     `json:"date,omitempty"`   // YYYY-MM-DD for whole-day results
+	Status string     `json:"status"`           // success, failure, running or scheduled
+	Detail string     `json:"detail,omitempty"` // Snapshot name or error
+}
+
+func (s *Server) handleCalendarPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	http.ServeFile(w, r, "web/templates/calendar.html")
+}
+
+// handleHistory lists past snapshot, scrub, DR drill and SLA results between
+// the from and to query parameters, by default the last 30 days
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from, to, err := calendarRange(r, now.AddDate(0, 0, -30), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.history(from, to))
+}
+
+// handleSchedule lists scheduled snapshots, scrubs and SLA deadline checks
+// between the from and to query parameters, by default the next 14 days
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from, to, err := calendarRange(r, now, now.AddDate(0, 0, 14))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Nothing is scheduled in the past
+	if from.Before(now) {
+		from = now
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.schedule(from, to))
+}
+
+// calendarRange reads the from and to query parameters, each a date
+// (YYYY-MM-DD, local time) or an RFC 3339 timestamp
+func calendarRange(r *http.Request, from, to time.Time) (time.Time, time.Time, error) {
+	parse := func(name string, value *time.Time) error {
+		param := r.URL.Query().Get(name)
+		if param == "" {
+			return nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", param, time.Local); err == nil {
+			*value = t
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			return fmt.Errorf("%s must be a date (YYYY-MM-DD) or RFC 3339 time", name)
+		}
+		*value = t
+		return nil
+	}
+	if err := parse("from", &from); err != nil {
+		return from, to, err
+	}
+	if err := parse("to", &to); err != nil {
+		return from, to, err
+	}
+	if !to.After(from) {
+		return from, to, fmt.Errorf("to must be after from")
+	}
+	if to.Sub(from) > maxCalendarRange {
+		return from, to, fmt.Errorf("range cannot exceed 93 days")
+	}
+	return from, to, nil
+}
+
+func (s *Server) history(from, to time.Time) []calendarEvent {
+	events := []calendarEvent{}
+
+	for _, run := range s.scheduler.History(from, to) {
+		finished := run.Finished
+		event := calendarEvent{
+			Kind:   run.Kind,
+			Start:  run.Started,
+			End:    &finished,
+			Status: "success",
+			Detail: run.Detail,
+		}
+		if run.Kind == scheduler.RunScrub {
+			event.Title = "Scrub " + run.Target
+		} else {
+			event.Title = fmt.Sprintf("Snapshot %s (%s)", run.Target, run.Job)
+		}
+		if !run.Success {
+			event.Status = "failure"
+			event.Detail = run.Error
+		}
+		events = append(events, event)
+	}
+
+	for _, report := range s.drillManager.ListReports() {
+		if report.StartTime.Before(from) || !report.StartTime.Before(to) {
+			continue
+		}
+		event := calendarEvent{
+			Kind:   eventVerification,
+			Title:  "DR drill " + report.Namespace,
+			Start:  report.StartTime,
+			End:    report.EndTime,
+			Detail: fmt.Sprintf("%d datasets", len(report.Datasets)),
+		}
+		switch report.Status {
+		case "passed":
+			event.Status = "success"
+		case "failed":
+			event.Status = "failure"
+		default:
+			event.Status = "running"
+		}
+		events = append(events, event)
+	}
+
+	if s.slaTracker != nil {
+		for _, result := range s.slaTracker.Results(from, to) {
+			day, _ := time.ParseInLocation("2006-01-02", result.Date, time.Local)
+			event := calendarEvent{
+				Kind:   eventAudit,
+				Title:  "SLA " + result.Dataset,
+				Start:  day,
+				Date:   result.Date,
+				Status: "success",
+			}
+			if !result.Met {
+				event.Status = "failure"
+				event.Detail = "SLA missed"
+			}
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
+
+func (s *Server) schedule(from, to time.Time) []calendarEvent {
+	events := []calendarEvent{}
+
+	for _, run := range s.scheduler.Upcoming(from, to) {
+		title := fmt.Sprintf("Snapshot %s (%s)", run.Target, run.Job)
+		if run.Kind == scheduler.RunScrub {
+			title = "Scrub " + run.Target
+		}
+		events = append(events, calendarEvent{
+			Kind:   run.Kind,
+			Title:  title,
+			Start:  run.At,
+			Status: "scheduled",
+			Detail: run.Schedule,
+		})
+	}
+
+	if s.slaTracker != nil {
+		for _, deadline := range s.slaTracker.Deadlines(from, to) {
+			events = append(events, calendarEvent{
+				Kind:   eventAudit,
+				Title:  "SLA deadline " + deadline.Dataset,
+				Start:  deadline.At,
+				Status: "scheduled",
+			})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
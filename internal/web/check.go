@@ -0,0 +1,240 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/sla"
+	"zfsrabbit/internal/statuscheck"
+	"zfsrabbit/internal/zfs"
+)
+
+// Default backup freshness thresholds: a nightly backup that is an hour or
+// two late warns, one that missed a whole night is critical
+const (
+	defaultFreshnessWarning  = 26 * time.Hour
+	defaultFreshnessCritical = 48 * time.Hour
+)
+
+// handleCheck serves Nagios/Icinga-style checks: /api/check/backup-freshness
+// and /api/check/pool-health. Thresholds are taken from the warning and
+// critical query parameters. The result is JSON, or the plugin output line
+// with format=text.
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var result statuscheck.CheckResult
+	switch name := strings.TrimPrefix(r.URL.Path, "/api/check/"); name {
+	case "backup-freshness":
+		warning, critical, err := durationThresholds(query.Get("warning"), query.Get("critical"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var slaStatus []sla.Status
+		if s.slaTracker != nil {
+			slaStatus = s.slaTracker.Status()
+		}
+		result = backupFreshness(s.scheduler.Jobs(), slaStatus, query.Get("dataset"), warning, critical, time.Now())
+
+	case "pool-health":
+		warning, critical, err := percentThresholds(query.Get("warning"), query.Get("critical"),
+			s.config.Monitor.CapacityWarningPercent, s.config.Monitor.CapacityCriticalPercent)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), s.config.Monitor.CheckTimeout)
+		defer cancel()
+		result = checkPools(ctx, query.Get("pool"), warning, critical)
+
+	default:
+		http.Error(w, fmt.Sprintf("Unknown check %q", name), http.StatusNotFound)
+		return
+	}
+
+	if query.Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, result.String())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func durationThresholds(warningParam, criticalParam string) (time.Duration, time.Duration, error) {
+	warning, critical := defaultFreshnessWarning, defaultFreshnessCritical
+	var err error
+	if warningParam != "" {
+		if warning, err = time.ParseDuration(warningParam); err != nil || warning <= 0 {
+			return 0, 0, fmt.Errorf("warning must be a positive duration such as 26h")
+		}
+	}
+	if criticalParam != "" {
+		if critical, err = time.ParseDuration(criticalParam); err != nil || critical <= 0 {
+			return 0, 0, fmt.Errorf("critical must be a positive duration such as 48h")
+		}
+	}
+	if warning > critical {
+		return 0, 0, fmt.Errorf("warning cannot exceed critical")
+	}
+	return warning, critical, nil
+}
+
+func percentThresholds(warningParam, criticalParam string, warning, critical int) (int, int, error) {
+	var err error
+	if warningParam != "" {
+		if warning, err = strconv.Atoi(strings.TrimSuffix(warningParam, "%")); err != nil || warning < 1 || warning > 100 {
+			return 0, 0, fmt.Errorf("warning must be a percentage between 1 and 100")
+		}
+	}
+	if criticalParam != "" {
+		if critical, err = strconv.Atoi(strings.TrimSuffix(criticalParam, "%")); err != nil || critical < 1 || critical > 100 {
+			return 0, 0, fmt.Errorf("critical must be a percentage between 1 and 100")
+		}
+	}
+	if warning > critical {
+		return 0, 0, fmt.Errorf("warning cannot exceed critical")
+	}
+	return warning, critical, nil
+}
+
+// backupFreshness grades how long ago each target last received a snapshot.
+// Targets that have not replicated since the daemon started fall back to the
+// SLA tracker's record of the dataset's last success.
+func backupFreshness(jobs []scheduler.JobStatus, slaStatus []sla.Status, dataset string, warning, critical time.Duration, now time.Time) statuscheck.CheckResult {
+	lastSLASuccess := make(map[string]*time.Time)
+	for _, status := range slaStatus {
+		lastSLASuccess[status.Dataset] = status.LastSuccess
+	}
+
+	result := statuscheck.NewCheckResult(statuscheck.ExitHealthy, "")
+	var problems []string
+	checked := 0
+	for _, job := range jobs {
+		if dataset != "" && job.Dataset != dataset {
+			continue
+		}
+		for _, target := range job.Targets {
+			checked++
+			label := fmt.Sprintf("%s to %s", job.Dataset, target.Name)
+
+			lastSuccess := target.LastSuccess
+			if lastSuccess == nil {
+				lastSuccess = lastSLASuccess[job.Dataset]
+			}
+			if lastSuccess == nil {
+				result.Escalate(statuscheck.ExitUnknown)
+				problems = append(problems, label+" has no successful send recorded")
+				continue
+			}
+
+			age := now.Sub(*lastSuccess)
+			result.Perfdata = append(result.Perfdata, fmt.Sprintf("'%s:%s'=%ds;%d;%d;0",
+				job.Dataset, target.Name, int64(age.Seconds()), int64(warning.Seconds()), int64(critical.Seconds())))
+			switch {
+			case age >= critical:
+				result.Escalate(statuscheck.ExitUnhealthy)
+			case age >= warning:
+				result.Escalate(statuscheck.ExitWarning)
+			default:
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s last replicated %s ago", label, age.Round(time.Minute)))
+		}
+	}
+
+	switch {
+	case checked == 0:
+		result = statuscheck.NewCheckResult(statuscheck.ExitUnknown, "no replication targets match")
+	case len(problems) > 0:
+		result.Message = strings.Join(problems, ", ")
+	default:
+		result.Message = fmt.Sprintf("%d targets replicated within %s", checked, warning)
+	}
+	return result
+}
+
+// checkPools reads every pool's health and capacity and grades them
+func checkPools(ctx context.Context, poolFilter string, warning, critical int) statuscheck.CheckResult {
+	pools, err := zfs.GetPoolsContext(ctx)
+	if err != nil {
+		return statuscheck.NewCheckResult(statuscheck.ExitUnknown, "failed to list pools: %v", err)
+	}
+
+	var statuses []*zfs.PoolStatus
+	capacities := make(map[string]int)
+	for _, pool := range pools {
+		if poolFilter != "" && pool != poolFilter {
+			continue
+		}
+		status, err := zfs.GetPoolStatusContext(ctx, pool)
+		if err != nil {
+			return statuscheck.NewCheckResult(statuscheck.ExitUnknown, "failed to get status of %s: %v", pool, err)
+		}
+		statuses = append(statuses, status)
+		if capacity, err := zfs.GetPoolCapacity(ctx, pool); err == nil {
+			capacities[pool] = capacity.Capacity
+		}
+	}
+	return poolHealth(statuses, capacities, warning, critical)
+}
+
+// poolHealth grades pools: DEGRADED is a warning, any other state than
+// ONLINE or DEGRADED, or data errors, are critical, and so is capacity at or
+// past the critical percentage
+func poolHealth(statuses []*zfs.PoolStatus, capacities map[string]int, warning, critical int) statuscheck.CheckResult {
+	if len(statuses) == 0 {
+		return statuscheck.NewCheckResult(statuscheck.ExitUnknown, "no pools found")
+	}
+
+	result := statuscheck.NewCheckResult(statuscheck.ExitHealthy, "")
+	var problems []string
+	for _, status := range statuses {
+		switch status.State {
+		case "ONLINE":
+		case "DEGRADED":
+			result.Escalate(statuscheck.ExitWarning)
+			problems = append(problems, fmt.Sprintf("%s is DEGRADED", status.Pool))
+		default:
+			result.Escalate(statuscheck.ExitUnhealthy)
+			problems = append(problems, fmt.Sprintf("%s is %s", status.Pool, status.State))
+		}
+		if len(status.Errors) > 0 {
+			result.Escalate(statuscheck.ExitUnhealthy)
+			problems = append(problems, fmt.Sprintf("%s has %d errors", status.Pool, len(status.Errors)))
+		}
+
+		capacity, ok := capacities[status.Pool]
+		if !ok {
+			continue
+		}
+		result.Perfdata = append(result.Perfdata, fmt.Sprintf("'%s'=%d%%;%d;%d;0;100", status.Pool, capacity, warning, critical))
+		switch {
+		case capacity >= critical:
+			result.Escalate(statuscheck.ExitUnhealthy)
+		case capacity >= warning:
+			result.Escalate(statuscheck.ExitWarning)
+		default:
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s is %d%% full", status.Pool, capacity))
+	}
+
+	if len(problems) > 0 {
+		result.Message = strings.Join(problems, ", ")
+	} else {
+		result.Message = fmt.Sprintf("%d pools ONLINE", len(statuses))
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zfsrabbit/internal/restore"
+)
+
+// handleDrill starts a DR drill: POST {"datasets":[{"dataset":"backup/data","snapshot":"..."}]}
+func (s *Server) handleDrill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Datasets []restore.DrillTarget `json:"datasets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.drillManager.StartDrill(req.Datasets)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start drill: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleDrillReports lists drill reports, or returns one by ID from
+// /api/drill/reports/<id>; add ?format=text for the printable form
+func (s *Server) handleDrillReports(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/drill/reports"), "/")
+
+	if id == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.drillManager.ListReports())
+		return
+	}
+
+	report, ok := s.drillManager.GetReport(id)
+	if !ok {
+		http.Error(w, "Drill report not found", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(report.Text()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/events"
+)
+
+func TestHandleEvents(t *testing.T) {
+	srv := createTestServer(t)
+	bus := events.NewBus()
+	srv.SetEvents(bus)
+
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleEvents))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	// The retry hint is flushed once the subscription is in place
+	if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("Expected retry hint, got %q", line)
+	}
+	reader.ReadString('\n')
+
+	bus.Publish(events.TypeRestore, events.RestoreChange{ID: "restore_1", Status: "completed", Progress: 100})
+
+	lines := make(chan string)
+	go func() {
+		for i := 0; i < 2; i++ {
+			line, _ := reader.ReadString('\n')
+			lines <- line
+		}
+	}()
+
+	for _, want := range []string{"event: restore\n", `"status":"completed"`} {
+		select {
+		case line := <-lines:
+			if !strings.Contains(line, want) {
+				t.Errorf("Expected %q, got %q", want, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for event")
+		}
+	}
+}
+
+func TestEventStreamOutlivesWriteTimeoutAndEndsOnShutdown(t *testing.T) {
+	srv := createTestServer(t)
+	bus := events.NewBus()
+	srv.SetEvents(bus)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(srv.handleEvents))
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	reader.ReadString('\n')
+	reader.ReadString('\n')
+
+	time.Sleep(300 * time.Millisecond)
+	bus.Publish(events.TypePool, events.PoolChange{Pool: "tank", State: "ONLINE"})
+	if line, err := reader.ReadString('\n'); err != nil || line != "event: pool\n" {
+		t.Fatalf("Expected the stream to outlive write_timeout, got %q, %v", line, err)
+	}
+	reader.ReadString('\n')
+	reader.ReadString('\n')
+
+	done := make(chan error)
+	go func() {
+		_, err := reader.ReadString('\n')
+		done <- err
+	}()
+	srv.Shutdown(context.Background())
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected the stream to end on shutdown")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the stream to end")
+	}
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/restore"
+)
+
+// handleFileSessions lists file restore sessions (GET) or opens one for a
+// snapshot (POST {"dataset":"backup/data","snapshot":"...","local":false})
+func (s *Server) handleFileSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.fileBrowser.Sessions())
+
+	case http.MethodPost:
+		var req restore.BrowseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		user, _, _ := s.authenticate(r)
+		session, err := s.fileBrowser.Open(req)
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+		}
+		audit.Record(audit.Event{Actor: user, Action: "browse " + req.Dataset + "@" + req.Snapshot, Remote: r.RemoteAddr, Outcome: outcome})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to open snapshot: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFileSession serves one session under /api/files/sessions/<id>:
+// GET reports it, DELETE closes it, GET .../list?path= lists a directory
+// and GET .../download?path= downloads a file
+func (s *Server) handleFileSession(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/files/sessions/"), "/"), "/", 2)
+	id := parts[0]
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	session, exists := s.fileBrowser.Session(id)
+	if !exists {
+		http.Error(w, fmt.Sprintf("File restore session %s not found", id), http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+
+	case action == "" && r.Method == http.MethodDelete:
+		user, _, _ := s.authenticate(r)
+		err := s.fileBrowser.Close(id)
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+		}
+		audit.Record(audit.Event{Actor: user, Action: "browse_close " + id, Remote: r.RemoteAddr, Outcome: outcome})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Closed file restore session %s", id),
+		})
+
+	case action == "list" && r.Method == http.MethodGet:
+		files, err := s.fileBrowser.List(id, r.URL.Query().Get("path"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(files)
+
+	case action == "download" && r.Method == http.MethodGet:
+		file, entry, err := s.fileBrowser.OpenFile(id, r.URL.Query().Get("path"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		user, _, _ := s.authenticate(r)
+		audit.Record(audit.Event{Actor: user, Action: fmt.Sprintf("download %s@%s:%s", session.SourceDataset, session.Snapshot, entry.Path), Remote: r.RemoteAddr, Outcome: "success"})
+
+		w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(entry.Name))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		clearWriteDeadline(w) // Restored files can be too large to send within write_timeout
+		http.ServeContent(w, r, entry.Name, entry.ModTime, file)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
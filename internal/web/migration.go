@@ -3,13 +3,13 @@ package web
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"zfsrabbit/internal/restore"
 	"zfsrabbit/internal/scheduler"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/utils"
 	"zfsrabbit/internal/zfs"
 )
 
@@ -19,6 +19,7 @@ type MigrationWizard struct {
 	zfsManager     *zfs.Manager
 	restoreManager *restore.RestoreManager
 	scheduler      *scheduler.Scheduler
+	path           string // Where the session is saved; "" keeps it in memory only
 }
 
 // MigrationSession tracks an active migration
@@ -28,7 +29,7 @@ type MigrationSession struct {
 	TargetHost      string    `json:"targetHost"`
 	TargetDataset   string    `json:"targetDataset"`
 	CurrentStep     int       `json:"currentStep"`
-	Status          string    `json:"status"` // active, completed, failed, cancelled
+	Status          string    `json:"status"` // active, completed, failed, cancelled, interrupted
 	StartTime       time.Time `json:"startTime"`
 	
 	// Step-specific data
@@ -42,6 +43,12 @@ type MigrationSession struct {
 	WorkloadStarted  bool      `json:"workloadStarted"`
 	TargetPrepared   bool      `json:"targetPrepared"`
 	
+	// Share management: the source's shares are turned off for the final
+	// sync so clients can't write to it, and turned back on if cancelled
+	ManageShares   bool        `json:"manageShares"`
+	SourceShares   *zfs.Shares `json:"sourceShares,omitempty"`
+	SharesDisabled bool        `json:"sharesDisabled,omitempty"`
+	
 	Error            string    `json:"error,omitempty"`
 }
 
@@ -97,6 +104,47 @@ func NewMigrationWizard(transport *transport.SSHTransport, zfsManager *zfs.Manag
 	}
 }
 
+// open saves the session to path from now on and loads the one saved there
+// by the previous run. A session that was still active is marked interrupted:
+// whatever step was running died with the daemon, so it can't simply carry
+// on. Cancelling it still turns the source's shares back on.
+func (w *MigrationWizard) open(path string) {
+	w.path = path
+	if path == "" {
+		return
+	}
+
+	var session *MigrationSession
+	if err := utils.ReadJSONFile(path, &session); err != nil {
+		logger.Error("Failed to load migration session", "path", path, "err", err)
+		return
+	}
+	if session == nil {
+		return
+	}
+	if session.CurrentStep < 0 || session.CurrentStep >= len(migrationSteps) {
+		logger.Warn("Ignoring migration session with unknown step", "path", path, "step", session.CurrentStep)
+		return
+	}
+	if session.Status == "active" {
+		logger.Warn("Migration was active when zfsrabbit stopped, marking it interrupted", "migration", session.ID, "step", session.CurrentStep)
+		session.Status = "interrupted"
+		session.Error = fmt.Sprintf("interrupted by a zfsrabbit restart at step %d (%s)", session.CurrentStep, migrationSteps[session.CurrentStep].Title)
+	}
+	activeMigrationSession = session
+	w.save()
+}
+
+// save writes the current session to the session file
+func (w *MigrationWizard) save() {
+	if w.path == "" || activeMigrationSession == nil {
+		return
+	}
+	if err := utils.WriteJSONAtomic(w.path, activeMigrationSession, 0600); err != nil {
+		logger.Error("Failed to save migration session", "path", w.path, "err", err)
+	}
+}
+
 // StartMigrationHandler starts a new migration session (source node)
 func (w *MigrationWizard) StartMigrationHandler(rw http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -108,6 +156,7 @@ func (w *MigrationWizard) StartMigrationHandler(rw http.ResponseWriter, r *http.
 		SourceDataset string `json:"sourceDataset"`
 		TargetHost    string `json:"targetHost"`
 		TargetDataset string `json:"targetDataset"`
+		ManageShares  bool   `json:"manageShares"` // Turn the source's NFS/SMB shares off for the final sync
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -127,13 +176,15 @@ func (w *MigrationWizard) StartMigrationHandler(rw http.ResponseWriter, r *http.
 		SourceDataset: req.SourceDataset,
 		TargetHost:    req.TargetHost,
 		TargetDataset: req.TargetDataset,
+		ManageShares:  req.ManageShares,
 		CurrentStep:   0,
 		Status:        "active",
 		StartTime:     time.Now(),
 	}
+	w.save()
 
-	log.Printf("Started migration session %s: %s -> %s:%s", 
-		sessionID, req.SourceDataset, req.TargetHost, req.TargetDataset)
+	logger.Info("Started migration session", "migration", sessionID, "source", req.SourceDataset,
+		"target_host", req.TargetHost, "target", req.TargetDataset)
 
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(activeMigrationSession)
@@ -185,12 +236,12 @@ func (w *MigrationWizard) ExecuteStepHandler(rw http.ResponseWriter, r *http.Req
 		err = w.performInitialSync(session)
 	case "confirm_workload_stopped":
 		session.WorkloadStopped = true
-		log.Printf("Migration %s: User confirmed workload stopped", session.ID)
+		logger.Info("User confirmed workload stopped", "migration", session.ID)
 	case "final_sync":
 		err = w.performFinalSync(session)
 	case "confirm_workload_started":
 		session.WorkloadStarted = true
-		log.Printf("Migration %s: User confirmed workload started", session.ID)
+		logger.Info("User confirmed workload started", "migration", session.ID)
 	case "complete":
 		err = w.completeMigration(session)
 	default:
@@ -200,7 +251,8 @@ func (w *MigrationWizard) ExecuteStepHandler(rw http.ResponseWriter, r *http.Req
 	if err != nil {
 		session.Status = "failed"
 		session.Error = err.Error()
-		log.Printf("Migration %s failed at step %d: %v", session.ID, session.CurrentStep, err)
+		w.save()
+		logger.Error("Migration failed", "migration", session.ID, "step", session.CurrentStep, "err", err)
 		
 		rw.Header().Set("Content-Type", "application/json")
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -216,8 +268,9 @@ func (w *MigrationWizard) ExecuteStepHandler(rw http.ResponseWriter, r *http.Req
 	if session.CurrentStep < len(migrationSteps)-1 {
 		session.CurrentStep++
 	}
+	w.save()
 
-	log.Printf("Migration %s completed step %d: %s", session.ID, session.CurrentStep-1, step.Title)
+	logger.Info("Migration completed step", "migration", session.ID, "step", session.CurrentStep-1, "title", step.Title)
 
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(map[string]interface{}{
@@ -245,18 +298,27 @@ func (w *MigrationWizard) validateSetup(session *MigrationSession) error {
 		return fmt.Errorf("source dataset %s not found", session.SourceDataset)
 	}
 
+	// Record the source's shares so the target can be shared the same way
+	if session.ManageShares {
+		shares, err := zfs.GetShares(session.SourceDataset)
+		if err != nil {
+			return fmt.Errorf("failed to read shares of %s: %w", session.SourceDataset, err)
+		}
+		session.SourceShares = &shares
+	}
+
 	// Test remote connectivity
 	if err := w.transport.Connect(); err != nil {
 		return fmt.Errorf("failed to connect to backup server: %w", err)
 	}
 
-	log.Printf("Migration %s: Setup validation passed", session.ID)
+	logger.Info("Migration setup validation passed", "migration", session.ID)
 	return nil
 }
 
 func (w *MigrationWizard) performInitialSync(session *MigrationSession) error {
 	// Just trigger a normal backup - this creates and sends a regular autosnap_* snapshot
-	log.Printf("Migration %s: Triggering normal backup for initial sync", session.ID)
+	logger.Info("Triggering normal backup for initial sync", "migration", session.ID)
 	
 	if err := w.scheduler.TriggerSnapshot(); err != nil {
 		return fmt.Errorf("failed to trigger backup: %w", err)
@@ -270,7 +332,7 @@ func (w *MigrationWizard) performInitialSync(session *MigrationSession) error {
 	session.InitialSyncTime = &time.Time{}
 	*session.InitialSyncTime = time.Now()
 	
-	log.Printf("Migration %s: Initial backup triggered - regular snapshot will be sent to backup server", session.ID)
+	logger.Info("Initial backup triggered, regular snapshot will be sent to backup server", "migration", session.ID)
 	return nil
 }
 
@@ -280,9 +342,17 @@ func (w *MigrationWizard) performFinalSync(session *MigrationSession) error {
 	}
 
 	// Just trigger another normal backup - this creates and sends another regular autosnap_* snapshot
-	log.Printf("Migration %s: Triggering final backup (incremental from %s)", 
-		session.ID, session.InitialSnapshot)
-	
+	logger.Info("Triggering final backup", "migration", session.ID, "incremental_from", session.InitialSnapshot)
+
+	// Stop sharing the source so no client writes land after the final snapshot
+	if session.ManageShares {
+		if err := zfs.SetShares(session.SourceDataset, zfs.Shares{NFS: "off", SMB: "off"}); err != nil {
+			return fmt.Errorf("failed to unshare %s: %w", session.SourceDataset, err)
+		}
+		session.SharesDisabled = true
+		logger.Info("Turned off shares for cutover", "migration", session.ID, "dataset", session.SourceDataset)
+	}
+
 	if err := w.scheduler.TriggerSnapshot(); err != nil {
 		return fmt.Errorf("failed to trigger final backup: %w", err)
 	}
@@ -291,7 +361,7 @@ func (w *MigrationWizard) performFinalSync(session *MigrationSession) error {
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
 	session.FinalSnapshot = fmt.Sprintf("autosnap_%s", timestamp)
 	
-	log.Printf("Migration %s: Final backup triggered - incremental changes will be sent to backup server", session.ID)
+	logger.Info("Final backup triggered, incremental changes will be sent to backup server", "migration", session.ID)
 	return nil
 }
 
@@ -304,7 +374,7 @@ func (w *MigrationWizard) completeMigration(session *MigrationSession) error {
 	session.CompletionTime = &time.Time{}
 	*session.CompletionTime = time.Now()
 	
-	log.Printf("Migration %s completed successfully", session.ID)
+	logger.Info("Migration completed successfully", "migration", session.ID)
 	return nil
 }
 
@@ -328,8 +398,8 @@ func (w *MigrationWizard) PrepareTargetHandler(rw http.ResponseWriter, r *http.R
 		return
 	}
 
-	log.Printf("Target node: Preparing dataset %s from snapshot %s (source: %s)", 
-		req.TargetDataset, req.SnapshotName, req.SourceDataset)
+	logger.Info("Target node: preparing dataset from snapshot", "target", req.TargetDataset,
+		"snapshot", req.SnapshotName, "source", req.SourceDataset)
 	
 	// Use the restore manager to restore the initial snapshot from backup server
 	// IMPORTANT: This may overwrite existing data on target if target dataset already exists
@@ -337,7 +407,7 @@ func (w *MigrationWizard) PrepareTargetHandler(rw http.ResponseWriter, r *http.R
 	job, err := w.restoreManager.StartRestoreFromDatasetWithTracking(req.SourceDataset, req.SnapshotName, req.TargetDataset)
 	if err != nil {
 		if err.Error() == "restore operation already in progress" {
-			log.Printf("Target node: Restore blocked - operation already in progress")
+			logger.Warn("Target node: restore blocked, operation already in progress", "target", req.TargetDataset)
 			rw.Header().Set("Content-Type", "application/json")
 			rw.WriteHeader(http.StatusConflict)
 			json.NewEncoder(rw).Encode(map[string]interface{}{
@@ -345,13 +415,13 @@ func (w *MigrationWizard) PrepareTargetHandler(rw http.ResponseWriter, r *http.R
 				"error":   "restore operation already in progress",
 			})
 		} else {
-			log.Printf("Target node: Failed to start restore: %v", err)
+			logger.Error("Target node: failed to start restore", "target", req.TargetDataset, "err", err)
 			http.Error(rw, fmt.Sprintf("Failed to start restore: %v", err), http.StatusInternalServerError)
 		}
 		return
 	}
 	
-	log.Printf("Target node: Started restore job %s for migration snapshot %s", job.ID, req.SnapshotName)
+	logger.Info("Target node: started restore job for migration snapshot", "job", job.ID, "snapshot", req.SnapshotName)
 	
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(map[string]interface{}{
@@ -372,6 +442,8 @@ func (w *MigrationWizard) FinalRestoreHandler(rw http.ResponseWriter, r *http.Re
 		SourceDataset string `json:"sourceDataset"`
 		TargetDataset string `json:"targetDataset"`
 		SnapshotName  string `json:"snapshotName"`
+		ShareNFS      string `json:"sharenfs,omitempty"` // Share the target like the source once restored
+		ShareSMB      string `json:"sharesmb,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -379,19 +451,20 @@ func (w *MigrationWizard) FinalRestoreHandler(rw http.ResponseWriter, r *http.Re
 		return
 	}
 
-	log.Printf("Target node: Final incremental restore to %s from snapshot %s (source: %s)", 
-		req.TargetDataset, req.SnapshotName, req.SourceDataset)
+	logger.Info("Target node: final incremental restore", "target", req.TargetDataset,
+		"snapshot", req.SnapshotName, "source", req.SourceDataset)
 	
 	// Use the restore manager to restore the final incremental snapshot from backup server
 	// This will be an incremental restore on top of the initial snapshot already restored
-	job, err := w.restoreManager.StartRestoreFromDatasetWithTracking(req.SourceDataset, req.SnapshotName, req.TargetDataset)
+	mount := restore.MountOptions{Shares: zfs.Shares{NFS: req.ShareNFS, SMB: req.ShareSMB}}
+	job, err := w.restoreManager.StartRestoreWithOptions(req.SourceDataset, req.SnapshotName, req.TargetDataset, mount)
 	if err != nil {
-		log.Printf("Target node: Failed to start final restore: %v", err)
+		logger.Error("Target node: failed to start final restore", "target", req.TargetDataset, "err", err)
 		http.Error(rw, fmt.Sprintf("Failed to start final restore: %v", err), http.StatusInternalServerError)
 		return
 	}
 	
-	log.Printf("Target node: Started final restore job %s for migration snapshot %s", job.ID, req.SnapshotName)
+	logger.Info("Target node: started final restore job for migration snapshot", "job", job.ID, "snapshot", req.SnapshotName)
 	
 	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(map[string]interface{}{
@@ -409,8 +482,19 @@ func (w *MigrationWizard) CancelMigrationHandler(rw http.ResponseWriter, r *http
 	}
 
 	if activeMigrationSession != nil {
-		activeMigrationSession.Status = "cancelled"
-		log.Printf("Migration %s cancelled by user", activeMigrationSession.ID)
+		session := activeMigrationSession
+		session.Status = "cancelled"
+		logger.Info("Migration cancelled by user", "migration", session.ID)
+
+		// The source stays in service, so share it again
+		if session.SharesDisabled && session.SourceShares != nil {
+			if err := zfs.SetShares(session.SourceDataset, *session.SourceShares); err != nil {
+				logger.Error("Failed to restore shares", "migration", session.ID, "dataset", session.SourceDataset, "err", err)
+			} else {
+				session.SharesDisabled = false
+			}
+		}
+		w.save()
 	}
 
 	rw.Header().Set("Content-Type", "application/json")
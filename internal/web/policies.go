@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zfsrabbit/internal/policy"
+	"zfsrabbit/internal/state"
+)
+
+// handlePolicies serves the declarative policy API: GET returns the policy set
+// in effect, PUT replaces it and reconciles the scheduler. PUT is idempotent.
+func (s *Server) handlePolicies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.scheduler.Policy())
+
+	case http.MethodPut:
+		var set policy.Set
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&set); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid policy document: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := set.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid policy set: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+		if err := set.Matches(s.scheduler.Config()); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid policy set: %v", err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		changed, err := s.scheduler.ApplyPolicy(&set)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to apply policy set: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if path := state.PathIn(s.config.Server.StateDir, state.PoliciesFile); changed && path != "" {
+			if err := set.Save(path); err != nil {
+				logger.Error("Failed to persist policy set", "err", err)
+				http.Error(w, fmt.Sprintf("Policy applied but not persisted: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"changed":  changed,
+			"policies": s.scheduler.Policy().Policies,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/pool"
+)
+
+func (s *Server) handlePoolPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	http.ServeFile(w, r, "web/templates/pool.html")
+}
+
+// handlePoolDisks lists the monitored disks and whether each can be used
+func (s *Server) handlePoolDisks(w http.ResponseWriter, r *http.Request) {
+	disks, err := s.poolAssistant.Disks(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(disks)
+}
+
+// handlePoolPlans lists pool plans (GET) or validates and dry-runs a pool
+// creation or expansion (POST). Plans run on POST /api/pool/confirm/<id>.
+func (s *Server) handlePoolPlans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.poolAssistant.Plans())
+
+	case http.MethodPost:
+		var req pool.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		plan, err := s.poolAssistant.Plan(r.Context(), req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to plan pool change: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handlePoolConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	planID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/pool/confirm/"))
+	if planID == "" {
+		http.Error(w, "Plan ID required", http.StatusBadRequest)
+		return
+	}
+
+	user, _, _ := s.authenticate(r)
+	plan, err := s.poolAssistant.Confirm(r.Context(), planID)
+	if plan == nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	audit.Record(audit.Event{Actor: user, Action: fmt.Sprintf("pool_%s %s", plan.Request.Action, plan.Request.Pool), Remote: r.RemoteAddr, Outcome: outcome})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(plan)
+}
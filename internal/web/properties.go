@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zfsrabbit/internal/validation"
+)
+
+// handleProperties reports the expected ZFS properties of each managed
+// dataset and any that have drifted from them
+func (s *Server) handleProperties(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"expected": s.config.ExpectedProperties(),
+		"drift":    s.monitor.GetPropertyDrift(),
+	})
+}
+
+// handlePropertiesReapply sets the expected properties on a dataset again,
+// or on every drifted dataset when none is given
+func (s *Server) handlePropertiesReapply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Dataset string `json:"dataset"` // Every drifted dataset if empty
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Dataset != "" {
+		if err := validation.ValidateDatasetName(req.Dataset); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid dataset: %v", err), http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.config.ExpectedProperties()[req.Dataset]; !ok {
+			http.Error(w, fmt.Sprintf("No expected properties are configured for %s", req.Dataset), http.StatusNotFound)
+			return
+		}
+	}
+
+	user, _, _ := s.authenticate(r)
+	if err := s.monitor.ReapplyProperties(r.Context(), req.Dataset); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Expected ZFS properties reapplied", "user", user)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"drift": s.monitor.GetPropertyDrift(),
+	})
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"zfsrabbit/internal/scheduler"
+)
+
+// handleRenames lists managed datasets that were renamed out from under
+// zfsrabbit; POST {"dataset": old name} accepts a rename, switching the job
+// and its config to the new name
+func (s *Server) handleRenames(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		renames := s.scheduler.Renames()
+		if renames == nil {
+			renames = []scheduler.DatasetRename{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(renames)
+
+	case http.MethodPost:
+		var req struct {
+			Dataset string `json:"dataset"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		rename, err := s.scheduler.AcceptRename(req.Dataset)
+		if rename == nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rename)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
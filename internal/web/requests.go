@@ -0,0 +1,132 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/restore"
+)
+
+// handleRequestPage serves the restore request form for non-admin users
+func (s *Server) handleRequestPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	http.ServeFile(w, r, "web/templates/request.html")
+}
+
+// handleRestoreRequests lists restore requests (requesters see only their own)
+// or creates one
+func (s *Server) handleRestoreRequests(w http.ResponseWriter, r *http.Request) {
+	if s.restoreRequests == nil {
+		http.Error(w, "Restore requests are not enabled", http.StatusNotFound)
+		return
+	}
+
+	user, role, _ := s.authenticate(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		requester := user
+		if role == config.RoleAdmin {
+			requester = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.restoreRequests.List(requester))
+
+	case http.MethodPost:
+		var req struct {
+			Snapshot      string `json:"snapshot"`
+			Dataset       string `json:"dataset"`
+			SourceDataset string `json:"source_dataset,omitempty"`
+			Mountpoint    string `json:"mountpoint,omitempty"`
+			Reason        string `json:"reason,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Snapshot == "" || req.Dataset == "" {
+			http.Error(w, "Snapshot and dataset are required", http.StatusBadRequest)
+			return
+		}
+
+		created, err := s.restoreRequests.Create(restore.Request{
+			Snapshot:      req.Snapshot,
+			SourceDataset: req.SourceDataset,
+			TargetDataset: req.Dataset,
+			Mountpoint:    req.Mountpoint,
+			Reason:        req.Reason,
+			RequestedBy:   user,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit.Record(audit.Event{Actor: user, Action: "restore_requested " + created.ID, Remote: r.RemoteAddr, Outcome: "success"})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRestoreRequestDecision approves or rejects a request:
+// POST /api/restore/requests/<id>/approve or /reject with an optional {"note"}
+func (s *Server) handleRestoreRequestDecision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.restoreRequests == nil {
+		http.Error(w, "Restore requests are not enabled", http.StatusNotFound)
+		return
+	}
+
+	id, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/restore/requests/"), "/")
+	if !ok || id == "" {
+		http.Error(w, "Expected /api/restore/requests/<id>/approve or /reject", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, _, _ := s.authenticate(r)
+
+	var decided restore.Request
+	var err error
+	switch action {
+	case "approve":
+		decided, err = s.restoreRequests.Approve(id, user, body.Note)
+	case "reject":
+		decided, err = s.restoreRequests.Reject(id, user, body.Note)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown action %q", action), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		status := http.StatusConflict
+		if _, exists := s.restoreRequests.Get(id); !exists {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	audit.Record(audit.Event{Actor: user, Action: "restore_request_" + decided.Status + " " + id, Remote: r.RemoteAddr, Outcome: "success"})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decided)
+}
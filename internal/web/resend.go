@@ -0,0 +1,69 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// handleResend lists corrective re-send jobs (GET) or plans one for a
+// snapshot flagged for verification (POST). Plans that pass the safety checks
+// wait for POST /api/resend/confirm/<id>.
+func (s *Server) handleResend(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.scheduler.ResendJobs())
+
+	case http.MethodPost:
+		var req struct {
+			Dataset  string `json:"dataset"`
+			Snapshot string `json:"snapshot,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Dataset == "" {
+			http.Error(w, "Dataset is required", http.StatusBadRequest)
+			return
+		}
+
+		job, err := s.scheduler.PlanResend(req.Dataset, req.Snapshot)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to plan re-send: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleResendConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/resend/confirm/"))
+	if jobID == "" {
+		http.Error(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.scheduler.ConfirmResend(jobID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to confirm re-send: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Re-send %s confirmed - replacing remote snapshots", jobID),
+	})
+}
@@ -3,21 +3,44 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
+	"zfsrabbit/api/openapi"
+	"zfsrabbit/internal/alert"
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/compact"
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/display"
+	"zfsrabbit/internal/events"
+	"zfsrabbit/internal/features"
+	"zfsrabbit/internal/i18n"
+	"zfsrabbit/internal/inventory"
+	"zfsrabbit/internal/logging"
+	"zfsrabbit/internal/metrics"
 	"zfsrabbit/internal/monitor"
+	"zfsrabbit/internal/pool"
 	"zfsrabbit/internal/restore"
 	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/silence"
+	"zfsrabbit/internal/sla"
 	"zfsrabbit/internal/slack"
+	"zfsrabbit/internal/state"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/update"
+	"zfsrabbit/internal/utils"
+	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/version"
 	"zfsrabbit/internal/zfs"
 )
 
+var logger = logging.For("web")
+
 type Server struct {
 	config          *config.Config
 	scheduler       *scheduler.Scheduler
@@ -25,14 +48,34 @@ type Server struct {
 	zfsManager      *zfs.Manager
 	restoreManager  *restore.RestoreManager
 	migrationWizard *MigrationWizard
+	drillManager    *restore.DrillManager
+	fileBrowser     *restore.FileBrowser
 	slackHandler    *slack.CommandHandler
 	transport       *transport.SSHTransport
+	updateChecker   *update.Checker
+	slaTracker      *sla.Tracker
+	standby         *restore.Standby
+	restoreRequests *restore.Requests
+	compactor       *compact.Compactor
+	poolAssistant   *pool.Assistant
+	sessions        *sessionStore
+	tokens          *tokenStore
+	events          *events.Bus
+	silences        *silence.Store
+	alertHistory    *alert.History
 	httpServer      *http.Server
+	closing         chan struct{} // Closed on shutdown to end long-lived streams
+	closeOnce       sync.Once
 }
 
+// readHeaderTimeout bounds how long a client may take to send its request
+// headers, whatever server.read_timeout allows for the body
+const readHeaderTimeout = 10 * time.Second
+
 func NewServer(cfg *config.Config, sched *scheduler.Scheduler, mon *monitor.Monitor, zfsMgr *zfs.Manager, restoreMgr *restore.RestoreManager, transport *transport.SSHTransport) *Server {
 	slackHandler := slack.NewCommandHandler(&cfg.Slack, sched, mon, zfsMgr, restoreMgr, transport)
 	migrationWizard := NewMigrationWizard(transport, zfsMgr, restoreMgr, sched)
+	migrationWizard.open(state.PathIn(cfg.Server.StateDir, state.MigrationFile))
 
 	return &Server{
 		config:          cfg,
@@ -41,22 +84,104 @@ func NewServer(cfg *config.Config, sched *scheduler.Scheduler, mon *monitor.Moni
 		zfsManager:      zfsMgr,
 		restoreManager:  restoreMgr,
 		migrationWizard: migrationWizard,
+		drillManager:    restore.NewDrillManager(cfg, transport),
+		fileBrowser:     restore.NewFileBrowser(cfg, transport),
+		poolAssistant:   pool.New(mon),
+		sessions:        newSessionStore(cfg.Server.SessionTTL),
+		tokens:          newTokenStore(state.PathIn(cfg.Server.StateDir, state.TokensFile)),
 		slackHandler:    slackHandler,
 		transport:       transport,
+		closing:         make(chan struct{}),
 	}
 }
 
+// SetUpdateChecker adds release check results to the status report
+func (s *Server) SetUpdateChecker(checker *update.Checker) {
+	s.updateChecker = checker
+}
+
+// SetSLATracker serves SLA compliance from tracker
+func (s *Server) SetSLATracker(tracker *sla.Tracker) {
+	s.slaTracker = tracker
+}
+
+// SetStandby serves the warm standby's readiness at /api/standby
+func (s *Server) SetStandby(standby *restore.Standby) {
+	s.standby = standby
+}
+
+// SetRestoreRequests enables delegated restore requests on the web and Slack
+func (s *Server) SetRestoreRequests(requests *restore.Requests) {
+	s.restoreRequests = requests
+	s.slackHandler.SetRestoreRequests(requests)
+}
+
+// SetCompactor reports state store sizes and allows compaction on demand
+func (s *Server) SetCompactor(compactor *compact.Compactor) {
+	s.compactor = compactor
+}
+
+// SetEvents streams pool, send and restore changes from bus at /api/events
+func (s *Server) SetEvents(bus *events.Bus) {
+	s.events = bus
+}
+
+// SetSilences lets operators silence alerts on the web and Slack
+func (s *Server) SetSilences(store *silence.Store) {
+	s.silences = store
+	s.slackHandler.SetSilences(store)
+}
+
+// SetAlertHistory serves the alerts sent at /api/alerts
+func (s *Server) SetAlertHistory(history *alert.History) {
+	s.alertHistory = history
+}
+
+// DrillManager returns the DR drill manager so its reports can be compacted
+func (s *Server) DrillManager() *restore.DrillManager {
+	return s.drillManager
+}
+
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.basicAuth(s.handleIndex))
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/logout", s.handleLogout)
+	mux.HandleFunc("/api/tokens", s.requesterAuth(s.handleTokens))
+	mux.HandleFunc("/api/tokens/", s.requesterAuth(s.handleTokenRevoke))
 	mux.HandleFunc("/api/status", s.basicAuth(s.handleStatus))
+	mux.HandleFunc("/api/events", s.basicAuth(s.handleEvents))
 	mux.HandleFunc("/api/snapshots", s.basicAuth(s.handleSnapshots))
-	mux.HandleFunc("/api/trigger/snapshot", s.basicAuth(s.handleTriggerSnapshot))
-	mux.HandleFunc("/api/trigger/scrub", s.basicAuth(s.handleTriggerScrub))
-	mux.HandleFunc("/api/trigger/retry", s.basicAuth(s.handleRetryPendingSends))
+	mux.HandleFunc("/api/snapshots/destroyed", s.basicAuth(s.handleDestroyedSnapshots))
+	mux.HandleFunc("/api/snapshots/held", s.basicAuth(s.handleHeldSnapshots))
+	mux.HandleFunc("/api/renames", s.basicAuth(s.handleRenames))
+	mux.HandleFunc("/api/lag", s.basicAuth(s.handleLag))
+	mux.HandleFunc("/api/snapshots/verification", s.basicAuth(s.handleSnapshotsNeedingVerification))
+	mux.HandleFunc("/api/snapshots/archived", s.basicAuth(s.handleArchivedSnapshots))
+	mux.HandleFunc("/api/store/compact", s.basicAuth(s.handleCompactStores))
+	mux.HandleFunc("/api/trigger/snapshot", s.operatorAuth(s.handleTriggerSnapshot))
+	mux.HandleFunc("/api/trigger/scrub", s.operatorAuth(s.handleTriggerScrub))
+	mux.HandleFunc("/api/trigger/retry", s.operatorAuth(s.handleRetryPendingSends))
+	mux.HandleFunc("/api/backfill", s.operatorAuth(s.handleBackfill))
 	mux.HandleFunc("/api/restore", s.basicAuth(s.handleRestore))
 	mux.HandleFunc("/api/restore/jobs", s.basicAuth(s.handleRestoreJobs))
+	mux.HandleFunc("/api/restore/estimate", s.basicAuth(s.handleRestoreEstimate))
+	mux.HandleFunc("/api/restore/jobs/", s.basicAuth(s.handleRestoreJobCancel))
 	mux.HandleFunc("/api/restore/confirm/", s.basicAuth(s.handleRestoreConfirm))
+	mux.HandleFunc("/api/shares", s.basicAuth(s.handleShares))
+	mux.HandleFunc("/api/properties", s.basicAuth(s.handleProperties))
+	mux.HandleFunc("/api/properties/reapply", s.basicAuth(s.handlePropertiesReapply))
+	mux.HandleFunc("/api/files/sessions", s.adminAuth(s.handleFileSessions))
+	mux.HandleFunc("/api/files/sessions/", s.adminAuth(s.handleFileSession))
+	mux.HandleFunc("/api/restore/requests", s.requesterAuth(s.handleRestoreRequests))
+	mux.HandleFunc("/api/restore/requests/", s.basicAuth(s.handleRestoreRequestDecision))
+	mux.HandleFunc("/request", s.requesterAuth(s.handleRequestPage))
+	mux.HandleFunc("/api/resend", s.basicAuth(s.handleResend))
+	mux.HandleFunc("/api/resend/confirm/", s.basicAuth(s.handleResendConfirm))
+	mux.HandleFunc("/api/v1/policies", s.basicAuth(s.handlePolicies))
+	mux.HandleFunc("/api/drill", s.basicAuth(s.handleDrill))
+	mux.HandleFunc("/api/drill/reports", s.basicAuth(s.handleDrillReports))
+	mux.HandleFunc("/api/drill/reports/", s.basicAuth(s.handleDrillReports))
 	mux.HandleFunc("/api/remote/datasets", s.basicAuth(s.handleRemoteDatasets))
 	mux.HandleFunc("/api/remote/dataset/", s.basicAuth(s.handleRemoteDatasetInfo))
 	mux.HandleFunc("/api/migration/start", s.basicAuth(s.migrationWizard.StartMigrationHandler))
@@ -66,48 +191,208 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/api/migration/target/prepare", s.basicAuth(s.migrationWizard.PrepareTargetHandler))
 	mux.HandleFunc("/api/migration/target/restore", s.basicAuth(s.migrationWizard.FinalRestoreHandler))
 	mux.HandleFunc("/migration", s.basicAuth(s.handleMigrationPage))
+	mux.HandleFunc("/pool", s.basicAuth(s.handlePoolPage))
+	mux.HandleFunc("/calendar", s.basicAuth(s.handleCalendarPage))
+	mux.HandleFunc("/api/history", s.basicAuth(s.handleHistory))
+	mux.HandleFunc("/api/schedule", s.basicAuth(s.handleSchedule))
+	mux.HandleFunc("/api/pool/disks", s.basicAuth(s.handlePoolDisks))
+	mux.HandleFunc("/api/pool/plans", s.basicAuth(s.handlePoolPlans))
+	mux.HandleFunc("/api/pool/confirm/", s.basicAuth(s.handlePoolConfirm))
 	mux.HandleFunc("/health", s.handleHealth) // Unauthenticated health check
+	mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/metrics", s.basicAuth(s.handleMetrics))
+	mux.HandleFunc("/api/inventory", s.basicAuth(s.handleInventory))
+	mux.HandleFunc("/api/support/bundle", s.adminAuth(s.handleSupportBundle))
+	mux.HandleFunc("/api/features", s.basicAuth(s.handleFeatures))
+	mux.HandleFunc("/api/sla", s.basicAuth(s.handleSLA))
+	mux.HandleFunc("/api/standby", s.operatorAuth(s.handleStandby))
+	mux.HandleFunc("/api/silences", s.operatorAuth(s.handleSilences))
+	mux.HandleFunc("/api/alerts", s.basicAuth(s.handleAlerts))
+	mux.HandleFunc("/api/silences/", s.operatorAuth(s.handleSilenceRemove))
+	mux.HandleFunc("/api/check/", s.basicAuth(s.handleCheck))
+	mux.HandleFunc("/api/capabilities", s.basicAuth(s.handleCapabilities))
+	mux.HandleFunc("/api/version", s.basicAuth(s.handleVersion))
+	mux.HandleFunc("/api/i18n", s.basicAuth(s.handleI18n))
 	mux.HandleFunc("/slack/command", s.slackHandler.HandleSlashCommand)
+	mux.HandleFunc("/slack/interactive", s.slackHandler.HandleInteraction)
+	mux.HandleFunc("/slack/options", s.slackHandler.HandleOptions)
 	mux.HandleFunc("/static/", s.handleStatic)
 
 	addr := fmt.Sprintf(":%d", s.config.Server.Port)
 
 	s.httpServer = &http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       s.config.Server.ReadTimeout,
+		WriteTimeout:      s.config.Server.WriteTimeout,
+		IdleTimeout:       s.config.Server.IdleTimeout,
 	}
 
-	log.Printf("Web server starting on %s", addr)
-	return s.httpServer.ListenAndServe()
+	if s.config.Server.TLS.Enabled {
+		certFile, keyFile, err := certificatePaths(&s.config.Server)
+		if err != nil {
+			return err
+		}
+		s.httpServer.TLSConfig = newTLSConfig()
+
+		logger.Info("Web server starting", "addr", addr, "tls", true)
+		return serveResult(s.httpServer.ListenAndServeTLS(certFile, keyFile))
+	}
+
+	logger.Info("Web server starting", "addr", addr)
+	return serveResult(s.httpServer.ListenAndServe())
+}
+
+// serveResult treats the server closing for Shutdown as a clean exit, so
+// callers don't mistake a graceful stop for a failure
+func serveResult(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	// Don't leave temporary file restore datasets behind
+	s.fileBrowser.CloseAll()
+
+	// Event streams never go idle, so end them or Shutdown waits out ctx
+	s.closeOnce.Do(func() { close(s.closing) })
+
 	if s.httpServer != nil {
-		log.Println("Gracefully shutting down web server")
+		logger.Info("Gracefully shutting down web server")
 		return s.httpServer.Shutdown(ctx)
 	}
 	return nil
 }
 
+// clearWriteDeadline exempts a streaming or download response from
+// server.write_timeout, which would otherwise cut it off
+func clearWriteDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Warn("Failed to clear write deadline", "err", err)
+	}
+}
+
+// basicAuth lets viewers read and restricts changes to admins
 func (s *Server) basicAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return s.requireAuth(handler, config.RoleViewer, config.RoleAdmin)
+}
+
+// operatorAuth lets viewers read and operators trigger routine jobs
+func (s *Server) operatorAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return s.requireAuth(handler, config.RoleViewer, config.RoleOperator)
+}
+
+// adminAuth restricts a handler to admins, for reads that expose data
+func (s *Server) adminAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return s.requireAuth(handler, config.RoleAdmin, config.RoleAdmin)
+}
+
+// requesterAuth allows every signed-in user, including restore requesters
+func (s *Server) requesterAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return s.requireAuth(handler, config.RoleRequester, config.RoleRequester)
+}
+
+// authenticate checks the session cookie, then an API token, falling back to
+// basic auth, reporting the user and their role
+func (s *Server) authenticate(r *http.Request) (user string, role string, ok bool) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if sess, found := s.sessions.lookup(cookie.Value, time.Now()); found {
+			return sess.user, sess.role, true
+		}
+	}
+	if token := bearerToken(r); token != "" {
+		return s.checkToken(token)
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return "", "", false
+	}
+	role, ok = s.checkCredentials(user, pass)
+	return user, role, ok
+}
+
+// requireAuth needs readRole for GET and HEAD and writeRole for anything else
+func (s *Server) requireAuth(handler http.HandlerFunc, readRole, writeRole string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		user, pass, ok := r.BasicAuth()
-		if !ok || user != "admin" || pass != s.config.GetAdminPassword() {
+		user, role, ok := s.authenticate(r)
+		if !ok {
+			if user != "" {
+				audit.Record(audit.Event{Actor: user, Action: "login_failed", Remote: r.RemoteAddr, Outcome: "denied", Status: http.StatusUnauthorized})
+			} else if wantsLoginPage(r) {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
 			w.Header().Set("WWW-Authenticate", `Basic realm="ZFSRabbit"`)
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Unauthorized"))
 			return
 		}
-		handler(w, r)
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead
+		required := writeRole
+		if read {
+			required = readRole
+		}
+		if !config.RoleAllows(role, required) {
+			audit.Record(audit.Event{Actor: user, Action: r.Method + " " + r.URL.Path, Remote: r.RemoteAddr, Outcome: "denied", Status: http.StatusForbidden})
+			if role == config.RoleRequester {
+				http.Error(w, "Forbidden: restore requesters can only use /request", http.StatusForbidden)
+			} else {
+				http.Error(w, fmt.Sprintf("Forbidden: requires the %s role", required), http.StatusForbidden)
+			}
+			return
+		}
+
+		// Reads aren't audited; anything that can change state is
+		if read {
+			handler(w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r)
+
+		outcome := "success"
+		if recorder.status >= 400 {
+			outcome = "failure"
+		}
+		audit.Record(audit.Event{
+			Actor:   user,
+			Action:  r.Method + " " + r.URL.Path,
+			Remote:  r.RemoteAddr,
+			Outcome: outcome,
+			Status:  recorder.status,
+		})
 	}
 }
 
+// statusRecorder captures the status code a handler responds with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	http.ServeFile(w, r, "web/templates/dashboard.html")
 }
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.StatusReport())
+}
+
+// StatusReport builds the status document served by /api/status and written
+// by the status file exporter
+func (s *Server) StatusReport() map[string]interface{} {
 	status := s.monitor.GetSystemStatus()
 
 	healthy := true
@@ -126,11 +411,53 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"healthy":      healthy,
 		"pools":        status["pools"],
 		"disks":        status["disks"],
+		"checks":       status["checks"],
 		"pendingSends": s.scheduler.GetPendingSends(),
+		"targets":      s.scheduler.TargetStatus(),
+		"jobs":         s.scheduler.Jobs(),
+		"health":       s.scheduler.Health(),
+		"display":      displaySettings(),
+		"dry_run":      utils.DefaultRunner.DryRun(),
+	}
+	if drift := s.monitor.GetPropertyDrift(); len(drift) > 0 {
+		response["property_drift"] = drift
 	}
 
+	if s.updateChecker != nil {
+		response["update"] = s.updateChecker.Status()
+	}
+	if s.slaTracker != nil {
+		response["sla"] = s.slaTracker.Status()
+	}
+	if s.standby != nil {
+		if report := s.standby.Report(); report != nil {
+			response["standby"] = map[string]interface{}{"summary": report.Summary(), "ready": report.Ready}
+		}
+	}
+	if s.compactor != nil {
+		response["store"] = s.compactor.Status()
+	}
+
+	return response
+}
+
+// displaySettings tells the dashboard how to format temperatures and times
+func displaySettings() map[string]string {
+	settings := display.Settings()
+	return map[string]string{
+		"temperature_unit": settings.TemperatureUnit,
+		"date_format":      settings.DateFormat,
+		"timezone":         settings.Timezone,
+	}
+}
+
+// handleI18n serves the web UI's messages in the configured locale
+func (s *Server) handleI18n(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"locale":   i18n.Locale(),
+		"messages": i18n.Messages("ui."),
+	})
 }
 
 func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
@@ -144,7 +471,7 @@ func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
 	for i, snap := range snapshots {
 		response[i] = map[string]string{
 			"name":    snap.Name,
-			"created": snap.Created.Format("2006-01-02 15:04:05"),
+			"created": display.Time(snap.Created),
 			"used":    snap.Used,
 			"refer":   snap.Refer,
 		}
@@ -154,14 +481,73 @@ func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleDestroyedSnapshots returns the catalog of snapshots removed by retention
+func (s *Server) handleDestroyedSnapshots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.DestroyedSnapshots())
+}
+
+// handleHeldSnapshots lists snapshots that can't be destroyed because of a
+// zfs hold, including those held while they are being sent
+func (s *Server) handleHeldSnapshots(w http.ResponseWriter, r *http.Request) {
+	holds, err := s.scheduler.Holds()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holds)
+}
+
+// handleLag reports the age of the newest snapshot on each job's targets,
+// checking them now rather than waiting for the next scheduled check
+func (s *Server) handleLag(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.CheckLag())
+}
+
+// handleArchivedSnapshots lists recovery points moved off the backup pool by tiering
+func (s *Server) handleArchivedSnapshots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.Catalog().ArchivedSnapshots())
+}
+
+// handleCompactStores trims old history from the state stores now instead of
+// waiting for store.compact_cron
+func (s *Server) handleCompactStores(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.compactor == nil {
+		http.Error(w, "Store compaction is not available", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"removed": s.compactor.Run(),
+		"store":   s.compactor.Status(),
+	})
+}
+
+// handleSnapshotsNeedingVerification lists replicas flagged after scrubs found permanent errors
+func (s *Server) handleSnapshotsNeedingVerification(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduler.Catalog().NeedsVerification())
+}
+
 func (s *Server) handleTriggerSnapshot(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if err := s.scheduler.TriggerSnapshot(); err != nil {
-		if err.Error() == "snapshot operation already in progress" {
+	// ?job= snapshots one of the jobs entries instead of the zfs section
+	if err := s.scheduler.TriggerJob(r.URL.Query().Get("job")); err != nil {
+		if strings.HasPrefix(err.Error(), "unknown job") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if err.Error() == "snapshot operation already in progress" {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusConflict)
 			w.Write([]byte(`{"error": "snapshot operation already in progress"}`))
@@ -176,6 +562,45 @@ func (s *Server) handleTriggerSnapshot(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"success": true, "message": "Snapshot triggered"}`))
 }
 
+// handleBackfill lists the snapshots each target of ?job= is missing (GET)
+// or sends them in the background (POST)
+func (s *Server) handleBackfill(w http.ResponseWriter, r *http.Request) {
+	job := r.URL.Query().Get("job")
+
+	switch r.Method {
+	case http.MethodGet:
+		gaps, err := s.scheduler.BackfillGaps(job)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if strings.HasPrefix(err.Error(), "unknown job") {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(gaps)
+
+	case http.MethodPost:
+		if err := s.scheduler.TriggerBackfill(job); err != nil {
+			status := http.StatusInternalServerError
+			if strings.HasPrefix(err.Error(), "unknown job") {
+				status = http.StatusNotFound
+			} else if err.Error() == "snapshot operation already in progress" {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"success": true, "message": "Backfill started"}`))
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 func (s *Server) handleTriggerScrub(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -201,6 +626,10 @@ func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 		Snapshot      string `json:"snapshot"`
 		Dataset       string `json:"dataset"`
 		SourceDataset string `json:"source_dataset,omitempty"`
+		Mountpoint    string `json:"mountpoint,omitempty"` // Mount the restore here once received
+		Mount         bool   `json:"mount,omitempty"`      // Mount at the inherited mountpoint
+		ShareNFS      string `json:"sharenfs,omitempty"`   // Share the mounted restore over NFS with these options
+		ShareSMB      string `json:"sharesmb,omitempty"`   // Share the mounted restore over SMB with these options
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -213,15 +642,29 @@ func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var job *restore.RestoreJob
-	var err error
+	if req.Mountpoint != "" {
+		if err := validation.ValidateMountpoint(req.Mountpoint); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid mountpoint: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
 
-	if req.SourceDataset != "" {
-		job, err = s.restoreManager.StartRestoreFromDatasetWithTracking(req.SourceDataset, req.Snapshot, req.Dataset)
-	} else {
-		job, err = s.restoreManager.StartRestoreWithTracking(req.Snapshot, req.Dataset)
+	for _, share := range []string{req.ShareNFS, req.ShareSMB} {
+		if share == "" {
+			continue
+		}
+		if err := validation.ValidateShareOption(share); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid share option: %v", err), http.StatusBadRequest)
+			return
+		}
 	}
 
+	mount := restore.MountOptions{
+		Mountpoint: req.Mountpoint,
+		Mount:      req.Mount,
+		Shares:     zfs.Shares{NFS: req.ShareNFS, SMB: req.ShareSMB},
+	}
+	job, err := s.restoreManager.StartRestoreWithOptions(req.SourceDataset, req.Snapshot, req.Dataset, mount)
 	if err != nil {
 		if err.Error() == "restore operation already in progress" {
 			w.Header().Set("Content-Type", "application/json")
@@ -238,6 +681,58 @@ func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleRestoreJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.restoreJobsReport())
+}
+
+// handleRestoreEstimate sizes restoring the snapshot query parameter from
+// the dataset parameter (the default remote dataset if omitted) and
+// estimates how long it takes from past transfer rates
+func (s *Server) handleRestoreEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dataset, snapshot := r.URL.Query().Get("dataset"), r.URL.Query().Get("snapshot")
+	if err := validation.ValidateSnapshotName(snapshot); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dataset != "" {
+		if err := validation.ValidateDatasetName(dataset); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	estimate, err := s.restoreManager.EstimateRestore(dataset, snapshot)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restoreEstimateReport(estimate))
+}
+
+// restoreEstimateReport describes an estimate for the restore picker; the
+// duration is left out when there is no throughput history yet
+func restoreEstimateReport(estimate restore.Estimate) map[string]interface{} {
+	report := map[string]interface{}{
+		"bytes": estimate.Bytes,
+		"size":  display.Bytes(estimate.Bytes),
+		"tier":  estimate.Tier,
+	}
+	if estimate.Duration > 0 {
+		report["seconds"] = int64(estimate.Duration.Seconds())
+		report["estimate"] = "~" + display.Duration(estimate.Duration)
+	}
+	return report
+}
+
+// restoreJobsReport summarises restore jobs for the API and support bundles
+func (s *Server) restoreJobsReport() []map[string]interface{} {
 	jobs := s.restoreManager.ListJobs()
 
 	response := make([]map[string]interface{}, len(jobs))
@@ -248,17 +743,47 @@ func (s *Server) handleRestoreJobs(w http.ResponseWriter, r *http.Request) {
 			"dataset":    job.TargetDataset,
 			"status":     job.Status,
 			"progress":   job.Progress,
-			"start_time": job.StartTime.Format("2006-01-02 15:04:05"),
+			"start_time": display.Time(job.StartTime),
 		}
 
 		if job.EndTime != nil {
-			jobData["end_time"] = job.EndTime.Format("2006-01-02 15:04:05")
+			jobData["end_time"] = display.Time(*job.EndTime)
 		}
 
 		if job.Error != nil {
 			jobData["error"] = job.Error.Error()
 		}
 
+		if job.BytesTransferred > 0 {
+			jobData["bytes_transferred"] = job.BytesTransferred
+			jobData["transfer_rate"] = job.TransferRate
+		}
+		if job.TotalBytes > 0 {
+			jobData["total_bytes"] = job.TotalBytes
+		}
+		if job.ETA != "" {
+			jobData["eta"] = job.ETA
+		}
+		if job.RestoredDataset != "" {
+			jobData["restored_dataset"] = job.RestoredDataset
+		}
+		if job.Tier != "" {
+			jobData["tier"] = job.Tier
+		}
+		if job.Datasets > 0 {
+			jobData["datasets"] = job.Datasets
+			jobData["datasets_done"] = job.DatasetsDone
+		}
+		if job.MountedAt != "" {
+			jobData["mounted_at"] = job.MountedAt
+		}
+		if !job.Mount.Shares.IsZero() {
+			jobData["shares"] = job.Mount.Shares
+		}
+		if len(job.Hooks) > 0 {
+			jobData["hooks"] = job.Hooks
+		}
+
 		// Add safety warning fields for destructive operations
 		if job.RequiresConfirm {
 			jobData["requires_confirm"] = true
@@ -268,8 +793,7 @@ func (s *Server) handleRestoreJobs(w http.ResponseWriter, r *http.Request) {
 		response[i] = jobData
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return response
 }
 
 func (s *Server) handleRestoreConfirm(w http.ResponseWriter, r *http.Request) {
@@ -302,6 +826,43 @@ func (s *Server) handleRestoreConfirm(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleRestoreJobCancel cancels a restore job (DELETE /api/restore/jobs/<id>)
+func (s *Server) handleRestoreJobCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/restore/jobs/"))
+	if jobID == "" {
+		http.Error(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+
+	if _, exists := s.restoreManager.GetJob(jobID); !exists {
+		http.Error(w, fmt.Sprintf("Restore job %s not found", jobID), http.StatusNotFound)
+		return
+	}
+
+	user, _, _ := s.authenticate(r)
+	err := s.restoreManager.CancelJob(jobID)
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	audit.Record(audit.Event{Actor: user, Action: "restore_cancel " + jobID, Remote: r.RemoteAddr, Outcome: outcome})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to cancel restore: %v", err), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Cancelling restore job %s", jobID),
+	})
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	// Simple health check endpoint for monitoring systems
 	health := map[string]interface{}{
@@ -314,6 +875,65 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(health)
 }
 
+// handleOpenAPI serves the OpenAPI description of the REST API. It is
+// unauthenticated so tools can discover the API before they have credentials.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openapi.Spec)
+}
+
+// handleMetrics serves internal counters and histograms in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.scheduler.Health() // Refresh the health scores, whose lag grows between sends
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.Default.WriteText(w)
+}
+
+// handleInventory returns host, ZFS, pool, disk and NIC details for support cases
+func (s *Server) handleInventory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.config.Monitor.CheckTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inventory.Collect(ctx))
+}
+
+// handleSLA reports each dataset's SLA compliance
+func (s *Server) handleSLA(w http.ResponseWriter, r *http.Request) {
+	statuses := []sla.Status{}
+	if s.slaTracker != nil {
+		statuses = s.slaTracker.Status()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// handleFeatures lists every feature flag with its stage and effective value
+func (s *Server) handleFeatures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(features.List(s.config.Features))
+}
+
+// handleCapabilities tells clients and peers what this instance can do, so
+// they can avoid calling into features that are switched off here
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"version":  version.Get().Version,
+		"features": features.EnabledNames(s.config.Features),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleVersion reports the running build, to correlate behaviour changes
+// with deployments
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
+
 func (s *Server) handleRemoteDatasets(w http.ResponseWriter, r *http.Request) {
 	datasets, err := s.transport.ListAllRemoteDatasets()
 	if err != nil {
@@ -321,20 +941,21 @@ func (s *Server) handleRemoteDatasets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Categorize datasets
+	// Categorize datasets, as of the policy in effect
+	cfg := s.scheduler.Config()
 	response := map[string]interface{}{
-		"local_dataset":         s.config.ZFS.Dataset,
+		"local_dataset":         cfg.ZFS.Dataset,
 		"remote_datasets":       datasets,
 		"available_for_restore": []map[string]interface{}{},
 		"managed_by_this_instance": map[string]interface{}{
-			"dataset":   s.config.SSH.RemoteDataset,
-			"snapshots": datasets[s.config.SSH.RemoteDataset],
+			"dataset":   cfg.SSH.RemoteDataset,
+			"snapshots": datasets[cfg.SSH.RemoteDataset],
 		},
 	}
 
 	// Find datasets that exist remotely but not locally managed
 	for dataset, snapshots := range datasets {
-		if dataset != s.config.SSH.RemoteDataset && len(snapshots) > 0 {
+		if dataset != cfg.SSH.RemoteDataset && len(snapshots) > 0 {
 			response["available_for_restore"] = append(
 				response["available_for_restore"].([]map[string]interface{}),
 				map[string]interface{}{
@@ -382,7 +1003,7 @@ func (s *Server) handleRetryPendingSends(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Printf("Manual retry of pending snapshot sends requested")
+	logger.Info("Manual retry of pending snapshot sends requested")
 	
 	if err := s.scheduler.RetryPendingSends(); err != nil {
 		http.Error(w, fmt.Sprintf("Retry failed: %v", err), http.StatusInternalServerError)
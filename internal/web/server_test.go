@@ -1,21 +1,30 @@
 package web
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"zfsrabbit/internal/audit"
 	"zfsrabbit/internal/config"
 	"zfsrabbit/internal/monitor"
 	"zfsrabbit/internal/restore"
 	"zfsrabbit/internal/scheduler"
+	"zfsrabbit/internal/sla"
+	"zfsrabbit/internal/statuscheck"
 	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/version"
 	"zfsrabbit/internal/zfs"
 )
 
@@ -26,6 +35,10 @@ func (m *MockZFSExecutor) Command(name string, args ...string) *exec.Cmd {
 	return exec.Command("echo", "mock")
 }
 
+func (m *MockZFSExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return m.Command(name, args...)
+}
+
 func (m *MockZFSExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
 	// Return mock ZFS snapshot output
 	if strings.Contains(cmd.String(), "list -t snapshot") {
@@ -45,6 +58,10 @@ func (m *MockAlerter) SendAlert(subject, body string) error {
 	return nil
 }
 
+func (m *MockAlerter) SendSyncStart(snapshot, dataset string, estimatedBytes int64, eta time.Duration) error {
+	return nil
+}
+
 func (m *MockAlerter) SendSyncSuccess(snapshot, dataset string, duration time.Duration) error {
 	return nil
 }
@@ -131,6 +148,41 @@ func TestBasicAuth(t *testing.T) {
 	}
 }
 
+func TestBasicAuthAudit(t *testing.T) {
+	srv := createTestServer(t)
+
+	var events []audit.Event
+	audit.AddSink(func(event audit.Event) {
+		events = append(events, event)
+	})
+
+	handler := srv.basicAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	req.SetBasicAuth("admin", "testpass")
+	handler(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/api/trigger/scrub", nil)
+	req.SetBasicAuth("admin", "testpass")
+	handler(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/api/trigger/scrub", nil)
+	req.SetBasicAuth("admin", "wrong")
+	handler(httptest.NewRecorder(), req)
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events (GET not audited), got %d: %+v", len(events), events)
+	}
+	if events[0].Action != "POST /api/trigger/scrub" || events[0].Status != http.StatusAccepted || events[0].Outcome != "success" {
+		t.Errorf("Unexpected audit event: %+v", events[0])
+	}
+	if events[1].Action != "login_failed" {
+		t.Errorf("Expected failed login to be audited, got %+v", events[1])
+	}
+}
+
 func TestHandleStatus(t *testing.T) {
 	srv := createTestServer(t)
 
@@ -156,6 +208,130 @@ func TestHandleStatus(t *testing.T) {
 	}
 }
 
+func TestHandleMetrics(t *testing.T) {
+	srv := createTestServer(t)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.SetBasicAuth("admin", "testpass")
+	w := httptest.NewRecorder()
+
+	srv.handleMetrics(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "zfsrabbit_ssh_connect_attempts_total") {
+		t.Errorf("Expected transport metrics in output, got:\n%s", w.Body.String())
+	}
+}
+
+func TestHandleCapabilities(t *testing.T) {
+	srv := createTestServer(t)
+	srv.config.Features = map[string]bool{"resumable_sends": false, "object_store_target": true}
+
+	req := httptest.NewRequest("GET", "/api/capabilities", nil)
+	w := httptest.NewRecorder()
+
+	srv.handleCapabilities(w, req)
+
+	var response struct {
+		Version  string   `json:"version"`
+		Features []string `json:"features"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Version == "" {
+		t.Error("Expected version in capabilities")
+	}
+	if len(response.Features) != 1 || response.Features[0] != "object_store_target" {
+		t.Errorf("Expected only object_store_target enabled, got %v", response.Features)
+	}
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	srv := createTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	srv.handleOpenAPI(w, req)
+
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&spec); err != nil {
+		t.Fatalf("Failed to decode spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", spec.OpenAPI)
+	}
+	for _, path := range []string{"/api/status", "/api/snapshots", "/api/restore", "/api/restore/jobs", "/api/trigger/snapshot", "/api/remote/datasets", "/api/migration/start"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("Expected %s in the spec", path)
+		}
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	srv := createTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/version", nil)
+	w := httptest.NewRecorder()
+
+	srv.handleVersion(w, req)
+
+	var info version.Info
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Version == "" || info.GoVersion == "" {
+		t.Errorf("Expected version and Go version, got %+v", info)
+	}
+}
+
+func TestHandleSupportBundle(t *testing.T) {
+	srv := createTestServer(t)
+	srv.config.Monitor.CheckTimeout = 10 * time.Second
+	srv.config.Email.SMTPPassword = "hunter2"
+
+	req := httptest.NewRequest("GET", "/api/support/bundle", nil)
+	w := httptest.NewRecorder()
+
+	srv.handleSupportBundle(w, req)
+
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), `attachment; filename="zfsrabbit-support-`) {
+		t.Errorf("Expected attachment download, got %q", w.Header().Get("Content-Disposition"))
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected gzip body: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	found := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, _ := io.ReadAll(tr)
+		found[header.Name[strings.Index(header.Name, "/")+1:]] = string(data)
+	}
+
+	for _, name := range []string{"config.yaml", "logs/zfsrabbit.log", "jobs/restore.json", "catalog/destroyed.json", "zfs/zpool-status.txt"} {
+		if _, ok := found[name]; !ok {
+			t.Errorf("Expected %s in bundle", name)
+		}
+	}
+	if strings.Contains(found["config.yaml"], "hunter2") {
+		t.Error("Expected SMTP password to be redacted from bundled config")
+	}
+}
+
 func TestHandleSnapshots(t *testing.T) {
 	srv := createTestServer(t)
 
@@ -253,6 +429,177 @@ func TestHandleRestoreMissingFields(t *testing.T) {
 	}
 }
 
+func TestHandleRestoreInvalidMountpoint(t *testing.T) {
+	srv := createTestServer(t)
+
+	body, _ := json.Marshal(map[string]string{
+		"snapshot":   "autosnap_2026-01-01_02-00-00",
+		"dataset":    "tank/restored",
+		"mountpoint": "srv/restore",
+	})
+
+	req := httptest.NewRequest("POST", "/api/restore", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	srv.handleRestore(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "absolute path") {
+		t.Errorf("Expected 400 for a relative mountpoint, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleRestoreEstimate(t *testing.T) {
+	srv := createTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/restore/estimate?snapshot=bad;name", nil)
+	w := httptest.NewRecorder()
+	srv.handleRestoreEstimate(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid snapshot name, got %d", w.Code)
+	}
+
+	report := restoreEstimateReport(restore.Estimate{Bytes: 10 * 1024 * 1024 * 1024, Duration: 2*time.Hour + 15*time.Minute, Tier: "pool"})
+	if report["estimate"] != "~2h 15m" || report["seconds"] != int64(8100) || report["size"] != "10.0 GiB" {
+		t.Errorf("Unexpected estimate report: %v", report)
+	}
+	if _, ok := restoreEstimateReport(restore.Estimate{Bytes: 1024})["estimate"]; ok {
+		t.Error("Expected no duration without throughput history")
+	}
+}
+
+func TestHandleRestoreJobCancel(t *testing.T) {
+	srv := createTestServer(t)
+
+	req := httptest.NewRequest("DELETE", "/api/restore/jobs/restore_missing", nil)
+	w := httptest.NewRecorder()
+	srv.handleRestoreJobCancel(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/restore/jobs/restore_missing", nil)
+	w = httptest.NewRecorder()
+	srv.handleRestoreJobCancel(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", w.Code)
+	}
+}
+
+func TestHandleSharesRejectsBadInput(t *testing.T) {
+	srv := createTestServer(t)
+
+	tests := []struct {
+		name string
+		body map[string]string
+		want string
+	}{
+		{"invalid dataset", map[string]string{"dataset": "tank/../etc", "sharenfs": "on"}, "Invalid dataset"},
+		{"nothing to set", map[string]string{"dataset": "tank/restored"}, "is required"},
+		{"invalid option", map[string]string{"dataset": "tank/restored", "sharesmb": "on;reboot"}, "Invalid share option"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest("POST", "/api/shares", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			srv.handleShares(w, req)
+
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("Expected 400 containing %q, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleFileSessions(t *testing.T) {
+	srv := createTestServer(t)
+
+	req := httptest.NewRequest("POST", "/api/files/sessions", strings.NewReader(`{"snapshot":"bad snapshot!"}`))
+	w := httptest.NewRecorder()
+	srv.handleFileSessions(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid snapshot, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/api/files/sessions/browse_missing", "/api/files/sessions/browse_missing/download"} {
+		req = httptest.NewRequest("GET", path+"?path=/etc/passwd", nil)
+		w = httptest.NewRecorder()
+		srv.handleFileSession(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", path, w.Code)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/files/sessions", nil)
+	w = httptest.NewRecorder()
+	srv.handleFileSessions(w, req)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected no sessions, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRequesterAuth(t *testing.T) {
+	srv := createTestServer(t)
+	os.Setenv("REQUESTER_PASSWORD", "reqpass")
+	srv.config.Server.Requesters = []config.RequesterConfig{{User: "alice", PassEnv: "REQUESTER_PASSWORD"}}
+	srv.SetRestoreRequests(restore.NewRequests(srv.restoreManager, "", nil))
+
+	// Requesters can't use admin routes
+	req := httptest.NewRequest("POST", "/api/restore", nil)
+	req.SetBasicAuth("alice", "reqpass")
+	w := httptest.NewRecorder()
+	srv.basicAuth(srv.handleRestore)(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a requester on an admin route, got %d", w.Code)
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"snapshot": "autosnap_2026-01-01_02-00-00",
+		"dataset":  "tank/restored",
+		"reason":   "deleted a report",
+	})
+	req = httptest.NewRequest("POST", "/api/restore/requests", bytes.NewReader(body))
+	req.SetBasicAuth("alice", "reqpass")
+	w = httptest.NewRecorder()
+	srv.requesterAuth(srv.handleRestoreRequests)(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a request, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var created restore.Request
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.RequestedBy != "alice" || created.Status != restore.RequestPending {
+		t.Errorf("Unexpected request: %+v", created)
+	}
+
+	// Rejecting needs admin
+	req = httptest.NewRequest("POST", "/api/restore/requests/"+created.ID+"/reject", nil)
+	req.SetBasicAuth("alice", "reqpass")
+	w = httptest.NewRecorder()
+	srv.basicAuth(srv.handleRestoreRequestDecision)(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a requester rejecting, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/restore/requests/"+created.ID+"/reject", nil)
+	req.SetBasicAuth("admin", "testpass")
+	w = httptest.NewRecorder()
+	srv.basicAuth(srv.handleRestoreRequestDecision)(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 rejecting, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/restore/requests/request_missing/approve", nil)
+	req.SetBasicAuth("admin", "testpass")
+	w = httptest.NewRecorder()
+	srv.basicAuth(srv.handleRestoreRequestDecision)(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing request, got %d", w.Code)
+	}
+}
+
 func TestHandleRestoreJobs(t *testing.T) {
 	srv := createTestServer(t)
 
@@ -382,3 +729,234 @@ func TestJSONContentType(t *testing.T) {
 		}
 	}
 }
+
+func TestHandlePolicies(t *testing.T) {
+	srv := createTestServer(t)
+	srv.config.Schedule.SnapshotCron = "0 2 * * *"
+	srv.config.Schedule.ScrubCron = "0 3 * * 0"
+	srv.config.ZFS.KeepSnapshots = 30
+
+	req := httptest.NewRequest("GET", "/api/v1/policies", nil)
+	w := httptest.NewRecorder()
+	srv.handlePolicies(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var set map[string][]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(set["policies"]) != 1 || set["policies"][0]["dataset"] != "tank/test" {
+		t.Errorf("Unexpected policy set: %v", set)
+	}
+
+	body := `{"policies":[{"name":"nightly","dataset":"tank/test","recursive":true,
+		"schedule":{"snapshot":"0 4 * * *","scrub":"0 3 * * 0"},
+		"retention":{"keep_snapshots":14},
+		"target":{"host":"nonexistent.test.invalid","user":"root","dataset":"backup/test"}}]}`
+
+	for i, wantChanged := range []bool{true, false} {
+		req = httptest.NewRequest("PUT", "/api/v1/policies", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		srv.handlePolicies(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("PUT %d: expected 200, got %d: %s", i, w.Code, w.Body.String())
+		}
+
+		var result map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if result["changed"] != wantChanged {
+			t.Errorf("PUT %d: expected changed=%v, got %v", i, wantChanged, result["changed"])
+		}
+	}
+
+	if cfg := srv.scheduler.Config(); cfg.ZFS.KeepSnapshots != 14 || cfg.Schedule.SnapshotCron != "0 4 * * *" {
+		t.Errorf("Policy not reconciled into config: keep=%d cron=%s", cfg.ZFS.KeepSnapshots, cfg.Schedule.SnapshotCron)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/v1/policies", strings.NewReader(`{"policies":[]}`))
+	w = httptest.NewRecorder()
+	srv.handlePolicies(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for empty policy set, got %d", w.Code)
+	}
+}
+
+func TestHandlePoolPlans(t *testing.T) {
+	srv := createTestServer(t)
+
+	req := httptest.NewRequest("POST", "/api/pool/plans", strings.NewReader(`{"action":`))
+	w := httptest.NewRecorder()
+	srv.handlePoolPlans(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid JSON, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/pool/plans", strings.NewReader(`{"action":"create","pool":"raidz","layout":"mirror"}`))
+	w = httptest.NewRecorder()
+	srv.handlePoolPlans(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "pool name") {
+		t.Errorf("Expected 400 for a reserved pool name, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/pool/confirm/pool_missing", nil)
+	w = httptest.NewRecorder()
+	srv.handlePoolConfirm(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "not found") {
+		t.Errorf("Expected 400 for an unknown plan, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleCheck(t *testing.T) {
+	srv := createTestServer(t)
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{"/api/check/backup-freshness", http.StatusOK, `"state":"UNKNOWN"`},
+		{"/api/check/backup-freshness?format=text", http.StatusOK, "UNKNOWN - tank/test to primary has no successful send recorded"},
+		{"/api/check/backup-freshness?warning=2d", http.StatusBadRequest, "warning"},
+		{"/api/check/backup-freshness?warning=48h&critical=24h", http.StatusBadRequest, "cannot exceed"},
+		{"/api/check/pool-health?critical=120", http.StatusBadRequest, "critical"},
+		{"/api/check/disk-usage", http.StatusNotFound, "Unknown check"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		w := httptest.NewRecorder()
+		srv.handleCheck(w, req)
+		if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("%s: expected %d containing %q, got %d: %s", tt.url, tt.wantCode, tt.wantBody, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestBackupFreshness(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-2 * time.Hour)
+	late := now.Add(-30 * time.Hour)
+	restarted := now.Add(-50 * time.Hour)
+	jobs := []scheduler.JobStatus{
+		{Dataset: "tank/data", Targets: []scheduler.TargetStatus{{Name: "primary", LastSuccess: &recent}, {Name: "offsite", LastSuccess: &late}}},
+		{Dataset: "tank/home", Targets: []scheduler.TargetStatus{{Name: "primary"}}},
+	}
+	slaStatus := []sla.Status{{Dataset: "tank/home", LastSuccess: &restarted}}
+
+	result := backupFreshness(jobs, slaStatus, "", 26*time.Hour, 48*time.Hour, now)
+	if result.State != "CRITICAL" || !strings.Contains(result.Message, "tank/data to offsite last replicated 30h0m0s ago") ||
+		!strings.Contains(result.Message, "tank/home to primary last replicated 50h0m0s ago") {
+		t.Errorf("Expected the SLA record to make tank/home critical, got %s", result)
+	}
+	if len(result.Perfdata) != 3 || result.Perfdata[0] != "'tank/data:primary'=7200s;93600;172800;0" {
+		t.Errorf("Unexpected perfdata %v", result.Perfdata)
+	}
+
+	result = backupFreshness(jobs, slaStatus, "tank/data", 26*time.Hour, 48*time.Hour, now)
+	if result.Code != statuscheck.ExitWarning {
+		t.Errorf("Expected a warning for tank/data alone, got %s", result)
+	}
+
+	result = backupFreshness(jobs, nil, "tank/data", 36*time.Hour, 48*time.Hour, now)
+	if result.Code != statuscheck.ExitHealthy || result.Message != "2 targets replicated within 36h0m0s" {
+		t.Errorf("Expected OK with a 36h warning, got %s", result)
+	}
+
+	if result := backupFreshness(jobs, nil, "tank/missing", time.Hour, time.Hour, now); result.Code != statuscheck.ExitUnknown {
+		t.Errorf("Expected UNKNOWN for an unknown dataset, got %s", result)
+	}
+}
+
+func TestPoolHealth(t *testing.T) {
+	pools := []*zfs.PoolStatus{{Pool: "tank", State: "ONLINE"}, {Pool: "backup", State: "ONLINE"}}
+
+	result := poolHealth(pools, map[string]int{"tank": 50, "backup": 85}, 80, 90)
+	if result.String() != "WARNING - backup is 85% full | 'tank'=50%;80;90;0;100 'backup'=85%;80;90;0;100" {
+		t.Errorf("Unexpected result %s", result)
+	}
+
+	pools[0].State = "DEGRADED"
+	pools[1].Errors = []string{"permanent errors in tank/data"}
+	result = poolHealth(pools, nil, 80, 90)
+	if result.Code != statuscheck.ExitUnhealthy || result.Message != "tank is DEGRADED, backup has 1 errors" {
+		t.Errorf("Expected data errors to be critical, got %s", result)
+	}
+
+	if result := poolHealth(nil, nil, 80, 90); result.Code != statuscheck.ExitUnknown {
+		t.Errorf("Expected UNKNOWN without pools, got %s", result)
+	}
+}
+
+func TestHandleHistoryAndSchedule(t *testing.T) {
+	srv := createTestServer(t)
+	srv.config.Schedule.SnapshotCron = "0 2 * * *"
+
+	for _, url := range []string{"/api/history?from=yesterday", "/api/schedule?from=2026-02-01&to=2026-01-01", "/api/history?from=2026-01-01&to=2026-06-01"} {
+		w := httptest.NewRecorder()
+		srv.handleHistory(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.handleHistory(w, httptest.NewRequest("GET", "/api/history", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected an empty history, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleSchedule(w, httptest.NewRequest("GET", "/api/schedule", nil))
+	var events []calendarEvent
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+		t.Fatalf("Expected JSON events, got %s", w.Body.String())
+	}
+	if len(events) < 13 || len(events) > 14 || events[0].Kind != "snapshot" || events[0].Status != "scheduled" ||
+		events[0].Start.Hour() != 2 || events[0].Title != "Snapshot tank/test (default)" {
+		t.Errorf("Expected a nightly snapshot for the next 14 days, got %d: %+v", len(events), events)
+	}
+
+	// Past ranges have nothing scheduled
+	w = httptest.NewRecorder()
+	srv.handleSchedule(w, httptest.NewRequest("GET", "/api/schedule?from=2020-01-01&to=2020-02-01", nil))
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected nothing scheduled in the past, got %s", w.Body.String())
+	}
+}
+
+func TestMigrationSessionSurvivesRestart(t *testing.T) {
+	defer func() { activeMigrationSession = nil }()
+	path := filepath.Join(t.TempDir(), "migration_session.json")
+
+	activeMigrationSession = &MigrationSession{ID: "migration_1", SourceDataset: "tank/app", Status: "active", CurrentStep: 2, SharesDisabled: true}
+	(&MigrationWizard{path: path}).save()
+	activeMigrationSession = nil
+
+	wizard := &MigrationWizard{}
+	wizard.open(path)
+	if activeMigrationSession == nil || activeMigrationSession.ID != "migration_1" {
+		t.Fatalf("Expected the session to be loaded, got %+v", activeMigrationSession)
+	}
+	if activeMigrationSession.Status != "interrupted" || activeMigrationSession.Error == "" || !activeMigrationSession.SharesDisabled {
+		t.Errorf("Expected an interrupted session that still knows its shares are off, got %+v", activeMigrationSession)
+	}
+
+	// An interrupted session doesn't block a new one, which is saved too
+	body := strings.NewReader(`{"sourceDataset": "tank/app", "targetHost": "new", "targetDataset": "tank/app"}`)
+	w := httptest.NewRecorder()
+	wizard.StartMigrationHandler(w, httptest.NewRequest(http.MethodPost, "/api/migration/start", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a new migration to start, got %d: %s", w.Code, w.Body.String())
+	}
+
+	activeMigrationSession = nil
+	(&MigrationWizard{}).open(path)
+	if activeMigrationSession == nil || activeMigrationSession.ID == "migration_1" || activeMigrationSession.Status != "interrupted" {
+		t.Errorf("Expected the new session to be saved and interrupted by the restart, got %+v", activeMigrationSession)
+	}
+}
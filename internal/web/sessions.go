@@ -0,0 +1,205 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/config"
+)
+
+const (
+	sessionCookie     = "zfsrabbit_session"
+	defaultSessionTTL = 12 * time.Hour
+)
+
+// session is a signed-in web user
+type session struct {
+	user    string
+	role    string
+	expires time.Time
+}
+
+// sessionStore keeps web logins in memory; a restart signs everyone out
+type sessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]session
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	return &sessionStore{ttl: ttl, sessions: make(map[string]session)}
+}
+
+// create starts a session and returns its token
+func (s *sessionStore) create(user, role string, now time.Time) (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+	expires := now.Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired sessions so abandoned logins don't accumulate
+	for key, existing := range s.sessions {
+		if now.After(existing.expires) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[token] = session{user: user, role: role, expires: expires}
+	return token, expires, nil
+}
+
+// lookup returns the live session for token
+func (s *sessionStore) lookup(token string, now time.Time) (session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok {
+		return session{}, false
+	}
+	if now.After(sess.expires) {
+		delete(s.sessions, token)
+		return session{}, false
+	}
+	return sess, true
+}
+
+func (s *sessionStore) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// checkCredentials verifies a user's password against the admin password,
+// configured accounts and restore requesters, returning their role
+func (s *Server) checkCredentials(user, pass string) (role string, ok bool) {
+	if user == "" || pass == "" {
+		return "", false
+	}
+	if user == "admin" {
+		return config.RoleAdmin, secureEqual(pass, s.config.GetAdminPassword())
+	}
+	if account := s.config.GetUser(user); account != nil {
+		if bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(pass)) != nil {
+			return "", false
+		}
+		return account.Role, true
+	}
+	if expected := s.config.GetRequesterPassword(user); expected != "" {
+		return config.RoleRequester, secureEqual(pass, expected)
+	}
+	return "", false
+}
+
+func secureEqual(given, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// handleLogin serves the login page and signs users in from its form
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "text/html")
+		http.ServeFile(w, r, "web/templates/login.html")
+	case http.MethodPost:
+		user := r.FormValue("user")
+		role, ok := s.checkCredentials(user, r.FormValue("password"))
+		if !ok {
+			audit.Record(audit.Event{Actor: user, Action: "login_failed", Remote: r.RemoteAddr, Outcome: "denied", Status: http.StatusUnauthorized})
+			http.Redirect(w, r, "/login?error=1&next="+url.QueryEscape(r.FormValue("next")), http.StatusSeeOther)
+			return
+		}
+
+		token, expires, err := s.sessions.create(user, role, time.Now())
+		if err != nil {
+			http.Error(w, "Failed to start session", http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token,
+			Path:     "/",
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   s.config.Server.TLS.Enabled || r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		audit.Record(audit.Event{Actor: user, Action: "login", Remote: r.RemoteAddr, Outcome: "success", Status: http.StatusSeeOther})
+
+		http.Redirect(w, r, loginDestination(r.FormValue("next"), role), http.StatusSeeOther)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLogout ends the caller's session
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if sess, ok := s.sessions.lookup(cookie.Value, time.Now()); ok {
+			audit.Record(audit.Event{Actor: sess.user, Action: "logout", Remote: r.RemoteAddr, Outcome: "success", Status: http.StatusSeeOther})
+		}
+		s.sessions.remove(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// loginDestination returns where to send a user after login, only following
+// local paths so the login form can't be used as an open redirect
+func loginDestination(next, role string) string {
+	if localPath(next) && !strings.HasPrefix(next, "/login") {
+		return next
+	}
+	if role == config.RoleRequester {
+		return "/request"
+	}
+	return "/"
+}
+
+// localPath reports whether next is a path on this server. Browsers treat
+// backslashes as slashes, so "/\evil.com" is rejected like "//evil.com", also
+// when percent-encoded.
+func localPath(next string) bool {
+	unescaped, err := url.PathUnescape(next)
+	if err != nil {
+		return false
+	}
+	for _, path := range []string{next, unescaped} {
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+			return false
+		}
+		for _, c := range path {
+			if c < 0x20 || c == 0x7f {
+				return false
+			}
+		}
+	}
+	u, err := url.Parse(next)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// wantsLoginPage reports whether an unauthenticated request is a browser
+// navigating to a page rather than an API client
+func wantsLoginPage(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
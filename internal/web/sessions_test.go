@@ -0,0 +1,191 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"zfsrabbit/internal/config"
+)
+
+func login(t *testing.T, srv *Server, user, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"user": {user}, "password": {password}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	srv.handleLogin(w, req)
+	return w
+}
+
+func TestSessionLogin(t *testing.T) {
+	srv := createTestServer(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.config.Server.Users = []config.UserConfig{
+		{User: "bob", PasswordHash: string(hash), Role: config.RoleAdmin},
+		{User: "carol", PasswordHash: string(hash), Role: config.RoleRequester},
+	}
+
+	handler := srv.basicAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	if w := login(t, srv, "bob", "wrong"); !strings.HasPrefix(w.Header().Get("Location"), "/login?error=1") {
+		t.Errorf("Expected failed login to return to the login page, got %q", w.Header().Get("Location"))
+	}
+
+	w := login(t, srv, "bob", "s3cret")
+	if w.Header().Get("Location") != "/" {
+		t.Errorf("Expected admin to land on the dashboard, got %q", w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("Expected an HttpOnly session cookie, got %+v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	req.AddCookie(cookies[0])
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected session to authenticate, got %d", rec.Code)
+	}
+
+	// Requesters sign in to /request and can't reach admin pages
+	w = login(t, srv, "carol", "s3cret")
+	if w.Header().Get("Location") != "/request" {
+		t.Errorf("Expected requester to land on /request, got %q", w.Header().Get("Location"))
+	}
+	req = httptest.NewRequest("GET", "/api/status", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected requester session to be forbidden, got %d", rec.Code)
+	}
+
+	// Logging out ends the session
+	req = httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(cookies[0])
+	srv.handleLogout(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", "/api/status", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected logged out session to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestUnauthenticatedBrowserRedirectsToLogin(t *testing.T) {
+	srv := createTestServer(t)
+	handler := srv.basicAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/calendar", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?next=%2Fcalendar" {
+		t.Errorf("Expected redirect to login, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	store := newSessionStore(time.Hour)
+	now := time.Now()
+
+	token, _, err := store.create("bob", config.RoleAdmin, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.lookup(token, now.Add(59*time.Minute)); !ok {
+		t.Error("Expected session to be valid before expiry")
+	}
+	if _, ok := store.lookup(token, now.Add(61*time.Minute)); ok {
+		t.Error("Expected session to expire")
+	}
+}
+
+func TestLoginDestination(t *testing.T) {
+	tests := []struct {
+		next string
+		role string
+		want string
+	}{
+		{"", config.RoleAdmin, "/"},
+		{"", config.RoleViewer, "/"},
+		{"", config.RoleRequester, "/request"},
+		{"/calendar", config.RoleAdmin, "/calendar"},
+		{"//evil.example.com", config.RoleAdmin, "/"},
+		{"https://evil.example.com", config.RoleRequester, "/request"},
+		{"/login?error=1", config.RoleAdmin, "/"},
+		{"/\\evil.example.com", config.RoleAdmin, "/"},
+		{"/%5Cevil.example.com", config.RoleAdmin, "/"},
+		{"/%5cevil.example.com", config.RoleRequester, "/request"},
+		{"/%2F%2Fevil.example.com", config.RoleAdmin, "/"},
+		{"/\tevil.example.com", config.RoleAdmin, "/"},
+		{"/%0D%0ALocation:%20https://evil.example.com", config.RoleAdmin, "/"},
+		{"/snapshots?dataset=tank%2Fdata", config.RoleAdmin, "/snapshots?dataset=tank%2Fdata"},
+	}
+	for _, tt := range tests {
+		if got := loginDestination(tt.next, tt.role); got != tt.want {
+			t.Errorf("loginDestination(%q, %q) = %q, want %q", tt.next, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestRoleAccess(t *testing.T) {
+	srv := createTestServer(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.config.Server.Users = []config.UserConfig{
+		{User: "vera", PasswordHash: string(hash), Role: config.RoleViewer},
+		{User: "otto", PasswordHash: string(hash), Role: config.RoleOperator},
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		user    string
+		want    int
+	}{
+		{"viewer reads status", srv.basicAuth(ok), "GET", "vera", http.StatusOK},
+		{"viewer can't trigger", srv.operatorAuth(ok), "POST", "vera", http.StatusForbidden},
+		{"viewer can't read files", srv.adminAuth(ok), "GET", "vera", http.StatusForbidden},
+		{"operator triggers", srv.operatorAuth(ok), "POST", "otto", http.StatusOK},
+		{"operator can't restore", srv.basicAuth(ok), "POST", "otto", http.StatusForbidden},
+		{"operator requests restores", srv.requesterAuth(ok), "POST", "otto", http.StatusOK},
+		{"admin restores", srv.basicAuth(ok), "POST", "admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/test", nil)
+		if tt.user == "admin" {
+			req.SetBasicAuth("admin", "testpass")
+		} else {
+			req.SetBasicAuth(tt.user, "s3cret")
+		}
+		w := httptest.NewRecorder()
+		tt.handler(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"zfsrabbit/internal/audit"
+	"zfsrabbit/internal/validation"
+	"zfsrabbit/internal/zfs"
+)
+
+// handleShares reports (GET ?dataset=) or changes (POST) the NFS and SMB
+// shares of a local dataset, such as a restored or cloned one. Setting a
+// share to "off" stops sharing it.
+func (s *Server) handleShares(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		dataset := r.URL.Query().Get("dataset")
+		if err := validation.ValidateDatasetName(dataset); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid dataset: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		shares, err := zfs.GetShares(dataset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"dataset": dataset, "shares": shares})
+
+	case http.MethodPost:
+		var req struct {
+			Dataset string `json:"dataset"`
+			zfs.Shares
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidateDatasetName(req.Dataset); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid dataset: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Shares.IsZero() {
+			http.Error(w, "sharenfs or sharesmb is required", http.StatusBadRequest)
+			return
+		}
+		for _, share := range []string{req.NFS, req.SMB} {
+			if share == "" {
+				continue
+			}
+			if err := validation.ValidateShareOption(share); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid share option: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		user, _, _ := s.authenticate(r)
+		err := zfs.SetShares(req.Dataset, req.Shares)
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+		}
+		audit.Record(audit.Event{Actor: user, Action: "share " + req.Dataset, Remote: r.RemoteAddr, Outcome: outcome})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"dataset": req.Dataset, "shares": req.Shares})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// handleSilences lists the active alert silences or adds one
+func (s *Server) handleSilences(w http.ResponseWriter, r *http.Request) {
+	if s.silences == nil {
+		http.Error(w, "Alert silences are not available", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.silences.Active())
+
+	case http.MethodPost:
+		var req struct {
+			Scope    string `json:"scope"`
+			Target   string `json:"target,omitempty"`
+			Duration string `json:"duration"` // e.g. "2h" or "30m"
+			Reason   string `json:"reason,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			http.Error(w, "duration must be like 30m or 2h", http.StatusBadRequest)
+			return
+		}
+
+		user, _, _ := s.authenticate(r)
+		created, err := s.silences.Add(req.Scope, req.Target, duration, req.Reason, user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSilenceRemove ends a silence before it expires
+func (s *Server) handleSilenceRemove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.silences == nil {
+		http.Error(w, "Alert silences are not available", http.StatusNotFound)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/silences/")
+	if !s.silences.Remove(id) {
+		http.Error(w, "No active silence "+id, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
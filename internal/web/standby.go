@@ -0,0 +1,35 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleStandby returns the latest standby readiness report; POST runs the
+// checks now and returns the new report
+func (s *Server) handleStandby(w http.ResponseWriter, r *http.Request) {
+	if s.standby == nil || !s.config.Standby.Enabled {
+		http.Error(w, "Standby mode is not enabled", http.StatusNotFound)
+		return
+	}
+
+	report := s.standby.Report()
+	switch r.Method {
+	case http.MethodGet:
+		if report == nil {
+			http.Error(w, "No readiness check has run yet", http.StatusNotFound)
+			return
+		}
+	case http.MethodPost:
+		report = s.standby.Check()
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"summary": report.Summary(),
+		"report":  report,
+	})
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"zfsrabbit/internal/inventory"
+	"zfsrabbit/internal/support"
+	"zfsrabbit/internal/version"
+	"zfsrabbit/internal/zfs"
+)
+
+// handleSupportBundle streams a tar.gz of everything usually asked for when
+// debugging replication: sanitized config, recent logs, job history, pool
+// state and the snapshot catalog
+func (s *Server) handleSupportBundle(w http.ResponseWriter, r *http.Request) {
+	// Collecting pool state can take up to monitor.check_timeout before writing starts
+	clearWriteDeadline(w)
+	ctx, cancel := context.WithTimeout(r.Context(), s.config.Monitor.CheckTimeout)
+	defer cancel()
+
+	host, _ := os.Hostname()
+	prefix := fmt.Sprintf("zfsrabbit-support-%s-%s", host, time.Now().Format("20060102-150405"))
+
+	files := s.supportFiles(ctx)
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", prefix+".tar.gz"))
+	if err := support.Write(w, prefix, files); err != nil {
+		// Headers are already sent, so the client just sees a truncated archive
+		logger.Error("Failed to write support bundle", "err", err)
+	}
+}
+
+func (s *Server) supportFiles(ctx context.Context) []support.File {
+	files := []support.File{
+		support.JSONFile("version.json", version.Get()),
+		support.ConfigFile("config.yaml", s.config),
+		support.LogsFile("logs/zfsrabbit.log"),
+		support.JSONFile("status.json", s.StatusReport()),
+		support.JSONFile("inventory.json", inventory.Collect(ctx)),
+		support.JSONFile("jobs/restore.json", s.restoreJobsReport()),
+		support.JSONFile("jobs/resend.json", s.scheduler.ResendJobs()),
+		support.JSONFile("jobs/drill.json", s.drillManager.ListReports()),
+		support.JSONFile("catalog/destroyed.json", s.scheduler.DestroyedSnapshots()),
+		support.JSONFile("catalog/needs_verification.json", s.scheduler.Catalog().NeedsVerification()),
+		support.CommandFile(ctx, "zfs/zpool-status.txt", "zpool", "status", "-v"),
+		support.CommandFile(ctx, "zfs/zpool-iostat.txt", "zpool", "iostat", "-v"),
+		support.CommandFile(ctx, "zfs/zpool-list.txt", "zpool", "list", "-v"),
+		support.CommandFile(ctx, "zfs/zfs-list.txt", "zfs", "list", "-t", "all", "-o", "name,used,avail,refer,mountpoint", "-r", s.scheduler.Config().ZFS.Dataset),
+	}
+
+	if activeMigrationSession != nil {
+		files = append(files, support.JSONFile("jobs/migration.json", activeMigrationSession))
+	}
+
+	// Pool history shows recent manual zfs and zpool changes
+	if pools, err := zfs.GetPoolsContext(ctx); err == nil {
+		for _, pool := range pools {
+			files = append(files, support.CommandFile(ctx, "zfs/zpool-history-"+pool+".txt", "zpool", "history", pool))
+		}
+	}
+
+	return files
+}
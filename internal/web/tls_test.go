@@ -0,0 +1,116 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestCertificatePathsGeneratesSelfSigned(t *testing.T) {
+	cfg := &config.ServerConfig{
+		StateDir: t.TempDir(),
+		TLS:      config.TLSConfig{Enabled: true, SelfSigned: true},
+	}
+
+	certFile, keyFile, err := certificatePaths(cfg)
+	if err != nil {
+		t.Fatalf("certificatePaths failed: %v", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Generated pair does not load: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse generated certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Errorf("Expected certificate to cover localhost: %v", err)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key mode 0600, got %o", info.Mode().Perm())
+	}
+
+	// A valid certificate is reused rather than regenerated
+	before, _ := os.ReadFile(certFile)
+	if _, _, err := certificatePaths(cfg); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(certFile)
+	if string(before) != string(after) {
+		t.Error("Expected existing certificate to be reused")
+	}
+}
+
+func TestCertificatePathsRegeneratesExpired(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.ServerConfig{
+		StateDir: dir,
+		TLS:      config.TLSConfig{Enabled: true, SelfSigned: true},
+	}
+
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+	if err := generateSelfSigned(certFile, keyFile, time.Now().Add(-2*selfSignedValidity)); err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := os.ReadFile(certFile)
+
+	if _, _, err := certificatePaths(cfg); err != nil {
+		t.Fatal(err)
+	}
+	current, _ := os.ReadFile(certFile)
+	if string(expired) == string(current) {
+		t.Error("Expected expired certificate to be regenerated")
+	}
+}
+
+func TestCertificatePathsPrefersConfigured(t *testing.T) {
+	cfg := &config.ServerConfig{
+		StateDir: t.TempDir(),
+		TLS:      config.TLSConfig{Enabled: true, CertFile: "/etc/ssl/zfsrabbit.crt", KeyFile: "/etc/ssl/zfsrabbit.key", SelfSigned: true},
+	}
+
+	certFile, keyFile, err := certificatePaths(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certFile != "/etc/ssl/zfsrabbit.crt" || keyFile != "/etc/ssl/zfsrabbit.key" {
+		t.Errorf("Expected configured paths, got %s %s", certFile, keyFile)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.StateDir, "tls")); !os.IsNotExist(err) {
+		t.Error("Expected no certificate to be generated")
+	}
+}
+
+func TestNewTLSConfigRejectsLegacyVersions(t *testing.T) {
+	if cfg := newTLSConfig(); cfg.MinVersion < tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 minimum, got %x", cfg.MinVersion)
+	}
+}
+
+func TestTLSConfigLoadsCertificate(t *testing.T) {
+	cfg := &config.ServerConfig{
+		StateDir: t.TempDir(),
+		TLS:      config.TLSConfig{Enabled: true, SelfSigned: true},
+	}
+
+	tlsConfig, err := TLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("TLSConfig failed: %v", err)
+	}
+	if len(tlsConfig.Certificates) != 1 || tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected the certificate with the web server's settings, got %d certificates and minimum version %x", len(tlsConfig.Certificates), tlsConfig.MinVersion)
+	}
+}
@@ -0,0 +1,321 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/utils"
+)
+
+// tokenPrefix marks zfsrabbit API tokens so they are easy to spot in scripts
+// and secret scanners
+const tokenPrefix = "zfsr_"
+
+// apiToken is a bearer token for scripts. Only a hash of the token is kept.
+type apiToken struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	User    string     `json:"user"`
+	Role    string     `json:"role"` // Never more than the user's own role when used
+	Hash    string     `json:"hash"` // SHA-256 of the token
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// tokenStore keeps API tokens, persisted to the state directory when one is set
+type tokenStore struct {
+	mu     sync.Mutex
+	path   string
+	tokens []apiToken
+}
+
+func newTokenStore(path string) *tokenStore {
+	s := &tokenStore{path: path}
+	if path != "" {
+		if err := utils.ReadJSONFile(path, &s.tokens); err != nil {
+			logger.Error("Failed to load API tokens", "path", path, "err", err)
+		}
+	}
+	return s
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// create issues a token and returns it along with the secret, which is
+// shown to the caller once and never again
+func (s *tokenStore) create(name, user, role string, expires *time.Time, now time.Time) (apiToken, string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return apiToken{}, "", err
+	}
+	id, err := randomHex(6)
+	if err != nil {
+		return apiToken{}, "", err
+	}
+	secret = tokenPrefix + secret
+
+	token := apiToken{
+		ID:      "tok_" + id,
+		Name:    name,
+		User:    user,
+		Role:    role,
+		Hash:    hashToken(secret),
+		Created: now,
+		Expires: expires,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, token)
+	s.saveLocked()
+	return token, secret, nil
+}
+
+// lookup returns the unexpired token matching secret
+func (s *tokenStore) lookup(secret string, now time.Time) (apiToken, bool) {
+	hash := hashToken(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if token.Expires != nil && now.After(*token.Expires) {
+			return apiToken{}, false
+		}
+		return token, true
+	}
+	return apiToken{}, false
+}
+
+// list returns a user's tokens, or everyone's if user is ""
+func (s *tokenStore) list(user string) []apiToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []apiToken{}
+	for _, token := range s.tokens {
+		if user == "" || token.User == user {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// revoke deletes a token, only if it belongs to user unless user is ""
+func (s *tokenStore) revoke(id, user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, token := range s.tokens {
+		if token.ID != id {
+			continue
+		}
+		if user != "" && token.User != user {
+			break
+		}
+		s.tokens = append(s.tokens[:i], s.tokens[i+1:]...)
+		s.saveLocked()
+		return nil
+	}
+	return fmt.Errorf("token %s not found", id)
+}
+
+func (s *tokenStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	if err := utils.WriteJSONAtomic(s.path, s.tokens, 0600); err != nil {
+		logger.Error("Failed to save API tokens", "path", s.path, "err", err)
+	}
+}
+
+// bearerToken returns the token from an Authorization: Bearer header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// userRole returns the role a user currently has, or "" if they no longer exist
+func (s *Server) userRole(user string) string {
+	if user == "admin" {
+		return config.RoleAdmin
+	}
+	if account := s.config.GetUser(user); account != nil {
+		return account.Role
+	}
+	if s.config.GetRequesterPassword(user) != "" {
+		return config.RoleRequester
+	}
+	return ""
+}
+
+// checkToken authenticates a bearer token as its user, with the lesser of
+// the token's role and the user's current one
+func (s *Server) checkToken(secret string) (user, role string, ok bool) {
+	token, found := s.tokens.lookup(secret, time.Now())
+	if !found {
+		return "", "", false
+	}
+	role = s.userRole(token.User)
+	if role == "" {
+		return "", "", false
+	}
+	if config.RoleAllows(role, token.Role) {
+		role = token.Role
+	}
+	return token.User, role, true
+}
+
+// CheckAPICredentials authenticates a bearer token, or without one a user
+// and password, for the gRPC API. It reports the user and their role.
+func (s *Server) CheckAPICredentials(token, user, pass string) (string, string, bool) {
+	if token != "" {
+		return s.checkToken(token)
+	}
+	role, ok := s.checkCredentials(user, pass)
+	return user, role, ok
+}
+
+// tokenInfo is a token as reported by the API, without its hash
+type tokenInfo struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	User    string     `json:"user"`
+	Role    string     `json:"role"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Token   string     `json:"token,omitempty"` // Only when created
+}
+
+func newTokenInfo(token apiToken) tokenInfo {
+	return tokenInfo{ID: token.ID, Name: token.Name, User: token.User, Role: token.Role, Created: token.Created, Expires: token.Expires}
+}
+
+// handleTokens lists the caller's API tokens (every token for admins) or
+// creates one
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	if bearerToken(r) != "" {
+		http.Error(w, "Forbidden: API tokens can't manage tokens", http.StatusForbidden)
+		return
+	}
+	user, role, _ := s.authenticate(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		owner := user
+		if role == config.RoleAdmin {
+			owner = ""
+		}
+		tokens := []tokenInfo{}
+		for _, token := range s.tokens.list(owner) {
+			tokens = append(tokens, newTokenInfo(token))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+
+	case http.MethodPost:
+		var req struct {
+			Name      string `json:"name"`
+			Role      string `json:"role"`       // Defaults to the caller's role
+			ExpiresIn string `json:"expires_in"` // Go duration such as 720h; never if empty
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if req.Role == "" {
+			req.Role = role
+		}
+		if !config.ValidRole(req.Role) {
+			http.Error(w, "role must be requester, viewer, operator or admin", http.StatusBadRequest)
+			return
+		}
+		if !config.RoleAllows(role, req.Role) {
+			http.Error(w, fmt.Sprintf("Forbidden: a %s can't create %s tokens", role, req.Role), http.StatusForbidden)
+			return
+		}
+
+		now := time.Now()
+		var expires *time.Time
+		if req.ExpiresIn != "" {
+			ttl, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || ttl <= 0 {
+				http.Error(w, "expires_in must be a positive duration such as 720h", http.StatusBadRequest)
+				return
+			}
+			at := now.Add(ttl)
+			expires = &at
+		}
+
+		token, secret, err := s.tokens.create(req.Name, user, req.Role, expires, now)
+		if err != nil {
+			http.Error(w, "Failed to create token", http.StatusInternalServerError)
+			return
+		}
+		logger.Info("API token created", "token", token.ID, "name", token.Name, "user", user, "role", token.Role)
+
+		info := newTokenInfo(token)
+		info.Token = secret
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(info)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTokenRevoke deletes /api/tokens/<id>; users can revoke their own
+// tokens and admins anyone's
+func (s *Server) handleTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if bearerToken(r) != "" {
+		http.Error(w, "Forbidden: API tokens can't manage tokens", http.StatusForbidden)
+		return
+	}
+	user, role, _ := s.authenticate(r)
+
+	owner := user
+	if role == config.RoleAdmin {
+		owner = ""
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/tokens/")
+	if err := s.tokens.revoke(id, owner); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	logger.Info("API token revoked", "token", id, "user", user)
+	w.WriteHeader(http.StatusNoContent)
+}
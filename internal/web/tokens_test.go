@@ -0,0 +1,97 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zfsrabbit/internal/config"
+)
+
+func TestAPITokens(t *testing.T) {
+	srv := createTestServer(t)
+	srv.tokens = newTokenStore(filepath.Join(t.TempDir(), "api_tokens.json"))
+
+	ok := srv.basicAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	call := func(handler http.HandlerFunc, method, token string) int {
+		req := httptest.NewRequest(method, "/api/status", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w.Code
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": "nagios", "role": config.RoleViewer})
+	req := httptest.NewRequest("POST", "/api/tokens", bytes.NewReader(body))
+	req.SetBasicAuth("admin", "testpass")
+	w := httptest.NewRecorder()
+	srv.requesterAuth(srv.handleTokens)(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a token, got %d: %s", w.Code, w.Body.String())
+	}
+	var created tokenInfo
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Token == "" || created.Role != config.RoleViewer || created.User != "admin" {
+		t.Fatalf("Unexpected token: %+v", created)
+	}
+
+	// The token reads as a viewer but can't change anything
+	if code := call(ok, "GET", created.Token); code != http.StatusOK {
+		t.Errorf("Expected token to authenticate a read, got %d", code)
+	}
+	if code := call(ok, "POST", created.Token); code != http.StatusForbidden {
+		t.Errorf("Expected viewer token to be refused a change, got %d", code)
+	}
+	if code := call(ok, "GET", "zfsr_wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected an unknown token to be rejected, got %d", code)
+	}
+	if code := call(srv.requesterAuth(srv.handleTokens), "GET", created.Token); code != http.StatusForbidden {
+		t.Errorf("Expected tokens not to manage tokens, got %d", code)
+	}
+
+	// Only the hash is stored, and tokens survive a restart
+	reloaded := newTokenStore(srv.tokens.path)
+	if tokens := reloaded.list(""); len(tokens) != 1 || tokens[0].Hash == created.Token {
+		t.Errorf("Expected one hashed token on disk, got %+v", tokens)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/tokens/"+created.ID, nil)
+	req.SetBasicAuth("admin", "testpass")
+	w = httptest.NewRecorder()
+	srv.requesterAuth(srv.handleTokenRevoke)(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 revoking, got %d", w.Code)
+	}
+	if code := call(ok, "GET", created.Token); code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked token to be rejected, got %d", code)
+	}
+}
+
+func TestAPITokenLimits(t *testing.T) {
+	srv := createTestServer(t)
+	now := time.Now()
+
+	// Expired tokens don't authenticate
+	expired := now.Add(-time.Minute)
+	_, secret, _ := srv.tokens.create("old", "admin", config.RoleAdmin, &expired, now.Add(-time.Hour))
+	if _, _, ok := srv.checkToken(secret); ok {
+		t.Error("Expected expired token to be rejected")
+	}
+
+	// A token never has more access than its user, and dies with them
+	srv.config.Server.Requesters = []config.RequesterConfig{{User: "alice", PassEnv: "ADMIN_PASSWORD"}}
+	_, secret, _ = srv.tokens.create("script", "alice", config.RoleAdmin, nil, now)
+	if _, role, ok := srv.checkToken(secret); !ok || role != config.RoleRequester {
+		t.Errorf("Expected requester's token to act as requester, got %q %v", role, ok)
+	}
+	srv.config.Server.Requesters = nil
+	if _, _, ok := srv.checkToken(secret); ok {
+		t.Error("Expected token of a removed user to be rejected")
+	}
+}
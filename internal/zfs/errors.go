@@ -0,0 +1,110 @@
+package zfs
+
+import (
+	"bufio"
+	"context"
+	"strings"
+)
+
+// DataError is one entry from the permanent error list of `zpool status -v`,
+// resolved to the dataset (and snapshot) holding the damaged object
+type DataError struct {
+	Entry    string // Raw line as printed by zpool
+	Dataset  string // Empty for pool metadata or objects in destroyed datasets
+	Snapshot string // Set when the damaged object is only referenced by a snapshot
+	Path     string // File path within the dataset, or an <0x..> object id
+}
+
+// ParseDataErrors resolves zpool error entries to datasets. Entries are either
+// absolute paths of mounted files, "dataset[@snapshot]:<path or object>", or
+// "<metadata>:<0x..>". mountpoints maps mounted paths to dataset names.
+func ParseDataErrors(entries []string, mountpoints map[string]string) []DataError {
+	var errors []DataError
+
+	for _, entry := range entries {
+		if !isDataErrorEntry(entry) {
+			continue
+		}
+
+		dataErr := DataError{Entry: entry, Path: entry}
+
+		switch {
+		case strings.HasPrefix(entry, "/"):
+			dataErr.Dataset, dataErr.Path = resolveMountedPath(entry, mountpoints)
+		case strings.HasPrefix(entry, "<"):
+			// Pool metadata or an object whose dataset no longer exists
+		default:
+			sep := strings.Index(entry, ":/")
+			if sep < 0 {
+				sep = strings.Index(entry, ":<")
+			}
+			if sep < 0 {
+				break
+			}
+			name := entry[:sep]
+			dataErr.Path = entry[sep+1:]
+			if at := strings.Index(name, "@"); at >= 0 {
+				dataErr.Snapshot = name[at+1:]
+				name = name[:at]
+			}
+			dataErr.Dataset = name
+		}
+
+		errors = append(errors, dataErr)
+	}
+
+	return errors
+}
+
+// isDataErrorEntry filters out the explanatory text zpool prints around the list
+func isDataErrorEntry(entry string) bool {
+	if strings.HasPrefix(entry, "/") || strings.HasPrefix(entry, "<") {
+		return true
+	}
+	return strings.Contains(entry, ":/") || strings.Contains(entry, ":<")
+}
+
+// resolveMountedPath finds the dataset with the longest mountpoint containing path
+func resolveMountedPath(path string, mountpoints map[string]string) (string, string) {
+	var dataset, best string
+	for mountpoint, name := range mountpoints {
+		if path != mountpoint && !strings.HasPrefix(path, strings.TrimSuffix(mountpoint, "/")+"/") {
+			continue
+		}
+		if len(mountpoint) > len(best) {
+			best = mountpoint
+			dataset = name
+		}
+	}
+
+	if dataset == "" {
+		return "", path
+	}
+
+	rel := strings.TrimPrefix(path, strings.TrimSuffix(best, "/"))
+	if rel == "" {
+		rel = "/"
+	}
+	return dataset, rel
+}
+
+// GetMountpointsContext maps the mountpoint of every mounted filesystem to its dataset
+func GetMountpointsContext(ctx context.Context) (map[string]string, error) {
+	cmd := commands.CommandContext(ctx, "zfs", "list", "-H", "-t", "filesystem", "-o", "name,mounted,mountpoint")
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	mountpoints := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 || fields[1] != "yes" || !strings.HasPrefix(fields[2], "/") {
+			continue
+		}
+		mountpoints[fields[2]] = fields[0]
+	}
+
+	return mountpoints, scanner.Err()
+}
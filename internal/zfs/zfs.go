@@ -2,40 +2,60 @@ package zfs
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os/exec"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"zfsrabbit/internal/orphan"
+	"zfsrabbit/internal/parse"
+	"zfsrabbit/internal/utils"
 	"zfsrabbit/internal/validation"
 )
 
 type Manager struct {
+	mu              sync.RWMutex // Guards dataset and recursive, which a policy change or rename replaces
 	dataset         string
 	sendCompression string
 	recursive       bool
+	rawSend         bool
+	excluded        []string // Children left out of recursive snapshots and sends
 	executor        CommandExecutor
 }
 
-type CommandExecutor interface {
-	Command(name string, args ...string) *exec.Cmd
-	Output(cmd *exec.Cmd) ([]byte, error)
-	Run(cmd *exec.Cmd) error
-}
+// CommandExecutor runs the manager's zfs commands
+type CommandExecutor = utils.CommandRunner
 
-type DefaultCommandExecutor struct{}
+// DefaultCommandExecutor runs commands through the shared runner
+type DefaultCommandExecutor struct {
+	*utils.Runner
+}
 
+// Command tags the process so a send left running by a crash can be found
+// and stopped on the next start
 func (d *DefaultCommandExecutor) Command(name string, args ...string) *exec.Cmd {
-	return exec.Command(name, args...)
+	cmd := d.Runner.Command(name, args...)
+	orphan.Mark(cmd)
+	return cmd
 }
 
-func (d *DefaultCommandExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
-	return cmd.Output()
+func (d *DefaultCommandExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := d.Runner.CommandContext(ctx, name, args...)
+	orphan.Mark(cmd)
+	return cmd
 }
 
-func (d *DefaultCommandExecutor) Run(cmd *exec.Cmd) error {
-	return cmd.Run()
+// commands runs the pool and dataset commands that aren't tied to a Manager
+var commands utils.CommandRunner = utils.DefaultRunner
+
+// SetCommandRunner replaces the runner used by the package-level functions
+func SetCommandRunner(runner utils.CommandRunner) {
+	commands = runner
 }
 
 type Snapshot struct {
@@ -46,28 +66,38 @@ type Snapshot struct {
 	Dataset string
 }
 
-type PoolStatus struct {
-	Pool   string
-	State  string
-	Scan   string
-	Config []DeviceStatus
-	Errors []string
+// PoolStatus is a pool as reported by zpool status
+type PoolStatus = parse.PoolStatus
+
+type PoolCapacity struct {
+	Pool     string
+	Size     uint64
+	Alloc    uint64
+	Free     uint64
+	Capacity int // Percentage used
+	Health   string
 }
 
-type DeviceStatus struct {
-	Name  string
-	State string
-	Read  int
-	Write int
-	Cksum int
+// PoolIOStat is a pool's load over one zpool iostat sample
+type PoolIOStat struct {
+	Pool       string
+	ReadOps    uint64 // Per second
+	WriteOps   uint64
+	ReadBytes  uint64 // Per second
+	WriteBytes uint64
+	ReadWait   time.Duration // Average total wait per IO
+	WriteWait  time.Duration
 }
 
+// DeviceStatus is one row of a pool's config
+type DeviceStatus = parse.DeviceStatus
+
 func New(dataset, sendCompression string, recursive bool) *Manager {
 	return &Manager{
 		dataset:         dataset,
 		sendCompression: sendCompression,
 		recursive:       recursive,
-		executor:        &DefaultCommandExecutor{},
+		executor:        &DefaultCommandExecutor{Runner: utils.DefaultRunner},
 	}
 }
 
@@ -80,26 +110,123 @@ func NewWithExecutor(dataset, sendCompression string, recursive bool, executor C
 	}
 }
 
+// Reconfigure points the manager at a different dataset, e.g. after a policy change
+func (m *Manager) Reconfigure(dataset string, recursive bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dataset = dataset
+	m.recursive = recursive
+}
+
+// Dataset returns the managed dataset
+func (m *Manager) Dataset() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.dataset
+}
+
+// target returns the managed dataset and whether it is recursive, read
+// together so a concurrent Reconfigure can't mix old and new
+func (m *Manager) target() (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.dataset, m.recursive
+}
+
+// SetRawSend makes sends raw (zfs send -w): encrypted datasets are sent as
+// stored on disk, so the receiving side never needs their keys
+func (m *Manager) SetRawSend(raw bool) {
+	m.rawSend = raw
+}
+
+// SetExcluded leaves children of a recursive dataset, and their own
+// children, out of its snapshots and replication streams. Used for children
+// another job replicates on its own.
+func (m *Manager) SetExcluded(datasets []string) {
+	m.excluded = datasets
+}
+
+// RawSend reports whether sends are raw
+func (m *Manager) RawSend() bool {
+	return m.rawSend
+}
+
+// sendArgs starts a zfs send command line with the stream flags; raw
+// streams carry blocks as stored, so they are already compressed
+func (m *Manager) sendArgs(allowRecursive bool) []string {
+	args := []string{"send"}
+	if m.rawSend {
+		args = append(args, "-w")
+	} else if m.sendCompression != "" {
+		args = append(args, "-c")
+	}
+	if allowRecursive && m.Recursive() {
+		args = append(args, "-R")
+		for _, dataset := range m.excluded {
+			args = append(args, "-X", dataset)
+		}
+	}
+	return args
+}
+
 func (m *Manager) CreateSnapshot(name string) error {
 	// Validate snapshot name to prevent injection
 	if err := validation.ValidateSnapshotName(name); err != nil {
 		return fmt.Errorf("invalid snapshot name: %w", err)
 	}
 
-	snapshotName := fmt.Sprintf("%s@%s", m.dataset, name)
+	dataset, recursive := m.target()
+	snapshotName := fmt.Sprintf("%s@%s", dataset, name)
 
 	args := []string{"snapshot"}
-	if m.recursive {
-		args = append(args, "-r")
+	if recursive && len(m.excluded) > 0 {
+		// zfs snapshot -r can't skip children, but snapshots of several
+		// datasets in one command are still taken atomically
+		datasets, err := m.includedDatasets()
+		if err != nil {
+			return err
+		}
+		for _, child := range datasets {
+			args = append(args, fmt.Sprintf("%s@%s", child, name))
+		}
+	} else {
+		if recursive {
+			args = append(args, "-r")
+		}
+		args = append(args, snapshotName)
 	}
-	args = append(args, snapshotName)
 
 	cmd := m.executor.Command("zfs", args...)
 	return m.executor.Run(cmd)
 }
 
+// includedDatasets returns the managed dataset and its children, less the
+// excluded ones and everything below them
+func (m *Manager) includedDatasets() ([]string, error) {
+	dataset := m.Dataset()
+	cmd := m.executor.Command("zfs", "list", "-H", "-o", "name", "-r", "-t", "filesystem,volume", dataset)
+	output, err := m.executor.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list children of %s: %w", dataset, err)
+	}
+
+	var datasets []string
+	for _, name := range strings.Fields(string(output)) {
+		excluded := false
+		for _, skip := range m.excluded {
+			if name == skip || strings.HasPrefix(name, skip+"/") {
+				excluded = true
+			}
+		}
+		if !excluded {
+			datasets = append(datasets, name)
+		}
+	}
+	return datasets, nil
+}
+
 func (m *Manager) ListSnapshots() ([]Snapshot, error) {
-	cmd := m.executor.Command("zfs", "list", "-t", "snapshot", "-H", "-o", "name,creation,used,refer", "-s", "creation", m.dataset)
+	cmd := m.executor.Command("zfs", "list", "-t", "snapshot", "-H", "-o", "name,creation,used,refer", "-s", "creation", m.Dataset())
 	output, err := m.executor.Output(cmd)
 	if err != nil {
 		return nil, err
@@ -109,12 +236,13 @@ func (m *Manager) ListSnapshots() ([]Snapshot, error) {
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
 
 	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
+		// -H separates columns with tabs; the creation date contains spaces
+		fields := strings.Split(scanner.Text(), "\t")
 		if len(fields) >= 4 {
 			parts := strings.Split(fields[0], "@")
 			if len(parts) == 2 {
 				// ZFS creation date format: "Wed Jul 17 18:00 2024"
-				created, _ := time.Parse("Mon Jan 2 15:04 2006", fields[1])
+				created, _ := time.ParseInLocation("Mon Jan _2 15:04 2006", fields[1], time.Local)
 				snapshots = append(snapshots, Snapshot{
 					Name:    parts[1],
 					Created: created,
@@ -129,27 +257,243 @@ func (m *Manager) ListSnapshots() ([]Snapshot, error) {
 	return snapshots, scanner.Err()
 }
 
+// SnapshotGUID is a snapshot with its GUID, which is the same on every
+// copy of the snapshot and survives renames
+type SnapshotGUID struct {
+	Name string `json:"name"`
+	GUID string `json:"guid"`
+}
+
+// ListSnapshotGUIDs returns the managed dataset's own snapshots with their
+// GUIDs, oldest first
+func (m *Manager) ListSnapshotGUIDs() ([]SnapshotGUID, error) {
+	cmd := m.executor.Command("zfs", "list", "-t", "snapshot", "-H", "-o", "name,guid", "-s", "creation", "-d", "1", m.Dataset())
+	output, err := m.executor.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSnapshotGUIDs(string(output)), nil
+}
+
+// ParseSnapshotGUIDs reads zfs list -H -o name,guid output, keeping the
+// part of each name after the @
+func ParseSnapshotGUIDs(output string) []SnapshotGUID {
+	var snapshots []SnapshotGUID
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		name, guid, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if _, short, ok := strings.Cut(name, "@"); ok {
+			snapshots = append(snapshots, SnapshotGUID{Name: short, GUID: strings.TrimSpace(guid)})
+		}
+	}
+	return snapshots
+}
+
+// RollbackSnapshot rolls the managed dataset back to a snapshot, destroying
+// the snapshots taken after it
+func (m *Manager) RollbackSnapshot(name string) error {
+	if err := validation.ValidateSnapshotName(name); err != nil {
+		return fmt.Errorf("invalid snapshot name: %w", err)
+	}
+
+	cmd := m.executor.Command("zfs", "rollback", "-r", fmt.Sprintf("%s@%s", m.Dataset(), name))
+	return m.executor.Run(cmd)
+}
+
 func (m *Manager) DestroySnapshot(name string) error {
 	// Validate snapshot name to prevent injection
 	if err := validation.ValidateSnapshotName(name); err != nil {
 		return fmt.Errorf("invalid snapshot name: %w", err)
 	}
 
-	snapshotName := fmt.Sprintf("%s@%s", m.dataset, name)
-	cmd := m.executor.Command("zfs", "destroy", snapshotName)
+	dataset, recursive := m.target()
+	args := []string{"destroy"}
+	if recursive {
+		// Snapshots are taken on every child, so they are destroyed there too
+		args = append(args, "-r")
+	}
+	args = append(args, fmt.Sprintf("%s@%s", dataset, name))
+	cmd := m.executor.Command("zfs", args...)
 	return m.executor.Run(cmd)
 }
 
-func (m *Manager) SendSnapshot(snapshot string) (*exec.Cmd, error) {
-	snapshotName := fmt.Sprintf("%s@%s", m.dataset, snapshot)
+// HoldSnapshot places a user hold on a snapshot so it can't be destroyed
+// until ReleaseSnapshot is called with the same tag
+func (m *Manager) HoldSnapshot(name, tag string) error {
+	return m.holdCommand("hold", name, tag)
+}
 
-	args := []string{"send"}
-	if m.sendCompression != "" {
-		args = append(args, "-c")
+// ReleaseSnapshot removes a hold placed by HoldSnapshot
+func (m *Manager) ReleaseSnapshot(name, tag string) error {
+	return m.holdCommand("release", name, tag)
+}
+
+// Hold is a user hold on a snapshot of the managed dataset or its children
+type Hold struct {
+	Dataset  string    `json:"dataset"`
+	Snapshot string    `json:"snapshot"`
+	Tag      string    `json:"tag"`
+	Created  time.Time `json:"created"`
+}
+
+// ListHolds returns the holds on the managed dataset's snapshots, and on
+// its children's when recursive
+func (m *Manager) ListHolds() ([]Hold, error) {
+	dataset, recursive := m.target()
+	depth := []string{"-d", "1"}
+	if recursive {
+		depth = []string{"-r"}
 	}
-	if m.recursive {
-		args = append(args, "-R")
+	args := append([]string{"list", "-t", "snapshot", "-H", "-o", "name,userrefs"}, depth...)
+	cmd := m.executor.Command("zfs", append(args, dataset)...)
+	output, err := m.executor.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %w", dataset, err)
+	}
+
+	// zfs holds needs the snapshot names, so only ask about held ones
+	var held []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if name, refs, ok := strings.Cut(line, "\t"); ok && strings.TrimSpace(refs) != "0" {
+			held = append(held, name)
+		}
+	}
+	if len(held) == 0 {
+		return nil, nil
 	}
+
+	cmd = m.executor.Command("zfs", append([]string{"holds", "-H", "-p"}, held...)...)
+	output, err = m.executor.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list holds: %w", err)
+	}
+	return ParseHolds(string(output)), nil
+}
+
+// ParseHolds reads zfs holds -H -p output
+func ParseHolds(output string) []Hold {
+	var holds []Hold
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		dataset, snapshot, ok := strings.Cut(fields[0], "@")
+		if !ok {
+			continue
+		}
+		hold := Hold{Dataset: dataset, Snapshot: snapshot, Tag: fields[1]}
+		if seconds, err := strconv.ParseInt(strings.TrimSpace(fields[2]), 10, 64); err == nil {
+			hold.Created = time.Unix(seconds, 0)
+		}
+		holds = append(holds, hold)
+	}
+	return holds
+}
+
+func (m *Manager) holdCommand(action, name, tag string) error {
+	dataset, recursive := m.target()
+	if err := validation.ValidateSnapshotName(name); err != nil {
+		return fmt.Errorf("invalid snapshot name: %w", err)
+	}
+
+	args := []string{action}
+	if recursive {
+		args = append(args, "-r")
+	}
+	args = append(args, tag, fmt.Sprintf("%s@%s", dataset, name))
+
+	cmd := m.executor.Command("zfs", args...)
+	return m.executor.Run(cmd)
+}
+
+// CreateBookmark keeps a bookmark of a snapshot (dataset#name) so it can
+// still serve as an incremental source after the snapshot is destroyed
+func (m *Manager) CreateBookmark(name string) error {
+	dataset := m.Dataset()
+	if err := validation.ValidateSnapshotName(name); err != nil {
+		return fmt.Errorf("invalid snapshot name: %w", err)
+	}
+
+	cmd := m.executor.Command("zfs", "bookmark", fmt.Sprintf("%s@%s", dataset, name), fmt.Sprintf("%s#%s", dataset, name))
+	return m.executor.Run(cmd)
+}
+
+// CreateBookmarks bookmarks a snapshot on every dataset DestroySnapshot
+// destroys it from: the managed dataset and, in recursive mode, each child
+// that has it. Datasets that already have the bookmark are skipped.
+func (m *Manager) CreateBookmarks(name string) error {
+	dataset, recursive := m.target()
+	if !recursive {
+		return m.CreateBookmark(name)
+	}
+	if err := validation.ValidateSnapshotName(name); err != nil {
+		return fmt.Errorf("invalid snapshot name: %w", err)
+	}
+
+	cmd := m.executor.Command("zfs", "list", "-H", "-o", "name", "-t", "snapshot,bookmark", "-r", dataset)
+	output, err := m.executor.Output(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots of %s: %w", dataset, err)
+	}
+
+	var snapshotted []string
+	bookmarked := make(map[string]bool)
+	for _, line := range strings.Fields(string(output)) {
+		if child, snapshot, ok := strings.Cut(line, "@"); ok && snapshot == name {
+			snapshotted = append(snapshotted, child)
+		} else if child, bookmark, ok := strings.Cut(line, "#"); ok && bookmark == name {
+			bookmarked[child] = true
+		}
+	}
+
+	for _, child := range snapshotted {
+		if bookmarked[child] {
+			continue
+		}
+		cmd := m.executor.Command("zfs", "bookmark", fmt.Sprintf("%s@%s", child, name), fmt.Sprintf("%s#%s", child, name))
+		if err := m.executor.Run(cmd); err != nil {
+			return fmt.Errorf("failed to bookmark %s@%s: %w", child, name, err)
+		}
+	}
+	return nil
+}
+
+// ListBookmarks returns the bookmark names on the managed dataset
+func (m *Manager) ListBookmarks() ([]string, error) {
+	cmd := m.executor.Command("zfs", "list", "-t", "bookmark", "-H", "-o", "name", "-d", "1", m.Dataset())
+	output, err := m.executor.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var bookmarks []string
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		if _, name, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "#"); ok {
+			bookmarks = append(bookmarks, name)
+		}
+	}
+
+	return bookmarks, scanner.Err()
+}
+
+// DestroyBookmark removes the bookmark dataset#name
+func (m *Manager) DestroyBookmark(name string) error {
+	if err := validation.ValidateSnapshotName(name); err != nil {
+		return fmt.Errorf("invalid bookmark name: %w", err)
+	}
+
+	cmd := m.executor.Command("zfs", "destroy", fmt.Sprintf("%s#%s", m.Dataset(), name))
+	return m.executor.Run(cmd)
+}
+
+func (m *Manager) SendSnapshot(snapshot string) (*exec.Cmd, error) {
+	snapshotName := fmt.Sprintf("%s@%s", m.Dataset(), snapshot)
+
+	args := m.sendArgs(true)
 	args = append(args, snapshotName)
 
 	cmd := m.executor.Command("zfs", args...)
@@ -157,86 +501,182 @@ func (m *Manager) SendSnapshot(snapshot string) (*exec.Cmd, error) {
 }
 
 func (m *Manager) SendIncremental(fromSnapshot, toSnapshot string) (*exec.Cmd, error) {
-	fromName := fmt.Sprintf("%s@%s", m.dataset, fromSnapshot)
-	toName := fmt.Sprintf("%s@%s", m.dataset, toSnapshot)
+	dataset := m.Dataset()
+	fromName := fmt.Sprintf("%s@%s", dataset, fromSnapshot)
+	toName := fmt.Sprintf("%s@%s", dataset, toSnapshot)
 
-	args := []string{"send"}
-	if m.sendCompression != "" {
-		args = append(args, "-c")
-	}
-	if m.recursive {
-		args = append(args, "-R")
-	}
+	args := m.sendArgs(true)
 	args = append(args, "-i", fromName, toName)
 
 	cmd := m.executor.Command("zfs", args...)
 	return cmd, nil
 }
 
-func (m *Manager) ReceiveSnapshot(dataset string) (*exec.Cmd, error) {
-	cmd := m.executor.Command("zfs", "receive", "-F", dataset)
+// SendIncrementalRange sends every snapshot after fromSnapshot up to and
+// including toSnapshot (zfs send -I), recreating intermediate snapshots
+func (m *Manager) SendIncrementalRange(fromSnapshot, toSnapshot string) (*exec.Cmd, error) {
+	dataset := m.Dataset()
+	fromName := fmt.Sprintf("%s@%s", dataset, fromSnapshot)
+	toName := fmt.Sprintf("%s@%s", dataset, toSnapshot)
+
+	args := m.sendArgs(true)
+	args = append(args, "-I", fromName, toName)
+
+	cmd := m.executor.Command("zfs", args...)
 	return cmd, nil
 }
 
-func GetPoolStatus(pool string) (*PoolStatus, error) {
-	cmd := exec.Command("zpool", "status", pool)
-	output, err := cmd.Output()
+// SendIncrementalFromBookmark sends the changes since a bookmark. Bookmarks
+// can't be the source of a replication (-R) stream, so this is never recursive.
+func (m *Manager) SendIncrementalFromBookmark(bookmark, toSnapshot string) (*exec.Cmd, error) {
+	dataset := m.Dataset()
+	fromName := fmt.Sprintf("%s#%s", dataset, bookmark)
+	toName := fmt.Sprintf("%s@%s", dataset, toSnapshot)
+
+	args := m.sendArgs(false)
+	args = append(args, "-i", fromName, toName)
+
+	cmd := m.executor.Command("zfs", args...)
+	return cmd, nil
+}
+
+// EstimateSend returns the size of the stream SendSnapshot (from "") or
+// SendIncremental would produce, with a dry run (zfs send -nP). A from
+// starting with "#" names a bookmark, as for SendIncrementalFromBookmark.
+func (m *Manager) EstimateSend(from, to string) (int64, error) {
+	dataset := m.Dataset()
+	toName := fmt.Sprintf("%s@%s", dataset, to)
+
+	var args []string
+	switch {
+	case from == "":
+		args = append(m.sendArgs(true), "-nP", toName)
+	case strings.HasPrefix(from, "#"):
+		args = append(m.sendArgs(false), "-nP", "-i", dataset+from, toName)
+	default:
+		args = append(m.sendArgs(true), "-nP", "-i", fmt.Sprintf("%s@%s", dataset, from), toName)
+	}
+
+	cmd := m.executor.Command("zfs", args...)
+	output, err := m.executor.Output(cmd)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to estimate send of %s: %w", toName, err)
 	}
+	return ParseSendSize(string(output))
+}
 
-	return parsePoolStatus(string(output))
+// ParseSendSize reads the total from zfs send -nP output, whose last
+// "size" line holds the size of the whole stream
+func ParseSendSize(output string) (int64, error) {
+	var size int64 = -1
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "size" {
+			value, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid send size %q", fields[1])
+			}
+			size = value
+		}
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("no size in zfs send output")
+	}
+	return size, nil
 }
 
-func parsePoolStatus(output string) (*PoolStatus, error) {
-	lines := strings.Split(output, "\n")
-	status := &PoolStatus{}
+// Recursive reports whether snapshots and sends include child datasets
+// resumeTokenPattern matches the opaque receive_resume_token ZFS reports
+var resumeTokenPattern = regexp.MustCompile(`^[0-9]+-[0-9a-fA-F-]+$`)
 
-	var inConfig bool
-	var inErrors bool
+// SendResume continues an interrupted send from the backup server's
+// receive_resume_token. Flags such as -c are carried in the token.
+func (m *Manager) SendResume(token string) (*exec.Cmd, error) {
+	if !resumeTokenPattern.MatchString(token) {
+		return nil, fmt.Errorf("invalid resume token")
+	}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	cmd := m.executor.Command("zfs", "send", "-t", token)
+	return cmd, nil
+}
 
-		if strings.HasPrefix(line, "pool:") {
-			status.Pool = strings.TrimSpace(strings.TrimPrefix(line, "pool:"))
-		} else if strings.HasPrefix(line, "state:") {
-			status.State = strings.TrimSpace(strings.TrimPrefix(line, "state:"))
-		} else if strings.HasPrefix(line, "scan:") {
-			status.Scan = strings.TrimSpace(strings.TrimPrefix(line, "scan:"))
-		} else if strings.HasPrefix(line, "config:") {
-			inConfig = true
-			inErrors = false
-		} else if strings.HasPrefix(line, "errors:") {
-			inErrors = true
-			inConfig = false
-		} else if inConfig && line != "" {
-			if device := parseDeviceStatus(line); device != nil {
-				status.Config = append(status.Config, *device)
-			}
-		} else if inErrors && line != "" {
-			status.Errors = append(status.Errors, line)
-		}
+// ValidateResumeToken dry-runs a resumed send, which fails when the token is
+// malformed or the snapshot it was sending no longer exists locally
+func (m *Manager) ValidateResumeToken(token string) error {
+	if !resumeTokenPattern.MatchString(token) {
+		return fmt.Errorf("invalid resume token")
 	}
 
-	return status, nil
+	cmd := m.executor.Command("zfs", "send", "-n", "-t", token)
+	return m.executor.Run(cmd)
+}
+
+func (m *Manager) Recursive() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.recursive
 }
 
-func parseDeviceStatus(line string) *DeviceStatus {
-	re := regexp.MustCompile(`^\s*(\S+)\s+(\S+)\s+(\d+)\s+(\d+)\s+(\d+)`)
-	matches := re.FindStringSubmatch(line)
+// SetDataset points the manager at a dataset's new name after a zfs rename.
+// Callers make sure nothing else is using the manager meanwhile.
+func (m *Manager) SetDataset(dataset string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dataset = dataset
+}
 
-	if len(matches) != 6 {
-		return nil
+// DatasetGUID returns the managed dataset's GUID, which stays the same when
+// the dataset is renamed
+func (m *Manager) DatasetGUID() (string, error) {
+	dataset := m.Dataset()
+	cmd := m.executor.Command("zfs", "get", "-H", "-o", "value", "guid", dataset)
+	output, err := m.executor.Output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the GUID of %s: %w", dataset, err)
 	}
+	return strings.TrimSpace(string(output)), nil
+}
 
-	return &DeviceStatus{
-		Name:  matches[1],
-		State: matches[2],
-		Read:  parseInt(matches[3]),
-		Write: parseInt(matches[4]),
-		Cksum: parseInt(matches[5]),
+// FindDatasetByGUID returns the filesystem or volume with the given GUID,
+// or "" if there is none
+func (m *Manager) FindDatasetByGUID(guid string) (string, error) {
+	cmd := m.executor.Command("zfs", "list", "-H", "-o", "name,guid", "-t", "filesystem,volume")
+	output, err := m.executor.Output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to list datasets: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if name, value, ok := strings.Cut(line, "\t"); ok && strings.TrimSpace(value) == guid {
+			return name, nil
+		}
 	}
+	return "", nil
+}
+
+func (m *Manager) ReceiveSnapshot(dataset string) (*exec.Cmd, error) {
+	cmd := m.executor.Command("zfs", "receive", "-F", dataset)
+	return cmd, nil
+}
+
+func GetPoolStatus(pool string) (*PoolStatus, error) {
+	return GetPoolStatusContext(context.Background(), pool)
+}
+
+// GetPoolStatusContext is GetPoolStatus with a context so a hung zpool can be killed
+func GetPoolStatusContext(ctx context.Context, pool string) (*PoolStatus, error) {
+	// -v lists the files affected by permanent errors
+	cmd := commands.CommandContext(ctx, "zpool", "status", "-v", pool)
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return parse.ZpoolStatus(string(output))
+}
+
+// ParsePoolStatus parses `zpool status` output for a single pool
+func ParsePoolStatus(output string) (*PoolStatus, error) {
+	return parse.ZpoolStatus(output)
 }
 
 func parseInt(s string) int {
@@ -246,13 +686,18 @@ func parseInt(s string) int {
 }
 
 func ScrubPool(pool string) error {
-	cmd := exec.Command("zpool", "scrub", pool)
-	return cmd.Run()
+	cmd := commands.Command("zpool", "scrub", pool)
+	return commands.Run(cmd)
 }
 
 func GetPools() ([]string, error) {
-	cmd := exec.Command("zpool", "list", "-H", "-o", "name")
-	output, err := cmd.Output()
+	return GetPoolsContext(context.Background())
+}
+
+// GetPoolsContext is GetPools with a context so a hung zpool can be killed
+func GetPoolsContext(ctx context.Context) ([]string, error) {
+	cmd := commands.CommandContext(ctx, "zpool", "list", "-H", "-o", "name")
+	output, err := commands.Output(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -269,3 +714,288 @@ func GetPools() ([]string, error) {
 
 	return pools, scanner.Err()
 }
+
+// GetPoolCapacity returns size and usage figures for a pool in bytes
+func GetPoolCapacity(ctx context.Context, pool string) (*PoolCapacity, error) {
+	cmd := commands.CommandContext(ctx, "zpool", "list", "-H", "-p", "-o", "name,size,alloc,free,cap,health", pool)
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePoolCapacity(string(output))
+}
+
+func parsePoolCapacity(output string) (*PoolCapacity, error) {
+	fields := strings.Fields(strings.TrimSpace(output))
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("unexpected zpool list output: %q", output)
+	}
+
+	capacity := &PoolCapacity{
+		Pool:   fields[0],
+		Health: fields[5],
+	}
+	fmt.Sscanf(fields[1], "%d", &capacity.Size)
+	fmt.Sscanf(fields[2], "%d", &capacity.Alloc)
+	fmt.Sscanf(fields[3], "%d", &capacity.Free)
+	capacity.Capacity = parseInt(strings.TrimSuffix(fields[4], "%"))
+
+	return capacity, nil
+}
+
+// GetPoolIOStat measures a pool's throughput and latency over one sample of
+// the given length
+func GetPoolIOStat(ctx context.Context, pool string, sample time.Duration) (*PoolIOStat, error) {
+	seconds := max(int(sample.Seconds()), 1)
+	cmd := commands.CommandContext(ctx, "zpool", "iostat", "-H", "-p", "-y", "-l", pool, strconv.Itoa(seconds), "1")
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePoolIOStat(string(output))
+}
+
+// parsePoolIOStat reads zpool iostat -H -p -l output: name, alloc, free,
+// read and write ops, read and write bandwidth, then total read and write
+// wait in nanoseconds. A wait is "-" when there was no IO.
+func parsePoolIOStat(output string) (*PoolIOStat, error) {
+	fields := strings.Fields(strings.TrimSpace(output))
+	if len(fields) < 9 {
+		return nil, fmt.Errorf("unexpected zpool iostat output: %q", output)
+	}
+
+	stat := &PoolIOStat{Pool: fields[0]}
+	fmt.Sscanf(fields[3], "%d", &stat.ReadOps)
+	fmt.Sscanf(fields[4], "%d", &stat.WriteOps)
+	fmt.Sscanf(fields[5], "%d", &stat.ReadBytes)
+	fmt.Sscanf(fields[6], "%d", &stat.WriteBytes)
+	var readWait, writeWait int64
+	fmt.Sscanf(fields[7], "%d", &readWait)
+	fmt.Sscanf(fields[8], "%d", &writeWait)
+	stat.ReadWait = time.Duration(readWait)
+	stat.WriteWait = time.Duration(writeWait)
+
+	return stat, nil
+}
+
+// DatasetExists reports whether a local dataset exists
+func DatasetExists(dataset string) (bool, error) {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return false, err
+	}
+
+	cmd := commands.Command("zfs", "list", "-H", "-o", "name", dataset)
+	if _, err := commands.Output(cmd); err != nil {
+		if strings.Contains(err.Error(), "does not exist") {
+			return false, nil
+		}
+		return false, fmt.Errorf("zfs list %s failed: %w", dataset, err)
+	}
+	return true, nil
+}
+
+// CreateDataset creates a dataset along with any missing parents
+func CreateDataset(dataset string) error {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+
+	cmd := commands.Command("zfs", "create", "-p", dataset)
+	if err := commands.Run(cmd); err != nil {
+		return fmt.Errorf("zfs create %s failed: %w", dataset, err)
+	}
+	return nil
+}
+
+// DestroyDataset destroys a dataset together with its children and snapshots
+func DestroyDataset(dataset string) error {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+
+	cmd := commands.Command("zfs", "destroy", "-r", dataset)
+	if err := commands.Run(cmd); err != nil {
+		return fmt.Errorf("zfs destroy %s failed: %w", dataset, err)
+	}
+	return nil
+}
+
+// ListFilesystems returns a filesystem and those below it, parents first
+func ListFilesystems(dataset string) ([]string, error) {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return nil, err
+	}
+
+	cmd := commands.Command("zfs", "list", "-H", "-o", "name", "-r", "-t", "filesystem", dataset)
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("zfs list %s failed: %w", dataset, err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// SetMountpoint sets a dataset's mountpoint property
+func SetMountpoint(dataset, mountpoint string) error {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+	if err := validation.ValidateMountpoint(mountpoint); err != nil {
+		return err
+	}
+
+	cmd := commands.Command("zfs", "set", "mountpoint="+mountpoint, dataset)
+	if err := commands.Run(cmd); err != nil {
+		return fmt.Errorf("zfs set mountpoint on %s failed: %w", dataset, err)
+	}
+	return nil
+}
+
+// MountDataset mounts a dataset at its mountpoint
+func MountDataset(dataset string) error {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+
+	cmd := commands.Command("zfs", "mount", dataset)
+	if err := commands.Run(cmd); err != nil {
+		return fmt.Errorf("zfs mount %s failed: %w", dataset, err)
+	}
+	return nil
+}
+
+// Shares holds a dataset's sharenfs and sharesmb properties. An empty field
+// leaves that property unchanged when setting them.
+type Shares struct {
+	NFS string `json:"sharenfs,omitempty"`
+	SMB string `json:"sharesmb,omitempty"`
+}
+
+// IsZero reports whether no share property is set
+func (s Shares) IsZero() bool {
+	return s.NFS == "" && s.SMB == ""
+}
+
+// GetShares returns a dataset's sharenfs and sharesmb properties
+func GetShares(dataset string) (Shares, error) {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return Shares{}, err
+	}
+
+	cmd := commands.Command("zfs", "get", "-H", "-o", "value", "sharenfs,sharesmb", dataset)
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return Shares{}, fmt.Errorf("zfs get shares of %s failed: %w", dataset, err)
+	}
+
+	values := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(values) != 2 {
+		return Shares{}, fmt.Errorf("unexpected zfs get output for %s: %q", dataset, string(output))
+	}
+	return Shares{NFS: values[0], SMB: values[1]}, nil
+}
+
+// SetShares sets the non-empty share properties on a dataset
+func SetShares(dataset string, shares Shares) error {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+
+	args := []string{"set"}
+	for _, property := range []struct{ name, value string }{{"sharenfs", shares.NFS}, {"sharesmb", shares.SMB}} {
+		if property.value == "" {
+			continue
+		}
+		if err := validation.ValidateShareOption(property.value); err != nil {
+			return fmt.Errorf("invalid %s: %w", property.name, err)
+		}
+		args = append(args, property.name+"="+property.value)
+	}
+	if len(args) == 1 {
+		return nil
+	}
+
+	cmd := commands.Command("zfs", append(args, dataset)...)
+	if err := commands.Run(cmd); err != nil {
+		return fmt.Errorf("zfs set shares on %s failed: %w", dataset, err)
+	}
+	return nil
+}
+
+// GetPropertiesContext returns the current values of the named properties
+// of a dataset
+func GetPropertiesContext(ctx context.Context, dataset string, names []string) (map[string]string, error) {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return map[string]string{}, nil
+	}
+
+	cmd := commands.CommandContext(ctx, "zfs", "get", "-H", "-o", "property,value", strings.Join(names, ","), dataset)
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("zfs get properties of %s failed: %w", dataset, err)
+	}
+
+	values := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		values[fields[0]] = fields[1]
+	}
+	return values, nil
+}
+
+// SetProperties sets several properties on a dataset in one zfs set
+func SetProperties(dataset string, props map[string]string) error {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return err
+	}
+	if len(props) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{"set"}
+	for _, name := range names {
+		value := props[name]
+		if strings.ContainsAny(name, "= \t\n") || strings.ContainsAny(value, "\n\x00") {
+			return fmt.Errorf("invalid property %s=%q", name, value)
+		}
+		args = append(args, name+"="+value)
+	}
+
+	cmd := commands.Command("zfs", append(args, dataset)...)
+	if err := commands.Run(cmd); err != nil {
+		return fmt.Errorf("zfs set properties on %s failed: %w", dataset, err)
+	}
+	return nil
+}
+
+// GetMountpoint returns where a dataset is mounted, or "" if it isn't
+func GetMountpoint(dataset string) (string, error) {
+	if err := validation.ValidateDatasetName(dataset); err != nil {
+		return "", err
+	}
+
+	cmd := commands.Command("zfs", "get", "-H", "-o", "value", "mounted,mountpoint", dataset)
+	output, err := commands.Output(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	values := strings.Fields(string(output))
+	if len(values) != 2 || values[0] != "yes" {
+		return "", nil
+	}
+	return values[1], nil
+}
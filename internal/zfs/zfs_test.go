@@ -1,10 +1,12 @@
 package zfs
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 )
 
 // The Manager now supports dependency injection, so we don't need TestableManager
@@ -42,6 +44,10 @@ func (m *MockCommandExecutor) Command(name string, args ...string) *exec.Cmd {
 	return &exec.Cmd{Path: name, Args: append([]string{name}, args...)}
 }
 
+func (m *MockCommandExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return m.Command(name, args...)
+}
+
 func (m *MockCommandExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
 	cmdStr := strings.Join(cmd.Args, " ")
 
@@ -166,6 +172,27 @@ func TestCreateSnapshot(t *testing.T) {
 
 // Helper methods removed - Manager now uses injected executor directly
 
+func TestExcludedChildren(t *testing.T) {
+	executor := NewMockCommandExecutor()
+	executor.AddCommand("zfs list -H -o name -r -t filesystem,volume tank/test",
+		"tank/test\ntank/test/home\ntank/test/db\ntank/test/db/logs\ntank/test/dbx\n", nil)
+	manager := NewWithExecutor("tank/test", "lz4", true, executor)
+	manager.SetExcluded([]string{"tank/test/db"})
+
+	if err := manager.CreateSnapshot("snap1"); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	want := "zfs snapshot tank/test@snap1 tank/test/home@snap1 tank/test/dbx@snap1"
+	if got := executor.callLog[len(executor.callLog)-1]; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	cmd, _ := manager.SendSnapshot("snap1")
+	if got := strings.Join(cmd.Args, " "); got != "zfs send -c -R -X tank/test/db tank/test@snap1" {
+		t.Errorf("Expected the child to be excluded from the stream, got %q", got)
+	}
+}
+
 func TestListSnapshots(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -230,11 +257,51 @@ tank/test@snap2	Tue Jan  3 10:30 2023	2.34G	5.67G`,
 				if snapshots[i].Name != expectedName {
 					t.Errorf("Expected snapshot name %s, got %s", expectedName, snapshots[i].Name)
 				}
+				if snapshots[i].Created.IsZero() {
+					t.Errorf("Expected creation time to be parsed for %s", expectedName)
+				}
 			}
 		})
 	}
 }
 
+func TestListSnapshotGUIDs(t *testing.T) {
+	executor := NewMockCommandExecutor()
+	executor.AddCommand("zfs list -t snapshot -H -o name,guid -s creation -d 1 tank/test",
+		"tank/test@snap1\t1234567890123456789\ntank/test@snap2\t42\n", nil)
+	manager := NewWithExecutor("tank/test", "lz4", false, executor)
+
+	snapshots, err := manager.ListSnapshotGUIDs()
+	if err != nil {
+		t.Fatalf("ListSnapshotGUIDs: %v", err)
+	}
+	want := []SnapshotGUID{{Name: "snap1", GUID: "1234567890123456789"}, {Name: "snap2", GUID: "42"}}
+	if len(snapshots) != len(want) || snapshots[0] != want[0] || snapshots[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, snapshots)
+	}
+}
+
+func TestListHolds(t *testing.T) {
+	executor := NewMockCommandExecutor()
+	executor.AddCommand("zfs list -t snapshot -H -o name,userrefs -r tank/test",
+		"tank/test@snap1\t0\ntank/test@snap2\t1\ntank/test/db@snap2\t2\n", nil)
+	executor.AddCommand("zfs holds -H -p tank/test@snap2 tank/test/db@snap2",
+		"tank/test@snap2\tzfsrabbit-send\t1721239200\ntank/test/db@snap2\tzfsrabbit-send\t1721239200\ntank/test/db@snap2\tkeep\t1721239300\n", nil)
+	manager := NewWithExecutor("tank/test", "lz4", true, executor)
+
+	holds, err := manager.ListHolds()
+	if err != nil {
+		t.Fatalf("ListHolds: %v", err)
+	}
+	if len(holds) != 3 {
+		t.Fatalf("Expected 3 holds, got %+v", holds)
+	}
+	want := Hold{Dataset: "tank/test/db", Snapshot: "snap2", Tag: "keep", Created: time.Unix(1721239300, 0)}
+	if holds[2] != want {
+		t.Errorf("Expected %+v, got %+v", want, holds[2])
+	}
+}
+
 // Helper methods removed - using Manager methods directly
 
 func TestSendSnapshot(t *testing.T) {
@@ -291,6 +358,84 @@ func TestSendSnapshot(t *testing.T) {
 	}
 }
 
+func TestSendRaw(t *testing.T) {
+	manager := New("tank/secure", "lz4", true)
+	manager.SetRawSend(true)
+
+	full, _ := manager.SendSnapshot("snap2")
+	incremental, _ := manager.SendIncremental("snap1", "snap2")
+	bookmark, _ := manager.SendIncrementalFromBookmark("snap1", "snap2")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"full", full.Args, "zfs send -w -R tank/secure@snap2"},
+		{"incremental", incremental.Args, "zfs send -w -R -i tank/secure@snap1 tank/secure@snap2"},
+		{"from bookmark", bookmark.Args, "zfs send -w -i tank/secure#snap1 tank/secure@snap2"},
+	}
+	for _, tt := range tests {
+		if actual := strings.Join(tt.args, " "); actual != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseSendSize(t *testing.T) {
+	output := "full\tbackup/data@autosnap_2026-01-01_02-00-00\t1048576\n" +
+		"full\tbackup/data/child@autosnap_2026-01-01_02-00-00\t2048\n" +
+		"size\t1050624\n"
+
+	size, err := ParseSendSize(output)
+	if err != nil {
+		t.Fatalf("ParseSendSize failed: %v", err)
+	}
+	if size != 1050624 {
+		t.Errorf("Expected 1050624, got %d", size)
+	}
+
+	if _, err := ParseSendSize("cannot open 'backup/data@missing'"); err == nil {
+		t.Error("Expected an error without a size line")
+	}
+}
+
+func TestEstimateSend(t *testing.T) {
+	executor := NewMockCommandExecutor()
+	executor.AddCommand("zfs send -c -nP -i tank/test#snap1 tank/test@snap2", "size\t4096\n", nil)
+	manager := NewWithExecutor("tank/test", "lz4", false, executor)
+
+	size, err := manager.EstimateSend("#snap1", "snap2")
+	if err != nil || size != 4096 {
+		t.Errorf("Expected 4096 bytes, got %d (%v)", size, err)
+	}
+
+	manager.EstimateSend("", "snap2")
+	if last := executor.callLog[len(executor.callLog)-1]; last != "zfs send -c -nP tank/test@snap2" {
+		t.Errorf("Unexpected full send estimate command %q", last)
+	}
+}
+
+func TestSendResume(t *testing.T) {
+	manager := New("tank/test", "lz4", false)
+	token := "1-e3f30e5bc-c0-789c636064000310a500c4ec50360710e72765a5269730"
+
+	cmd, err := manager.SendResume(token)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Compression and other flags come from the token, so none are added
+	if got := strings.Join(cmd.Args, " "); got != "zfs send -t "+token {
+		t.Errorf("Unexpected command: %s", got)
+	}
+
+	for _, bad := range []string{"", "-", "-n", "1-abc; rm -rf /"} {
+		if _, err := manager.SendResume(bad); err == nil {
+			t.Errorf("Expected invalid token %q to be rejected", bad)
+		}
+	}
+}
+
 func TestGetPools(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -363,7 +508,7 @@ config:
 
 errors: No known data errors`
 
-	status, err := parsePoolStatus(mockOutput)
+	status, err := ParsePoolStatus(mockOutput)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -399,4 +544,99 @@ errors: No known data errors`
 			t.Errorf("Expected device state ONLINE, got %s", status.Config[i].State)
 		}
 	}
+
+	for i, depth := range []int{0, 1, 2, 2, 2} {
+		if i < len(status.Config) && status.Config[i].Depth != depth {
+			t.Errorf("Expected %s at depth %d, got %d", status.Config[i].Name, depth, status.Config[i].Depth)
+		}
+	}
+}
+
+func TestParsePoolCapacity(t *testing.T) {
+	mockOutput := "tank\t4000787030016\t3200629624012\t800157406004\t80\tONLINE\n"
+
+	capacity, err := parsePoolCapacity(mockOutput)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if capacity.Pool != "tank" {
+		t.Errorf("Expected pool name 'tank', got '%s'", capacity.Pool)
+	}
+
+	if capacity.Size != 4000787030016 {
+		t.Errorf("Expected size 4000787030016, got %d", capacity.Size)
+	}
+
+	if capacity.Capacity != 80 {
+		t.Errorf("Expected capacity 80, got %d", capacity.Capacity)
+	}
+
+	if capacity.Health != "ONLINE" {
+		t.Errorf("Expected health ONLINE, got %s", capacity.Health)
+	}
+
+	if _, err := parsePoolCapacity("tank 100"); err == nil {
+		t.Error("Expected error for truncated output")
+	}
+}
+
+func TestParsePoolIOStat(t *testing.T) {
+	mockOutput := "tank\t3200629624012\t800157406004\t120\t450\t10485760\t94371840\t2500000\t41000000\t1200000\t30000000\t-\t-\t-\t-\t-\t-\n"
+
+	stat, err := parsePoolIOStat(mockOutput)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if stat.Pool != "tank" || stat.ReadOps != 120 || stat.WriteOps != 450 {
+		t.Errorf("Unexpected pool or ops: %+v", stat)
+	}
+	if stat.ReadBytes != 10485760 || stat.WriteBytes != 94371840 {
+		t.Errorf("Unexpected bandwidth: %+v", stat)
+	}
+	if stat.ReadWait != 2500*time.Microsecond || stat.WriteWait != 41*time.Millisecond {
+		t.Errorf("Unexpected waits: read %s, write %s", stat.ReadWait, stat.WriteWait)
+	}
+
+	idle, err := parsePoolIOStat("tank\t1\t2\t0\t0\t0\t0\t-\t-\n")
+	if err != nil || idle.ReadWait != 0 || idle.WriteWait != 0 {
+		t.Errorf("Expected no wait for an idle pool, got %+v, %v", idle, err)
+	}
+
+	if _, err := parsePoolIOStat("tank 1 2 3"); err == nil {
+		t.Error("Expected error for truncated output")
+	}
+}
+
+func TestParseDataErrors(t *testing.T) {
+	entries := []string{
+		"Permanent errors have been detected in the following files:",
+		"/tank/data/docs/report.pdf",
+		"tank/data/child@autosnap_2024-01-01:/photos/img.jpg",
+		"tank/data/unmounted:<0x1a>",
+		"<metadata>:<0x3f>",
+	}
+	mountpoints := map[string]string{
+		"/tank":      "tank",
+		"/tank/data": "tank/data",
+	}
+
+	errors := ParseDataErrors(entries, mountpoints)
+	if len(errors) != 4 {
+		t.Fatalf("Expected 4 errors, got %d: %+v", len(errors), errors)
+	}
+
+	expected := []DataError{
+		{Dataset: "tank/data", Path: "/docs/report.pdf"},
+		{Dataset: "tank/data/child", Snapshot: "autosnap_2024-01-01", Path: "/photos/img.jpg"},
+		{Dataset: "tank/data/unmounted", Path: "<0x1a>"},
+		{Dataset: "", Path: "<metadata>:<0x3f>"},
+	}
+	for i, want := range expected {
+		got := errors[i]
+		if got.Dataset != want.Dataset || got.Snapshot != want.Snapshot || got.Path != want.Path {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want, got)
+		}
+	}
 }
@@ -1,25 +1,93 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 
 	"zfsrabbit/internal/config"
+	"zfsrabbit/internal/failover"
+	"zfsrabbit/internal/logging"
+	"zfsrabbit/internal/objectstore"
+	"zfsrabbit/internal/selfbackup"
 	"zfsrabbit/internal/server"
+	"zfsrabbit/internal/state"
+	"zfsrabbit/internal/statuscheck"
+	"zfsrabbit/internal/support"
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/zfs"
 )
 
 func main() {
 	var configPath string
+	var dryRun bool
+	var bootstrap bootstrapOptions
 	flag.StringVar(&configPath, "config", "/etc/zfsrabbit/config.yaml", "Path to configuration file")
+	flag.BoolVar(&dryRun, "dry-run", false, "Log snapshots, sends, destroys and restores instead of performing them (overrides dry_run in the config)")
+	flag.StringVar(&bootstrap.from, "bootstrap-from", "", "Restore config and state from a backup server (user@host) and exit")
+	flag.StringVar(&bootstrap.key, "bootstrap-key", "/root/.ssh/id_rsa", "SSH private key for -bootstrap-from")
+	flag.StringVar(&bootstrap.remoteDir, "bootstrap-remote-dir", "/var/backups/zfsrabbit", "Directory holding state backups on the backup server")
+	flag.StringVar(&bootstrap.host, "bootstrap-host", "", "Hostname of the machine being replaced (default: this host)")
+	flag.StringVar(&bootstrap.stateDir, "bootstrap-state-dir", "/var/lib/zfsrabbit", "State directory to restore into")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n       %s [flags] status [--json] [--url URL]\n       %s [flags] check backup-freshness|pool-health [-w WARNING] [-c CRITICAL]\n       %s hash-password < password\n       %s [flags] object-restore [--dataset DATASET] [--snapshot SNAPSHOT] [--list] TARGET\n       %s [flags] reverse --host HOST [--user USER] [--output FILE] [--yes]\n\nFlags:\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	args := flag.Args()
+	if filepath.Base(os.Args[0]) == "check_zfsrabbit" {
+		// Installed as a Nagios plugin: check_zfsrabbit pool-health -w 80 -c 90
+		args = append([]string{"check"}, args...)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "status":
+			os.Exit(runStatus(configPath, args[1:]))
+		case "check":
+			os.Exit(runCheck(configPath, args[1:]))
+		case "hash-password":
+			os.Exit(runHashPassword(os.Stdin))
+		case "object-restore":
+			os.Exit(runObjectRestore(configPath, args[1:]))
+		case "reverse":
+			os.Exit(runReverse(configPath, args[1:]))
+		}
+	}
+
+	// Keep recent log lines for support bundles
+	logOutput := io.MultiWriter(os.Stderr, support.RecentLogs)
+	log.SetOutput(logOutput)
+
+	if bootstrap.from != "" {
+		if err := runBootstrapRestore(configPath, bootstrap); err != nil {
+			log.Fatalf("Bootstrap restore failed: %v", err)
+		}
+		return
+	}
+
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if dryRun {
+		cfg.DryRun = true
+	}
+	if err := logging.Setup(logOutput, cfg.Server.LogLevel, cfg.Server.LogFormat); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
 
 	srv, err := server.New(cfg)
 	if err != nil {
@@ -39,3 +107,315 @@ func main() {
 	log.Println("Shutting down...")
 	srv.Stop()
 }
+
+// runStatus prints the running daemon's status for cron jobs and monitoring
+// checks, exiting non-zero when it is unhealthy
+func runStatus(configPath string, args []string) int {
+	var opts statuscheck.Options
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.BoolVar(&opts.JSON, "json", false, "Print the full status as JSON")
+	flags.StringVar(&opts.URL, "url", "", "Status endpoint (default: http://127.0.0.1:<server.port>/api/status)")
+	flags.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "How long to wait for the daemon")
+	flags.Parse(args)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "UNKNOWN: failed to load configuration: %v\n", err)
+		return statuscheck.ExitUnknown
+	}
+	return statuscheck.Run(cfg, opts, os.Stdout)
+}
+
+// runCheck runs one of the daemon's Nagios-style checks and exits with its
+// plugin exit code
+func runCheck(configPath string, args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("UNKNOWN - usage: check backup-freshness|pool-health [-w WARNING] [-c CRITICAL]")
+		return statuscheck.ExitUnknown
+	}
+	name := args[0]
+
+	var opts statuscheck.Options
+	var warning, critical, dataset, pool, baseURL string
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.StringVar(&warning, "warning", "", "Warning threshold: a duration for backup-freshness, a capacity percentage for pool-health")
+	flags.StringVar(&warning, "w", "", "Shorthand for --warning")
+	flags.StringVar(&critical, "critical", "", "Critical threshold")
+	flags.StringVar(&critical, "c", "", "Shorthand for --critical")
+	flags.StringVar(&dataset, "dataset", "", "Only check this dataset (backup-freshness)")
+	flags.StringVar(&pool, "pool", "", "Only check this pool (pool-health)")
+	flags.StringVar(&baseURL, "url", "", "Daemon URL (default: http://127.0.0.1:<server.port>)")
+	flags.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "How long to wait for the daemon")
+	flags.Parse(args[1:])
+
+	params := url.Values{}
+	for key, value := range map[string]string{"warning": warning, "critical": critical, "dataset": dataset, "pool": pool} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Printf("UNKNOWN - failed to load configuration: %v\n", err)
+		return statuscheck.ExitUnknown
+	}
+	return statuscheck.RunCheck(cfg, name, params, baseURL, opts, os.Stdout)
+}
+
+// runHashPassword prints a bcrypt hash of the password read from stdin for
+// use as a server.users password_hash
+func runHashPassword(in io.Reader) int {
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "failed to read password: %v\n", err)
+		return 1
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		fmt.Fprintln(os.Stderr, "usage: echo 'password' | zfsrabbit hash-password")
+		return 1
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hash password: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(hash))
+	return 0
+}
+
+// runObjectRestore receives a snapshot kept in object storage into TARGET,
+// downloading the full stream and every incremental leading up to it
+func runObjectRestore(configPath string, args []string) int {
+	var dataset, snapshot string
+	var list bool
+	flags := flag.NewFlagSet("object-restore", flag.ExitOnError)
+	flags.StringVar(&dataset, "dataset", "", "Source dataset the snapshot was taken of (default: zfs.dataset)")
+	flags.StringVar(&snapshot, "snapshot", "", "Snapshot to restore (default: the newest uploaded)")
+	flags.BoolVar(&list, "list", false, "List the dataset's uploaded snapshots instead of restoring")
+	flags.Parse(args)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+	if dataset == "" {
+		dataset = cfg.ZFS.Dataset
+	}
+	store, err := objectstore.New(&cfg.S3)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "object storage: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	manifests, err := store.Manifests(ctx, dataset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list uploaded snapshots: %v\n", err)
+		return 1
+	}
+	if list {
+		for _, m := range manifests {
+			kind := "full"
+			if m.Base != "" {
+				kind = "incremental from " + m.Base
+			}
+			fmt.Printf("%s\t%s\t%d bytes\t%s\n", m.Snapshot, m.Created.Local().Format(time.RFC3339), m.StreamBytes, kind)
+		}
+		return 0
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: zfsrabbit object-restore [--dataset DATASET] [--snapshot SNAPSHOT] TARGET")
+		return 1
+	}
+	target := flags.Arg(0)
+	if snapshot == "" {
+		if len(manifests) == 0 {
+			fmt.Fprintf(os.Stderr, "no snapshots of %s in object storage\n", dataset)
+			return 1
+		}
+		snapshot = manifests[len(manifests)-1].Snapshot
+	}
+
+	chain, err := store.Chain(ctx, dataset, snapshot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	zfsManager := zfs.New(dataset, "", false)
+	for _, manifest := range chain {
+		log.Printf("Receiving %s@%s (%d bytes) into %s", dataset, manifest.Snapshot, manifest.StreamBytes, target)
+		if err := receiveObject(store, zfsManager, manifest, target); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to restore %s@%s: %v\n", dataset, manifest.Snapshot, err)
+			return 1
+		}
+	}
+	log.Printf("Restored %s@%s into %s", dataset, snapshot, target)
+	return 0
+}
+
+// receiveObject pipes one downloaded stream into zfs receive. A corrupt
+// chunk stops the download, and closing the pipe early aborts the receive.
+func receiveObject(store *objectstore.Store, zfsManager *zfs.Manager, manifest *objectstore.Manifest, target string) error {
+	receiveCmd, err := zfsManager.ReceiveSnapshot(target)
+	if err != nil {
+		return err
+	}
+	stdin, err := receiveCmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	receiveCmd.Stderr = os.Stderr
+	if err := receiveCmd.Start(); err != nil {
+		return err
+	}
+
+	downloadErr := store.Download(context.Background(), manifest, stdin)
+	stdin.Close()
+	receiveErr := receiveCmd.Wait()
+	if downloadErr != nil {
+		return downloadErr
+	}
+	return receiveErr
+}
+
+// runReverse turns this host into the backup target of the server that took
+// over after a failover: it shows the plan, applies it with --yes and writes
+// the config for the new primary
+func runReverse(configPath string, args []string) int {
+	var host, user, output string
+	var yes bool
+	flags := flag.NewFlagSet("reverse", flag.ExitOnError)
+	flags.StringVar(&host, "host", "", "Address the new primary reaches this host at")
+	flags.StringVar(&user, "user", "root", "User the new primary logs in to this host as")
+	flags.StringVar(&output, "output", "", "Where to write the new primary's config (default: <config>.reversed)")
+	flags.BoolVar(&yes, "yes", false, "Apply the plan instead of only showing it")
+	flags.Parse(args)
+
+	if host == "" {
+		fmt.Fprintln(os.Stderr, "usage: zfsrabbit reverse --host HOST [--user USER] [--output FILE] [--yes]")
+		return 1
+	}
+	if output == "" {
+		output = configPath + ".reversed"
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	// Holding the lock guarantees the daemon isn't replicating meanwhile
+	if cfg.Server.StateDir != "" {
+		dir, err := state.Open(cfg.Server.StateDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v (stop zfsrabbit first)\n", err)
+			return 1
+		}
+		defer dir.Close()
+	}
+
+	sshTransport := transport.NewSSHTransport(&cfg.SSH)
+	defer sshTransport.Close()
+	zfsManager := zfs.New(cfg.ZFS.Dataset, cfg.ZFS.SendCompression, cfg.ZFS.Recursive)
+
+	local, err := zfsManager.ListSnapshotGUIDs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list local snapshots: %v\n", err)
+		return 1
+	}
+	remote, err := sshTransport.ListRemoteSnapshotGUIDs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	plan, err := failover.NewPlan(cfg.ZFS.Dataset, cfg.SSH.RemoteHost, cfg.SSH.RemoteDataset, local, remote)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	fmt.Print(plan)
+	if !yes {
+		fmt.Println("\nRun again with --yes to apply.")
+		return 0
+	}
+
+	if err := failover.Apply(context.Background(), plan, zfsManager, sshTransport, cfg.ZFS.RawSend); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	data, err := failover.ReversedConfig(cfg, host, user)
+	if err == nil {
+		err = os.WriteFile(output, data, 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write the new primary's config: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n%s now follows %s:%s. To resume protection in the new direction:\n", cfg.ZFS.Dataset, cfg.SSH.RemoteHost, cfg.SSH.RemoteDataset)
+	fmt.Printf("  1. Copy %s to %s and start zfsrabbit there with it\n", output, cfg.SSH.RemoteHost)
+	fmt.Printf("  2. Make sure %s can log in here as %s with %s\n", cfg.SSH.RemoteHost, user, cfg.SSH.PrivateKey)
+	fmt.Println("  3. Leave zfsrabbit stopped on this host, which is now the target")
+	return 0
+}
+
+type bootstrapOptions struct {
+	from      string
+	key       string
+	remoteDir string
+	host      string
+	stateDir  string
+}
+
+// runBootstrapRestore rebuilds a daemon's config and state on a replacement
+// host from the archive the old host kept on the backup server
+func runBootstrapRestore(configPath string, opts bootstrapOptions) error {
+	user, host, ok := strings.Cut(opts.from, "@")
+	if !ok || user == "" || host == "" {
+		return fmt.Errorf("-bootstrap-from must be user@host")
+	}
+
+	if opts.host == "" {
+		name, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		opts.host = name
+	}
+
+	// Holding the lock guarantees no daemon is using the state we overwrite
+	dir, err := state.Open(opts.stateDir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	sshTransport := transport.NewSSHTransport(&config.SSHConfig{
+		RemoteHost: host,
+		RemoteUser: user,
+		PrivateKey: opts.key,
+	})
+	defer sshTransport.Close()
+
+	remotePath := selfbackup.RemotePath(opts.remoteDir, opts.host)
+	log.Printf("Restoring zfsrabbit config and state from %s:%s", host, remotePath)
+
+	if err := selfbackup.Restore(sshTransport, remotePath, configPath, opts.stateDir); err != nil {
+		return err
+	}
+
+	if _, err := config.Load(configPath); err != nil {
+		return fmt.Errorf("restored config is invalid: %w", err)
+	}
+
+	log.Printf("Restored config to %s and state to %s; start zfsrabbit to resume", configPath, opts.stateDir)
+	return nil
+}
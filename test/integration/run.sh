@@ -0,0 +1,27 @@
+#!/bin/bash
+
+# Runs the ZFS integration tests. Inside the sandbox container it first
+# starts sshd with a throwaway key so the transport tests can replicate to
+# localhost; on a host it uses whatever ZFSRABBIT_INTEGRATION_SSH_KEY
+# points at, and skips the transport tests if nothing does.
+
+set -e
+
+if [ ! -e /dev/zfs ]; then
+    echo "/dev/zfs not found: load the zfs kernel module (modprobe zfs) and run the container --privileged" >&2
+    exit 1
+fi
+
+if [ -z "$ZFSRABBIT_INTEGRATION_SSH_KEY" ] && [ -x /usr/sbin/sshd ] && [ -f /.dockerenv ]; then
+    key=/root/.ssh/zfsrabbit-integration
+    mkdir -p /root/.ssh /run/sshd
+    chmod 700 /root/.ssh
+    ssh-keygen -q -t ed25519 -N "" -f "$key"
+    cat "$key.pub" >> /root/.ssh/authorized_keys
+    ssh-keygen -A
+    /usr/sbin/sshd -o PermitRootLogin=prohibit-password
+    export ZFSRABBIT_INTEGRATION_SSH_KEY="$key"
+fi
+
+export ZFSRABBIT_INTEGRATION=1
+exec go test -v -count=1 -tags=integration ./test/integration/... "$@"
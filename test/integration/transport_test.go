@@ -0,0 +1,104 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"zfsrabbit/internal/transport"
+	"zfsrabbit/internal/zfs"
+	"zfsrabbit/test/zfstest"
+)
+
+// sendOverSSH streams a zfs send into the transport's remote dataset
+func sendOverSSH(t *testing.T, ssh *transport.SSHTransport, manager *zfs.Manager, from, to string) {
+	t.Helper()
+
+	send, _ := manager.SendSnapshot(to)
+	if from != "" {
+		send, _ = manager.SendIncremental(from, to)
+	}
+	stdout, err := send.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := send.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ssh.SendSnapshot(stdout, from != ""); err != nil {
+		send.Process.Kill()
+		send.Wait()
+		t.Fatalf("SendSnapshot() failed: %v", err)
+	}
+	if err := send.Wait(); err != nil {
+		t.Fatalf("zfs send failed: %v", err)
+	}
+}
+
+func TestReplicateAndRestoreOverSSH(t *testing.T) {
+	source := zfstest.NewPool(t, "zrsrc")
+	backup := zfstest.NewPool(t, "zrdst")
+	restored := zfstest.NewPool(t, "zrres")
+	dataset := source.Dataset(t, "data")
+	remoteDataset := backup.Name + "/data"
+
+	ssh := transport.NewSSHTransport(zfstest.SSHConfig(t, remoteDataset))
+	if err := ssh.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer ssh.Close()
+
+	manager := zfs.New(dataset, "lz4", false)
+	source.WriteFile(t, dataset, "file.txt", "first")
+	if err := manager.CreateSnapshot("autosnap_1"); err != nil {
+		t.Fatal(err)
+	}
+	sendOverSSH(t, ssh, manager, "", "autosnap_1")
+
+	source.WriteFile(t, dataset, "file.txt", "second")
+	if err := manager.CreateSnapshot("autosnap_2"); err != nil {
+		t.Fatal(err)
+	}
+	sendOverSSH(t, ssh, manager, "autosnap_1", "autosnap_2")
+
+	remote, err := ssh.ListRemoteSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"autosnap_1", "autosnap_2"}; !reflect.DeepEqual(remote, want) {
+		t.Errorf("ListRemoteSnapshots() = %v, want %v", remote, want)
+	}
+
+	// Receiving with -d strips the backup pool's name, giving <pool>/data
+	var progressed bool
+	err = ssh.Restore(context.Background(), transport.RestoreRequest{
+		Snapshot:     "autosnap_1",
+		LocalDataset: restored.Name,
+		Progress:     func(transport.ProgressInfo) { progressed = true },
+	})
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if got := restored.ReadFile(t, restored.Name+"/data", "file.txt"); got != "first" {
+		t.Errorf("Restored file = %q, want %q", got, "first")
+	}
+	if !progressed {
+		t.Error("Restore reported no progress")
+	}
+
+	// Then catch up with the later snapshot incrementally
+	err = ssh.Restore(context.Background(), transport.RestoreRequest{
+		Snapshot:     "autosnap_2",
+		Since:        "autosnap_1",
+		LocalDataset: restored.Name,
+		Force:        true,
+	})
+	if err != nil {
+		t.Fatalf("Incremental Restore() failed: %v", err)
+	}
+	if got := restored.ReadFile(t, restored.Name+"/data", "file.txt"); got != "second" {
+		t.Errorf("Restored file = %q, want %q", got, "second")
+	}
+}
@@ -0,0 +1,197 @@
+//go:build integration
+
+package integration
+
+import (
+	"io"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"zfsrabbit/internal/zfs"
+	"zfsrabbit/test/zfstest"
+)
+
+// pipe runs send into receive, as the scheduler does over SSH
+func pipe(t *testing.T, send, receive *exec.Cmd) {
+	t.Helper()
+
+	reader, writer := io.Pipe()
+	send.Stdout = writer
+	receive.Stdin = reader
+	if err := receive.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sendErr := send.Run()
+	writer.CloseWithError(sendErr)
+	if err := receive.Wait(); err != nil {
+		t.Fatalf("receive failed: %v", err)
+	}
+	if sendErr != nil {
+		t.Fatalf("send failed: %v", sendErr)
+	}
+}
+
+func TestSnapshotSendReceive(t *testing.T) {
+	source := zfstest.NewPool(t, "zrsrc")
+	backup := zfstest.NewPool(t, "zrdst")
+	dataset := source.Dataset(t, "data")
+	target := backup.Name + "/data"
+
+	manager := zfs.New(dataset, "lz4", false)
+	source.WriteFile(t, dataset, "file.txt", "first")
+	if err := manager.CreateSnapshot("autosnap_1"); err != nil {
+		t.Fatal(err)
+	}
+
+	send, _ := manager.SendSnapshot("autosnap_1")
+	receive, _ := manager.ReceiveSnapshot(target)
+	pipe(t, send, receive)
+
+	source.WriteFile(t, dataset, "file.txt", "second")
+	if err := manager.CreateSnapshot("autosnap_2"); err != nil {
+		t.Fatal(err)
+	}
+	send, _ = manager.SendIncremental("autosnap_1", "autosnap_2")
+	receive, _ = manager.ReceiveSnapshot(target)
+	pipe(t, send, receive)
+
+	if got, want := zfstest.Snapshots(t, target), []string{"autosnap_1", "autosnap_2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Backup snapshots = %v, want %v", got, want)
+	}
+	if got := backup.ReadFile(t, target, "file.txt"); got != "second" {
+		t.Errorf("Backup file = %q, want %q", got, "second")
+	}
+
+	snapshots, err := manager.ListSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[1].Name != "autosnap_2" || snapshots[1].Dataset != dataset {
+		t.Errorf("ListSnapshots() = %+v", snapshots)
+	}
+}
+
+func TestBookmarkIncremental(t *testing.T) {
+	source := zfstest.NewPool(t, "zrsrc")
+	backup := zfstest.NewPool(t, "zrdst")
+	dataset := source.Dataset(t, "data")
+	target := backup.Name + "/data"
+
+	manager := zfs.New(dataset, "lz4", false)
+	source.WriteFile(t, dataset, "file.txt", "first")
+	if err := manager.CreateSnapshot("autosnap_1"); err != nil {
+		t.Fatal(err)
+	}
+	send, _ := manager.SendSnapshot("autosnap_1")
+	receive, _ := manager.ReceiveSnapshot(target)
+	pipe(t, send, receive)
+
+	// Retention may destroy the snapshot once it is bookmarked
+	if err := manager.CreateBookmark("autosnap_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.DestroySnapshot("autosnap_1"); err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, err := manager.ListBookmarks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bookmarks, []string{"autosnap_1"}) {
+		t.Fatalf("ListBookmarks() = %v", bookmarks)
+	}
+
+	source.WriteFile(t, dataset, "file.txt", "second")
+	if err := manager.CreateSnapshot("autosnap_2"); err != nil {
+		t.Fatal(err)
+	}
+	send, _ = manager.SendIncrementalFromBookmark("autosnap_1", "autosnap_2")
+	receive, _ = manager.ReceiveSnapshot(target)
+	pipe(t, send, receive)
+
+	if got := backup.ReadFile(t, target, "file.txt"); got != "second" {
+		t.Errorf("Backup file = %q, want %q", got, "second")
+	}
+}
+
+func TestHolds(t *testing.T) {
+	source := zfstest.NewPool(t, "zrsrc")
+	dataset := source.Dataset(t, "data")
+
+	manager := zfs.New(dataset, "lz4", false)
+	if err := manager.CreateSnapshot("autosnap_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.HoldSnapshot("autosnap_1", "zfsrabbit-send"); err != nil {
+		t.Fatal(err)
+	}
+
+	holds, err := manager.ListHolds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 1 || holds[0].Snapshot != "autosnap_1" || holds[0].Tag != "zfsrabbit-send" || holds[0].Dataset != dataset {
+		t.Fatalf("ListHolds() = %+v", holds)
+	}
+	if err := manager.DestroySnapshot("autosnap_1"); err == nil {
+		t.Error("Destroying a held snapshot should fail")
+	}
+
+	if err := manager.ReleaseSnapshot("autosnap_1", "zfsrabbit-send"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.DestroySnapshot("autosnap_1"); err != nil {
+		t.Errorf("Destroying a released snapshot failed: %v", err)
+	}
+}
+
+func TestRecursiveExcludedChildren(t *testing.T) {
+	source := zfstest.NewPool(t, "zrsrc")
+	backup := zfstest.NewPool(t, "zrdst")
+	dataset := source.Dataset(t, "data")
+	source.Dataset(t, "data/app")
+	managed := source.Dataset(t, "data/db")
+
+	manager := zfs.New(dataset, "lz4", true)
+	manager.SetExcluded([]string{managed})
+	if err := manager.CreateSnapshot("autosnap_1"); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots := zfstest.Snapshots(t, managed); len(snapshots) != 0 {
+		t.Errorf("Excluded child was snapshotted: %v", snapshots)
+	}
+	if snapshots := zfstest.Snapshots(t, dataset+"/app"); len(snapshots) != 1 {
+		t.Errorf("Included child snapshots = %v", snapshots)
+	}
+
+	send, _ := manager.SendSnapshot("autosnap_1")
+	receive, _ := manager.ReceiveSnapshot(backup.Name + "/data")
+	pipe(t, send, receive)
+
+	zfstest.Run(t, "zfs", "list", backup.Name+"/data/app")
+	if err := exec.Command("zfs", "list", backup.Name+"/data/db").Run(); err == nil {
+		t.Error("Excluded child was sent")
+	}
+}
+
+func TestDatasetGUID(t *testing.T) {
+	source := zfstest.NewPool(t, "zrsrc")
+	dataset := source.Dataset(t, "data")
+
+	manager := zfs.New(dataset, "lz4", false)
+	guid, err := manager.DatasetGUID()
+	if err != nil || guid == "" {
+		t.Fatalf("DatasetGUID() = %q, %v", guid, err)
+	}
+
+	renamed := source.Name + "/renamed"
+	zfstest.Run(t, "zfs", "rename", dataset, renamed)
+	found, err := manager.FindDatasetByGUID(guid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != renamed {
+		t.Errorf("FindDatasetByGUID() = %q, want %q", found, renamed)
+	}
+}
@@ -7,6 +7,7 @@ import (
 // MockAlerter mocks alert functionality
 type MockAlerter struct {
 	SentAlerts       []Alert
+	SyncStarts       []SyncStart
 	SyncSuccesses    []SyncSuccess
 	SyncFailures     []SyncFailure
 	SystemStatuses   []map[string]interface{}
@@ -19,6 +20,13 @@ type Alert struct {
 	Body    string
 }
 
+type SyncStart struct {
+	Snapshot       string
+	Dataset        string
+	EstimatedBytes int64
+	ETA            time.Duration
+}
+
 type SyncSuccess struct {
 	Snapshot string
 	Dataset  string
@@ -48,6 +56,16 @@ func (m *MockAlerter) SendAlert(subject, body string) error {
 	return m.SendAlertError
 }
 
+func (m *MockAlerter) SendSyncStart(snapshot, dataset string, estimatedBytes int64, eta time.Duration) error {
+	m.SyncStarts = append(m.SyncStarts, SyncStart{
+		Snapshot:       snapshot,
+		Dataset:        dataset,
+		EstimatedBytes: estimatedBytes,
+		ETA:            eta,
+	})
+	return nil
+}
+
 func (m *MockAlerter) SendSyncSuccess(snapshot, dataset string, duration time.Duration) error {
 	m.SyncSuccesses = append(m.SyncSuccesses, SyncSuccess{
 		Snapshot: snapshot,
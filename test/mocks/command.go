@@ -1,16 +1,15 @@
 package mocks
 
 import (
+	"context"
 	"os/exec"
 	"strings"
+
+	"zfsrabbit/internal/utils"
 )
 
 // CommandExecutor interface for mocking command execution
-type CommandExecutor interface {
-	Command(name string, args ...string) *exec.Cmd
-	Output(cmd *exec.Cmd) ([]byte, error)
-	Run(cmd *exec.Cmd) error
-}
+type CommandExecutor = utils.CommandRunner
 
 // MockCommandExecutor mocks command execution
 type MockCommandExecutor struct {
@@ -47,6 +46,10 @@ func (m *MockCommandExecutor) Command(name string, args ...string) *exec.Cmd {
 	return &exec.Cmd{}
 }
 
+func (m *MockCommandExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return m.Command(name, args...)
+}
+
 func (m *MockCommandExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
 	if len(m.Commands) == 0 {
 		return []byte(""), nil
@@ -0,0 +1,181 @@
+// Package zfstest sets up throwaway ZFS pools for integration tests. Pools
+// are backed by sparse files in the test's temp dir and destroyed when the
+// test ends, so the tests can exercise real snapshots, sends and receives
+// without touching the host's pools.
+//
+// The tests only run when ZFSRABBIT_INTEGRATION is set, as root, on a
+// machine with the ZFS kernel module loaded; see make test-integration.
+package zfstest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"zfsrabbit/internal/config"
+)
+
+// Environment variables read by the harness
+const (
+	EnvEnable  = "ZFSRABBIT_INTEGRATION"          // Set to run the integration tests at all
+	EnvSSHKey  = "ZFSRABBIT_INTEGRATION_SSH_KEY"  // Private key that can log in as root on EnvSSHHost
+	EnvSSHHost = "ZFSRABBIT_INTEGRATION_SSH_HOST" // Backup server for transport tests, localhost:22 if unset
+)
+
+// vdevSize is the size of each sparse file vdev; ZFS needs at least 64M
+const vdevSize = 256 << 20
+
+// Require skips the test unless the integration tests are enabled and ZFS
+// can be used
+func Require(t *testing.T) {
+	t.Helper()
+
+	if os.Getenv(EnvEnable) == "" {
+		t.Skipf("Set %s=1 to run ZFS integration tests", EnvEnable)
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("ZFS integration tests must run as root")
+	}
+	for _, tool := range []string{"zfs", "zpool"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found: %v", tool, err)
+		}
+	}
+	if _, err := os.Stat("/dev/zfs"); err != nil {
+		t.Skipf("ZFS kernel module not loaded: %v", err)
+	}
+}
+
+// Pool is a file-backed pool that lives as long as the test
+type Pool struct {
+	Name string
+	Root string // Altroot the pool's datasets are mounted under
+}
+
+// NewPool creates a pool on a sparse file with a random name starting with
+// prefix, and destroys it when the test ends
+func NewPool(t *testing.T, prefix string) *Pool {
+	t.Helper()
+	Require(t)
+
+	dir := t.TempDir()
+	vdev := filepath.Join(dir, "vdev")
+	file, err := os.Create(vdev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Truncate(vdevSize); err != nil {
+		file.Close()
+		t.Fatal(err)
+	}
+	file.Close()
+
+	pool := &Pool{Name: prefix + randomSuffix(t), Root: filepath.Join(dir, "mnt")}
+	Run(t, "zpool", "create", "-f", "-R", pool.Root, "-O", "mountpoint=/"+pool.Name, pool.Name, vdev)
+	t.Cleanup(func() {
+		if output, err := exec.Command("zpool", "destroy", "-f", pool.Name).CombinedOutput(); err != nil {
+			t.Logf("Failed to destroy pool %s: %v: %s", pool.Name, err, output)
+		}
+	})
+	return pool
+}
+
+// Dataset creates a filesystem in the pool and returns its full name
+func (p *Pool) Dataset(t *testing.T, name string) string {
+	t.Helper()
+	dataset := p.Name + "/" + name
+	Run(t, "zfs", "create", "-p", dataset)
+	return dataset
+}
+
+// WriteFile writes a file inside a mounted dataset of the pool
+func (p *Pool) WriteFile(t *testing.T, dataset, name, content string) {
+	t.Helper()
+	path := filepath.Join(Mountpoint(t, dataset), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ReadFile reads a file inside a mounted dataset of the pool
+func (p *Pool) ReadFile(t *testing.T, dataset, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(Mountpoint(t, dataset), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Mountpoint returns where a dataset is mounted, including any altroot
+func Mountpoint(t *testing.T, dataset string) string {
+	t.Helper()
+	return Run(t, "zfs", "get", "-H", "-o", "value", "mountpoint", dataset)
+}
+
+// Snapshots returns the short names of a dataset's snapshots, oldest first
+func Snapshots(t *testing.T, dataset string) []string {
+	t.Helper()
+	output := Run(t, "zfs", "list", "-H", "-o", "name", "-t", "snapshot", "-s", "createtxg", "-d", "1", dataset)
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		if _, name, ok := strings.Cut(line, "@"); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Run runs a command, failing the test if it fails, and returns its
+// trimmed output
+func Run(t *testing.T, name string, args ...string) string {
+	t.Helper()
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s: %v: %s", name, strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// SSHConfig returns settings for replicating to remoteDataset over SSH as
+// root, skipping the test unless a key has been provided. The container
+// started by make test-integration-docker runs sshd and sets this up.
+func SSHConfig(t *testing.T, remoteDataset string) *config.SSHConfig {
+	t.Helper()
+	Require(t)
+
+	key := os.Getenv(EnvSSHKey)
+	if key == "" {
+		t.Skipf("Set %s to a key that can log in as root to run transport tests", EnvSSHKey)
+	}
+	for _, tool := range []string{"mbuffer", "pv"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found: %v", tool, err)
+		}
+	}
+	host := os.Getenv(EnvSSHHost)
+	if host == "" {
+		host = "localhost:22"
+	}
+
+	return &config.SSHConfig{
+		RemoteHost:    host,
+		RemoteUser:    "root",
+		PrivateKey:    key,
+		RemoteDataset: remoteDataset,
+		MbufferSize:   "16M",
+	}
+}
+
+func randomSuffix(t *testing.T) string {
+	t.Helper()
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}